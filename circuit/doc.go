@@ -7,7 +7,8 @@ active breakers and the recycling of the idle ones.
 
 The circuit breakers are always assigned to backend hosts, so that the outcome of requests to one host never
 affects the circuit breaker behavior of another host. Besides hosts, individual routes can have separate circuit
-breakers, too.
+breakers, too. The load balanced routes share a breaker with the other routes using the same set of endpoints,
+unless the outgoing host is preserved from the incoming request.
 
 Breaker Type - Consecutive Failures
 
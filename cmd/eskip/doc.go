@@ -82,7 +82,7 @@ const (
 	prependFileUsage    = "prepend filters from a file to each patched route"
 	appendFiltersUsage  = "append filters to each patched route"
 	appendFileUsage     = "append filters from a file to each patched route"
-	prettyUsage         = "prints routes in a more readable format"
+	prettyUsage         = "prints routes in a more readable format, with -json indents the JSON output"
 	indentStrUsage      = "indent string used in pretty printing. Must match regexp \\s"
 	jsonUsage           = "prints routes as JSON"
 
@@ -116,6 +116,10 @@ func printCmd(a cmdArgs) error {
 	if printJson {
 		e := json.NewEncoder(stdout)
 		e.SetEscapeHTML(false)
+		if pretty {
+			e.SetIndent("", indentStr)
+		}
+
 		if err := e.Encode(lr.routes); err != nil {
 			return err
 		}
@@ -9,6 +9,8 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/zalando/skipper/filters/cache"
 )
 
 func Test_NewConfig(t *testing.T) {
@@ -37,6 +39,8 @@ func Test_NewConfig(t *testing.T) {
 				ConfigFile:                              "test.yaml",
 				Address:                                 "localhost:8080",
 				StatusChecks:                            nil,
+				SourceTrustedProxies:                    commaListFlag(),
+				RetryMethods:                            commaListFlag(),
 				ExpectedBytesPerRequest:                 50 * 1024,
 				SupportListener:                         ":9911",
 				MaxLoopbacks:                            12,
@@ -47,6 +51,12 @@ func Test_NewConfig(t *testing.T) {
 				PredicatePlugins:                        newPluginFlag(),
 				DataclientPlugins:                       newPluginFlag(),
 				MultiPlugins:                            newPluginFlag(),
+				ForwardedHeadersString:                  "keep",
+				ForwardedHeadersTrustedProxies:          commaListFlag(),
+				RequestHeadersAllow:                     commaListFlag(),
+				RequestHeadersDeny:                      commaListFlag(),
+				ResponseHeadersAllow:                    commaListFlag(),
+				ResponseHeadersDeny:                     commaListFlag(),
 				OpenTracing:                             "noop",
 				OpenTracingInitialSpan:                  "ingress",
 				OpentracingLogFilterLifecycleEvents:     true,
@@ -70,7 +80,9 @@ func Test_NewConfig(t *testing.T) {
 				Oauth2TokeninfoTimeout:                  2 * time.Second,
 				Oauth2TokenintrospectionTimeout:         2 * time.Second,
 				WebhookTimeout:                          2 * time.Second,
+				ResponseCacheSize:                       cache.DefaultMemoryStoreSize,
 				CredentialPaths:                         commaListFlag(),
+				XDSRouteConfigurations:                  commaListFlag(),
 				CredentialsUpdateInterval:               10 * time.Minute,
 				ApiUsageMonitoringClientKeys:            "sub",
 				ApiUsageMonitoringRealmsTrackingPattern: "services",
@@ -0,0 +1,328 @@
+/*
+Package consul implements a DataClient for reading the skipper route
+definitions from the key/value store of Consul.
+
+(See the DataClient interface in the skipper/routing package.)
+
+Consul is a service discovery and configuration system:
+https://www.consul.io. The route definitions are stored under individual
+keys below the configured prefix, as eskip route expressions. When loaded
+from Consul, the routes get the last segment of the key as id. Updates are
+received with blocking queries on the key prefix.
+
+In addition to the DataClient implementation, type Client provides
+methods to Upsert and Delete routes.
+*/
+package consul
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/internal/routeset"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	routesPath      = "/routes/"
+	consulIndexHdr  = "X-Consul-Index"
+	consulTokenHdr  = "X-Consul-Token"
+	defaultPrefix   = "skipper"
+	defaultWaitTime = time.Second
+)
+
+// Consul serialization object
+type keyValue struct {
+	Key         string `json:"Key"`
+	Value       string `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// Initialization options.
+type Options struct {
+
+	// Address of the Consul agent.
+	// (Schema and host.)
+	Address string
+
+	// Key prefix in the Consul key/value store, where the
+	// Skipper related settings are stored. The default is
+	// "skipper".
+	Prefix string
+
+	// The maximum duration of the blocking queries used when
+	// waiting for updates. The default is 1 second.
+	WaitTime time.Duration
+
+	// Optional ACL token.
+	Token string
+
+	// Optional Consul datacenter. When not set, the datacenter
+	// of the agent is used.
+	Datacenter string
+}
+
+// A Client is used to load the whole set of routes and the updates from
+// the Consul key/value store.
+type Client struct {
+	address    string
+	routesRoot string
+	waitTime   time.Duration
+	token      string
+	datacenter string
+	client     *http.Client
+	index      uint64
+	current    map[string]string
+}
+
+var (
+	errMissingAddress         = errors.New("missing Consul address")
+	errMissingRouteID         = errors.New("missing route id")
+	errNotFound               = errors.New("not found")
+	errUnexpectedHTTPResponse = errors.New("unexpected http response")
+	errUpdateRejected         = errors.New("update rejected")
+)
+
+// New creates a Client with the provided options.
+func New(o Options) (*Client, error) {
+	if o.Address == "" {
+		return nil, errMissingAddress
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = defaultPrefix
+	}
+
+	if o.WaitTime <= 0 {
+		o.WaitTime = defaultWaitTime
+	}
+
+	return &Client{
+		address:    strings.TrimSuffix(o.Address, "/"),
+		routesRoot: strings.Trim(o.Prefix, "/") + routesPath,
+		waitTime:   o.WaitTime,
+		token:      o.Token,
+		datacenter: o.Datacenter,
+
+		// Consul adds a random jitter of up to 1/16th of the
+		// wait time to the blocking queries
+		client: &http.Client{Timeout: o.WaitTime + o.WaitTime/16 + time.Second},
+
+		current: make(map[string]string),
+	}, nil
+}
+
+func (c *Client) request(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	if c.datacenter != "" {
+		if query == nil {
+			query = make(url.Values)
+		}
+
+		query.Set("dc", c.datacenter)
+	}
+
+	u := c.address + "/v1/kv/" + key
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		req.Header.Set(consulTokenHdr, c.token)
+	}
+
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode == http.StatusNotFound {
+		rsp.Body.Close()
+		return rsp, errNotFound
+	}
+
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		rsp.Body.Close()
+		return nil, errUnexpectedHTTPResponse
+	}
+
+	return rsp, nil
+}
+
+// Loads the route expressions stored under the prefix. When index is
+// not 0, it makes a blocking query, that returns when the stored data
+// changed, or the wait time was reached.
+func (c *Client) loadData(index uint64) (map[string]string, uint64, error) {
+	q := make(url.Values)
+	q.Set("recurse", "true")
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%dms", c.waitTime/time.Millisecond))
+	}
+
+	rsp, err := c.request("GET", c.routesRoot, q, nil)
+	if err == errNotFound {
+		return make(map[string]string), parseIndex(rsp), nil
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rsp.Body.Close()
+
+	var kvs []*keyValue
+	if err := json.NewDecoder(rsp.Body).Decode(&kvs); err != nil {
+		return nil, 0, err
+	}
+
+	data := make(map[string]string)
+	for _, kv := range kvs {
+		id := strings.TrimPrefix(kv.Key, c.routesRoot)
+		if id == "" || strings.Contains(id, "/") {
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			log.Errorf("error while decoding route %s: %v", id, err)
+			continue
+		}
+
+		data[id] = string(value)
+	}
+
+	return data, parseIndex(rsp), nil
+}
+
+func parseIndex(rsp *http.Response) uint64 {
+	index, _ := strconv.ParseUint(rsp.Header.Get(consulIndexHdr), 10, 64)
+	return index
+}
+
+// Consul requires resetting the index when it goes backwards, and
+// the index needs to be greater than 0.
+func (c *Client) setIndex(index uint64) {
+	if index < c.index || index == 0 {
+		index = 1
+	}
+
+	c.index = index
+}
+
+// LoadAll returns all the route definitions currently stored in Consul.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	data, index, err := c.loadData(0)
+	if err != nil {
+		return nil, err
+	}
+
+	c.current = data
+	c.setIndex(index)
+	return routeset.ParseLogged(data), nil
+}
+
+// LoadUpdate returns the updates (upserts and deletes) since the last
+// initial request or update.
+//
+// It uses a blocking query on the key prefix, that results in blocking
+// this call until the next change is detected in Consul or the configured
+// wait time is reached.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	data, index, err := c.loadData(c.index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.setIndex(index)
+
+	updates, deletedIDs := routeset.DiffData(c.current, data)
+	c.current = data
+	routes, deletedIDs := routeset.ParseUpdates(updates, deletedIDs)
+	return routes, deletedIDs, nil
+}
+
+// Upsert inserts or updates a route in Consul.
+func (c *Client) Upsert(r *eskip.Route) error {
+	if r.Id == "" {
+		return errMissingRouteID
+	}
+
+	rsp, err := c.request("PUT", c.routesRoot+r.Id, nil, []byte(r.String()))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	var ok bool
+	if err := json.NewDecoder(rsp.Body).Decode(&ok); err != nil {
+		return err
+	}
+
+	if !ok {
+		return errUpdateRejected
+	}
+
+	return nil
+}
+
+// Delete deletes a route from Consul.
+func (c *Client) Delete(id string) error {
+	if id == "" {
+		return errMissingRouteID
+	}
+
+	rsp, err := c.request("DELETE", c.routesRoot+id, nil, nil)
+	if err == errNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Body.Close()
+	return nil
+}
+
+// UpsertAll inserts or updates all the routes, generating an id for those
+// that don't have one.
+func (c *Client) UpsertAll(routes []*eskip.Route) error {
+	for _, r := range routes {
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		if err := c.Upsert(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteAllIf deletes the routes that match the condition.
+func (c *Client) DeleteAllIf(routes []*eskip.Route, cond eskip.RoutePredicate) error {
+	for _, r := range routes {
+		if !cond(r) {
+			continue
+		}
+
+		if err := c.Delete(r.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package consul
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type kvStore struct {
+	mx    sync.Mutex
+	index uint64
+	data  map[string]string
+	token string
+}
+
+func (s *kvStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.token = r.Header.Get(consulTokenHdr)
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	switch r.Method {
+	case "GET":
+		var kvs []*keyValue
+		for k, v := range s.data {
+			if strings.HasPrefix(k, key) {
+				kvs = append(kvs, &keyValue{Key: k, Value: base64.StdEncoding.EncodeToString([]byte(v))})
+			}
+		}
+
+		w.Header().Set(consulIndexHdr, strconv.FormatUint(s.index, 10))
+		if len(kvs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(kvs)
+	case "PUT":
+		b, _ := ioutil.ReadAll(r.Body)
+		s.data[key] = string(b)
+		s.index++
+		w.Write([]byte("true"))
+	case "DELETE":
+		delete(s.data, key)
+		s.index++
+		w.Write([]byte("true"))
+	}
+}
+
+func testClient(t *testing.T, data map[string]string) (*kvStore, *httptest.Server, *Client) {
+	kv := &kvStore{index: 42, data: data}
+	s := httptest.NewServer(kv)
+	c, err := New(Options{Address: s.URL, Prefix: "/skippertest/", WaitTime: 10 * time.Millisecond, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kv, s, c
+}
+
+func routeIDs(routes []*eskip.Route) string {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestMissingAddress(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingAddress {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	kv, s, c := testClient(t, map[string]string{
+		"skippertest/routes/foo":     `Path("/foo") -> "https://foo.example.org"`,
+		"skippertest/routes/bar":     `Path("/bar") -> "https://bar.example.org"`,
+		"skippertest/routes/invalid": `invalid expression`,
+		"skippertest/other":          `* -> "https://other.example.org"`,
+	})
+	defer s.Close()
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "bar,foo" {
+		t.Error("failed to load routes", ids)
+	}
+
+	if kv.token != "secret" {
+		t.Error("failed to send token")
+	}
+}
+
+func TestLoadAllEmpty(t *testing.T) {
+	_, s, c := testClient(t, map[string]string{})
+	defer s.Close()
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 {
+		t.Error("unexpected routes")
+	}
+}
+
+func TestLoadUpdate(t *testing.T) {
+	kv, s, c := testClient(t, map[string]string{
+		"skippertest/routes/foo": `Path("/foo") -> "https://foo.example.org"`,
+		"skippertest/routes/bar": `Path("/bar") -> "https://bar.example.org"`,
+	})
+	defer s.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Error("unexpected update")
+	}
+
+	if err := c.Upsert(&eskip.Route{Id: "foo", Backend: "https://foo2.example.org"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Upsert(&eskip.Route{Id: "baz", Backend: "https://baz.example.org"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "baz,foo" {
+		t.Error("failed to receive upserts", ids)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive deletes", deletedIDs)
+	}
+
+	if c.index != kv.index {
+		t.Error("failed to update the index", c.index, kv.index)
+	}
+}
+
+func TestUpsertDeleteNoID(t *testing.T) {
+	_, s, c := testClient(t, map[string]string{})
+	defer s.Close()
+
+	if err := c.Upsert(&eskip.Route{}); err != errMissingRouteID {
+		t.Error("failed to fail upsert")
+	}
+
+	if err := c.Delete(""); err != errMissingRouteID {
+		t.Error("failed to fail delete")
+	}
+}
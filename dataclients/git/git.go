@@ -0,0 +1,262 @@
+/*
+Package git implements a DataClient for reading the skipper route
+definitions from eskip files in a git repository.
+
+(See the DataClient interface in the skipper/routing package and the eskip
+format in the skipper/eskip package.)
+
+The client clones the repository into a local directory, and loads every
+file with the .eskip extension from the configured path of the
+repository, merging the routes of all the files. The updates are received
+by pulling the repository in the configured interval, and when the
+checked out revision changed, the difference of the routes is returned.
+When a file fails to load, e.g. because it contains invalid eskip, the
+error is logged, and the previous routes of the file are kept.
+
+The client uses the git command, which needs to be available in the
+PATH. The authentication with the remote repository can be set up in the
+usual ways of git, e.g. with SSH keys or credential helpers.
+*/
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/internal/routeset"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	defaultPullInterval = time.Minute
+	eskipExtension      = ".eskip"
+)
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// URL of the git repository. Required.
+	Repository string
+
+	// The branch to check out. When not set, the default branch
+	// of the remote repository is used.
+	Branch string
+
+	// Path of the directory in the repository containing the eskip
+	// files. The subdirectories are included. Defaults to the root
+	// of the repository.
+	Path string
+
+	// Local directory for the checkout. When not set, a temporary
+	// directory is created.
+	Dir string
+
+	// Minimum time between two pulls of the repository. Defaults to
+	// 1 minute.
+	PullInterval time.Duration
+}
+
+// Client loads the routes from the eskip files of a git repository.
+type Client struct {
+	repository   string
+	branch       string
+	path         string
+	dir          string
+	pullInterval time.Duration
+	lastPull     time.Time
+	revision     string
+	files        map[string][]*eskip.Route
+	routes       map[string]*eskip.Route
+}
+
+var errMissingRepository = errors.New("missing git repository")
+
+// New creates a Client with the provided options. It doesn't clone the
+// repository, that happens during the first call to LoadAll.
+func New(o Options) (*Client, error) {
+	if o.Repository == "" {
+		return nil, errMissingRepository
+	}
+
+	if o.PullInterval <= 0 {
+		o.PullInterval = defaultPullInterval
+	}
+
+	if o.Dir == "" {
+		dir, err := ioutil.TempDir("", "skipper-git-routes")
+		if err != nil {
+			return nil, err
+		}
+
+		o.Dir = dir
+	}
+
+	return &Client{
+		repository:   o.Repository,
+		branch:       o.Branch,
+		path:         o.Path,
+		dir:          o.Dir,
+		pullInterval: o.PullInterval,
+	}, nil
+}
+
+func (c *Client) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	/* #nosec */
+	cmd := exec.Command("git", append([]string{"-C", c.dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// clones the repository, unless it was cloned already
+func (c *Client) clone() error {
+	if _, err := os.Stat(filepath.Join(c.dir, ".git")); err == nil {
+		return nil
+	}
+
+	args := []string{"clone", "--depth", "1"}
+	if c.branch != "" {
+		args = append(args, "--branch", c.branch)
+	}
+
+	_, err := c.git(append(args, "--", c.repository, ".")...)
+	return err
+}
+
+// fetches the latest state of the branch, and checks it out
+func (c *Client) pull() error {
+	ref := "HEAD"
+	if c.branch != "" {
+		ref = c.branch
+	}
+
+	if _, err := c.git("fetch", "--depth", "1", "origin", ref); err != nil {
+		return err
+	}
+
+	_, err := c.git("reset", "--hard", "FETCH_HEAD")
+	return err
+}
+
+// loads the eskip files, and merges their routes in the order of the
+// file names. Files that fail to load keep their previous routes.
+func (c *Client) loadFiles() map[string]*eskip.Route {
+	var files []string
+	root := filepath.Join(c.dir, c.path)
+	filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			log.Errorf("error while reading git checkout %s: %v", p, err)
+			return nil
+		}
+
+		if fi.IsDir() && fi.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), eskipExtension) {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+
+	sort.Strings(files)
+	loaded := make(map[string][]*eskip.Route)
+	routes := make(map[string]*eskip.Route)
+	for _, f := range files {
+		parsed, err := loadFile(f)
+		if err != nil {
+			log.Errorf("error while loading eskip file %s, keeping its previous routes: %v", f, err)
+			parsed = c.files[f]
+		}
+
+		if parsed == nil {
+			continue
+		}
+
+		loaded[f] = parsed
+		for _, r := range parsed {
+			routes[r.Id] = r
+		}
+	}
+
+	c.files = loaded
+	return routes
+}
+
+func loadFile(name string) ([]*eskip.Route, error) {
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return eskip.Parse(string(content))
+}
+
+// LoadAll clones or pulls the repository, and returns the routes from all
+// the eskip files.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	if err := c.clone(); err != nil {
+		return nil, err
+	}
+
+	if err := c.pull(); err != nil {
+		return nil, err
+	}
+
+	c.lastPull = time.Now()
+	revision, err := c.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	c.revision = revision
+	c.routes = c.loadFiles()
+	return routeset.Clone(c.routes), nil
+}
+
+// LoadUpdate pulls the repository, when the pull interval has passed since
+// the last pull, and returns the changed and deleted routes when a new
+// revision was checked out.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if time.Since(c.lastPull) < c.pullInterval {
+		return nil, nil, nil
+	}
+
+	if err := c.pull(); err != nil {
+		return nil, nil, err
+	}
+
+	c.lastPull = time.Now()
+	revision, err := c.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if revision == c.revision {
+		return nil, nil, nil
+	}
+
+	log.Infof("routes updated from git revision %s to %s", c.revision, revision)
+	c.revision = revision
+	routes := c.loadFiles()
+
+	upserts, deletedIDs := routeset.Diff(c.routes, routes)
+	c.routes = routes
+	return upserts, deletedIDs, nil
+}
@@ -0,0 +1,167 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type sourceRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newSourceRepo(t *testing.T) *sourceRepo {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir, err := ioutil.TempDir("", "skipper-git-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &sourceRepo{t: t, dir: dir}
+	r.git("init", "-q")
+	r.git("config", "user.email", "test@example.org")
+	r.git("config", "user.name", "test")
+	return r
+}
+
+func (r *sourceRepo) git(args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+func (r *sourceRepo) commit(files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(r.dir, name)
+		if content == "" {
+			r.git("rm", "-q", name)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+
+		r.git("add", name)
+	}
+
+	r.git("commit", "-q", "-m", "update routes")
+}
+
+func routeIDs(routes []*eskip.Route) string {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestMissingRepository(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingRepository {
+		t.Error("failed to fail")
+	}
+}
+
+func TestGitRoutes(t *testing.T) {
+	source := newSourceRepo(t)
+	defer os.RemoveAll(source.dir)
+
+	source.commit(map[string]string{
+		"routes/a.eskip":     `foo: Path("/foo") -> "https://foo.example.org";`,
+		"routes/sub/b.eskip": `bar: Path("/bar") -> "https://bar.example.org";`,
+		"routes/c.txt":       `baz: Path("/baz") -> "https://baz.example.org";`,
+		"other.eskip":        `qux: Path("/qux") -> "https://qux.example.org";`,
+	})
+
+	c, err := New(Options{
+		Repository:   "file://" + source.dir,
+		Path:         "routes",
+		PullInterval: time.Nanosecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(c.dir)
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "bar,foo" {
+		t.Error("failed to load routes", ids)
+	}
+
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Error("unexpected update", routes, deletedIDs)
+	}
+
+	source.commit(map[string]string{
+		"routes/a.eskip":     `foo: Path("/foo") -> "https://foo2.example.org";`,
+		"routes/sub/b.eskip": "",
+	})
+
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "foo" || routes[0].Backend != "https://foo2.example.org" {
+		t.Error("failed to receive upsert", routes)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive delete", deletedIDs)
+	}
+	source.commit(map[string]string{
+		"routes/a.eskip":     `foo: Path("/foo") -> "https://foo3.example.org"; invalid`,
+		"routes/sub/b.eskip": `bar: Path("/bar") -> "https://bar.example.org";`,
+	})
+
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "bar" || len(deletedIDs) != 0 {
+		t.Error("failed to keep the routes of the invalid file", routes, deletedIDs)
+	}
+
+	source.commit(map[string]string{
+		"routes/a.eskip": `foo: Path("/foo") -> "https://foo3.example.org";`,
+	})
+
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "foo" || routes[0].Backend != "https://foo3.example.org" || len(deletedIDs) != 0 {
+		t.Error("failed to receive the fixed file", routes, deletedIDs)
+	}
+}
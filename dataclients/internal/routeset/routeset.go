@@ -0,0 +1,124 @@
+/*
+Package routeset contains the helpers shared by the data clients, that
+store the route definitions as eskip documents or as single route
+expressions, for parsing them and for diffing the loaded routes against
+the previous state.
+*/
+package routeset
+
+import (
+	"errors"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+)
+
+// ErrMultipleExpressions is returned when a single route entry contains
+// more than one route expressions.
+var ErrMultipleExpressions = errors.New("invalid route entry: multiple route expressions")
+
+// ParseOne parses a single route expression, and sets the id of the
+// route. It fails when the data contains more than one expressions.
+func ParseOne(id, data string) (*eskip.Route, error) {
+	r, err := eskip.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r) != 1 {
+		return nil, ErrMultipleExpressions
+	}
+
+	r[0].Id = id
+	return r[0], nil
+}
+
+// ParseLogged parses the route expressions stored by their ids, and logs
+// and omits those whose parsing failed.
+func ParseLogged(data map[string]string) []*eskip.Route {
+	var routes []*eskip.Route
+	for id, d := range data {
+		r, err := ParseOne(id, d)
+		if err != nil {
+			log.Errorf("error while parsing route %s: %v", id, err)
+			continue
+		}
+
+		routes = append(routes, r)
+	}
+
+	return routes
+}
+
+// ParseUpdates parses the new or changed route expressions, stored by
+// their ids, and appends the ids of those whose parsing failed to the
+// deleted ids, after logging the error. This way, an invalid update
+// deletes the route, the same way as the invalid routes are omitted when
+// loading all the routes, instead of keeping the previous definition.
+func ParseUpdates(updates map[string]string, deletedIDs []string) ([]*eskip.Route, []string) {
+	var routes []*eskip.Route
+	for id, d := range updates {
+		r, err := ParseOne(id, d)
+		if err != nil {
+			log.Errorf("error while parsing route %s, deleting it: %v", id, err)
+			deletedIDs = append(deletedIDs, id)
+			continue
+		}
+
+		routes = append(routes, r)
+	}
+
+	return routes, deletedIDs
+}
+
+// DiffData compares two sets of route expressions stored by their ids,
+// and returns the new or changed expressions, and the ids of the deleted
+// ones.
+func DiffData(old, new map[string]string) (map[string]string, []string) {
+	updates := make(map[string]string)
+	for id, d := range new {
+		if current, ok := old[id]; !ok || current != d {
+			updates[id] = d
+		}
+	}
+
+	var deletedIDs []string
+	for id := range old {
+		if _, ok := new[id]; !ok {
+			deletedIDs = append(deletedIDs, id)
+		}
+	}
+
+	return updates, deletedIDs
+}
+
+func values(m map[string]*eskip.Route) []*eskip.Route {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	routes := make([]*eskip.Route, len(ids))
+	for i, id := range ids {
+		routes[i] = m[id]
+	}
+
+	return routes
+}
+
+// Clone returns a copy of the routes stored by their ids, ordered by the
+// ids, so that the caller can modify them without affecting the stored
+// state.
+func Clone(m map[string]*eskip.Route) []*eskip.Route {
+	return eskip.CopyRoutes(values(m))
+}
+
+// Diff compares two sets of routes stored by their ids, and returns a
+// copy of the new or changed routes, and the ids of the deleted ones.
+// The routes are compared with eskip.Diff.
+func Diff(old, new map[string]*eskip.Route) ([]*eskip.Route, []string) {
+	upserts, deletedIDs := eskip.Diff(values(old), values(new))
+	return eskip.CopyRoutes(upserts), deletedIDs
+}
@@ -0,0 +1,107 @@
+package routeset
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func TestParseOne(t *testing.T) {
+	r, err := ParseOne("foo", `Path("/foo") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Id != "foo" {
+		t.Error("failed to set the id", r.Id)
+	}
+
+	if _, err := ParseOne("foo", `a: * -> <shunt>; b: * -> <shunt>`); err != ErrMultipleExpressions {
+		t.Error("failed to fail", err)
+	}
+}
+
+func TestParseLogged(t *testing.T) {
+	routes := ParseLogged(map[string]string{
+		"foo": `* -> "https://www.example.org"`,
+		"bar": `invalid`,
+	})
+
+	if len(routes) != 1 || routes[0].Id != "foo" {
+		t.Error("unexpected routes", routes)
+	}
+}
+
+func TestDiffData(t *testing.T) {
+	updates, deletedIDs := DiffData(
+		map[string]string{"foo": "1", "bar": "2", "baz": "3"},
+		map[string]string{"foo": "1", "bar": "4", "qux": "5"},
+	)
+
+	if len(updates) != 2 || updates["bar"] != "4" || updates["qux"] != "5" {
+		t.Error("unexpected updates", updates)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "baz" {
+		t.Error("unexpected deletes", deletedIDs)
+	}
+}
+
+func routeMap(t *testing.T, doc string) map[string]*eskip.Route {
+	routes, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string]*eskip.Route)
+	for _, r := range routes {
+		m[r.Id] = r
+	}
+
+	return m
+}
+
+func TestDiff(t *testing.T) {
+	old := routeMap(t, `
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+		baz: Path("/baz") -> "https://baz.example.org";
+	`)
+
+	new := routeMap(t, `
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar2.example.org";
+		qux: Path("/qux") -> "https://qux.example.org";
+	`)
+
+	upserts, deletedIDs := Diff(old, new)
+	if len(upserts) != 2 || upserts[0].Id != "bar" || upserts[1].Id != "qux" {
+		t.Error("unexpected upserts", upserts)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "baz" {
+		t.Error("unexpected deletes", deletedIDs)
+	}
+
+	upserts[0].Backend = "https://changed.example.org"
+	if new["bar"].Backend != "https://bar2.example.org" {
+		t.Error("failed to return a copy")
+	}
+}
+
+func TestParseUpdates(t *testing.T) {
+	routes, deletedIDs := ParseUpdates(map[string]string{
+		"foo": `* -> "https://www.example.org"`,
+		"bar": `invalid`,
+	}, []string{"baz"})
+
+	if len(routes) != 1 || routes[0].Id != "foo" {
+		t.Error("unexpected routes", routes)
+	}
+
+	sort.Strings(deletedIDs)
+	if len(deletedIDs) != 2 || deletedIDs[0] != "bar" || deletedIDs[1] != "baz" {
+		t.Error("failed to delete the invalid route", deletedIDs)
+	}
+}
@@ -16,6 +16,15 @@ Both Kube-ingress-aws-controller and Skipper Kubernetes are part of the larger p
 
 https://github.com/zalando-incubator/kubernetes-on-aws/
 
+RouteGroups
+
+Besides the Ingress resources, the client loads the RouteGroup custom resources, when the RouteGroup CRD is
+installed in the cluster. RouteGroups allow expressing the Skipper routes in a more detailed way than the
+Ingress annotations, and are converted to routes the same way as the ingress rules. When the CRD is not
+installed, the client logs a warning and continues with the Ingress resources only.
+
+See: https://opensource.zalando.com/skipper/kubernetes/routegroups/
+
 Ingress shutdown by healthcheck
 
 The Kubernetes ingress client catches TERM signals when the ProvideHealthcheck option is enabled, and reports
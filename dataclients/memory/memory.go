@@ -0,0 +1,146 @@
+/*
+Package memory implements a DataClient, whose routes are stored in memory
+and can be updated programmatically.
+
+(See the DataClient interface in the skipper/routing package.)
+
+The client is meant for embedding skipper as a library, e.g. by passing
+it in the CustomDataClients field of the skipper options, and for
+integration tests. The changes made with the Upsert, Delete and UpdateDoc
+methods are returned by the next call to LoadUpdate, and they are applied
+by the routing in the next polling cycle.
+
+Unlike the routing/testdataclient package, the methods of the client
+don't block, and they are safe to be called from multiple goroutines.
+*/
+package memory
+
+import (
+	"sync"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// Client stores the routes in memory.
+type Client struct {
+	mx      sync.Mutex
+	routes  map[string]*eskip.Route
+	upserts map[string]*eskip.Route
+	deletes map[string]bool
+}
+
+// New creates a Client with an initial set of routes. Routes without an
+// id get a generated one.
+func New(initial ...*eskip.Route) *Client {
+	c := &Client{routes: make(map[string]*eskip.Route)}
+	c.reset()
+	for _, r := range initial {
+		r = r.Copy()
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		c.routes[r.Id] = r
+	}
+
+	return c
+}
+
+// NewDoc creates a Client with an initial set of routes in eskip format.
+func NewDoc(doc string) (*Client, error) {
+	routes, err := eskip.Parse(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(routes...), nil
+}
+
+func (c *Client) reset() {
+	c.upserts = make(map[string]*eskip.Route)
+	c.deletes = make(map[string]bool)
+}
+
+// LoadAll returns the current set of routes.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.reset()
+	routes := make([]*eskip.Route, 0, len(c.routes))
+	for _, r := range c.routes {
+		routes = append(routes, r.Copy())
+	}
+
+	return routes, nil
+}
+
+// LoadUpdate returns the routes upserted and the ids of the routes
+// deleted since the previous call to LoadAll or LoadUpdate. It doesn't
+// block.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var (
+		upserts    []*eskip.Route
+		deletedIDs []string
+	)
+
+	for _, r := range c.upserts {
+		upserts = append(upserts, r.Copy())
+	}
+
+	for id := range c.deletes {
+		deletedIDs = append(deletedIDs, id)
+	}
+
+	c.reset()
+	return upserts, deletedIDs, nil
+}
+
+func (c *Client) upsert(routes []*eskip.Route) {
+	for _, r := range routes {
+		r = r.Copy()
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		c.routes[r.Id] = r
+		c.upserts[r.Id] = r
+		delete(c.deletes, r.Id)
+	}
+}
+
+func (c *Client) delete(ids []string) {
+	for _, id := range ids {
+		delete(c.routes, id)
+		delete(c.upserts, id)
+		c.deletes[id] = true
+	}
+}
+
+// Upsert inserts or updates routes. Routes without an id get a
+// generated one.
+func (c *Client) Upsert(routes ...*eskip.Route) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.upsert(routes)
+}
+
+// Delete deletes the routes with the provided ids.
+func (c *Client) Delete(ids ...string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.delete(ids)
+}
+
+// UpdateDoc deletes the routes with the provided ids, and upserts the
+// routes in eskip format. If parsing the document fails, it returns an
+// error, and the routes are not changed.
+func (c *Client) UpdateDoc(upsertDoc string, deletedIDs []string) error {
+	routes, err := eskip.Parse(upsertDoc)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.delete(deletedIDs)
+	c.upsert(routes)
+	return nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func checkIDs(t *testing.T, routes []*eskip.Route, expected ...string) {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Errorf("invalid routes, expected: %v, got: %v", expected, ids)
+	}
+}
+
+func TestInvalidDoc(t *testing.T) {
+	if _, err := NewDoc("invalid eskip"); err == nil {
+		t.Error("failed to fail")
+	}
+
+	c := New()
+	if err := c.UpdateDoc("invalid eskip", []string{"foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	c, err := NewDoc(`foo: * -> <shunt>; bar: * -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Upsert(&eskip.Route{Id: "baz", BackendType: eskip.ShuntBackend, Shunt: true})
+	c.Delete("bar")
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIDs(t, routes, "baz", "foo")
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deleted) != 0 {
+		t.Error("unexpected update", routes, deleted)
+	}
+}
+
+func TestLoadUpdate(t *testing.T) {
+	c, err := NewDoc(`foo: * -> <shunt>; bar: * -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpdateDoc(`baz: * -> <shunt>; qux: * -> <shunt>`, []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Delete("qux")
+	r := &eskip.Route{Path: "/generated", BackendType: eskip.ShuntBackend, Shunt: true}
+	c.Upsert(r)
+	if r.Id != "" {
+		t.Error("the upserted route was modified")
+	}
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatal("invalid number of routes", len(routes))
+	}
+
+	sort.Strings(deleted)
+	if strings.Join(deleted, ",") != "foo,qux" {
+		t.Error("invalid deleted ids", deleted)
+	}
+
+	routes, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deleted) != 0 {
+		t.Error("unexpected update", routes, deleted)
+	}
+}
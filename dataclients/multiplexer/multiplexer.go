@@ -0,0 +1,230 @@
+/*
+Package multiplexer implements a DataClient that merges the routes of
+multiple other data clients.
+
+(See the DataClient interface in the skipper/routing package.)
+
+The ids of the routes coming from a source can be namespaced with a
+prefix, e.g. the routes of a source with the prefix "bootstrap" get ids
+like bootstrap_<original id>. This way the operators can combine e.g.
+static bootstrap routes from a file with the dynamic routes from etcd or
+Kubernetes, without the ids of the different sources colliding.
+
+When the ids of the routes from multiple sources still collide, e.g.
+because the sources don't have a prefix, the route from the source listed
+first in the options wins. The routes from the other sources with the same
+id are ignored, and a warning is logged.
+*/
+package multiplexer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+// Source is a data client, whose route ids are prefixed.
+type Source struct {
+
+	// Prefix of the route ids from the data client. It needs to be
+	// a valid route id. When empty, the ids are kept unchanged.
+	Prefix string
+
+	// The data client.
+	Client routing.DataClient
+}
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// The data clients, in the order of their priority.
+	Sources []Source
+}
+
+type source struct {
+	Source
+	routes map[string]*eskip.Route
+}
+
+// Client merges the routes of multiple data clients.
+type Client struct {
+	sources    []*source
+	merged     map[string]*eskip.Route
+	collisions map[string]bool
+}
+
+var (
+	errNoSources = errors.New("no data client sources")
+	validPrefix  = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+)
+
+// New creates a Client merging the routes from the sources.
+func New(o Options) (*Client, error) {
+	if len(o.Sources) == 0 {
+		return nil, errNoSources
+	}
+
+	c := &Client{}
+	for i, s := range o.Sources {
+		if s.Client == nil {
+			return nil, fmt.Errorf("missing data client of source %d", i)
+		}
+
+		if s.Prefix != "" && !validPrefix.MatchString(s.Prefix) {
+			return nil, fmt.Errorf("invalid route id prefix: %s", s.Prefix)
+		}
+
+		c.sources = append(c.sources, &source{Source: s})
+	}
+
+	return c, nil
+}
+
+func (s *source) id(id string) string {
+	if s.Prefix == "" {
+		return id
+	}
+
+	return s.Prefix + "_" + id
+}
+
+func (s *source) storeAll(routes []*eskip.Route) {
+	s.routes = make(map[string]*eskip.Route)
+	s.upsert(routes)
+}
+
+func (s *source) upsert(routes []*eskip.Route) {
+	for _, r := range routes {
+		if s.Prefix != "" {
+			r = r.Copy()
+			r.Id = s.id(r.Id)
+		}
+
+		s.routes[r.Id] = r
+	}
+}
+
+func (s *source) delete(ids []string) {
+	for _, id := range ids {
+		delete(s.routes, s.id(id))
+	}
+}
+
+func (s *source) loadAll() error {
+	routes, err := s.Client.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	s.storeAll(routes)
+	return nil
+}
+
+// loads the updates of the source, or all its routes when the update
+// failed, the same way as the routing does with the data clients
+func (s *source) loadUpdate() error {
+	routes, deletedIDs, err := s.Client.LoadUpdate()
+	if err != nil {
+		log.Errorf("error while receiving update from source %q: %v", s.Prefix, err)
+		return s.loadAll()
+	}
+
+	s.delete(deletedIDs)
+	s.upsert(routes)
+	return nil
+}
+
+// calls f for each source concurrently, and returns the first error
+func (c *Client) each(f func(*source) error) error {
+	errs := make([]error, len(c.sources))
+	var wg sync.WaitGroup
+	for i, s := range c.sources {
+		wg.Add(1)
+		go func(i int, s *source) {
+			defer wg.Done()
+			errs[i] = f(s)
+		}(i, s)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// merges the routes of the sources, the source listed first winning on
+// id collisions
+func (c *Client) merge() map[string]*eskip.Route {
+	merged := make(map[string]*eskip.Route)
+	collisions := make(map[string]bool)
+	for _, s := range c.sources {
+		for id, r := range s.routes {
+			if _, exists := merged[id]; exists {
+				if !c.collisions[id] {
+					log.Warnf("route id collision, ignoring route %s from source %q", id, s.Prefix)
+				}
+
+				collisions[id] = true
+				continue
+			}
+
+			merged[id] = r
+		}
+	}
+
+	c.collisions = collisions
+	return merged
+}
+
+// LoadAll returns the merged routes of all the sources.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	if err := c.each((*source).loadAll); err != nil {
+		return nil, err
+	}
+
+	c.merged = c.merge()
+	routes := make([]*eskip.Route, 0, len(c.merged))
+	for _, r := range c.merged {
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// LoadUpdate returns the changes of the merged routes since the previous
+// load. When loading the updates of a source fails, it reloads all the
+// routes of that source.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if err := c.each((*source).loadUpdate); err != nil {
+		return nil, nil, err
+	}
+
+	merged := c.merge()
+
+	var upserts []*eskip.Route
+	for id, r := range merged {
+		if old, ok := c.merged[id]; !ok || old != r && !reflect.DeepEqual(old, r) {
+			upserts = append(upserts, r)
+		}
+	}
+
+	var deletedIDs []string
+	for id := range c.merged {
+		if _, ok := merged[id]; !ok {
+			deletedIDs = append(deletedIDs, id)
+		}
+	}
+
+	c.merged = merged
+	return upserts, deletedIDs, nil
+}
@@ -0,0 +1,179 @@
+package multiplexer
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type stubClient struct {
+	all        []*eskip.Route
+	upserts    []*eskip.Route
+	deletedIDs []string
+	failUpdate bool
+	failAll    bool
+}
+
+func (c *stubClient) LoadAll() ([]*eskip.Route, error) {
+	if c.failAll {
+		return nil, errors.New("failed to load")
+	}
+
+	return c.all, nil
+}
+
+func (c *stubClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if c.failUpdate {
+		return nil, nil, errors.New("failed to load update")
+	}
+
+	u, d := c.upserts, c.deletedIDs
+	c.upserts, c.deletedIDs = nil, nil
+	return u, d, nil
+}
+
+func parse(t *testing.T, doc string) []*eskip.Route {
+	r, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func checkIDs(t *testing.T, routes []*eskip.Route, expected ...string) {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Errorf("invalid routes, expected: %v, got: %v", expected, ids)
+	}
+}
+
+func backendOf(routes []*eskip.Route, id string) string {
+	for _, r := range routes {
+		if r.Id == id {
+			return r.Backend
+		}
+	}
+
+	return ""
+}
+
+func TestInvalidOptions(t *testing.T) {
+	if _, err := New(Options{}); err != errNoSources {
+		t.Error("failed to fail without sources")
+	}
+
+	if _, err := New(Options{Sources: []Source{{Prefix: "foo"}}}); err == nil {
+		t.Error("failed to fail without client")
+	}
+
+	if _, err := New(Options{Sources: []Source{{Prefix: "foo-bar", Client: &stubClient{}}}}); err == nil {
+		t.Error("failed to fail with invalid prefix")
+	}
+}
+
+func TestPrefixesAndCollisions(t *testing.T) {
+	bootstrap := &stubClient{all: parse(t, `health: Path("/health") -> "https://a.example.org"; catchAll: * -> "https://a.example.org"`)}
+	dynamic := &stubClient{all: parse(t, `foo: * -> "https://b.example.org"; catchAll: * -> "https://b.example.org"`)}
+	static := &stubClient{all: parse(t, `bootstrap_health: * -> "https://c.example.org"; bar: * -> "https://c.example.org"`)}
+
+	c, err := New(Options{Sources: []Source{
+		{Prefix: "bootstrap", Client: bootstrap},
+		{Prefix: "dynamic", Client: dynamic},
+		{Client: static},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIDs(t, routes, "bar", "bootstrap_catchAll", "bootstrap_health", "dynamic_catchAll", "dynamic_foo")
+	if b := backendOf(routes, "bootstrap_health"); b != "https://a.example.org" {
+		t.Error("failed to resolve the collision by the order of the sources", b)
+	}
+
+	if bootstrap.all[0].Id != "health" {
+		t.Error("the routes of the source were modified")
+	}
+}
+
+func TestLoadUpdate(t *testing.T) {
+	first := &stubClient{all: parse(t, `foo: * -> "https://a.example.org"`)}
+	second := &stubClient{all: parse(t, `foo: * -> "https://b.example.org"; bar: * -> "https://b.example.org"`)}
+	c, err := New(Options{Sources: []Source{{Client: first}, {Client: second}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deleted) != 0 {
+		t.Error("unexpected update", routes, deleted)
+	}
+
+	// deleting the winner of the collision reveals the other route
+	first.deletedIDs = []string{"foo"}
+	second.deletedIDs = []string{"bar"}
+	routes, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIDs(t, routes, "foo")
+	if b := backendOf(routes, "foo"); b != "https://b.example.org" {
+		t.Error("failed to switch to the other source", b)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "bar" {
+		t.Error("failed to delete the route", deleted)
+	}
+}
+
+func TestReloadFailedSource(t *testing.T) {
+	first := &stubClient{all: parse(t, `foo: * -> <shunt>`)}
+	second := &stubClient{all: parse(t, `bar: * -> <shunt>`)}
+	c, err := New(Options{Sources: []Source{{Prefix: "first", Client: first}, {Prefix: "second", Client: second}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	second.failUpdate = true
+	second.all = parse(t, `baz: * -> <shunt>`)
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIDs(t, routes, "second_baz")
+	if len(deleted) != 1 || deleted[0] != "second_bar" {
+		t.Error("failed to delete the route", deleted)
+	}
+
+	second.failAll = true
+	if _, _, err := c.LoadUpdate(); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Package redis implements a DataClient for reading the skipper route
+definitions from Redis.
+
+(See the DataClient interface in the skipper/routing package.)
+
+The route definitions are stored under individual keys with a common
+prefix, as eskip route expressions. When loaded from Redis, the routes get
+the key without the prefix as id.
+
+The client receives the updates via Redis keyspace notifications, which
+need to be enabled on the Redis server for the generic and string
+commands, and for the expired keys, e.g.:
+
+	CONFIG SET notify-keyspace-events K$gx
+
+In addition to the DataClient implementation, type Client provides
+methods to Upsert and Delete routes.
+*/
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/internal/routeset"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	defaultPrefix  = "skipper:routes:"
+	defaultTimeout = time.Second
+	scanCount      = 1000
+)
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// Address of the Redis server. Required.
+	Address string
+
+	// Optional password for the Redis server.
+	Password string
+
+	// Database number.
+	DB int
+
+	// Key prefix of the stored routes. Defaults to
+	// "skipper:routes:".
+	Prefix string
+
+	// The time LoadUpdate waits for keyspace notifications.
+	// Defaults to 1 second.
+	Timeout time.Duration
+}
+
+// Client is used to load the whole set of routes and the updates from
+// Redis.
+type Client struct {
+	client         *redis.Client
+	prefix         string
+	channelPrefix  string
+	timeout        time.Duration
+	pubsub         *redis.PubSub
+	notifications  <-chan *redis.Message
+	pendingChanges map[string]bool
+}
+
+var (
+	errMissingAddress     = errors.New("missing Redis address")
+	errMissingRouteID     = errors.New("missing route id")
+	errSubscriptionClosed = errors.New("keyspace notification subscription closed")
+)
+
+// New creates a Client with the provided options.
+func New(o Options) (*Client, error) {
+	if o.Address == "" {
+		return nil, errMissingAddress
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = defaultPrefix
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	return &Client{
+		client: redis.NewClient(&redis.Options{
+			Addr:     o.Address,
+			Password: o.Password,
+			DB:       o.DB,
+		}),
+		prefix:         o.Prefix,
+		channelPrefix:  fmt.Sprintf("__keyspace@%d__:", o.DB),
+		timeout:        o.Timeout,
+		pendingChanges: make(map[string]bool),
+	}, nil
+}
+
+// subscribes to the keyspace notifications of the route keys, when not
+// subscribed yet
+func (c *Client) subscribe() error {
+	if c.pubsub != nil {
+		return nil
+	}
+
+	ps := c.client.PSubscribe(c.channelPrefix + c.prefix + "*")
+	if _, err := ps.Receive(); err != nil {
+		ps.Close()
+		return err
+	}
+
+	c.pubsub = ps
+	c.notifications = ps.Channel()
+	return nil
+}
+
+func (c *Client) unsubscribe() {
+	if c.pubsub == nil {
+		return
+	}
+
+	c.pubsub.Close()
+	c.pubsub = nil
+	c.notifications = nil
+}
+
+// LoadAll returns all the route definitions currently stored in Redis.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	// subscribing first, to not miss any changes
+	// happening during loading
+	if err := c.subscribe(); err != nil {
+		return nil, err
+	}
+
+	c.pendingChanges = make(map[string]bool)
+
+	var (
+		keys   []string
+		cursor uint64
+	)
+
+	for {
+		var (
+			k   []string
+			err error
+		)
+
+		k, cursor, err = c.client.Scan(cursor, c.prefix+"*", scanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k...)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*eskip.Route
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			// deleted meanwhile
+			continue
+		}
+
+		id := strings.TrimPrefix(keys[i], c.prefix)
+		r, err := routeset.ParseOne(id, s)
+		if err != nil {
+			log.Errorf("error while parsing route %s: %v", id, err)
+			continue
+		}
+
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// LoadUpdate returns the updates (upserts and deletes) since the last
+// initial request or update.
+//
+// It collects the keyspace notifications of the changed routes until the
+// configured timeout, and loads the current state of the changed routes.
+// The routes whose new value fails to parse are returned as deleted.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if c.notifications == nil {
+		return nil, nil, errSubscriptionClosed
+	}
+
+	timeout := time.After(c.timeout)
+collect:
+	for {
+		select {
+		case m, ok := <-c.notifications:
+			if !ok {
+				c.unsubscribe()
+				return nil, nil, errSubscriptionClosed
+			}
+
+			key := strings.TrimPrefix(m.Channel, c.channelPrefix)
+			if id := strings.TrimPrefix(key, c.prefix); id != key && id != "" {
+				c.pendingChanges[id] = true
+			}
+		case <-timeout:
+			break collect
+		}
+	}
+
+	var (
+		updates    = make(map[string]string)
+		deletedIDs []string
+	)
+
+	for id := range c.pendingChanges {
+		v, err := c.client.Get(c.prefix + id).Result()
+		if err == redis.Nil {
+			deletedIDs = append(deletedIDs, id)
+			delete(c.pendingChanges, id)
+			continue
+		}
+
+		if err != nil {
+			// the pending changes are kept and retried
+			return nil, nil, err
+		}
+
+		delete(c.pendingChanges, id)
+		updates[id] = v
+	}
+
+	routes, deletedIDs := routeset.ParseUpdates(updates, deletedIDs)
+	return routes, deletedIDs, nil
+}
+
+// Upsert inserts or updates a route in Redis.
+func (c *Client) Upsert(r *eskip.Route) error {
+	if r.Id == "" {
+		return errMissingRouteID
+	}
+
+	return c.client.Set(c.prefix+r.Id, r.String(), 0).Err()
+}
+
+// Delete deletes a route from Redis.
+func (c *Client) Delete(id string) error {
+	if id == "" {
+		return errMissingRouteID
+	}
+
+	return c.client.Del(c.prefix + id).Err()
+}
+
+// UpsertAll inserts or updates all the routes, generating an id for those
+// that don't have one.
+func (c *Client) UpsertAll(routes []*eskip.Route) error {
+	for _, r := range routes {
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		if err := c.Upsert(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteAllIf deletes the routes that match the condition.
+func (c *Client) DeleteAllIf(routes []*eskip.Route, cond eskip.RoutePredicate) error {
+	for _, r := range routes {
+		if !cond(r) {
+			continue
+		}
+
+		if err := c.Delete(r.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops receiving the notifications, and closes the connections to
+// Redis.
+func (c *Client) Close() {
+	c.unsubscribe()
+	c.client.Close()
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zalando/skipper/eskip"
+)
+
+// miniredis doesn't send keyspace notifications, so the tests publish
+// them after changing the routes
+type testRedis struct {
+	*miniredis.Miniredis
+	t *testing.T
+}
+
+func startRedis(t *testing.T) *testRedis {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testRedis{Miniredis: m, t: t}
+}
+
+func (r *testRedis) notify(id, event string) {
+	r.Publish("__keyspace@0__:"+defaultPrefix+id, event)
+}
+
+func (r *testRedis) set(id, expression string) {
+	if err := r.Set(defaultPrefix+id, expression); err != nil {
+		r.t.Fatal(err)
+	}
+
+	r.notify(id, "set")
+}
+
+func routeIDs(routes []*eskip.Route) string {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestRedisDataClient(t *testing.T) {
+	r := startRedis(t)
+	defer r.Close()
+
+	c, err := New(Options{Address: r.Addr(), Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	if err := c.UpsertAll([]*eskip.Route{
+		{Id: "foo", Path: "/foo", Backend: "https://foo.example.org"},
+		{Id: "bar", Path: "/bar", Backend: "https://bar.example.org"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.set("invalid", "invalid")
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "bar,foo" {
+		t.Error("failed to load routes", ids)
+	}
+
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Error("unexpected update", routes, deletedIDs)
+	}
+
+	if err := c.Upsert(&eskip.Route{Id: "baz", Path: "/baz", Backend: "https://baz.example.org"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.notify("baz", "set")
+	if err := c.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.notify("foo", "del")
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "baz" {
+		t.Error("failed to receive upsert", ids)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "foo" {
+		t.Error("failed to receive delete", deletedIDs)
+	}
+}
+
+func TestRedisInvalidUpdate(t *testing.T) {
+	r := startRedis(t)
+	defer r.Close()
+
+	c, err := New(Options{Address: r.Addr(), Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	r.set("foo", `Path("/foo") -> "https://foo.example.org"`)
+	r.set("bar", `Path("/bar") -> "https://bar.example.org"`)
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.set("foo", "invalid")
+	r.set("bar", `Path("/bar") -> "https://bar2.example.org"`)
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "bar" {
+		t.Error("failed to receive upsert", ids)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "foo" {
+		t.Error("failed to delete the invalid route", deletedIDs)
+	}
+}
+
+func TestMissingAddress(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingAddress {
+		t.Error("failed to fail")
+	}
+}
@@ -0,0 +1,315 @@
+/*
+Package s3 implements a DataClient for reading the skipper route
+definitions from eskip documents stored in an AWS S3 bucket.
+
+(See the DataClient interface in the skipper/routing package and the eskip
+format in the skipper/eskip package.)
+
+The client loads every object with the .eskip extension under the
+configured key prefix, and merges the routes of all the documents. The
+updates are detected by polling the object listing of the prefix, and
+only the documents whose ETag changed are loaded again.
+
+The requests are signed with the AWS Signature Version 4, using the
+credentials from the options, or from the standard AWS environment
+variables. When no credentials are available, the requests are sent
+anonymously.
+*/
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/internal/routeset"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	defaultRegion  = "us-east-1"
+	defaultTimeout = 10 * time.Second
+	eskipExtension = ".eskip"
+)
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// Name of the bucket. Required.
+	Bucket string
+
+	// Key prefix of the eskip documents.
+	Prefix string
+
+	// AWS region of the bucket. Defaults to the value of the
+	// AWS_REGION environment variable, or us-east-1.
+	Region string
+
+	// Optional custom endpoint, e.g. for S3 compatible storages.
+	// The bucket is addressed in path style.
+	Endpoint string
+
+	// Optional credentials. When not set, the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+	// variables are used.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Timeout of a single request. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+type document struct {
+	etag   string
+	routes []*eskip.Route
+}
+
+// Client loads the routes from the eskip documents stored in S3.
+type Client struct {
+	bucketURL   string
+	prefix      string
+	region      string
+	credentials credentials
+	client      *http.Client
+	documents   map[string]*document
+	invalid     map[string]string
+	routes      map[string]*eskip.Route
+}
+
+// S3 serialization objects
+type (
+	object struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+	}
+
+	listBucketResult struct {
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken"`
+		Contents              []*object `xml:"Contents"`
+	}
+)
+
+var errMissingBucket = errors.New("missing S3 bucket")
+
+// New creates a Client with the provided options.
+func New(o Options) (*Client, error) {
+	if o.Bucket == "" {
+		return nil, errMissingBucket
+	}
+
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_REGION")
+	}
+
+	if o.Region == "" {
+		o.Region = defaultRegion
+	}
+
+	if o.Endpoint == "" {
+		o.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", o.Region)
+	}
+
+	if o.AccessKeyID == "" {
+		o.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		o.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		o.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	return &Client{
+		bucketURL: strings.TrimSuffix(o.Endpoint, "/") + "/" + o.Bucket,
+		prefix:    o.Prefix,
+		region:    o.Region,
+		credentials: credentials{
+			accessKeyID:     o.AccessKeyID,
+			secretAccessKey: o.SecretAccessKey,
+			sessionToken:    o.SessionToken,
+		},
+		client:    &http.Client{Timeout: o.Timeout},
+		documents: make(map[string]*document),
+		invalid:   make(map[string]string),
+	}, nil
+}
+
+func (c *Client) get(u string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.credentials.accessKeyID != "" {
+		signRequest(req, c.credentials, c.region, time.Now())
+	}
+
+	return c.client.Do(req)
+}
+
+func (c *Client) listPage(continuation string) (*listBucketResult, error) {
+	q := make(url.Values)
+	q.Set("list-type", "2")
+	q.Set("prefix", c.prefix)
+	if continuation != "" {
+		q.Set("continuation-token", continuation)
+	}
+
+	rsp, err := c.get(c.bucketURL + "/?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list S3 objects: %s", rsp.Status)
+	}
+
+	var result listBucketResult
+	if err := xml.NewDecoder(rsp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// lists the eskip documents under the prefix, mapping their keys to
+// their ETag
+func (c *Client) list() (map[string]string, error) {
+	objects := make(map[string]string)
+	var continuation string
+	for {
+		result, err := c.listPage(continuation)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range result.Contents {
+			if strings.HasSuffix(o.Key, eskipExtension) {
+				objects[o.Key] = o.ETag
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		continuation = result.NextContinuationToken
+	}
+}
+
+// loads a document, and stores it with the ETag from the listing
+func (c *Client) getDocument(key, etag string) (*document, error) {
+	rsp, err := c.get(c.bucketURL + "/" + uriEncode(key, true))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get S3 object %s: %s", key, rsp.Status)
+	}
+
+	content, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := eskip.Parse(string(content))
+	if err != nil {
+		// not loading the invalid document again until it changes
+		c.invalid[key] = etag
+		return nil, err
+	}
+
+	delete(c.invalid, key)
+	return &document{etag: etag, routes: routes}, nil
+}
+
+// loads the changed documents, and returns the merged routes of all
+// the documents. Documents that fail to load keep their previous state.
+func (c *Client) load() (map[string]*eskip.Route, error) {
+	objects, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range c.documents {
+		if _, ok := objects[key]; !ok {
+			delete(c.documents, key)
+		}
+	}
+
+	for key := range c.invalid {
+		if _, ok := objects[key]; !ok {
+			delete(c.invalid, key)
+		}
+	}
+
+	var keys []string
+	for key, etag := range objects {
+		keys = append(keys, key)
+		if c.invalid[key] == etag {
+			continue
+		}
+
+		if d, ok := c.documents[key]; !ok || d.etag != etag {
+			d, err := c.getDocument(key, etag)
+			if err != nil {
+				log.Errorf("error while loading eskip document %s from S3: %v", key, err)
+				continue
+			}
+
+			c.documents[key] = d
+		}
+	}
+
+	// merging in the order of the keys, to have a deterministic
+	// result for duplicate route ids
+	sort.Strings(keys)
+	routes := make(map[string]*eskip.Route)
+	for _, key := range keys {
+		if d, ok := c.documents[key]; ok {
+			for _, r := range d.routes {
+				routes[r.Id] = r
+			}
+		}
+	}
+
+	return routes, nil
+}
+
+// LoadAll returns the routes from all the eskip documents under the
+// prefix.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	routes, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.routes = routes
+	return routeset.Clone(routes), nil
+}
+
+// LoadUpdate returns the changed and deleted routes since the previous
+// call.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	routes, err := c.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upserts, deletedIDs := routeset.Diff(c.routes, routes)
+	c.routes = routes
+	return upserts, deletedIDs, nil
+}
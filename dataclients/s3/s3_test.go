@@ -0,0 +1,143 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type bucket struct {
+	t          *testing.T
+	objects    map[string]string
+	gets       int
+	authorized bool
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.authorized = strings.HasPrefix(r.Header.Get("Authorization"), signAlgorithm+" Credential=AKID/")
+	if r.URL.Path == "/routes" || r.URL.Path == "/routes/" {
+		var result listBucketResult
+		prefix := r.URL.Query().Get("prefix")
+		for key, content := range b.objects {
+			if strings.HasPrefix(key, prefix) {
+				result.Contents = append(result.Contents, &object{Key: key, ETag: etag(content)})
+			}
+		}
+
+		if err := xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ListBucketResult"`
+			*listBucketResult
+		}{listBucketResult: &result}); err != nil {
+			b.t.Fatal(err)
+		}
+
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/routes/")
+	content, ok := b.objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	b.gets++
+	w.Header().Set("ETag", etag(content))
+	w.Write([]byte(content))
+}
+
+func etag(content string) string {
+	return `"` + sha256Hex(content) + `"`
+}
+
+func routeIDs(routes []*eskip.Route) string {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestMissingBucket(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingBucket {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	b := &bucket{
+		t: t,
+		objects: map[string]string{
+			"skipper/a.eskip":   `foo: Path("/foo") -> "https://foo.example.org";`,
+			"skipper/b.eskip":   `bar: Path("/bar") -> "https://bar.example.org";`,
+			"skipper/c.txt":     `baz: Path("/baz") -> "https://baz.example.org";`,
+			"other/d.eskip":     `qux: Path("/qux") -> "https://qux.example.org";`,
+			"skipper/e.eskip":   `invalid`,
+			"skipper/x/f.eskip": `quux: Path("/quux") -> "https://quux.example.org";`,
+		},
+	}
+
+	s := httptest.NewServer(b)
+	defer s.Close()
+
+	c, err := New(Options{
+		Bucket:          "routes",
+		Prefix:          "skipper/",
+		Endpoint:        s.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "bar,foo,quux" {
+		t.Error("failed to load routes", ids)
+	}
+
+	if !b.authorized {
+		t.Error("failed to sign the requests")
+	}
+
+	gets := b.gets
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Error("unexpected update", routes, deletedIDs)
+	}
+
+	if b.gets != gets {
+		t.Error("unchanged documents loaded again")
+	}
+
+	b.objects["skipper/a.eskip"] = `foo: Path("/foo") -> "https://foo2.example.org";`
+	delete(b.objects, "skipper/b.eskip")
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "foo" || routes[0].Backend != "https://foo2.example.org" {
+		t.Error("failed to receive upsert", routes)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive delete", deletedIDs)
+	}
+}
@@ -0,0 +1,274 @@
+package xds
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	log "github.com/sirupsen/logrus"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	// registers the resource types, so that they can be decoded
+	_ "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	_ "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+const (
+	grpcScheme       = "grpc"
+	grpcsScheme      = "grpcs"
+	reconnectBackoff = 3 * time.Second
+)
+
+var errADSTimeout = errors.New("timeout while waiting for the initial xDS resources")
+
+// the latest accepted version of the resources of a type, received on
+// the ADS stream
+type adsResources struct {
+	version   string
+	resources []json.RawMessage
+}
+
+// ads maintains a stream to the aggregated discovery service of the
+// management server, and keeps the latest accepted resources. The
+// resources are requested with the state of the world variant of the
+// protocol: every response contains all the resources of a type, and
+// every response is acknowledged (ACK) or, when the resources cannot be
+// decoded, rejected (NACK), by sending a new request with the nonce of
+// the response.
+type ads struct {
+	target      string
+	dialOptions []grpc.DialOption
+	node        *corev3.Node
+	names       map[string][]string
+	timeout     time.Duration
+
+	mx        sync.Mutex
+	resources map[string]*adsResources
+	ready     chan struct{}
+	readyOnce sync.Once
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func newADS(scheme, target string, n node, configNames []string, timeout time.Duration) *ads {
+	var dialOption grpc.DialOption
+	if scheme == grpcsScheme {
+		dialOption = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	} else {
+		dialOption = grpc.WithInsecure()
+	}
+
+	a := &ads{
+		target:      target,
+		dialOptions: []grpc.DialOption{dialOption},
+		node: &corev3.Node{
+			Id:            n.ID,
+			Cluster:       n.Cluster,
+			UserAgentName: defaultNodeID,
+		},
+		names: map[string][]string{
+			clusterType:     nil,
+			routeConfigType: configNames,
+		},
+		timeout:   timeout,
+		resources: make(map[string]*adsResources),
+		ready:     make(chan struct{}),
+		quit:      make(chan struct{}),
+	}
+
+	go a.run()
+	return a
+}
+
+func (a *ads) run() {
+	for {
+		err := a.stream()
+		select {
+		case <-a.quit:
+			return
+		default:
+		}
+
+		log.Errorf("Error while streaming from the xDS management server: %v.", err)
+		select {
+		case <-time.After(reconnectBackoff):
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// the version of the last accepted resources of a type. It is sent in
+// every request, also after reconnecting, so that the management server
+// doesn't need to resend unchanged resources.
+func (a *ads) version(typeURL string) string {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if r, ok := a.resources[typeURL]; ok {
+		return r.version
+	}
+
+	return ""
+}
+
+func (a *ads) request(typeURL, nonce string, errorDetail *rpcstatus.Status) *discoveryv3.DiscoveryRequest {
+	return &discoveryv3.DiscoveryRequest{
+		VersionInfo:   a.version(typeURL),
+		Node:          a.node,
+		ResourceNames: a.names[typeURL],
+		TypeUrl:       typeURL,
+		ResponseNonce: nonce,
+		ErrorDetail:   errorDetail,
+	}
+}
+
+func (a *ads) stream() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-a.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := grpc.DialContext(ctx, a.target, a.dialOptions...)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+	s, err := discoveryv3.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, typeURL := range []string{clusterType, routeConfigType} {
+		if err := s.Send(a.request(typeURL, "", nil)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		rsp, err := s.Recv()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := a.names[rsp.TypeUrl]; !ok {
+			log.Warnf("Unexpected xDS resource type: %s.", rsp.TypeUrl)
+			continue
+		}
+
+		resources, err := decodeResources(rsp)
+		if err != nil {
+			log.Errorf("Rejecting xDS resources of type %s, version %s: %v.", rsp.TypeUrl, rsp.VersionInfo, err)
+			nack := a.request(rsp.TypeUrl, rsp.Nonce, &rpcstatus.Status{
+				Code:    int32(codes.InvalidArgument),
+				Message: err.Error(),
+			})
+
+			if err := s.Send(nack); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		a.accept(rsp.TypeUrl, rsp.VersionInfo, resources)
+		if err := s.Send(a.request(rsp.TypeUrl, rsp.Nonce, nil)); err != nil {
+			return err
+		}
+	}
+}
+
+// decodes the resources to their proto3 canonical JSON form, which is
+// translated the same way as the resources received with REST-JSON
+func decodeResources(rsp *discoveryv3.DiscoveryResponse) ([]json.RawMessage, error) {
+	resources := []json.RawMessage{}
+	for _, r := range rsp.Resources {
+		if r.TypeUrl != rsp.TypeUrl {
+			return nil, fmt.Errorf("resource type %s doesn't match the response type", r.TypeUrl)
+		}
+
+		m, err := r.UnmarshalNew()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		switch rsp.TypeUrl {
+		case clusterType:
+			v = &cluster{}
+		default:
+			v = &routeConfiguration{}
+		}
+
+		if err := json.Unmarshal(b, v); err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, b)
+	}
+
+	return resources, nil
+}
+
+func (a *ads) accept(typeURL, version string, resources []json.RawMessage) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.resources[typeURL] = &adsResources{version: version, resources: resources}
+	if len(a.resources) == len(a.names) {
+		a.readyOnce.Do(func() { close(a.ready) })
+	}
+}
+
+// load copies the accepted resources to the resource states, and returns
+// true when any of them changed. When wait is true, it waits until the
+// resources of all the types were received at least once.
+func (a *ads) load(wait bool, states ...*resourceState) (bool, error) {
+	if wait {
+		select {
+		case <-a.ready:
+		case <-time.After(a.timeout):
+			return false, errADSTimeout
+		}
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	var changed bool
+	for _, s := range states {
+		r, ok := a.resources[s.typeURL]
+		if !ok || s.resources != nil && r.version == s.version {
+			continue
+		}
+
+		s.version = r.version
+		s.resources = r.resources
+		changed = true
+	}
+
+	return changed, nil
+}
+
+func (a *ads) close() {
+	a.closeOnce.Do(func() { close(a.quit) })
+}
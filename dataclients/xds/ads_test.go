@@ -0,0 +1,298 @@
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// creates the protobuf resources from their JSON form used in the
+// REST-JSON tests
+func protoResources(t *testing.T, resources string, newMessage func() proto.Message) []proto.Message {
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(resources), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []proto.Message
+	for _, i := range items {
+		delete(i, "@type")
+		b, err := json.Marshal(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := newMessage()
+		if err := protojson.Unmarshal(b, m); err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages
+}
+
+func testClusterMessages(t *testing.T) []proto.Message {
+	return protoResources(t, testClusters, func() proto.Message { return &clusterv3.Cluster{} })
+}
+
+func testRouteConfigMessages(t *testing.T, configs string) []proto.Message {
+	return protoResources(t, configs, func() proto.Message { return &routev3.RouteConfiguration{} })
+}
+
+func cacheResources(m []proto.Message) []types.Resource {
+	var r []types.Resource
+	for _, mi := range m {
+		r = append(r, mi.(types.Resource))
+	}
+
+	return r
+}
+
+func startGRPCServer(t *testing.T, ads discoveryv3.AggregatedDiscoveryServiceServer) (*grpc.Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	discoveryv3.RegisterAggregatedDiscoveryServiceServer(s, ads)
+	go s.Serve(l)
+	return s, "grpc://" + l.Addr().String()
+}
+
+func TestADSWithControlPlane(t *testing.T) {
+	snapshots := cachev3.NewSnapshotCache(true, cachev3.IDHash{}, nil)
+	if err := snapshots.SetSnapshot("test-node", cachev3.NewSnapshot(
+		"1",
+		nil,
+		cacheResources(testClusterMessages(t)),
+		cacheResources(testRouteConfigMessages(t, testRouteConfigs)),
+		nil,
+		nil,
+		nil,
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	server, url := startGRPCServer(t, serverv3.NewServer(context.Background(), snapshots, serverv3.CallbackFuncs{}))
+	defer server.Stop()
+
+	c, err := New(Options{Server: url, NodeID: "test-node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, routes)
+
+	if err := snapshots.SetSnapshot("test-node", cachev3.NewSnapshot(
+		"2",
+		nil,
+		cacheResources(testClusterMessages(t)),
+		nil,
+		nil,
+		nil,
+		nil,
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(3 * time.Second)
+	for {
+		routes, deleted, err := c.LoadUpdate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(routes) == 0 && len(deleted) == 5 {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatal("failed to receive the update")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// an ADS server controlled by the test, recording the requests
+type testADS struct {
+	discoveryv3.UnimplementedAggregatedDiscoveryServiceServer
+	requests  chan *discoveryv3.DiscoveryRequest
+	responses chan *discoveryv3.DiscoveryResponse
+}
+
+func (s *testADS) StreamAggregatedResources(stream discoveryv3.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			s.requests <- req
+		}
+	}()
+
+	for {
+		select {
+		case rsp := <-s.responses:
+			if err := stream.Send(rsp); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *testADS) receive(t *testing.T) *discoveryv3.DiscoveryRequest {
+	select {
+	case req := <-s.requests:
+		return req
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout while waiting for a request")
+		return nil
+	}
+}
+
+func (s *testADS) expect(t *testing.T, typeURL, version, nonce string, nack bool) {
+	req := s.receive(t)
+	if req.TypeUrl != typeURL ||
+		req.VersionInfo != version ||
+		req.ResponseNonce != nonce ||
+		req.Node.GetId() != "test-node" ||
+		(req.ErrorDetail != nil) != nack {
+		t.Fatalf(
+			"unexpected request, expected type: %s, version: %s, nonce: %s, nack: %t, got: %v",
+			typeURL, version, nonce, nack, req,
+		)
+	}
+}
+
+func testResponse(t *testing.T, typeURL, version, nonce string, m []proto.Message) *discoveryv3.DiscoveryResponse {
+	rsp := &discoveryv3.DiscoveryResponse{TypeUrl: typeURL, VersionInfo: version, Nonce: nonce}
+	for _, mi := range m {
+		a, err := anypb.New(mi)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Resources = append(rsp.Resources, a)
+	}
+
+	return rsp
+}
+
+func TestADSAckNack(t *testing.T) {
+	ads := &testADS{
+		requests:  make(chan *discoveryv3.DiscoveryRequest, 32),
+		responses: make(chan *discoveryv3.DiscoveryResponse, 32),
+	}
+
+	server, url := startGRPCServer(t, ads)
+	defer server.Stop()
+
+	c, err := New(Options{Server: url, NodeID: "test-node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	ads.expect(t, clusterType, "", "", false)
+	ads.expect(t, routeConfigType, "", "", false)
+
+	ads.responses <- testResponse(t, clusterType, "1", "c1", testClusterMessages(t))
+	ads.responses <- testResponse(t, routeConfigType, "1", "r1", testRouteConfigMessages(t, testRouteConfigs))
+	ads.expect(t, clusterType, "1", "c1", false)
+	ads.expect(t, routeConfigType, "1", "r1", false)
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, routes)
+
+	// a cluster in the route configuration response is rejected, and
+	// the previous version is kept
+	ads.responses <- testResponse(t, routeConfigType, "2", "r2", testClusterMessages(t))
+	ads.expect(t, routeConfigType, "1", "r2", true)
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deleted) != 0 {
+		t.Error("unexpected update", routes, deleted)
+	}
+
+	ads.responses <- testResponse(t, routeConfigType, "3", "r3", testRouteConfigMessages(t, `[{
+		"name": "main",
+		"virtualHosts": [{
+			"name": "any",
+			"domains": ["*"],
+			"routes": [{
+				"name": "health",
+				"match": {"safeRegex": {"regex": "/health(z)?"}},
+				"directResponse": {"status": 204}
+			}]
+		}]
+	}]`))
+	ads.expect(t, routeConfigType, "3", "r3", false)
+
+	routes, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "xds_main_any_health" || len(deleted) != 4 {
+		t.Error("failed to receive the update", routes, deleted)
+	}
+}
+
+func TestADSInitialTimeout(t *testing.T) {
+	ads := &testADS{
+		requests:  make(chan *discoveryv3.DiscoveryRequest, 32),
+		responses: make(chan *discoveryv3.DiscoveryResponse, 32),
+	}
+
+	server, url := startGRPCServer(t, ads)
+	defer server.Stop()
+
+	c, err := New(Options{Server: url, NodeID: "test-node", Timeout: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	ads.responses <- testResponse(t, clusterType, "1", "c1", testClusterMessages(t))
+	if _, err := c.LoadAll(); err != errADSTimeout {
+		t.Error("failed to fail", err)
+	}
+}
@@ -0,0 +1,128 @@
+package xds
+
+import "encoding/json"
+
+// The subset of the Envoy v3 API resources that the client understands,
+// in their proto3 canonical JSON form. See:
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/api
+
+const (
+	clusterType     = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	routeConfigType = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+)
+
+type node struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+type discoveryRequest struct {
+	VersionInfo   string   `json:"versionInfo,omitempty"`
+	Node          node     `json:"node"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	TypeURL       string   `json:"typeUrl"`
+	ResponseNonce string   `json:"responseNonce,omitempty"`
+}
+
+type discoveryResponse struct {
+	VersionInfo string            `json:"versionInfo"`
+	Resources   []json.RawMessage `json:"resources"`
+	TypeURL     string            `json:"typeUrl"`
+	Nonce       string            `json:"nonce"`
+}
+
+type socketAddress struct {
+	Address   string `json:"address"`
+	PortValue int    `json:"portValue"`
+}
+
+type lbEndpoint struct {
+	Endpoint struct {
+		Address struct {
+			SocketAddress socketAddress `json:"socketAddress"`
+		} `json:"address"`
+	} `json:"endpoint"`
+}
+
+type localityLbEndpoints struct {
+	LbEndpoints []lbEndpoint `json:"lbEndpoints"`
+}
+
+type cluster struct {
+	Name           string `json:"name"`
+	LbPolicy       string `json:"lbPolicy"`
+	LoadAssignment *struct {
+		Endpoints []localityLbEndpoints `json:"endpoints"`
+	} `json:"loadAssignment"`
+	TransportSocket *struct {
+		Name string `json:"name"`
+	} `json:"transportSocket"`
+}
+
+type regexMatcher struct {
+	Regex string `json:"regex"`
+}
+
+type headerMatcher struct {
+	Name           string        `json:"name"`
+	ExactMatch     string        `json:"exactMatch"`
+	SafeRegexMatch *regexMatcher `json:"safeRegexMatch"`
+	PrefixMatch    string        `json:"prefixMatch"`
+	PresentMatch   bool          `json:"presentMatch"`
+	InvertMatch    bool          `json:"invertMatch"`
+}
+
+type routeMatch struct {
+	Prefix    string          `json:"prefix"`
+	Path      string          `json:"path"`
+	SafeRegex *regexMatcher   `json:"safeRegex"`
+	Headers   []headerMatcher `json:"headers"`
+}
+
+type weightedCluster struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+type routeAction struct {
+	Cluster          string `json:"cluster"`
+	WeightedClusters *struct {
+		Clusters []weightedCluster `json:"clusters"`
+	} `json:"weightedClusters"`
+	PrefixRewrite      string `json:"prefixRewrite"`
+	HostRewriteLiteral string `json:"hostRewriteLiteral"`
+}
+
+type redirectAction struct {
+	HTTPSRedirect  bool   `json:"httpsRedirect"`
+	SchemeRedirect string `json:"schemeRedirect"`
+	HostRedirect   string `json:"hostRedirect"`
+	PathRedirect   string `json:"pathRedirect"`
+	ResponseCode   string `json:"responseCode"`
+}
+
+type directResponseAction struct {
+	Status int `json:"status"`
+	Body   *struct {
+		InlineString string `json:"inlineString"`
+	} `json:"body"`
+}
+
+type route struct {
+	Name           string                `json:"name"`
+	Match          routeMatch            `json:"match"`
+	Route          *routeAction          `json:"route"`
+	Redirect       *redirectAction       `json:"redirect"`
+	DirectResponse *directResponseAction `json:"directResponse"`
+}
+
+type virtualHost struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+	Routes  []route  `json:"routes"`
+}
+
+type routeConfiguration struct {
+	Name         string        `json:"name"`
+	VirtualHosts []virtualHost `json:"virtualHosts"`
+}
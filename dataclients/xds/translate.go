@@ -0,0 +1,323 @@
+package xds
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+)
+
+var (
+	invalidIDChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+	portSuffix     = regexp.MustCompile(":[0-9]+$")
+)
+
+func sanitizeID(s string) string {
+	return invalidIDChars.ReplaceAllString(s, "_")
+}
+
+func pathRegexp(re string) string { return "^(?:" + re + ")$" }
+
+func prefixRegexp(prefix string) string { return "^" + regexp.QuoteMeta(prefix) }
+
+func predicate(name string, args ...interface{}) *eskip.Predicate {
+	return &eskip.Predicate{Name: name, Args: args}
+}
+
+func filter(name string, args ...interface{}) *eskip.Filter {
+	return &eskip.Filter{Name: name, Args: args}
+}
+
+func lbAlgorithm(policy string) string {
+	switch policy {
+	case "RANDOM":
+		return "random"
+	case "RING_HASH", "MAGLEV":
+		return "consistentHash"
+	case "LEAST_REQUEST":
+		return "leastConnections"
+	default:
+		return "roundRobin"
+	}
+}
+
+// returns the backend endpoints of a cluster with static load assignment
+func clusterEndpoints(c *cluster) []string {
+	if c.LoadAssignment == nil {
+		return nil
+	}
+
+	scheme := "http"
+	if c.TransportSocket != nil && strings.Contains(c.TransportSocket.Name, "tls") {
+		scheme = "https"
+	}
+
+	var endpoints []string
+	for _, le := range c.LoadAssignment.Endpoints {
+		for _, e := range le.LbEndpoints {
+			a := e.Endpoint.Address.SocketAddress
+			endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d", scheme, a.Address, a.PortValue))
+		}
+	}
+
+	return endpoints
+}
+
+func setClusterBackend(r *eskip.Route, clusters map[string]*cluster, name string) error {
+	c, ok := clusters[name]
+	if !ok {
+		return fmt.Errorf("cluster not found: %s", name)
+	}
+
+	endpoints := clusterEndpoints(c)
+	switch len(endpoints) {
+	case 0:
+		return fmt.Errorf("cluster without static endpoints: %s", name)
+	case 1:
+		r.BackendType = eskip.NetworkBackend
+		r.Backend = endpoints[0]
+	default:
+		r.BackendType = eskip.LBBackend
+		r.LBAlgorithm = lbAlgorithm(c.LbPolicy)
+		r.LBEndpoints = endpoints
+	}
+
+	return nil
+}
+
+// hostRegexp returns the regular expression matching the domains of a
+// virtual host, or an empty string if it matches any host.
+func hostRegexp(domains []string) string {
+	var exps []string
+	for _, d := range domains {
+		if d == "*" {
+			return ""
+		}
+
+		var exp string
+		switch {
+		case strings.HasPrefix(d, "*"):
+			exp = ".+" + regexp.QuoteMeta(d[1:])
+		case strings.HasSuffix(d, "*"):
+			exp = regexp.QuoteMeta(d[:len(d)-1]) + ".+"
+		default:
+			exp = regexp.QuoteMeta(d)
+		}
+
+		if !portSuffix.MatchString(d) {
+			exp += "(:[0-9]+)?"
+		}
+
+		exps = append(exps, exp)
+	}
+
+	if len(exps) == 0 {
+		return ""
+	}
+
+	return "^(" + strings.Join(exps, "|") + ")$"
+}
+
+func matchPredicates(m routeMatch) ([]*eskip.Predicate, error) {
+	var p []*eskip.Predicate
+	switch {
+	case m.SafeRegex != nil:
+		p = append(p, predicate("PathRegexp", pathRegexp(m.SafeRegex.Regex)))
+	case m.Path != "" && strings.ContainsAny(m.Path, ":*"):
+		p = append(p, predicate("PathRegexp", pathRegexp(regexp.QuoteMeta(m.Path))))
+	case m.Path != "":
+		p = append(p, predicate("Path", m.Path))
+	case m.Prefix != "" && m.Prefix != "/":
+		p = append(p, predicate("PathRegexp", prefixRegexp(m.Prefix)))
+	}
+
+	for _, h := range m.Headers {
+		if h.InvertMatch {
+			return nil, fmt.Errorf("inverted header match not supported: %s", h.Name)
+		}
+
+		switch {
+		case h.SafeRegexMatch != nil:
+			p = append(p, predicate("HeaderRegexp", h.Name, pathRegexp(h.SafeRegexMatch.Regex)))
+		case h.PrefixMatch != "":
+			p = append(p, predicate("HeaderRegexp", h.Name, prefixRegexp(h.PrefixMatch)))
+		case h.PresentMatch:
+			p = append(p, predicate("HeaderRegexp", h.Name, ".*"))
+		default:
+			p = append(p, predicate("Header", h.Name, h.ExactMatch))
+		}
+	}
+
+	return p, nil
+}
+
+func redirectCode(code string) int {
+	switch code {
+	case "FOUND":
+		return 302
+	case "SEE_OTHER":
+		return 303
+	case "TEMPORARY_REDIRECT":
+		return 307
+	case "PERMANENT_REDIRECT":
+		return 308
+	default:
+		return 301
+	}
+}
+
+func redirectLocation(a *redirectAction) string {
+	u := url.URL{
+		Scheme: a.SchemeRedirect,
+		Host:   a.HostRedirect,
+		Path:   a.PathRedirect,
+	}
+
+	if a.HTTPSRedirect {
+		u.Scheme = "https"
+	}
+
+	if u.Scheme != "" && u.Host == "" && u.Path == "" {
+		// keeping the host and the path of the request
+		return u.Scheme + ":"
+	}
+
+	return u.String()
+}
+
+func rewriteFilters(m routeMatch, a *routeAction) ([]*eskip.Filter, error) {
+	var f []*eskip.Filter
+	if a.PrefixRewrite != "" {
+		switch {
+		case m.SafeRegex != nil:
+			return nil, fmt.Errorf("prefix rewrite not supported with regular expression match")
+		case m.Path != "":
+			f = append(f, filter("modPath", pathRegexp(regexp.QuoteMeta(m.Path)), a.PrefixRewrite))
+		default:
+			f = append(f, filter("modPath", prefixRegexp(m.Prefix), a.PrefixRewrite))
+		}
+	}
+
+	if a.HostRewriteLiteral != "" {
+		f = append(f, filter("setRequestHeader", "Host", a.HostRewriteLiteral))
+	}
+
+	return f, nil
+}
+
+// weightedRoutes splits a route into one route per cluster, with the
+// Traffic predicate distributing the requests according to the weights,
+// and the True predicates balancing the priority of the routes.
+func weightedRoutes(r *eskip.Route, clusters map[string]*cluster, wc []weightedCluster) ([]*eskip.Route, error) {
+	var sum int
+	for _, c := range wc {
+		sum += c.Weight
+	}
+
+	var routes []*eskip.Route
+	for i, c := range wc {
+		if c.Weight <= 0 && sum > 0 {
+			continue
+		}
+
+		ri := r.Copy()
+		ri.Id = r.Id + "_" + strconv.Itoa(i)
+		if err := setClusterBackend(ri, clusters, c.Name); err != nil {
+			return nil, err
+		}
+
+		if c.Weight < sum {
+			ri.Predicates = append(ri.Predicates, predicate("Traffic", float64(c.Weight)/float64(sum)))
+			for j := i; j < len(wc)-2; j++ {
+				ri.Predicates = append(ri.Predicates, predicate("True"))
+			}
+		}
+
+		sum -= c.Weight
+		routes = append(routes, ri)
+	}
+
+	return routes, nil
+}
+
+func convertRoute(id string, hostRx string, weight int, rt *route, clusters map[string]*cluster) ([]*eskip.Route, error) {
+	p, err := matchPredicates(rt.Match)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostRx != "" {
+		p = append([]*eskip.Predicate{predicate("Host", hostRx)}, p...)
+	}
+
+	p = append(p, predicate("Weight", float64(weight)))
+	r := &eskip.Route{Id: id, Predicates: p}
+	switch {
+	case rt.Route != nil:
+		r.Filters, err = rewriteFilters(rt.Match, rt.Route)
+		if err != nil {
+			return nil, err
+		}
+
+		if rt.Route.WeightedClusters != nil {
+			return weightedRoutes(r, clusters, rt.Route.WeightedClusters.Clusters)
+		}
+
+		if err := setClusterBackend(r, clusters, rt.Route.Cluster); err != nil {
+			return nil, err
+		}
+	case rt.Redirect != nil:
+		r.Filters = []*eskip.Filter{
+			filter("redirectTo", float64(redirectCode(rt.Redirect.ResponseCode)), redirectLocation(rt.Redirect)),
+		}
+
+		r.BackendType = eskip.ShuntBackend
+		r.Shunt = true
+	case rt.DirectResponse != nil:
+		r.Filters = []*eskip.Filter{filter("status", float64(rt.DirectResponse.Status))}
+		if rt.DirectResponse.Body != nil {
+			r.Filters = append(r.Filters, filter("inlineContent", rt.DirectResponse.Body.InlineString))
+		}
+
+		r.BackendType = eskip.ShuntBackend
+		r.Shunt = true
+	default:
+		return nil, fmt.Errorf("route without action")
+	}
+
+	return []*eskip.Route{r}, nil
+}
+
+// convertRouteConfiguration translates the routes of the virtual hosts
+// to eskip routes. The ordering of the Envoy routes inside a virtual host
+// is preserved with the Weight predicate.
+func convertRouteConfiguration(rc *routeConfiguration, clusters map[string]*cluster) []*eskip.Route {
+	var routes []*eskip.Route
+	for _, vh := range rc.VirtualHosts {
+		hostRx := hostRegexp(vh.Domains)
+		ids := make(map[string]bool)
+		for i := range vh.Routes {
+			rt := &vh.Routes[i]
+			name := rt.Name
+			if name == "" || ids[name] {
+				name = strconv.Itoa(i)
+			}
+
+			ids[name] = true
+			id := sanitizeID(fmt.Sprintf("xds_%s_%s_%s", rc.Name, vh.Name, name))
+			r, err := convertRoute(id, hostRx, len(vh.Routes)-i, rt, clusters)
+			if err != nil {
+				log.Errorf("error while converting route %s: %v", id, err)
+				continue
+			}
+
+			routes = append(routes, r...)
+		}
+	}
+
+	return routes
+}
@@ -0,0 +1,338 @@
+/*
+Package xds implements a DataClient for reading the skipper route
+definitions from an xDS management server, built for the Envoy proxy.
+
+(See the DataClient interface in the skipper/routing package.)
+
+The client supports two transports of the xDS v3 protocol, selected by the
+scheme of the management server URL:
+
+- grpc:// and grpcs:// (with TLS) use the aggregated discovery service
+(ADS) over gRPC, envoy.service.discovery.v3.AggregatedDiscoveryService,
+which is the default transport of the control planes, e.g. of Istio, or of
+the servers built with go-control-plane. The client keeps a stream open,
+and receives the changes as they are pushed by the management server,
+
+- http:// and https:// use the REST-JSON transport, polling the POST
+/v3/discovery:routes and /v3/discovery:clusters endpoints.
+
+With both transports, the client subscribes to the route configurations
+(RDS) and the clusters (CDS), with the state of the world variant of the
+protocol. The accepted responses are acknowledged with their version and
+nonce. When the resources of a gRPC response cannot be decoded, the
+response is rejected (NACK) with the error detail, and the previously
+accepted version is kept. The incremental (delta) protocol is not
+supported. Some control planes, e.g. Istio, don't serve the route
+configurations without their names, in which case the names need to be set
+in the RouteConfigurations option.
+
+The virtual hosts and their routes are translated to eskip routes:
+
+- the domains of the virtual hosts are matched with the Host predicate,
+
+- the path prefix, exact path and regular expression matches are
+translated to the PathRegexp and Path predicates,
+
+- the header matches are translated to the Header and HeaderRegexp
+predicates,
+
+- the clusters are translated to network or load balanced backends, and
+the weighted clusters to multiple routes with the Traffic predicate,
+
+- the prefix and host rewrites are translated to the modPath and
+setRequestHeader filters,
+
+- the redirect and direct response routes are translated to shunt routes
+with the redirectTo, status and inlineContent filters.
+
+Only the clusters with statically assigned endpoints are supported, the
+endpoint discovery (EDS) is not. Since the Envoy routes of a virtual host
+are matched in order, the client preserves their order by setting the
+Weight predicate. The routes that cannot be translated are skipped and
+logged.
+*/
+package xds
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	clustersPath   = "/v3/discovery:clusters"
+	routesPath     = "/v3/discovery:routes"
+	defaultTimeout = 3 * time.Second
+	defaultNodeID  = "skipper"
+)
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// URL of the xDS management server. Required. The grpc and grpcs
+	// schemes select the aggregated discovery service over gRPC, e.g.
+	// grpc://istiod:15010, the http and https schemes the REST-JSON
+	// transport.
+	Server string
+
+	// Node ID used to identify the client to the management server.
+	// Defaults to the hostname.
+	NodeID string
+
+	// Node cluster used to identify the client to the management
+	// server.
+	NodeCluster string
+
+	// Names of the route configurations to load. When not set, all the
+	// route configurations are loaded.
+	RouteConfigurations []string
+
+	// Timeout of the requests to the management server, and, with
+	// gRPC, of waiting for the initial resources. Defaults to 3
+	// seconds. Ignored with REST-JSON when Client is set.
+	Timeout time.Duration
+
+	// HTTP client used to make the requests to the management server,
+	// with REST-JSON.
+	Client *http.Client
+}
+
+// tracks the version of the resources of a type
+type resourceState struct {
+	typeURL   string
+	path      string
+	names     []string
+	version   string
+	nonce     string
+	resources []json.RawMessage
+}
+
+// Client loads the routes from an xDS management server.
+type Client struct {
+	server   string
+	node     node
+	client   *http.Client
+	clusters *resourceState
+	configs  *resourceState
+	routes   map[string]*eskip.Route
+	ads      *ads
+}
+
+var errMissingServer = errors.New("missing xDS management server")
+
+// New creates a Client with the provided options.
+func New(o Options) (*Client, error) {
+	if o.Server == "" {
+		return nil, errMissingServer
+	}
+
+	if o.NodeID == "" {
+		o.NodeID, _ = os.Hostname()
+		if o.NodeID == "" {
+			o.NodeID = defaultNodeID
+		}
+	}
+
+	u, err := url.Parse(o.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: o.Timeout}
+	}
+
+	c := &Client{
+		server: strings.TrimSuffix(o.Server, "/"),
+		node:   node{ID: o.NodeID, Cluster: o.NodeCluster},
+		client: o.Client,
+		clusters: &resourceState{
+			typeURL: clusterType,
+			path:    clustersPath,
+		},
+		configs: &resourceState{
+			typeURL: routeConfigType,
+			path:    routesPath,
+			names:   o.RouteConfigurations,
+		},
+	}
+
+	if u.Scheme == grpcScheme || u.Scheme == grpcsScheme {
+		c.ads = newADS(u.Scheme, u.Host, c.node, o.RouteConfigurations, o.Timeout)
+	}
+
+	return c, nil
+}
+
+// fetch requests the resources of a type, acknowledging the last
+// received version. It returns false when the resources didn't change.
+func (c *Client) fetch(s *resourceState) (bool, error) {
+	b, err := json.Marshal(discoveryRequest{
+		VersionInfo:   s.version,
+		Node:          c.node,
+		ResourceNames: s.names,
+		TypeURL:       s.typeURL,
+		ResponseNonce: s.nonce,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rsp, err := c.client.Post(c.server+s.path, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return false, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch %s: %s", s.typeURL, rsp.Status)
+	}
+
+	var dr discoveryResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&dr); err != nil {
+		return false, err
+	}
+
+	if dr.VersionInfo == s.version && s.resources != nil {
+		return false, nil
+	}
+
+	s.version = dr.VersionInfo
+	s.nonce = dr.Nonce
+	s.resources = dr.Resources
+	if s.resources == nil {
+		s.resources = []json.RawMessage{}
+	}
+
+	return true, nil
+}
+
+func (c *Client) convert() map[string]*eskip.Route {
+	clusters := make(map[string]*cluster)
+	for _, raw := range c.clusters.resources {
+		var ci cluster
+		if err := json.Unmarshal(raw, &ci); err != nil {
+			log.Errorf("error while parsing xDS cluster: %v", err)
+			continue
+		}
+
+		clusters[ci.Name] = &ci
+	}
+
+	routes := make(map[string]*eskip.Route)
+	for _, raw := range c.configs.resources {
+		var rc routeConfiguration
+		if err := json.Unmarshal(raw, &rc); err != nil {
+			log.Errorf("error while parsing xDS route configuration: %v", err)
+			continue
+		}
+
+		for _, r := range convertRouteConfiguration(&rc, clusters) {
+			routes[r.Id] = r
+		}
+	}
+
+	return routes
+}
+
+// update updates the resources, and returns true when any of them
+// changed. When all is true, all the resources are loaded, not only the
+// changed ones.
+func (c *Client) update(all bool) (bool, error) {
+	states := []*resourceState{c.clusters, c.configs}
+	if all {
+		for _, s := range states {
+			s.version, s.nonce, s.resources = "", "", nil
+		}
+	}
+
+	if c.ads != nil {
+		return c.ads.load(all, states...)
+	}
+
+	var changed bool
+	for _, s := range states {
+		ch, err := c.fetch(s)
+		if err != nil {
+			return false, err
+		}
+
+		changed = changed || ch
+	}
+
+	return changed, nil
+}
+
+// LoadAll returns the routes translated from the current route
+// configurations of the management server. With gRPC, it waits for the
+// initial resources, when they were not received yet.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	if _, err := c.update(true); err != nil {
+		return nil, err
+	}
+
+	c.routes = c.convert()
+	var routes []*eskip.Route
+	for _, r := range c.routes {
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// LoadUpdate returns the routes that changed since the previous load,
+// and the IDs of the deleted routes.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	changed, err := c.update(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !changed {
+		return nil, nil, nil
+	}
+
+	routes := c.convert()
+
+	var upserts []*eskip.Route
+	for id, r := range routes {
+		if !reflect.DeepEqual(r, c.routes[id]) {
+			upserts = append(upserts, r)
+		}
+	}
+
+	var deletedIDs []string
+	for id := range c.routes {
+		if _, ok := routes[id]; !ok {
+			deletedIDs = append(deletedIDs, id)
+		}
+	}
+
+	c.routes = routes
+	return upserts, deletedIDs, nil
+}
+
+// Close closes the stream to the management server, when using gRPC.
+func (c *Client) Close() {
+	if c.ads != nil {
+		c.ads.close()
+	}
+}
@@ -0,0 +1,303 @@
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const testClusters = `[{
+	"@type": "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+	"name": "foo",
+	"loadAssignment": {"endpoints": [{"lbEndpoints": [
+		{"endpoint": {"address": {"socketAddress": {"address": "10.0.0.1", "portValue": 8080}}}}
+	]}]}
+}, {
+	"@type": "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+	"name": "bar",
+	"lbPolicy": "RING_HASH",
+	"transportSocket": {"name": "envoy.transport_sockets.tls"},
+	"loadAssignment": {"endpoints": [{"lbEndpoints": [
+		{"endpoint": {"address": {"socketAddress": {"address": "10.0.1.1", "portValue": 443}}}},
+		{"endpoint": {"address": {"socketAddress": {"address": "10.0.1.2", "portValue": 443}}}}
+	]}]}
+}]`
+
+const testRouteConfigs = `[{
+	"@type": "type.googleapis.com/envoy.config.route.v3.RouteConfiguration",
+	"name": "main",
+	"virtualHosts": [{
+		"name": "example",
+		"domains": ["example.org", "*.example.org"],
+		"routes": [{
+			"name": "api",
+			"match": {"prefix": "/api", "headers": [{"name": "X-Version", "exactMatch": "2"}]},
+			"route": {"cluster": "bar", "prefixRewrite": "/v2"}
+		}, {
+			"match": {"path": "/old"},
+			"redirect": {"pathRedirect": "/new", "responseCode": "FOUND"}
+		}, {
+			"name": "split",
+			"match": {"prefix": "/"},
+			"route": {"weightedClusters": {"clusters": [
+				{"name": "foo", "weight": 80},
+				{"name": "bar", "weight": 20}
+			]}}
+		}]
+	}, {
+		"name": "any",
+		"domains": ["*"],
+		"routes": [{
+			"name": "health",
+			"match": {"safeRegex": {"regex": "/health(z)?"}},
+			"directResponse": {"status": 200, "body": {"inlineString": "ok"}}
+		}, {
+			"name": "invalid",
+			"match": {"prefix": "/"},
+			"route": {"cluster": "missing"}
+		}]
+	}]
+}]`
+
+type testServer struct {
+	mx       sync.Mutex
+	version  string
+	clusters string
+	configs  string
+	requests []discoveryRequest
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	var req discoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.requests = append(s.requests, req)
+	if req.VersionInfo == s.version {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var resources string
+	switch r.URL.Path {
+	case clustersPath:
+		resources = s.clusters
+	case routesPath:
+		resources = s.configs
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Write([]byte(`{"versionInfo": "` + s.version + `", "nonce": "n` + s.version + `", "resources": ` + resources + `}`))
+}
+
+func (s *testServer) update(version, clusters, configs string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.version, s.clusters, s.configs = version, clusters, configs
+}
+
+func startTestServer(t *testing.T) (*testServer, *httptest.Server, *Client) {
+	s := &testServer{version: "1", clusters: testClusters, configs: testRouteConfigs}
+	server := httptest.NewServer(s)
+	c, err := New(Options{Server: server.URL, NodeID: "test-node"})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return s, server, c
+}
+
+func mapByID(routes []*eskip.Route) map[string]*eskip.Route {
+	m := make(map[string]*eskip.Route)
+	for _, r := range routes {
+		m[r.Id] = r
+	}
+
+	return m
+}
+
+// the routes translated from testClusters and testRouteConfigs
+const expectedRoutes = `
+xds_main_example_api:
+	Host(/^(example\.org(:[0-9]+)?|.+\.example\.org(:[0-9]+)?)$/)
+	&& PathRegexp(/^\/api/)
+	&& Header("X-Version", "2")
+	&& Weight(3)
+	-> modPath("^/api", "/v2")
+	-> <consistentHash, "https://10.0.1.1:443", "https://10.0.1.2:443">;
+
+xds_main_example_1:
+	Host(/^(example\.org(:[0-9]+)?|.+\.example\.org(:[0-9]+)?)$/)
+	&& Path("/old")
+	&& Weight(2)
+	-> redirectTo(302, "/new")
+	-> <shunt>;
+
+xds_main_example_split_0:
+	Host(/^(example\.org(:[0-9]+)?|.+\.example\.org(:[0-9]+)?)$/)
+	&& Weight(1)
+	&& Traffic(0.8)
+	-> "http://10.0.0.1:8080";
+
+xds_main_example_split_1:
+	Host(/^(example\.org(:[0-9]+)?|.+\.example\.org(:[0-9]+)?)$/)
+	&& Weight(1)
+	-> <consistentHash, "https://10.0.1.1:443", "https://10.0.1.2:443">;
+
+xds_main_any_health:
+	PathRegexp(/^(?:\/health(z)?)$/)
+	&& Weight(2)
+	-> status(200)
+	-> inlineContent("ok")
+	-> <shunt>;
+`
+
+func checkRoutes(t *testing.T, routes []*eskip.Route) {
+	expected, err := eskip.Parse(expectedRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := mapByID(routes)
+	if len(got) != len(expected) {
+		t.Errorf("invalid number of routes, expected: %d, got: %d", len(expected), len(got))
+	}
+
+	for _, e := range expected {
+		r, ok := got[e.Id]
+		if !ok {
+			t.Errorf("route not found: %s", e.Id)
+			continue
+		}
+
+		// normalizing the order of the predicates
+		p, err := eskip.Parse(r.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p[0].String() != e.String() {
+			t.Errorf("invalid route %s\nexpected: %s\ngot:      %s", e.Id, e.String(), p[0].String())
+		}
+	}
+}
+
+func TestMissingServer(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingServer {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	_, server, c := startTestServer(t)
+	defer server.Close()
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, routes)
+}
+
+func TestLoadUpdate(t *testing.T) {
+	s, server, c := startTestServer(t)
+	defer server.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 0 || len(deleted) != 0 {
+		t.Error("unexpected update", routes, deleted)
+	}
+
+	s.mx.Lock()
+	last := s.requests[len(s.requests)-1]
+	s.mx.Unlock()
+	if last.VersionInfo != "1" || last.ResponseNonce != "n1" || last.Node.ID != "test-node" {
+		t.Error("failed to acknowledge the received version", last)
+	}
+
+	s.update("2", testClusters, `[{
+		"name": "main",
+		"virtualHosts": [{
+			"name": "any",
+			"domains": ["*"],
+			"routes": [{
+				"name": "health",
+				"match": {"safeRegex": {"regex": "/health(z)?"}},
+				"directResponse": {"status": 204}
+			}]
+		}]
+	}]`)
+
+	routes, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "xds_main_any_health" {
+		t.Error("failed to receive the updated route", routes)
+	}
+
+	sort.Strings(deleted)
+	if len(deleted) != 4 || deleted[0] != "xds_main_example_1" {
+		t.Error("failed to receive the deleted routes", deleted)
+	}
+}
+
+func TestLoadFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, err := New(Options{Server: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestHostRegexp(t *testing.T) {
+	for _, test := range []struct {
+		domains  []string
+		expected string
+	}{{
+		domains: nil,
+	}, {
+		domains: []string{"example.org", "*"},
+	}, {
+		domains:  []string{"example.org:8080"},
+		expected: `^(example\.org:8080)$`,
+	}, {
+		domains:  []string{"www.*"},
+		expected: `^(www\..+(:[0-9]+)?)$`,
+	}} {
+		if got := hostRegexp(test.domains); got != test.expected {
+			t.Errorf("invalid host regexp for %v, expected: %s, got: %s", test.domains, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// +build zookeeper
+
+// The integration tests run against a real ZooKeeper ensemble. The
+// addresses of the servers are taken from the ZOOKEEPER_SERVERS
+// environment variable, defaulting to 127.0.0.1:2181:
+//
+//     docker run -d -p 2181:2181 zookeeper
+//     go test -tags zookeeper ./dataclients/zookeeper
+//
+package zookeeper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func integrationClient(t *testing.T, prefix string) *Client {
+	servers := os.Getenv("ZOOKEEPER_SERVERS")
+	if servers == "" {
+		servers = "127.0.0.1:2181"
+	}
+
+	c, err := New(Options{
+		Servers: strings.Split(servers, ","),
+		Prefix:  prefix,
+		Timeout: 300 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestIntegrationLoadUpdate(t *testing.T) {
+	prefix := fmt.Sprintf("/skipper-test-%d", time.Now().UnixNano())
+	c := integrationClient(t, prefix)
+	defer c.Close()
+
+	writer := integrationClient(t, prefix)
+	defer writer.Close()
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r)
+
+	routes, err := eskip.Parse(`foo: Path("/foo") -> <shunt>; bar: * -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer writer.DeleteAllIf(routes, func(*eskip.Route) bool { return true })
+	if err := writer.UpsertAll(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	// the routes znode was created by the writer, the reader receives it
+	// with the watch set on its creation
+	r, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "bar", "foo")
+	if len(deleted) != 0 {
+		t.Error("unexpected deletes", deleted)
+	}
+
+	routes[0].Path = "/baz"
+	if err := writer.Upsert(routes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "foo")
+	if len(r) == 1 && r[0].Path != "/baz" {
+		t.Error("failed to receive the updated route")
+	}
+
+	if len(deleted) != 1 || deleted[0] != "bar" {
+		t.Error("failed to receive the deleted route", deleted)
+	}
+
+	// a new session loads the current state
+	r, err = c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "foo")
+}
@@ -0,0 +1,520 @@
+/*
+Package zookeeper implements a DataClient for reading the skipper route
+definitions from ZooKeeper.
+
+(See the DataClient interface in the skipper/routing package.)
+
+The layout of the stored routes is analogous to the etcd data client: the
+route definitions are stored as eskip route expressions in the data of
+individual znodes under the /routes znode of the configured prefix. When
+loaded from ZooKeeper, the routes get the name of the znode as id. The
+client receives the updates via ZooKeeper watches.
+
+The client uses the github.com/go-zookeeper/zk library, which handles
+the reconnects to the ensemble and the restoring of the watches. When the
+session expires, the watches are lost, and the client loads the whole set
+of routes again, with a new session.
+
+In addition to the DataClient implementation, type Client provides
+methods to Upsert and Delete routes.
+*/
+package zookeeper
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/internal/routeset"
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	routesPath            = "/routes"
+	defaultPrefix         = "/skipper"
+	defaultSessionTimeout = 10 * time.Second
+	defaultTimeout        = time.Second
+)
+
+// Options contains the initialization options of the client.
+type Options struct {
+
+	// Addresses of the ZooKeeper servers (host:port). Required.
+	Servers []string
+
+	// Path of the znode where the Skipper related data is stored.
+	// Defaults to /skipper.
+	Prefix string
+
+	// ZooKeeper session timeout. Defaults to 10 seconds.
+	SessionTimeout time.Duration
+
+	// The time LoadUpdate waits for watch events. Defaults to
+	// 1 second.
+	Timeout time.Duration
+}
+
+// Client is used to load the whole set of routes and the updates from
+// ZooKeeper.
+type Client struct {
+	servers        []string
+	prefix         string
+	routesRoot     string
+	sessionTimeout time.Duration
+	timeout        time.Duration
+	dial           func([]string, time.Duration) (zkConn, <-chan zk.Event, error)
+	session        *session
+	data           map[string]string
+}
+
+// the subset of the zk.Conn methods used by the client
+type zkConn interface {
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Delete(path string, version int32) error
+	Close()
+}
+
+// session collects the events of the one-shot watches set during a
+// ZooKeeper session
+type session struct {
+	conn    zkConn
+	mx      sync.Mutex
+	events  []zk.Event
+	signal  chan struct{}
+	expired chan struct{}
+	once    sync.Once
+}
+
+var (
+	errMissingServers = errors.New("missing ZooKeeper servers")
+	errMissingRouteID = errors.New("missing route id")
+	errNoSession      = errors.New("no ZooKeeper session")
+	errSessionLost    = errors.New("ZooKeeper session lost")
+)
+
+// New creates a Client with the provided options. It connects to
+// ZooKeeper on the first request.
+func New(o Options) (*Client, error) {
+	if len(o.Servers) == 0 {
+		return nil, errMissingServers
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = defaultPrefix
+	}
+
+	if o.SessionTimeout <= 0 {
+		o.SessionTimeout = defaultSessionTimeout
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	prefix := "/" + strings.Trim(o.Prefix, "/")
+	return &Client{
+		servers:        o.Servers,
+		prefix:         prefix,
+		routesRoot:     prefix + routesPath,
+		sessionTimeout: o.SessionTimeout,
+		timeout:        o.Timeout,
+		dial:           dial,
+		data:           make(map[string]string),
+	}, nil
+}
+
+// connects to the ZooKeeper ensemble, and waits until a session is
+// established, or the session timeout is over
+func dial(servers []string, sessionTimeout time.Duration) (zkConn, <-chan zk.Event, error) {
+	conn, events, err := zk.Connect(servers, sessionTimeout, zk.WithLogger(log.StandardLogger()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timeout := time.After(sessionTimeout)
+	for {
+		select {
+		case e := <-events:
+			if e.State == zk.StateHasSession {
+				return conn, events, nil
+			}
+		case <-timeout:
+			conn.Close()
+			return nil, nil, zk.ErrNoServer
+		}
+	}
+}
+
+func newSession(conn zkConn, events <-chan zk.Event) *session {
+	s := &session{
+		conn:    conn,
+		signal:  make(chan struct{}, 1),
+		expired: make(chan struct{}),
+	}
+
+	go func() {
+		for e := range events {
+			if e.State == zk.StateExpired {
+				s.expire()
+			}
+		}
+
+		// the connection was closed
+		s.expire()
+	}()
+
+	return s
+}
+
+func (s *session) expire() {
+	s.once.Do(func() { close(s.expired) })
+}
+
+// receives the event of a one-shot watch
+func (s *session) watch(w <-chan zk.Event) {
+	go func() {
+		e, ok := <-w
+		if !ok {
+			return
+		}
+
+		s.mx.Lock()
+		s.events = append(s.events, e)
+		s.mx.Unlock()
+
+		select {
+		case s.signal <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// returns the received watch events, and fails when a watch was lost
+func (s *session) receivedEvents() ([]zk.Event, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	events := s.events
+	s.events = nil
+	for _, e := range events {
+		if e.Type == zk.EventNotWatching {
+			return nil, errSessionLost
+		}
+	}
+
+	return events, nil
+}
+
+func (s *session) close() {
+	s.conn.Close()
+}
+
+// returns the current session, or creates a new one
+func (c *Client) connection() (*session, error) {
+	if c.session != nil {
+		select {
+		case <-c.session.expired:
+			c.session.close()
+			c.session = nil
+		default:
+			return c.session, nil
+		}
+	}
+
+	conn, events, err := c.dial(c.servers, c.sessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session = newSession(conn, events)
+	return c.session, nil
+}
+
+func (s *session) getChildren(p string) ([]string, error) {
+	children, _, w, err := s.conn.ChildrenW(p)
+	if err != nil {
+		return nil, err
+	}
+
+	s.watch(w)
+	return children, nil
+}
+
+func (s *session) getData(p string) ([]byte, error) {
+	data, _, w, err := s.conn.GetW(p)
+	if err != nil {
+		return nil, err
+	}
+
+	s.watch(w)
+	return data, nil
+}
+
+func (s *session) exists(p string) (bool, error) {
+	exists, _, w, err := s.conn.ExistsW(p)
+	if err != nil {
+		return false, err
+	}
+
+	s.watch(w)
+	return exists, nil
+}
+
+// lists the route znodes, and sets a watch on the routes znode. When the
+// routes znode doesn't exist, it watches for its creation.
+func (c *Client) watchChildren(s *session) ([]string, error) {
+	children, err := s.getChildren(c.routesRoot)
+	if err == zk.ErrNoNode {
+		exists, err := s.exists(c.routesRoot)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			// created meanwhile
+			return c.watchChildren(s)
+		}
+
+		return nil, nil
+	}
+
+	return children, err
+}
+
+// LoadAll returns all the route definitions currently stored in
+// ZooKeeper, and sets the watches for the subsequent updates.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	// starting a new session to have a clean set of watches
+	if c.session != nil {
+		c.session.close()
+		c.session = nil
+	}
+
+	s, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := c.watchChildren(s)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string)
+	for _, id := range children {
+		d, err := s.getData(c.routesRoot + "/" + id)
+		if err == zk.ErrNoNode {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data[id] = string(d)
+	}
+
+	c.data = data
+	return routeset.ParseLogged(data), nil
+}
+
+// LoadUpdate returns the updates (upserts and deletes) since the last
+// initial request or update.
+//
+// It collects the watch events until the configured timeout, and loads
+// the current state of the changed routes, renewing the watches. When the
+// session expired, or a watch was lost, it returns an error, and the
+// routes need to be loaded again with LoadAll.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if c.session == nil {
+		return nil, nil, errNoSession
+	}
+
+	s := c.session
+	var (
+		childrenChanged bool
+		changed         = make(map[string]bool)
+	)
+
+	timeout := time.After(c.timeout)
+collect:
+	for {
+		select {
+		case <-s.signal:
+			events, err := s.receivedEvents()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for _, e := range events {
+				switch {
+				case e.Path == c.routesRoot:
+					childrenChanged = true
+				case path.Dir(e.Path) == c.routesRoot:
+					changed[path.Base(e.Path)] = true
+				}
+			}
+		case <-s.expired:
+			return nil, nil, errSessionLost
+		case <-timeout:
+			break collect
+		}
+	}
+
+	deleted := make(map[string]bool)
+	if childrenChanged {
+		children, err := c.watchChildren(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		current := make(map[string]bool)
+		for _, id := range children {
+			current[id] = true
+			if _, ok := c.data[id]; !ok {
+				changed[id] = true
+			}
+		}
+
+		for id := range c.data {
+			if !current[id] {
+				deleted[id] = true
+			}
+		}
+	}
+
+	updates := make(map[string]string)
+	for id := range changed {
+		if deleted[id] {
+			continue
+		}
+
+		d, err := s.getData(c.routesRoot + "/" + id)
+		if err == zk.ErrNoNode {
+			deleted[id] = true
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if s := string(d); s != c.data[id] {
+			updates[id] = s
+			c.data[id] = s
+		}
+	}
+
+	var deletedIDs []string
+	for id := range deleted {
+		if _, ok := c.data[id]; ok {
+			deletedIDs = append(deletedIDs, id)
+			delete(c.data, id)
+		}
+	}
+
+	routes, deletedIDs := routeset.ParseUpdates(updates, deletedIDs)
+	return routes, deletedIDs, nil
+}
+
+// creates the znode and its missing parents
+func createAll(conn zkConn, p string, data []byte) error {
+	_, err := conn.Create(p, data, 0, zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNoNode {
+		if err := createAll(conn, path.Dir(p), nil); err != nil && err != zk.ErrNodeExists {
+			return err
+		}
+
+		_, err = conn.Create(p, data, 0, zk.WorldACL(zk.PermAll))
+	}
+
+	return err
+}
+
+// Upsert inserts or updates a route in ZooKeeper.
+func (c *Client) Upsert(r *eskip.Route) error {
+	if r.Id == "" {
+		return errMissingRouteID
+	}
+
+	s, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	p := c.routesRoot + "/" + r.Id
+	data := []byte(r.String())
+	_, err = s.conn.Set(p, data, -1)
+	if err == zk.ErrNoNode {
+		err = createAll(s.conn, p, data)
+		if err == zk.ErrNodeExists {
+			// created meanwhile
+			_, err = s.conn.Set(p, data, -1)
+		}
+	}
+
+	return err
+}
+
+// Delete deletes a route from ZooKeeper.
+func (c *Client) Delete(id string) error {
+	if id == "" {
+		return errMissingRouteID
+	}
+
+	s, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	err = s.conn.Delete(c.routesRoot+"/"+id, -1)
+	if err == zk.ErrNoNode {
+		err = nil
+	}
+
+	return err
+}
+
+// UpsertAll inserts or updates all the routes, generating an id for those
+// that don't have one.
+func (c *Client) UpsertAll(routes []*eskip.Route) error {
+	for _, r := range routes {
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		if err := c.Upsert(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteAllIf deletes the routes that match the condition.
+func (c *Client) DeleteAllIf(routes []*eskip.Route, cond eskip.RoutePredicate) error {
+	for _, r := range routes {
+		if !cond(r) {
+			continue
+		}
+
+		if err := c.Delete(r.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the ZooKeeper session.
+func (c *Client) Close() {
+	if c.session != nil {
+		c.session.close()
+		c.session = nil
+	}
+}
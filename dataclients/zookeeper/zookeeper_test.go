@@ -0,0 +1,472 @@
+package zookeeper
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/zalando/skipper/eskip"
+)
+
+// fakeServer is an in-memory ZooKeeper, supporting the operations used
+// by the client through the zkConn interface, with one-shot watches.
+type fakeServer struct {
+	mx           sync.Mutex
+	nodes        map[string][]byte
+	dataWatches  map[string][]*fakeWatch
+	childWatches map[string][]*fakeWatch
+	conns        []*fakeConn
+	failSessions bool
+}
+
+type fakeWatch struct {
+	conn *fakeConn
+	ch   chan zk.Event
+}
+
+type fakeConn struct {
+	server *fakeServer
+	events chan zk.Event
+	closed bool
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		nodes:        map[string][]byte{"/": nil},
+		dataWatches:  make(map[string][]*fakeWatch),
+		childWatches: make(map[string][]*fakeWatch),
+	}
+}
+
+func (s *fakeServer) dial([]string, time.Duration) (zkConn, <-chan zk.Event, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.failSessions {
+		return nil, nil, zk.ErrNoServer
+	}
+
+	c := &fakeConn{server: s, events: make(chan zk.Event, 1)}
+	s.conns = append(s.conns, c)
+	return c, c.events, nil
+}
+
+func (s *fakeServer) close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, c := range s.conns {
+		c.closeLocked(zk.ErrConnectionClosed)
+	}
+}
+
+// expires the sessions of all the connections
+func (s *fakeServer) expire() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, c := range s.conns {
+		if !c.closed {
+			c.events <- zk.Event{Type: zk.EventSession, State: zk.StateExpired}
+			c.invalidateLocked(zk.ErrSessionExpired)
+		}
+	}
+}
+
+func (s *fakeServer) addWatch(watches map[string][]*fakeWatch, c *fakeConn, p string) <-chan zk.Event {
+	w := &fakeWatch{conn: c, ch: make(chan zk.Event, 1)}
+	watches[p] = append(watches[p], w)
+	return w.ch
+}
+
+// expected to be called with the lock held
+func trigger(watches map[string][]*fakeWatch, typ zk.EventType, p string) {
+	for _, w := range watches[p] {
+		w.ch <- zk.Event{Type: typ, State: zk.StateHasSession, Path: p}
+		close(w.ch)
+	}
+
+	delete(watches, p)
+}
+
+func (s *fakeServer) children(p string) []string {
+	var c []string
+	for n := range s.nodes {
+		if n != "/" && path.Dir(n) == p {
+			c = append(c, path.Base(n))
+		}
+	}
+
+	sort.Strings(c)
+	return c
+}
+
+func (c *fakeConn) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if c.closed {
+		return nil, nil, nil, zk.ErrConnectionClosed
+	}
+
+	if _, ok := s.nodes[p]; !ok {
+		return nil, nil, nil, zk.ErrNoNode
+	}
+
+	return s.children(p), &zk.Stat{}, s.addWatch(s.childWatches, c, p), nil
+}
+
+func (c *fakeConn) GetW(p string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if c.closed {
+		return nil, nil, nil, zk.ErrConnectionClosed
+	}
+
+	data, ok := s.nodes[p]
+	if !ok {
+		return nil, nil, nil, zk.ErrNoNode
+	}
+
+	return data, &zk.Stat{}, s.addWatch(s.dataWatches, c, p), nil
+}
+
+func (c *fakeConn) ExistsW(p string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if c.closed {
+		return false, nil, nil, zk.ErrConnectionClosed
+	}
+
+	_, ok := s.nodes[p]
+	return ok, &zk.Stat{}, s.addWatch(s.dataWatches, c, p), nil
+}
+
+func (c *fakeConn) Set(p string, data []byte, version int32) (*zk.Stat, error) {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.nodes[p]; !ok {
+		return nil, zk.ErrNoNode
+	}
+
+	s.nodes[p] = data
+	trigger(s.dataWatches, zk.EventNodeDataChanged, p)
+	return &zk.Stat{}, nil
+}
+
+func (c *fakeConn) Create(p string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.nodes[p]; ok {
+		return "", zk.ErrNodeExists
+	}
+
+	if _, ok := s.nodes[path.Dir(p)]; !ok {
+		return "", zk.ErrNoNode
+	}
+
+	s.nodes[p] = data
+	trigger(s.dataWatches, zk.EventNodeCreated, p)
+	trigger(s.childWatches, zk.EventNodeChildrenChanged, path.Dir(p))
+	return p, nil
+}
+
+func (c *fakeConn) Delete(p string, version int32) error {
+	s := c.server
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.nodes[p]; !ok {
+		return zk.ErrNoNode
+	}
+
+	delete(s.nodes, p)
+	trigger(s.dataWatches, zk.EventNodeDeleted, p)
+	trigger(s.childWatches, zk.EventNodeChildrenChanged, path.Dir(p))
+	return nil
+}
+
+func (c *fakeConn) Close() {
+	c.server.mx.Lock()
+	defer c.server.mx.Unlock()
+	c.closeLocked(zk.ErrClosing)
+}
+
+func (c *fakeConn) closeLocked(err error) {
+	if c.closed {
+		return
+	}
+
+	c.invalidateLocked(err)
+	c.closed = true
+	close(c.events)
+}
+
+// sends the not watching event to the watches of the connection
+func (c *fakeConn) invalidateLocked(err error) {
+	for _, watches := range []map[string][]*fakeWatch{c.server.dataWatches, c.server.childWatches} {
+		for p, ws := range watches {
+			var keep []*fakeWatch
+			for _, w := range ws {
+				if w.conn != c {
+					keep = append(keep, w)
+					continue
+				}
+
+				w.ch <- zk.Event{Type: zk.EventNotWatching, State: zk.StateDisconnected, Path: p, Err: err}
+				close(w.ch)
+			}
+
+			watches[p] = keep
+		}
+	}
+}
+
+func testClient(t *testing.T, s *fakeServer) *Client {
+	c, err := New(Options{Servers: []string{"zk.example.org:2181"}, Timeout: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.dial = s.dial
+	return c
+}
+
+func checkRoutes(t *testing.T, routes []*eskip.Route, expected ...string) {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Errorf("invalid routes, expected: %v, got: %v", expected, ids)
+	}
+}
+
+func TestMissingServers(t *testing.T) {
+	if _, err := New(Options{}); err != errMissingServers {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadAllEmpty(t *testing.T) {
+	s := newFakeServer()
+	defer s.close()
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r)
+}
+
+func TestUpsertLoadAll(t *testing.T) {
+	s := newFakeServer()
+	defer s.close()
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	routes, err := eskip.Parse(`foo: Path("/foo") -> <shunt>; bar: * -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertAll(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mx.Lock()
+	if _, ok := s.nodes["/skipper/routes/foo"]; !ok {
+		t.Error("failed to create the route znode")
+	}
+
+	s.nodes["/skipper/routes/invalid"] = []byte("invalid eskip")
+	s.mx.Unlock()
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "bar", "foo")
+}
+
+func TestLoadUpdate(t *testing.T) {
+	s := newFakeServer()
+	defer s.close()
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client of another skipper instance
+	writer := testClient(t, s)
+	defer writer.Close()
+
+	routes, err := eskip.Parse(`foo: Path("/foo") -> <shunt>; bar: * -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.UpsertAll(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	r, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "bar", "foo")
+	if len(deleted) != 0 {
+		t.Error("unexpected deletes", deleted)
+	}
+
+	routes[0].Path = "/baz"
+	if err := writer.Upsert(routes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "foo")
+	if len(r) == 1 && r[0].Path != "/baz" {
+		t.Error("failed to receive the updated route")
+	}
+
+	if len(deleted) != 1 || deleted[0] != "bar" {
+		t.Error("failed to receive the deleted route", deleted)
+	}
+
+	r, deleted, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 0 || len(deleted) != 0 {
+		t.Error("unexpected updates", r, deleted)
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	s := newFakeServer()
+	defer s.close()
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	if err := c.Delete("foo"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadUpdateFailsOnLostConnection(t *testing.T) {
+	s := newFakeServer()
+	c := testClient(t, s)
+	defer c.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.close()
+	if _, _, err := c.LoadUpdate(); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLoadUpdateFailsOnExpiredSession(t *testing.T) {
+	s := newFakeServer()
+	defer s.close()
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expire()
+	if _, _, err := c.LoadUpdate(); err == nil {
+		t.Fatal("failed to fail")
+	}
+
+	// the routing loads all the routes again after the failed update,
+	// with a new session, and the new watches
+	writer := testClient(t, s)
+	defer writer.Close()
+
+	routes, err := eskip.Parse(`foo: Path("/foo") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.UpsertAll(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "foo")
+	routes[0].Path = "/bar"
+	if err := writer.Upsert(routes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoutes(t, r, "foo")
+}
+
+func TestLoadAllFailsWithoutSession(t *testing.T) {
+	s := newFakeServer()
+	s.failSessions = true
+	c := testClient(t, s)
+	defer c.Close()
+
+	if _, err := c.LoadAll(); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestNoServer(t *testing.T) {
+	c, err := New(Options{
+		Servers:        []string{"127.0.0.1:1"},
+		SessionTimeout: 300 * time.Millisecond,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	if _, err := c.LoadAll(); err == nil {
+		t.Error("failed to fail")
+	}
+}
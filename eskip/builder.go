@@ -0,0 +1,220 @@
+package eskip
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+var (
+	errMultipleBackends = errors.New("multiple backends defined")
+	errMissingBackend   = errors.New("missing backend")
+	errNoLBEndpoints    = errors.New("missing load balancer endpoints")
+)
+
+var symbolRx = regexp.MustCompile(`^[a-zA-Z_]\w*$`)
+
+// RouteBuilder creates routes programmatically, as an alternative to
+// parsing them, or to creating the Route objects by hand. The methods
+// of the builder can be chained, and the validation of the route happens
+// when calling Build.
+//
+// Example:
+//
+//	r, err := eskip.NewRoute("api").
+//		Path("/api/*").
+//		Filter("setPath", "/v2/${1}").
+//		BackendURL("https://api.example.org").
+//		Build()
+type RouteBuilder struct {
+	route    parsedRoute
+	backends int
+	err      error
+}
+
+// NewRoute creates a route builder. The id is optional, when empty,
+// the route is built without an id, as if it was parsed from a route
+// expression.
+func NewRoute(id string) *RouteBuilder {
+	return &RouteBuilder{route: parsedRoute{id: id}}
+}
+
+// the numeric args are stored as float64, the same way as the parser
+// stores them
+func builderArgs(args []interface{}) ([]interface{}, error) {
+	result := make([]interface{}, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case string, float64:
+			result[i] = v
+		case int:
+			result[i] = float64(v)
+		case int32:
+			result[i] = float64(v)
+		case int64:
+			result[i] = float64(v)
+		case float32:
+			result[i] = float64(v)
+		default:
+			return nil, fmt.Errorf("invalid argument type: %T", a)
+		}
+	}
+
+	return result, nil
+}
+
+func (b *RouteBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Predicate adds a predicate to the route with its name and arguments,
+// e.g. Predicate("Traffic", .3). The arguments can be strings or
+// numbers.
+func (b *RouteBuilder) Predicate(name string, args ...interface{}) *RouteBuilder {
+	if !symbolRx.MatchString(name) {
+		b.fail(fmt.Errorf("invalid predicate name: %s", name))
+		return b
+	}
+
+	a, err := builderArgs(args)
+	if err != nil {
+		b.fail(fmt.Errorf("invalid predicate %s: %v", name, err))
+		return b
+	}
+
+	b.route.matchers = append(b.route.matchers, &matcher{name: name, args: a})
+	return b
+}
+
+// Path adds a Path predicate to the route.
+func (b *RouteBuilder) Path(path string) *RouteBuilder {
+	return b.Predicate("Path", path)
+}
+
+// PathSubtree adds a PathSubtree predicate to the route.
+func (b *RouteBuilder) PathSubtree(path string) *RouteBuilder {
+	return b.Predicate("PathSubtree", path)
+}
+
+// PathRegexp adds a PathRegexp predicate to the route.
+func (b *RouteBuilder) PathRegexp(rx string) *RouteBuilder {
+	return b.Predicate("PathRegexp", rx)
+}
+
+// Host adds a Host predicate to the route.
+func (b *RouteBuilder) Host(rx string) *RouteBuilder {
+	return b.Predicate("Host", rx)
+}
+
+// Method adds a Method predicate to the route.
+func (b *RouteBuilder) Method(method string) *RouteBuilder {
+	return b.Predicate("Method", method)
+}
+
+// Header adds a Header predicate to the route.
+func (b *RouteBuilder) Header(name, value string) *RouteBuilder {
+	return b.Predicate("Header", name, value)
+}
+
+// HeaderRegexp adds a HeaderRegexp predicate to the route.
+func (b *RouteBuilder) HeaderRegexp(name, rx string) *RouteBuilder {
+	return b.Predicate("HeaderRegexp", name, rx)
+}
+
+// Filter appends a filter to the filter chain of the route, with its
+// name and arguments. The arguments can be strings or numbers.
+func (b *RouteBuilder) Filter(name string, args ...interface{}) *RouteBuilder {
+	if !symbolRx.MatchString(name) {
+		b.fail(fmt.Errorf("invalid filter name: %s", name))
+		return b
+	}
+
+	a, err := builderArgs(args)
+	if err != nil {
+		b.fail(fmt.Errorf("invalid filter %s: %v", name, err))
+		return b
+	}
+
+	b.route.filters = append(b.route.filters, &Filter{Name: name, Args: a})
+	return b
+}
+
+// BackendURL sets a network backend for the route. The address needs
+// to be an absolute URL.
+func (b *RouteBuilder) BackendURL(address string) *RouteBuilder {
+	u, err := url.Parse(address)
+	if err != nil {
+		b.fail(err)
+	} else if u.Scheme == "" || u.Host == "" {
+		b.fail(fmt.Errorf("invalid backend address: %s", address))
+	}
+
+	b.route.backend = address
+	b.backends++
+	return b
+}
+
+// Shunt sets the <shunt> backend for the route.
+func (b *RouteBuilder) Shunt() *RouteBuilder {
+	b.route.shunt = true
+	b.backends++
+	return b
+}
+
+// Loopback sets the <loopback> backend for the route.
+func (b *RouteBuilder) Loopback() *RouteBuilder {
+	b.route.loopback = true
+	b.backends++
+	return b
+}
+
+// Dynamic sets the <dynamic> backend for the route.
+func (b *RouteBuilder) Dynamic() *RouteBuilder {
+	b.route.dynamic = true
+	b.backends++
+	return b
+}
+
+// LB sets a load balanced backend for the route. The algorithm is
+// optional, when empty, the default algorithm is used.
+func (b *RouteBuilder) LB(algorithm string, endpoints ...string) *RouteBuilder {
+	if len(endpoints) == 0 {
+		b.fail(errNoLBEndpoints)
+	}
+
+	b.route.lbBackend = true
+	b.route.lbAlgorithm = algorithm
+	b.route.lbEndpoints = endpoints
+	b.backends++
+	return b
+}
+
+// Build validates and returns the route. It checks the same rules as the
+// parser, e.g. that there is at most one Path predicate, and
+// additionally that exactly one backend was set.
+func (b *RouteBuilder) Build() (*Route, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if b.route.id != "" && !symbolRx.MatchString(b.route.id) {
+		return nil, fmt.Errorf("invalid route id: %s", b.route.id)
+	}
+
+	switch {
+	case b.backends == 0:
+		return nil, errMissingBackend
+	case b.backends > 1:
+		return nil, errMultipleBackends
+	}
+
+	// copying, so that the builder can be reused
+	pr := b.route
+	pr.matchers = append([]*matcher(nil), b.route.matchers...)
+	pr.filters = append([]*Filter(nil), b.route.filters...)
+	pr.lbEndpoints = append([]string(nil), b.route.lbEndpoints...)
+	return newRouteDefinition(&pr)
+}
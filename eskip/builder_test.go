@@ -0,0 +1,111 @@
+package eskip
+
+import "testing"
+
+func TestRouteBuilder(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		builder  *RouteBuilder
+		expected string
+		fail     bool
+	}{{
+		title:    "network backend",
+		builder:  NewRoute("api").Path("/api/*").Method("GET").Filter("setPath", "/v2/${1}").BackendURL("https://api.example.org"),
+		expected: `api: Path("/api/*") && Method("GET") -> setPath("/v2/${1}") -> "https://api.example.org"`,
+	}, {
+		title: "custom predicates and numeric args",
+		builder: NewRoute("").
+			Header("X-Foo", "foo").
+			HeaderRegexp("Accept", "json").
+			Predicate("Traffic", .3).
+			Filter("status", 418).
+			Shunt(),
+		expected: `Header("X-Foo", "foo") && HeaderRegexp("Accept", /json/) && Traffic(.3) -> status(418) -> <shunt>`,
+	}, {
+		title:    "load balanced backend",
+		builder:  NewRoute("lb").PathSubtree("/").LB("roundRobin", "http://10.0.0.1", "http://10.0.0.2"),
+		expected: `lb: PathSubtree("/") -> <roundRobin, "http://10.0.0.1", "http://10.0.0.2">`,
+	}, {
+		title:    "loopback",
+		builder:  NewRoute("loop").Host("^www[.]example[.]org$").Loopback(),
+		expected: `loop: Host(/^www[.]example[.]org$/) -> <loopback>`,
+	}, {
+		title:   "invalid id",
+		builder: NewRoute("foo-bar").Shunt(),
+		fail:    true,
+	}, {
+		title:   "invalid filter name",
+		builder: NewRoute("foo").Filter("foo bar").Shunt(),
+		fail:    true,
+	}, {
+		title:   "invalid arg type",
+		builder: NewRoute("foo").Filter("foo", true).Shunt(),
+		fail:    true,
+	}, {
+		title:   "missing backend",
+		builder: NewRoute("foo").Path("/foo"),
+		fail:    true,
+	}, {
+		title:   "multiple backends",
+		builder: NewRoute("foo").Shunt().Dynamic(),
+		fail:    true,
+	}, {
+		title:   "invalid backend address",
+		builder: NewRoute("foo").BackendURL("/foo"),
+		fail:    true,
+	}, {
+		title:   "duplicate path",
+		builder: NewRoute("foo").Path("/foo").Path("/bar").Shunt(),
+		fail:    true,
+	}, {
+		title:   "missing lb endpoints",
+		builder: NewRoute("foo").LB("random"),
+		fail:    true,
+	}, {
+		title:   "mixed lb protocols",
+		builder: NewRoute("foo").LB("random", "http://10.0.0.1", "https://10.0.0.2"),
+		fail:    true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := test.builder.Build()
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := Parse(test.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !Eq(r, expected[0]) {
+				t.Error("invalid route", r.String())
+			}
+		})
+	}
+}
+
+func TestRouteBuilderReuse(t *testing.T) {
+	b := NewRoute("foo").Path("/foo").Shunt()
+	r1, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r1.Filters = append(r1.Filters, &Filter{Name: "bar"})
+	if !Eq(r2, &Route{Id: "foo", Path: "/foo", BackendType: ShuntBackend, Shunt: true}) {
+		t.Error("builds are not independent", r2.String())
+	}
+}
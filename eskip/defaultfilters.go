@@ -0,0 +1,143 @@
+package eskip
+
+import "strings"
+
+// NoDefaultFiltersName is the name of the marker filter that a route can
+// use to opt out from the default filters of the routing document.
+const NoDefaultFiltersName = "noDefaultFilters"
+
+type defaultFiltersDirective struct {
+	start, end               int
+	filtersStart, filtersEnd int
+}
+
+// finds the default filters directives in a routing document. A default
+// filters directive can stand in the place of a route definition, and
+// has the form of:
+//
+//	default filters: accessLog() -> securityHeaders();
+//
+// When the document cannot be scanned, it stops, and leaves it to the
+// parser to report the error.
+func findDefaultFilters(doc string) []defaultFiltersDirective {
+	var (
+		directives []defaultFiltersDirective
+		current    defaultFiltersDirective
+		matched    int
+	)
+
+	l := newLexer(doc)
+	atDefinitionStart := true
+	for {
+		pos := len(doc) - len(l.code)
+		t, err := l.next()
+		if err != nil && err != eof {
+			return directives
+		}
+
+		switch {
+		case err == eof || t.id == semicolon:
+			if matched == 3 {
+				current.filtersEnd = pos
+				current.end = len(doc) - len(l.code)
+				directives = append(directives, current)
+			}
+
+			if err == eof {
+				return directives
+			}
+
+			matched = 0
+			atDefinitionStart = true
+		case matched == 3:
+			// scanning the filters
+		case atDefinitionStart && t.id == symbol && t.val == "default":
+			current = defaultFiltersDirective{start: pos}
+			matched = 1
+			atDefinitionStart = false
+		case matched == 1 && t.id == symbol && t.val == "filters":
+			matched = 2
+		case matched == 2 && t.id == colon:
+			current.filtersStart = len(doc) - len(l.code)
+			matched = 3
+		default:
+			matched = 0
+			atDefinitionStart = false
+		}
+	}
+}
+
+// extracts the default filters from a routing document, and returns the
+// document without the directives
+func extractDefaultFilters(doc string) (string, []*Filter, error) {
+	// avoiding scanning the document twice when there are no directives
+	if !strings.Contains(doc, "default") {
+		return doc, nil, nil
+	}
+
+	var filters []*Filter
+	for _, d := range findDefaultFilters(doc) {
+		f, err := ParseFilters(doc[d.filtersStart:d.filtersEnd])
+		if err != nil {
+			return "", nil, newParseError(doc, d.start, "", "invalid default filters: "+err.Error())
+		}
+
+		filters = append(filters, f...)
+		doc = blankOut(doc, d.start, d.end)
+	}
+
+	return doc, filters, nil
+}
+
+func hasNoDefaultFilters(r *Route) bool {
+	for _, f := range r.Filters {
+		if f.Name == NoDefaultFiltersName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withoutNoDefaultFilters(f []*Filter) []*Filter {
+	var result []*Filter
+	for _, fi := range f {
+		if fi.Name != NoDefaultFiltersName {
+			result = append(result, fi)
+		}
+	}
+
+	return result
+}
+
+// prepends the default filters to the routes, except for those that
+// opted out with the noDefaultFilters() marker, which gets removed
+func applyDefaultFilters(routes []*Route, defaults []*Filter) {
+	for _, r := range routes {
+		if hasNoDefaultFilters(r) {
+			r.Filters = withoutNoDefaultFilters(r.Filters)
+			continue
+		}
+
+		if len(defaults) > 0 {
+			r.Filters = append(CopyFilters(defaults), r.Filters...)
+		}
+	}
+}
+
+// parses a routing document, applying its default filters directives to
+// the routes. Used for the documents loaded with ParseWithImports.
+func parseDocument(doc string) ([]*Route, error) {
+	doc, defaultFilters, err := extractDefaultFilters(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := Parse(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	applyDefaultFilters(routes, defaultFilters)
+	return routes, nil
+}
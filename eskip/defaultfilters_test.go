@@ -0,0 +1,117 @@
+package eskip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultFilters(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		doc      string
+		expected string
+		fail     bool
+	}{{
+		title:    "no directive",
+		doc:      `r1: * -> foo() -> <shunt>`,
+		expected: `r1: * -> foo() -> <shunt>`,
+	}, {
+		title: "directive applied to all routes",
+		doc: `
+			default filters: accessLog() -> setResponseHeader("X-Frame-Options", "DENY");
+			r1: * -> foo() -> <shunt>;
+			r2: Path("/bar") -> <shunt>`,
+		expected: `
+			r1: * -> accessLog() -> setResponseHeader("X-Frame-Options", "DENY") -> foo() -> <shunt>;
+			r2: Path("/bar") -> accessLog() -> setResponseHeader("X-Frame-Options", "DENY") -> <shunt>`,
+	}, {
+		title: "multiple directives, anywhere in the document",
+		doc: `
+			r1: * -> <shunt>;
+			default filters: foo();
+			r2: * -> <shunt>;
+			default filters: bar()`,
+		expected: `
+			r1: * -> foo() -> bar() -> <shunt>;
+			r2: * -> foo() -> bar() -> <shunt>`,
+	}, {
+		title: "opt out",
+		doc: `
+			default filters: foo();
+			r1: * -> bar() -> noDefaultFilters() -> <shunt>;
+			r2: * -> <shunt>`,
+		expected: `
+			r1: * -> bar() -> <shunt>;
+			r2: * -> foo() -> <shunt>`,
+	}, {
+		title:    "route named default",
+		doc:      `default filters: foo(); default: * -> <shunt>`,
+		expected: `default: * -> foo() -> <shunt>`,
+	}, {
+		title: "invalid filters",
+		doc:   `default filters: foo() -> ; r1: * -> <shunt>`,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := parseDocument(test.doc)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := Parse(test.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !EqLists(r, expected) {
+				t.Error("invalid routes", String(r...))
+			}
+		})
+	}
+}
+
+func TestDefaultFiltersErrorPosition(t *testing.T) {
+	_, err := parseDocument("default filters: foo();\nr1: * -> -> <shunt>")
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("failed to return parse error", err)
+	}
+
+	if perr.Line != 2 || !strings.Contains(perr.Snippet, "r1") {
+		t.Error("invalid error position", perr)
+	}
+}
+
+func TestDefaultFiltersNotInParse(t *testing.T) {
+	if _, err := Parse(`default filters: foo(); r1: * -> <shunt>`); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestDefaultFiltersWithImports(t *testing.T) {
+	r, err := ParseWithImports("main.eskip", mapLoader(map[string]string{
+		"main.eskip":  `import "other.eskip"; default filters: foo(); r1: * -> <shunt>`,
+		"other.eskip": `default filters: bar(); r2: * -> <shunt>`,
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Parse(`r2: * -> bar() -> <shunt>; r1: * -> foo() -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !EqLists(r, expected) {
+		t.Error("invalid routes", String(r...))
+	}
+}
@@ -115,6 +115,22 @@ responsibility of the implementation to validate them.
 (See the documentation of the routing package.)
 
 
+Traffic splitting
+
+Splitting the traffic between routes, e.g. for canary deployments, is
+supported by the Traffic predicate, that matches a route with the given
+probability, optionally sticky with the help of a cookie:
+
+	// hit by 10% chance
+	v2: Traffic(.1) -> "https://api-green.example.org";
+
+	// hit by the remaining chance
+	v1: * -> "https://api-blue.example.org";
+
+The parser handles Traffic as any other custom predicate, the implementation
+is in the predicates/traffic package.
+
+
 Filters
 
 Filters are used to augment the incoming requests and the outgoing
@@ -186,7 +202,8 @@ predicates start with upper case, while filters start with lower case.
 
 Backend
 
-There are four backend types: network endpoint address, shunt, loopback and dynamic.
+There are five backend types: network endpoint address, shunt, loopback, dynamic
+and load balanced.
 
 Network endpoint address:
 
@@ -223,12 +240,22 @@ dynamic:
 The dynamic backend means that a filter must be present in the filter chain which
 must set the target url explicitly.
 
+load balanced:
+
+	<roundRobin, "http://10.2.0.1:9090", "http://10.2.0.2:9090">
+
+The load balanced backend contains a list of network endpoint addresses, and
+the requests are distributed between them with the algorithm specified as the
+first, optional, element of the list. The available algorithms are roundRobin,
+random, consistentHash and leastConnections, and when not set, roundRobin is
+used. All the endpoints need to use the same protocol scheme.
+
 
 Comments
 
-An eskip document can contain comments. The rule for comments is simple:
-everything is a comment that starts with '//' and ends with a new-line
-character.
+An eskip document can contain comments. Line comments start with '//'
+and end with a new-line character. Block comments are enclosed in the
+C style block comment markers, and can span multiple lines.
 
 Example with comments:
 
@@ -236,6 +263,44 @@ Example with comments:
 	route1: Path("/api") -> "https://api.example.org";
 	route2: * -> <shunt> // everything else 404
 
+The Parse function ignores the comments. The ParseWithComments function
+stores them in the Comments field of the route that they precede or are
+inside of, and the Print and String functions print them before the
+route, when printing a routing document. This way, a commented routing
+document can be modified and written back without losing the comments,
+though the comments inside a route are moved before it.
+
+
+Imports
+
+When parsing with eskip.ParseFile or eskip.ParseWithImports, a routing
+document can include other documents with import directives, in place of
+route definitions:
+
+	import "common/redirects.eskip";
+	api: Path("/api") -> "https://api.example.org";
+
+The relative paths are resolved relative to the importing document.
+Circular imports are reported as errors.
+
+
+Default filters
+
+A routing document can define filters that are prepended to the filter
+chain of every route in the same document, with default filters
+directives in place of route definitions:
+
+	default filters: accessLog() -> setResponseHeader("X-Frame-Options", "DENY");
+	api: Path("/api") -> "https://api.example.org";
+	health: Path("/health") -> noDefaultFilters() -> status(200) -> <shunt>;
+
+A route can opt out from the default filters with the noDefaultFilters()
+marker, which is removed from the route during parsing. The directives
+are applied only by eskip.ParseFile and eskip.ParseWithImports, used by
+the eskip file clients. Parse, ParseWithComments, ParseCollect and Fmt
+handle only route definitions, and report the directives as syntax
+errors.
+
 
 Regular expressions
 
@@ -254,19 +319,57 @@ without the matcher and backend part of a full route expression.
 Parsing
 
 Parsing a routing table or a route expression happens with the
-eskip.Parse function. In case of grammar error, it returns an error with
-the approximate position of the invalid syntax element, otherwise it
-returns a list of structured, in-memory route definitions.
+eskip.Parse function. In case of grammar error, it returns a *ParseError
+with the line and column of the invalid syntax element, the last valid
+token and the source line around it, otherwise it returns a list of
+structured, in-memory route definitions.
+
+The eskip.ParseCollect function doesn't stop at the first invalid route
+definition of a routing document. It returns the valid routes, and all
+the errors of the invalid route definitions.
 
 The eskip parser does not validate the routes against all semantic rules,
 e.g., whether a filter or a custom predicate implementation is available.
 This validation happens during processing the parsed definitions.
 
 
+Building routes
+
+Instead of parsing, routes can be created programmatically with the
+RouteBuilder, started by eskip.NewRoute. The builder validates the route
+with the same rules as the parser, when calling its Build method:
+
+	r, err := eskip.NewRoute("api").
+		Path("/api").
+		Filter("setRequestHeader", "X-Version", "v2").
+		BackendURL("https://api.example.org").
+		Build()
+
+
 Serializing
 
 Serializing a single route happens by calling its String method.
 Serializing a complete routing table happens by calling the
 eskip.String method.
+
+The routes can be serialized to JSON and YAML, too, e.g. to exchange them
+with tools that don't understand eskip. The Route, Filter and Predicate
+types implement the encoding/json and the gopkg.in/yaml.v2 marshaling
+interfaces. In the serialized form, every predicate, including Path or
+Method, is listed among the predicates with its name and arguments:
+
+	{
+	  "id": "route1",
+	  "backend": "https://www.example.org",
+	  "predicates": [{"name": "Path", "args": ["/foo"]}],
+	  "filters": [{"name": "setRequestHeader", "args": ["X-Foo", "bar"]}]
+	}
+
+The load balanced backends are stored in the lbAlgorithm and lbEndpoints
+fields, while their backend field is empty. (Earlier versions put the
+eskip form of the load balanced backend, e.g. <roundRobin, "http://10.0.0.1">,
+into the backend field. The JSON of the other routes didn't change.)
+When unmarshaling, the routes are checked the same way as when
+parsing eskip.
 */
 package eskip
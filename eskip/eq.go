@@ -9,10 +9,15 @@ func compareRouteID(r []*Route) func(int, int) bool {
 	}
 }
 
-// used for sorting:
+// used for sorting, the predicates with the same name, e.g. multiple
+// Header predicates, are ordered by their arguments:
 func comparePredicateName(p []*Predicate) func(int, int) bool {
 	return func(i, j int) bool {
-		return p[i].Name < p[j].Name
+		if p[i].Name != p[j].Name {
+			return p[i].Name < p[j].Name
+		}
+
+		return argsString(p[i].Args) < argsString(p[j].Args)
 	}
 }
 
@@ -171,6 +176,40 @@ func EqLists(r ...[]*Route) bool {
 	return true
 }
 
+// Diff compares two sets of routes by their IDs, and returns the routes
+// that need to be inserted or updated, and the IDs of the routes that
+// need to be deleted, in order to turn the old set into the new one. The
+// routes are compared with Eq(), so the differences in the formatting,
+// in the legacy representation of the predicates and the backends, or in
+// the order of the predicates don't count as changes. The upserts keep
+// the order of the new routes, and the deletes the order of the old ones.
+//
+func Diff(old, new []*Route) (upserts []*Route, deletes []string) {
+	oldByID := make(map[string]*Route)
+	for _, r := range old {
+		oldByID[r.Id] = r
+	}
+
+	newIDs := make(map[string]bool)
+	for _, r := range new {
+		newIDs[r.Id] = true
+		if current, ok := oldByID[r.Id]; !ok || !eq2(current, r) {
+			upserts = append(upserts, r)
+		}
+	}
+
+	for _, r := range old {
+		if !newIDs[r.Id] {
+			deletes = append(deletes, r.Id)
+
+			// avoid deleting duplicate IDs twice
+			newIDs[r.Id] = true
+		}
+	}
+
+	return
+}
+
 // Canonical returns the canonical representation of a route, that uses the
 // standard, non-legacy representation of the predicates and the backends.
 // Canonical creates a copy of the route, but doesn't necessarily creates a
@@ -243,7 +282,7 @@ func Canonical(r *Route) *Route {
 		c.Predicates = nil
 	}
 
-	sort.Slice(c.Predicates, comparePredicateName(c.Predicates))
+	sort.SliceStable(c.Predicates, comparePredicateName(c.Predicates))
 	c.Filters = r.Filters
 
 	c.BackendType = r.BackendType
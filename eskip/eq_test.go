@@ -319,3 +319,64 @@ func TestCanonicalList(t *testing.T) {
 		})
 	}
 }
+
+func TestDiff(t *testing.T) {
+	mustParse := func(doc string) []*Route {
+		r, err := Parse(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	for _, test := range []struct {
+		title           string
+		old, new        string
+		expectedUpserts []string
+		expectedDeletes []string
+	}{{
+		title: "empty",
+	}, {
+		title:           "all new",
+		new:             `r1: * -> <shunt>; r2: * -> <shunt>`,
+		expectedUpserts: []string{"r1", "r2"},
+	}, {
+		title:           "all deleted",
+		old:             `r1: * -> <shunt>; r2: * -> <shunt>`,
+		expectedDeletes: []string{"r1", "r2"},
+	}, {
+		title: "formatting and predicate order ignored",
+		old: `r1: Header("X-Foo", "foo") && Header("X-Bar", "bar") && Path("/foo") && Traffic(.3)
+			-> setPath("/bar") -> <roundRobin, "http://10.0.0.1", "http://10.0.0.2">`,
+		new: `r1: Traffic(0.3) && Path("/foo") && Header("X-Bar", "bar") && Header("X-Foo", "foo") -> setPath("/bar")
+			-> <roundRobin, "http://10.0.0.2", "http://10.0.0.1">`,
+	}, {
+		title: "mixed",
+		old: `r1: Path("/foo") -> "https://www.example.org";
+			r2: Path("/bar") -> "https://www.example.org";
+			r3: Path("/baz") -> "https://www.example.org"`,
+		new: `r4: Path("/qux") -> "https://www.example.org";
+			r3: Path("/baz") -> "https://www.example.org";
+			r1: Path("/foo") -> setPath("/") -> "https://www.example.org"`,
+		expectedUpserts: []string{"r4", "r1"},
+		expectedDeletes: []string{"r2"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			upserts, deletes := Diff(mustParse(test.old), mustParse(test.new))
+
+			var upsertIDs []string
+			for _, r := range upserts {
+				upsertIDs = append(upsertIDs, r.Id)
+			}
+
+			if !reflect.DeepEqual(upsertIDs, test.expectedUpserts) {
+				t.Error("invalid upserts", upsertIDs, test.expectedUpserts)
+			}
+
+			if !reflect.DeepEqual(deletes, test.expectedDeletes) {
+				t.Error("invalid deletes", deletes, test.expectedDeletes)
+			}
+		})
+	}
+}
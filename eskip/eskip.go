@@ -183,6 +183,13 @@ type Route struct {
 
 	// Namespace is deprecated and not used.
 	Namespace string
+
+	// Comments contains the comments found before or inside the route
+	// expression, when the route was parsed with ParseWithComments.
+	// They are printed before the route by Print and String, when
+	// printing a routing document. The comments contain the comment
+	// markers, e.g. "// route to the API".
+	Comments []string
 }
 
 type RoutePredicate func(*Route) bool
@@ -261,6 +268,11 @@ func (r *Route) Copy() *Route {
 		copy(c.LBEndpoints, r.LBEndpoints)
 	}
 
+	if len(r.Comments) > 0 {
+		c.Comments = make([]string, len(r.Comments))
+		copy(c.Comments, r.Comments)
+	}
+
 	return &c
 }
 
@@ -445,6 +457,14 @@ func parse(code string) ([]*parsedRoute, error) {
 	return l.routes, l.err
 }
 
+// executes the parser, and collects the comments, too.
+func parseWithComments(code string) ([]*parsedRoute, []*comment, error) {
+	l := newLexer(code)
+	l.keepComments = true
+	eskipParse(l)
+	return l.routes, l.comments, l.err
+}
+
 func partialRouteToRoute(format, p string) string {
 	p = strings.TrimSpace(p)
 	if p == "" {
@@ -471,6 +491,37 @@ func Parse(code string) ([]*Route, error) {
 		return nil, err
 	}
 
+	return newRouteDefinitions(parsedRoutes)
+}
+
+// ParseWithComments parses a route expression or a routing document like
+// Parse, and stores the comments in the routes. The comments are stored
+// in the route that they precede or are inside of. The comments after the
+// last route are stored in the last route.
+func ParseWithComments(code string) ([]*Route, error) {
+	parsedRoutes, comments, err := parseWithComments(code)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := newRouteDefinitions(parsedRoutes)
+	if err != nil || len(routes) == 0 {
+		return routes, err
+	}
+
+	for _, c := range comments {
+		i := c.routeIndex
+		if i >= len(routes) {
+			i = len(routes) - 1
+		}
+
+		routes[i].Comments = append(routes[i].Comments, c.text)
+	}
+
+	return routes, nil
+}
+
+func newRouteDefinitions(parsedRoutes []*parsedRoute) ([]*Route, error) {
 	routeDefinitions := make([]*Route, len(parsedRoutes))
 	for i, r := range parsedRoutes {
 		rd, err := newRouteDefinition(r)
@@ -113,6 +113,16 @@ func TestParseRouteExpression(t *testing.T) {
 		"route: Any() -> <shunt>; // some comment",
 		&Route{Id: "route", BackendType: ShuntBackend, Shunt: true},
 		false,
+	}, {
+		"block comment",
+		"route: /* some\ncomment */ Any() -> /**/ <shunt>",
+		&Route{Id: "route", BackendType: ShuntBackend, Shunt: true},
+		false,
+	}, {
+		"unterminated block comment",
+		"route: Any() -> <shunt> /* some comment",
+		nil,
+		true,
 	}, {
 		"catch all",
 		`* -> "https://www.example.org"`,
@@ -601,3 +611,53 @@ r2: Method("POST") -> inlineContent("r2") -> <shunt>;
 		}
 	}
 }
+
+func TestParseWithComments(t *testing.T) {
+	doc := `// first route
+		route1: Path("/foo") /* inline */ -> <shunt>;
+		;
+
+		/*
+		 * second route
+		 */
+		route2: Path("/bar")
+			// on the backend
+			-> "https://www.example.org"; // trailing
+		// commented out: route3: * -> <shunt>;`
+
+	routes, err := ParseWithComments(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatal("failed to parse routes")
+	}
+
+	expected := [][]string{{
+		"// first route",
+		"/* inline */",
+	}, {
+		"/*\n\t\t * second route\n\t\t */",
+		"// on the backend",
+		"// trailing",
+		"// commented out: route3: * -> <shunt>;",
+	}}
+
+	for i, r := range routes {
+		if !reflect.DeepEqual(r.Comments, expected[i]) {
+			t.Errorf("invalid comments of %s: %q", r.Id, r.Comments)
+		}
+	}
+
+	plain, err := Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range plain {
+		if len(r.Comments) != 0 {
+			t.Error("unexpected comments", r.Comments)
+		}
+	}
+}
@@ -101,6 +101,27 @@ func ExampleFilter() {
 	// filter arg 1: 3.14
 }
 
+func ExamplePredicate() {
+	code := `
+		Cookie("canary", "true") && Traffic(.25) -> "https://canary.example.org"`
+
+	routes, err := eskip.Parse(code)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	fmt.Println("Parsed a route with custom predicates:")
+	for _, p := range routes[0].Predicates {
+		fmt.Printf("%s%v\n", p.Name, p.Args)
+	}
+
+	// output:
+	// Parsed a route with custom predicates:
+	// Cookie[canary true]
+	// Traffic[0.25]
+}
+
 func ExampleNetworkBackend() {
 	code := `
 		ajaxRouteV3: PathRegexp(/^\/api\/v3\/.*/) -> ajaxHeader("v3") -> "https://api.example.org"`
@@ -185,6 +206,32 @@ func ExampleShuntBackend() {
 	// backend address: ""
 }
 
+func ExampleLBBackend() {
+	code := `
+		api: Path("/api") -> <roundRobin, "http://10.2.0.1:9090", "http://10.2.0.2:9090">`
+
+	routes, err := eskip.Parse(code)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	r := routes[0]
+
+	fmt.Println("Parsed a route:")
+	fmt.Printf("id: %v\n", r.Id)
+	fmt.Printf("backend type: %v\n", r.BackendType)
+	fmt.Printf("algorithm: %v\n", r.LBAlgorithm)
+	fmt.Printf("endpoints: %v\n", r.LBEndpoints)
+
+	// output:
+	// Parsed a route:
+	// id: api
+	// backend type: lb
+	// algorithm: roundRobin
+	// endpoints: [http://10.2.0.1:9090 http://10.2.0.2:9090]
+}
+
 func ExampleParse() {
 	code := `
 		PathRegexp(/\.html$/) && Header("Accept", "text/html") ->
@@ -226,3 +273,21 @@ func ExampleParseFilters() {
 	// second filter, first arg: 3.14
 	// second filter, second arg: Hello, world!
 }
+
+func ExampleRouteBuilder() {
+	r, err := eskip.NewRoute("api").
+		Path("/api").
+		Method("GET").
+		Filter("setRequestHeader", "X-Version", "v2").
+		BackendURL("https://api.example.org").
+		Build()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	fmt.Println(eskip.String(r))
+
+	// output:
+	// api: Path("/api") && Method("GET") -> setRequestHeader("X-Version", "v2") -> "https://api.example.org";
+}
@@ -0,0 +1,188 @@
+package eskip
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ImportLoader loads the content of a routing document by its name. The
+// name is the one that was passed in to ParseWithImports, or the path
+// of an imported document, resolved relative to the importing one.
+type ImportLoader func(name string) (string, error)
+
+type importedDocument struct {
+	name  string
+	start int
+	end   int
+}
+
+func loadFile(name string) (string, error) {
+	b, err := ioutil.ReadFile(name)
+	return string(b), err
+}
+
+// replaces a part of the document with whitespace, keeping the line
+// breaks, so that the parse errors report the original positions
+func blankOut(doc string, start, end int) string {
+	blank := strings.Map(func(r rune) rune {
+		if r == newlineChar {
+			return r
+		}
+
+		return ' '
+	}, doc[start:end])
+
+	return doc[:start] + blank + doc[end:]
+}
+
+// finds the import directives in a routing document. An import directive
+// can stand in the place of a route definition, and has the form of:
+//
+//	import "other.eskip";
+//
+// When the document cannot be scanned, it stops, and leaves it to the
+// parser to report the error.
+func findImports(doc string) []importedDocument {
+	var (
+		imports []importedDocument
+		tokens  []token
+		start   int
+	)
+
+	l := newLexer(doc)
+	atDefinitionStart := true
+	for {
+		pos := len(doc) - len(l.code)
+		t, err := l.next()
+		if err != nil && err != eof {
+			return imports
+		}
+
+		switch {
+		case err == eof || t.id == semicolon:
+			if len(tokens) == 2 {
+				end := len(doc) - len(l.code)
+				imports = append(imports, importedDocument{name: tokens[1].val, start: start, end: end})
+			}
+
+			if err == eof {
+				return imports
+			}
+
+			tokens = nil
+			atDefinitionStart = true
+		case atDefinitionStart && t.id == symbol && t.val == "import":
+			tokens = []token{t}
+			start = pos
+			atDefinitionStart = false
+		case len(tokens) == 1 && t.id == stringliteral:
+			tokens = append(tokens, t)
+		default:
+			tokens = nil
+			atDefinitionStart = false
+		}
+	}
+}
+
+func isURL(name string) bool {
+	u, err := url.Parse(name)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func cleanName(name string) string {
+	if isURL(name) {
+		return name
+	}
+
+	return filepath.Clean(name)
+}
+
+// resolves the imported names as file paths, or, when the importing
+// document was loaded from a URL, as URL references
+func resolveImport(importing, name string) string {
+	if isURL(name) {
+		return name
+	}
+
+	if isURL(importing) {
+		base, _ := url.Parse(importing)
+		ref, err := url.Parse(name)
+		if err != nil {
+			return name
+		}
+
+		return base.ResolveReference(ref).String()
+	}
+
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+
+	return filepath.Join(filepath.Dir(importing), name)
+}
+
+func parseImports(name string, load ImportLoader, stack []string, loaded map[string]bool) ([]*Route, error) {
+	for i, s := range stack {
+		if s == name {
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(append(stack[i:], name), " -> "))
+		}
+	}
+
+	if loaded[name] {
+		return nil, nil
+	}
+
+	loaded[name] = true
+	stack = append(stack, name)
+
+	doc, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*Route
+	imports := findImports(doc)
+	for _, imp := range imports {
+		doc = blankOut(doc, imp.start, imp.end)
+
+		imported, err := parseImports(resolveImport(name, imp.name), load, stack, loaded)
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, imported...)
+	}
+
+	own, err := parseDocument(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return append(routes, own...), nil
+}
+
+// ParseWithImports loads and parses a routing document, that may contain
+// import directives in place of route definitions:
+//
+//	import "common/redirects.eskip";
+//
+// The imported documents are loaded with the provided loader, with their
+// names resolved relative to the importing document, as file paths, or as
+// URL references when the name of the importing document is a URL. They
+// can contain import directives, too. A document imported multiple times is included
+// only once, while circular imports are reported as an error. The routes
+// of the imported documents precede the routes of the importing one in
+// the returned list. The default filters directives of each document are
+// applied to the routes of the same document.
+func ParseWithImports(name string, load ImportLoader) ([]*Route, error) {
+	return parseImports(cleanName(name), load, nil, make(map[string]bool))
+}
+
+// ParseFile loads and parses a routing document from a file, resolving
+// the import directives in it from the file system. See ParseWithImports.
+func ParseFile(name string) ([]*Route, error) {
+	return ParseWithImports(name, loadFile)
+}
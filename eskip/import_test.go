@@ -0,0 +1,157 @@
+package eskip
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mapLoader(docs map[string]string) ImportLoader {
+	return func(name string) (string, error) {
+		doc, ok := docs[name]
+		if !ok {
+			return "", errors.New("not found: " + name)
+		}
+
+		return doc, nil
+	}
+}
+
+func routeIDs(r []*Route) string {
+	var ids []string
+	for _, ri := range r {
+		ids = append(ids, ri.Id)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func TestParseWithImports(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		docs     map[string]string
+		expected string
+		fail     string
+	}{{
+		title:    "no imports",
+		docs:     map[string]string{"main.eskip": `r1: * -> <shunt>`},
+		expected: "r1",
+	}, {
+		title: "relative and nested imports",
+		docs: map[string]string{
+			"main.eskip": `
+				r1: * -> <shunt>;
+				// a comment
+				import "common/redirects.eskip";
+				r2: * -> <shunt>`,
+			"common/redirects.eskip": `import "../lb/api.eskip"; r3: * -> <shunt>`,
+			"lb/api.eskip":           `r4: * -> <shunt>;`,
+		},
+		expected: "r4,r3,r1,r2",
+	}, {
+		title: "route named import",
+		docs: map[string]string{
+			"main.eskip":  `import: * -> <shunt>; import "other.eskip"`,
+			"other.eskip": `r1: * -> <shunt>`,
+		},
+		expected: "r1,import",
+	}, {
+		title: "diamond imported once",
+		docs: map[string]string{
+			"main.eskip":   `import "a.eskip"; import "b.eskip"`,
+			"a.eskip":      `import "common.eskip"; a: * -> <shunt>`,
+			"b.eskip":      `import "common.eskip"; b: * -> <shunt>`,
+			"common.eskip": `common: * -> <shunt>`,
+		},
+		expected: "common,a,b",
+	}, {
+		title: "cycle",
+		docs: map[string]string{
+			"main.eskip": `import "a.eskip"`,
+			"a.eskip":    `import "b.eskip"`,
+			"b.eskip":    `import "a.eskip"`,
+		},
+		fail: "import cycle: a.eskip -> b.eskip -> a.eskip",
+	}, {
+		title: "missing import",
+		docs:  map[string]string{"main.eskip": `import "missing.eskip"`},
+		fail:  "not found: missing.eskip",
+	}, {
+		title: "parse error in imported document",
+		docs: map[string]string{
+			"main.eskip": `import "a.eskip"`,
+			"a.eskip":    "r1: * -> <shunt>;\nr2: * -> -> <shunt>",
+		},
+		fail: "a.eskip: parse failed after token ->, position 29, line 2, column 12",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			routes, err := ParseWithImports("main.eskip", mapLoader(test.docs))
+			if test.fail != "" {
+				if err == nil || !strings.Contains(err.Error(), test.fail) {
+					t.Error("failed to fail with the right error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ids := routeIDs(routes); ids != test.expected {
+				t.Error("invalid routes", ids, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eskip-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "common"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, doc := range map[string]string{
+		"main.eskip":       `import "common/api.eskip"; main: * -> <shunt>`,
+		"common/api.eskip": `api: Path("/api") -> "https://api.example.org"`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routes, err := ParseFile(filepath.Join(dir, "main.eskip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "api,main" {
+		t.Error("invalid routes", ids)
+	}
+}
+
+func TestParseWithImportsURL(t *testing.T) {
+	routes, err := ParseWithImports("https://routes.example.org/main.eskip", mapLoader(map[string]string{
+		"https://routes.example.org/main.eskip":       `import "common/api.eskip"; main: * -> <shunt>`,
+		"https://routes.example.org/common/api.eskip": `import "/lb.eskip"; import "../redirects.eskip"; api: * -> <shunt>`,
+		"https://routes.example.org/lb.eskip":         `lb: * -> <shunt>`,
+		"https://routes.example.org/redirects.eskip":  `redirects: * -> <shunt>`,
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := routeIDs(routes); ids != "lb,redirects,api,main" {
+		t.Error("invalid routes", ids)
+	}
+}
@@ -3,8 +3,27 @@ package eskip
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 )
 
+var errInvalidLBBackend = errors.New("invalid load balanced backend: missing endpoints")
+
+// jsonNameArgs is the serialized form of the filters and the predicates.
+type jsonNameArgs struct {
+	Name string        `json:"name" yaml:"name"`
+	Args []interface{} `json:"args" yaml:"args"`
+}
+
+// jsonRoute is the serialized form of the routes.
+type jsonRoute struct {
+	Id          string       `json:"id" yaml:"id"`
+	Backend     string       `json:"backend" yaml:"backend"`
+	LBAlgorithm string       `json:"lbAlgorithm,omitempty" yaml:"lbAlgorithm,omitempty"`
+	LBEndpoints []string     `json:"lbEndpoints,omitempty" yaml:"lbEndpoints,omitempty"`
+	Predicates  []*Predicate `json:"predicates" yaml:"predicates"`
+	Filters     []*Filter    `json:"filters" yaml:"filters"`
+}
+
 func marshalJsonPredicates(r *Route) []*Predicate {
 	rjf := make([]*Predicate, 0, len(r.Predicates))
 
@@ -36,15 +55,15 @@ func marshalJsonPredicates(r *Route) []*Predicate {
 		})
 	}
 
-	for k, v := range r.Headers {
+	for _, k := range SortedKeys(r.Headers) {
 		rjf = append(rjf, &Predicate{
 			Name: "Header",
-			Args: []interface{}{k, v},
+			Args: []interface{}{k, r.Headers[k]},
 		})
 	}
 
-	for k, list := range r.HeaderRegexps {
-		for _, v := range list {
+	for _, k := range SortedKeys(r.HeaderRegexps) {
+		for _, v := range r.HeaderRegexps[k] {
 			rjf = append(rjf, &Predicate{
 				Name: "HeaderRegexp",
 				Args: []interface{}{k, v},
@@ -62,13 +81,7 @@ func marshalNameArgs(name string, args []interface{}) ([]byte, error) {
 		args = []interface{}{}
 	}
 
-	return json.Marshal(&struct {
-		Name string        `json:"name"`
-		Args []interface{} `json:"args"`
-	}{
-		Name: name,
-		Args: args,
-	})
+	return json.Marshal(&jsonNameArgs{Name: name, Args: args})
 }
 
 func (f *Filter) MarshalJSON() ([]byte, error) {
@@ -79,31 +92,120 @@ func (p *Predicate) MarshalJSON() ([]byte, error) {
 	return marshalNameArgs(p.Name, p.Args)
 }
 
-func (r *Route) MarshalJSON() ([]byte, error) {
-	backend := r.backendString()
-
+func toJSONRoute(r *Route) *jsonRoute {
 	filters := r.Filters
 	if filters == nil {
 		filters = []*Filter{}
 	}
 
+	jr := &jsonRoute{
+		Id:         r.Id,
+		Backend:    r.backendString(),
+		Predicates: marshalJsonPredicates(r),
+		Filters:    filters,
+	}
+
+	if r.BackendType == LBBackend {
+		jr.Backend = ""
+		jr.LBAlgorithm = r.LBAlgorithm
+		jr.LBEndpoints = r.LBEndpoints
+	}
+
+	return jr
+}
+
+// MarshalJSON serializes a route into JSON. All the predicates, including
+// the ones stored in the dedicated fields like Path or Method, are
+// listed under the predicates key. The special backends are represented
+// by their eskip form, e.g. "<shunt>", while the load balanced backends
+// are represented by the lbAlgorithm and the lbEndpoints keys, with an
+// empty backend key. Earlier versions put the eskip form of the load
+// balanced backends in the backend key.
+func (r *Route) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e := json.NewEncoder(&buf)
 	e.SetEscapeHTML(false)
 
-	if err := e.Encode(&struct {
-		Id         string       `json:"id"`
-		Backend    string       `json:"backend"`
-		Predicates []*Predicate `json:"predicates"`
-		Filters    []*Filter    `json:"filters"`
-	}{
-		Id:         r.Id,
-		Backend:    backend,
-		Predicates: marshalJsonPredicates(r),
-		Filters:    filters,
-	}); err != nil {
+	if err := e.Encode(toJSONRoute(r)); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
+
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var na jsonNameArgs
+	if err := json.Unmarshal(b, &na); err != nil {
+		return err
+	}
+
+	f.Name, f.Args = na.Name, na.Args
+	return nil
+}
+
+func (p *Predicate) UnmarshalJSON(b []byte) error {
+	var na jsonNameArgs
+	if err := json.Unmarshal(b, &na); err != nil {
+		return err
+	}
+
+	p.Name, p.Args = na.Name, na.Args
+	return nil
+}
+
+// converts the serialized form back to a route, the same way as the
+// parser does it, so that the predicates with dedicated fields and the
+// backend are checked and stored the same way
+func fromJSONRoute(jr *jsonRoute) (*Route, error) {
+	pr := &parsedRoute{
+		id:      jr.Id,
+		filters: jr.Filters,
+	}
+
+	for _, p := range jr.Predicates {
+		name := p.Name
+		if name == "HostRegexp" {
+			name = "Host"
+		}
+
+		pr.matchers = append(pr.matchers, &matcher{name: name, args: p.Args})
+	}
+
+	switch {
+	case jr.LBAlgorithm != "" || len(jr.LBEndpoints) > 0:
+		if len(jr.LBEndpoints) == 0 {
+			return nil, errInvalidLBBackend
+		}
+
+		pr.lbBackend = true
+		pr.lbAlgorithm = jr.LBAlgorithm
+		pr.lbEndpoints = jr.LBEndpoints
+	case jr.Backend == "<shunt>":
+		pr.shunt = true
+	case jr.Backend == "<loopback>":
+		pr.loopback = true
+	case jr.Backend == "<dynamic>":
+		pr.dynamic = true
+	default:
+		pr.backend = jr.Backend
+	}
+
+	return newRouteDefinition(pr)
+}
+
+// UnmarshalJSON parses a route from the JSON format produced by
+// MarshalJSON.
+func (r *Route) UnmarshalJSON(b []byte) error {
+	var jr jsonRoute
+	if err := json.Unmarshal(b, &jr); err != nil {
+		return err
+	}
+
+	rd, err := fromJSONRoute(&jr)
+	if err != nil {
+		return err
+	}
+
+	*r = *rd
+	return nil
+}
@@ -0,0 +1,143 @@
+package eskip
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const serializationTestDoc = `
+	route1: Method("GET") && Path("/foo") && Host(/^www[.]example[.]org$/) && Header("X-Foo", "bar")
+		-> setRequestHeader("X-Bar", "baz") -> ratelimit(20, "1m")
+		-> "https://backend.example.org";
+	route2: PathRegexp(/^\/api/) && HeaderRegexp("Accept", /json/) && Traffic(.3) -> <shunt>;
+	route3: * -> <loopback>;
+	route4: * -> <dynamic>;
+	route5: Path("/lb") -> <roundRobin, "http://10.0.0.1:8080", "http://10.0.0.2:8080">;
+`
+
+func testSerializationRoundTrip(t *testing.T, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) {
+	routes, err := Parse(serializationTestDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := marshal(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []*Route
+	if err := unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if !EqLists(routes, result) {
+		t.Error("failed to restore the routes")
+		t.Log(String(routes...))
+		t.Log(String(result...))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	testSerializationRoundTrip(t, json.Marshal, json.Unmarshal)
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	testSerializationRoundTrip(t, yaml.Marshal, yaml.Unmarshal)
+}
+
+func TestLBBackendJSON(t *testing.T) {
+	r := &Route{BackendType: LBBackend, LBAlgorithm: "random", LBEndpoints: []string{"http://10.0.0.1"}}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id":"","backend":"","lbAlgorithm":"random","lbEndpoints":["http://10.0.0.1"],"predicates":[],"filters":[]}`
+	if string(b) != expected {
+		t.Errorf("invalid output: %s", b)
+	}
+}
+
+// the JSON of the routes without load balanced backends is the same as
+// before the lbAlgorithm and lbEndpoints keys were introduced
+func TestJSONFormat(t *testing.T) {
+	routes, err := Parse(`
+		route1: Method("GET") && Path("/foo") && Header("X-Foo", "bar")
+			&& HeaderRegexp("X-Baz", /qux/) && HeaderRegexp("Accept", /json/)
+			-> setRequestHeader("X-Bar", "baz") -> "https://backend.example.org";
+		route2: * -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	b := bytes.TrimSpace(buf.Bytes())
+	expected := `[` +
+		`{"id":"route1","backend":"https://backend.example.org","predicates":[` +
+		`{"name":"Method","args":["GET"]},` +
+		`{"name":"Path","args":["/foo"]},` +
+		`{"name":"Header","args":["X-Foo","bar"]},` +
+		`{"name":"HeaderRegexp","args":["Accept","json"]},` +
+		`{"name":"HeaderRegexp","args":["X-Baz","qux"]}],` +
+		`"filters":[{"name":"setRequestHeader","args":["X-Bar","baz"]}]},` +
+		`{"id":"route2","backend":"<shunt>","predicates":[],"filters":[]}]`
+
+	if string(b) != expected {
+		t.Errorf("invalid output: %s", b)
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"predicates": 42}`,
+		`{"predicates": [{"name": "Path", "args": [42]}]}`,
+		`{"predicates": [{"name": "Method", "args": ["GET"]}, {"name": "Method", "args": ["PUT"]}]}`,
+		`{"lbAlgorithm": "roundRobin"}`,
+		`{"lbEndpoints": ["http://10.0.0.1", "https://10.0.0.2"]}`,
+	} {
+		var r Route
+		if err := json.Unmarshal([]byte(doc), &r); err == nil {
+			t.Error("failed to fail", doc)
+		}
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	doc := `
+- id: route1
+  backend: https://www.example.org
+  predicates:
+  - name: Path
+    args: [/foo]
+  - name: Weight
+    args: [3]
+  filters:
+  - name: status
+    args: [418]
+`
+
+	var routes []*Route
+	if err := yaml.Unmarshal([]byte(doc), &routes); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Parse(`route1: Path("/foo") && Weight(3) -> status(418) -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !EqLists(routes, expected) {
+		t.Error("failed to unmarshal the routes", String(routes...))
+	}
+}
@@ -2,7 +2,6 @@ package eskip
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"unicode"
 )
@@ -28,6 +27,23 @@ type eskipLex struct {
 	err           error
 	initialLength int
 	routes        []*parsedRoute
+
+	// the whole document, and the offset of the lexed code in it,
+	// used for the position of the parse errors
+	doc    string
+	offset int
+
+	// when set, the comments are collected together with the
+	// index of the route that they precede or are inside of
+	keepComments bool
+	comments     []*comment
+	routeIndex   int
+	routeStarted bool
+}
+
+type comment struct {
+	text       string
+	routeIndex int
 }
 
 type fixedScanner string
@@ -95,9 +111,16 @@ func (fs fixedScanner) scan(code string) (t token, rest string, err error) {
 }
 
 func newLexer(code string) *eskipLex {
+	return newLexerAt(code, 0, len(code))
+}
+
+// creates a lexer for a part of a document
+func newLexerAt(doc string, start, end int) *eskipLex {
 	return &eskipLex{
-		code:          code,
-		initialLength: len(code)}
+		code:          doc[start:end],
+		initialLength: end - start,
+		doc:           doc,
+		offset:        start}
 }
 
 func isWhitespace(c byte) bool  { return unicode.IsSpace(rune(c)) }
@@ -232,10 +255,14 @@ func scanRegexpOrComment(code string) (t token, rest string, err error) {
 		return
 	}
 
-	if code[1] == '/' {
-		rest = scanComment(code)
+	switch code[1] {
+	case '/':
+		t.val, rest = scanLineComment(code)
 		err = void
 		return
+	case '*':
+		t.val, rest, err = scanBlockComment(code)
+		return
 	}
 
 	t, rest, err = scanRegexpLiteral(code)
@@ -256,9 +283,21 @@ func scanStringLiteral(delimiter byte, code string) (t token, rest string, err e
 }
 
 func scanWhitespace(code string) string { return scanVoid(code, isWhitespace) }
-func scanComment(code string) string {
-	return scanVoid(code, func(c byte) bool { return !isNewline(c) })
+func scanLineComment(code string) (string, string) {
+	b, rest := scanWhile(code, func(c byte) bool { return !isNewline(c) })
+	return strings.TrimRight(string(b), " \t\r"), rest
+}
+
+func scanBlockComment(code string) (string, string, error) {
+	end := strings.Index(code[2:], "*/")
+	if end < 0 {
+		return "", code, incompleteToken
+	}
+
+	end += 4
+	return code[:end], code[end:], void
 }
+
 func scanDoubleQuote(code string) (token, string, error) { return scanStringLiteral('"', code) }
 func scanBacktick(code string) (token, string, error)    { return scanStringLiteral('`', code) }
 
@@ -351,18 +390,44 @@ func (l *eskipLex) next() (t token, err error) {
 		return
 	}
 
-	t, l.code, err = s.scan(l.code)
+	var rest string
+	t, rest, err = s.scan(l.code)
 	if err == void {
+		l.code = rest
+		l.addComment(t.val)
 		return l.next()
 	}
 
+	// in case of an error, the position is kept at the start of the
+	// invalid token
 	if err == nil {
+		l.code = rest
 		l.lastToken = &t
+		l.trackRoutes(t)
 	}
 
 	return
 }
 
+func (l *eskipLex) addComment(text string) {
+	if l.keepComments && text != "" {
+		l.comments = append(l.comments, &comment{text: text, routeIndex: l.routeIndex})
+	}
+}
+
+// counts the routes by the semicolons, ignoring the empty ones
+func (l *eskipLex) trackRoutes(t token) {
+	if t.id != semicolon {
+		l.routeStarted = true
+		return
+	}
+
+	if l.routeStarted {
+		l.routeIndex++
+		l.routeStarted = false
+	}
+}
+
 func (l *eskipLex) Lex(lval *eskipSymType) int {
 	token, err := l.next()
 	if err == eof {
@@ -378,8 +443,14 @@ func (l *eskipLex) Lex(lval *eskipSymType) int {
 	return token.id
 }
 
+// Stores the error, either from the lexer or from the parser. When the
+// lexer fails, the parser reports a syntax error afterwards, which
+// overrides the error of the lexer, as before the positional errors.
 func (l *eskipLex) Error(err string) {
-	l.err = fmt.Errorf(
-		"parse failed after token %v, position %d: %s",
-		l.lastToken, l.initialLength-len(l.code), err)
+	var token string
+	if l.lastToken != nil {
+		token = l.lastToken.val
+	}
+
+	l.err = newParseError(l.doc, l.offset+l.initialLength-len(l.code), token, err)
 }
@@ -0,0 +1,182 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// the maximum length of the source snippet in the parse errors
+const maxSnippetLength = 80
+
+// ParseError is returned when a route expression or a routing document
+// cannot be parsed. It contains the position of the invalid syntax
+// element.
+type ParseError struct {
+
+	// The byte offset in the parsed document, where the parsing
+	// failed.
+	Offset int
+
+	// The line and the column of the position where the parsing
+	// failed, starting from 1. The column is counted in bytes.
+	Line   int
+	Column int
+
+	// The last token that was scanned successfully before the
+	// failure, if any.
+	Token string
+
+	// The line of the document where the parsing failed, shortened
+	// around the position of the failure when it's too long.
+	Snippet string
+
+	// The reason of the failure.
+	Message string
+}
+
+// ParseErrors is returned by ParseCollect, containing the parse errors
+// of all the invalid route definitions in a routing document.
+type ParseErrors []*ParseError
+
+func (e *ParseError) Error() string {
+	var after string
+	if e.Token != "" {
+		after = fmt.Sprintf(" after token %s", e.Token)
+	}
+
+	msg := fmt.Sprintf(
+		"parse failed%s, position %d, line %d, column %d: %s",
+		after, e.Offset, e.Line, e.Column, e.Message,
+	)
+
+	if e.Snippet != "" {
+		msg += fmt.Sprintf(", near: %q", e.Snippet)
+	}
+
+	return msg
+}
+
+func (e ParseErrors) Error() string {
+	s := make([]string, len(e))
+	for i, ei := range e {
+		s[i] = ei.Error()
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// creates a parse error for the offset of the document, calculating the
+// line, the column and the source snippet
+func newParseError(doc string, offset int, token, message string) *ParseError {
+	if offset > len(doc) {
+		offset = len(doc)
+	}
+
+	lineStart := strings.LastIndexByte(doc[:offset], newlineChar) + 1
+	lineEnd := strings.IndexByte(doc[offset:], newlineChar)
+	if lineEnd < 0 {
+		lineEnd = len(doc)
+	} else {
+		lineEnd += offset
+	}
+
+	snippetStart, snippetEnd := lineStart, lineEnd
+	if snippetEnd-snippetStart > maxSnippetLength {
+		snippetStart = offset - maxSnippetLength/2
+		if snippetStart < lineStart {
+			snippetStart = lineStart
+		}
+
+		snippetEnd = snippetStart + maxSnippetLength
+		if snippetEnd > lineEnd {
+			snippetEnd = lineEnd
+		}
+	}
+
+	return &ParseError{
+		Offset:  offset,
+		Line:    strings.Count(doc[:offset], "\n") + 1,
+		Column:  offset - lineStart + 1,
+		Token:   token,
+		Snippet: strings.TrimSpace(doc[snippetStart:snippetEnd]),
+		Message: message,
+	}
+}
+
+// Splits a routing document to the parts containing a single route
+// definition, at the semicolons. When the lexer fails, it continues after
+// the next semicolon character.
+func splitDefinitions(doc string) [][2]int {
+	var parts [][2]int
+	l := newLexer(doc)
+	start := 0
+	for {
+		t, err := l.next()
+		if err == eof {
+			break
+		}
+
+		pos := len(doc) - len(l.code)
+		if err != nil {
+			next := strings.IndexByte(l.code, ';')
+			if next < 0 {
+				break
+			}
+
+			l.code = l.code[next+1:]
+			parts = append(parts, [2]int{start, pos + next + 1})
+			start = pos + next + 1
+			continue
+		}
+
+		if t.id == semicolon {
+			parts = append(parts, [2]int{start, pos})
+			start = pos
+		}
+	}
+
+	if start < len(doc) {
+		parts = append(parts, [2]int{start, len(doc)})
+	}
+
+	return parts
+}
+
+// ParseCollect parses a routing document like Parse, but it doesn't stop
+// at the first invalid route definition. It returns the valid routes, and
+// when there were invalid route definitions, a ParseErrors error
+// containing the positions of all of them.
+func ParseCollect(code string) ([]*Route, error) {
+	var (
+		routes []*Route
+		errs   ParseErrors
+	)
+
+	for _, p := range splitDefinitions(code) {
+		l := newLexerAt(code, p[0], p[1])
+		eskipParse(l)
+		if perr, ok := l.err.(*ParseError); ok {
+			errs = append(errs, perr)
+			continue
+		}
+
+		for _, r := range l.routes {
+			rd, err := newRouteDefinition(r)
+			if err != nil {
+				part := code[p[0]:p[1]]
+				offset := p[0] + len(part) - len(strings.TrimLeftFunc(part, unicode.IsSpace))
+				errs = append(errs, newParseError(code, offset, r.id, err.Error()))
+				continue
+			}
+
+			routes = append(routes, rd)
+		}
+	}
+
+	if len(errs) > 0 {
+		return routes, errs
+	}
+
+	return routes, nil
+}
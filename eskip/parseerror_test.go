@@ -0,0 +1,90 @@
+package eskip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseErrorPosition(t *testing.T) {
+	doc := `route1: Path("/foo") -> <shunt>;
+route2: Path("/bar") -> -> <shunt>;`
+
+	_, err := Parse(doc)
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("failed to return parse error", err)
+	}
+
+	if perr.Line != 2 || perr.Column != 27 || perr.Offset != 59 {
+		t.Error("invalid position", perr.Line, perr.Column, perr.Offset)
+	}
+
+	if perr.Token != "->" {
+		t.Error("invalid token", perr.Token)
+	}
+
+	if perr.Snippet != `route2: Path("/bar") -> -> <shunt>;` {
+		t.Error("invalid snippet", perr.Snippet)
+	}
+
+	if !strings.Contains(perr.Error(), "line 2, column 27") {
+		t.Error("invalid error message", perr.Error())
+	}
+}
+
+func TestParseErrorLongLine(t *testing.T) {
+	doc := `Path("/foo") -> setPath("` + strings.Repeat("x", 200) + `") -> -> <shunt>`
+	_, err := Parse(doc)
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("failed to return parse error", err)
+	}
+
+	if len(perr.Snippet) > maxSnippetLength || !strings.HasSuffix(perr.Snippet, "-> -> <shunt>") {
+		t.Error("invalid snippet", perr.Snippet)
+	}
+}
+
+func TestParseCollect(t *testing.T) {
+	doc := `route1: Path("/foo") -> <shunt>;
+		route2: Path("/bar") -> -> <shunt>;
+		route3: Path("/baz") -> "https://www.example.org";
+		route4: Path("/qux") && Path("/quux") -> <shunt>;
+		route5: Method("GET") -> "https://www.example.org";
+		route6: Path("/unterminated) -> <shunt>;
+		route7: * -> <shunt>`
+
+	routes, err := ParseCollect(doc)
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatal("failed to return parse errors", err)
+	}
+
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+
+	if strings.Join(ids, ",") != "route1,route3,route5,route7" {
+		t.Error("failed to parse the valid routes", ids)
+	}
+
+	if len(errs) != 3 {
+		t.Fatal("failed to collect the errors", errs)
+	}
+
+	for i, line := range []int{2, 4, 6} {
+		if errs[i].Line != line {
+			t.Error("invalid line", errs[i].Line, line)
+		}
+	}
+
+	if errs[1].Token != "route4" {
+		t.Error("invalid token for semantic error", errs[1].Token)
+	}
+
+	routes, err = ParseCollect(`route1: * -> <shunt>; route2: * -> <shunt>`)
+	if err != nil || len(routes) != 2 {
+		t.Error("failed to parse valid document", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -66,6 +68,23 @@ func argsString(args []interface{}) string {
 	return strings.Join(sargs, ", ")
 }
 
+// SortedKeys returns the keys of a map with string keys, e.g. the
+// Headers or the HeaderRegexps of a route, in sorted order, to iterate
+// over the map in a stable order. It panics when the argument is not a
+// map with string keys.
+func SortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// the header predicates are printed in the order of the header names,
+// to make the output stable
 func (r *Route) predicateString() string {
 	var predicates []string
 
@@ -85,12 +104,12 @@ func (r *Route) predicateString() string {
 		predicates = appendFmtEscape(predicates, `Method("%s")`, `"`, r.Method)
 	}
 
-	for k, v := range r.Headers {
-		predicates = appendFmtEscape(predicates, `Header("%s", "%s")`, `"`, k, v)
+	for _, k := range SortedKeys(r.Headers) {
+		predicates = appendFmtEscape(predicates, `Header("%s", "%s")`, `"`, k, r.Headers[k])
 	}
 
-	for k, rxs := range r.HeaderRegexps {
-		for _, rx := range rxs {
+	for _, k := range SortedKeys(r.HeaderRegexps) {
+		for _, rx := range r.HeaderRegexps[k] {
 			predicates = appendFmt(predicates, `HeaderRegexp("%s", /%s/)`, escape(k, `"`), escape(rx, "/"))
 		}
 	}
@@ -193,15 +212,44 @@ func Print(pretty PrettyPrintInfo, routes ...*Route) string {
 	return buf.String()
 }
 
+// Fmt formats a route expression or a routing document in the canonical
+// form: the routes are sorted by their ids, the filters and the backend
+// are printed on separate lines, indented with two spaces, and the
+// predicates are printed in a stable order. The comments are preserved,
+// as described at ParseWithComments. Formatting the output again
+// doesn't change it, so it can be used to keep the routing documents
+// stored e.g. in git readable and easy to diff.
+func Fmt(doc string) (string, error) {
+	routes, err := ParseWithComments(doc)
+	if err != nil {
+		return "", err
+	}
+
+	if len(routes) == 0 {
+		return "", nil
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Id < routes[j].Id })
+	return Print(PrettyPrintInfo{Pretty: true, IndentStr: "  "}, routes...) + "\n", nil
+}
+
 func isDefinition(route *Route) bool {
 	return route.Id != ""
 }
 
+func fprintComments(w io.Writer, route *Route) {
+	for _, c := range route.Comments {
+		fmt.Fprintln(w, c)
+	}
+}
+
 func fprintExpression(w io.Writer, route *Route, prettyPrintInfo PrettyPrintInfo) {
+	fprintComments(w, route)
 	fmt.Fprint(w, route.Print(prettyPrintInfo))
 }
 
 func fprintDefinition(w io.Writer, route *Route, prettyPrintInfo PrettyPrintInfo) {
+	fprintComments(w, route)
 	fmt.Fprintf(w, "%s: %s", route.Id, route.Print(prettyPrintInfo))
 }
 
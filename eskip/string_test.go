@@ -3,6 +3,7 @@ package eskip
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -213,6 +214,85 @@ func TestParseAndStringAndParse(t *testing.T) {
 	_ = testDoc(t, doc)
 }
 
+func TestStringWithComments(t *testing.T) {
+	doc := "// the first route\n" +
+		`route1: Method("GET") -> <shunt>;` + "\n" +
+		"/* the second route */\n" +
+		`route2: Path("/some/path") -> "https://www.example.org";`
+
+	routes, err := ParseWithComments(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := String(routes...); s != doc {
+		t.Error("failed to print comments", s)
+	}
+
+	if s := routes[0].String(); s != `Method("GET") -> <shunt>` {
+		t.Error("unexpected comments in route expression", s)
+	}
+}
+
+func TestStableHeaderOrder(t *testing.T) {
+	r := &Route{
+		Headers: map[string]string{"X-C": "c", "X-A": "a", "X-B": "b"},
+		HeaderRegexps: map[string][]string{
+			"X-Z": {"z"},
+			"X-Y": {"y1", "y2"},
+		},
+		Shunt: true,
+	}
+
+	expected := `Header("X-A", "a") && Header("X-B", "b") && Header("X-C", "c") && ` +
+		`HeaderRegexp("X-Y", /y1/) && HeaderRegexp("X-Y", /y2/) && HeaderRegexp("X-Z", /z/) -> <shunt>`
+	for i := 0; i < 10; i++ {
+		if s := r.String(); s != expected {
+			t.Fatal("unstable header order", s)
+		}
+	}
+}
+
+func TestFmt(t *testing.T) {
+	doc := `// the second route
+		route2: Path("/bar") -> setPath("/") -> /* the backend */ "https://www.example.org";
+		route1: Method("GET") -> status(404) -> inlineContent("not found") -> <shunt>;`
+
+	expected := `route1: Method("GET")
+  -> status(404)
+  -> inlineContent("not found")
+  -> <shunt>;
+
+// the second route
+/* the backend */
+route2: Path("/bar")
+  -> setPath("/")
+  -> "https://www.example.org";
+`
+
+	formatted, err := Fmt(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if formatted != expected {
+		t.Error("failed to format document", formatted)
+	}
+
+	again, err := Fmt(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != formatted {
+		t.Error("formatting is not idempotent", again)
+	}
+
+	if _, err := Fmt("invalid"); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
 func TestNumberString(t *testing.T) {
 	for _, ti := range []float64{
 		0,
@@ -481,3 +561,28 @@ testRoute3: Path("/baz")
 		})
 	})
 }
+
+func TestSortedKeys(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		m        interface{}
+		expected string
+	}{{
+		title: "nil map",
+		m:     map[string]string(nil),
+	}, {
+		title:    "headers",
+		m:        map[string]string{"X-B": "b", "X-C": "c", "X-A": "a"},
+		expected: "X-A,X-B,X-C",
+	}, {
+		title:    "header regexps",
+		m:        map[string][]string{"X-B": {"b"}, "X-A": {"a", "aa"}},
+		expected: "X-A,X-B",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if keys := strings.Join(SortedKeys(test.m), ","); keys != test.expected {
+				t.Errorf("invalid keys, expected: %s, got: %s", test.expected, keys)
+			}
+		})
+	}
+}
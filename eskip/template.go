@@ -5,15 +5,25 @@
 package eskip
 
 import (
+	"net"
+	"net/http"
 	"regexp"
 	"strings"
 )
 
-var parameterRegexp = regexp.MustCompile(`\$\{(\w+)\}`)
+var parameterRegexp = regexp.MustCompile(`\$\{([^{}]+)\}`)
 
 // TemplateGetter functions return the value for a template parameter name.
 type TemplateGetter func(string) string
 
+// TemplateContext provides the values for the placeholders in
+// ApplyContext. The filters.FilterContext implements it.
+type TemplateContext interface {
+	PathParam(string) string
+	Request() *http.Request
+	Response() *http.Response
+}
+
 // Template represents a string template with named placeholders.
 type Template struct {
 	template     string
@@ -25,6 +35,26 @@ type Template struct {
 //
 // 	Hello, ${who}!
 //
+// When applied with ApplyContext, the placeholders can refer to the path
+// parameters of the route by name, or to the attributes of the request
+// and the response:
+//
+// 	${request.method}
+// 	${request.host}
+// 	${request.path}
+// 	${request.rawQuery}
+// 	${request.source}
+// 	${request.header.<name>}
+// 	${request.query.<name>}
+// 	${request.cookie.<name>}
+// 	${response.header.<name>}
+//
+// The request source is the remote address of the connection, which is the
+// address of the last proxy when skipper runs behind a load balancer. The
+// X-Forwarded-For header is not taken into account, because the clients
+// can set it to any value. When the load balancer is trusted, the header
+// is available as ${request.header.X-Forwarded-For}.
+//
 func NewTemplate(template string) *Template {
 	matches := parameterRegexp.FindAllStringSubmatch(template, -1)
 	placeholders := make([]string, len(matches))
@@ -51,3 +81,75 @@ func (t *Template) Apply(get TemplateGetter) string {
 
 	return result
 }
+
+// ApplyContext evaluates the template using the path parameters, the
+// request and the response of the context to resolve the placeholders.
+// The second return value is false when one or more placeholders could
+// not be resolved, or resolved to an empty value.
+func (t *Template) ApplyContext(ctx TemplateContext) (string, bool) {
+	resolved := true
+	result := t.Apply(func(placeholder string) string {
+		v := contextValue(ctx, placeholder)
+		if v == "" {
+			resolved = false
+		}
+
+		return v
+	})
+
+	return result, resolved
+}
+
+func requestSource(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func requestValue(r *http.Request, name string) string {
+	switch name {
+	case "method":
+		return r.Method
+	case "host":
+		return r.Host
+	case "path":
+		return r.URL.Path
+	case "rawQuery":
+		return r.URL.RawQuery
+	case "source":
+		return requestSource(r)
+	}
+
+	switch {
+	case strings.HasPrefix(name, "header."):
+		return r.Header.Get(name[len("header."):])
+	case strings.HasPrefix(name, "query."):
+		return r.URL.Query().Get(name[len("query."):])
+	case strings.HasPrefix(name, "cookie."):
+		if c, err := r.Cookie(name[len("cookie."):]); err == nil {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+func contextValue(ctx TemplateContext, placeholder string) string {
+	switch {
+	case strings.HasPrefix(placeholder, "request."):
+		if r := ctx.Request(); r != nil {
+			return requestValue(r, placeholder[len("request."):])
+		}
+	case strings.HasPrefix(placeholder, "response.header."):
+		if r := ctx.Response(); r != nil {
+			return r.Header.Get(placeholder[len("response.header."):])
+		}
+	default:
+		return ctx.PathParam(placeholder)
+	}
+
+	return ""
+}
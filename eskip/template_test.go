@@ -1,6 +1,9 @@
 package eskip
 
-import "testing"
+import (
+	"net/http"
+	"testing"
+)
 
 type createTestItem struct {
 	template string
@@ -57,3 +60,57 @@ func TestTemplateGetter(t *testing.T) {
 		nil,
 	}})
 }
+
+type testTemplateContext struct {
+	pathParams map[string]string
+	request    *http.Request
+	response   *http.Response
+}
+
+func (c *testTemplateContext) PathParam(name string) string { return c.pathParams[name] }
+func (c *testTemplateContext) Request() *http.Request       { return c.request }
+func (c *testTemplateContext) Response() *http.Response     { return c.response }
+
+func TestTemplateApplyContext(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://www.example.org/foo?bar=baz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.RemoteAddr = "192.168.0.1:9090"
+	req.Header.Set("X-Foo", "foo")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "qux"})
+
+	ctx := &testTemplateContext{
+		pathParams: map[string]string{"id": "42"},
+		request:    req,
+		response:   &http.Response{Header: http.Header{"X-Bar": []string{"bar"}}},
+	}
+
+	for _, ti := range []struct {
+		template string
+		expected string
+		ok       bool
+	}{
+		{"/items/${id}", "/items/42", true},
+		{"${request.method} ${request.host}${request.path}?${request.rawQuery}", "GET www.example.org/foo?bar=baz", true},
+		{"${request.source}", "192.168.0.1", true},
+		{"${request.header.X-Foo}", "foo", true},
+		{"${request.query.bar}", "baz", true},
+		{"${request.cookie.session}", "qux", true},
+		{"${response.header.X-Bar}", "bar", true},
+		{"${missing}", "", false},
+		{"foo-${request.header.X-Missing}", "foo-", false},
+		{"${request.unknown}", "", false},
+	} {
+		result, ok := NewTemplate(ti.template).ApplyContext(ctx)
+		if result != ti.expected || ok != ti.ok {
+			t.Error(ti.template, result, ok)
+		}
+	}
+
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	if result, _ := NewTemplate("${request.source}").ApplyContext(ctx); result != "192.168.0.1" {
+		t.Error("failed to ignore X-Forwarded-For in the source", result)
+	}
+}
@@ -0,0 +1,87 @@
+package eskip
+
+// normalizes the numeric arguments decoded from YAML to float64, the same
+// as the parser and the JSON decoder produce them
+func yamlArgs(args []interface{}) []interface{} {
+	for i, a := range args {
+		switch v := a.(type) {
+		case int:
+			args[i] = float64(v)
+		case int64:
+			args[i] = float64(v)
+		case uint64:
+			args[i] = float64(v)
+		}
+	}
+
+	return args
+}
+
+func unmarshalYAMLNameArgs(unmarshal func(interface{}) error) (*jsonNameArgs, error) {
+	var na jsonNameArgs
+	if err := unmarshal(&na); err != nil {
+		return nil, err
+	}
+
+	na.Args = yamlArgs(na.Args)
+	return &na, nil
+}
+
+func marshalYAMLNameArgs(name string, args []interface{}) *jsonNameArgs {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	return &jsonNameArgs{Name: name, Args: args}
+}
+
+func (f *Filter) MarshalYAML() (interface{}, error) {
+	return marshalYAMLNameArgs(f.Name, f.Args), nil
+}
+
+func (p *Predicate) MarshalYAML() (interface{}, error) {
+	return marshalYAMLNameArgs(p.Name, p.Args), nil
+}
+
+func (f *Filter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	na, err := unmarshalYAMLNameArgs(unmarshal)
+	if err != nil {
+		return err
+	}
+
+	f.Name, f.Args = na.Name, na.Args
+	return nil
+}
+
+func (p *Predicate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	na, err := unmarshalYAMLNameArgs(unmarshal)
+	if err != nil {
+		return err
+	}
+
+	p.Name, p.Args = na.Name, na.Args
+	return nil
+}
+
+// MarshalYAML serializes a route into YAML, using the same structure as
+// MarshalJSON. It is used by gopkg.in/yaml.v2.
+func (r *Route) MarshalYAML() (interface{}, error) {
+	return toJSONRoute(r), nil
+}
+
+// UnmarshalYAML parses a route from the YAML format produced by
+// MarshalYAML. It is used by gopkg.in/yaml.v2.
+func (r *Route) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var jr jsonRoute
+	if err := unmarshal(&jr); err != nil {
+		return err
+	}
+
+	rd, err := fromJSONRoute(&jr)
+	if err != nil {
+		return err
+	}
+
+	*r = *rd
+	return nil
+}
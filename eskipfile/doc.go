@@ -7,5 +7,10 @@ format in the skipper/eskip package.)
 
 The package provides two implementations: one without file watch (legacy version) and one with file watch. When
 running the skipper command, the one with watch is used.
+
+In addition, the package provides a client that polls an eskip document from a remote HTTP(S) URL, using
+conditional requests based on the ETag and Last-Modified response headers.
+
+All the clients resolve the import directives in the documents, see eskip.ParseWithImports.
 */
 package eskipfile
@@ -0,0 +1,132 @@
+package eskipfile
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// the events in the directory of a watched file that may mean that the
+// file changed. The directory is watched instead of the file, to detect
+// when the file is written, or replaced by renaming or by swapping a
+// symlink, as with Kubernetes ConfigMap volumes. The creation of a file
+// is not signaled, because it would trigger loading the file before its
+// content was written. The files and symlinks created in place are
+// detected by the polling.
+const dirEvents = unix.IN_CLOSE_WRITE |
+	unix.IN_DELETE |
+	unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO |
+	unix.IN_ATTRIB
+
+// fileEvents signals when something changed in the directories of the
+// watched files, using inotify. The signal only triggers a check, the
+// files are parsed again only when their version changed.
+type fileEvents struct {
+	fd     int
+	file   *os.File
+	notify chan<- struct{}
+	mx     sync.Mutex
+	dirs   map[string]int
+	closed bool
+}
+
+func newFileEvents(notify chan<- struct{}) (*fileEvents, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &fileEvents{
+		fd: fd,
+
+		// the non-blocking file is handled by the runtime poller, and
+		// closing it stops the pending reads
+		file: os.NewFile(uintptr(fd), "inotify"),
+
+		notify: notify,
+		dirs:   make(map[string]int),
+	}
+
+	go e.receive()
+	return e, nil
+}
+
+func (e *fileEvents) receive() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		if _, err := e.file.Read(buf); err != nil {
+			e.mx.Lock()
+			closed := e.closed
+			e.mx.Unlock()
+			if !closed {
+				log.Errorf("Error while receiving file events, falling back to polling: %v.", err)
+			}
+
+			return
+		}
+
+		select {
+		case e.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch sets the directories of the provided files as the watched ones.
+// Adding a watch again for the same directory is a no-op for inotify, and
+// it restores the watch when the directory was deleted and created again.
+func (e *fileEvents) watch(names []string) {
+	if e == nil {
+		return
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if e.closed {
+		return
+	}
+
+	dirs := make(map[string]int)
+	for _, n := range names {
+		d := filepath.Dir(n)
+		if _, ok := dirs[d]; ok {
+			continue
+		}
+
+		wd, err := unix.InotifyAddWatch(e.fd, d, dirEvents)
+		if err != nil {
+			// the directory may not exist yet, the polling
+			// detects it, and it is watched on the next load
+			continue
+		}
+
+		dirs[d] = wd
+	}
+
+	for d, wd := range e.dirs {
+		if _, ok := dirs[d]; !ok {
+			unix.InotifyRmWatch(e.fd, uint32(wd))
+		}
+	}
+
+	e.dirs = dirs
+}
+
+func (e *fileEvents) close() {
+	if e == nil {
+		return
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if e.closed {
+		return
+	}
+
+	e.closed = true
+	e.file.Close()
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package eskipfile
+
+// fileEvents is not supported on this platform, the watched files are
+// only polled.
+type fileEvents struct{}
+
+func newFileEvents(chan<- struct{}) (*fileEvents, error) { return nil, nil }
+func (*fileEvents) watch([]string)                         {}
+func (*fileEvents) close()                                 {}
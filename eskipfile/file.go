@@ -1,8 +1,6 @@
 package eskipfile
 
 import (
-	"io/ioutil"
-
 	"github.com/zalando/skipper/eskip"
 )
 
@@ -11,14 +9,10 @@ import (
 type Client struct{ routes []*eskip.Route }
 
 // Opens an eskip file and parses it, returning a DataClient implementation. If reading or parsing the file
-// fails, returns an error. This implementation doesn't provide file watch.
+// fails, returns an error. The import directives in the file are resolved relative to its location, see
+// eskip.ParseFile. This implementation doesn't provide file watch.
 func Open(path string) (*Client, error) {
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	routes, err := eskip.Parse(string(content))
+	routes, err := eskip.ParseFile(path)
 	if err != nil {
 		return nil, err
 	}
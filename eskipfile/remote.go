@@ -0,0 +1,167 @@
+package eskipfile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const defaultRemoteTimeout = 10 * time.Second
+
+// RemoteOptions contains the options for the remote eskip document client.
+type RemoteOptions struct {
+
+	// URL of the eskip document. Required.
+	URL string
+
+	// Timeout of a single request. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// Optional custom HTTP client, e.g. for custom TLS settings.
+	Client *http.Client
+}
+
+// RemoteClient implements a route configuration client, that loads an eskip document from a remote HTTP(S)
+// URL. Use the Remote function to create instances of it. The import directives in the document are resolved
+// as URL references relative to the importing document, see eskip.ParseWithImports.
+//
+// Every LoadUpdate call fetches the document and the imported documents again, sending the ETag and the
+// Last-Modified values of the previous responses in conditional request headers, and returns the difference
+// to the previous version of the routes when any of the documents changed. The frequency of the updates is
+// controlled by the poll timeout of the routing.
+type RemoteClient struct {
+	url       string
+	client    *http.Client
+	documents map[string]remoteDocument
+	routes    map[string]*eskip.Route
+}
+
+// the last fetched version of a document
+type remoteDocument struct {
+	etag         string
+	lastModified string
+	content      string
+}
+
+var errMissingURL = errors.New("missing URL of the eskip document")
+
+// Remote creates a client loading the eskip document from a remote URL.
+func Remote(o RemoteOptions) (*RemoteClient, error) {
+	if o.URL == "" {
+		return nil, errMissingURL
+	}
+
+	client := o.Client
+	if client == nil {
+		timeout := o.Timeout
+		if timeout <= 0 {
+			timeout = defaultRemoteTimeout
+		}
+
+		client = &http.Client{Timeout: timeout}
+	}
+
+	return &RemoteClient{url: o.URL, client: client}, nil
+}
+
+// fetches a document, and returns the previous version when it was not
+// modified
+func (c *RemoteClient) fetch(url string, conditional bool) (remoteDocument, bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return remoteDocument{}, false, err
+	}
+
+	previous, cached := c.documents[url]
+	if conditional && cached {
+		if previous.etag != "" {
+			req.Header.Set("If-None-Match", previous.etag)
+		}
+
+		if previous.lastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.lastModified)
+		}
+	}
+
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return remoteDocument{}, false, err
+	}
+
+	defer rsp.Body.Close()
+
+	if conditional && cached && rsp.StatusCode == http.StatusNotModified {
+		return previous, false, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return remoteDocument{}, false, fmt.Errorf("failed to fetch eskip document from %s: %s", url, rsp.Status)
+	}
+
+	content, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return remoteDocument{}, false, err
+	}
+
+	return remoteDocument{
+		etag:         rsp.Header.Get("ETag"),
+		lastModified: rsp.Header.Get("Last-Modified"),
+		content:      string(content),
+	}, true, nil
+}
+
+// fetches and parses the document with the imported documents, and tells
+// whether any of them was modified
+func (c *RemoteClient) load(conditional bool) ([]*eskip.Route, bool, error) {
+	var modified bool
+	documents := make(map[string]remoteDocument)
+	r, err := eskip.ParseWithImports(c.url, func(url string) (string, error) {
+		d, m, err := c.fetch(url, conditional)
+		if err != nil {
+			return "", err
+		}
+
+		documents[url] = d
+		modified = modified || m
+		return d.content, nil
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.documents = documents
+	return r, modified, nil
+}
+
+// LoadAll returns the parsed route definitions found in the remote document.
+func (c *RemoteClient) LoadAll() ([]*eskip.Route, error) {
+	r, _, err := c.load(false)
+	if err != nil {
+		return nil, err
+	}
+
+	c.routes = mapRoutes(r)
+	return cloneRoutes(r), nil
+}
+
+// LoadUpdate returns differential updates when the remote document, or one of the imported documents, has
+// changed.
+func (c *RemoteClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	r, modified, err := c.load(true)
+	if err != nil || !modified {
+		return nil, nil, err
+	}
+
+	var (
+		upsert     []*eskip.Route
+		deletedIDs []string
+	)
+
+	c.routes, upsert, deletedIDs = diffRoutes(c.routes, r)
+	return cloneRoutes(upsert), deletedIDs, nil
+}
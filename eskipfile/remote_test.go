@@ -0,0 +1,157 @@
+package eskipfile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteMissingURL(t *testing.T) {
+	if _, err := Remote(RemoteOptions{}); err != errMissingURL {
+		t.Error("failed to fail")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	var (
+		content     = testWatchFileContent
+		requests    int
+		conditional bool
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := fmt.Sprintf(`"%d"`, len(content))
+		if r.Header.Get("If-None-Match") == etag {
+			conditional = true
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer s.Close()
+
+	c, err := Remote(RemoteOptions{URL: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 3 {
+		t.Error("failed to load routes", len(routes))
+	}
+
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil || len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Fatal("unexpected update", routes, deletedIDs, err)
+	}
+
+	if !conditional {
+		t.Error("failed to make conditional request")
+	}
+
+	content = testWatchFileUpdatedContent
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "baz" || routes[0].Backend != "https://baz-new.example.org" {
+		t.Error("failed to receive upsert", routes)
+	}
+
+	if len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive delete", deletedIDs)
+	}
+
+	if requests != 3 {
+		t.Error("unexpected number of requests", requests)
+	}
+}
+
+func TestRemoteImport(t *testing.T) {
+	var (
+		imported = `bar: Path("/bar") -> "https://bar.example.org"`
+		requests = make(map[string]int)
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		var content string
+		switch r.URL.Path {
+		case "/routes/main.eskip":
+			content = `import "common/imported.eskip"; foo: Path("/foo") -> "https://foo.example.org"`
+		case "/routes/common/imported.eskip":
+			content = imported
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%d"`, len(content))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer s.Close()
+
+	c, err := Remote(RemoteOptions{URL: s.URL + "/routes/main.eskip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 {
+		t.Error("failed to load the imported routes", routes)
+	}
+
+	routes, deletedIDs, err := c.LoadUpdate()
+	if err != nil || len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Fatal("unexpected update", routes, deletedIDs, err)
+	}
+
+	imported = `baz: Path("/baz") -> "https://baz-new.example.org"`
+	routes, deletedIDs, err = c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "baz" || len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive the update of the imported document", routes, deletedIDs)
+	}
+
+	if requests["/routes/main.eskip"] != 3 || requests["/routes/common/imported.eskip"] != 3 {
+		t.Error("unexpected number of requests", requests)
+	}
+}
+
+func TestRemoteInvalidDocument(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testWatchFileInvalidContent))
+	}))
+	defer s.Close()
+
+	c, err := Remote(RemoteOptions{URL: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/eskip"
 )
@@ -14,26 +17,51 @@ type watchResponse struct {
 	err        error
 }
 
+// identifies the version of a file that was loaded last
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
 // WatchClient implements a route configuration client with file watching. Use the Watch function to initialize
 // instances of it.
 type WatchClient struct {
 	fileName   string
 	routes     map[string]*eskip.Route
+	versions   map[string]fileVersion
 	getAll     chan (chan<- watchResponse)
 	getUpdates chan (chan<- watchResponse)
 	quit       chan struct{}
+	notify     chan struct{}
+	events     *fileEvents
 }
 
 // Watch creates a route configuration client with file watching. Watch doesn't follow file system nodes, it
-// always reads from the file identified by the initially provided file name.
+// always reads from the file identified by the initially provided file name. The import directives in the file
+// are resolved relative to its location, see eskip.ParseFile. The files are read and parsed again only when the
+// modification time or size of the file, or of one of the imported files, changed since they were last loaded.
+//
+// On Linux, the directories of the file and of the imported files are watched with inotify, and the client
+// signals the routing on the channel returned by UpdateNotify when something changed in them, so that the
+// changes are applied without waiting for the next poll. On other platforms, or when inotify is not available,
+// the files are only polled.
 func Watch(name string) *WatchClient {
 	c := &WatchClient{
 		fileName:   name,
 		getAll:     make(chan (chan<- watchResponse)),
 		getUpdates: make(chan (chan<- watchResponse)),
 		quit:       make(chan struct{}),
+		notify:     make(chan struct{}, 1),
 	}
 
+	events, err := newFileEvents(c.notify)
+	if err != nil {
+		log.Errorf("Failed to watch the file events of %s, falling back to polling: %v.", name, err)
+	}
+
+	c.events = events
+	c.events.watch([]string{name})
+
 	go c.watch()
 	return c
 }
@@ -51,21 +79,25 @@ func (c *WatchClient) storeRoutes(r []*eskip.Route) {
 	c.routes = mapRoutes(r)
 }
 
-func (c *WatchClient) diffStoreRoutes(r []*eskip.Route) (upsert []*eskip.Route, deletedIDs []string) {
+func diffRoutes(current map[string]*eskip.Route, r []*eskip.Route) (next map[string]*eskip.Route, upsert []*eskip.Route, deletedIDs []string) {
 	for i := range r {
-		if !reflect.DeepEqual(r[i], c.routes[r[i].Id]) {
+		if !reflect.DeepEqual(r[i], current[r[i].Id]) {
 			upsert = append(upsert, r[i])
 		}
 	}
 
-	m := mapRoutes(r)
-	for id := range c.routes {
-		if _, keep := m[id]; !keep {
+	next = mapRoutes(r)
+	for id := range current {
+		if _, keep := next[id]; !keep {
 			deletedIDs = append(deletedIDs, id)
 		}
 	}
 
-	c.routes = m
+	return
+}
+
+func (c *WatchClient) diffStoreRoutes(r []*eskip.Route) (upsert []*eskip.Route, deletedIDs []string) {
+	c.routes, upsert, deletedIDs = diffRoutes(c.routes, r)
 	return
 }
 
@@ -92,25 +124,96 @@ func cloneRoutes(r []*eskip.Route) []*eskip.Route {
 	return c
 }
 
-func (c *WatchClient) loadAll() watchResponse {
-	content, err := ioutil.ReadFile(c.fileName)
+func (v fileVersion) equal(w fileVersion) bool {
+	return v.modTime.Equal(w.modTime) && v.size == w.size
+}
+
+func statVersion(name string) (fileVersion, error) {
+	fi, err := os.Stat(name)
 	if err != nil {
-		return watchResponse{err: err}
+		return fileVersion{}, err
+	}
+
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// the names of the main file and the imported files
+func (c *WatchClient) fileNames() []string {
+	names := []string{c.fileName}
+	for name := range c.versions {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (c *WatchClient) watchFiles() {
+	c.events.watch(c.fileNames())
+}
+
+// parses the file with the imported files, and returns the version of
+// each loaded file
+func (c *WatchClient) parse() ([]*eskip.Route, map[string]fileVersion, error) {
+	versions := make(map[string]fileVersion)
+	r, err := eskip.ParseWithImports(c.fileName, func(name string) (string, error) {
+		v, err := statVersion(name)
+		if err != nil {
+			return "", err
+		}
+
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+
+		versions[name] = v
+		return string(content), nil
+	})
+
+	return r, versions, err
+}
+
+// tells whether any of the files changed since they were last loaded
+func (c *WatchClient) changed() (bool, error) {
+	if len(c.versions) == 0 {
+		return true, nil
 	}
 
-	r, err := eskip.Parse(string(content))
+	for name, v := range c.versions {
+		current, err := statVersion(name)
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if !current.equal(v) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *WatchClient) loadAll() watchResponse {
+	defer c.watchFiles()
+	r, versions, err := c.parse()
 	if err != nil {
 		return watchResponse{err: err}
 	}
 
 	c.storeRoutes(r)
+	c.versions = versions
 	return watchResponse{routes: cloneRoutes(r)}
 }
 
 func (c *WatchClient) loadUpdates() watchResponse {
-	content, err := ioutil.ReadFile(c.fileName)
-	if err != nil {
+	defer c.watchFiles()
+	if _, err := os.Stat(c.fileName); err != nil {
 		if os.IsNotExist(err) {
+			c.versions = nil
 			deletedIDs := c.deleteAllListIDs()
 			return watchResponse{deletedIDs: deletedIDs}
 		}
@@ -118,16 +221,27 @@ func (c *WatchClient) loadUpdates() watchResponse {
 		return watchResponse{err: err}
 	}
 
-	r, err := eskip.Parse(string(content))
+	changed, err := c.changed()
+	if err != nil {
+		return watchResponse{err: err}
+	}
+
+	if !changed {
+		return watchResponse{}
+	}
+
+	r, versions, err := c.parse()
 	if err != nil {
 		return watchResponse{err: err}
 	}
 
 	upsert, del := c.diffStoreRoutes(r)
+	c.versions = versions
 	return watchResponse{routes: cloneRoutes(upsert), deletedIDs: del}
 }
 
 func (c *WatchClient) watch() {
+	defer c.events.close()
 	for {
 		select {
 		case req := <-c.getAll:
@@ -156,6 +270,12 @@ func (c *WatchClient) LoadUpdate() ([]*eskip.Route, []string, error) {
 	return rsp.routes, rsp.deletedIDs, rsp.err
 }
 
+// UpdateNotify returns a channel that receives a signal when the file may have changed. It implements the
+// routing.UpdateNotifier interface.
+func (c *WatchClient) UpdateNotify() <-chan struct{} {
+	return c.notify
+}
+
 // Close stops watching the configured file and providing updates.
 func (c *WatchClient) Close() {
 	close(c.quit)
@@ -0,0 +1,75 @@
+package eskipfile
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+)
+
+func initEventWatchTest(t *testing.T) *watchTest {
+	l := loggingtest.New()
+	f := Watch(testWatchFile)
+	return &watchTest{
+		testing: t,
+		log:     l,
+		file:    f,
+		routing: routing.New(routing.Options{
+			Log:            l,
+			FilterRegistry: builtin.MakeRegistry(),
+			DataClients:    []routing.DataClient{f},
+
+			// only the file events can trigger the updates
+			PollTimeout: time.Hour,
+		}),
+	}
+}
+
+func TestWatchFileEvents(t *testing.T) {
+	createFile()
+	defer deleteFile()
+	test := initEventWatchTest(t)
+	defer test.close()
+	test.waitAndSucceedInitial()
+	updateFile()
+	test.waitAndSucceedUpdated()
+}
+
+func TestWatchFileEventsRename(t *testing.T) {
+	createFile()
+	defer deleteFile()
+	test := initEventWatchTest(t)
+	defer test.close()
+	test.waitAndSucceedInitial()
+
+	const tmp = testWatchFile + ".tmp"
+	defer os.Remove(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte(testWatchFileUpdatedContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	if err := os.Rename(tmp, testWatchFile); err != nil {
+		t.Fatal(err)
+	}
+
+	test.waitAndSucceedUpdated()
+}
+
+func TestWatchFileEventsDelete(t *testing.T) {
+	createFile()
+	defer deleteFile()
+	test := initEventWatchTest(t)
+	defer test.close()
+	test.waitAndSucceedInitial()
+	deleteFile()
+	test.waitAndFailInitial()
+}
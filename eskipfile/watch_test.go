@@ -1,6 +1,7 @@
 package eskipfile
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -252,3 +253,83 @@ func TestWatchUpdate(t *testing.T) {
 	updateFile()
 	test.waitAndSucceedUpdated()
 }
+
+func TestSkipsUnchangedFile(t *testing.T) {
+	createFile()
+	defer deleteFile()
+
+	f := Watch(testWatchFile)
+	defer f.Close()
+
+	if _, err := f.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(testWatchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same size, same modification time:
+	createFileWith(testWatchFileContent[:len(testWatchFileContent)-2] + " \n")
+	if err := os.Chtimes(testWatchFile, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deletedIDs, err := f.LoadUpdate()
+	if err != nil || len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Fatal("unexpected update", routes, deletedIDs, err)
+	}
+
+	updateFile()
+	routes, deletedIDs, err = f.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "baz" || len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive update", routes, deletedIDs)
+	}
+}
+
+func TestWatchImportedFile(t *testing.T) {
+	const importedFile = "fixtures/watch-test-imported.eskip"
+	createFileWith(`import "watch-test-imported.eskip"; foo: Path("/foo") -> "https://foo.example.org"`)
+	defer deleteFile()
+
+	if err := ioutil.WriteFile(importedFile, []byte(`bar: Path("/bar") -> "https://bar.example.org"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(importedFile)
+
+	f := Watch(testWatchFile)
+	defer f.Close()
+
+	routes, err := f.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatal("failed to load the imported routes", routes)
+	}
+
+	routes, deletedIDs, err := f.LoadUpdate()
+	if err != nil || len(routes) != 0 || len(deletedIDs) != 0 {
+		t.Fatal("unexpected update", routes, deletedIDs, err)
+	}
+
+	if err := ioutil.WriteFile(importedFile, []byte(`baz: Path("/baz") -> "https://baz-new.example.org"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deletedIDs, err = f.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Id != "baz" || len(deletedIDs) != 1 || deletedIDs[0] != "bar" {
+		t.Error("failed to receive the update of the imported file", routes, deletedIDs)
+	}
+}
@@ -0,0 +1,58 @@
+package etcd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// The stored values starting with this marker contain a gzip compressed
+// route expression, encoded with base64. The marker cannot be the
+// beginning of a valid route expression.
+const compressedMarker = "data:application/gzip;base64,"
+
+func compress(doc string) string {
+	var b bytes.Buffer
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	w, _ := gzip.NewWriterLevel(enc, gzip.BestCompression)
+
+	// writing to a bytes.Buffer doesn't fail
+	w.Write([]byte(doc))
+	w.Close()
+	enc.Close()
+
+	return compressedMarker + b.String()
+}
+
+// Returns the route expression of a stored value, decompressing it
+// when it was stored compressed.
+func decodeValue(v string) (string, error) {
+	if !strings.HasPrefix(v, compressedMarker) {
+		return v, nil
+	}
+
+	dec := base64.NewDecoder(base64.StdEncoding, strings.NewReader(v[len(compressedMarker):]))
+	r, err := gzip.NewReader(dec)
+	if err != nil {
+		return "", err
+	}
+
+	defer r.Close()
+	doc, err := ioutil.ReadAll(r)
+	return string(doc), err
+}
+
+// Returns the value to be stored for a route, compressed when the
+// compression is enabled and the route expression is long enough.
+func (c *Client) encodeRoute(r *eskip.Route) string {
+	doc := r.String()
+	if !c.compress || len(doc) < c.compressMinSize {
+		return doc
+	}
+
+	return compress(doc)
+}
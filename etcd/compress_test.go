@@ -0,0 +1,91 @@
+package etcd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	doc := `Path("/foo") -> setPath("/bar") -> "https://www.example.org"`
+	v := compress(doc)
+	if !strings.HasPrefix(v, compressedMarker) {
+		t.Fatal("failed to mark compressed value")
+	}
+
+	if _, err := eskip.Parse(v); err == nil {
+		t.Error("compressed value parsed as a route expression")
+	}
+
+	d, err := decodeValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d != doc {
+		t.Error("failed to decompress", d)
+	}
+
+	if d, err := decodeValue(doc); err != nil || d != doc {
+		t.Error("failed to pass through uncompressed value", d, err)
+	}
+
+	if _, err := decodeValue(compressedMarker + "not gzip"); err == nil {
+		t.Error("failed to fail on invalid compressed value")
+	}
+}
+
+func TestV3Compress(t *testing.T) {
+	g, s, plain := newV3Gateway(t)
+	defer s.Close()
+
+	c, err := New(Options{
+		Endpoints:       []string{s.URL},
+		Prefix:          "/skippertest",
+		Timeout:         30 * time.Millisecond,
+		V3:              true,
+		Compress:        true,
+		CompressMinSize: 48,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertAll([]*eskip.Route{
+		{Id: "baz", Backend: "https://baz.example.org"},
+		{Id: "qux", Path: "/qux", Backend: "https://qux.example.org/with/a/longer/path"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.kvs["/skippertest/routes/baz"] != `* -> "https://baz.example.org"` {
+		t.Error("short route compressed")
+	}
+
+	if !strings.HasPrefix(g.kvs["/skippertest/routes/qux"], compressedMarker) {
+		t.Error("failed to compress route")
+	}
+
+	g.kvs["/skippertest/routes/broken"] = compressedMarker + "invalid"
+
+	var parseErrors []string
+	plain.onParseErr = func(err *RouteParseError) { parseErrors = append(parseErrors, err.Id) }
+
+	routes, err := plain.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 4 ||
+		!checkBackend(routes, "qux", "https://qux.example.org/with/a/longer/path") ||
+		!checkBackend(routes, "baz", "https://baz.example.org") {
+		t.Error("failed to load compressed routes")
+	}
+
+	if len(parseErrors) != 1 || parseErrors[0] != "broken" {
+		t.Error("failed to report invalid compressed value", parseErrors)
+	}
+}
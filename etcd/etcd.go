@@ -11,12 +11,31 @@ routes will get the etcd key as id.
 
 In addition to the DataClient implementation, type Client provides
 methods to Upsert and Delete routes.
+
+By default, the client uses the etcd v2 keys API. When the V3 option is
+set, it uses the etcd v3 API through the gRPC JSON gateway of etcd,
+storing the routes under the keys with the same prefix as with v2.
+Watching for changes is done with the watch streams of the v3 API, and
+routes can be stored with an expiring lease. The client doesn't use the
+native gRPC protocol, and it requires that the JSON gateway is available
+on the etcd endpoints, under the /v3 path. The gateway is enabled by
+default since etcd 3.4, and in etcd 3.3 it's served under /v3beta, which
+is not supported.
+
+The client can load the routes from multiple storage roots, merging the
+routes found under the different prefixes. The routes are written only
+under the primary prefix.
+
+Large route expressions can be stored compressed with gzip. The
+compressed values are marked with a prefix, and they are decompressed
+transparently when loading the routes.
 */
 package etcd
 
 import (
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -28,16 +47,28 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/multiplexer"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/routing"
 )
 
 const (
 	routesPath      = "/routes"
 	etcdIndexHeader = "X-Etcd-Index"
 	defaultTimeout  = time.Second
+
+	// maximum number of concurrent requests of the batch operations
+	maxConcurrentRequests = 16
+
+	// etcd error code returned when the requested watch index
+	// was already cleared from the event history
+	eventIndexClearedCode = 401
 )
 
 // etcd serialization objects
@@ -55,6 +86,12 @@ type (
 		Action    string `json:"action"`
 		Node      *node  `json:"node"`
 	}
+
+	errorResponse struct {
+		ErrorCode int    `json:"errorCode"`
+		Message   string `json:"message"`
+		Index     uint64 `json:"index"`
+	}
 )
 
 // common error object for errors coming from multiple
@@ -77,6 +114,22 @@ func (ee *endpointErrors) String() string {
 	return ee.Error()
 }
 
+func (e *RouteParseError) Error() string {
+	return fmt.Sprintf("error while parsing route %s: %v", e.Id, e.Err)
+}
+
+func (e *RouteParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InvalidRouteError) Error() string {
+	return fmt.Sprintf("invalid route %s: %v", e.Id, e.Err)
+}
+
+func (e *InvalidRouteError) Unwrap() error {
+	return e.Err
+}
+
 // Initialization options.
 type Options struct {
 
@@ -88,13 +141,58 @@ type Options struct {
 	// Skipper related settings are stored.
 	Prefix string
 
+	// Optional additional etcd paths, whose routes are merged with
+	// the routes under Prefix, e.g. when the route ownership is
+	// sharded by team. When the same route id is found under
+	// multiple paths, the route from the path listed first wins,
+	// with Prefix being the first. The routes are written only
+	// under Prefix.
+	Prefixes []string
+
 	// A timeout value for etcd long-polling.
 	// The default timeout is 1 second.
 	Timeout time.Duration
 
+	// Timeout of the individual requests other than watching for
+	// changes. Defaults to Timeout.
+	RequestTimeout time.Duration
+
+	// The number of times a failed request, other than watching
+	// or the conditional writes of UpsertIf, is retried, when the
+	// failure is transient: etcd could not be reached, the request
+	// timed out, or etcd responded with a server error. Defaults to
+	// 0, no retries.
+	MaxRetries int
+
+	// The initial and the maximum wait time between the retries.
+	// The wait time grows exponentially, with a random jitter.
+	// Defaults to 100 milliseconds and 3 seconds.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// The number of consecutive failed requests, after which the
+	// client reports itself degraded, and stops sending requests
+	// to etcd for DegradedTimeout. After the timeout, a single
+	// request is let through to check whether etcd recovered. 0
+	// disables the degraded mode.
+	DegradedThreshold int
+
+	// The time spent in degraded mode before probing etcd again.
+	// Defaults to 10 seconds.
+	DegradedTimeout time.Duration
+
 	// Skip TLS certificate check.
 	Insecure bool
 
+	// Optional path to a PEM encoded CA certificate bundle, used to
+	// verify the certificates of the etcd endpoints.
+	CAFile string
+
+	// Optional paths to a PEM encoded client certificate and key,
+	// used for client certificate authentication with etcd.
+	CertFile string
+	KeyFile  string
+
 	// Optional OAuth-Token
 	OAuthToken string
 
@@ -103,20 +201,101 @@ type Options struct {
 
 	// Optional password for basic auth
 	Password string
+
+	// Use the etcd v3 API via the etcd JSON gateway
+	// instead of the v2 keys API. The gateway must be
+	// available on the endpoints under the /v3 path.
+	V3 bool
+
+	// Optional handler called for every stored route that cannot be
+	// parsed. These routes are skipped, and the valid ones are loaded.
+	// When not set, the parse errors are logged.
+	ParseErrorHandler func(*RouteParseError)
+
+	// When set, the route expressions are stored compressed with
+	// gzip, when their length is at least CompressMinSize. The
+	// compressed values are decompressed when loading the routes,
+	// regardless of this setting.
+	Compress bool
+
+	// The minimum length of a route expression to be stored
+	// compressed. Defaults to 0, compressing every route.
+	CompressMinSize int
+
+	// Optional registry of the available filters. When set, the
+	// routes referencing unknown filters are rejected by the
+	// upsert methods.
+	FilterRegistry filters.Registry
+
+	// Optional specifications of the available custom predicates.
+	// When set, the routes referencing unknown predicates are
+	// rejected by the upsert methods.
+	Predicates []routing.PredicateSpec
+}
+
+// RouteParseError is reported for the stored route expressions that
+// cannot be parsed.
+type RouteParseError struct {
+	// The id of the route, the etcd key.
+	Id string
+
+	// The error returned by the parser.
+	Err error
+}
+
+// IndexedRoute is a route loaded together with the etcd index of its
+// last modification. With the v3 API, the index is the modification
+// revision of the key.
+type IndexedRoute struct {
+	Route         *eskip.Route
+	ModifiedIndex uint64
+}
+
+// InvalidRouteError is returned by the upsert methods for the routes
+// that would not be loaded by the routing.
+type InvalidRouteError struct {
+	// The id of the route.
+	Id string
+
+	// The reason of the rejection.
+	Err error
 }
 
 // A Client is used to load the whole set of routes and the updates from an
 // etcd store.
 type Client struct {
-	endpoints  []string
-	routesRoot string
-	client     *http.Client
-	etcdIndex  uint64
-	oauthToken string
-	username   string
-	password   string
+	endpointsMx sync.Mutex
+	endpoints   []string
+	routesRoot  string
+	client      *http.Client
+	etcdIndex   uint64
+	routeIds    map[string]bool
+	oauthToken  string
+	username    string
+	password    string
+	v3          bool
+	onParseErr  func(*RouteParseError)
+	filters     filters.Registry
+	predicates  map[string]bool
+
+	watchClient     *http.Client
+	compress        bool
+	compressMinSize int
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	circuit         *circuit
+
+	// clients of the individual storage roots, when there are
+	// additional prefixes
+	roots []*Client
+	mux   *multiplexer.Client
 }
 
+// ErrConflict is returned by UpsertIf when the route was modified since
+// the provided index.
+var ErrConflict = errors.New("route was modified concurrently")
+
 var (
 	missingEtcdEndpoint     = errors.New("missing etcd endpoint")
 	missingRouteId          = errors.New("missing route id")
@@ -124,8 +303,50 @@ var (
 	unexpectedHttpResponse  = errors.New("unexpected http response")
 	notFound                = errors.New("not found")
 	invalidResponseDocument = errors.New("invalid response document")
+	eventIndexCleared       = errors.New("event index cleared")
+	invalidCACertificate    = errors.New("invalid CA certificate")
+	routeRoundTripFailed    = errors.New("the serialized route doesn't parse to the same route")
 )
 
+// Creates the TLS configuration for the etcd connections, or nil when
+// the default settings should be used.
+func tlsConfig(o Options) (*tls.Config, error) {
+	if !o.Insecure && o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" {
+		return nil, nil
+	}
+
+	c := &tls.Config{}
+	if o.Insecure {
+		/* #nosec */
+		c.InsecureSkipVerify = true
+	}
+
+	if o.CAFile != "" {
+		ca, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, invalidCACertificate
+		}
+
+		c.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		kp, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Certificates = []tls.Certificate{kp}
+	}
+
+	return c, nil
+}
+
 // Creates a new Client with the provided options.
 func New(o Options) (*Client, error) {
 	if len(o.Endpoints) == 0 {
@@ -136,30 +357,145 @@ func New(o Options) (*Client, error) {
 		o.Timeout = defaultTimeout
 	}
 
-	httpClient := &http.Client{Timeout: o.Timeout}
+	if o.RequestTimeout == 0 {
+		o.RequestTimeout = o.Timeout
+	}
 
-	if o.Insecure {
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = defaultRetryBackoff
+	}
+
+	if o.MaxRetryBackoff == 0 {
+		o.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+
+	if o.DegradedTimeout == 0 {
+		o.DegradedTimeout = defaultDegradedTimeout
+	}
+
+	httpClient := &http.Client{Timeout: o.RequestTimeout}
+	watchClient := &http.Client{Timeout: o.Timeout}
+
+	tlsConfig, err := tlsConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
 		httpClient.Transport = &http.Transport{
 			Proxy: http.ProxyFromEnvironment,
 			Dial: (&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second}).Dial,
 			TLSHandshakeTimeout: 10 * time.Second,
-			/* #nosec */
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSClientConfig:     tlsConfig,
+		}
+
+		watchClient.Transport = httpClient.Transport
+	}
+
+	var predicates map[string]bool
+	if o.Predicates != nil {
+		// the predicates handled by the routing itself
+		predicates = map[string]bool{
+			routing.PathName:            true,
+			routing.PathSubtreeName:     true,
+			routing.WeightPredicateName: true,
+		}
+
+		for _, p := range o.Predicates {
+			predicates[p.Name()] = true
 		}
 	}
 
-	return &Client{
+	c := &Client{
 		endpoints:  o.Endpoints,
 		routesRoot: o.Prefix + routesPath,
 		client:     httpClient,
 		etcdIndex:  0,
+		routeIds:   make(map[string]bool),
 		oauthToken: o.OAuthToken,
 		username:   o.Username,
-		password:   o.Password}, nil
+		password:   o.Password,
+		v3:         o.V3,
+		onParseErr: o.ParseErrorHandler,
+		filters:    o.FilterRegistry,
+		predicates: predicates,
+
+		watchClient:     watchClient,
+		compress:        o.Compress,
+		compressMinSize: o.CompressMinSize,
+		maxRetries:      o.MaxRetries,
+		retryBackoff:    o.RetryBackoff,
+		maxRetryBackoff: o.MaxRetryBackoff,
+		circuit: &circuit{
+			threshold: o.DegradedThreshold,
+			timeout:   o.DegradedTimeout,
+		}}
+
+	if len(o.Prefixes) > 0 {
+		if err := c.initRoots(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Creates a client for each storage root, and a multiplexer merging
+// their routes.
+func (c *Client) initRoots(o Options) error {
+	var sources []multiplexer.Source
+	for _, prefix := range append([]string{o.Prefix}, o.Prefixes...) {
+		ro := o
+		ro.Prefix = prefix
+		ro.Prefixes = nil
+		r, err := New(ro)
+		if err != nil {
+			return err
+		}
+
+		c.roots = append(c.roots, r)
+		sources = append(sources, multiplexer.Source{Client: r})
+	}
+
+	var err error
+	c.mux, err = multiplexer.New(multiplexer.Options{Sources: sources})
+	return err
+}
+
+// Checks that the serialized route can be parsed back to the same
+// route, and that it references only the known filters and predicates,
+// so that storing it doesn't break the routing of the skipper
+// instances.
+func (c *Client) validate(r *eskip.Route) error {
+	doc := r.String()
+	parsed, err := parseOne(doc)
+	if err != nil {
+		return &InvalidRouteError{Id: r.Id, Err: err}
+	}
+
+	if parsed.String() != doc {
+		return &InvalidRouteError{Id: r.Id, Err: routeRoundTripFailed}
+	}
+
+	if c.filters != nil {
+		for _, f := range parsed.Filters {
+			if _, ok := c.filters[f.Name]; !ok {
+				return &InvalidRouteError{Id: r.Id, Err: fmt.Errorf("filter not found: %s", f.Name)}
+			}
+		}
+	}
+
+	if c.predicates != nil {
+		for _, p := range parsed.Predicates {
+			if !c.predicates[p.Name] {
+				return &InvalidRouteError{Id: r.Id, Err: fmt.Errorf("predicate not found: %s", p.Name)}
+			}
+		}
+	}
+
+	return nil
 }
 
 func isTimeout(err error) bool {
@@ -170,7 +506,7 @@ func isTimeout(err error) bool {
 // Makes a request to an etcd endpoint. If it fails due to connection problems,
 // it makes a new request to the next available endpoint, until all endpoints
 // are tried. It returns the response to the first successful request.
-func (c *Client) tryEndpoints(mreq func(string) (*http.Request, error)) (*http.Response, error) {
+func (c *Client) tryEndpoints(client *http.Client, mreq func(string) (*http.Request, error)) (*http.Response, error) {
 	var (
 		req          *http.Request
 		rsp          *http.Response
@@ -178,13 +514,17 @@ func (c *Client) tryEndpoints(mreq func(string) (*http.Request, error)) (*http.R
 		endpointErrs []error
 	)
 
-	for index, endpoint := range c.endpoints {
-		req, err = mreq(endpoint + "/v2/keys")
+	c.endpointsMx.Lock()
+	endpoints := c.endpoints
+	c.endpointsMx.Unlock()
+
+	for index, endpoint := range endpoints {
+		req, err = mreq(endpoint)
 		if err != nil {
 			return nil, err
 		}
 
-		rsp, err = c.client.Do(req)
+		rsp, err = client.Do(req)
 
 		isTimeoutError := false
 
@@ -203,7 +543,12 @@ func (c *Client) tryEndpoints(mreq func(string) (*http.Request, error)) (*http.R
 
 		if err == nil || isTimeoutError {
 			if index != 0 {
-				c.endpoints = append(c.endpoints[index:], c.endpoints[:index]...)
+				rotated := make([]string, 0, len(endpoints))
+				rotated = append(rotated, endpoints[index:]...)
+				rotated = append(rotated, endpoints[:index]...)
+				c.endpointsMx.Lock()
+				c.endpoints = rotated
+				c.endpointsMx.Unlock()
 			}
 
 			return rsp, err
@@ -243,6 +588,10 @@ func httpError(code int) (bool, error) {
 		return true, notFound
 	}
 
+	if code == http.StatusPreconditionFailed {
+		return true, ErrConflict
+	}
+
 	if code < http.StatusOK || code >= http.StatusMultipleChoices {
 		return true, unexpectedHttpResponse
 	}
@@ -250,32 +599,56 @@ func httpError(code int) (bool, error) {
 	return false, nil
 }
 
+// Checks whether a bad request response was caused by watching an
+// etcd index that is already outdated and cleared from the history.
+func isEventIndexCleared(rsp *http.Response) bool {
+	if rsp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+
+	d, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return false
+	}
+
+	var er errorResponse
+	if err := json.Unmarshal(d, &er); err != nil {
+		return false
+	}
+
+	return er.ErrorCode == eventIndexClearedCode
+}
+
+func (c *Client) setAuthorization(r *http.Request) {
+	// Give oauth priority over basic auth
+	if c.oauthToken != "" {
+		r.Header.Set("Authorization", "Bearer "+c.oauthToken)
+	} else if c.username != "" && c.password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(c.username + ":" + c.password))
+		r.Header.Set("Authorization", "Basic "+credentials)
+	}
+}
+
 // Makes a request to an available etcd endpoint, with retries in case of
 // failure, and converts the http response to a parsed etcd response object.
-func (c *Client) etcdRequest(method, path, data string) (*response, error) {
-	rsp, err := c.tryEndpoints(func(a string) (*http.Request, error) {
+func (c *Client) etcdRequest(method, path string, form url.Values) (*response, error) {
+	return c.etcdRequestKind(requestIdempotent, method, path, form)
+}
+
+func (c *Client) etcdRequestKind(kind requestKind, method, path string, form url.Values) (*response, error) {
+	rsp, err := c.request(kind, func(a string) (*http.Request, error) {
 		var body io.Reader
-		if data != "" {
-			v := make(url.Values)
-			v.Add("value", data)
-			body = bytes.NewBufferString(v.Encode())
+		if len(form) > 0 {
+			body = bytes.NewBufferString(form.Encode())
 		}
 
-		r, err := http.NewRequest(method, a+path, body)
+		r, err := http.NewRequest(method, a+"/v2/keys"+path, body)
 		if err != nil {
 			return nil, err
 		}
 
 		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-		// Give oauth priority over basic auth
-		if c.oauthToken != "" {
-			r.Header.Set("Authorization", "Bearer "+c.oauthToken)
-		} else if c.username != "" && c.password != "" {
-			credentials := base64.StdEncoding.EncodeToString([]byte(c.username + ":" + c.password))
-			r.Header.Set("Authorization", "Basic "+credentials)
-		}
-
+		c.setAuthorization(r)
 		return r, nil
 	})
 
@@ -285,6 +658,10 @@ func (c *Client) etcdRequest(method, path, data string) (*response, error) {
 
 	defer rsp.Body.Close()
 
+	if isEventIndexCleared(rsp) {
+		return nil, eventIndexCleared
+	}
+
 	if hasErr, err := httpError(rsp.StatusCode); hasErr {
 		return nil, err
 	}
@@ -293,38 +670,60 @@ func (c *Client) etcdRequest(method, path, data string) (*response, error) {
 }
 
 func (c *Client) etcdGet() (*response, error) {
-	return c.etcdRequest("GET", c.routesRoot, "")
+	return c.etcdRequest("GET", c.routesRoot, nil)
 }
 
 // Calls etcd 'watch' but with a timeout configured for
 // the http client.
 func (c *Client) etcdGetUpdates() (*response, error) {
-	return c.etcdRequest("GET",
+	return c.etcdRequestKind(requestWatch, "GET",
 		fmt.Sprintf("%s?wait=true&waitIndex=%d&recursive=true",
-			c.routesRoot, c.etcdIndex+1), "")
+			c.routesRoot, c.etcdIndex+1), nil)
 }
 
-func (c *Client) etcdSet(r *eskip.Route) error {
-	_, err := c.etcdRequest("PUT", c.routesRoot+"/"+r.Id, r.String())
+func (c *Client) etcdSet(r *eskip.Route, ttl time.Duration) error {
+	v := make(url.Values)
+	v.Add("value", c.encodeRoute(r))
+	if ttl > 0 {
+		v.Add("ttl", strconv.Itoa(ttlSeconds(ttl)))
+	}
+
+	_, err := c.etcdRequest("PUT", c.routesRoot+"/"+r.Id, v)
 	return err
 }
 
 func (c *Client) etcdDelete(id string) error {
-	_, err := c.etcdRequest("DELETE", c.routesRoot+"/"+id, "")
+	_, err := c.etcdRequest("DELETE", c.routesRoot+"/"+id, nil)
 	return err
 }
 
+// etcd accepts TTL values in whole seconds, and at least one.
+func ttlSeconds(ttl time.Duration) int {
+	s := int((ttl + time.Second - 1) / time.Second)
+	if s < 1 {
+		s = 1
+	}
+
+	return s
+}
+
 // Finds all route expressions in the containing directory node.
 // Returns a map where the keys are the etcd keys and the values are the
-// eskip route expressions.
-func (c *Client) iterateNodes(dir *node, highestIndex uint64) (map[string]string, uint64) {
+// eskip route expressions. When indexes is not nil, it stores the
+// modified index of the routes in it.
+func (c *Client) iterateNodes(dir *node, highestIndex uint64, indexes map[string]uint64) (map[string]string, uint64) {
 	routes := make(map[string]string)
 	for _, n := range dir.Nodes {
 		if n.Dir {
 			continue
 		}
 
-		routes[path.Base(n.Key)] = n.Value
+		id := path.Base(n.Key)
+		routes[id] = n.Value
+		if indexes != nil {
+			indexes[id] = n.ModifiedIndex
+		}
+
 		if n.ModifiedIndex > highestIndex {
 			highestIndex = n.ModifiedIndex
 		}
@@ -354,7 +753,12 @@ func parseRoutes(data map[string]string) []*eskip.RouteInfo {
 	for id, d := range data {
 		info := &eskip.RouteInfo{}
 
-		r, err := parseOne(d)
+		d, err := decodeValue(d)
+		var r *eskip.Route
+		if err == nil {
+			r, err = parseOne(d)
+		}
+
 		if err == nil {
 			info.Route = *r
 		} else {
@@ -368,54 +772,178 @@ func parseRoutes(data map[string]string) []*eskip.RouteInfo {
 	return allInfo
 }
 
-// Converts route info to route objects logging those whose
-// parsing failed.
-func infoToRoutesLogged(info []*eskip.RouteInfo) []*eskip.Route {
+// Converts route info to route objects, skipping and reporting
+// those whose parsing failed.
+func (c *Client) infoToRoutes(info []*eskip.RouteInfo) []*eskip.Route {
 	var routes []*eskip.Route
 	for _, ri := range info {
 		if ri.ParseError == nil {
 			routes = append(routes, &ri.Route)
+			continue
+		}
+
+		perr := &RouteParseError{Id: ri.Id, Err: ri.ParseError}
+		if c.onParseErr != nil {
+			c.onParseErr(perr)
 		} else {
-			log.Println("error while parsing routes", ri.Id, ri.ParseError)
+			log.Error(perr)
 		}
 	}
 
 	return routes
 }
 
-// Returns all the route definitions currently stored in etcd,
-// or the parsing error in case of failure.
-func (c *Client) LoadAndParseAll() ([]*eskip.RouteInfo, error) {
+// Loads the stored route expressions with the v2 API, and
+// returns the index for the subsequent watch requests.
+func (c *Client) loadAllV2(indexes map[string]uint64) (map[string]string, uint64, error) {
 	response, err := c.etcdGet()
 	if err == notFound {
-		return nil, nil
+		return nil, 0, nil
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if !response.Node.Dir {
-		return nil, invalidNode
+		return nil, 0, invalidNode
 	}
 
-	data, etcdIndex := c.iterateNodes(response.Node, 0)
+	data, etcdIndex := c.iterateNodes(response.Node, 0, indexes)
 	if response.etcdIndex > etcdIndex {
 		etcdIndex = response.etcdIndex
 	}
 
+	return data, etcdIndex, nil
+}
+
+// Loads the stored route expressions, without changing the state
+// of the watch. When indexes is not nil, it stores the modified index
+// of the routes in it.
+func (c *Client) loadData(indexes map[string]uint64) (map[string]string, uint64, error) {
+	if c.v3 {
+		return c.loadAllV3(indexes)
+	}
+
+	return c.loadAllV2(indexes)
+}
+
+// Returns all the route definitions currently stored in etcd,
+// or the parsing error in case of failure.
+func (c *Client) LoadAndParseAll() ([]*eskip.RouteInfo, error) {
+	if len(c.roots) > 0 {
+		return c.loadAndParseAllRoots()
+	}
+
+	data, etcdIndex, err := c.loadData(nil)
+	if err != nil {
+		return nil, err
+	}
+
 	c.etcdIndex = etcdIndex
+	c.routeIds = make(map[string]bool)
+	for id := range data {
+		c.routeIds[id] = true
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
 	return parseRoutes(data), nil
 }
 
-// Returns all the route definitions currently stored in etcd.
+// Loads and merges the routes of all the storage roots, the root listed
+// first winning on id collisions.
+func (c *Client) loadAndParseAllRoots() ([]*eskip.RouteInfo, error) {
+	var all []*eskip.RouteInfo
+	ids := make(map[string]bool)
+	for _, r := range c.roots {
+		info, err := r.LoadAndParseAll()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range info {
+			if !ids[i.Id] {
+				ids[i.Id] = true
+				all = append(all, i)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// Returns all the route definitions currently stored in etcd. With
+// multiple storage roots, it loads them concurrently.
 func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	if c.mux != nil {
+		return c.mux.LoadAll()
+	}
+
 	routeInfo, err := c.LoadAndParseAll()
 	if err != nil {
 		return nil, err
 	}
 
-	return infoToRoutesLogged(routeInfo), nil
+	return c.infoToRoutes(routeInfo), nil
+}
+
+// Returns all the route definitions currently stored in etcd, together
+// with the index of their last modification. The index can be used with
+// UpsertIf for optimistic concurrency control. It doesn't change the
+// state of the watch used by LoadUpdate.
+func (c *Client) LoadAllIndexed() ([]*IndexedRoute, error) {
+	indexes := make(map[string]uint64)
+	data, _, err := c.loadData(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*IndexedRoute
+	for _, r := range c.infoToRoutes(parseRoutes(data)) {
+		routes = append(routes, &IndexedRoute{Route: r, ModifiedIndex: indexes[r.Id]})
+	}
+
+	return routes, nil
+}
+
+// Tells whether a watch request failed in a way that the watch needs to be
+// restarted from a fresh state, e.g. during an etcd leader change.
+func isWatchLost(err error) bool {
+	if err == eventIndexCleared {
+		return true
+	}
+
+	if _, ok := err.(*endpointErrors); ok {
+		return true
+	}
+
+	return err == io.EOF ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Loads the complete set of routes after the watch was lost, and returns
+// it as upserts, together with the ids of the routes that disappeared
+// since the last known state. Watching resumes from the new etcd index.
+func (c *Client) resync() ([]*eskip.Route, []string, error) {
+	previous := c.routeIds
+	routeInfo, err := c.LoadAndParseAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deletedIds []string
+	for id := range previous {
+		if !c.routeIds[id] {
+			deletedIds = append(deletedIds, id)
+		}
+	}
+
+	return c.infoToRoutes(routeInfo), deletedIds, nil
 }
 
 // Returns the updates (upserts and deletes) since the last initial request
@@ -424,16 +952,70 @@ func (c *Client) LoadAll() ([]*eskip.Route, error) {
 // It uses etcd's watch functionality that results in blocking this call
 // until the next change is detected in etcd or reaches the configured hard
 // timeout.
+//
+// When the watch is lost, e.g. because the watched index was cleared from
+// the etcd history or the connection was reset during a leader change, it
+// reloads all the routes, and returns them together with the deletions
+// since the previous state.
+//
+// With multiple storage roots, it watches the roots concurrently.
 func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	if c.mux != nil {
+		return c.mux.LoadUpdate()
+	}
+
 	updates := make(map[string]string)
 	deletes := make(map[string]bool)
 
+	var err error
+	if c.v3 {
+		err = c.watchV3(updates, deletes)
+	} else {
+		err = c.watchV2(updates, deletes)
+	}
+
+	if isWatchLost(err) {
+		log.Warnf("etcd watch lost, reloading all routes: %v", err)
+		return c.resync()
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	routeInfo := parseRoutes(updates)
+	routes := c.infoToRoutes(routeInfo)
+
+	deletedIds := make([]string, 0, len(deletes))
+	for id, deleted := range deletes {
+		if deleted {
+			deletedIds = append(deletedIds, id)
+		}
+	}
+
+	return routes, deletedIds, nil
+}
+
+// Records a single change received from the watch.
+func (c *Client) recordChange(updates map[string]string, deletes map[string]bool, id, value string, deleted bool) {
+	if deleted {
+		deletes[id] = true
+		delete(updates, id)
+		delete(c.routeIds, id)
+	} else {
+		updates[id] = value
+		deletes[id] = false
+		c.routeIds[id] = true
+	}
+}
+
+// Collects the changes with the v2 watch, until no more
+// changes arrive within the configured timeout.
+func (c *Client) watchV2(updates map[string]string, deletes map[string]bool) error {
 	for {
 		response, err := c.etcdGetUpdates()
 		if isTimeout(err) {
-			break
+			return nil
 		} else if err != nil {
-			return nil, nil, err
+			return err
 		} else if response.Node.Dir {
 			if response.Node.ModifiedIndex > c.etcdIndex {
 				c.etcdIndex = response.Node.ModifiedIndex
@@ -442,39 +1024,68 @@ func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
 		}
 
 		id := path.Base(response.Node.Key)
-		if response.Action == "delete" {
-			deletes[id] = true
-			delete(updates, id)
-		} else {
-			updates[id] = response.Node.Value
-			deletes[id] = false
-		}
-
+		c.recordChange(updates, deletes, id, response.Node.Value, response.Action == "delete")
 		if response.Node.ModifiedIndex > c.etcdIndex {
 			c.etcdIndex = response.Node.ModifiedIndex
 		}
 	}
+}
 
-	routeInfo := parseRoutes(updates)
-	routes := infoToRoutesLogged(routeInfo)
+// Inserts or updates a route in etcd.
+func (c *Client) Upsert(r *eskip.Route) error {
+	return c.UpsertTTL(r, 0)
+}
 
-	deletedIds := make([]string, 0, len(deletes))
-	for id, deleted := range deletes {
-		if deleted {
-			deletedIds = append(deletedIds, id)
-		}
+// Inserts or updates a route in etcd, that expires after the provided
+// time-to-live, unless it is updated again before. With the v3 API, it
+// grants a new lease for the route. When the ttl is 0, the route doesn't
+// expire.
+func (c *Client) UpsertTTL(r *eskip.Route, ttl time.Duration) error {
+	if r.Id == "" {
+		return missingRouteId
 	}
 
-	return routes, deletedIds, nil
+	if err := c.validate(r); err != nil {
+		return err
+	}
+
+	if c.v3 {
+		return c.putV3(r, ttl)
+	}
+
+	return c.etcdSet(r, ttl)
 }
 
-// Inserts or updates a route in etcd.
-func (c *Client) Upsert(r *eskip.Route) error {
+// Inserts or updates a route in etcd, only if it was not modified since
+// the provided index, as returned by LoadAllIndexed. When prevIndex is 0,
+// it only inserts the route, if it doesn't exist yet. When the condition
+// fails, it returns ErrConflict. The request is not retried, because
+// when a timed out attempt was applied, the retry would report a
+// conflict. After a failure, the route needs to be loaded again to
+// check whether it was written.
+func (c *Client) UpsertIf(r *eskip.Route, prevIndex uint64) error {
 	if r.Id == "" {
 		return missingRouteId
 	}
 
-	return c.etcdSet(r)
+	if err := c.validate(r); err != nil {
+		return err
+	}
+
+	if c.v3 {
+		return c.putIfV3(r, prevIndex)
+	}
+
+	v := make(url.Values)
+	v.Add("value", c.encodeRoute(r))
+	if prevIndex == 0 {
+		v.Add("prevExist", "false")
+	} else {
+		v.Add("prevIndex", strconv.FormatUint(prevIndex, 10))
+	}
+
+	_, err := c.etcdRequestKind(requestConditional, "PUT", c.routesRoot+"/"+r.Id, v)
+	return err
 }
 
 // Deletes a route from etcd.
@@ -483,6 +1094,10 @@ func (c *Client) Delete(id string) error {
 		return missingRouteId
 	}
 
+	if c.v3 {
+		return c.deleteV3(id)
+	}
+
 	err := c.etcdDelete(id)
 	if err == notFound {
 		err = nil
@@ -491,29 +1106,145 @@ func (c *Client) Delete(id string) error {
 	return err
 }
 
+// Inserts or updates multiple routes, generating an id for those that
+// don't have one. With the v3 API, the routes are written in
+// transactions of at most 128 operations, otherwise the requests are
+// made concurrently. The changes are atomic only when they fit in a
+// single transaction. When a later transaction fails, the previous ones
+// are not rolled back, and a *PartialWriteError is returned.
 func (c *Client) UpsertAll(routes []*eskip.Route) error {
 	for _, r := range routes {
 		r.Id = eskip.GenerateIfNeeded(r.Id)
-		err := c.Upsert(r)
-		if err != nil {
-			return err
-		}
 	}
 
-	return nil
+	return c.apply(routes, nil)
 }
 
+// Deletes the routes that match the condition, in the same way as
+// UpsertAll writes them.
 func (c *Client) DeleteAllIf(routes []*eskip.Route, cond eskip.RoutePredicate) error {
+	var ids []string
 	for _, r := range routes {
-		if !cond(r) {
-			continue
+		if cond(r) {
+			ids = append(ids, r.Id)
 		}
+	}
 
-		err := c.Delete(r.Id)
-		if err != nil {
+	return c.apply(nil, ids)
+}
+
+// Sync makes the stored routes equal to the provided ones: it upserts
+// those routes that are new or semantically differ from the stored ones,
+// as compared by eskip.Diff, and deletes the stored routes that are not
+// in the provided set. Routes without an id get a generated one. The
+// changes are written the same way as by UpsertAll, so with many
+// changes, they are not atomic.
+func (c *Client) Sync(routes []*eskip.Route) error {
+	data, _, err := c.loadData(nil)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for _, r := range routes {
+		r.Id = eskip.GenerateIfNeeded(r.Id)
+		keep[r.Id] = true
+	}
+
+	// the routes that fail to parse are always overwritten, because
+	// they are not part of the current routes
+	var current []*eskip.Route
+	for _, ri := range parseRoutes(data) {
+		if ri.ParseError == nil {
+			r := ri.Route
+			current = append(current, &r)
+		}
+	}
+
+	upserts, _ := eskip.Diff(current, routes)
+
+	var deletes []string
+	for id := range data {
+		if !keep[id] {
+			deletes = append(deletes, id)
+		}
+	}
+
+	return c.apply(upserts, deletes)
+}
+
+// Applies the upserts and deletes in batches.
+func (c *Client) apply(upserts []*eskip.Route, deletes []string) error {
+	for _, r := range upserts {
+		if r.Id == "" {
+			return missingRouteId
+		}
+
+		if err := c.validate(r); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	for _, id := range deletes {
+		if id == "" {
+			return missingRouteId
+		}
+	}
+
+	if c.v3 {
+		return c.txnV3(upserts, deletes)
+	}
+
+	var ops []func() error
+	for _, r := range upserts {
+		r := r
+		ops = append(ops, func() error { return c.etcdSet(r, 0) })
+	}
+
+	for _, id := range deletes {
+		id := id
+		ops = append(ops, func() error {
+			if err := c.etcdDelete(id); err != notFound {
+				return err
+			}
+
+			return nil
+		})
+	}
+
+	return concurrently(ops)
+}
+
+// Executes the operations with limited concurrency, and returns the
+// first error.
+func concurrently(ops []func() error) error {
+	var (
+		wg       sync.WaitGroup
+		mx       sync.Mutex
+		firstErr error
+	)
+
+	sem := make(chan struct{}, maxConcurrentRequests)
+	for _, op := range ops {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(op func() error) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := op(); err != nil {
+				mx.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+
+				mx.Unlock()
+			}
+		}(op)
+	}
+
+	wg.Wait()
+	return firstErr
 }
@@ -1,8 +1,11 @@
 package etcd
 
 import (
+	"crypto/tls"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -218,7 +221,7 @@ func TestReceivesInitial(t *testing.T) {
 
 	expectedEndpoints := strings.Join(etcdtest.Urls, ";")
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -249,7 +252,7 @@ func TestReceivesUpdates(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -283,7 +286,7 @@ func TestReceiveInsert(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -319,7 +322,7 @@ func TestReceiveDelete(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -344,7 +347,7 @@ func TestReceiveDelete(t *testing.T) {
 }
 
 func TestUpsertNoId(t *testing.T) {
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -366,7 +369,7 @@ func TestUpsertNew(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -396,7 +399,7 @@ func TestUpsertExisting(t *testing.T) {
 		return
 	}
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -424,8 +427,111 @@ func TestUpsertExisting(t *testing.T) {
 	}
 }
 
+func TestSync(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	if err := etcdtest.DeleteAll(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	initial, err := eskip.Parse(`
+		route1: Method("POST") -> <shunt>;
+		route2: Method("PUT") -> <shunt>;
+		route3: Method("GET") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertAll(initial); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := eskip.Parse(`
+		route1: Method("POST") -> <shunt>;
+		route2: Method("DELETE") -> <shunt>;
+		route4: Method("PATCH") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Sync(next); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := make(map[string]string)
+	for _, r := range routes {
+		methods[r.Id] = r.Method
+	}
+
+	if len(methods) != 3 ||
+		methods["route1"] != "POST" ||
+		methods["route2"] != "DELETE" ||
+		methods["route4"] != "PATCH" {
+		t.Error("failed to sync routes", methods)
+	}
+}
+
+func TestUpsertIf(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	if err := etcdtest.DeleteAll(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	r := &eskip.Route{Id: "route1", Method: "POST", Shunt: true}
+	if err := c.UpsertIf(r, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertIf(r, 0); err != ErrConflict {
+		t.Error("failed to detect existing route", err)
+	}
+
+	routes, err := c.LoadAllIndexed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].ModifiedIndex == 0 {
+		t.Fatal("failed to load the modified index")
+	}
+
+	r.Method = "PUT"
+	if err := c.UpsertIf(r, routes[0].ModifiedIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Method = "PATCH"
+	if err := c.UpsertIf(r, routes[0].ModifiedIndex); err != ErrConflict {
+		t.Error("failed to detect concurrent modification", err)
+	}
+}
+
 func TestDeleteNoId(t *testing.T) {
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -446,7 +552,7 @@ func TestDeleteNotExists(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -480,7 +586,7 @@ func TestDelete(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -518,7 +624,7 @@ func TestLoadWithParseFailures(t *testing.T) {
 	etcdtest.PutData("catalog", `Path("/pdp") -> "https://catalog.example.org"`)
 	etcdtest.PutData("cms", "invalid expression")
 
-	c, err := New(Options{etcdtest.Urls, "/skippertest", 0, false, "", "", ""})
+	c, err := New(Options{Endpoints: etcdtest.Urls, Prefix: "/skippertest"})
 	if err != nil {
 		t.Error(err)
 		return
@@ -558,7 +664,7 @@ func TestRequestWithOauthToken(t *testing.T) {
 	}))
 	defer s.Close()
 
-	c, err := New(Options{[]string{s.URL}, "/skippertest", 0, false, "token", "", ""})
+	c, err := New(Options{Endpoints: []string{s.URL}, Prefix: "/skippertest", OAuthToken: "token"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -583,7 +689,7 @@ func TestRequestWithBasicAuth(t *testing.T) {
 	}))
 	defer s.Close()
 
-	c, err := New(Options{[]string{s.URL}, "/skippertest", 0, false, "", "user", "password"})
+	c, err := New(Options{Endpoints: []string{s.URL}, Prefix: "/skippertest", Username: "user", Password: "password"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -617,3 +723,144 @@ func TestRequestWithBasicAuth(t *testing.T) {
 		t.Fatal("invalid token not set")
 	}
 }
+
+func TestResyncOnClearedEventIndex(t *testing.T) {
+	var watched bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") == "true" {
+			watched = true
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errorCode": 401, "message": "The event in requested index is outdated and cleared", "index": 2048}`))
+			return
+		}
+
+		w.Header().Set("X-Etcd-Index", "2048")
+		if watched {
+			w.Write([]byte(`{"action": "get", "node": {"key": "/skippertest/routes", "dir": true, "nodes": [
+				{"key": "/skippertest/routes/bar", "value": "Path(\"/bar\") -> \"https://bar.example.org\"", "modifiedIndex": 2040}
+			]}}`))
+			return
+		}
+
+		w.Write([]byte(`{"action": "get", "node": {"key": "/skippertest/routes", "dir": true, "nodes": [
+			{"key": "/skippertest/routes/foo", "value": "Path(\"/foo\") -> \"https://foo.example.org\"", "modifiedIndex": 42}
+		]}}`))
+	}))
+	defer s.Close()
+
+	c, err := New(Options{Endpoints: []string{s.URL}, Prefix: "/skippertest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, deletedIds, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || !checkBackend(routes, "bar", "https://bar.example.org") {
+		t.Error("failed to reload routes")
+	}
+
+	if len(deletedIds) != 1 || !checkDeleted(deletedIds, "foo") {
+		t.Error("failed to detect deleted route")
+	}
+
+	if c.etcdIndex != 2048 {
+		t.Error("failed to resume from the new index", c.etcdIndex)
+	}
+}
+
+func TestLoadReportsParseFailures(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Etcd-Index", "42")
+		w.Write([]byte(`{"action": "get", "node": {"key": "/skippertest/routes", "dir": true, "nodes": [
+			{"key": "/skippertest/routes/catalog", "value": "Path(\"/pdp\") -> \"https://catalog.example.org\"", "modifiedIndex": 41},
+			{"key": "/skippertest/routes/cms", "value": "invalid expression", "modifiedIndex": 42}
+		]}}`))
+	}))
+	defer s.Close()
+
+	var reported []*RouteParseError
+	c, err := New(Options{
+		Endpoints:         []string{s.URL},
+		Prefix:            "/skippertest",
+		ParseErrorHandler: func(err *RouteParseError) { reported = append(reported, err) },
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || !checkBackend(routes, "catalog", "https://catalog.example.org") {
+		t.Error("failed to load the valid routes")
+	}
+
+	if len(reported) != 1 || reported[0].Id != "cms" || reported[0].Err == nil {
+		t.Error("failed to report the parse error")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("X-Etcd-Index", "42")
+		w.Write([]byte(`{"action": "get", "node": {"key": "/skippertest/routes", "dir": true}}`))
+	}))
+
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	ca, err := ioutil.TempFile("", "etcd-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(ca.Name())
+	if err := pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}); err != nil {
+		t.Fatal(err)
+	}
+
+	ca.Close()
+
+	c, err := New(Options{
+		Endpoints: []string{s.URL},
+		Prefix:    "/skippertest",
+		CAFile:    ca.Name(),
+		CertFile:  "../fixtures/test.crt",
+		KeyFile:   "../fixtures/test.key",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidCACertificate(t *testing.T) {
+	_, err := New(Options{
+		Endpoints: []string{"https://etcd.example.org"},
+		CAFile:    "../fixtures/test.key",
+	})
+
+	if err != invalidCACertificate {
+		t.Error("failed to fail", err)
+	}
+}
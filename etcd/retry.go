@@ -0,0 +1,195 @@
+package etcd
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultMaxRetryBackoff = 3 * time.Second
+	defaultDegradedTimeout = 10 * time.Second
+)
+
+// ErrDegraded is returned without contacting etcd, while the client
+// considers the etcd cluster unavailable after too many consecutive
+// failures.
+var ErrDegraded = errors.New("etcd client degraded")
+
+// Tracks the consecutive failures of the etcd requests. After reaching
+// the threshold, it rejects the requests until the timeout passes, and
+// then lets a single request through to probe whether etcd recovered.
+type circuit struct {
+	mx        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func (c *circuit) allow() bool {
+	if c.threshold <= 0 {
+		return true
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.failures < c.threshold {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(c.openUntil) {
+		return false
+	}
+
+	// the next probe is allowed only after another timeout
+	c.openUntil = now.Add(c.timeout)
+	return true
+}
+
+func (c *circuit) report(ok bool) {
+	if c.threshold <= 0 {
+		return
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if ok {
+		if c.failures >= c.threshold {
+			log.Info("etcd client recovered")
+		}
+
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+	if c.failures == c.threshold {
+		log.Warnf("etcd client degraded after %d consecutive failures", c.failures)
+		c.openUntil = time.Now().Add(c.timeout)
+	}
+}
+
+func (c *circuit) degraded() bool {
+	if c.threshold <= 0 {
+		return false
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.failures >= c.threshold
+}
+
+// Tells whether a failed request is worth retrying: the endpoints
+// could not be reached, the request timed out, or etcd responded with
+// a server error.
+func isTransient(rsp *http.Response, err error) bool {
+	if err != nil {
+		_, ok := err.(*endpointErrors)
+		return ok || isTimeout(err)
+	}
+
+	return rsp.StatusCode >= http.StatusInternalServerError
+}
+
+// the kinds of the etcd requests, deciding how they are retried
+type requestKind int
+
+const (
+	// the reads and the writes that can be repeated with the same
+	// result are retried in case of transient failures
+	requestIdempotent requestKind = iota
+
+	// the watches are not retried, and timing out is not a failure
+	requestWatch
+
+	// the conditional writes, compare-and-swap, are not retried,
+	// because when a timed out attempt was applied by etcd, the retry
+	// would fail with a conflict
+	requestConditional
+)
+
+func (c *Client) newBackOff(kind requestKind) backoff.BackOff {
+	if c.maxRetries <= 0 || kind == requestConditional {
+		return &backoff.StopBackOff{}
+	}
+
+	// the exponential backoff applies a random jitter to the
+	// intervals by default
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = c.retryBackoff
+	b.MaxInterval = c.maxRetryBackoff
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return backoff.WithMaxRetries(b, uint64(c.maxRetries))
+}
+
+// Makes a request to an available etcd endpoint. The idempotent requests
+// are retried with exponential backoff in case of transient failures.
+// The failures are reported to the circuit, and while the circuit is
+// open, the request fails with ErrDegraded.
+func (c *Client) request(kind requestKind, mreq func(string) (*http.Request, error)) (*http.Response, error) {
+	if kind == requestWatch {
+		if !c.circuit.allow() {
+			return nil, ErrDegraded
+		}
+
+		rsp, err := c.tryEndpoints(c.watchClient, mreq)
+
+		// timing out is the normal outcome of a watch without changes
+		c.circuit.report(isTimeout(err) || !isTransient(rsp, err))
+		return rsp, err
+	}
+
+	b := c.newBackOff(kind)
+	for {
+		if !c.circuit.allow() {
+			return nil, ErrDegraded
+		}
+
+		rsp, err := c.tryEndpoints(c.client, mreq)
+		if !isTransient(rsp, err) {
+			c.circuit.report(true)
+			return rsp, err
+		}
+
+		c.circuit.report(false)
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			return rsp, err
+		}
+
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+
+		log.Debugf("etcd request failed, retrying in %v", wait)
+		time.Sleep(wait)
+	}
+}
+
+// Degraded tells whether the client stopped sending requests to etcd,
+// after the number of consecutive failures reached DegradedThreshold.
+// With multiple storage roots, it is true when any of the roots is
+// degraded.
+func (c *Client) Degraded() bool {
+	if c.circuit.degraded() {
+		return true
+	}
+
+	for _, r := range c.roots {
+		if r.Degraded() {
+			return true
+		}
+	}
+
+	return false
+}
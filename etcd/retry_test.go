@@ -0,0 +1,202 @@
+package etcd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type flakyHandler struct {
+	mx       sync.Mutex
+	handler  http.Handler
+	failures int
+	requests int
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mx.Lock()
+	h.requests++
+	fail := h.failures > 0
+	if fail {
+		h.failures--
+	}
+
+	h.mx.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *flakyHandler) setFailures(n int) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.failures = n
+	h.requests = 0
+}
+
+func (h *flakyHandler) requestCount() int {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return h.requests
+}
+
+func newFlakyGateway(t *testing.T, o Options) (*flakyHandler, *httptest.Server, *Client) {
+	g, s, _ := newV3Gateway(t)
+	s.Close()
+
+	h := &flakyHandler{handler: g}
+	s = httptest.NewServer(h)
+
+	o.Endpoints = []string{s.URL}
+	o.Prefix = "/skippertest"
+	o.Timeout = 30 * time.Millisecond
+	o.V3 = true
+	c, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h, s, c
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	h, s, c := newFlakyGateway(t, Options{
+		MaxRetries:      3,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+	})
+	defer s.Close()
+
+	h.setFailures(2)
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 {
+		t.Error("failed to load routes")
+	}
+
+	if n := h.requestCount(); n != 3 {
+		t.Error("unexpected number of requests", n)
+	}
+
+	h.setFailures(5)
+	if _, err := c.LoadAll(); err != unexpectedHttpResponse {
+		t.Error("failed to report failure", err)
+	}
+
+	if n := h.requestCount(); n != 4 {
+		t.Error("unexpected number of requests", n)
+	}
+}
+
+func TestNoRetryOfPermanentFailures(t *testing.T) {
+	h, s, c := newFlakyGateway(t, Options{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	defer s.Close()
+
+	h.setFailures(0)
+	if _, err := c.v3Post("/unknown", struct{}{}); err != notFound {
+		t.Error("failed to report not found", err)
+	}
+
+	if n := h.requestCount(); n != 1 {
+		t.Error("unexpected number of requests", n)
+	}
+}
+
+func TestNoRetryOfConditionalWrites(t *testing.T) {
+	h, s, c := newFlakyGateway(t, Options{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	defer s.Close()
+
+	h.setFailures(1)
+	err := c.UpsertIf(&eskip.Route{Id: "baz", BackendType: eskip.ShuntBackend, Shunt: true}, 0)
+	if err != unexpectedHttpResponse {
+		t.Error("failed to report failure", err)
+	}
+
+	if n := h.requestCount(); n != 1 {
+		t.Error("unexpected number of requests", n)
+	}
+
+	h.setFailures(1)
+	if err := c.UpsertAll([]*eskip.Route{{Id: "baz", BackendType: eskip.ShuntBackend, Shunt: true}}); err != nil {
+		t.Error(err)
+	}
+
+	if n := h.requestCount(); n != 2 {
+		t.Error("unexpected number of requests", n)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	c, err := New(Options{
+		Endpoints:      []string{s.URL},
+		Timeout:        time.Second,
+		RequestTimeout: 15 * time.Millisecond,
+		V3:             true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); !isTimeout(err) {
+		t.Error("failed to time out", err)
+	}
+}
+
+func TestDegraded(t *testing.T) {
+	h, s, c := newFlakyGateway(t, Options{
+		DegradedThreshold: 2,
+		DegradedTimeout:   30 * time.Millisecond,
+	})
+	defer s.Close()
+
+	h.setFailures(2)
+	for i := 0; i < 2; i++ {
+		if _, err := c.LoadAll(); err != unexpectedHttpResponse {
+			t.Fatal("failed to report failure", err)
+		}
+	}
+
+	if !c.Degraded() {
+		t.Fatal("failed to report degraded")
+	}
+
+	if _, err := c.LoadAll(); err != ErrDegraded {
+		t.Error("failed to fail fast", err)
+	}
+
+	if n := h.requestCount(); n != 2 {
+		t.Error("unexpected number of requests", n)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Degraded() {
+		t.Error("failed to recover")
+	}
+}
@@ -0,0 +1,404 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	// path of the etcd JSON gateway for the v3 API
+	v3Path = "/v3"
+
+	// the default limit of etcd for the operations in a
+	// transaction
+	maxTxnOps = 128
+)
+
+// PartialWriteError is returned by UpsertAll, DeleteAllIf and Sync with
+// the v3 API, when the changes didn't fit in a single transaction, and
+// a transaction failed after some of the previous ones succeeded. The
+// changes written in the succeeded transactions are not rolled back.
+type PartialWriteError struct {
+
+	// Applied is the number of the written operations.
+	Applied int
+
+	// Total is the number of all the operations.
+	Total int
+
+	// Err is the error of the failed transaction.
+	Err error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("etcd changes written partially, %d of %d operations: %v", e.Applied, e.Total, e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error { return e.Err }
+
+// etcd v3 JSON gateway serialization objects. The keys and
+// values are base64 encoded, which the []byte fields take
+// care of, and the 64 bit integers are sent as strings.
+type (
+	keyValue struct {
+		Key         []byte `json:"key"`
+		Value       []byte `json:"value"`
+		ModRevision int64  `json:"mod_revision,string"`
+	}
+
+	responseHeader struct {
+		Revision int64 `json:"revision,string"`
+	}
+
+	rangeRequest struct {
+		Key      []byte `json:"key"`
+		RangeEnd []byte `json:"range_end"`
+	}
+
+	rangeResponse struct {
+		Header responseHeader `json:"header"`
+		Kvs    []*keyValue    `json:"kvs"`
+	}
+
+	putRequest struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+		Lease int64  `json:"lease,string,omitempty"`
+	}
+
+	deleteRangeRequest struct {
+		Key []byte `json:"key"`
+	}
+
+	leaseGrantRequest struct {
+		TTL int64 `json:"TTL,string"`
+	}
+
+	leaseGrantResponse struct {
+		ID    int64  `json:"ID,string"`
+		Error string `json:"error"`
+	}
+
+	requestOp struct {
+		RequestPut         *putRequest         `json:"request_put,omitempty"`
+		RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+	}
+
+	compare struct {
+		Target         string `json:"target"`
+		Result         string `json:"result"`
+		Key            []byte `json:"key"`
+		CreateRevision int64  `json:"create_revision,string"`
+		ModRevision    int64  `json:"mod_revision,string"`
+	}
+
+	txnRequest struct {
+		Compare []*compare   `json:"compare,omitempty"`
+		Success []*requestOp `json:"success"`
+	}
+
+	txnResponse struct {
+		Succeeded bool `json:"succeeded"`
+	}
+
+	watchCreateRequest struct {
+		Key           []byte `json:"key"`
+		RangeEnd      []byte `json:"range_end"`
+		StartRevision int64  `json:"start_revision,string"`
+	}
+
+	watchRequest struct {
+		CreateRequest *watchCreateRequest `json:"create_request"`
+	}
+
+	watchEvent struct {
+		Type string    `json:"type"`
+		Kv   *keyValue `json:"kv"`
+	}
+
+	watchResponse struct {
+		Result struct {
+			Header          responseHeader `json:"header"`
+			Canceled        bool           `json:"canceled"`
+			CompactRevision int64          `json:"compact_revision,string"`
+			Events          []*watchEvent  `json:"events"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+var watchCanceled = errors.New("watch canceled")
+
+// the key prefix of the routes in the v3 key space
+func (c *Client) v3Prefix() string {
+	return c.routesRoot + "/"
+}
+
+// the end of the key range containing all keys with the prefix
+func rangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	// all keys
+	return []byte{0}
+}
+
+// Makes a v3 gateway request to an available etcd endpoint. The
+// caller needs to close the body of the returned response.
+func (c *Client) v3Post(path string, req interface{}) (*http.Response, error) {
+	return c.v3PostKind(requestIdempotent, path, req)
+}
+
+func (c *Client) v3PostKind(kind requestKind, path string, req interface{}) (*http.Response, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := c.request(kind, func(a string) (*http.Request, error) {
+		r, err := http.NewRequest("POST", a+v3Path+path, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		r.Header.Set("Content-Type", "application/json")
+		c.setAuthorization(r)
+		return r, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hasErr, err := httpError(rsp.StatusCode); hasErr {
+		rsp.Body.Close()
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
+// Makes a v3 gateway request, and parses the response.
+func (c *Client) v3Request(path string, req, rsp interface{}) error {
+	return c.v3RequestKind(requestIdempotent, path, req, rsp)
+}
+
+func (c *Client) v3RequestKind(kind requestKind, path string, req, rsp interface{}) error {
+	r, err := c.v3PostKind(kind, path, req)
+	if err != nil {
+		return err
+	}
+
+	defer r.Body.Close()
+	return json.NewDecoder(r.Body).Decode(rsp)
+}
+
+// Loads the stored route expressions with the v3 API, and
+// returns the revision for the subsequent watch requests.
+func (c *Client) loadAllV3(indexes map[string]uint64) (map[string]string, uint64, error) {
+	prefix := c.v3Prefix()
+
+	var rsp rangeResponse
+	if err := c.v3Request("/kv/range", &rangeRequest{
+		Key:      []byte(prefix),
+		RangeEnd: rangeEnd(prefix),
+	}, &rsp); err != nil {
+		return nil, 0, err
+	}
+
+	data := make(map[string]string)
+	for _, kv := range rsp.Kvs {
+		id := strings.TrimPrefix(string(kv.Key), prefix)
+		if id == "" || strings.Contains(id, "/") {
+			continue
+		}
+
+		data[id] = string(kv.Value)
+		if indexes != nil {
+			indexes[id] = uint64(kv.ModRevision)
+		}
+	}
+
+	return data, uint64(rsp.Header.Revision), nil
+}
+
+// Collects the changes from a v3 watch stream, until no more
+// changes arrive within the configured timeout.
+func (c *Client) watchV3(updates map[string]string, deletes map[string]bool) error {
+	prefix := c.v3Prefix()
+	rsp, err := c.v3PostKind(requestWatch, "/watch", &watchRequest{
+		CreateRequest: &watchCreateRequest{
+			Key:           []byte(prefix),
+			RangeEnd:      rangeEnd(prefix),
+			StartRevision: int64(c.etcdIndex) + 1,
+		},
+	})
+
+	if isTimeout(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	dec := json.NewDecoder(rsp.Body)
+	for {
+		var wr watchResponse
+		err := dec.Decode(&wr)
+		if isTimeout(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if wr.Error != nil {
+			return errors.New(wr.Error.Message)
+		}
+
+		if wr.Result.CompactRevision > 0 {
+			return eventIndexCleared
+		}
+
+		if wr.Result.Canceled {
+			return watchCanceled
+		}
+
+		for _, e := range wr.Result.Events {
+			if e.Kv == nil {
+				continue
+			}
+
+			id := strings.TrimPrefix(string(e.Kv.Key), prefix)
+			if id == "" || strings.Contains(id, "/") {
+				continue
+			}
+
+			c.recordChange(updates, deletes, id, string(e.Kv.Value), e.Type == "DELETE")
+			if uint64(e.Kv.ModRevision) > c.etcdIndex {
+				c.etcdIndex = uint64(e.Kv.ModRevision)
+			}
+		}
+	}
+}
+
+func (c *Client) grantLease(ttl time.Duration) (int64, error) {
+	var rsp leaseGrantResponse
+	if err := c.v3Request("/lease/grant", &leaseGrantRequest{TTL: int64(ttlSeconds(ttl))}, &rsp); err != nil {
+		return 0, err
+	}
+
+	if rsp.Error != "" {
+		return 0, errors.New(rsp.Error)
+	}
+
+	return rsp.ID, nil
+}
+
+func (c *Client) putV3(r *eskip.Route, ttl time.Duration) error {
+	var (
+		lease int64
+		err   error
+	)
+
+	if ttl > 0 {
+		if lease, err = c.grantLease(ttl); err != nil {
+			return err
+		}
+	}
+
+	var rsp json.RawMessage
+	return c.v3Request("/kv/put", &putRequest{
+		Key:   []byte(c.v3Prefix() + r.Id),
+		Value: []byte(c.encodeRoute(r)),
+		Lease: lease,
+	}, &rsp)
+}
+
+func (c *Client) deleteV3(id string) error {
+	var rsp json.RawMessage
+	return c.v3Request("/kv/deleterange", &deleteRangeRequest{
+		Key: []byte(c.v3Prefix() + id),
+	}, &rsp)
+}
+
+// Writes the changes in transactions, each containing at most
+// maxTxnOps operations. Only the individual transactions are atomic:
+// when a transaction fails, the ones before it stay applied, and the
+// returned error tells how many of the operations were written.
+func (c *Client) txnV3(upserts []*eskip.Route, deletes []string) error {
+	var ops []*requestOp
+	for _, r := range upserts {
+		ops = append(ops, &requestOp{RequestPut: &putRequest{
+			Key:   []byte(c.v3Prefix() + r.Id),
+			Value: []byte(c.encodeRoute(r)),
+		}})
+	}
+
+	for _, id := range deletes {
+		ops = append(ops, &requestOp{RequestDeleteRange: &deleteRangeRequest{
+			Key: []byte(c.v3Prefix() + id),
+		}})
+	}
+
+	total := len(ops)
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+
+		var rsp json.RawMessage
+		if err := c.v3Request("/kv/txn", &txnRequest{Success: ops[:n]}, &rsp); err != nil {
+			if applied := total - len(ops); applied > 0 {
+				return &PartialWriteError{Applied: applied, Total: total, Err: err}
+			}
+
+			return err
+		}
+
+		ops = ops[n:]
+	}
+
+	return nil
+}
+
+// Puts the route in a transaction, comparing the modification revision
+// of the key, or when prevIndex is 0, that the key doesn't exist.
+func (c *Client) putIfV3(r *eskip.Route, prevIndex uint64) error {
+	key := []byte(c.v3Prefix() + r.Id)
+	cmp := &compare{Result: "EQUAL", Key: key}
+	if prevIndex == 0 {
+		cmp.Target = "CREATE"
+	} else {
+		cmp.Target = "MOD"
+		cmp.ModRevision = int64(prevIndex)
+	}
+
+	var rsp txnResponse
+	if err := c.v3RequestKind(requestConditional, "/kv/txn", &txnRequest{
+		Compare: []*compare{cmp},
+		Success: []*requestOp{{RequestPut: &putRequest{Key: key, Value: []byte(c.encodeRoute(r))}}},
+	}, &rsp); err != nil {
+		return err
+	}
+
+	if !rsp.Succeeded {
+		return ErrConflict
+	}
+
+	return nil
+}
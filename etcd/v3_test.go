@@ -0,0 +1,543 @@
+package etcd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+type v3Gateway struct {
+	t        *testing.T
+	kvs      map[string]string
+	events   []*watchEvent
+	revision int64
+	lease    int64
+	compact  bool
+	txns     int
+	failTxn  int
+	mods     map[string]int64
+}
+
+func (g *v3Gateway) put(key, value string) {
+	if g.mods == nil {
+		g.mods = make(map[string]int64)
+	}
+
+	g.revision++
+	g.kvs[key] = value
+	g.mods[key] = g.revision
+}
+
+func (g *v3Gateway) compare(c *compare) bool {
+	k := string(c.Key)
+	_, exists := g.kvs[k]
+	switch c.Target {
+	case "CREATE":
+		return !exists && c.CreateRevision == 0
+	case "MOD":
+		return exists && g.mods[k] == c.ModRevision
+	default:
+		g.t.Fatal("unsupported compare target", c.Target)
+		return false
+	}
+}
+
+func (g *v3Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	enc := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req rangeRequest
+		if err := dec.Decode(&req); err != nil {
+			g.t.Fatal(err)
+		}
+
+		rsp := rangeResponse{Header: responseHeader{Revision: g.revision}}
+		for k, v := range g.kvs {
+			if k >= string(req.Key) && k < string(req.RangeEnd) {
+				rsp.Kvs = append(rsp.Kvs, &keyValue{Key: []byte(k), Value: []byte(v), ModRevision: g.mods[k]})
+			}
+		}
+
+		enc.Encode(&rsp)
+	case "/v3/kv/put":
+		var req putRequest
+		if err := dec.Decode(&req); err != nil {
+			g.t.Fatal(err)
+		}
+
+		g.kvs[string(req.Key)] = string(req.Value)
+		g.lease = req.Lease
+		w.Write([]byte("{}"))
+	case "/v3/kv/deleterange":
+		var req deleteRangeRequest
+		if err := dec.Decode(&req); err != nil {
+			g.t.Fatal(err)
+		}
+
+		delete(g.kvs, string(req.Key))
+		w.Write([]byte("{}"))
+	case "/v3/kv/txn":
+		var req txnRequest
+		if err := dec.Decode(&req); err != nil {
+			g.t.Fatal(err)
+		}
+
+		if len(req.Success) > maxTxnOps || g.failTxn > 0 && g.txns+1 == g.failTxn {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for _, c := range req.Compare {
+			if !g.compare(c) {
+				w.Write([]byte(`{"succeeded": false}`))
+				return
+			}
+		}
+
+		g.txns++
+		for _, op := range req.Success {
+			switch {
+			case op.RequestPut != nil:
+				g.put(string(op.RequestPut.Key), string(op.RequestPut.Value))
+			case op.RequestDeleteRange != nil:
+				delete(g.kvs, string(op.RequestDeleteRange.Key))
+			}
+		}
+
+		w.Write([]byte(`{"succeeded": true}`))
+	case "/v3/lease/grant":
+		w.Write([]byte(`{"ID": "42", "TTL": "3"}`))
+	case "/v3/watch":
+		var rsp watchResponse
+		if g.compact {
+			rsp.Result.CompactRevision = g.revision
+		} else {
+			rsp.Result.Events = g.events
+		}
+
+		enc.Encode(&rsp)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newV3Gateway(t *testing.T) (*v3Gateway, *httptest.Server, *Client) {
+	g := &v3Gateway{
+		t: t,
+		kvs: map[string]string{
+			"/skippertest/routes/foo": `Path("/foo") -> "https://foo.example.org"`,
+			"/skippertest/routes/bar": `Path("/bar") -> "https://bar.example.org"`,
+			"/skippertest/other":      `* -> "https://other.example.org"`,
+		},
+		revision: 12,
+	}
+
+	s := httptest.NewServer(g)
+	c, err := New(Options{
+		Endpoints: []string{s.URL},
+		Prefix:    "/skippertest",
+		Timeout:   30 * time.Millisecond,
+		V3:        true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, s, c
+}
+
+func TestV3LoadAll(t *testing.T) {
+	_, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 2 ||
+		!checkBackend(routes, "foo", "https://foo.example.org") ||
+		!checkBackend(routes, "bar", "https://bar.example.org") {
+		t.Error("failed to load routes")
+	}
+
+	if c.etcdIndex != 12 {
+		t.Error("failed to set revision", c.etcdIndex)
+	}
+}
+
+func TestV3Watch(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	g.events = []*watchEvent{{
+		Kv: &keyValue{
+			Key:         []byte("/skippertest/routes/baz"),
+			Value:       []byte(`Path("/baz") -> "https://baz.example.org"`),
+			ModRevision: 13,
+		},
+	}, {
+		Type: "DELETE",
+		Kv: &keyValue{
+			Key:         []byte("/skippertest/routes/foo"),
+			ModRevision: 14,
+		},
+	}}
+
+	routes, deletedIds, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || !checkBackend(routes, "baz", "https://baz.example.org") {
+		t.Error("failed to receive update")
+	}
+
+	if len(deletedIds) != 1 || !checkDeleted(deletedIds, "foo") {
+		t.Error("failed to receive delete")
+	}
+
+	if c.etcdIndex != 14 {
+		t.Error("failed to set revision", c.etcdIndex)
+	}
+}
+
+func TestV3WatchCompacted(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(g.kvs, "/skippertest/routes/foo")
+	g.revision = 36
+	g.compact = true
+
+	routes, deletedIds, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || !checkBackend(routes, "bar", "https://bar.example.org") {
+		t.Error("failed to reload routes")
+	}
+
+	if len(deletedIds) != 1 || !checkDeleted(deletedIds, "foo") {
+		t.Error("failed to detect deleted route")
+	}
+
+	if c.etcdIndex != 36 {
+		t.Error("failed to resume from the new revision", c.etcdIndex)
+	}
+}
+
+func TestV3UpsertDelete(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	if err := c.Upsert(&eskip.Route{Id: "baz", Backend: "https://baz.example.org"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.kvs["/skippertest/routes/baz"] != `* -> "https://baz.example.org"` || g.lease != 0 {
+		t.Error("failed to upsert route")
+	}
+
+	if err := c.UpsertTTL(&eskip.Route{Id: "qux", Backend: "https://qux.example.org"}, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.kvs["/skippertest/routes/qux"] == "" || g.lease != 42 {
+		t.Error("failed to upsert route with lease")
+	}
+
+	if err := c.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := g.kvs["/skippertest/routes/foo"]; ok {
+		t.Error("failed to delete route")
+	}
+}
+
+func TestV3Sync(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	routes, err := eskip.Parse(`foo: Path("/foo") -> "https://foo.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxTxnOps; i++ {
+		routes = append(routes, &eskip.Route{
+			Id:          fmt.Sprintf("route%d", i),
+			BackendType: eskip.ShuntBackend,
+			Shunt:       true,
+		})
+	}
+
+	if err := c.Sync(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := g.kvs["/skippertest/routes/bar"]; ok {
+		t.Error("failed to delete route")
+	}
+
+	if _, ok := g.kvs["/skippertest/other"]; !ok {
+		t.Error("deleted a key outside of the routes")
+	}
+
+	if len(g.kvs) != maxTxnOps+2 {
+		t.Error("failed to upsert routes", len(g.kvs))
+	}
+
+	// the unchanged foo route is not written, the rest doesn't
+	// fit in a single transaction
+	if g.txns != 2 {
+		t.Error("invalid number of transactions", g.txns)
+	}
+
+	if c.etcdIndex != 0 {
+		t.Error("sync changed the watch state")
+	}
+}
+
+func TestV3PartialWrite(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	var routes []*eskip.Route
+	for i := 0; i < maxTxnOps+2; i++ {
+		routes = append(routes, &eskip.Route{
+			Id:          fmt.Sprintf("route%d", i),
+			BackendType: eskip.ShuntBackend,
+			Shunt:       true,
+		})
+	}
+
+	g.failTxn = 2
+	err := c.UpsertAll(routes)
+	perr, ok := err.(*PartialWriteError)
+	if !ok {
+		t.Fatalf("failed to report the partial write: %v", err)
+	}
+
+	if perr.Applied != maxTxnOps || perr.Total != maxTxnOps+2 {
+		t.Errorf("invalid partial write: %d of %d", perr.Applied, perr.Total)
+	}
+
+	// the first transaction is not rolled back
+	if len(g.kvs) != maxTxnOps+3 {
+		t.Error("unexpected routes", len(g.kvs))
+	}
+
+	g.txns = 0
+	g.failTxn = 1
+	if err := c.UpsertAll(routes); err == nil {
+		t.Error("failed to fail")
+	} else if _, ok := err.(*PartialWriteError); ok {
+		t.Error("unexpected partial write", err)
+	}
+}
+
+func TestV3UpsertValidation(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	c.filters = builtin.MakeRegistry()
+	c.predicates = map[string]bool{"Traffic": true}
+
+	for _, test := range []struct {
+		title string
+		route *eskip.Route
+		fail  bool
+	}{{
+		title: "valid",
+		route: &eskip.Route{
+			Id:         "valid",
+			Predicates: []*eskip.Predicate{{Name: "Traffic", Args: []interface{}{0.3}}},
+			Filters:    []*eskip.Filter{{Name: "setPath", Args: []interface{}{"/foo"}}},
+			Shunt:      true,
+		},
+	}, {
+		title: "unknown filter",
+		route: &eskip.Route{
+			Id:      "unknownFilter",
+			Filters: []*eskip.Filter{{Name: "noSuchFilter"}},
+			Shunt:   true,
+		},
+		fail: true,
+	}, {
+		title: "unknown predicate",
+		route: &eskip.Route{
+			Id:         "unknownPredicate",
+			Predicates: []*eskip.Predicate{{Name: "NoSuchPredicate"}},
+			Shunt:      true,
+		},
+		fail: true,
+	}, {
+		title: "not parseable",
+		route: &eskip.Route{
+			Id:      "notParseable",
+			Filters: []*eskip.Filter{{Name: "set path"}},
+			Shunt:   true,
+		},
+		fail: true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			err := c.UpsertAll([]*eskip.Route{test.route})
+			_, stored := g.kvs["/skippertest/routes/"+test.route.Id]
+			if !test.fail {
+				if err != nil || !stored {
+					t.Error("failed to upsert route", err)
+				}
+
+				return
+			}
+
+			var ierr *InvalidRouteError
+			if !errors.As(err, &ierr) || ierr.Id != test.route.Id {
+				t.Error("failed to reject route", err)
+			}
+
+			if err := c.Upsert(test.route); err == nil {
+				t.Error("failed to reject single route")
+			}
+
+			if stored {
+				t.Error("invalid route stored")
+			}
+		})
+	}
+}
+
+func TestV3UpsertIf(t *testing.T) {
+	g, s, c := newV3Gateway(t)
+	defer s.Close()
+
+	r := &eskip.Route{Id: "baz", Path: "/baz", BackendType: eskip.ShuntBackend, Shunt: true}
+	if err := c.UpsertIf(r, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertIf(r, 0); err != ErrConflict {
+		t.Error("failed to detect existing route", err)
+	}
+
+	routes, err := c.LoadAllIndexed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var index uint64
+	for _, ri := range routes {
+		if ri.Route.Id == "baz" {
+			index = ri.ModifiedIndex
+		}
+	}
+
+	if index != uint64(g.mods["/skippertest/routes/baz"]) || index == 0 {
+		t.Fatal("failed to load the modified index", index)
+	}
+
+	r.Path = "/qux"
+	if err := c.UpsertIf(r, index); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Path = "/quux"
+	if err := c.UpsertIf(r, index); err != ErrConflict {
+		t.Error("failed to detect concurrent modification", err)
+	}
+
+	if g.kvs["/skippertest/routes/baz"] != `Path("/qux") -> <shunt>` {
+		t.Error("invalid stored route", g.kvs["/skippertest/routes/baz"])
+	}
+
+	if c.etcdIndex != 0 {
+		t.Error("loading changed the watch state")
+	}
+}
+
+func TestV3MultipleRoots(t *testing.T) {
+	g, s, _ := newV3Gateway(t)
+	defer s.Close()
+
+	g.kvs["/team/routes/foo"] = `Path("/foo") -> "https://team.example.org"`
+	g.kvs["/team/routes/baz"] = `Path("/baz") -> "https://team.example.org"`
+
+	c, err := New(Options{
+		Endpoints: []string{s.URL},
+		Prefix:    "/skippertest",
+		Prefixes:  []string{"/team"},
+		Timeout:   30 * time.Millisecond,
+		V3:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := c.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 3 ||
+		!checkBackend(routes, "foo", "https://foo.example.org") ||
+		!checkBackend(routes, "bar", "https://bar.example.org") ||
+		!checkBackend(routes, "baz", "https://team.example.org") {
+		t.Error("failed to merge the routes of the roots")
+	}
+
+	info, err := c.LoadAndParseAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info) != 3 {
+		t.Error("failed to merge the parsed routes of the roots", len(info))
+	}
+
+	g.events = []*watchEvent{{
+		Type: "DELETE",
+		Kv: &keyValue{
+			Key:         []byte("/skippertest/routes/foo"),
+			ModRevision: 13,
+		},
+	}}
+
+	routes, deleted, err := c.LoadUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || !checkBackend(routes, "foo", "https://team.example.org") || len(deleted) != 0 {
+		t.Error("failed to fall back to the route of the other root", routes, deleted)
+	}
+
+	if err := c.Upsert(&eskip.Route{Id: "qux", BackendType: eskip.ShuntBackend, Shunt: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := g.kvs["/skippertest/routes/qux"]; !ok {
+		t.Error("failed to write to the primary root")
+	}
+}
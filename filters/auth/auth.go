@@ -45,6 +45,7 @@ const (
 	invalidClaim       rejectReason = "invalid-claim"
 	invalidFilter      rejectReason = "invalid-filter"
 	invalidAccess      rejectReason = "invalid-access"
+	invalidSignature   rejectReason = "invalid-signature"
 )
 
 const (
@@ -56,6 +57,9 @@ const (
 	tokenKey = "token"
 	scopeKey = "scope"
 	uidKey   = "uid"
+
+	expiresInKey = "expires_in"
+	expKey       = "exp"
 )
 
 type kv map[string][]string
@@ -13,12 +13,14 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/net"
+	jose "gopkg.in/square/go-jose.v2"
 )
 
 const (
 	webhookSpanName            = "webhook"
 	tokenInfoSpanName          = "tokeninfo"
 	tokenIntrospectionSpanName = "tokenintrospection"
+	jwksSpanName               = "jwks"
 )
 
 const (
@@ -26,8 +28,9 @@ const (
 )
 
 type authClient struct {
-	url *url.URL
-	cli *net.Client
+	url   *url.URL
+	cli   *net.Client
+	cache *tokenCache
 }
 
 func newAuthClient(baseURL, spanName string, timeout time.Duration, maxIdleConns int, tracer opentracing.Tracer) (*authClient, error) {
@@ -60,6 +63,10 @@ func (ac *authClient) Close() {
 }
 
 func (ac *authClient) getTokenintrospect(token string, ctx filters.FilterContext) (tokenIntrospectionInfo, error) {
+	if info, ok := ac.cache.get(token); ok {
+		return tokenIntrospectionInfo(info), nil
+	}
+
 	body := url.Values{}
 	body.Add(tokenKey, token)
 	req, err := http.NewRequest("POST", ac.url.String(), strings.NewReader(body.Encode()))
@@ -88,11 +95,22 @@ func (ac *authClient) getTokenintrospect(token string, ctx filters.FilterContext
 		return nil, err
 	}
 	info := make(tokenIntrospectionInfo)
-	err = json.Unmarshal(buf, &info)
-	return info, err
+	if err := json.Unmarshal(buf, &info); err != nil {
+		return info, err
+	}
+
+	if info.Active() {
+		ac.cache.set(token, info, tokenintrospectionExpiresIn(info, time.Now()))
+	}
+
+	return info, nil
 }
 
 func (ac *authClient) getTokeninfo(token string, ctx filters.FilterContext) (map[string]interface{}, error) {
+	if doc, ok := ac.cache.get(token); ok {
+		return doc, nil
+	}
+
 	var doc map[string]interface{}
 
 	req, err := http.NewRequest("GET", ac.url.String(), nil)
@@ -114,8 +132,33 @@ func (ac *authClient) getTokeninfo(token string, ctx filters.FilterContext) (map
 	}
 
 	d := json.NewDecoder(rsp.Body)
-	err = d.Decode(&doc)
-	return doc, err
+	if err := d.Decode(&doc); err != nil {
+		return doc, err
+	}
+
+	ac.cache.set(token, doc, tokeninfoExpiresIn(doc))
+	return doc, nil
+}
+
+func (ac *authClient) getJwks() (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequest("GET", ac.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := ac.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get the JWKS: %d", rsp.StatusCode)
+	}
+
+	var keys jose.JSONWebKeySet
+	err = json.NewDecoder(rsp.Body).Decode(&keys)
+	return &keys, err
 }
 
 func (ac *authClient) getWebhook(ctx filters.FilterContext) (*http.Response, error) {
@@ -124,6 +167,8 @@ func (ac *authClient) getWebhook(ctx filters.FilterContext) (*http.Response, err
 		return nil, err
 	}
 	copyHeader(req.Header, ctx.Request().Header)
+	req.Header.Set(webhookForwardedMethodHeader, ctx.Request().Method)
+	req.Header.Set(webhookForwardedURIHeader, ctx.Request().URL.RequestURI())
 
 	rsp, err := ac.cli.Do(req)
 	if err != nil {
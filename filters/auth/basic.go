@@ -1,9 +1,12 @@
 package auth
 
 import (
+	"net/http"
+	"os"
+
 	auth "github.com/abbot/go-http-auth"
+	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
-	"net/http"
 )
 
 const (
@@ -27,9 +30,23 @@ func NewBasicAuth() *basicSpec {
 //We do not touch response at all
 func (a *basic) Response(filters.FilterContext) {}
 
+// checks the credentials of the request. The htpasswd provider panics when
+// the file cannot be reloaded, e.g. when it was removed. In this case, the
+// request is rejected.
+func (a *basic) checkAuth(r *http.Request) (username string) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("basicAuth: failed to check credentials: %v", err)
+			username = ""
+		}
+	}()
+
+	return a.authenticator.CheckAuth(r)
+}
+
 // check basic auth
 func (a *basic) Request(ctx filters.FilterContext) {
-	username := a.authenticator.CheckAuth(ctx.Request())
+	username := a.checkAuth(ctx.Request())
 
 	if username == "" {
 		header := http.Header{}
@@ -45,8 +62,9 @@ func (a *basic) Request(ctx filters.FilterContext) {
 // Creates out basicAuth Filter
 // The first params specifies the used htpasswd file
 // The second is optional and defines the realm name
+// The htpasswd file is reloaded when it changes.
 func (spec *basicSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
-	if len(config) == 0 {
+	if len(config) == 0 || len(config) > 2 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
@@ -55,12 +73,19 @@ func (spec *basicSpec) CreateFilter(config []interface{}) (filters.Filter, error
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
+	if _, err := os.Stat(configFile); err != nil {
+		return nil, err
+	}
+
 	realmName := DefaultRealmName
 
 	if len(config) == 2 {
-		if definedName, ok := config[1].(string); ok {
-			realmName = definedName
+		definedName, ok := config[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
 		}
+
+		realmName = definedName
 	}
 
 	htpasswd := auth.HtpasswdFileProvider(configFile)
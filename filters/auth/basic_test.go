@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/zalando/skipper/filters"
@@ -89,6 +92,24 @@ func TestCreateFilterBasicAuthErrorCases(t *testing.T) {
 			args:    []interface{}{5},
 			want:    nil,
 			wantErr: true,
+		},
+		{
+			name:    "test missing htpasswd file",
+			args:    []interface{}{"testdata/missing-htpasswd"},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "test wrong realm type passed to filter",
+			args:    []interface{}{"testdata/htpasswd", 5},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "test too many args passed to filter",
+			args:    []interface{}{"testdata/htpasswd", "My Website", "foo"},
+			want:    nil,
+			wantErr: true,
 		}} {
 		t.Run(tt.name, func(t *testing.T) {
 
@@ -102,3 +123,43 @@ func TestCreateFilterBasicAuthErrorCases(t *testing.T) {
 	}
 
 }
+
+func TestBasicAuthFileRemoved(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "basic-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	htpasswd := filepath.Join(dir, "htpasswd")
+	if err := ioutil.WriteFile(htpasswd, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewBasicAuth().CreateFilter([]interface{}{htpasswd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(htpasswd); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetBasicAuth("myName", "myPassword")
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	if !ctx.Served() || ctx.Response().StatusCode != http.StatusUnauthorized {
+		t.Error("failed to reject the request")
+	}
+}
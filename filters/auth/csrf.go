@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/secrets"
+)
+
+const (
+	CSRFName = "csrf"
+
+	// the state bag key of a new token to be set in the response
+	csrfTokenStateKey = "filter::csrf::token"
+
+	csrfTokenSize = 32
+)
+
+type (
+	csrfSpec struct {
+		secretsReader secrets.SecretsReader
+	}
+
+	csrfFilter struct {
+		secretsReader secrets.SecretsReader
+		cookieName    string
+		header        string
+		secretName    string
+	}
+)
+
+// NewCSRF creates a filter specification to protect the routes against
+// cross-site request forgery, using the double submit cookie pattern. The
+// filter issues a token in a cookie for the requests with safe methods,
+// and requires the same token in a request header for the requests with
+// state changing methods. When the name of a secret is set, the tokens
+// are signed, and only the tokens issued by Skipper are accepted.
+func NewCSRF(sr secrets.SecretsReader) filters.Spec {
+	return &csrfSpec{secretsReader: sr}
+}
+
+func (*csrfSpec) Name() string { return CSRFName }
+
+// CreateFilter creates a CSRF filter. The arguments are the name of the
+// token cookie, the name of the request header containing the token, and
+// optionally the name of the secret used to sign the tokens.
+//
+//     s.CreateFilter("csrf-token", "X-CSRF-Token")
+//     s.CreateFilter("csrf-token", "X-CSRF-Token", "my-csrf-secret")
+//
+func (s *csrfSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	sargs, err := getStrings(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sargs) < 2 || len(sargs) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &csrfFilter{
+		secretsReader: s.secretsReader,
+		cookieName:    sargs[0],
+		header:        sargs[1],
+	}
+
+	if f.cookieName == "" || !httpguts.ValidHeaderFieldName(f.header) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if len(sargs) > 2 {
+		f.secretName = sargs[2]
+		if f.secretName == "" || s.secretsReader == nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *csrfFilter) secret() ([]byte, bool) {
+	if f.secretName == "" {
+		return nil, true
+	}
+
+	secret, ok := f.secretsReader.GetSecret(f.secretName)
+	if !ok {
+		log.Errorf("Secret not found for the %s filter: %s.", CSRFName, f.secretName)
+	}
+
+	return secret, ok
+}
+
+func csrfSignature(secret []byte, value string) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(value))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// the token is a random value, followed by its signature separated by a
+// dot, when a secret is used
+func (f *csrfFilter) newToken(secret []byte) (string, error) {
+	b := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(b)
+	if f.secretName != "" {
+		token += "." + csrfSignature(secret, token)
+	}
+
+	return token, nil
+}
+
+func (f *csrfFilter) validToken(secret []byte, token string) bool {
+	if f.secretName == "" {
+		return token != ""
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(parts[1]), []byte(csrfSignature(secret, parts[0])))
+}
+
+func (f *csrfFilter) cookieToken(r *http.Request) string {
+	c, err := r.Cookie(f.cookieName)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
+
+func (f *csrfFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	secret, ok := f.secret()
+	if !ok {
+		if !csrfSafeMethod(r.Method) {
+			forbidden(ctx, "", invalidToken, "CSRF secret not found.")
+		}
+
+		return
+	}
+
+	token := f.cookieToken(r)
+	valid := f.validToken(secret, token)
+	if csrfSafeMethod(r.Method) {
+		if valid {
+			return
+		}
+
+		t, err := f.newToken(secret)
+		if err != nil {
+			log.Errorf("Failed to create a CSRF token: %v.", err)
+			return
+		}
+
+		ctx.StateBag()[csrfTokenStateKey] = t
+		return
+	}
+
+	if !valid || !hmac.Equal([]byte(token), []byte(r.Header.Get(f.header))) {
+		forbidden(ctx, "", invalidToken, "Invalid CSRF token.")
+	}
+}
+
+func (f *csrfFilter) Response(ctx filters.FilterContext) {
+	token, ok := ctx.StateBag()[csrfTokenStateKey].(string)
+	if !ok {
+		return
+	}
+
+	// not HttpOnly, because the client code needs to read the token in
+	// order to send it in the request header
+	c := &http.Cookie{
+		Name:     f.cookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	ctx.Response().Header.Add("Set-Cookie", c.String())
+}
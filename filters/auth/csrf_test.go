@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func testCSRFRequest(t *testing.T, f filters.Filter, method, cookie, header string) *filtertest.Context {
+	req, err := http.NewRequest(method, "https://www.example.org/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: "csrf-token", Value: cookie})
+	}
+
+	if header != "" {
+		req.Header.Set("X-CSRF-Token", header)
+	}
+
+	ctx := &filtertest.Context{
+		FRequest:  req,
+		FResponse: &http.Response{Header: make(http.Header)},
+		FStateBag: make(map[string]interface{}),
+	}
+
+	f.Request(ctx)
+	if !ctx.Served() {
+		f.Response(ctx)
+	}
+
+	return ctx
+}
+
+func issuedCSRFToken(ctx *filtertest.Context) string {
+	for _, c := range (&http.Response{Header: ctx.Response().Header}).Cookies() {
+		if c.Name == "csrf-token" {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+func TestCSRF(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"csrf-token", "X-CSRF-Token"},
+		{"csrf-token", "X-CSRF-Token", "csrf-secret"},
+	} {
+		t.Run(fmt.Sprint(args), func(t *testing.T) {
+			spec := NewCSRF(&testSecretsReader{name: "csrf-secret", secret: "my-secret"})
+			f, err := spec.CreateFilter(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := testCSRFRequest(t, f, "GET", "", "")
+			token := issuedCSRFToken(ctx)
+			if token == "" {
+				t.Fatal("failed to issue a token")
+			}
+
+			ctx = testCSRFRequest(t, f, "GET", token, "")
+			if issuedCSRFToken(ctx) != "" {
+				t.Error("token issued again for a valid token")
+			}
+
+			ctx = testCSRFRequest(t, f, "POST", token, token)
+			if ctx.Served() {
+				t.Errorf("failed to accept a valid token: %d", ctx.Response().StatusCode)
+			}
+
+			for _, test := range []struct {
+				title, cookie, header string
+			}{
+				{"missing cookie", "", token},
+				{"missing header", token, ""},
+				{"different header", token, token + "x"},
+			} {
+				ctx = testCSRFRequest(t, f, "POST", test.cookie, test.header)
+				if !ctx.Served() || ctx.Response().StatusCode != http.StatusForbidden {
+					t.Errorf("failed to reject the request: %s", test.title)
+				}
+			}
+		})
+	}
+}
+
+func TestCSRFSignedToken(t *testing.T) {
+	spec := NewCSRF(&testSecretsReader{name: "csrf-secret", secret: "my-secret"})
+	f, err := spec.CreateFilter([]interface{}{"csrf-token", "X-CSRF-Token", "csrf-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const forged = "0123456789abcdef.0123456789abcdef"
+	ctx := testCSRFRequest(t, f, "POST", forged, forged)
+	if !ctx.Served() || ctx.Response().StatusCode != http.StatusForbidden {
+		t.Error("failed to reject a token not signed by the filter")
+	}
+
+	ctx = testCSRFRequest(t, f, "GET", forged, "")
+	if issuedCSRFToken(ctx) == "" {
+		t.Error("failed to replace a token not signed by the filter")
+	}
+}
+
+func TestCSRFArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{},
+		{"csrf-token"},
+		{"", "X-CSRF-Token"},
+		{"csrf-token", "X CSRF Token"},
+		{"csrf-token", "X-CSRF-Token", ""},
+		{"csrf-token", "X-CSRF-Token", "csrf-secret", "foo"},
+		{"csrf-token", 42},
+	} {
+		if _, err := NewCSRF(&testSecretsReader{}).CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
@@ -22,8 +22,9 @@ type (
 	}
 )
 
-// NewForwardToken creates a filter to forward the result of token info or
-// token introspection to the backend server.
+// NewForwardToken creates a filter to forward the result of token info,
+// token introspection or the claims of the OpenID Connect ID token to the
+// backend server.
 func NewForwardToken() filters.Spec {
 	return &forwardTokenSpec{}
 }
@@ -71,6 +72,16 @@ func (f *forwardTokenFilter) Request(ctx filters.FilterContext) {
 	if tiMap == nil {
 		tiMap = getTokenPayload(ctx, tokenintrospectionCacheKey)
 	}
+	if tiMap == nil {
+		tiMap = getTokenPayload(ctx, jwtValidationCacheKey)
+	}
+	if tiMap == nil {
+		// the claims of the ID token, when the request was authorized by
+		// one of the OpenID Connect filters
+		if container, ok := getTokenPayload(ctx, oidcClaimsCacheKey).(tokenContainer); ok && len(container.Claims) > 0 {
+			tiMap = container.Claims
+		}
+	}
 	if tiMap == nil {
 		return
 	}
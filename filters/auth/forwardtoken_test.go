@@ -12,6 +12,7 @@ import (
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/proxy/proxytest"
 )
 
@@ -307,3 +308,38 @@ func TestInvalidHeadername(t *testing.T) {
 		t.Fatalf("bad header name")
 	}
 }
+
+func TestForwardOIDCClaims(t *testing.T) {
+	f, err := NewForwardToken().CreateFilter([]interface{}{"X-Skipper-Claims", "sub", "email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FStateBag: map[string]interface{}{
+		oidcClaimsCacheKey: tokenContainer{
+			Subject: "jdoe",
+			Claims: map[string]interface{}{
+				"sub":   "jdoe",
+				"email": "jdoe@example.org",
+				"nonce": "foo",
+			},
+		},
+	}}
+
+	f.Request(ctx)
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(req.Header.Get("X-Skipper-Claims")), &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"sub": "jdoe", "email": "jdoe@example.org"}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("invalid claims forwarded: %v", claims)
+	}
+}
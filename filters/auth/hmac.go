@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/secrets"
+)
+
+const (
+	HmacVerifyName = "hmacVerify"
+	HmacSignName   = "hmacSign"
+
+	defaultHmacMaxSkew = 5 * time.Minute
+
+	// the request body is read into memory to verify or to create the
+	// signature, up to this size
+	hmacMaxBodySize = 1 << 24
+)
+
+var errHmacBodyTooLarge = errors.New("request body too large")
+
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type (
+	hmacSpec struct {
+		verify        bool
+		secretsReader secrets.SecretsReader
+	}
+
+	hmacFilter struct {
+		verify          bool
+		secretsReader   secrets.SecretsReader
+		secretName      string
+		header          string
+		algorithm       string
+		hash            func() hash.Hash
+		timestampHeader string
+		maxSkew         time.Duration
+		now             func() time.Time
+	}
+)
+
+// NewHmacVerify creates a filter specification to verify the HMAC
+// signature of the incoming requests, e.g. sent by webhook providers.
+// The secret is looked up by name from the secrets reader.
+func NewHmacVerify(sr secrets.SecretsReader) filters.Spec {
+	return &hmacSpec{verify: true, secretsReader: sr}
+}
+
+// NewHmacSign creates a filter specification to sign the outgoing
+// backend requests with an HMAC signature. The secret is looked up by
+// name from the secrets reader.
+func NewHmacSign(sr secrets.SecretsReader) filters.Spec {
+	return &hmacSpec{secretsReader: sr}
+}
+
+func (s *hmacSpec) Name() string {
+	if s.verify {
+		return HmacVerifyName
+	}
+
+	return HmacSignName
+}
+
+// CreateFilter creates an HMAC filter. The arguments are the name of the
+// secret, the name of the signature header, the hash algorithm: sha1,
+// sha256 or sha512, and optionally the name of the timestamp header. The
+// verifying filter accepts the maximum allowed clock skew of the
+// timestamp as the last, optional, argument, defaulting to 5 minutes.
+//
+//     s.CreateFilter("my-secret", "X-Hub-Signature-256", "sha256")
+//     s.CreateFilter("my-secret", "X-Signature", "sha256", "X-Signature-Timestamp", "30s")
+//
+func (s *hmacSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	sargs, err := getStrings(args)
+	if err != nil {
+		return nil, err
+	}
+
+	maxArgs := 4
+	if s.verify {
+		maxArgs = 5
+	}
+
+	if len(sargs) < 3 || len(sargs) > maxArgs {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &hmacFilter{
+		verify:        s.verify,
+		secretsReader: s.secretsReader,
+		secretName:    sargs[0],
+		header:        sargs[1],
+		algorithm:     sargs[2],
+		maxSkew:       defaultHmacMaxSkew,
+		now:           time.Now,
+	}
+
+	if !httpguts.ValidHeaderFieldName(f.header) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var ok bool
+	if f.hash, ok = hmacAlgorithms[f.algorithm]; !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if len(sargs) > 3 {
+		f.timestampHeader = sargs[3]
+		if !httpguts.ValidHeaderFieldName(f.timestampHeader) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if len(sargs) > 4 {
+		if f.maxSkew, err = time.ParseDuration(sargs[4]); err != nil || f.maxSkew <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+// the signed message is the body, or, when a timestamp is used, the
+// timestamp and the body separated by a dot
+func (f *hmacFilter) sign(secret []byte, timestamp string, body []byte) []byte {
+	m := hmac.New(f.hash, secret)
+	if f.timestampHeader != "" {
+		m.Write([]byte(timestamp + "."))
+	}
+
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+func readRequestBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		log.Errorf("Error while reading the request body: %v.", err)
+		return nil, err
+	}
+
+	if int64(len(b)) > limit {
+		return nil, errHmacBodyTooLarge
+	}
+
+	return b, nil
+}
+
+func (f *hmacFilter) verifyRequest(ctx filters.FilterContext, secret []byte) bool {
+	r := ctx.Request()
+	signature := r.Header.Get(f.header)
+	signature = strings.TrimPrefix(signature, f.algorithm+"=")
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	var timestamp string
+	if f.timestampHeader != "" {
+		timestamp = r.Header.Get(f.timestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+
+		skew := f.now().Sub(time.Unix(seconds, 0))
+		if skew > f.maxSkew || skew < -f.maxSkew {
+			return false
+		}
+	}
+
+	body, err := readRequestBody(r, hmacMaxBodySize)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(expected, f.sign(secret, timestamp, body))
+}
+
+// when the body cannot be read, or it is too large, the request is not
+// forwarded unsigned, but it is rejected
+func (f *hmacFilter) signRequest(ctx filters.FilterContext, secret []byte) {
+	r := ctx.Request()
+	body, err := readRequestBody(r, hmacMaxBodySize)
+	if err == errHmacBodyTooLarge {
+		ctx.Serve(&http.Response{StatusCode: http.StatusRequestEntityTooLarge})
+		return
+	}
+
+	if err != nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	var timestamp string
+	if f.timestampHeader != "" {
+		timestamp = strconv.FormatInt(f.now().Unix(), 10)
+		r.Header.Set(f.timestampHeader, timestamp)
+	}
+
+	r.Header.Set(f.header, f.algorithm+"="+hex.EncodeToString(f.sign(secret, timestamp, body)))
+}
+
+func (f *hmacFilter) Request(ctx filters.FilterContext) {
+	secret, ok := f.secretsReader.GetSecret(f.secretName)
+	if !ok {
+		log.Errorf("Secret not found for the %s filter: %s.", f.name(), f.secretName)
+		if f.verify {
+			unauthorized(ctx, "", invalidSignature, "", "")
+		}
+
+		return
+	}
+
+	if !f.verify {
+		f.signRequest(ctx, secret)
+		return
+	}
+
+	if !f.verifyRequest(ctx, secret) {
+		unauthorized(ctx, "", invalidSignature, "", "")
+		return
+	}
+
+	authorized(ctx, HmacVerifyName)
+}
+
+func (*hmacFilter) Response(filters.FilterContext) {}
+
+func (f *hmacFilter) name() string {
+	if f.verify {
+		return HmacVerifyName
+	}
+
+	return HmacSignName
+}
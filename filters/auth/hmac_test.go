@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func testHmacSignature(secret, message string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(message))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func TestHmacVerify(t *testing.T) {
+	const (
+		secret = "my-secret"
+		body   = `{"action": "opened"}`
+	)
+
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		header   http.Header
+		expected int
+	}{{
+		title:    "missing signature",
+		args:     []interface{}{"secret", "X-Hub-Signature-256", "sha256"},
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "valid signature",
+		args:  []interface{}{"secret", "X-Hub-Signature-256", "sha256"},
+		header: http.Header{
+			"X-Hub-Signature-256": []string{"sha256=" + testHmacSignature(secret, body)},
+		},
+		expected: http.StatusOK,
+	}, {
+		title: "valid signature without algorithm prefix",
+		args:  []interface{}{"secret", "X-Signature", "sha256"},
+		header: http.Header{
+			"X-Signature": []string{testHmacSignature(secret, body)},
+		},
+		expected: http.StatusOK,
+	}, {
+		title: "invalid signature",
+		args:  []interface{}{"secret", "X-Hub-Signature-256", "sha256"},
+		header: http.Header{
+			"X-Hub-Signature-256": []string{"sha256=" + testHmacSignature("other-secret", body)},
+		},
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "unknown secret",
+		args:  []interface{}{"unknown", "X-Hub-Signature-256", "sha256"},
+		header: http.Header{
+			"X-Hub-Signature-256": []string{"sha256=" + testHmacSignature(secret, body)},
+		},
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "valid signature with timestamp",
+		args:  []interface{}{"secret", "X-Signature", "sha256", "X-Signature-Timestamp"},
+		header: http.Header{
+			"X-Signature":           []string{testHmacSignature(secret, timestamp+"."+body)},
+			"X-Signature-Timestamp": []string{timestamp},
+		},
+		expected: http.StatusOK,
+	}, {
+		title: "signature with the timestamp not included",
+		args:  []interface{}{"secret", "X-Signature", "sha256", "X-Signature-Timestamp"},
+		header: http.Header{
+			"X-Signature":           []string{testHmacSignature(secret, body)},
+			"X-Signature-Timestamp": []string{timestamp},
+		},
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "timestamp out of the allowed skew",
+		args:  []interface{}{"secret", "X-Signature", "sha256", "X-Signature-Timestamp", "30s"},
+		header: http.Header{
+			"X-Signature": []string{testHmacSignature(secret, strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)+"."+body)},
+			"X-Signature-Timestamp": []string{
+				strconv.FormatInt(now.Add(-time.Minute).Unix(), 10),
+			},
+		},
+		expected: http.StatusUnauthorized,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			spec := NewHmacVerify(&testSecretsReader{name: "secret", secret: secret})
+			f, err := spec.CreateFilter(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f.(*hmacFilter).now = func() time.Time { return now }
+
+			req, err := http.NewRequest("POST", "https://www.example.org/hook", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			status := http.StatusOK
+			if ctx.Served() {
+				status = ctx.Response().StatusCode
+			}
+
+			if status != test.expected {
+				t.Fatalf("invalid status, expected: %d, got: %d", test.expected, status)
+			}
+
+			if status != http.StatusOK {
+				return
+			}
+
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != body {
+				t.Errorf("failed to preserve the request body: %s", string(b))
+			}
+		})
+	}
+}
+
+func TestHmacSignAndVerify(t *testing.T) {
+	const body = "Hello, world!"
+	sr := &testSecretsReader{name: "secret", secret: "my-secret"}
+	args := []interface{}{"secret", "X-Signature", "sha256", "X-Signature-Timestamp"}
+
+	sign, err := NewHmacSign(sr).CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify, err := NewHmacVerify(sr).CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "https://www.example.org", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	sign.Request(ctx)
+	if !strings.HasPrefix(req.Header.Get("X-Signature"), "sha256=") || req.Header.Get("X-Signature-Timestamp") == "" {
+		t.Fatalf("failed to sign the request: %v", req.Header)
+	}
+
+	verify.Request(ctx)
+	if ctx.Served() {
+		t.Fatalf("failed to verify the signed request: %d", ctx.Response().StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != body {
+		t.Errorf("failed to preserve the request body: %s", string(b))
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("failed to read") }
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
+
+func TestHmacSignInvalidBody(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		body     io.Reader
+		expected int
+	}{{
+		title:    "body too large",
+		body:     io.LimitReader(zeroReader{}, hmacMaxBodySize+1),
+		expected: http.StatusRequestEntityTooLarge,
+	}, {
+		title:    "failing body",
+		body:     failingReader{},
+		expected: http.StatusBadRequest,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			sr := &testSecretsReader{name: "secret", secret: "my-secret"}
+			f, err := NewHmacSign(sr).CreateFilter([]interface{}{"secret", "X-Signature", "sha256"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest("POST", "https://www.example.org", test.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			if !ctx.Served() || ctx.Response().StatusCode != test.expected {
+				t.Fatalf("failed to reject the request, expected: %d", test.expected)
+			}
+
+			if req.Header.Get("X-Signature") != "" {
+				t.Error("unexpected signature")
+			}
+		})
+	}
+}
+
+func TestHmacArgs(t *testing.T) {
+	sr := &testSecretsReader{}
+	for _, args := range [][]interface{}{
+		{"secret", "X-Signature"},
+		{"secret", "X-Signature", "md5"},
+		{"secret", "X Signature", "sha256"},
+		{"secret", "X-Signature", "sha256", "X-Timestamp", "foo"},
+		{"secret", "X-Signature", "sha256", "X-Timestamp", "30s", "foo"},
+		{"secret", "X-Signature", 42},
+	} {
+		if _, err := NewHmacVerify(sr).CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+
+	if _, err := NewHmacSign(sr).CreateFilter([]interface{}{"secret", "X-Signature", "sha256", "X-Timestamp", "30s"}); err == nil {
+		t.Error("failed to fail for the clock skew of the signing filter")
+	}
+}
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	log "github.com/sirupsen/logrus"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	JWTValidationName = "jwtValidation"
+
+	jwtValidationCacheKey = "jwtvalidation"
+
+	defaultJWKSTimeout         = 2 * time.Second
+	defaultJWKSRefreshInterval = time.Hour
+
+	// the JWKS is fetched again for an unknown key ID only when the last
+	// attempt happened earlier than this, to protect the key provider
+	// from tokens with made up key IDs
+	jwksMinRefreshInterval = 10 * time.Second
+)
+
+type JWTValidationOptions struct {
+	Timeout      time.Duration
+	MaxIdleConns int
+	Tracer       opentracing.Tracer
+
+	// RefreshInterval sets how often the keys are fetched again from the
+	// JWKS URL. Defaults to one hour. Independent of this setting, the
+	// keys are fetched again when a token is signed with an unknown key.
+	RefreshInterval time.Duration
+}
+
+type (
+	jwtValidationSpec struct {
+		options JWTValidationOptions
+	}
+
+	jwtValidationFilter struct {
+		keys     *jwksKeySet
+		issuer   string
+		audience string
+	}
+
+	// jwksKeySet caches the keys fetched from a JWKS URL, and it is shared
+	// by the filters using the same URL.
+	jwksKeySet struct {
+		authClient      *authClient
+		refreshInterval time.Duration
+		now             func() time.Time
+
+		mx      sync.Mutex
+		keys    *jose.JSONWebKeySet
+		err     error
+		updated time.Time
+		checked time.Time
+
+		// closed when the running fetch of the keys finished, nil
+		// when there is no running fetch
+		refreshing chan struct{}
+	}
+)
+
+var jwksKeySets map[string]*jwksKeySet = make(map[string]*jwksKeySet)
+
+// NewJWTValidation creates a new auth filter specification to validate
+// the Bearer tokens of the requests as signed JWTs, using the keys from
+// a JWKS URL.
+func NewJWTValidation() filters.Spec {
+	return JWTValidationWithOptions(JWTValidationOptions{})
+}
+
+// JWTValidationWithOptions creates a new auth filter specification to
+// validate the Bearer tokens of the requests as signed JWTs, with
+// additional settings for the JWKS requests.
+func JWTValidationWithOptions(o JWTValidationOptions) filters.Spec {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultJWKSTimeout
+	}
+
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = defaultJWKSRefreshInterval
+	}
+
+	return &jwtValidationSpec{options: o}
+}
+
+func (*jwtValidationSpec) Name() string {
+	return JWTValidationName
+}
+
+// CreateFilter creates an auth filter. The first argument is the JWKS
+// URL. The second, optional, argument is the expected issuer, and the
+// third, optional, argument is the expected audience of the tokens.
+// Empty strings skip the issuer or audience checks.
+//
+//     s.CreateFilter("https://auth.example.org/.well-known/jwks.json")
+//     s.CreateFilter("https://auth.example.org/.well-known/jwks.json", "https://auth.example.org", "my-service")
+//
+func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	sargs, err := getStrings(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sargs) == 0 || len(sargs) > 3 || sargs[0] == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	keys, ok := jwksKeySets[sargs[0]]
+	if !ok {
+		ac, err := newAuthClient(sargs[0], jwksSpanName, s.options.Timeout, s.options.MaxIdleConns, s.options.Tracer)
+		if err != nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		keys = &jwksKeySet{
+			authClient:      ac,
+			refreshInterval: s.options.RefreshInterval,
+			now:             time.Now,
+		}
+
+		jwksKeySets[sargs[0]] = keys
+	}
+
+	f := &jwtValidationFilter{keys: keys}
+	if len(sargs) > 1 {
+		f.issuer = sargs[1]
+	}
+
+	if len(sargs) > 2 {
+		f.audience = sargs[2]
+	}
+
+	return f, nil
+}
+
+// returns the keys matching the key ID, or all the keys, when the key ID
+// is empty. It needs to be called with the lock held.
+func (ks *jwksKeySet) find(kid string) []jose.JSONWebKey {
+	if ks.keys == nil {
+		return nil
+	}
+
+	if kid == "" {
+		return ks.keys.Keys
+	}
+
+	return ks.keys.Key(kid)
+}
+
+// fetches the keys without holding the lock, so that the requests with
+// the cached keys are not blocked. When fetching the keys fails, the
+// previous keys are kept.
+func (ks *jwksKeySet) refresh(started time.Time, done chan struct{}) {
+	set, err := ks.authClient.getJwks()
+
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+	defer close(done)
+	ks.refreshing = nil
+	if err != nil {
+		if ks.keys != nil {
+			log.Errorf("Error while fetching the JWKS, using the previous keys: %v.", err)
+		}
+
+		ks.err = err
+		return
+	}
+
+	ks.keys = set
+	ks.err = nil
+	ks.updated = started
+}
+
+// returns the keys matching the key ID, or all the keys, when the key ID
+// is empty. The keys are fetched again, when they are older than the
+// refresh interval, or when there is no key with the key ID. Only one
+// fetch runs at a time. While the keys are refreshed, the cached keys are
+// used, and only the requests without a matching cached key wait for the
+// fetch to finish.
+func (ks *jwksKeySet) get(kid string) ([]jose.JSONWebKey, error) {
+	ks.mx.Lock()
+	now := ks.now()
+	keys := ks.find(kid)
+	if len(keys) > 0 && now.Sub(ks.updated) < ks.refreshInterval {
+		ks.mx.Unlock()
+		return keys, nil
+	}
+
+	if ks.refreshing == nil && now.Sub(ks.checked) >= jwksMinRefreshInterval {
+		ks.checked = now
+		ks.refreshing = make(chan struct{})
+		go ks.refresh(now, ks.refreshing)
+	}
+
+	refreshing := ks.refreshing
+	ks.mx.Unlock()
+
+	if len(keys) > 0 || refreshing == nil {
+		return keys, nil
+	}
+
+	<-refreshing
+
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+	if ks.keys == nil {
+		return nil, ks.err
+	}
+
+	return ks.find(kid), nil
+}
+
+func (f *jwtValidationFilter) Request(ctx filters.FilterContext) {
+	hostname := f.keys.authClient.url.Hostname()
+	token, ok := getToken(ctx.Request())
+	if !ok || token == "" {
+		unauthorized(ctx, "", missingBearerToken, hostname, "")
+		return
+	}
+
+	t, err := jwt.ParseSigned(token)
+	if err != nil || len(t.Headers) == 0 {
+		unauthorized(ctx, "", invalidToken, hostname, "Failed to parse the token.")
+		return
+	}
+
+	keys, err := f.keys.get(t.Headers[0].KeyID)
+	if err != nil {
+		log.Errorf("Error while fetching the JWKS: %v.", err)
+		unauthorized(ctx, "", authServiceAccess, hostname, "")
+		return
+	}
+
+	var (
+		claims   jwt.Claims
+		all      map[string]interface{}
+		verified bool
+	)
+
+	for _, k := range keys {
+		if err := t.Claims(k, &claims, &all); err == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		unauthorized(ctx, "", invalidToken, hostname, "Failed to verify the token signature.")
+		return
+	}
+
+	expected := jwt.Expected{Issuer: f.issuer, Time: time.Now()}
+	if f.audience != "" {
+		expected.Audience = jwt.Audience{f.audience}
+	}
+
+	if claims.Expiry == nil {
+		err = jwt.ErrExpired
+	} else {
+		err = claims.Validate(expected)
+	}
+
+	if err != nil {
+		unauthorized(ctx, claims.Subject, invalidClaim, hostname, err.Error())
+		return
+	}
+
+	authorized(ctx, claims.Subject)
+	ctx.StateBag()[jwtValidationCacheKey] = all
+}
+
+func (*jwtValidationFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,366 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testJWKS struct {
+	mx       sync.Mutex
+	keys     []jose.JSONWebKey
+	requests int
+}
+
+func (j *testJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.requests++
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: j.keys})
+}
+
+func (j *testJWKS) setKeys(keys ...jose.JSONWebKey) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.keys = keys
+}
+
+func (j *testJWKS) requestCount() int {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	return j.requests
+}
+
+func generateTestKey(t *testing.T, kid string) (*rsa.PrivateKey, jose.JSONWebKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims interface{}) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestJWTValidation(t *testing.T) {
+	key, jwk := generateTestKey(t, "foo")
+	otherKey, _ := generateTestKey(t, "foo")
+
+	jwks := &testJWKS{keys: []jose.JSONWebKey{jwk}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   "https://issuer.example.org",
+			"aud":   "my-service",
+			"sub":   "jdoe",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"email": "jdoe@example.org",
+		}
+	}
+
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		token    func() string
+		expected int
+	}{{
+		title:    "missing token",
+		args:     []interface{}{server.URL},
+		token:    func() string { return "" },
+		expected: http.StatusUnauthorized,
+	}, {
+		title:    "malformed token",
+		args:     []interface{}{server.URL},
+		token:    func() string { return "foo.bar.baz" },
+		expected: http.StatusUnauthorized,
+	}, {
+		title:    "valid token",
+		args:     []interface{}{server.URL, "https://issuer.example.org", "my-service"},
+		token:    func() string { return signTestToken(t, key, "foo", validClaims()) },
+		expected: http.StatusOK,
+	}, {
+		title: "invalid signature",
+		args:  []interface{}{server.URL},
+		token: func() string {
+			return signTestToken(t, otherKey, "foo", validClaims())
+		},
+		expected: http.StatusUnauthorized,
+	}, {
+		title:    "invalid issuer",
+		args:     []interface{}{server.URL, "https://other-issuer.example.org"},
+		token:    func() string { return signTestToken(t, key, "foo", validClaims()) },
+		expected: http.StatusUnauthorized,
+	}, {
+		title:    "invalid audience",
+		args:     []interface{}{server.URL, "", "other-service"},
+		token:    func() string { return signTestToken(t, key, "foo", validClaims()) },
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "expired token",
+		args:  []interface{}{server.URL},
+		token: func() string {
+			c := validClaims()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return signTestToken(t, key, "foo", c)
+		},
+		expected: http.StatusUnauthorized,
+	}, {
+		title: "no expiration",
+		args:  []interface{}{server.URL},
+		token: func() string {
+			c := validClaims()
+			delete(c, "exp")
+			return signTestToken(t, key, "foo", c)
+		},
+		expected: http.StatusUnauthorized,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewJWTValidation().CreateFilter(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest("GET", "https://www.example.org", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if token := test.token(); token != "" {
+				req.Header.Set(authHeaderName, authHeaderPrefix+token)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			status := http.StatusOK
+			if ctx.Served() {
+				status = ctx.Response().StatusCode
+			}
+
+			if status != test.expected {
+				t.Errorf("invalid status, expected: %d, got: %d", test.expected, status)
+			}
+
+			if status != http.StatusOK {
+				return
+			}
+
+			claims, ok := ctx.StateBag()[jwtValidationCacheKey].(map[string]interface{})
+			if !ok || claims["email"] != "jdoe@example.org" {
+				t.Errorf("failed to store the claims: %v", ctx.StateBag())
+			}
+		})
+	}
+
+	if n := jwks.requestCount(); n != 1 {
+		t.Errorf("failed to cache the keys, JWKS requests: %d", n)
+	}
+}
+
+func TestJWTValidationKeyRotation(t *testing.T) {
+	key, jwk := generateTestKey(t, "foo")
+	newKey, newJWK := generateTestKey(t, "bar")
+
+	jwks := &testJWKS{keys: []jose.JSONWebKey{jwk}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	f, err := NewJWTValidation().CreateFilter([]interface{}{server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	f.(*jwtValidationFilter).keys.now = func() time.Time { return now }
+
+	request := func(key *rsa.PrivateKey, kid string) int {
+		req, err := http.NewRequest("GET", "https://www.example.org", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token := signTestToken(t, key, kid, map[string]interface{}{"sub": "jdoe", "exp": time.Now().Add(time.Hour).Unix()})
+		req.Header.Set(authHeaderName, authHeaderPrefix+token)
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		if ctx.Served() {
+			return ctx.Response().StatusCode
+		}
+
+		return http.StatusOK
+	}
+
+	if s := request(key, "foo"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token: %d", s)
+	}
+
+	jwks.setKeys(jwk, newJWK)
+
+	// unknown keys are not fetched again within the minimum interval
+	if s := request(newKey, "bar"); s != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", s)
+	}
+
+	if n := jwks.requestCount(); n != 1 {
+		t.Fatalf("unexpected JWKS requests: %d", n)
+	}
+
+	now = now.Add(2 * jwksMinRefreshInterval)
+	if s := request(newKey, "bar"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token with the rotated key: %d", s)
+	}
+
+	if s := request(key, "foo"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token with the previous key: %d", s)
+	}
+
+	if n := jwks.requestCount(); n != 2 {
+		t.Errorf("unexpected JWKS requests: %d", n)
+	}
+
+	// the cached keys are used while they are refreshed
+	jwks.setKeys(newJWK)
+	now = now.Add(defaultJWKSRefreshInterval)
+	if s := request(key, "foo"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token during the refresh: %d", s)
+	}
+
+	// the removed key is rejected after the refresh finished
+	timeout := time.After(3 * time.Second)
+	for request(key, "foo") != http.StatusUnauthorized {
+		select {
+		case <-timeout:
+			t.Fatal("failed to reject the token with the removed key")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if n := jwks.requestCount(); n != 3 {
+		t.Errorf("unexpected JWKS requests: %d", n)
+	}
+}
+
+// a JWKS handler that blocks the responses while it is locked
+type blockingJWKS struct {
+	*testJWKS
+	block sync.RWMutex
+}
+
+func (j *blockingJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.block.RLock()
+	defer j.block.RUnlock()
+	j.testJWKS.ServeHTTP(w, r)
+}
+
+func TestJWTValidationRefreshNotBlocking(t *testing.T) {
+	key, jwk := generateTestKey(t, "foo")
+	newKey, newJWK := generateTestKey(t, "bar")
+
+	jwks := &blockingJWKS{testJWKS: &testJWKS{keys: []jose.JSONWebKey{jwk}}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	f, err := NewJWTValidation().CreateFilter([]interface{}{server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mx sync.Mutex
+	now := time.Now()
+	f.(*jwtValidationFilter).keys.now = func() time.Time {
+		mx.Lock()
+		defer mx.Unlock()
+		return now
+	}
+
+	request := func(key *rsa.PrivateKey, kid string) int {
+		req, err := http.NewRequest("GET", "https://www.example.org", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token := signTestToken(t, key, kid, map[string]interface{}{"sub": "jdoe", "exp": time.Now().Add(time.Hour).Unix()})
+		req.Header.Set(authHeaderName, authHeaderPrefix+token)
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		if ctx.Served() {
+			return ctx.Response().StatusCode
+		}
+
+		return http.StatusOK
+	}
+
+	if s := request(key, "foo"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token: %d", s)
+	}
+
+	jwks.setKeys(jwk, newJWK)
+	jwks.block.Lock()
+	mx.Lock()
+	now = now.Add(defaultJWKSRefreshInterval)
+	mx.Unlock()
+
+	// the unknown key waits for the blocked fetch
+	unknown := make(chan int)
+	go func() { unknown <- request(newKey, "bar") }()
+
+	// the cached key is not blocked by the running fetch
+	if s := request(key, "foo"); s != http.StatusOK {
+		t.Fatalf("failed to validate the token during the refresh: %d", s)
+	}
+
+	select {
+	case s := <-unknown:
+		t.Fatalf("failed to wait for the fetch: %d", s)
+	default:
+	}
+
+	jwks.block.Unlock()
+	if s := <-unknown; s != http.StatusOK {
+		t.Errorf("failed to validate the token with the fetched key: %d", s)
+	}
+
+	if n := jwks.requestCount(); n != 2 {
+		t.Errorf("unexpected JWKS requests: %d", n)
+	}
+}
+
+func TestJWTValidationArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42},
+		{"https://auth.example.org/jwks", "issuer", "audience", "foo"},
+	} {
+		if _, err := NewJWTValidation().CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
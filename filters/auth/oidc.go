@@ -323,7 +323,10 @@ func (f *tokenOidcFilter) doOauthRedirect(ctx filters.FilterContext) {
 		return
 	}
 
-	oauth2URL := f.config.AuthCodeURL(fmt.Sprintf("%x", stateEnc), f.authCodeOptions...)
+	// the nonce is stored in the state, and it is validated in the callback
+	// against the nonce claim of the ID token
+	options := append([]oauth2.AuthCodeOption{oidc.Nonce(fmt.Sprintf("%x", nonce))}, f.authCodeOptions...)
+	oauth2URL := f.config.AuthCodeURL(fmt.Sprintf("%x", stateEnc), options...)
 	rsp := &http.Response{
 		Header: http.Header{
 			"Location": []string{oauth2URL},
@@ -545,6 +548,18 @@ func (f *tokenOidcFilter) callbackEndpoint(ctx filters.FilterContext) {
 		}
 	}
 
+	if n, _ := claimsMap["nonce"].(string); n != oauthState.Nonce {
+		unauthorized(
+			ctx,
+			sub,
+			invalidToken,
+			r.Host,
+			"Nonce of the ID token does not match the state.",
+		)
+
+		return
+	}
+
 	resp = tokenContainer{
 		OAuth2Token: oauth2Token,
 		UserInfo:    userInfo,
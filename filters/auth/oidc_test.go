@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -124,7 +125,11 @@ var testOpenIDConfig = `{
 // server with configendpoint, tokenendpoint, authenticationserver endpoint, userinfor
 // endpoint, jwks endpoint
 func createOIDCServer(cb, client, clientsecret string) *httptest.Server {
-	var oidcServer *httptest.Server
+	var (
+		oidcServer *httptest.Server
+		nonce      atomic.Value
+	)
+
 	oidcServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case "/.well-known/openid-configuration":
@@ -172,6 +177,7 @@ func createOIDCServer(cb, client, clientsecret string) *httptest.Server {
 			//
 			// redirect if we have a callback
 			if cb != "" {
+				nonce.Store(r.URL.Query().Get("nonce"))
 				state := r.URL.Query().Get("state")
 				u, err := url.Parse(cb + "?state=" + state + "&code=" + validCode)
 				if err != nil {
@@ -230,6 +236,7 @@ func createOIDCServer(cb, client, clientsecret string) *httptest.Server {
 						"AppX-Test-Users",
 					},
 					"email": "someone@example.org",
+					"nonce": nonce.Load(),
 				})
 
 				privKey, err := ioutil.ReadFile(keyPath)
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultTokenCacheSize = 1 << 14
+
+type tokenCacheEntry struct {
+	value   map[string]interface{}
+	expires time.Time
+}
+
+// tokenCache stores the successful token validation results for a
+// limited time, to avoid calling the authorization service on every
+// request with the same token. The entries never outlive the expiration
+// of the token itself, when it is known from the validation result.
+// When the cache is full, and there are no expired entries to drop, the
+// new results are not stored.
+type tokenCache struct {
+	mx      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]tokenCacheEntry
+	now     func() time.Time
+}
+
+// returns nil, when the ttl is not positive, which means that the caching
+// is disabled.
+func newTokenCache(ttl time.Duration, size int) *tokenCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	if size <= 0 {
+		size = defaultTokenCacheSize
+	}
+
+	return &tokenCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]tokenCacheEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *tokenCache) get(token string) (map[string]interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	e, ok := c.entries[token]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.now().Before(e.expires) {
+		delete(c.entries, token)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// stores the validation result. The expiration of the token is taken
+// from the expiresIn duration, when it is not negative. Expired tokens
+// are not stored.
+func (c *tokenCache) set(token string, value map[string]interface{}, expiresIn time.Duration) {
+	if c == nil || expiresIn == 0 {
+		return
+	}
+
+	ttl := c.ttl
+	if expiresIn > 0 && expiresIn < ttl {
+		ttl = expiresIn
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	now := c.now()
+	if _, exists := c.entries[token]; !exists && len(c.entries) >= c.size {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= c.size {
+			return
+		}
+	}
+
+	c.entries[token] = tokenCacheEntry{value: value, expires: now.Add(ttl)}
+}
+
+// returns the remaining validity of the token based on the expires_in
+// field of the tokeninfo response, which can be a number or a string of
+// seconds. It returns -1, when the field is missing or invalid.
+func tokeninfoExpiresIn(doc map[string]interface{}) time.Duration {
+	var seconds float64
+	switch v := doc[expiresInKey].(type) {
+	case float64:
+		seconds = v
+	case string:
+		var err error
+		seconds, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return -1
+		}
+	default:
+		return -1
+	}
+
+	if seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// returns the remaining validity of the token based on the exp field of
+// the token introspection response, which contains the expiration as
+// seconds since the epoch. It returns -1, when the field is missing.
+func tokenintrospectionExpiresIn(info map[string]interface{}, now time.Time) time.Duration {
+	exp, ok := info[expKey].(float64)
+	if !ok {
+		return -1
+	}
+
+	d := time.Unix(int64(exp), 0).Sub(now)
+	if d <= 0 {
+		return 0
+	}
+
+	return d
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestTokenCache(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	c.set("foo", map[string]interface{}{"uid": "foo"}, -1)
+	c.set("bar", map[string]interface{}{"uid": "bar"}, 10*time.Second)
+	c.set("expired", map[string]interface{}{"uid": "expired"}, 0)
+
+	if v, ok := c.get("foo"); !ok || v["uid"] != "foo" {
+		t.Error("failed to get the cached token")
+	}
+
+	if _, ok := c.get("expired"); ok {
+		t.Error("unexpected expired token")
+	}
+
+	c.set("baz", map[string]interface{}{"uid": "baz"}, -1)
+	if _, ok := c.get("baz"); ok {
+		t.Error("failed to limit the cache size")
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, ok := c.get("bar"); ok {
+		t.Error("failed to expire the token by its expiration")
+	}
+
+	c.set("baz", map[string]interface{}{"uid": "baz"}, -1)
+	if _, ok := c.get("baz"); !ok {
+		t.Error("failed to cache the token after expiration")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get("foo"); ok {
+		t.Error("failed to expire the token by the ttl")
+	}
+
+	var disabled *tokenCache
+	disabled.set("foo", map[string]interface{}{}, -1)
+	if _, ok := disabled.get("foo"); ok {
+		t.Error("unexpected cached token")
+	}
+}
+
+func TestTokeninfoExpiresIn(t *testing.T) {
+	for _, test := range []struct {
+		doc      map[string]interface{}
+		expected time.Duration
+	}{{
+		doc:      map[string]interface{}{},
+		expected: -1,
+	}, {
+		doc:      map[string]interface{}{"expires_in": float64(300)},
+		expected: 300 * time.Second,
+	}, {
+		doc:      map[string]interface{}{"expires_in": "300"},
+		expected: 300 * time.Second,
+	}, {
+		doc:      map[string]interface{}{"expires_in": "foo"},
+		expected: -1,
+	}, {
+		doc:      map[string]interface{}{"expires_in": float64(0)},
+		expected: 0,
+	}} {
+		if d := tokeninfoExpiresIn(test.doc); d != test.expected {
+			t.Errorf("invalid expiration for %v: %v", test.doc, d)
+		}
+	}
+}
+
+func TestTokeninfoCache(t *testing.T) {
+	var requests int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get(authHeaderName) != authHeaderPrefix+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"uid": "jdoe", "scope": ["uid"], "expires_in": 300}`))
+	}))
+	defer backend.Close()
+
+	spec := NewOAuthTokeninfoAnyScopeWithOptions(TokeninfoOptions{
+		URL:      backend.URL,
+		Timeout:  time.Second,
+		CacheTTL: time.Minute,
+	})
+
+	f, err := spec.CreateFilter([]interface{}{"uid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.(*tokeninfoFilter).Close()
+
+	request := func(token string) *filtertest.Context {
+		req, err := http.NewRequest("GET", "https://www.example.org", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set(authHeaderName, authHeaderPrefix+token)
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		return ctx
+	}
+
+	for i := 0; i < 3; i++ {
+		if ctx := request(testToken); ctx.Served() {
+			t.Fatalf("failed to authorize the request: %d", ctx.Response().StatusCode)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("failed to cache the token, tokeninfo requests: %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ctx := request("invalid-token"); !ctx.Served() || ctx.Response().StatusCode != http.StatusUnauthorized {
+			t.Fatal("failed to reject the request")
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("unexpected caching of the invalid token, tokeninfo requests: %d", n)
+	}
+}
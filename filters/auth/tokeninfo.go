@@ -23,6 +23,14 @@ type TokeninfoOptions struct {
 	Timeout      time.Duration
 	MaxIdleConns int
 	Tracer       opentracing.Tracer
+
+	// CacheTTL enables caching the successful tokeninfo responses for
+	// the given duration, but not longer than the token expiration.
+	CacheTTL time.Duration
+
+	// CacheSize limits the number of the cached tokens. Defaults to
+	// 16384.
+	CacheSize int
 }
 
 type (
@@ -180,6 +188,7 @@ func (s *tokeninfoSpec) CreateFilter(args []interface{}) (filters.Filter, error)
 		if err != nil {
 			return nil, filters.ErrInvalidFilterParameters
 		}
+		ac.cache = newTokenCache(s.options.CacheTTL, s.options.CacheSize)
 		tokeninfoAuthClient[s.options.URL] = ac
 	}
 
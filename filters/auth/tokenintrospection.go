@@ -32,6 +32,14 @@ type TokenintrospectionOptions struct {
 	Timeout      time.Duration
 	Tracer       opentracing.Tracer
 	MaxIdleConns int
+
+	// CacheTTL enables caching the results of the active tokens for the
+	// given duration, but not longer than the token expiration.
+	CacheTTL time.Duration
+
+	// CacheSize limits the number of the cached tokens. Defaults to
+	// 16384.
+	CacheSize int
 }
 
 type (
@@ -286,6 +294,7 @@ func (s *tokenIntrospectionSpec) CreateFilter(args []interface{}) (filters.Filte
 		if err != nil {
 			return nil, filters.ErrInvalidFilterParameters
 		}
+		ac.cache = newTokenCache(s.options.CacheTTL, s.options.CacheSize)
 		issuerAuthClient[issuerURL] = ac
 	}
 
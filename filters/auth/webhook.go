@@ -15,6 +15,17 @@ import (
 
 const (
 	WebhookName = "webhook"
+
+	// WebhookFailOpen allows the requests, when the webhook cannot be
+	// reached, or it responds with a server error.
+	WebhookFailOpen = "fail-open"
+
+	// WebhookFailClosed rejects the requests, when the webhook cannot be
+	// reached, or it responds with a server error. This is the default.
+	WebhookFailClosed = "fail-closed"
+
+	webhookForwardedMethodHeader = "X-Forwarded-Method"
+	webhookForwardedURIHeader    = "X-Forwarded-Uri"
 )
 
 type WebhookOptions struct {
@@ -30,6 +41,7 @@ type (
 	webhookFilter struct {
 		authClient                *authClient
 		forwardResponseHeaderKeys []string
+		failOpen                  bool
 	}
 )
 
@@ -53,13 +65,15 @@ func (*webhookSpec) Name() string {
 
 // CreateFilter creates an auth filter. The first argument is an URL
 // string. The second, optional, argument is a comma separated list of
-// headers to forward from from webhook response.
+// headers to forward from from webhook response. The third, optional,
+// argument is the failure policy, "fail-closed" or "fail-open".
 //
 //     s.CreateFilter("https://my-auth-service.example.org/auth")
 //     s.CreateFilter("https://my-auth-service.example.org/auth", "X-Auth-User,X-Auth-User-Roles")
+//     s.CreateFilter("https://my-auth-service.example.org/auth", "", "fail-open")
 //
 func (ws *webhookSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
-	if l := len(args); l == 0 || l > 2 {
+	if l := len(args); l == 0 || l > 3 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
@@ -77,14 +91,27 @@ func (ws *webhookSpec) CreateFilter(args []interface{}) (filters.Filter, error)
 			return nil, filters.ErrInvalidFilterParameters
 		}
 
-		headerKeys := strings.Split(headerKeysOption, ",")
+		if headerKeysOption != "" {
+			headerKeys := strings.Split(headerKeysOption, ",")
 
-		for _, header := range headerKeys {
-			valid := httpguts.ValidHeaderFieldName(header)
-			if !valid {
-				return nil, fmt.Errorf("header %s is invalid", header)
+			for _, header := range headerKeys {
+				valid := httpguts.ValidHeaderFieldName(header)
+				if !valid {
+					return nil, fmt.Errorf("header %s is invalid", header)
+				}
+				forwardResponseHeaderKeys = append(forwardResponseHeaderKeys, http.CanonicalHeaderKey(header))
 			}
-			forwardResponseHeaderKeys = append(forwardResponseHeaderKeys, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	var failOpen bool
+	if len(args) > 2 {
+		switch args[2] {
+		case WebhookFailOpen:
+			failOpen = true
+		case WebhookFailClosed:
+		default:
+			return nil, filters.ErrInvalidFilterParameters
 		}
 	}
 
@@ -93,7 +120,11 @@ func (ws *webhookSpec) CreateFilter(args []interface{}) (filters.Filter, error)
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
-	return &webhookFilter{authClient: ac, forwardResponseHeaderKeys: forwardResponseHeaderKeys}, nil
+	return &webhookFilter{
+		authClient:                ac,
+		forwardResponseHeaderKeys: forwardResponseHeaderKeys,
+		failOpen:                  failOpen,
+	}, nil
 }
 
 func copyHeader(to, from http.Header) {
@@ -106,10 +137,26 @@ func (f *webhookFilter) Request(ctx filters.FilterContext) {
 	resp, err := f.authClient.getWebhook(ctx)
 	if err != nil {
 		log.Errorf("Failed to make authentication webhook request: %v.", err)
+	} else if resp.StatusCode >= 500 {
+		log.Errorf("Authentication webhook responded with: %d.", resp.StatusCode)
 	}
 
-	// errors, redirects, auth errors, webhook errors
-	if err != nil || resp.StatusCode >= 300 {
+	// webhook errors
+	if err != nil || resp.StatusCode >= 500 {
+		if f.failOpen {
+			authorized(ctx, WebhookName)
+			return
+		}
+
+		unauthorized(ctx, "", invalidAccess, f.authClient.url.Hostname(), WebhookName)
+		return
+	}
+
+	// redirects, auth errors
+	if resp.StatusCode == http.StatusForbidden {
+		forbidden(ctx, "", invalidAccess, WebhookName)
+		return
+	} else if resp.StatusCode >= 300 {
 		unauthorized(ctx, "", invalidAccess, f.authClient.url.Hostname(), WebhookName)
 		return
 	}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/proxy/proxytest"
 )
 
@@ -154,3 +155,98 @@ func TestWebhook(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhookPolicy(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		status   int
+		down     bool
+		policy   string
+		expected int
+	}{{
+		msg:      "server error, default policy",
+		status:   http.StatusInternalServerError,
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "server error, fail-closed",
+		status:   http.StatusServiceUnavailable,
+		policy:   WebhookFailClosed,
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "server error, fail-open",
+		status:   http.StatusInternalServerError,
+		policy:   WebhookFailOpen,
+		expected: http.StatusOK,
+	}, {
+		msg:      "unreachable, fail-open",
+		down:     true,
+		policy:   WebhookFailOpen,
+		expected: http.StatusOK,
+	}, {
+		msg:      "unreachable, fail-closed",
+		down:     true,
+		policy:   WebhookFailClosed,
+		expected: http.StatusUnauthorized,
+	}, {
+		msg:      "forbidden, fail-open",
+		status:   http.StatusForbidden,
+		policy:   WebhookFailOpen,
+		expected: http.StatusForbidden,
+	}, {
+		msg:      "authorized",
+		status:   http.StatusOK,
+		expected: http.StatusOK,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Forwarded-Method") != "POST" || r.Header.Get("X-Forwarded-Uri") != "/foo?bar=baz" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(ti.status)
+			}))
+
+			if ti.down {
+				authServer.Close()
+			} else {
+				defer authServer.Close()
+			}
+
+			args := []interface{}{authServer.URL}
+			if ti.policy != "" {
+				args = append(args, "", ti.policy)
+			}
+
+			f, err := NewWebhook(time.Second).CreateFilter(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer f.(*webhookFilter).Close()
+
+			req, err := http.NewRequest("POST", "https://www.example.org/foo?bar=baz", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			status := http.StatusOK
+			if ctx.Served() {
+				status = ctx.Response().StatusCode
+			}
+
+			if status != ti.expected {
+				t.Errorf("unexpected status code: %d, expected: %d", status, ti.expected)
+			}
+		})
+	}
+}
+
+func TestWebhookInvalidPolicy(t *testing.T) {
+	if _, err := NewWebhook(time.Second).CreateFilter([]interface{}{"https://auth.example.org", "", "fail-maybe"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
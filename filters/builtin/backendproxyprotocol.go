@@ -0,0 +1,42 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+)
+
+type backendProxyProtocolSpec struct{}
+
+type backendProxyProtocolFilter struct {
+	version int
+}
+
+// NewBackendProxyProtocol returns a filter specification that makes the
+// proxy send the PROXY protocol header to the backend of a route, when it
+// connects to it, passing the address of the client. The optional argument
+// is the version of the protocol, 1 or 2, defaulting to 1. The connections
+// with the header are not reused for other requests.
+func NewBackendProxyProtocol() filters.Spec { return backendProxyProtocolSpec{} }
+
+func (backendProxyProtocolSpec) Name() string { return BackendProxyProtocolName }
+
+func (backendProxyProtocolSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	switch len(args) {
+	case 0:
+		return backendProxyProtocolFilter{version: 1}, nil
+	case 1:
+		v, ok := args[0].(float64)
+		if !ok || (v != 1 && v != 2) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return backendProxyProtocolFilter{version: int(v)}, nil
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (f backendProxyProtocolFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendProxyProtocolKey] = f.version
+}
+
+func (backendProxyProtocolFilter) Response(filters.FilterContext) {}
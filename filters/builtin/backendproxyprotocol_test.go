@@ -0,0 +1,62 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendProxyProtocol(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		expected int
+		fail     bool
+	}{{
+		title:    "default version",
+		expected: 1,
+	}, {
+		title:    "version 2",
+		args:     []interface{}{float64(2)},
+		expected: 2,
+	}, {
+		title: "invalid version",
+		args:  []interface{}{float64(3)},
+		fail:  true,
+	}, {
+		title: "not a number",
+		args:  []interface{}{"2"},
+		fail:  true,
+	}, {
+		title: "too many args",
+		args:  []interface{}{float64(1), float64(2)},
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewBackendProxyProtocol().CreateFilter(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  &http.Request{},
+				FStateBag: map[string]interface{}{},
+			}
+
+			f.Request(ctx)
+			if v, ok := ctx.FStateBag[filters.BackendProxyProtocolKey].(int); !ok || v != test.expected {
+				t.Errorf("invalid version, expected: %d, got: %v", test.expected, ctx.FStateBag[filters.BackendProxyProtocolKey])
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package builtin
+
+import (
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type backendTimeoutSpec struct {
+	name string
+	key  string
+}
+
+type backendTimeoutFilter struct {
+	key     string
+	timeout time.Duration
+}
+
+// NewBackendTimeout returns a filter specification that sets the timeout of
+// the backend requests of a route, including reading the response body,
+// overriding the proxy defaults. The timeout is set as a duration string,
+// e.g. backendTimeout("10s"), or in milliseconds.
+func NewBackendTimeout() filters.Spec {
+	return &backendTimeoutSpec{name: BackendTimeoutName, key: filters.BackendTimeoutKey}
+}
+
+// NewBackendDialTimeout returns a filter specification that sets the
+// timeout of establishing the connections to the backend of a route,
+// overriding the proxy defaults.
+func NewBackendDialTimeout() filters.Spec {
+	return &backendTimeoutSpec{name: BackendDialTimeoutName, key: filters.BackendDialTimeoutKey}
+}
+
+// NewBackendTLSHandshakeTimeout returns a filter specification that sets
+// the timeout of the TLS handshake with the backend of a route, overriding
+// the proxy defaults.
+func NewBackendTLSHandshakeTimeout() filters.Spec {
+	return &backendTimeoutSpec{name: BackendTLSHandshakeTimeoutName, key: filters.BackendTLSHandshakeTimeoutKey}
+}
+
+// NewBackendResponseHeaderTimeout returns a filter specification that sets
+// how long the response headers of the backend of a route are waited for,
+// after the request was sent, overriding the proxy defaults.
+func NewBackendResponseHeaderTimeout() filters.Spec {
+	return &backendTimeoutSpec{name: BackendResponseHeaderTimeoutName, key: filters.BackendResponseHeaderTimeoutKey}
+}
+
+func (s *backendTimeoutSpec) Name() string { return s.name }
+
+func (s *backendTimeoutSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var d time.Duration
+	switch a := args[0].(type) {
+	case string:
+		var err error
+		if d, err = time.ParseDuration(a); err != nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	case float64:
+		d = time.Duration(a) * time.Millisecond
+	case int:
+		d = time.Duration(a) * time.Millisecond
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if d <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &backendTimeoutFilter{key: s.key, timeout: d}, nil
+}
+
+func (f *backendTimeoutFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[f.key] = f.timeout
+}
+
+func (f *backendTimeoutFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendTimeout(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		spec     filters.Spec
+		args     []interface{}
+		key      string
+		expected time.Duration
+		fail     bool
+	}{{
+		title: "no args",
+		spec:  NewBackendTimeout(),
+		fail:  true,
+	}, {
+		title: "too many args",
+		spec:  NewBackendTimeout(),
+		args:  []interface{}{"1s", "2s"},
+		fail:  true,
+	}, {
+		title: "invalid duration",
+		spec:  NewBackendTimeout(),
+		args:  []interface{}{"foo"},
+		fail:  true,
+	}, {
+		title: "negative duration",
+		spec:  NewBackendTimeout(),
+		args:  []interface{}{"-1s"},
+		fail:  true,
+	}, {
+		title:    "duration string",
+		spec:     NewBackendTimeout(),
+		args:     []interface{}{"10s"},
+		key:      filters.BackendTimeoutKey,
+		expected: 10 * time.Second,
+	}, {
+		title:    "milliseconds",
+		spec:     NewBackendDialTimeout(),
+		args:     []interface{}{float64(300)},
+		key:      filters.BackendDialTimeoutKey,
+		expected: 300 * time.Millisecond,
+	}, {
+		title:    "TLS handshake",
+		spec:     NewBackendTLSHandshakeTimeout(),
+		args:     []interface{}{"2s"},
+		key:      filters.BackendTLSHandshakeTimeoutKey,
+		expected: 2 * time.Second,
+	}, {
+		title:    "response header",
+		spec:     NewBackendResponseHeaderTimeout(),
+		args:     []interface{}{"1m"},
+		key:      filters.BackendResponseHeaderTimeoutKey,
+		expected: time.Minute,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := test.spec.CreateFilter(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  &http.Request{},
+				FStateBag: map[string]interface{}{},
+			}
+
+			f.Request(ctx)
+			if d, ok := ctx.FStateBag[test.key].(time.Duration); !ok || d != test.expected {
+				t.Errorf("invalid timeout, expected: %v, got: %v", test.expected, ctx.FStateBag[test.key])
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/accesslog"
 	"github.com/zalando/skipper/filters/auth"
+	"github.com/zalando/skipper/filters/cache"
 	"github.com/zalando/skipper/filters/circuit"
 	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/filters/cors"
@@ -33,6 +34,12 @@ const (
 	// Deprecated: use redirectTo
 	RedirectName = "redirect"
 
+	// Deprecated: use copyRequestHeader
+	RequestCopyHeaderName = "requestCopyHeader"
+
+	// Deprecated: use copyResponseHeader
+	ResponseCopyHeaderName = "responseCopyHeader"
+
 	SetRequestHeaderName            = "setRequestHeader"
 	SetResponseHeaderName           = "setResponseHeader"
 	AppendRequestHeaderName         = "appendRequestHeader"
@@ -43,6 +50,8 @@ const (
 	AppendContextRequestHeaderName  = "appendContextRequestHeader"
 	SetContextResponseHeaderName    = "setContextResponseHeader"
 	AppendContextResponseHeaderName = "appendContextResponseHeader"
+	CopyRequestHeaderName           = "copyRequestHeader"
+	CopyResponseHeaderName          = "copyResponseHeader"
 
 	SetDynamicBackendHostFromHeader   = "setDynamicBackendHostFromHeader"
 	SetDynamicBackendSchemeFromHeader = "setDynamicBackendSchemeFromHeader"
@@ -61,16 +70,35 @@ const (
 	StripQueryName            = "stripQuery"
 	PreserveHostName          = "preserveHost"
 	SetFastCgiFilenameName    = "setFastCgiFilename"
+	SetFastCgiParamName       = "setFastCgiParam"
 	StatusName                = "status"
 	CompressName              = "compress"
+	DecompressRequestName     = "decompressRequest"
 	SetQueryName              = "setQuery"
 	DropQueryName             = "dropQuery"
 	InlineContentName         = "inlineContent"
 	InlineContentIfStatusName = "inlineContentIfStatus"
+	PrependContentName        = "prependContent"
+	AppendContentName         = "appendContent"
+	PrependRequestContentName = "prependRequestContent"
+	AppendRequestContentName  = "appendRequestContent"
 	HeaderToQueryName         = "headerToQuery"
 	QueryToHeaderName         = "queryToHeader"
+	SecurityHeadersName       = "securityHeaders"
+
+	BackendTimeoutName               = "backendTimeout"
+	BackendDialTimeoutName           = "backendDialTimeout"
+	BackendTLSHandshakeTimeoutName   = "backendTLSHandshakeTimeout"
+	BackendResponseHeaderTimeoutName = "backendResponseHeaderTimeout"
+	BackendProxyProtocolName         = "backendProxyProtocol"
+	EgressProxyName                  = "egressProxy"
+	MaxRequestBodySizeName           = "maxRequestBodySize"
 )
 
+// filter specifications that are registered only when skipper is built
+// with the corresponding build tags
+var optionalSpecs []filters.Spec
+
 // Returns a Registry object initialized with the default set of filter
 // specifications found in the filters package. (including the builtin
 // and the flowid subdirectories.)
@@ -78,6 +106,13 @@ func MakeRegistry() filters.Registry {
 	r := make(filters.Registry)
 	for _, s := range []filters.Spec{
 		NewBackendIsProxy(),
+		NewBackendTimeout(),
+		NewBackendDialTimeout(),
+		NewBackendTLSHandshakeTimeout(),
+		NewBackendResponseHeaderTimeout(),
+		NewBackendProxyProtocol(),
+		NewEgressProxy(),
+		NewMaxRequestBodySize(),
 		NewRequestHeader(),
 		NewSetRequestHeader(),
 		NewAppendRequestHeader(),
@@ -103,18 +138,28 @@ func MakeRegistry() filters.Registry {
 		NewStripQuery(),
 		NewInlineContent(),
 		NewInlineContentIfStatus(),
+		NewPrependContent(),
+		NewAppendContent(),
+		NewPrependRequestContent(),
+		NewAppendRequestContent(),
 		flowid.New(),
+		flowid.NewRequestId(),
 		xforward.New(),
 		xforward.NewFirst(),
 		PreserveHost(),
 		NewSetFastCgiFilename(),
+		NewSetFastCgiParam(),
 		NewStatus(),
 		NewCompress(),
 		NewDecompress(),
+		NewDecompressRequest(),
 		NewCopyRequestHeader(),
 		NewCopyResponseHeader(),
+		NewRequestCopyHeader(),
+		NewResponseCopyHeader(),
 		NewHeaderToQuery(),
 		NewQueryToHeader(),
+		NewSecurityHeaders(),
 		NewSetDynamicBackendHostFromHeader(),
 		NewSetDynamicBackendSchemeFromHeader(),
 		NewSetDynamicBackendUrlFromHeader(),
@@ -131,6 +176,11 @@ func MakeRegistry() filters.Registry {
 		diag.NewBackendChunks(),
 		diag.NewAbsorb(),
 		diag.NewLogHeader(),
+		diag.NewFaultStatus(),
+		diag.NewFaultDrop(),
+		diag.NewTarpit(),
+		diag.NewTeapot(),
+		cache.NewCoalesce(),
 		tee.NewTee(),
 		tee.NewTeeDeprecated(),
 		tee.NewTeeNoFollow(),
@@ -142,6 +192,8 @@ func MakeRegistry() filters.Registry {
 		cookie.NewRequestCookie(),
 		cookie.NewResponseCookie(),
 		cookie.NewJSCookie(),
+		cookie.NewDropRequestCookie(),
+		cookie.NewDropResponseCookie(),
 		circuit.NewConsecutiveBreaker(),
 		circuit.NewRateBreaker(),
 		circuit.NewDisableBreaker(),
@@ -168,5 +220,9 @@ func MakeRegistry() filters.Registry {
 		r.Register(s)
 	}
 
+	for _, s := range optionalSpecs {
+		r.Register(s)
+	}
+
 	return r
 }
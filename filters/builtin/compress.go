@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"bytes"
 	"compress/flate"
 	"compress/gzip"
 	"errors"
@@ -26,8 +27,9 @@ type encoding struct {
 type encodings []*encoding
 
 type compress struct {
-	mime  []string
-	level int
+	mime    []string
+	level   int
+	minSize int
 }
 
 type encoder interface {
@@ -110,6 +112,14 @@ func (e encodings) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
 //
 // 	* -> compress(9, "image/tiff") -> "https://www.example.org"
 //
+// The minimum size of the compressed responses in bytes can be set as the
+// second numeric argument, after the compression level. Smaller responses
+// are returned uncompressed. When the Content-Length of the response is not
+// known, the filter reads ahead the response body up to the minimum size.
+// Example:
+//
+// 	* -> compress(1, 1024) -> "https://www.example.org"
+//
 // The filter also checks the incoming request, if it accepts the supported
 // encodings, explicitly stated in the Accept-Encoding header. The filter currently
 // supports gzip and deflate. It does not assume that the client accepts any
@@ -151,6 +161,18 @@ func (c *compress) CreateFilter(args []interface{}) (filters.Filter, error) {
 		}
 
 		args = args[1:]
+		if len(args) == 0 {
+			return f, nil
+		}
+
+		if ms, ok := args[0].(float64); ok {
+			if ms < 0 || math.Trunc(ms) != ms {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.minSize = int(ms)
+			args = args[1:]
+		}
 	}
 
 	if len(args) == 0 {
@@ -212,6 +234,41 @@ func canEncodeEntity(r *http.Response, mime []string) bool {
 	return true
 }
 
+// checks whether the response body reaches the minimum size. When the
+// content length is not known, it reads ahead the body up to the minimum
+// size, and puts back the read part.
+func reachesMinSize(r *http.Response, minSize int) bool {
+	if minSize <= 0 {
+		return true
+	}
+
+	if r.ContentLength >= 0 {
+		return r.ContentLength >= int64(minSize)
+	}
+
+	if cl, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64); err == nil {
+		return cl >= int64(minSize)
+	}
+
+	if r.Body == nil {
+		return false
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r.Body, int64(minSize))
+	r.Body = &readAheadBody{
+		Reader: io.MultiReader(&buf, r.Body),
+		Closer: r.Body,
+	}
+
+	return err == nil && n == int64(minSize)
+}
+
+type readAheadBody struct {
+	io.Reader
+	io.Closer
+}
+
 func acceptedEncoding(r *http.Request) string {
 	var encs encodings
 	for _, s := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
@@ -373,6 +430,10 @@ func (c *compress) Response(ctx filters.FilterContext) {
 		return
 	}
 
+	if !reachesMinSize(rsp, c.minSize) {
+		return
+	}
+
 	responseHeader(rsp, enc)
 	responseBody(rsp, enc, c.level)
 }
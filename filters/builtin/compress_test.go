@@ -185,6 +185,24 @@ func TestCompressArgs(t *testing.T) {
 		nil,
 		[]string{"x/custom-0", "x/custom-1"},
 		6,
+	}, {
+		"set level and min size",
+		[]interface{}{float64(6), float64(1024), "x/custom-0"},
+		nil,
+		[]string{"x/custom-0"},
+		6,
+	}, {
+		"negative min size",
+		[]interface{}{float64(6), float64(-1)},
+		filters.ErrInvalidFilterParameters,
+		nil,
+		0,
+	}, {
+		"non integer min size",
+		[]interface{}{float64(6), 3.14},
+		filters.ErrInvalidFilterParameters,
+		nil,
+		0,
 	}, {
 		"set level and extend mime",
 		[]interface{}{float64(6), "...", "x/custom-0", "x/custom-1"},
@@ -401,6 +419,29 @@ func TestCompress(t *testing.T) {
 		http.Header{
 			"Content-Encoding": []string{"gzip"},
 			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"min size reached",
+		http.Header{},
+		3 * 8192,
+		[]interface{}{float64(flate.BestSpeed), float64(8192)},
+		"gzip,deflate",
+		http.Header{
+			"Content-Encoding": []string{"gzip"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"below min size, unknown content length",
+		http.Header{},
+		3 * 8192,
+		[]interface{}{float64(flate.BestSpeed), float64(4 * 8192)},
+		"gzip,deflate",
+		http.Header{},
+	}, {
+		"below min size, known content length",
+		http.Header{"Content-Length": []string{strconv.Itoa(3 * 8192)}},
+		3 * 8192,
+		[]interface{}{float64(flate.BestSpeed), float64(4 * 8192)},
+		"gzip,deflate",
+		http.Header{"Content-Length": []string{strconv.Itoa(3 * 8192)}},
 	}, {
 		"encodes large body",
 		http.Header{},
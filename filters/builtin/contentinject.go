@@ -0,0 +1,148 @@
+package builtin
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type injectContentType int
+
+const (
+	prependResponse injectContentType = iota
+	appendResponse
+	prependRequest
+	appendRequest
+)
+
+type injectContent struct {
+	typ  injectContentType
+	text string
+}
+
+type injectedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// NewPrependContent creates a filter specification for the prependContent()
+// filter, that inserts the text given as the argument in front of the
+// response body. The body is not buffered.
+func NewPrependContent() filters.Spec { return &injectContent{typ: prependResponse} }
+
+// NewAppendContent creates a filter specification for the appendContent()
+// filter, that appends the text given as the argument to the response body.
+// The body is not buffered.
+func NewAppendContent() filters.Spec { return &injectContent{typ: appendResponse} }
+
+// NewPrependRequestContent creates a filter specification for the
+// prependRequestContent() filter, the same as prependContent(), but for the
+// request body.
+func NewPrependRequestContent() filters.Spec { return &injectContent{typ: prependRequest} }
+
+// NewAppendRequestContent creates a filter specification for the
+// appendRequestContent() filter, the same as appendContent(), but for the
+// request body.
+func NewAppendRequestContent() filters.Spec { return &injectContent{typ: appendRequest} }
+
+func (spec *injectContent) Name() string {
+	switch spec.typ {
+	case appendResponse:
+		return AppendContentName
+	case prependRequest:
+		return PrependRequestContentName
+	case appendRequest:
+		return AppendRequestContentName
+	default:
+		return PrependContentName
+	}
+}
+
+func (spec *injectContent) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	text, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &injectContent{typ: spec.typ, text: text}, nil
+}
+
+// injects the text into the body. When the length of the original body is
+// known, the content length is updated, otherwise the body is sent chunked.
+func (f *injectContent) inject(h http.Header, body io.ReadCloser, contentLength int64) (io.ReadCloser, int64) {
+	if body == nil {
+		body = ioutil.NopCloser(strings.NewReader(""))
+		contentLength = 0
+	}
+
+	var r io.Reader
+	switch f.typ {
+	case prependResponse, prependRequest:
+		r = io.MultiReader(strings.NewReader(f.text), body)
+	default:
+		r = io.MultiReader(body, strings.NewReader(f.text))
+	}
+
+	if contentLength < 0 {
+		h.Del("Content-Length")
+	} else {
+		contentLength += int64(len(f.text))
+		h.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+
+	return injectedBody{Reader: r, Closer: body}, contentLength
+}
+
+// the encoded content, e.g. gzip, cannot be modified without decoding it,
+// so it is left unchanged
+func isEncoded(h http.Header) bool {
+	ce := h.Get("Content-Encoding")
+	return ce != "" && !strings.EqualFold(ce, "identity")
+}
+
+// the responses to HEAD requests, and with the status 1xx, 204 or 304,
+// don't have a body
+func isBodyless(req *http.Request, rsp *http.Response) bool {
+	return req.Method == http.MethodHead ||
+		rsp.StatusCode < http.StatusOK ||
+		rsp.StatusCode == http.StatusNoContent ||
+		rsp.StatusCode == http.StatusNotModified
+}
+
+func (f *injectContent) Request(ctx filters.FilterContext) {
+	if f.typ != prependRequest && f.typ != appendRequest {
+		return
+	}
+
+	req := ctx.Request()
+	if isEncoded(req.Header) {
+		return
+	}
+
+	if req.Body == http.NoBody {
+		req.Body = nil
+	}
+
+	req.Body, req.ContentLength = f.inject(req.Header, req.Body, req.ContentLength)
+}
+
+func (f *injectContent) Response(ctx filters.FilterContext) {
+	if f.typ != prependResponse && f.typ != appendResponse {
+		return
+	}
+
+	rsp := ctx.Response()
+	if isBodyless(ctx.Request(), rsp) || isEncoded(rsp.Header) {
+		return
+	}
+
+	rsp.Body, rsp.ContentLength = f.inject(rsp.Header, rsp.Body, rsp.ContentLength)
+}
@@ -0,0 +1,185 @@
+package builtin
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestInjectContentArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{42.0},
+		{"foo", "bar"},
+	} {
+		if _, err := NewPrependContent().CreateFilter(args); err != filters.ErrInvalidFilterParameters {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
+
+func TestInjectContent(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	for _, test := range []struct {
+		title    string
+		filters  []*eskip.Filter
+		path     string
+		body     string
+		expected string
+	}{{
+		title:    "prepend",
+		filters:  []*eskip.Filter{{Name: PrependContentName, Args: []interface{}{"<!-- header -->"}}},
+		body:     "<html></html>",
+		expected: "<!-- header --><html></html>",
+	}, {
+		title:    "append",
+		filters:  []*eskip.Filter{{Name: AppendContentName, Args: []interface{}{"<!-- footer -->"}}},
+		body:     "<html></html>",
+		expected: "<html></html><!-- footer -->",
+	}, {
+		title:    "append, chunked",
+		filters:  []*eskip.Filter{{Name: AppendContentName, Args: []interface{}{"<!-- footer -->"}}},
+		path:     "/chunked",
+		body:     "<html></html>",
+		expected: "<html></html><!-- footer -->",
+	}, {
+		title: "request",
+		filters: []*eskip.Filter{
+			{Name: PrependRequestContentName, Args: []interface{}{"["}},
+			{Name: AppendRequestContentName, Args: []interface{}{"]"}},
+		},
+		body:     `{"foo": "bar"}`,
+		expected: `[{"foo": "bar"}]`,
+	}, {
+		title:    "empty request",
+		filters:  []*eskip.Filter{{Name: AppendRequestContentName, Args: []interface{}{"foo=bar"}}},
+		expected: "foo=bar",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p := proxytest.New(MakeRegistry(), &eskip.Route{Filters: test.filters, Backend: backend.URL})
+			defer p.Close()
+
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+
+			rsp, err := http.Post(p.URL+test.path, "text/plain", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.expected {
+				t.Errorf("invalid content, expected: %s, got: %s", test.expected, string(b))
+			}
+
+			if rsp.ContentLength >= 0 && rsp.ContentLength != int64(len(test.expected)) {
+				t.Errorf("invalid content length: %d", rsp.ContentLength)
+			}
+		})
+	}
+}
+
+func TestInjectContentSkipped(t *testing.T) {
+	const html = "<html></html>"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			gw.Write([]byte(html))
+			gw.Close()
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("Content-Length", "13")
+			if r.Method != "HEAD" {
+				w.Write([]byte(html))
+			}
+		}
+	}))
+	defer backend.Close()
+
+	p := proxytest.New(MakeRegistry(), &eskip.Route{
+		Filters: []*eskip.Filter{{Name: AppendContentName, Args: []interface{}{"<!-- footer -->"}}},
+		Backend: backend.URL,
+	})
+	defer p.Close()
+
+	for _, test := range []struct {
+		title         string
+		method        string
+		path          string
+		expected      string
+		contentLength int64
+	}{{
+		title:         "gzip",
+		method:        "GET",
+		path:          "/gzip",
+		expected:      html,
+		contentLength: -1,
+	}, {
+		title:  "no content",
+		method: "GET",
+		path:   "/no-content",
+	}, {
+		title:  "not modified",
+		method: "GET",
+		path:   "/not-modified",
+	}, {
+		title:         "head",
+		method:        "HEAD",
+		contentLength: 13,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, p.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.expected {
+				t.Errorf("invalid content, expected: %s, got: %s", test.expected, string(b))
+			}
+
+			if rsp.ContentLength != test.contentLength {
+				t.Errorf("invalid content length, expected: %d, got: %d", test.contentLength, rsp.ContentLength)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package builtin
 import "github.com/zalando/skipper/filters"
 
 const (
-	requestCopyFilterName  = "requestCopyHeader"
-	responseCopyFilterName = "responseCopyHeader"
 	// CopyRequestHeader copies a request header to another proxy
 	// request header
 	CopyRequestHeader direction = iota
@@ -30,7 +28,17 @@ type copyFilter struct {
 func NewCopyRequestHeader() filters.Spec {
 	return &copySpec{
 		typ:        CopyRequestHeader,
-		filterName: requestCopyFilterName,
+		filterName: CopyRequestHeaderName,
+	}
+}
+
+// NewRequestCopyHeader creates a filter specification with the same
+// behavior as NewCopyRequestHeader, with the deprecated name
+// requestCopyHeader.
+func NewRequestCopyHeader() filters.Spec {
+	return &copySpec{
+		typ:        CopyRequestHeader,
+		filterName: RequestCopyHeaderName,
 	}
 }
 
@@ -40,7 +48,17 @@ func NewCopyRequestHeader() filters.Spec {
 func NewCopyResponseHeader() filters.Spec {
 	return &copySpec{
 		typ:        CopyResponseHeader,
-		filterName: responseCopyFilterName,
+		filterName: CopyResponseHeaderName,
+	}
+}
+
+// NewResponseCopyHeader creates a filter specification with the same
+// behavior as NewCopyResponseHeader, with the deprecated name
+// responseCopyHeader.
+func NewResponseCopyHeader() filters.Spec {
+	return &copySpec{
+		typ:        CopyResponseHeader,
+		filterName: ResponseCopyHeaderName,
 	}
 }
 
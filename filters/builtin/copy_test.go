@@ -18,7 +18,7 @@ func TestNewCopyRequestHeader(t *testing.T) {
 			name: "test copy request header constructor",
 			want: &copySpec{
 				typ:        CopyRequestHeader,
-				filterName: requestCopyFilterName,
+				filterName: CopyRequestHeaderName,
 			},
 		},
 	}
@@ -40,7 +40,7 @@ func TestNewCopyResponseHeader(t *testing.T) {
 			name: "test copy response header constructor",
 			want: &copySpec{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
 		},
 	}
@@ -53,6 +53,16 @@ func TestNewCopyResponseHeader(t *testing.T) {
 	}
 }
 
+func TestDeprecatedCopyHeaderNames(t *testing.T) {
+	if name := NewRequestCopyHeader().Name(); name != RequestCopyHeaderName {
+		t.Errorf("invalid name: %s", name)
+	}
+
+	if name := NewResponseCopyHeader().Name(); name != ResponseCopyHeaderName {
+		t.Errorf("invalid name: %s", name)
+	}
+}
+
 func Test_copySpec_Name(t *testing.T) {
 	type fields struct {
 		typ        direction
@@ -67,16 +77,16 @@ func Test_copySpec_Name(t *testing.T) {
 			name: "test response copy filter name",
 			fields: fields{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
-			want: responseCopyFilterName,
+			want: CopyResponseHeaderName,
 		}, {
 			name: "test request copy filter name",
 			fields: fields{
 				typ:        CopyRequestHeader,
-				filterName: requestCopyFilterName,
+				filterName: CopyRequestHeaderName,
 			},
-			want: requestCopyFilterName,
+			want: CopyRequestHeaderName,
 		},
 	}
 	for _, tt := range tests {
@@ -111,7 +121,7 @@ func Test_copySpec_CreateFilter(t *testing.T) {
 			name: "test request copy filter create filter",
 			fields: fields{
 				typ:        CopyRequestHeader,
-				filterName: requestCopyFilterName,
+				filterName: CopyRequestHeaderName,
 			},
 			args: args{[]interface{}{"X-Src", "X-Dst"}},
 			want: &copyFilter{
@@ -124,7 +134,7 @@ func Test_copySpec_CreateFilter(t *testing.T) {
 			name: "test response copy filter create filter",
 			fields: fields{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
 			args: args{[]interface{}{"X-Src", "X-Dst"}},
 			want: &copyFilter{
@@ -137,7 +147,7 @@ func Test_copySpec_CreateFilter(t *testing.T) {
 			name: "test wrong args create filter",
 			fields: fields{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
 			args:    args{[]interface{}{5, "X-Dst"}},
 			want:    nil,
@@ -146,7 +156,7 @@ func Test_copySpec_CreateFilter(t *testing.T) {
 			name: "test wrong args 2 create filter",
 			fields: fields{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
 			args:    args{[]interface{}{"X-Dst", 5}},
 			want:    nil,
@@ -155,7 +165,7 @@ func Test_copySpec_CreateFilter(t *testing.T) {
 			name: "test wrong args 3 create filter",
 			fields: fields{
 				typ:        CopyResponseHeader,
-				filterName: responseCopyFilterName,
+				filterName: CopyResponseHeaderName,
 			},
 			args:    args{[]interface{}{"X-foo"}},
 			want:    nil,
@@ -3,6 +3,7 @@ package builtin
 import (
 	"compress/flate"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -229,3 +230,101 @@ func (d decompress) Response(ctx filters.FilterContext) {
 
 	rsp.Body = b
 }
+
+type decompressRequest struct{}
+
+var errDecompressedBodyTooLarge = errors.New("decompressed request body too large")
+
+// limitedDecodedBody fails the reading of a decompressed request body,
+// when it exceeds the maximum request body size. The limit is taken from
+// the state bag when the body is first read, after all the request
+// filters were executed.
+type limitedDecodedBody struct {
+	io.ReadCloser
+	bag       map[string]interface{}
+	limit     int64
+	read      int64
+	limitRead bool
+}
+
+func (b *limitedDecodedBody) Read(p []byte) (int, error) {
+	if !b.limitRead {
+		b.limit, _ = b.bag[filters.MaxRequestBodySizeKey].(int64)
+		b.limitRead = true
+	}
+
+	if b.limit > 0 && b.read > b.limit {
+		return 0, errDecompressedBodyTooLarge
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+
+	// the bytes over the limit are returned, too, so that the proxy can
+	// detect the exceeded limit of the forwarded body
+	if b.limit > 0 && b.read > b.limit {
+		return n, errDecompressedBodyTooLarge
+	}
+
+	return n, err
+}
+
+// NewDecompressRequest creates a filter specification for the
+// decompressRequest() filter. The filter attempts to decompress the request
+// body, if it was compressed with any of deflate or gzip, so that the
+// subsequent filters and the backend receive the plain content, e.g.
+// sedRequest(). The body is not compressed again.
+//
+// The size of the decompressed body is limited by the maximum request body
+// size of the proxy, or of the route, when set with maxRequestBodySize().
+// When the limit is exceeded, reading the body fails, and the proxy
+// responds with 413 Request Entity Too Large.
+//
+// If decompression is not possible, because the encoding is not supported,
+// it indicates it with the "filter::decompress::not-possible" key in the
+// state-bag, and forwards the request unchanged. If the decompression fails
+// to get initialized, it responds with 400 Bad Request.
+//
+// The filter does not need any parameters.
+//
+func NewDecompressRequest() filters.Spec {
+	return decompressRequest{}
+}
+
+func (d decompressRequest) Name() string { return DecompressRequestName }
+
+func (d decompressRequest) CreateFilter([]interface{}) (filters.Filter, error) {
+	return d, nil
+}
+
+func (d decompressRequest) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+
+	encs := getEncodings(req.Header.Get("Content-Encoding"))
+	if len(encs) == 0 {
+		return
+	}
+
+	if !encodingsSupported(encs) {
+		ctx.StateBag()[DecompressionNotPossible] = true
+		return
+	}
+
+	b, err := newDecodedBody(req.Body, encs)
+	if err != nil {
+		sb := ctx.StateBag()
+		sb[DecompressionNotPossible] = true
+		sb[DecompressionError] = err
+
+		log.Errorf("Error while initializing request decompression: %v", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	req.Body = &limitedDecodedBody{ReadCloser: b, bag: ctx.StateBag()}
+}
+
+func (d decompressRequest) Response(filters.FilterContext) {}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/proxy/proxytest"
 )
 
@@ -241,3 +242,182 @@ func TestDecompress(t *testing.T) {
 		}
 	})
 }
+
+func TestDecompressRequest(t *testing.T) {
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+		io.Copy(w, r.Body)
+	}))
+	defer b.Close()
+
+	r, err := eskip.Parse(fmt.Sprintf(`* -> decompressRequest() -> "%s"`, b.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(NewDecompressRequest())
+	p := proxytest.New(fr, r[0])
+	defer p.Close()
+
+	for _, test := range []struct {
+		title            string
+		encoding         string
+		body             io.Reader
+		expectedStatus   int
+		expectedEncoding string
+		expectedContent  string
+	}{{
+		title:           "not compressed",
+		body:            strings.NewReader("Hello, world!"),
+		expectedStatus:  http.StatusOK,
+		expectedContent: "Hello, world!",
+	}, {
+		title:            "cannot decompress",
+		encoding:         "br",
+		body:             bytes.NewReader([]byte{1, 2, 3}),
+		expectedStatus:   http.StatusOK,
+		expectedEncoding: "br",
+		expectedContent:  string([]byte{1, 2, 3}),
+	}, {
+		title:          "invalid content",
+		encoding:       "gzip",
+		body:           bytes.NewReader([]byte{1, 2, 3}),
+		expectedStatus: http.StatusBadRequest,
+	}, {
+		title:           "deflate",
+		encoding:        "deflate",
+		body:            compressedBody(t, strings.NewReader("Hello, world!"), "deflate"),
+		expectedStatus:  http.StatusOK,
+		expectedContent: "Hello, world!",
+	}, {
+		title:           "gzip",
+		encoding:        "gzip",
+		body:            compressedBody(t, strings.NewReader("Hello, world!"), "gzip"),
+		expectedStatus:  http.StatusOK,
+		expectedContent: "Hello, world!",
+	}, {
+		title:           "multiple encodings",
+		encoding:        "gzip, deflate",
+		body:            compressedBody(t, compressedBody(t, strings.NewReader("Hello, world!"), "gzip"), "deflate"),
+		expectedStatus:  http.StatusOK,
+		expectedContent: "Hello, world!",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest("POST", p.URL, test.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.encoding != "" {
+				req.Header.Set("Content-Encoding", test.encoding)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedStatus {
+				t.Fatalf("invalid status, expected: %d, got: %d", test.expectedStatus, rsp.StatusCode)
+			}
+
+			if rsp.StatusCode != http.StatusOK {
+				return
+			}
+
+			if e := rsp.Header.Get("X-Content-Encoding"); e != test.expectedEncoding {
+				t.Errorf("invalid content encoding, expected: %s, got: %s", test.expectedEncoding, e)
+			}
+
+			content, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != test.expectedContent {
+				t.Errorf("invalid content, expected: %s, got: %s", test.expectedContent, string(content))
+			}
+		})
+	}
+}
+
+func TestDecompressRequestLimit(t *testing.T) {
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer b.Close()
+
+	r, err := eskip.Parse(fmt.Sprintf(`
+		default: Path("/default") -> decompressRequest() -> "%s";
+		route: Path("/route") -> maxRequestBodySize(64) -> decompressRequest() -> "%s";
+	`, b.URL, b.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(NewDecompressRequest())
+	fr.Register(NewMaxRequestBodySize())
+	p := proxytest.WithParams(fr, proxy.Params{MaxRequestBodySize: 128}, r...)
+	defer p.Close()
+
+	for _, test := range []struct {
+		title          string
+		path           string
+		content        string
+		expectedStatus int
+	}{{
+		title:          "within the default limit",
+		path:           "/default",
+		content:        strings.Repeat("a", 128),
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "exceeds the default limit",
+		path:           "/default",
+		content:        strings.Repeat("a", 1<<20),
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		title:          "within the route limit",
+		path:           "/route",
+		content:        strings.Repeat("a", 64),
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "exceeds the route limit",
+		path:           "/route",
+		content:        strings.Repeat("a", 65),
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest("POST", p.URL+test.path, compressedBody(t, strings.NewReader(test.content), "gzip"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Content-Encoding", "gzip")
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedStatus {
+				t.Fatalf("invalid status, expected: %d, got: %d", test.expectedStatus, rsp.StatusCode)
+			}
+
+			if rsp.StatusCode != http.StatusOK {
+				return
+			}
+
+			content, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != test.content {
+				t.Error("invalid content")
+			}
+		})
+	}
+}
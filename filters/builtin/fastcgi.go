@@ -45,3 +45,47 @@ func (s setFastCgiFilenameSpec) Response(_ filters.FilterContext) {}
 func (s setFastCgiFilenameSpec) Request(ctx filters.FilterContext) {
 	ctx.StateBag()["fastCgiFilename"] = s.fileName
 }
+
+type setFastCgiParamSpec struct{}
+
+type setFastCgiParamFilter struct {
+	name, value string
+}
+
+// NewSetFastCgiParam returns a filter spec that sets an additional param
+// of the requests to the FastCGI backends, e.g. to map the script filename
+// or to pass application specific variables. The params set this way
+// override the params derived from the incoming request.
+func NewSetFastCgiParam() filters.Spec { return setFastCgiParamSpec{} }
+
+func (setFastCgiParamSpec) Name() string { return SetFastCgiParamName }
+
+func (setFastCgiParamSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	value, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return setFastCgiParamFilter{name: name, value: value}, nil
+}
+
+func (f setFastCgiParamFilter) Request(ctx filters.FilterContext) {
+	params, _ := ctx.StateBag()[filters.FastCgiParamsKey].(map[string]string)
+	if params == nil {
+		params = make(map[string]string)
+		ctx.StateBag()[filters.FastCgiParamsKey] = params
+	}
+
+	params[f.name] = f.value
+}
+
+func (setFastCgiParamFilter) Response(filters.FilterContext) {}
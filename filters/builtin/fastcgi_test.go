@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestSetFastCgiParam(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "param",
+		args:  []interface{}{"SCRIPT_FILENAME", "/var/www/app/index.php"},
+	}, {
+		title: "empty value",
+		args:  []interface{}{"HTTPS", ""},
+	}, {
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "missing value",
+		args:  []interface{}{"HTTPS"},
+		fail:  true,
+	}, {
+		title: "empty name",
+		args:  []interface{}{"", "on"},
+		fail:  true,
+	}, {
+		title: "not a string",
+		args:  []interface{}{"APP_ENV", float64(42)},
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewSetFastCgiParam().CreateFilter(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  &http.Request{},
+				FStateBag: map[string]interface{}{},
+			}
+
+			f.Request(ctx)
+			params, _ := ctx.FStateBag[filters.FastCgiParamsKey].(map[string]string)
+			if v, ok := params[test.args[0].(string)]; !ok || v != test.args[1] {
+				t.Errorf("invalid param, expected: %v, got: %v", test.args[1], v)
+			}
+		})
+	}
+}
+
+func TestSetFastCgiParamMultiple(t *testing.T) {
+	ctx := &filtertest.Context{
+		FRequest:  &http.Request{},
+		FStateBag: map[string]interface{}{},
+	}
+
+	for _, args := range [][]interface{}{
+		{"APP_ENV", "test"},
+		{"HTTPS", "on"},
+		{"APP_ENV", "production"},
+	} {
+		f, err := NewSetFastCgiParam().CreateFilter(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.Request(ctx)
+	}
+
+	params := ctx.FStateBag[filters.FastCgiParamsKey].(map[string]string)
+	if len(params) != 2 || params["APP_ENV"] != "production" || params["HTTPS"] != "on" {
+		t.Errorf("invalid params: %v", params)
+	}
+}
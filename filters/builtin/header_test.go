@@ -147,6 +147,24 @@ func TestHeader(t *testing.T) {
 			valid:          true,
 			requestHeader:  http.Header{"X-Test-Name": []string{"value0", "value1"}},
 			expectedHeader: http.Header{"X-Test-Request-Name": []string{"value"}},
+		}, {
+			msg:            "set request header from template",
+			filterName:     "setRequestHeader",
+			args:           []interface{}{"X-Test-Name", "${request.header.X-Test-Foo}-${request.method}"},
+			valid:          true,
+			requestHeader:  http.Header{"X-Test-Foo": []string{"bar"}},
+			expectedHeader: http.Header{"X-Test-Request-Foo": []string{"bar"}, "X-Test-Request-Name": []string{"bar-GET"}},
+		}, {
+			msg:        "set request header from unresolved template",
+			filterName: "setRequestHeader",
+			args:       []interface{}{"X-Test-Name", "${request.header.X-Test-Foo}"},
+			valid:      true,
+		}, {
+			msg:           "set request header from unresolved template removes the header",
+			filterName:    "setRequestHeader",
+			args:          []interface{}{"X-Test-Name", "${request.header.X-Test-Foo}"},
+			valid:         true,
+			requestHeader: http.Header{"X-Test-Name": []string{"value"}},
 		}, {
 			msg:        "set outgoing host on set",
 			filterName: "setRequestHeader",
@@ -173,6 +191,13 @@ func TestHeader(t *testing.T) {
 			args:       []interface{}{"Host", "www.example.org"},
 			valid:      true,
 			host:       "www.example.org",
+		}, {
+			msg:            "append request header from unresolved template",
+			filterName:     "appendRequestHeader",
+			args:           []interface{}{"X-Test-Name", "${request.header.X-Test-Foo}"},
+			valid:          true,
+			requestHeader:  http.Header{"X-Test-Name": []string{"value"}},
+			expectedHeader: http.Header{"X-Test-Request-Name": []string{"value"}},
 		}},
 		"dropRequestHeader": {{
 			msg:        "drop request header when none",
@@ -199,6 +224,12 @@ func TestHeader(t *testing.T) {
 			valid:          true,
 			responseHeader: http.Header{"X-Test-Name": []string{"value0", "value1"}},
 			expectedHeader: http.Header{"X-Test-Name": []string{"value"}},
+		}, {
+			msg:            "set response header from unresolved template removes the header",
+			filterName:     "setResponseHeader",
+			args:           []interface{}{"X-Test-Name", "${response.header.X-Test-Foo}"},
+			valid:          true,
+			responseHeader: http.Header{"X-Test-Name": []string{"value"}},
 		}},
 		"appendResponseHeader": {{
 			msg:            "append response header when none",
@@ -213,6 +244,13 @@ func TestHeader(t *testing.T) {
 			valid:          true,
 			responseHeader: http.Header{"X-Test-Name": []string{"value0", "value1"}},
 			expectedHeader: http.Header{"X-Test-Name": []string{"value0", "value1", "value"}},
+		}, {
+			msg:            "append response header from unresolved template",
+			filterName:     "appendResponseHeader",
+			args:           []interface{}{"X-Test-Name", "${response.header.X-Test-Foo}"},
+			valid:          true,
+			responseHeader: http.Header{"X-Test-Name": []string{"value"}},
+			expectedHeader: http.Header{"X-Test-Name": []string{"value"}},
 		}},
 		"dropResponseHeader": {{
 			msg:        "drop response header when none",
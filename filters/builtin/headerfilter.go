@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 )
 
@@ -30,6 +32,7 @@ const (
 type headerFilter struct {
 	typ        headerType
 	key, value string
+	template   *eskip.Template
 }
 
 // verifies that the filter config has two string parameters
@@ -75,6 +78,10 @@ func NewResponseHeader() filters.Spec {
 // Instances expect two parameters: the header name and the header value.
 // Name: "setRequestHeader".
 //
+// The header value can contain template placeholders, see
+// eskip.NewTemplate. When a placeholder cannot be resolved, the header is
+// removed.
+//
 // If the header name is 'Host', the filter uses the `SetOutgoingHost()`
 // method to set the header in addition to the standard `Request.Header`
 // map.
@@ -86,6 +93,10 @@ func NewSetRequestHeader() filters.Spec {
 // Instances expect two parameters: the header name and the header value.
 // Name: "appendRequestHeader".
 //
+// The header value can contain template placeholders, see
+// eskip.NewTemplate. When a placeholder cannot be resolved, the header is
+// left unchanged.
+//
 // If the header name is 'Host', the filter uses the `SetOutgoingHost()`
 // method to set the header in addition to the standard `Request.Header`
 // map.
@@ -183,7 +194,18 @@ func (spec *headerFilter) Name() string {
 //lint:ignore ST1016 "spec" makes sense here and we reuse the type for the filter
 func (spec *headerFilter) CreateFilter(config []interface{}) (filters.Filter, error) {
 	key, value, err := headerFilterConfig(spec.typ, config)
-	return &headerFilter{typ: spec.typ, key: key, value: value}, err
+	if err != nil {
+		return nil, err
+	}
+
+	f := &headerFilter{typ: spec.typ, key: key, value: value}
+	switch spec.typ {
+	case setRequestHeader, appendRequestHeader, depRequestHeader,
+		setResponseHeader, appendResponseHeader, depResponseHeader:
+		f.template = eskip.NewTemplate(value)
+	}
+
+	return f, nil
 }
 
 func valueFromContext(
@@ -205,17 +227,42 @@ func valueFromContext(
 	}
 }
 
+// evaluates the template of the header value. When a placeholder cannot
+// be resolved, the set filters remove the header, and the append filters
+// leave it unchanged, so that the unresolved placeholders are not sent
+// literally. This can happen on every request, e.g. when an optional
+// header is missing, so it is logged only on the debug level.
+func (f *headerFilter) templateValue(ctx filters.FilterContext) (string, bool) {
+	value, ok := f.template.ApplyContext(ctx)
+	if !ok {
+		log.Debugf("Unresolved placeholder in %s(%q, %q).", f.Name(), f.key, f.value)
+	}
+
+	return value, ok
+}
+
 func (f *headerFilter) Request(ctx filters.FilterContext) {
 	switch f.typ {
 	case setRequestHeader:
-		ctx.Request().Header.Set(f.key, f.value)
+		value, ok := f.templateValue(ctx)
+		if !ok {
+			ctx.Request().Header.Del(f.key)
+			return
+		}
+
+		ctx.Request().Header.Set(f.key, value)
 		if strings.ToLower(f.key) == "host" {
-			ctx.SetOutgoingHost(f.value)
+			ctx.SetOutgoingHost(value)
 		}
 	case appendRequestHeader, depRequestHeader:
-		ctx.Request().Header.Add(f.key, f.value)
+		value, ok := f.templateValue(ctx)
+		if !ok {
+			return
+		}
+
+		ctx.Request().Header.Add(f.key, value)
 		if strings.ToLower(f.key) == "host" {
-			ctx.SetOutgoingHost(f.value)
+			ctx.SetOutgoingHost(value)
 		}
 	case dropRequestHeader:
 		ctx.Request().Header.Del(f.key)
@@ -229,9 +276,15 @@ func (f *headerFilter) Request(ctx filters.FilterContext) {
 func (f *headerFilter) Response(ctx filters.FilterContext) {
 	switch f.typ {
 	case setResponseHeader:
-		ctx.Response().Header.Set(f.key, f.value)
+		if value, ok := f.templateValue(ctx); ok {
+			ctx.Response().Header.Set(f.key, value)
+		} else {
+			ctx.Response().Header.Del(f.key)
+		}
 	case appendResponseHeader, depResponseHeader:
-		ctx.Response().Header.Add(f.key, f.value)
+		if value, ok := f.templateValue(ctx); ok {
+			ctx.Response().Header.Add(f.key, value)
+		}
 	case dropResponseHeader:
 		ctx.Response().Header.Del(f.key)
 	case setContextResponseHeader:
@@ -0,0 +1,47 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+)
+
+type maxRequestBodySizeSpec struct{}
+
+type maxRequestBodySizeFilter struct {
+	size int64
+}
+
+// NewMaxRequestBodySize returns a filter specification that limits the size
+// of the request bodies of a route, in bytes, overriding the proxy
+// default. The requests with larger bodies are rejected with 413 Request
+// Entity Too Large. 0 disables the limit for the route.
+func NewMaxRequestBodySize() filters.Spec { return maxRequestBodySizeSpec{} }
+
+func (maxRequestBodySizeSpec) Name() string { return MaxRequestBodySizeName }
+
+func (maxRequestBodySizeSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var size int64
+	switch a := args[0].(type) {
+	case float64:
+		size = int64(a)
+	case int:
+		size = int64(a)
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if size < 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return maxRequestBodySizeFilter{size: size}, nil
+}
+
+func (f maxRequestBodySizeFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.MaxRequestBodySizeKey] = f.size
+}
+
+func (maxRequestBodySizeFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,66 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestMaxRequestBodySize(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		expected int64
+		fail     bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "too many args",
+		args:  []interface{}{float64(1024), float64(2048)},
+		fail:  true,
+	}, {
+		title: "not a number",
+		args:  []interface{}{"1024"},
+		fail:  true,
+	}, {
+		title: "negative size",
+		args:  []interface{}{float64(-1)},
+		fail:  true,
+	}, {
+		title:    "size",
+		args:     []interface{}{float64(1024)},
+		expected: 1024,
+	}, {
+		title:    "disabled",
+		args:     []interface{}{float64(0)},
+		expected: 0,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewMaxRequestBodySize().CreateFilter(test.args)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  &http.Request{},
+				FStateBag: map[string]interface{}{},
+			}
+
+			f.Request(ctx)
+			if s, ok := ctx.FStateBag[filters.MaxRequestBodySizeKey].(int64); !ok || s != test.expected {
+				t.Errorf("invalid size, expected: %d, got: %v", test.expected, ctx.FStateBag[filters.MaxRequestBodySizeKey])
+			}
+		})
+	}
+}
@@ -118,7 +118,7 @@ func (f *modPath) Request(ctx filters.FilterContext) {
 	case regexpReplace:
 		req.URL.Path = f.rx.ReplaceAllString(req.URL.Path, f.replacement)
 	case fullReplace:
-		req.URL.Path = f.template.Apply(ctx.PathParam)
+		req.URL.Path = applyTemplate(ctx, f.template)
 	default:
 		panic("unspecified behavior")
 	}
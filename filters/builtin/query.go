@@ -123,13 +123,13 @@ func (f *modQuery) Request(ctx filters.FilterContext) {
 
 	switch f.behavior {
 	case drop:
-		params.Del(f.name.Apply(ctx.PathParam))
+		params.Del(applyTemplate(ctx, f.name))
 	case set:
 		if f.value == nil {
-			req.URL.RawQuery = f.name.Apply(ctx.PathParam)
+			req.URL.RawQuery = applyTemplate(ctx, f.name)
 			return
 		} else {
-			params.Set(f.name.Apply(ctx.PathParam), f.value.Apply(ctx.PathParam))
+			params.Set(applyTemplate(ctx, f.name), applyTemplate(ctx, f.value))
 
 		}
 	default:
@@ -141,3 +141,9 @@ func (f *modQuery) Request(ctx filters.FilterContext) {
 
 // Noop.
 func (*modQuery) Response(filters.FilterContext) {}
+
+// evaluates a template, leaving the unresolved placeholders empty
+func applyTemplate(ctx filters.FilterContext, t *eskip.Template) string {
+	s, _ := t.ApplyContext(ctx)
+	return s
+}
@@ -19,6 +19,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 )
 
@@ -35,6 +36,7 @@ type redirect struct {
 	typ      redirectType
 	code     int
 	location *url.URL
+	template *eskip.Template
 }
 
 // NewRedirect returns a new filter Spec, whose instances create an HTTP redirect
@@ -50,7 +52,14 @@ func NewRedirect() filters.Spec { return &redirect{typ: redDeprecated} }
 // response. It shunts the request flow, meaning that the filter chain on
 // the request path is not continued. The request is not forwarded to the
 // backend. Instances expect two parameters: the redirect status code and
-// the redirect location.
+// the redirect location. The status code needs to be a 3xx code.
+//
+// The location can contain template placeholders, see eskip.NewTemplate,
+// e.g. "https://${request.host}/users/${id}". When a placeholder cannot be
+// resolved, or the resulting location is invalid, the request is not
+// redirected. When the location doesn't contain the path or the query, they
+// are preserved from the incoming request. A location ending with "?" drops
+// the query of the incoming request.
 // Name: "redirectTo".
 func NewRedirectTo() filters.Spec { return &redirect{typ: redTo} }
 
@@ -91,17 +100,28 @@ func (spec *redirect) CreateFilter(config []interface{}) (filters.Filter, error)
 		return invalidArgs()
 	}
 
+	if spec.typ != redDeprecated && (code < 300 || code > 399 || code != float64(int(code))) {
+		return invalidArgs()
+	}
+
 	location, ok := config[1].(string)
 	if !ok {
 		return invalidArgs()
 	}
 
+	f := &redirect{typ: spec.typ, code: int(code)}
+	if spec.typ != redDeprecated && strings.Contains(location, "${") {
+		f.template = eskip.NewTemplate(location)
+		return f, nil
+	}
+
 	u, err := url.Parse(location)
 	if err != nil {
 		return invalidArgs()
 	}
 
-	return &redirect{spec.typ, int(code), u}, nil
+	f.location = u
+	return f, nil
 }
 
 func getRequestHost(r *http.Request) string {
@@ -147,10 +167,11 @@ func getLocation(ctx filters.FilterContext, location *url.URL, typ redirectType)
 		u.Path = strings.ToLower(u.Path)
 	}
 
-	if u.RawQuery == "" {
+	if u.RawQuery == "" && !u.ForceQuery {
 		u.RawQuery = r.URL.RawQuery
 	}
 
+	u.ForceQuery = false
 	return u.String()
 }
 
@@ -172,7 +193,22 @@ func (spec *redirect) Request(ctx filters.FilterContext) {
 		return
 	}
 
-	redirectWithType(ctx, spec.code, spec.location, spec.typ)
+	location := spec.location
+	if spec.template != nil {
+		l, ok := spec.template.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		u, err := url.Parse(l)
+		if err != nil {
+			return
+		}
+
+		location = u
+	}
+
+	redirectWithType(ctx, spec.code, location, spec.typ)
 }
 
 // Sets the status code and the location header of the response. Marks the
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/logging/loggingtest"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/routing"
@@ -252,3 +253,95 @@ func TestRedirectLower(t *testing.T) {
 		}
 	}
 }
+
+func TestRedirectToArgs(t *testing.T) {
+	for _, test := range []struct {
+		msg  string
+		args []interface{}
+		fail bool
+	}{{
+		msg:  "code only",
+		args: []interface{}{float64(http.StatusFound)},
+	}, {
+		msg:  "template",
+		args: []interface{}{float64(http.StatusFound), "https://${request.host}/users/${id}"},
+	}, {
+		msg:  "not a redirect code",
+		args: []interface{}{float64(http.StatusOK), "/some/path"},
+		fail: true,
+	}, {
+		msg:  "not an integer code",
+		args: []interface{}{301.5, "/some/path"},
+		fail: true,
+	}} {
+		t.Run(test.msg, func(t *testing.T) {
+			_, err := NewRedirectTo().CreateFilter(test.args)
+			if test.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRedirectToTemplate(t *testing.T) {
+	for _, test := range []struct {
+		msg           string
+		location      string
+		params        map[string]string
+		checkLocation string
+	}{{
+		msg:           "path param",
+		location:      "/users/${id}",
+		params:        map[string]string{"id": "42"},
+		checkLocation: "https://incoming.example.org/users/42?foo=1&bar=2",
+	}, {
+		msg:           "request attributes",
+		location:      "https://www.${request.host}/v2${request.path}",
+		checkLocation: "https://www.incoming.example.org/v2/some/path?foo=1&bar=2",
+	}, {
+		msg:           "query from request",
+		location:      "/search?q=${request.query.foo}",
+		checkLocation: "https://incoming.example.org/search?q=1",
+	}, {
+		msg:      "unresolved placeholder",
+		location: "/users/${id}",
+	}, {
+		msg:           "drop query",
+		location:      "/other/path?",
+		checkLocation: "https://incoming.example.org/other/path",
+	}} {
+		t.Run(test.msg, func(t *testing.T) {
+			f, err := NewRedirectTo().CreateFilter([]interface{}{float64(http.StatusFound), test.location})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest: &http.Request{
+					URL:  &url.URL{Path: "/some/path", RawQuery: "foo=1&bar=2"},
+					Host: "incoming.example.org",
+				},
+				FParams: test.params,
+			}
+
+			f.Request(ctx)
+			if test.checkLocation == "" {
+				if ctx.FServed {
+					t.Error("unexpected redirect")
+				}
+
+				return
+			}
+
+			if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+				t.Fatal("failed to redirect")
+			}
+
+			if l := ctx.FResponse.Header.Get("Location"); l != test.checkLocation {
+				t.Errorf("invalid location, expected: %s, got: %s", test.checkLocation, l)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		args     []interface{}
+		backend  http.Header
+		expected http.Header
+	}{{
+		msg: "defaults",
+		expected: http.Header{
+			"Strict-Transport-Security": []string{"max-age=31536000; includeSubDomains"},
+			"X-Content-Type-Options":    []string{"nosniff"},
+			"X-Frame-Options":           []string{"DENY"},
+			"Referrer-Policy":           []string{"strict-origin-when-cross-origin"},
+			"Content-Security-Policy":   []string{"frame-ancestors 'none'"},
+		},
+	}, {
+		msg:  "override and disable",
+		args: []interface{}{"x-frame-options", "SAMEORIGIN", "Content-Security-Policy", ""},
+		expected: http.Header{
+			"Strict-Transport-Security": []string{"max-age=31536000; includeSubDomains"},
+			"X-Content-Type-Options":    []string{"nosniff"},
+			"X-Frame-Options":           []string{"SAMEORIGIN"},
+			"Referrer-Policy":           []string{"strict-origin-when-cross-origin"},
+		},
+	}, {
+		msg: "keep the backend headers",
+		backend: http.Header{
+			"Referrer-Policy": []string{"no-referrer"},
+		},
+		expected: http.Header{
+			"Strict-Transport-Security": []string{"max-age=31536000; includeSubDomains"},
+			"X-Content-Type-Options":    []string{"nosniff"},
+			"X-Frame-Options":           []string{"DENY"},
+			"Referrer-Policy":           []string{"no-referrer"},
+			"Content-Security-Policy":   []string{"frame-ancestors 'none'"},
+		},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			f, err := NewSecurityHeaders().CreateFilter(ti.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp := &http.Response{Header: make(http.Header)}
+			for k, v := range ti.backend {
+				rsp.Header[k] = v
+			}
+
+			f.Response(&filtertest.Context{FResponse: rsp})
+			if len(rsp.Header) != len(ti.expected) {
+				t.Fatalf("invalid headers, expected: %v, got: %v", ti.expected, rsp.Header)
+			}
+
+			for k := range ti.expected {
+				if rsp.Header.Get(k) != ti.expected.Get(k) {
+					t.Errorf("invalid header %s, expected: %s, got: %s", k, ti.expected.Get(k), rsp.Header.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"X-Frame-Options"},
+		{"X-Foo", "bar"},
+		{"X-Frame-Options", 42},
+		{42, "DENY"},
+	} {
+		if _, err := NewSecurityHeaders().CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
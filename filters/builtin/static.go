@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
@@ -14,6 +15,14 @@ type static struct {
 	handler http.Handler
 }
 
+// sets the ETag header for the served files, derived from the modification
+// time and the size of the file, so that the file server can respond to the
+// conditional requests with If-None-Match and If-Range.
+type etagHandler struct {
+	root http.FileSystem
+	next http.Handler
+}
+
 // Returns a filter Spec to serve static content from a file system
 // location. Behaves similarly to net/http.FileServer. It shunts the route.
 //
@@ -23,6 +32,9 @@ type static struct {
 // rest of the path to the directory path. Then, it uses the resulting
 // path to serve static content from the file system.
 //
+// It supports range requests, and conditional requests based on the
+// Last-Modified and the ETag headers.
+//
 // Name: "static".
 func NewStatic() filters.Spec { return &static{} }
 
@@ -54,7 +66,20 @@ func (spec *static) CreateFilter(config []interface{}) (filters.Filter, error) {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
-	return &static{http.StripPrefix(webRoot, http.FileServer(http.Dir(root)))}, nil
+	fs := http.Dir(root)
+	return &static{http.StripPrefix(webRoot, etagHandler{root: fs, next: http.FileServer(fs)})}, nil
+}
+
+func (h etagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f, err := h.root.Open(path.Clean("/" + r.URL.Path)); err == nil {
+		if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+		}
+
+		f.Close()
+	}
+
+	h.next.ServeHTTP(w, r)
 }
 
 // Serves content from the file system and marks the request served.
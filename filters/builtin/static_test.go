@@ -228,3 +228,54 @@ func TestMultipleRanges(t *testing.T) {
 		t.Error("failed to receive all ranges")
 	}
 }
+
+func TestStaticETag(t *testing.T) {
+	if err := ioutil.WriteFile("/tmp/static-test", []byte("test content"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(NewStatic())
+	pr := proxytest.New(fr, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: StaticName, Args: []interface{}{"/static", "/tmp"}}},
+		Shunt:   true})
+	defer pr.Close()
+
+	rsp, err := http.Get(pr.URL + "/static/static-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	etag := rsp.Header.Get("ETag")
+	if rsp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("failed to serve the file with an etag: %d, %q", rsp.StatusCode, etag)
+	}
+
+	req, err := http.NewRequest("GET", pr.URL+"/static/static-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	rsp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNotModified {
+		t.Errorf("failed to respond not modified: %d", rsp.StatusCode)
+	}
+
+	req.Header.Set("If-None-Match", `"other"`)
+	rsp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("failed to serve the modified file: %d", rsp.StatusCode)
+	}
+}
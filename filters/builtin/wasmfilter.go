@@ -0,0 +1,9 @@
+// +build wasmfilter
+
+package builtin
+
+import "github.com/zalando/skipper/filters/wasm"
+
+func init() {
+	optionalSpecs = append(optionalSpecs, wasm.New())
+}
@@ -0,0 +1,15 @@
+// +build wasmfilter
+
+package builtin
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters/wasm"
+)
+
+func TestWasmFilterRegistered(t *testing.T) {
+	if _, ok := MakeRegistry()[wasm.Name]; !ok {
+		t.Error("the wasm filter is not registered")
+	}
+}
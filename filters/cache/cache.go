@@ -0,0 +1,393 @@
+/*
+Package cache implements the responseCache filter, an HTTP cache of the
+backend responses.
+
+The filter caches the responses of the GET and HEAD requests, honoring the
+Cache-Control and Vary headers, as a shared cache. The freshness of the
+responses is taken from the s-maxage or max-age directives, or the Expires
+header, and, when none of them is set, from the optional default TTL of the
+filter. Responses marked as no-store, private or no-cache, and responses
+setting cookies, are not cached.
+
+When a response is stored with the stale-while-revalidate directive, and
+its age is within the allowed window, then a single request is forwarded
+to the backend to revalidate it, while the concurrent requests are served
+with the stale response.
+
+The responses are stored in a Store. The package provides an in-memory
+store with LRU eviction, and a Redis based store, which can be shared
+between multiple Skipper instances. Custom stores can be used by
+implementing the Store interface.
+
+Examples:
+
+	responseCache()
+
+	// cache the responses without freshness information for 30 seconds:
+	responseCache("30s")
+*/
+package cache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	Name = "responseCache"
+
+	stateKey = "filter::responseCache"
+
+	// larger responses are not cached
+	maxBodySize = 1 << 20
+
+	// after this, the revalidation of a stale response is considered
+	// failed, and another request can revalidate it
+	revalidationTimeout = 30 * time.Second
+)
+
+type spec struct {
+	store        Store
+	now          func() time.Time
+	mx           sync.Mutex
+	revalidating map[string]time.Time
+}
+
+type filter struct {
+	spec *spec
+	ttl  time.Duration
+}
+
+type requestState struct {
+	key          string
+	revalidating bool
+}
+
+type cacheControl map[string]string
+
+// New creates the specification of the responseCache filter, storing the
+// responses in the provided store. When the store is nil, an in-memory
+// store is used with the default size.
+func New(s Store) filters.Spec {
+	if s == nil {
+		s = NewMemoryStore(0)
+	}
+
+	return &spec{
+		store:        s,
+		now:          time.Now,
+		revalidating: make(map[string]time.Time),
+	}
+}
+
+func (*spec) Name() string { return Name }
+
+// CreateFilter creates a responseCache filter. It accepts an optional
+// argument, the default TTL of the responses without freshness
+// information, in seconds or as a duration string.
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) > 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{spec: s}
+	if len(args) == 1 {
+		switch v := args[0].(type) {
+		case float64:
+			f.ttl = time.Duration(v * float64(time.Second))
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.ttl = d
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if f.ttl < 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+func parseCacheControl(h string) cacheControl {
+	cc := make(cacheControl)
+	for _, d := range strings.Split(h, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+
+		kv := strings.SplitN(d, "=", 2)
+		k := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			cc[k] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			cc[k] = ""
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// returns false when the directive is not set or invalid
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	s, err := strconv.Atoi(v)
+	if err != nil || s < 0 {
+		return 0, false
+	}
+
+	return time.Duration(s) * time.Second, true
+}
+
+func cacheKey(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK,
+		http.StatusNonAuthoritativeInfo,
+		http.StatusNoContent,
+		http.StatusMultipleChoices,
+		http.StatusMovedPermanently,
+		http.StatusNotFound,
+		http.StatusGone:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Entry) matches(r *http.Request) bool {
+	for k, v := range e.Vary {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// returns true, when the calling request should revalidate the response
+func (s *spec) startRevalidation(key string) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.now()
+	if started, ok := s.revalidating[key]; ok && now.Sub(started) < revalidationTimeout {
+		return false
+	}
+
+	s.revalidating[key] = now
+	return true
+}
+
+func (s *spec) endRevalidation(key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.revalidating, key)
+}
+
+func serveEntry(ctx filters.FilterContext, e *Entry, age time.Duration) {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.Itoa(int(age/time.Second)))
+	ctx.Serve(&http.Response{
+		StatusCode:    e.StatusCode,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+	})
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return
+	}
+
+	cc := parseCacheControl(r.Header.Get("Cache-Control"))
+	if cc.has("no-store") {
+		return
+	}
+
+	state := &requestState{key: cacheKey(r)}
+	if maxAge, ok := cc.seconds("max-age"); !cc.has("no-cache") && (!ok || maxAge > 0) {
+		e, err := f.spec.store.Get(state.key)
+		if err != nil {
+			log.Errorf("Error while reading from the response cache: %v.", err)
+		}
+
+		if e != nil && e.matches(r) {
+			age := f.spec.now().Sub(e.Created)
+			if age < e.MaxAge {
+				serveEntry(ctx, e, age)
+				return
+			}
+
+			if age < e.MaxAge+e.StaleWhileRevalidate {
+				if !f.spec.startRevalidation(state.key) {
+					serveEntry(ctx, e, age)
+					return
+				}
+
+				state.revalidating = true
+			}
+		}
+	}
+
+	ctx.StateBag()[stateKey] = state
+}
+
+// returns the freshness lifetime of the response, or zero, when it
+// should not be stored
+func (f *filter) maxAge(rsp *http.Response, cc cacheControl) time.Duration {
+	if d, ok := cc.seconds("s-maxage"); ok {
+		return d
+	}
+
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+
+	if expires := rsp.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+
+		return t.Sub(f.spec.now())
+	}
+
+	return f.ttl
+}
+
+// returns the values of the request headers listed in the Vary header,
+// or false, when the response varies on everything
+func varyValues(r *http.Request, rsp *http.Response) (map[string]string, bool) {
+	var names []string
+	for _, v := range rsp.Header["Vary"] {
+		for _, n := range strings.Split(v, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, http.CanonicalHeaderKey(n))
+			}
+		}
+	}
+
+	vary := make(map[string]string)
+	for _, n := range names {
+		if n == "*" {
+			return nil, false
+		}
+
+		vary[n] = r.Header.Get(n)
+	}
+
+	return vary, true
+}
+
+type bodyReader struct {
+	io.Reader
+	io.Closer
+}
+
+// reads the response body, when it's not larger than the limit, and
+// restores it for the client
+func readBody(rsp *http.Response) ([]byte, bool) {
+	if rsp.Body == nil {
+		return nil, true
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, rsp.Body, maxBodySize+1)
+	if err == io.EOF && n <= maxBodySize {
+		rsp.Body.Close()
+		rsp.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+		return buf.Bytes(), true
+	}
+
+	// on read errors, the error is returned again by the original body,
+	// after the buffered part
+	rsp.Body = &bodyReader{Reader: io.MultiReader(&buf, rsp.Body), Closer: rsp.Body}
+	return nil, false
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	state, ok := ctx.StateBag()[stateKey].(*requestState)
+	if !ok {
+		return
+	}
+
+	if state.revalidating {
+		defer f.spec.endRevalidation(state.key)
+	}
+
+	r := ctx.Request()
+	rsp := ctx.Response()
+	if !cacheableStatus(rsp.StatusCode) || rsp.Header.Get("Set-Cookie") != "" {
+		return
+	}
+
+	cc := parseCacheControl(rsp.Header.Get("Cache-Control"))
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") {
+		return
+	}
+
+	if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") {
+		return
+	}
+
+	vary, ok := varyValues(r, rsp)
+	if !ok {
+		return
+	}
+
+	maxAge := f.maxAge(rsp, cc)
+	if maxAge <= 0 {
+		return
+	}
+
+	swr, _ := cc.seconds("stale-while-revalidate")
+	body, ok := readBody(rsp)
+	if !ok {
+		return
+	}
+
+	e := &Entry{
+		StatusCode:           rsp.StatusCode,
+		Header:               rsp.Header.Clone(),
+		Body:                 body,
+		Created:              f.spec.now(),
+		MaxAge:               maxAge,
+		StaleWhileRevalidate: swr,
+		Vary:                 vary,
+	}
+
+	if err := f.spec.store.Set(state.key, e, maxAge+swr); err != nil {
+		log.Errorf("Error while writing to the response cache: %v.", err)
+	}
+}
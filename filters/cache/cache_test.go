@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testBackend struct {
+	requests int
+	header   http.Header
+	status   int
+}
+
+type testResult struct {
+	fromCache bool
+	status    int
+	body      string
+	header    http.Header
+}
+
+func (b *testBackend) roundTrip(t *testing.T, f filters.Filter, req *http.Request) testResult {
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+
+	var result testResult
+	if ctx.Served() {
+		result.fromCache = true
+	} else {
+		b.requests++
+		status := b.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		h := make(http.Header)
+		for k, v := range b.header {
+			h[k] = v
+		}
+
+		ctx.FResponse = &http.Response{
+			StatusCode: status,
+			Header:     h,
+			Body:       ioutil.NopCloser(strings.NewReader("Hello, world!")),
+		}
+	}
+
+	f.Response(ctx)
+	rsp := ctx.Response()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result.status = rsp.StatusCode
+	result.body = string(body)
+	result.header = rsp.Header
+	return result
+}
+
+func testRequest(t *testing.T, method string, header http.Header) *http.Request {
+	req, err := http.NewRequest(method, "https://www.example.org/foo?bar=baz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return req
+}
+
+func TestResponseCache(t *testing.T) {
+	for _, ti := range []struct {
+		msg           string
+		args          []interface{}
+		method        string
+		requestHeader http.Header
+		backendHeader http.Header
+		status        int
+		cached        bool
+	}{{
+		msg:           "max-age",
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=60"}},
+		cached:        true,
+	}, {
+		msg:           "s-maxage",
+		backendHeader: http.Header{"Cache-Control": []string{"public, s-maxage=60"}},
+		cached:        true,
+	}, {
+		msg:    "no freshness information",
+		cached: false,
+	}, {
+		msg:    "default ttl",
+		args:   []interface{}{"1m"},
+		cached: true,
+	}, {
+		msg:           "max-age zero overrides the default ttl",
+		args:          []interface{}{float64(60)},
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=0"}},
+		cached:        false,
+	}, {
+		msg:           "no-store",
+		backendHeader: http.Header{"Cache-Control": []string{"no-store, max-age=60"}},
+		cached:        false,
+	}, {
+		msg:           "private",
+		backendHeader: http.Header{"Cache-Control": []string{"private, max-age=60"}},
+		cached:        false,
+	}, {
+		msg:           "no-cache",
+		backendHeader: http.Header{"Cache-Control": []string{"no-cache"}},
+		args:          []interface{}{"1m"},
+		cached:        false,
+	}, {
+		msg: "setting cookies",
+		backendHeader: http.Header{
+			"Cache-Control": []string{"max-age=60"},
+			"Set-Cookie":    []string{"foo=bar"},
+		},
+		cached: false,
+	}, {
+		msg:           "request no-store",
+		requestHeader: http.Header{"Cache-Control": []string{"no-store"}},
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=60"}},
+		cached:        false,
+	}, {
+		msg:           "authorized request",
+		requestHeader: http.Header{"Authorization": []string{"Bearer foo"}},
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=60"}},
+		cached:        false,
+	}, {
+		msg:           "authorized request with public response",
+		requestHeader: http.Header{"Authorization": []string{"Bearer foo"}},
+		backendHeader: http.Header{"Cache-Control": []string{"public, max-age=60"}},
+		cached:        true,
+	}, {
+		msg:           "not a cacheable method",
+		method:        "POST",
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=60"}},
+		cached:        false,
+	}, {
+		msg:           "not a cacheable status",
+		backendHeader: http.Header{"Cache-Control": []string{"max-age=60"}},
+		status:        http.StatusInternalServerError,
+		cached:        false,
+	}, {
+		msg: "vary on everything",
+		backendHeader: http.Header{
+			"Cache-Control": []string{"max-age=60"},
+			"Vary":          []string{"*"},
+		},
+		cached: false,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			f, err := New(NewMemoryStore(0)).CreateFilter(ti.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			method := ti.method
+			if method == "" {
+				method = "GET"
+			}
+
+			b := &testBackend{header: ti.backendHeader, status: ti.status}
+			for i := 0; i < 2; i++ {
+				result := b.roundTrip(t, f, testRequest(t, method, ti.requestHeader))
+				if result.body != "Hello, world!" {
+					t.Fatalf("invalid body: %s", result.body)
+				}
+			}
+
+			expectedRequests := 2
+			if ti.cached {
+				expectedRequests = 1
+			}
+
+			if b.requests != expectedRequests {
+				t.Errorf("invalid number of backend requests, expected: %d, got: %d", expectedRequests, b.requests)
+			}
+		})
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	f, err := New(NewMemoryStore(0)).CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBackend{header: http.Header{
+		"Cache-Control": []string{"max-age=60"},
+		"Vary":          []string{"Accept-Encoding"},
+	}}
+
+	gzip := http.Header{"Accept-Encoding": []string{"gzip"}}
+	b.roundTrip(t, f, testRequest(t, "GET", gzip))
+	if b.roundTrip(t, f, testRequest(t, "GET", gzip)); b.requests != 1 {
+		t.Fatal("failed to serve the matching variant from the cache")
+	}
+
+	if b.roundTrip(t, f, testRequest(t, "GET", nil)); b.requests != 2 {
+		t.Fatal("served a different variant from the cache")
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	now := time.Now()
+	s := New(NewMemoryStore(0)).(*spec)
+	s.now = func() time.Time { return now }
+	f, err := s.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBackend{header: http.Header{"Cache-Control": []string{"max-age=60, stale-while-revalidate=30"}}}
+	b.roundTrip(t, f, testRequest(t, "GET", nil))
+
+	now = now.Add(70 * time.Second)
+
+	// the first request revalidates, while the concurrent one gets the
+	// stale response
+	revalidating := &filtertest.Context{FRequest: testRequest(t, "GET", nil), FStateBag: make(map[string]interface{})}
+	f.Request(revalidating)
+	if revalidating.Served() {
+		t.Fatal("failed to revalidate the stale response")
+	}
+
+	stale := b.roundTrip(t, f, testRequest(t, "GET", nil))
+	if !stale.fromCache || stale.header.Get("Age") != "70" {
+		t.Fatalf("failed to serve the stale response, age: %s", stale.header.Get("Age"))
+	}
+
+	revalidating.FResponse = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Cache-Control": []string{"max-age=60"}},
+		Body:       ioutil.NopCloser(strings.NewReader("Hello, world!")),
+	}
+
+	f.Response(revalidating)
+	if fresh := b.roundTrip(t, f, testRequest(t, "GET", nil)); !fresh.fromCache || fresh.header.Get("Age") != "0" {
+		t.Error("failed to store the revalidated response")
+	}
+
+	// beyond the stale-while-revalidate window
+	now = now.Add(100 * time.Second)
+	if expired := b.roundTrip(t, f, testRequest(t, "GET", nil)); expired.fromCache {
+		t.Error("served an expired response")
+	}
+}
+
+func TestResponseCacheArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"foo"},
+		{float64(-1)},
+		{"1m", "1m"},
+		{true},
+	} {
+		if _, err := New(nil).CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	CoalesceName = "coalesceRequests"
+
+	coalesceStateKey = "filter::coalesceRequests"
+
+	defaultCoalesceTimeout = 10 * time.Second
+)
+
+// the values of these request headers are always part of the key of the
+// coalesced requests
+var coalesceKeyHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+}
+
+type coalesceSpec struct {
+	now   func() time.Time
+	mx    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalesceFilter struct {
+	spec    *coalesceSpec
+	timeout time.Duration
+	headers []string
+}
+
+// coalescedCall is the backend request of the first of the identical
+// requests, whose response is shared with the rest of them
+type coalescedCall struct {
+	started    time.Time
+	done       chan struct{}
+	once       sync.Once
+	ok         bool
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// NewCoalesce creates the specification of the coalesceRequests filter,
+// which collapses the concurrent, identical GET requests to a single
+// backend request, and serves its response to all of them.
+func NewCoalesce() filters.Spec {
+	return &coalesceSpec{
+		now:   time.Now,
+		calls: make(map[string]*coalescedCall),
+	}
+}
+
+func (*coalesceSpec) Name() string { return CoalesceName }
+
+// CreateFilter creates a coalesceRequests filter. The first, optional,
+// argument is the maximum time the identical requests wait for the
+// response of the first one, in milliseconds or as a duration string,
+// defaulting to 10 seconds. When the time is over, they are forwarded to
+// the backend. The rest of the arguments are the names of the request
+// headers whose values distinguish the requests, in addition to the
+// Authorization, Cookie and Accept headers.
+//
+//     s.CreateFilter()
+//     s.CreateFilter("3s", "X-Tenant-Id")
+//
+func (s *coalesceSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &coalesceFilter{spec: s, timeout: defaultCoalesceTimeout}
+	var headerArgs []interface{}
+	if len(args) > 0 {
+		headerArgs = args[1:]
+		switch v := args[0].(type) {
+		case float64:
+			f.timeout = time.Duration(v) * time.Millisecond
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.timeout = d
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if f.timeout <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	f.headers = append(f.headers, coalesceKeyHeaders...)
+	for _, a := range headerArgs {
+		h, ok := a.(string)
+		if !ok || !httpguts.ValidHeaderFieldName(h) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.headers = append(f.headers, h)
+	}
+
+	return f, nil
+}
+
+func (f *coalesceFilter) key(r *http.Request) string {
+	k := []string{cacheKey(r)}
+	for _, h := range f.headers {
+		k = append(k, strings.Join(r.Header[http.CanonicalHeaderKey(h)], ","))
+	}
+
+	return strings.Join(k, "\n")
+}
+
+// returns the pending call with the same key, or registers a new one,
+// when there is none, or it is older than the timeout
+func (f *coalesceFilter) call(key string) (c *coalescedCall, first bool) {
+	f.spec.mx.Lock()
+	defer f.spec.mx.Unlock()
+
+	now := f.spec.now()
+	if c, ok := f.spec.calls[key]; ok && now.Sub(c.started) < f.timeout {
+		return c, false
+	}
+
+	c = &coalescedCall{started: now, done: make(chan struct{})}
+	f.spec.calls[key] = c
+	return c, true
+}
+
+// releases the waiting requests, storing the shared response, when it is
+// not nil. It is called from the response filter, or, when the proxy
+// doesn't execute the response filters, e.g. because the backend request
+// failed, when the request is finished. Only the first call has effect.
+func (f *coalesceFilter) finish(key string, c *coalescedCall, rsp *http.Response, body []byte) {
+	c.once.Do(func() {
+		if rsp != nil {
+			c.ok = true
+			c.statusCode = rsp.StatusCode
+			c.header = rsp.Header.Clone()
+			c.body = body
+		}
+
+		f.spec.mx.Lock()
+		if f.spec.calls[key] == c {
+			delete(f.spec.calls, key)
+		}
+
+		f.spec.mx.Unlock()
+		close(c.done)
+	})
+}
+
+// the context of the incoming request is canceled when the request is
+// finished, regardless of whether the response filters were executed
+func (f *coalesceFilter) finishWithRequest(r *http.Request, key string, c *coalescedCall) {
+	select {
+	case <-c.done:
+	case <-r.Context().Done():
+		f.finish(key, c, nil, nil)
+	}
+}
+
+type coalesceState struct {
+	key  string
+	call *coalescedCall
+}
+
+func (f *coalesceFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if r.Method != "GET" {
+		return
+	}
+
+	key := f.key(r)
+	c, first := f.call(key)
+	if first {
+		ctx.StateBag()[coalesceStateKey] = &coalesceState{key: key, call: c}
+		go f.finishWithRequest(r, key, c)
+		return
+	}
+
+	t := time.NewTimer(f.timeout - f.spec.now().Sub(c.started))
+	defer t.Stop()
+
+	select {
+	case <-c.done:
+	case <-t.C:
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	if !c.ok {
+		return
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode:    c.statusCode,
+		Header:        c.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+	})
+}
+
+func (f *coalesceFilter) Response(ctx filters.FilterContext) {
+	state, ok := ctx.StateBag()[coalesceStateKey].(*coalesceState)
+	if !ok {
+		return
+	}
+
+	// the responses setting cookies are not shared
+	rsp := ctx.Response()
+	if rsp.Header.Get("Set-Cookie") != "" {
+		f.finish(state.key, state.call, nil, nil)
+		return
+	}
+
+	body, ok := readBody(rsp)
+	if !ok {
+		f.finish(state.key, state.call, nil, nil)
+		return
+	}
+
+	f.finish(state.key, state.call, rsp, body)
+}
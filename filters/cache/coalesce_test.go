@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func testCoalesce(t *testing.T, args []interface{}, headers []http.Header) (backendRequests int64, bodies []string) {
+	var requests int64
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		<-release
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	p := proxytest.New(filters.Registry{CoalesceName: NewCoalesce()}, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: CoalesceName, Args: args}},
+		Backend: backend.URL,
+	})
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	bodies = make([]string, len(headers))
+	for i, h := range headers {
+		wg.Add(1)
+		go func(i int, h http.Header) {
+			defer wg.Done()
+			req, err := http.NewRequest("GET", p.URL+"/foo", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			req.Header = h
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			defer rsp.Body.Close()
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			bodies[i] = string(b)
+		}(i, h)
+	}
+
+	// let the requests arrive before the backend responds
+	time.Sleep(120 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return atomic.LoadInt64(&requests), bodies
+}
+
+func TestCoalesceRequests(t *testing.T) {
+	headers := make([]http.Header, 8)
+	for i := range headers {
+		headers[i] = make(http.Header)
+	}
+
+	requests, bodies := testCoalesce(t, nil, headers)
+	if requests != 1 {
+		t.Errorf("failed to coalesce the requests, backend requests: %d", requests)
+	}
+
+	for _, b := range bodies {
+		if b != "Hello, world!" {
+			t.Errorf("invalid body: %s", b)
+		}
+	}
+}
+
+func TestCoalesceRequestsDistinctHeaders(t *testing.T) {
+	headers := []http.Header{
+		{"Authorization": []string{"Bearer foo"}},
+		{"Authorization": []string{"Bearer bar"}},
+		{"X-Tenant-Id": []string{"foo"}},
+		{"X-Tenant-Id": []string{"bar"}},
+	}
+
+	requests, _ := testCoalesce(t, []interface{}{"3s", "X-Tenant-Id"}, headers)
+	if requests != 4 {
+		t.Errorf("coalesced distinct requests, backend requests: %d", requests)
+	}
+}
+
+func TestCoalesceRequestsBackendError(t *testing.T) {
+	var requests int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request fails after a while, the rest fail right away
+		if atomic.AddInt64(&requests, 1) == 1 {
+			time.Sleep(120 * time.Millisecond)
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conn.Close()
+	}))
+	defer backend.Close()
+
+	p := proxytest.New(filters.Registry{CoalesceName: NewCoalesce()}, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: CoalesceName, Args: []interface{}{"3s"}}},
+		Backend: backend.URL,
+	})
+	defer p.Close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rsp, err := http.Get(p.URL + "/foo")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			rsp.Body.Close()
+			if rsp.StatusCode < http.StatusInternalServerError {
+				t.Errorf("unexpected status code: %d", rsp.StatusCode)
+			}
+		}()
+
+		// let the first request arrive first
+		if i == 0 {
+			time.Sleep(30 * time.Millisecond)
+		}
+	}
+
+	wg.Wait()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("failed to release the waiting requests on backend error, took: %v", d)
+	}
+}
+
+func TestCoalesceArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"foo"},
+		{float64(0)},
+		{"1s", "X Tenant"},
+		{"1s", 42},
+	} {
+		if _, err := NewCoalesce().CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
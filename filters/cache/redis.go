@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const defaultRedisPrefix = "skipper:cache:"
+
+var errMissingRedisAddress = errors.New("missing Redis address")
+
+// RedisStoreOptions contains the initialization options of the Redis
+// store.
+type RedisStoreOptions struct {
+
+	// Address of the Redis server. Required.
+	Address string
+
+	// Optional password for the Redis server.
+	Password string
+
+	// Database number.
+	DB int
+
+	// Key prefix of the stored responses. Defaults to
+	// "skipper:cache:".
+	Prefix string
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a store that keeps the cached responses in Redis,
+// which allows sharing them between multiple Skipper instances. The
+// entries expire in Redis with their time to live.
+func NewRedisStore(o RedisStoreOptions) (Store, error) {
+	if o.Address == "" {
+		return nil, errMissingRedisAddress
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = defaultRedisPrefix
+	}
+
+	return &redisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     o.Address,
+			Password: o.Password,
+			DB:       o.DB,
+		}),
+		prefix: o.Prefix,
+	}, nil
+}
+
+func (s *redisStore) Get(key string) (*Entry, error) {
+	data, err := s.client.Get(s.prefix + key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *redisStore) Set(key string, e *Entry, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(s.prefix+key, data, ttl).Err()
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultMemoryStoreSize is the default maximum size of the in-memory
+// store, in bytes.
+const DefaultMemoryStoreSize = 64 << 20
+
+// Entry is a cached response.
+type Entry struct {
+	// StatusCode of the cached response.
+	StatusCode int
+
+	// Header of the cached response.
+	Header http.Header
+
+	// Body of the cached response.
+	Body []byte
+
+	// Created is the time when the response was stored.
+	Created time.Time
+
+	// MaxAge is the duration while the response is fresh.
+	MaxAge time.Duration
+
+	// StaleWhileRevalidate is the duration after MaxAge, while the
+	// stale response can be served during revalidation.
+	StaleWhileRevalidate time.Duration
+
+	// Vary contains the values of the request headers listed in the
+	// Vary header of the response.
+	Vary map[string]string
+}
+
+// Store is the storage of the cached responses. Implementations must
+// be safe for concurrent use.
+type Store interface {
+	// Get returns the entry stored with the key, or nil, when there is
+	// no such entry.
+	Get(key string) (*Entry, error)
+
+	// Set stores an entry with the key, for the given time to live.
+	Set(key string, e *Entry, ttl time.Duration) error
+}
+
+type memoryItem struct {
+	key     string
+	entry   *Entry
+	size    int
+	expires time.Time
+}
+
+type memoryStore struct {
+	mx      sync.Mutex
+	maxSize int
+	size    int
+	items   map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an in-memory store, evicting the least recently
+// used entries when the total size of the stored responses exceeds the
+// maximum size in bytes. When maxSize is not positive, it defaults to
+// DefaultMemoryStoreSize.
+func NewMemoryStore(maxSize int) Store {
+	if maxSize <= 0 {
+		maxSize = DefaultMemoryStoreSize
+	}
+
+	return &memoryStore{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// approximation, counting only the key, the header and the body
+func entrySize(key string, e *Entry) int {
+	size := len(key) + len(e.Body)
+	for k, v := range e.Header {
+		size += len(k)
+		for _, vi := range v {
+			size += len(vi)
+		}
+	}
+
+	return size
+}
+
+func (s *memoryStore) remove(elem *list.Element) {
+	item := elem.Value.(*memoryItem)
+	s.lru.Remove(elem)
+	delete(s.items, item.key)
+	s.size -= item.size
+}
+
+func (s *memoryStore) Get(key string) (*Entry, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+
+	item := elem.Value.(*memoryItem)
+	if !s.now().Before(item.expires) {
+		s.remove(elem)
+		return nil, nil
+	}
+
+	s.lru.MoveToFront(elem)
+	return item.entry, nil
+}
+
+func (s *memoryStore) Set(key string, e *Entry, ttl time.Duration) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+
+	size := entrySize(key, e)
+	if size > s.maxSize || ttl <= 0 {
+		return nil
+	}
+
+	for s.size+size > s.maxSize {
+		s.remove(s.lru.Back())
+	}
+
+	item := &memoryItem{key: key, entry: e, size: size, expires: s.now().Add(ttl)}
+	s.items[key] = s.lru.PushFront(item)
+	s.size += size
+	return nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreEviction(t *testing.T) {
+	s := NewMemoryStore(30).(*memoryStore)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Set(key, &Entry{Body: []byte("123456789")}, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// touch a, so that b is the least recently used
+	if e, _ := s.Get("a"); e == nil {
+		t.Fatal("failed to get a")
+	}
+
+	if err := s.Set("d", &Entry{Body: []byte("123456789")}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if e, _ := s.Get(key); (e != nil) != expected {
+			t.Errorf("invalid state of %s, expected stored: %v", key, expected)
+		}
+	}
+
+	if s.size > s.maxSize {
+		t.Errorf("size exceeded: %d", s.size)
+	}
+}
+
+func TestMemoryStoreExpiration(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore(0).(*memoryStore)
+	s.now = func() time.Time { return now }
+	if err := s.Set("a", &Entry{}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, _ := s.Get("a"); e == nil {
+		t.Fatal("failed to get the entry")
+	}
+
+	now = now.Add(time.Minute)
+	if e, _ := s.Get("a"); e != nil {
+		t.Error("failed to expire the entry")
+	}
+
+	if s.size != 0 || len(s.items) != 0 {
+		t.Error("failed to remove the expired entry")
+	}
+}
+
+func TestMemoryStoreTooLarge(t *testing.T) {
+	s := NewMemoryStore(8)
+	if err := s.Set("a", &Entry{Body: []byte("123456789")}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, _ := s.Get("a"); e != nil {
+		t.Error("failed to skip the too large entry")
+	}
+}
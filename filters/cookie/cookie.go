@@ -21,6 +21,16 @@ The JS cookie behaves exactly as the response cookie, but it doesn't
 set the HttpOnly directive, so these cookies will be
 accessible from JS code running in web browsers.
 
+The cookies can be removed from the requests and from the responses with
+the dropRequestCookie and dropResponseCookie filters.
+
+The encryptResponseCookie filter encrypts the value of a cookie set by the
+backend, and the decryptRequestCookie filter decrypts it in the subsequent
+requests, so that the clients can't read or modify the value. They require
+a secrets file, containing comma separated secrets. The first secret is used
+for encryption, and all of them for decryption, which allows rotating the
+keys.
+
 Examples:
 
     requestCookie("test-session", "abc")
@@ -31,6 +41,10 @@ Examples:
 
     // response cookie without HttpOnly:
     jsCookie("test-session-info", "abc-debug", 31536000, "change-only")
+
+    dropRequestCookie("tracking")
+
+    decryptRequestCookie("session") -> encryptResponseCookie("session")
 */
 package cookie
 
@@ -44,11 +58,15 @@ import (
 )
 
 const (
-	RequestCookieFilterName    = "requestCookie"
-	ResponseCookieFilterName   = "responseCookie"
-	ResponseJSCookieFilterName = "jsCookie"
-	ChangeOnlyArg              = "change-only"
-	SetCookieHttpHeader        = "Set-Cookie"
+	RequestCookieFilterName         = "requestCookie"
+	ResponseCookieFilterName        = "responseCookie"
+	ResponseJSCookieFilterName      = "jsCookie"
+	DropRequestCookieFilterName     = "dropRequestCookie"
+	DropResponseCookieFilterName    = "dropResponseCookie"
+	EncryptResponseCookieFilterName = "encryptResponseCookie"
+	DecryptRequestCookieFilterName  = "decryptRequestCookie"
+	ChangeOnlyArg                   = "change-only"
+	SetCookieHttpHeader             = "Set-Cookie"
 )
 
 type direction int
@@ -0,0 +1,128 @@
+package cookie
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type dropSpec struct {
+	typ direction
+}
+
+type dropFilter struct {
+	typ  direction
+	name string
+}
+
+// NewDropRequestCookie creates a filter spec for removing a cookie from
+// the requests.
+// Name: dropRequestCookie
+func NewDropRequestCookie() filters.Spec {
+	return &dropSpec{typ: request}
+}
+
+// NewDropResponseCookie creates a filter spec for removing the Set-Cookie
+// headers of a cookie from the responses.
+// Name: dropResponseCookie
+func NewDropResponseCookie() filters.Spec {
+	return &dropSpec{typ: response}
+}
+
+func (s *dropSpec) Name() string {
+	if s.typ == request {
+		return DropRequestCookieFilterName
+	}
+
+	return DropResponseCookieFilterName
+}
+
+func (s *dropSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &dropFilter{typ: s.typ, name: name}, nil
+}
+
+func (f *dropFilter) Request(ctx filters.FilterContext) {
+	if f.typ != request {
+		return
+	}
+
+	editRequestCookies(ctx.Request(), func(c *http.Cookie) bool {
+		return c.Name != f.name
+	})
+}
+
+func (f *dropFilter) Response(ctx filters.FilterContext) {
+	if f.typ == request {
+		return
+	}
+
+	editSetCookies(ctx.Response().Header, f.name, func(string) (string, bool) {
+		return "", false
+	})
+}
+
+// rewrites the Cookie header of the request, keeping only those cookies,
+// for which the edit function returns true. The edit function can change
+// the cookie value.
+func editRequestCookies(r *http.Request, edit func(*http.Cookie) bool) {
+	cookies := r.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if edit(c) {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// rewrites the Set-Cookie headers with the given cookie name. The edit
+// function receives the raw cookie value, and returns the new value, and
+// whether the header should be kept. The attributes of the cookie are not
+// changed.
+func editSetCookies(h http.Header, name string, edit func(string) (string, bool)) {
+	values := h[SetCookieHttpHeader]
+	if len(values) == 0 {
+		return
+	}
+
+	var edited []string
+	for _, v := range values {
+		c := (&http.Response{Header: http.Header{SetCookieHttpHeader: []string{v}}}).Cookies()
+		if len(c) != 1 || c[0].Name != name {
+			edited = append(edited, v)
+			continue
+		}
+
+		value, keep := edit(c[0].Value)
+		if !keep {
+			continue
+		}
+
+		var attributes string
+		if i := strings.IndexByte(v, ';'); i >= 0 {
+			attributes = v[i:]
+		}
+
+		edited = append(edited, name+"="+value+attributes)
+	}
+
+	if len(edited) == 0 {
+		h.Del(SetCookieHttpHeader)
+		return
+	}
+
+	h[SetCookieHttpHeader] = edited
+}
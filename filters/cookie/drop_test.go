@@ -0,0 +1,57 @@
+package cookie
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestDropRequestCookie(t *testing.T) {
+	f, err := NewDropRequestCookie().CreateFilter([]interface{}{"tracking"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Cookie", "session=abc; tracking=def; consent=yes")
+	f.Request(&filtertest.Context{FRequest: req})
+	if c := req.Header.Get("Cookie"); c != "session=abc; consent=yes" {
+		t.Errorf("failed to drop the cookie: %s", c)
+	}
+}
+
+func TestDropResponseCookie(t *testing.T) {
+	f, err := NewDropResponseCookie().CreateFilter([]interface{}{"tracking"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := &http.Response{Header: http.Header{SetCookieHttpHeader: []string{
+		"session=abc; Path=/; HttpOnly",
+		"tracking=def; Max-Age=3600",
+	}}}
+
+	f.Response(&filtertest.Context{FResponse: rsp})
+	if c := rsp.Header[SetCookieHttpHeader]; len(c) != 1 || c[0] != "session=abc; Path=/; HttpOnly" {
+		t.Errorf("failed to drop the cookie: %v", c)
+	}
+
+	rsp.Header.Set(SetCookieHttpHeader, "tracking=def")
+	f.Response(&filtertest.Context{FResponse: rsp})
+	if _, ok := rsp.Header[SetCookieHttpHeader]; ok {
+		t.Error("failed to drop the Set-Cookie header")
+	}
+}
+
+func TestDropCookieArgs(t *testing.T) {
+	for _, args := range [][]interface{}{nil, {""}, {42.0}, {"foo", "bar"}} {
+		if _, err := NewDropRequestCookie().CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/secrets"
+)
+
+const secretsRefreshInterval = time.Minute
+
+type encryptSpec struct {
+	typ             direction
+	secretsFile     string
+	secretsRegistry secrets.EncrypterCreator
+}
+
+type encryptFilter struct {
+	typ       direction
+	name      string
+	encrypter secrets.Encryption
+}
+
+// NewEncryptResponseCookie creates a filter spec for encrypting the value
+// of a cookie set by the backend, before it is sent to the client. The
+// encryption key is derived from the first secret in the secrets file, while
+// all the secrets in the file are accepted when decrypting, which allows the
+// rotation of the keys. The secrets file is reloaded every minute.
+// Name: encryptResponseCookie
+func NewEncryptResponseCookie(secretsFile string, secretsRegistry *secrets.Registry) filters.Spec {
+	return &encryptSpec{typ: response, secretsFile: secretsFile, secretsRegistry: secretsRegistry}
+}
+
+// NewDecryptRequestCookie creates a filter spec for decrypting the value of
+// a cookie encrypted by the encryptResponseCookie filter, before the request
+// is forwarded to the backend. When the cookie cannot be decrypted, e.g.
+// because it was modified by the client, or its key was rotated out, it is
+// removed from the request.
+// Name: decryptRequestCookie
+func NewDecryptRequestCookie(secretsFile string, secretsRegistry *secrets.Registry) filters.Spec {
+	return &encryptSpec{typ: request, secretsFile: secretsFile, secretsRegistry: secretsRegistry}
+}
+
+func (s *encryptSpec) Name() string {
+	if s.typ == request {
+		return DecryptRequestCookieFilterName
+	}
+
+	return EncryptResponseCookieFilterName
+}
+
+func (s *encryptSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if s.secretsFile == "" || s.secretsRegistry == nil {
+		log.Errorf("Cookie encryption requires a secrets file.")
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	encrypter, err := s.secretsRegistry.GetEncrypter(secretsRefreshInterval, s.secretsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptFilter{typ: s.typ, name: name, encrypter: encrypter}, nil
+}
+
+func (f *encryptFilter) Request(ctx filters.FilterContext) {
+	if f.typ != request {
+		return
+	}
+
+	editRequestCookies(ctx.Request(), func(c *http.Cookie) bool {
+		if c.Name != f.name {
+			return true
+		}
+
+		encrypted, err := base64.RawURLEncoding.DecodeString(c.Value)
+		if err != nil {
+			return false
+		}
+
+		value, err := f.encrypter.Decrypt(encrypted)
+		if err != nil {
+			return false
+		}
+
+		c.Value = string(value)
+		return true
+	})
+}
+
+func (f *encryptFilter) Response(ctx filters.FilterContext) {
+	if f.typ == request {
+		return
+	}
+
+	editSetCookies(ctx.Response().Header, f.name, func(value string) (string, bool) {
+		encrypted, err := f.encrypter.Encrypt([]byte(value))
+		if err != nil {
+			log.Errorf("Failed to encrypt cookie %s: %v.", f.name, err)
+			return "", false
+		}
+
+		return base64.RawURLEncoding.EncodeToString(encrypted), true
+	})
+}
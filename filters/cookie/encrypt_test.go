@@ -0,0 +1,113 @@
+package cookie
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/secrets/secrettest"
+)
+
+func encryptResponse(f filters.Filter, setCookie string) string {
+	rsp := &http.Response{Header: http.Header{SetCookieHttpHeader: []string{setCookie}}}
+	f.Response(&filtertest.Context{FResponse: rsp})
+	return rsp.Header.Get(SetCookieHttpHeader)
+}
+
+func decryptRequest(t *testing.T, f filters.Filter, setCookie string) *http.Request {
+	c := (&http.Response{Header: http.Header{SetCookieHttpHeader: []string{setCookie}}}).Cookies()
+	if len(c) != 1 {
+		t.Fatalf("invalid Set-Cookie: %s", setCookie)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.AddCookie(&http.Cookie{Name: "other", Value: "foo"})
+	req.AddCookie(c[0])
+	f.Request(&filtertest.Context{FRequest: req})
+	return req
+}
+
+func TestEncryptCookie(t *testing.T) {
+	reg := secrettest.NewTestRegistry()
+	encrypt, err := (&encryptSpec{typ: response, secretsFile: "secret", secretsRegistry: reg}).CreateFilter([]interface{}{"session"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypt, err := (&encryptSpec{typ: request, secretsFile: "secret", secretsRegistry: reg}).CreateFilter([]interface{}{"session"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setCookie := encryptResponse(encrypt, "session=backend-42; Path=/; Secure")
+	if strings.Contains(setCookie, "backend-42") || !strings.HasSuffix(setCookie, "; Path=/; Secure") {
+		t.Fatalf("failed to encrypt the cookie: %s", setCookie)
+	}
+
+	req := decryptRequest(t, decrypt, setCookie)
+	if c, err := req.Cookie("session"); err != nil || c.Value != "backend-42" {
+		t.Errorf("failed to decrypt the cookie: %v, %v", c, err)
+	}
+
+	if c, err := req.Cookie("other"); err != nil || c.Value != "foo" {
+		t.Errorf("failed to preserve the other cookie: %v, %v", c, err)
+	}
+
+	req = decryptRequest(t, decrypt, "session=backend-42")
+	if _, err := req.Cookie("session"); err != http.ErrNoCookie {
+		t.Error("failed to drop the invalid cookie")
+	}
+}
+
+func TestEncryptCookieKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookie-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	secretsFile := filepath.Join(dir, "secrets")
+	createFilter := func(typ direction, keys string) filters.Filter {
+		if err := ioutil.WriteFile(secretsFile, []byte(keys), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		reg := secrets.NewRegistry()
+		defer reg.Close()
+
+		f, err := (&encryptSpec{typ: typ, secretsFile: secretsFile, secretsRegistry: reg}).CreateFilter([]interface{}{"session"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	setCookie := encryptResponse(createFilter(response, "old"), "session=backend-42")
+
+	req := decryptRequest(t, createFilter(request, "new,old"), setCookie)
+	if c, err := req.Cookie("session"); err != nil || c.Value != "backend-42" {
+		t.Errorf("failed to decrypt the cookie with the rotated key: %v, %v", c, err)
+	}
+
+	req = decryptRequest(t, createFilter(request, "new"), setCookie)
+	if _, err := req.Cookie("session"); err != http.ErrNoCookie {
+		t.Error("failed to drop the cookie with the removed key")
+	}
+}
+
+func TestEncryptCookieRequiresSecrets(t *testing.T) {
+	if _, err := NewEncryptResponseCookie("", secrets.NewRegistry()).CreateFilter([]interface{}{"session"}); err == nil {
+		t.Error("failed to fail without secrets file")
+	}
+}
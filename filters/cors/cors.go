@@ -1,19 +1,33 @@
 package cors
 
 import (
+	"net/http"
+	"regexp"
+	"strings"
+
 	"github.com/zalando/skipper/filters"
 )
 
 const (
-	name              = "corsOrigin"
-	allowOriginHeader = "Access-Control-Allow-Origin"
+	name                = "corsOrigin"
+	allowOriginHeader   = "Access-Control-Allow-Origin"
+	allowMethodsHeader  = "Access-Control-Allow-Methods"
+	allowHeadersHeader  = "Access-Control-Allow-Headers"
+	maxAgeHeader        = "Access-Control-Max-Age"
+	requestMethodHeader = "Access-Control-Request-Method"
+	requestHeaders      = "Access-Control-Request-Headers"
+
+	// seconds, the preflight responses can be cached by the clients
+	preflightMaxAge = "600"
 )
 
 type basicSpec struct {
 }
 
 type filter struct {
+	anyOrigin      bool
 	allowedOrigins []string
+	patterns       []*regexp.Regexp
 }
 
 // NewOrigin creates a CORS origin handler
@@ -22,39 +36,119 @@ func NewOrigin() filters.Spec {
 	return &basicSpec{}
 }
 
+func (a filter) allowAll() bool {
+	return a.anyOrigin || len(a.allowedOrigins) == 0 && len(a.patterns) == 0
+}
+
+// returns the value of the allow origin header for the origin of the
+// request, or empty string if the origin is not allowed
+func (a filter) allowedOrigin(r *http.Request) string {
+	if a.allowAll() {
+		return "*"
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return ""
+	}
+
+	for _, o := range a.allowedOrigins {
+		if o == origin {
+			return o
+		}
+	}
+
+	for _, p := range a.patterns {
+		if p.MatchString(origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
 // Response checks for the origin header if there are allowed origins
 // otherwise it just sets '*' as the value
 func (a filter) Response(ctx filters.FilterContext) {
-	if len(a.allowedOrigins) == 0 {
+	if a.allowAll() {
 		ctx.Response().Header.Set(allowOriginHeader, "*")
 		return
 	}
 
-	origin := ctx.Request().Header.Get("Origin")
-	if origin == "" {
+	ctx.Response().Header.Add("Vary", "Origin")
+	if o := a.allowedOrigin(ctx.Request()); o != "" {
+		ctx.Response().Header.Set(allowOriginHeader, o)
+	}
+}
+
+// Request answers the preflight requests, allowing the requested method
+// and headers for the allowed origins. The preflight requests from the
+// origins not allowed are answered without the CORS headers. The Vary
+// header of the preflight response is set by the Response method.
+func (a filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	method := r.Header.Get(requestMethodHeader)
+	if r.Method != "OPTIONS" || r.Header.Get("Origin") == "" || method == "" {
 		return
 	}
-	for _, o := range a.allowedOrigins {
-		if o == origin {
-			ctx.Response().Header.Set(allowOriginHeader, o)
-			return
+
+	h := make(http.Header)
+	if o := a.allowedOrigin(r); o != "" {
+		h.Set(allowOriginHeader, o)
+		h.Set(allowMethodsHeader, method)
+		if rh := r.Header.Get(requestHeaders); rh != "" {
+			h.Set(allowHeadersHeader, rh)
 		}
+
+		h.Set(maxAgeHeader, preflightMaxAge)
 	}
+
+	ctx.Serve(&http.Response{StatusCode: http.StatusNoContent, Header: h})
 }
 
-// Request is a noop
-func (a filter) Request(filters.FilterContext) {}
+// converts an origin containing wildcards to a regular expression, where
+// the wildcard matches any part of the host name
+func wildcardPattern(origin string) (*regexp.Regexp, error) {
+	parts := strings.Split(origin, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, "[^/:]+") + "$")
+}
 
 // CreateFilter takes an optional string array.
-// If any argument is not a string, it will return an error
+// If any argument is not a string, it will return an error.
+// The arguments starting with '^' are regular expressions, and the
+// arguments containing '*' are matched as wildcards.
 func (spec basicSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
 	f := &filter{}
 	for _, a := range args {
-		if s, ok := a.(string); ok {
-			f.allowedOrigins = append(f.allowedOrigins, s)
-		} else {
+		s, ok := a.(string)
+		if !ok {
 			return nil, filters.ErrInvalidFilterParameters
 		}
+
+		switch {
+		case strings.HasPrefix(s, "^"):
+			rx, err := regexp.Compile(s)
+			if err != nil {
+				return nil, err
+			}
+
+			f.patterns = append(f.patterns, rx)
+		case s == "*":
+			f.anyOrigin = true
+		case strings.Contains(s, "*"):
+			rx, err := wildcardPattern(s)
+			if err != nil {
+				return nil, err
+			}
+
+			f.patterns = append(f.patterns, rx)
+		default:
+			f.allowedOrigins = append(f.allowedOrigins, s)
+		}
 	}
 	return f, nil
 }
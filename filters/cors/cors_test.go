@@ -97,3 +97,93 @@ func TestSingleHeader(t *testing.T) {
 		t.Error("backend header value should have been overwritten")
 	}
 }
+
+func TestOriginPatterns(t *testing.T) {
+	f, err := NewOrigin().CreateFilter([]interface{}{
+		"https://www.example.org",
+		"https://*.example.com",
+		`^https://[a-z]+\.example\.net$`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		origin   string
+		expected string
+	}{
+		{"https://www.example.org", "https://www.example.org"},
+		{"https://api.example.com", "https://api.example.com"},
+		{"https://example.com", ""},
+		{"https://api.example.com.evil.org", ""},
+		{"https://evil.org/.example.com", ""},
+		{"https://app.example.net", "https://app.example.net"},
+		{"https://app1.example.net", ""},
+		{"https://www.example.io", ""},
+	} {
+		req, err := http.NewRequest("GET", "https://www.example.org/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Origin", test.origin)
+		ctx := &filtertest.Context{FRequest: req, FResponse: &http.Response{Header: http.Header{}}}
+		f.Response(ctx)
+		if v := ctx.Response().Header.Get(allowOriginHeader); v != test.expected {
+			t.Errorf("invalid allow origin header for %s: %q", test.origin, v)
+		}
+
+		if ctx.Response().Header.Get("Vary") != "Origin" {
+			t.Error("failed to set the Vary header")
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	f, err := NewOrigin().CreateFilter([]interface{}{"https://*.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preflight := func(method, origin string) *filtertest.Context {
+		req, err := http.NewRequest(method, "https://api.example.org/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Foo")
+		ctx := &filtertest.Context{FRequest: req}
+		f.Request(ctx)
+		return ctx
+	}
+
+	ctx := preflight("OPTIONS", "https://www.example.org")
+	if !ctx.Served() || ctx.Response().StatusCode != http.StatusNoContent {
+		t.Fatal("failed to answer the preflight request")
+	}
+
+	h := ctx.Response().Header
+	if h.Get(allowOriginHeader) != "https://www.example.org" ||
+		h.Get("Access-Control-Allow-Methods") != "PUT" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type, X-Foo" ||
+		h.Get("Access-Control-Max-Age") == "" {
+		t.Errorf("invalid preflight response headers: %v", h)
+	}
+
+	ctx = preflight("OPTIONS", "https://www.example.com")
+	if !ctx.Served() || ctx.Response().Header.Get(allowOriginHeader) != "" || ctx.Response().Header.Get("Access-Control-Allow-Methods") != "" {
+		t.Error("failed to reject the preflight request")
+	}
+
+	if ctx = preflight("GET", "https://www.example.org"); ctx.Served() {
+		t.Error("unexpected response to a non-preflight request")
+	}
+}
+
+func TestInvalidOriginPattern(t *testing.T) {
+	if _, err := NewOrigin().CreateFilter([]interface{}{"^https://[a-z"}); err == nil {
+		t.Error("failed to fail")
+	}
+}
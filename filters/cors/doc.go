@@ -7,10 +7,17 @@ The filter accepts an optional variadic list of acceptable origin parameters. If
 will always be set to '*' which means any origin is acceptable. Otherwise the header is only set if the request contains
 an Origin header and its value matches one of the elements in the input list. The header is only set on the response.
 
+The elements of the list containing '*' are matched as wildcards, where '*' matches a part of the host name, and the
+elements starting with '^' are matched as regular expressions.
+
+The preflight OPTIONS requests are answered by the filter, allowing the requested method and headers for the allowed
+origins.
+
 Usage
 
 	corsOrigin()
 	corsOrigin("https://www.example.org")
 	corsOrigin("https://www.example.org", "http://localhost:9001")
+	corsOrigin("https://*.example.org", "^https://[a-z]+\\.example\\.com$")
 */
 package cors
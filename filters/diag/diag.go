@@ -4,6 +4,10 @@ Package diag provides a set of network throttling filters for diagnostic purpose
 The filters enable adding artificial latency, limiting bandwidth or chunking responses with custom chunk size
 and delay. This throttling can be applied to the proxy responses or to the outgoing backend requests. An
 additional filter, randomContent, can be used to generate response with random text of specified length.
+
+The faultStatus and faultDrop filters can be used for resilience testing, by responding with an error status,
+or closing the client connection, for a percentage of the requests. The tarpit and teapot filters can be used to
+respond extremely slowly, or with a fixed status after a delay, to the requests of abusive clients.
 */
 package diag
 
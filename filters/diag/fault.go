@@ -0,0 +1,95 @@
+package diag
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	FaultStatusName = "faultStatus"
+	FaultDropName   = "faultDrop"
+)
+
+type faultType int
+
+const (
+	faultStatus faultType = iota
+	faultDrop
+)
+
+type fault struct {
+	typ        faultType
+	percentage float64
+	status     int
+	random     func() float64
+}
+
+// NewFaultStatus creates a filter specification whose filter instances can
+// be used to respond with an error status to a percentage of the requests,
+// instead of forwarding them to the backend. It expects the percentage of
+// the affected requests, and the status code. Eskip example:
+//
+// 	* -> faultStatus(5, 503) -> "https://www.example.org";
+//
+func NewFaultStatus() filters.Spec { return &fault{typ: faultStatus} }
+
+// NewFaultDrop creates a filter specification whose filter instances can be
+// used to close the client connection of a percentage of the requests,
+// without a response. It expects the percentage of the affected requests.
+// Eskip example:
+//
+// 	* -> faultDrop(1.5) -> "https://www.example.org";
+//
+func NewFaultDrop() filters.Spec { return &fault{typ: faultDrop} }
+
+func (f *fault) Name() string {
+	switch f.typ {
+	case faultStatus:
+		return FaultStatusName
+	case faultDrop:
+		return FaultDropName
+	default:
+		panic("invalid fault type")
+	}
+}
+
+func (f *fault) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if f.typ == faultStatus && len(args) != 2 || f.typ == faultDrop && len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	p, ok := args[0].(float64)
+	if !ok || p < 0 || p > 100 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	ff := &fault{typ: f.typ, percentage: p, random: rand.Float64}
+	if f.typ == faultStatus {
+		s, ok := args[1].(float64)
+		if !ok || s < 100 || s > 599 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		ff.status = int(s)
+	}
+
+	return ff, nil
+}
+
+func (f *fault) Request(ctx filters.FilterContext) {
+	if f.random()*100 >= f.percentage {
+		return
+	}
+
+	if f.typ == faultDrop {
+		ctx.StateBag()[filters.AbortConnectionKey] = true
+		ctx.Serve(&http.Response{StatusCode: http.StatusServiceUnavailable})
+		return
+	}
+
+	ctx.Serve(&http.Response{StatusCode: f.status})
+}
+
+func (f *fault) Response(filters.FilterContext) {}
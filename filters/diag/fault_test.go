@@ -0,0 +1,77 @@
+package diag
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestFaultStatus(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		random float64
+		served bool
+	}{{
+		msg:    "affected",
+		random: 0.04,
+		served: true,
+	}, {
+		msg:    "not affected",
+		random: 0.05,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			f, err := NewFaultStatus().CreateFilter([]interface{}{float64(5), float64(503)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f.(*fault).random = func() float64 { return ti.random }
+
+			ctx := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			if ctx.Served() != ti.served {
+				t.Fatalf("failed to apply the fault, expected served: %v", ti.served)
+			}
+
+			if ti.served && ctx.Response().StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("invalid status code: %d", ctx.Response().StatusCode)
+			}
+		})
+	}
+}
+
+func TestFaultDrop(t *testing.T) {
+	p := proxytest.New(filters.Registry{FaultDropName: NewFaultDrop()}, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: FaultDropName, Args: []interface{}{float64(100)}}},
+		Shunt:   true})
+	defer p.Close()
+
+	rsp, err := http.Get(p.URL)
+	if err == nil {
+		rsp.Body.Close()
+		t.Fatalf("failed to drop the connection, got status: %d", rsp.StatusCode)
+	}
+}
+
+func TestFaultArgs(t *testing.T) {
+	for _, ti := range []struct {
+		spec filters.Spec
+		args []interface{}
+	}{
+		{NewFaultStatus(), []interface{}{float64(5)}},
+		{NewFaultStatus(), []interface{}{float64(101), float64(503)}},
+		{NewFaultStatus(), []interface{}{float64(5), float64(600)}},
+		{NewFaultStatus(), []interface{}{"5", float64(503)}},
+		{NewFaultDrop(), []interface{}{}},
+		{NewFaultDrop(), []interface{}{float64(-1)}},
+		{NewFaultDrop(), []interface{}{float64(5), float64(503)}},
+	} {
+		if _, err := ti.spec.CreateFilter(ti.args); err == nil {
+			t.Errorf("failed to fail for %s%v", ti.spec.Name(), ti.args)
+		}
+	}
+}
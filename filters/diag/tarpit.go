@@ -0,0 +1,175 @@
+package diag
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	TarpitName = "tarpit"
+	TeapotName = "teapot"
+
+	defaultTarpitDuration = 5 * time.Minute
+)
+
+type tarpitType int
+
+const (
+	tarpit tarpitType = iota
+	teapot
+)
+
+type tarpitSpec struct {
+	typ tarpitType
+}
+
+type tarpitFilter struct {
+	typ      tarpitType
+	delay    time.Duration
+	duration time.Duration
+	status   int
+}
+
+// tarpitBody returns a single byte after every interval, until the
+// duration is over or the request is canceled
+type tarpitBody struct {
+	ctx      context.Context
+	interval time.Duration
+	deadline time.Time
+}
+
+// NewTarpit creates a filter specification whose filter instances can be
+// used to respond extremely slowly, e.g. to the requests of abusive
+// clients. It responds with status 200 and a body that is sent one byte at
+// a time. It expects the delay between the bytes, and, optionally, the
+// maximum duration of the response, which defaults to 5 minutes. Eskip
+// example:
+//
+// 	* -> tarpit("10s", "30m") -> <shunt>;
+//
+func NewTarpit() filters.Spec { return &tarpitSpec{typ: tarpit} }
+
+// NewTeapot creates a filter specification whose filter instances can be
+// used to respond with a fixed status after a delay, e.g. to the requests of
+// abusive clients. It expects the delay, and, optionally, the status code,
+// which defaults to 418. Eskip example:
+//
+// 	* -> teapot("30s", 429) -> <shunt>;
+//
+func NewTeapot() filters.Spec { return &tarpitSpec{typ: teapot} }
+
+func (s *tarpitSpec) Name() string {
+	switch s.typ {
+	case tarpit:
+		return TarpitName
+	case teapot:
+		return TeapotName
+	default:
+		panic("invalid tarpit type")
+	}
+}
+
+func (s *tarpitSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	d, err := parseDuration(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &tarpitFilter{typ: s.typ, delay: d}
+	switch s.typ {
+	case tarpit:
+		if d <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.duration = defaultTarpitDuration
+		if len(args) > 1 {
+			if f.duration, err = parseDuration(args[1]); err != nil {
+				return nil, err
+			}
+		}
+	case teapot:
+		f.status = http.StatusTeapot
+		if len(args) > 1 {
+			status, ok := args[1].(float64)
+			if !ok || status < 100 || status > 599 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.status = int(status)
+		}
+	}
+
+	return f, nil
+}
+
+func (b *tarpitBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	wait := b.interval
+	if remaining := time.Until(b.deadline); remaining < wait {
+		wait = remaining
+	}
+
+	if wait <= 0 {
+		return 0, io.EOF
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-b.ctx.Done():
+		return 0, b.ctx.Err()
+	case <-t.C:
+	}
+
+	if !time.Now().Before(b.deadline) {
+		return 0, io.EOF
+	}
+
+	p[0] = ' '
+	return 1, nil
+}
+
+func (*tarpitBody) Close() error { return nil }
+
+func (f *tarpitFilter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	if f.typ == tarpit {
+		ctx.Serve(&http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{"text/plain"}},
+			ContentLength: -1,
+			Body: &tarpitBody{
+				ctx:      req.Context(),
+				interval: f.delay,
+				deadline: time.Now().Add(f.duration),
+			},
+		})
+
+		return
+	}
+
+	t := time.NewTimer(f.delay)
+	defer t.Stop()
+
+	select {
+	case <-req.Context().Done():
+	case <-t.C:
+	}
+
+	ctx.Serve(&http.Response{StatusCode: f.status})
+}
+
+func (*tarpitFilter) Response(filters.FilterContext) {}
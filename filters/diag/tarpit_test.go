@@ -0,0 +1,96 @@
+package diag
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestTarpit(t *testing.T) {
+	p := proxytest.New(filters.Registry{TarpitName: NewTarpit()}, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: TarpitName, Args: []interface{}{"20ms", "110ms"}}},
+		Shunt:   true})
+	defer p.Close()
+
+	start := time.Now()
+	rsp, err := http.Get(p.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < 110*time.Millisecond {
+		t.Errorf("response too fast: %v", d)
+	}
+
+	if len(b) < 3 || len(b) > 5 {
+		t.Errorf("invalid number of bytes dripped: %d", len(b))
+	}
+}
+
+func TestTeapot(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		args   []interface{}
+		status int
+	}{{
+		msg:    "default status",
+		args:   []interface{}{"30ms"},
+		status: http.StatusTeapot,
+	}, {
+		msg:    "custom status",
+		args:   []interface{}{float64(30), float64(429)},
+		status: http.StatusTooManyRequests,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			p := proxytest.New(filters.Registry{TeapotName: NewTeapot()}, &eskip.Route{
+				Filters: []*eskip.Filter{{Name: TeapotName, Args: ti.args}},
+				Shunt:   true})
+			defer p.Close()
+
+			start := time.Now()
+			rsp, err := http.Get(p.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp.Body.Close()
+			if d := time.Since(start); d < 30*time.Millisecond {
+				t.Errorf("response too fast: %v", d)
+			}
+
+			if rsp.StatusCode != ti.status {
+				t.Errorf("invalid status, expected: %d, got: %d", ti.status, rsp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTarpitArgs(t *testing.T) {
+	for _, ti := range []struct {
+		spec filters.Spec
+		args []interface{}
+	}{
+		{NewTarpit(), []interface{}{}},
+		{NewTarpit(), []interface{}{float64(0)}},
+		{NewTarpit(), []interface{}{"1s", "foo"}},
+		{NewTarpit(), []interface{}{"1s", "1m", "foo"}},
+		{NewTeapot(), []interface{}{"foo"}},
+		{NewTeapot(), []interface{}{"1s", float64(600)}},
+		{NewTeapot(), []interface{}{"1s", "418"}},
+	} {
+		if _, err := ti.spec.CreateFilter(ti.args); err == nil {
+			t.Errorf("failed to fail for %s%v", ti.spec.Name(), ti.args)
+		}
+	}
+}
@@ -20,6 +20,34 @@ const (
 
 	// BackendIsProxyKey is the key used in the state bag to notify proxy that the backend is also a proxy.
 	BackendIsProxyKey = "backend:isproxy"
+
+	// BackendTimeoutKey is the key used in the state bag to pass the overall backend request timeout to the proxy.
+	BackendTimeoutKey = "backend:timeout"
+
+	// BackendDialTimeoutKey is the key used in the state bag to pass the backend dial timeout to the proxy.
+	BackendDialTimeoutKey = "backend:timeout:dial"
+
+	// BackendTLSHandshakeTimeoutKey is the key used in the state bag to pass the backend TLS handshake timeout to the proxy.
+	BackendTLSHandshakeTimeoutKey = "backend:timeout:tlshandshake"
+
+	// BackendResponseHeaderTimeoutKey is the key used in the state bag to pass the backend response header timeout to the proxy.
+	BackendResponseHeaderTimeoutKey = "backend:timeout:responseheader"
+
+	// BackendProxyProtocolKey is the key used in the state bag to pass the PROXY protocol version of the backend to the proxy.
+	BackendProxyProtocolKey = "backend:proxyprotocol"
+
+	// BackendEgressProxyKey is the key used in the state bag to pass the egress proxy URL of the backend connections to the proxy.
+	BackendEgressProxyKey = "backend:egressproxy"
+
+	// FastCgiParamsKey is the key used in the state bag to pass the additional FastCGI params of the backend requests to the proxy.
+	FastCgiParamsKey = "backend:fastcgi:params"
+
+	// MaxRequestBodySizeKey is the key used in the state bag to pass the maximum request body size to the proxy.
+	// The proxy sets it to its default limit, when there is one, before executing the filters.
+	MaxRequestBodySizeKey = "request:maxbodysize"
+
+	// AbortConnectionKey is the key used in the state bag to notify the proxy to close the client connection without a response.
+	AbortConnectionKey = "connection:abort"
 )
 
 // Context object providing state and information that is unique to a request.
@@ -72,6 +72,14 @@ Reuse existing flow id
 With a single string parameter with the value "reuse", the filter will accept an existing X-Flow-Id header, if
 it's present in the request. If it's invalid, a new one is generated and the header is overwritten.
 
+Reuse existing flow id from trusted networks
+
+	flowId("reuse", "10.0.0.0/8", "192.168.1.1")
+
+The further string parameters, IP addresses or networks in CIDR notation, limit the reuse of the existing X-Flow-Id
+header to the requests whose direct peer is in one of the trusted networks, e.g. other services or load balancers of
+the platform. From other clients, the existing header is overwritten with a new flow id.
+
 Some Benchmarks
 
 Built-In Flow ID Generator
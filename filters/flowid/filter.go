@@ -3,9 +3,12 @@ package flowid
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"strings"
 
 	"github.com/zalando/skipper/filters"
+	snet "github.com/zalando/skipper/net"
 )
 
 const (
@@ -30,6 +33,7 @@ type flowIdSpec struct {
 
 type flowId struct {
 	reuseExisting bool
+	trusted       []*net.IPNet
 	generator     Generator
 }
 
@@ -60,14 +64,33 @@ func NewWithGenerator(g Generator) *flowIdSpec {
 	return &flowIdSpec{generator: g}
 }
 
+// the existing flow id is reused only from the trusted networks, when they
+// are set. The request is trusted, when it comes from a trusted peer, and
+// all the addresses in the X-Forwarded-For header added by the trusted
+// proxies are trusted, too.
+func (f *flowId) trustedPeer(r *http.Request) bool {
+	if len(f.trusted) == 0 {
+		return true
+	}
+
+	ip := snet.RemoteHostTrusted(r, f.trusted)
+	for _, n := range f.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Request will inspect the current Request for the presence of an X-Flow-Id header which will be kept in case the
-// "reuse" flag has been set. In any other case it will set the same header with the value returned from the
-// defined Flow ID Generator
+// "reuse" flag has been set, and the request comes from a trusted network, when the trusted networks are set. In
+// any other case it will set the same header with the value returned from the defined Flow ID Generator
 func (f *flowId) Request(fc filters.FilterContext) {
 	r := fc.Request()
 	var flowId string
 
-	if f.reuseExisting {
+	if f.reuseExisting && f.trustedPeer(r) {
 		flowId = r.Header.Get(HeaderName)
 		if f.generator.IsValid(flowId) {
 			return
@@ -85,23 +108,54 @@ func (f *flowId) Request(fc filters.FilterContext) {
 // Response is No-Op in this filter
 func (*flowId) Response(filters.FilterContext) {}
 
+// the second argument used to select the generator, before it was deprecated
+func deprecatedArgs(fc []interface{}) bool {
+	switch g := fc[0].(type) {
+	case string:
+		return g == "" || g == "builtin" || g == "ulid"
+	default:
+		return true
+	}
+}
+
 // CreateFilter will return a new flowId filter from the spec
 // If at least 1 argument is present and it contains the value "reuse", the filter instance is configured to accept
-// keep the value of the X-Flow-Id header, if it's already set
+// keep the value of the X-Flow-Id header, if it's already set. The further arguments, when set, are the IP
+// addresses or networks in CIDR notation, from where the existing X-Flow-Id header is accepted
 func (spec *flowIdSpec) CreateFilter(fc []interface{}) (filters.Filter, error) {
 	var reuseExisting bool
+	var trusted []*net.IPNet
 	if len(fc) > 0 {
 		if r, ok := fc[0].(string); ok {
 			reuseExisting = strings.ToLower(r) == ReuseParameterValue
 		} else {
 			return nil, filters.ErrInvalidFilterParameters
 		}
-		if len(fc) > 1 {
+		if len(fc) > 1 && deprecatedArgs(fc[1:]) {
 			log.Println("flow id filter warning: this syntaxt is deprecated and will be removed soon. " +
 				"please check updated docs")
+		} else if len(fc) > 1 {
+			if !reuseExisting {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			var networks []string
+			for _, a := range fc[1:] {
+				n, ok := a.(string)
+				if !ok {
+					return nil, filters.ErrInvalidFilterParameters
+				}
+
+				networks = append(networks, n)
+			}
+
+			var err error
+			if trusted, err = snet.ParseCIDRs(networks); err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
 		}
 	}
-	return &flowId{reuseExisting: reuseExisting, generator: spec.generator}, nil
+	return &flowId{reuseExisting: reuseExisting, trusted: trusted, generator: spec.generator}, nil
 }
 
 // Name returns the canonical filter name
@@ -124,6 +124,55 @@ func TestFlowIdWithCustomGenerators(t *testing.T) {
 	}
 }
 
+func TestFlowIdReuseFromTrustedNetworks(t *testing.T) {
+	f, err := testFlowIdSpec.CreateFilter([]interface{}{ReuseParameterValue, "10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		remoteAddr   string
+		forwardedFor string
+		reused       bool
+	}{
+		{"10.2.3.4:5678", "", true},
+		{"192.168.1.1:5678", "", true},
+		{"192.168.1.2:5678", "", false},
+		{"[2001:db8::1]:5678", "", false},
+		{"invalid", "", false},
+		{"10.2.3.4:5678", "10.5.6.7", true},
+		{"10.2.3.4:5678", "203.0.113.1, 10.5.6.7", false},
+		{"192.168.1.2:5678", "10.5.6.7", false},
+	} {
+		t.Run(test.remoteAddr+" "+test.forwardedFor, func(t *testing.T) {
+			fc := buildfilterContext(HeaderName, testFlowId)
+			fc.Request().RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				fc.Request().Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+
+			f.Request(fc)
+
+			if reused := fc.Request().Header.Get(HeaderName) == testFlowId; reused != test.reused {
+				t.Errorf("invalid reuse of the flow id, expected: %v, got: %v", test.reused, reused)
+			}
+		})
+	}
+}
+
+func TestFlowIdInvalidTrustedNetworks(t *testing.T) {
+	for _, fc := range [][]interface{}{
+		{ReuseParameterValue, "10.0.0.0/33"},
+		{ReuseParameterValue, "10.0.0.0/8", "foo"},
+		{ReuseParameterValue, "10.0.0.0/8", 42},
+		{"dummy", "10.0.0.0/8"},
+	} {
+		if _, err := testFlowIdSpec.CreateFilter(fc); err != filters.ErrInvalidFilterParameters {
+			t.Errorf("expected an invalid parameters error for %v, got %v", fc, err)
+		}
+	}
+}
+
 func buildfilterContext(headers ...string) filters.FilterContext {
 	r, _ := http.NewRequest("GET", "http://example.org", nil)
 	for i := 0; i < len(headers); i += 2 {
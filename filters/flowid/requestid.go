@@ -0,0 +1,120 @@
+package flowid
+
+import (
+	"log"
+
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+)
+
+const (
+	RequestIdName          = "requestId"
+	RequestIdHeaderName    = "X-Request-Id"
+	RequestIdStateKey      = "filter::requestId"
+	RequestIdAccessLogKey  = "request-id"
+	RequestIdTracingTag    = "request_id"
+	UUIDGeneratorParameter = "uuid"
+	ULIDGeneratorParameter = "ulid"
+
+	// longer incoming request IDs are replaced by a generated one
+	maxRequestIdLength = 128
+)
+
+type requestIdSpec struct{}
+
+type requestId struct {
+	header    string
+	generator Generator
+}
+
+// NewRequestId creates a filter spec for the requestId filter. The filter keeps the
+// request ID of the incoming request, or generates a new one when it is missing, and
+// sets it in the request header sent to the backend, and in the response header sent
+// to the client. The request ID is stored in the state bag, added to the additional
+// data of the access log, and set as a tag of the request span.
+// Name: requestId
+func NewRequestId() filters.Spec { return requestIdSpec{} }
+
+func (requestIdSpec) Name() string { return RequestIdName }
+
+// CreateFilter creates a requestId filter. The first, optional, argument is the name of
+// the header, defaulting to X-Request-Id. The second, optional, argument is the format
+// of the generated IDs, "uuid" or "ulid", defaulting to "uuid".
+//
+//     s.CreateFilter()
+//     s.CreateFilter("X-Correlation-Id", "ulid")
+//
+func (requestIdSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &requestId{header: RequestIdHeaderName, generator: NewUUIDGenerator()}
+	if len(args) > 0 {
+		h, ok := args[0].(string)
+		if !ok || !httpguts.ValidHeaderFieldName(h) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.header = h
+	}
+
+	if len(args) > 1 {
+		g, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		switch g {
+		case UUIDGeneratorParameter:
+		case ULIDGeneratorParameter:
+			f.generator = NewULIDGenerator()
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+func validRequestId(id string) bool {
+	return id != "" && len(id) <= maxRequestIdLength && httpguts.ValidHeaderFieldValue(id)
+}
+
+func (f *requestId) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	id := r.Header.Get(f.header)
+	if !validRequestId(id) {
+		var err error
+		if id, err = f.generator.Generate(); err != nil {
+			log.Println(err)
+			return
+		}
+
+		r.Header.Set(f.header, id)
+	}
+
+	bag := ctx.StateBag()
+	bag[RequestIdStateKey] = id
+
+	additional, ok := bag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+	if !ok {
+		additional = make(map[string]interface{})
+		bag[al.AccessLogAdditionalDataKey] = additional
+	}
+
+	additional[RequestIdAccessLogKey] = id
+
+	if span := opentracing.SpanFromContext(r.Context()); span != nil {
+		span.SetTag(RequestIdTracingTag, id)
+	}
+}
+
+func (f *requestId) Response(ctx filters.FilterContext) {
+	if id, ok := ctx.StateBag()[RequestIdStateKey].(string); ok {
+		ctx.Response().Header.Set(f.header, id)
+	}
+}
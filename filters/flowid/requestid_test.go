@@ -0,0 +1,101 @@
+package flowid
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestRequestId(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		args     []interface{}
+		header   string
+		incoming string
+		valid    func(string) bool
+	}{{
+		title:  "generate uuid",
+		header: RequestIdHeaderName,
+		valid:  NewUUIDGenerator().IsValid,
+	}, {
+		title:  "generate ulid on custom header",
+		args:   []interface{}{"X-Correlation-Id", "ulid"},
+		header: "X-Correlation-Id",
+		valid:  NewULIDGenerator().IsValid,
+	}, {
+		title:    "keep incoming",
+		header:   RequestIdHeaderName,
+		incoming: "my-request-id",
+		valid:    func(id string) bool { return id == "my-request-id" },
+	}, {
+		title:    "replace too long incoming",
+		header:   RequestIdHeaderName,
+		incoming: strings.Repeat("x", maxRequestIdLength+1),
+		valid:    NewUUIDGenerator().IsValid,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f, err := NewRequestId().CreateFilter(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest("GET", "https://www.example.org", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.incoming != "" {
+				req.Header.Set(test.header, test.incoming)
+			}
+
+			tracer := mocktracer.New()
+			span := tracer.StartSpan("test")
+			req = req.WithContext(opentracing.ContextWithSpan(req.Context(), span))
+
+			ctx := &filtertest.Context{
+				FRequest:  req,
+				FResponse: &http.Response{Header: make(http.Header)},
+				FStateBag: make(map[string]interface{}),
+			}
+
+			f.Request(ctx)
+			id := req.Header.Get(test.header)
+			if !test.valid(id) {
+				t.Fatalf("invalid request id: %q", id)
+			}
+
+			if tag := span.(*mocktracer.MockSpan).Tag(RequestIdTracingTag); tag != id {
+				t.Errorf("invalid tracing tag, expected: %q, got: %v", id, tag)
+			}
+
+			additional := ctx.StateBag()[al.AccessLogAdditionalDataKey].(map[string]interface{})
+			if additional[RequestIdAccessLogKey] != id {
+				t.Errorf("invalid access log data, expected: %q, got: %v", id, additional[RequestIdAccessLogKey])
+			}
+
+			f.Response(ctx)
+			if rid := ctx.Response().Header.Get(test.header); rid != id {
+				t.Errorf("invalid response header, expected: %q, got: %q", id, rid)
+			}
+		})
+	}
+}
+
+func TestRequestIdArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{42},
+		{"X Request Id"},
+		{"X-Request-Id", "standard"},
+		{"X-Request-Id", "uuid", "foo"},
+	} {
+		if _, err := NewRequestId().CreateFilter(args); err == nil {
+			t.Errorf("failed to fail for %v", args)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package flowid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+)
+
+type uuidGenerator struct{}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// NewUUIDGenerator returns a flow ID generator that generates random,
+// version 4 UUIDs, in their canonical, lowercase format. It uses the
+// system's cryptographically secure source of entropy. It is safe for
+// concurrent usage.
+func NewUUIDGenerator() Generator {
+	return uuidGenerator{}
+}
+
+// Generate returns a random UUID or an empty string in case of failure
+func (uuidGenerator) Generate() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	// version 4 and the RFC 4122 variant
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// MustGenerate behaves like Generate but panics in case of failure
+func (g uuidGenerator) MustGenerate() string {
+	id, err := g.Generate()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// IsValid checks if the given id is a version 4 UUID in the canonical format
+func (uuidGenerator) IsValid(id string) bool {
+	return uuidRegex.MatchString(id)
+}
@@ -0,0 +1,40 @@
+package flowid
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUUIDGenerator(t *testing.T) {
+	g := NewUUIDGenerator()
+	id, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !g.IsValid(id) {
+		t.Errorf("generated id was not considered valid - %q", id)
+	}
+
+	if other := g.MustGenerate(); other == id {
+		t.Errorf("generated the same id twice - %q", id)
+	}
+}
+
+func TestInvalidUUIDs(t *testing.T) {
+	g := NewUUIDGenerator()
+	for _, test := range []string{
+		"",
+		"12345",
+		"f47ac10b-58cc-1372-a567-0e02b2c3d479",
+		"f47ac10b-58cc-4372-c567-0e02b2c3d479",
+		"F47AC10B-58CC-4372-A567-0E02B2C3D479",
+		"f47ac10b58cc4372a5670e02b2c3d479",
+	} {
+		t.Run(fmt.Sprintf("%v", test), func(t *testing.T) {
+			if g.IsValid(test) {
+				t.Errorf("invalid input was considered valid %q", test)
+			}
+		})
+	}
+}
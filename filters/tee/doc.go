@@ -21,5 +21,12 @@ It is possible to change the path of the tee request, in a similar way to the mo
 	Path("/api/v1") -> tee("https://api.example.org", "^/v1", "/v2" ) -> "http://api.example.org"
 
 In the above example, one can test how a new version of an API would behave on incoming requests.
+
+Optionally, the last argument can set the ratio of the requests that are mirrored to the shadow backend,
+between 0 and 1:
+
+	* -> tee("https://api.example.org", 0.1) -> "http://api.example.org"
+
+In the above example, one in ten requests on average is sent to the shadow backend, too.
 */
 package tee
@@ -3,6 +3,7 @@ package tee
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -16,6 +17,9 @@ const (
 	Name           = "tee"
 	DeprecatedName = "Tee"
 	NoFollowName   = "teenf"
+
+	// NoBodyArg, when set, the shadow request is sent without the body
+	NoBodyArg = "no-body"
 )
 
 const defaultTeeTimeout = time.Second
@@ -49,6 +53,8 @@ type tee struct {
 	scheme            string
 	rx                *regexp.Regexp
 	replacement       string
+	ratio             float64
+	noBody            bool
 	shadowRequestDone func() // test hook
 }
 
@@ -143,6 +149,10 @@ func (r *tee) Response(filters.FilterContext) {}
 
 // Request is copied and then modified to adopt changes in new backend
 func (r *tee) Request(fc filters.FilterContext) {
+	if r.ratio < 1 && rand.Float64() >= r.ratio {
+		return
+	}
+
 	req := fc.Request()
 	copyOfRequest, tr, err := cloneRequest(r, req)
 	if err != nil {
@@ -194,7 +204,7 @@ func cloneRequest(t *tee, req *http.Request) (*http.Request, io.ReadCloser, erro
 	mainBody := req.Body
 
 	// see proxy.go:231
-	if req.ContentLength != 0 {
+	if req.ContentLength != 0 && !t.noBody {
 		pr, pw := io.Pipe()
 		teeBody = pr
 		mainBody = &teeTie{mainBody, pw}
@@ -207,7 +217,9 @@ func cloneRequest(t *tee, req *http.Request) (*http.Request, io.ReadCloser, erro
 
 	clone.Header = h
 	clone.Host = t.host
-	clone.ContentLength = req.ContentLength
+	if !t.noBody {
+		clone.ContentLength = req.ContentLength
+	}
 
 	return clone, mainBody, nil
 }
@@ -215,6 +227,8 @@ func cloneRequest(t *tee, req *http.Request) (*http.Request, io.ReadCloser, erro
 // Creates out tee Filter
 // If only one parameter is given shadow backend is used as it is specified
 // If second and third parameters are also set, then path is modified
+// If the last parameter is a number, it is the ratio of the mirrored requests
+// If the last parameter before the ratio is "no-body", the body is not mirrored
 func (spec *teeSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
 	client := &http.Client{Timeout: spec.options.Timeout}
 
@@ -224,11 +238,26 @@ func (spec *teeSpec) CreateFilter(config []interface{}) (filters.Filter, error)
 		}
 	}
 
-	tee := tee{client: client}
+	tee := tee{client: client, ratio: 1}
 
 	if len(config) == 0 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
+
+	if ratio, ok := config[len(config)-1].(float64); ok && len(config) > 1 {
+		if ratio <= 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid filter config in %s, expecting ratio between 0 and 1, got: %v", Name, ratio)
+		}
+
+		tee.ratio = ratio
+		config = config[:len(config)-1]
+	}
+
+	// the flag follows the backend, or the path regexp and replacement
+	if len(config)%2 == 0 && config[len(config)-1] == NoBodyArg {
+		tee.noBody = true
+		config = config[:len(config)-1]
+	}
 	backend, ok := config[0].(string)
 	if !ok {
 		return nil, filters.ErrInvalidFilterParameters
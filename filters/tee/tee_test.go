@@ -171,6 +171,48 @@ func TestTeeEndToEndBody(t *testing.T) {
 	}
 }
 
+func TestTeeEndToEndNoBody(t *testing.T) {
+	shadowHandler := newTestHandler(t, "shadow")
+	shadowServer := httptest.NewServer(shadowHandler)
+	defer shadowServer.Close()
+
+	originalHandler := newTestHandler(t, "original")
+	originalServer := httptest.NewServer(originalHandler)
+	defer originalServer.Close()
+
+	routeStr := fmt.Sprintf(`route1: * -> tee("%v", "no-body") -> "%v";`, shadowServer.URL, originalServer.URL)
+
+	route, _ := eskip.Parse(routeStr)
+	registry := make(filters.Registry)
+	registry.Register(NewTee())
+	p := proxytest.New(registry, route...)
+	defer p.Close()
+
+	testingStr := "TESTEST"
+	req, err := http.NewRequest("POST", p.URL, strings.NewReader(testingStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("X-Test", "foo")
+	req.Close = true
+	rsp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-shadowHandler.served
+
+	rsp.Body.Close()
+	if shadowHandler.body != "" || originalHandler.body != testingStr {
+		t.Errorf("invalid bodies, shadow: %q, original: %q", shadowHandler.body, originalHandler.body)
+	}
+
+	if shadowHandler.header.Get("X-Test") != "foo" {
+		t.Error("failed to send the headers to the shadow backend")
+	}
+}
+
 func TestTeeFollowOrNot(t *testing.T) {
 	for _, follow := range []bool{
 		true,
@@ -340,6 +382,54 @@ func TestTeeArgsForFailure(t *testing.T) {
 			[]interface{}{"http://example.com", `\`, "/api"},
 			true,
 		},
+
+		{
+			"ratio",
+			[]interface{}{"http://example.com", 0.5},
+			false,
+		},
+
+		{
+			"ratio with modified path",
+			[]interface{}{"http://example.com", ".*", "/api", 0.5},
+			false,
+		},
+
+		{
+			"error on zero ratio",
+			[]interface{}{"http://example.com", 0.0},
+			true,
+		},
+
+		{
+			"error on ratio greater than 1",
+			[]interface{}{"http://example.com", 1.5},
+			true,
+		},
+
+		{
+			"error on ratio only",
+			[]interface{}{0.5},
+			true,
+		},
+
+		{
+			"no body",
+			[]interface{}{"http://example.com", NoBodyArg},
+			false,
+		},
+
+		{
+			"no body with modified path and ratio",
+			[]interface{}{"http://example.com", ".*", "/api", NoBodyArg, 0.5},
+			false,
+		},
+
+		{
+			"error on no body before the modified path",
+			[]interface{}{"http://example.com", NoBodyArg, ".*", "/api"},
+			true,
+		},
 	} {
 		_, err := NewTee().CreateFilter(ti.args)
 
@@ -358,6 +448,47 @@ func TestTeeArgsForFailure(t *testing.T) {
 	}
 }
 
+func TestTeeRatio(t *testing.T) {
+	const requests = 200
+
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer shadow.Close()
+
+	f, err := NewTee().CreateFilter([]interface{}{shadow.URL, 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{}, requests)
+	f.(*tee).shadowRequestDone = func() { done <- struct{}{} }
+
+	var mirrored int
+	for i := 0; i < requests; i++ {
+		req, err := http.NewRequest("POST", "http://example.org", strings.NewReader("foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.Request(&filtertest.Context{FRequest: req})
+		if _, ok := req.Body.(*teeTie); ok {
+			mirrored++
+		}
+
+		// reading the main body feeds the shadow request
+		if _, err := ioutil.ReadAll(req.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < mirrored; i++ {
+		<-done
+	}
+
+	if mirrored < requests/4 || mirrored > 3*requests/4 {
+		t.Errorf("invalid number of mirrored requests: %d of %d", mirrored, requests)
+	}
+}
+
 func TestName(t *testing.T) {
 	for _, ti := range []struct {
 		spec filters.Spec
@@ -0,0 +1,157 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+func currentCall(ctx context.Context) *call {
+	return ctx.Value(callKey{}).(*call)
+}
+
+// the panics in the host functions are returned by the wazero runtime as
+// the error of the filter function call
+func read(m api.Module, ptr, size uint32) []byte {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(errOutOfRange)
+	}
+
+	return b
+}
+
+func readString(m api.Module, ptr, size uint32) string {
+	return string(read(m, ptr, size))
+}
+
+// copies as much of the value into the buffer as fits, and returns the
+// full length of the value
+func write(m api.Module, buf, bufLen uint32, value string) int32 {
+	n := len(value)
+	if n > int(bufLen) {
+		n = int(bufLen)
+	}
+
+	if !m.Memory().Write(buf, []byte(value[:n])) {
+		panic(errOutOfRange)
+	}
+
+	return int32(len(value))
+}
+
+func writeHeader(m api.Module, h http.Header, name, nameLen, buf, bufLen uint32) int32 {
+	values, ok := h[http.CanonicalHeaderKey(readString(m, name, nameLen))]
+	if !ok || len(values) == 0 {
+		return -1
+	}
+
+	return write(m, buf, bufLen, values[0])
+}
+
+func responseHeader(c *call) http.Header {
+	if !c.response || c.ctx.Response() == nil {
+		return nil
+	}
+
+	return c.ctx.Response().Header
+}
+
+func hostModule(r wazero.Runtime) wazero.HostModuleBuilder {
+	b := r.NewHostModuleBuilder(importModule)
+	export := func(name string, f interface{}) {
+		b.NewFunctionBuilder().WithFunc(f).Export(name)
+	}
+
+	export("get_arg", func(ctx context.Context, m api.Module, index, buf, bufLen uint32) int32 {
+		c := currentCall(ctx)
+		if int(index) >= len(c.args) {
+			return -1
+		}
+
+		return write(m, buf, bufLen, c.args[index])
+	})
+
+	export("get_method", func(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+		return write(m, buf, bufLen, currentCall(ctx).ctx.Request().Method)
+	})
+
+	export("get_path", func(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+		return write(m, buf, bufLen, currentCall(ctx).ctx.Request().URL.Path)
+	})
+
+	export("set_path", func(ctx context.Context, m api.Module, path, pathLen uint32) {
+		currentCall(ctx).ctx.Request().URL.Path = readString(m, path, pathLen)
+	})
+
+	export("get_request_header", func(ctx context.Context, m api.Module, name, nameLen, buf, bufLen uint32) int32 {
+		return writeHeader(m, currentCall(ctx).ctx.Request().Header, name, nameLen, buf, bufLen)
+	})
+
+	export("set_request_header", func(ctx context.Context, m api.Module, name, nameLen, value, valueLen uint32) {
+		currentCall(ctx).ctx.Request().Header.Set(readString(m, name, nameLen), readString(m, value, valueLen))
+	})
+
+	export("del_request_header", func(ctx context.Context, m api.Module, name, nameLen uint32) {
+		currentCall(ctx).ctx.Request().Header.Del(readString(m, name, nameLen))
+	})
+
+	export("get_status", func(ctx context.Context) int32 {
+		c := currentCall(ctx)
+		if !c.response || c.ctx.Response() == nil {
+			return 0
+		}
+
+		return int32(c.ctx.Response().StatusCode)
+	})
+
+	export("set_status", func(ctx context.Context, status uint32) {
+		c := currentCall(ctx)
+		if c.response && c.ctx.Response() != nil {
+			c.ctx.Response().StatusCode = int(status)
+		}
+	})
+
+	export("get_response_header", func(ctx context.Context, m api.Module, name, nameLen, buf, bufLen uint32) int32 {
+		h := responseHeader(currentCall(ctx))
+		if h == nil {
+			return -1
+		}
+
+		return writeHeader(m, h, name, nameLen, buf, bufLen)
+	})
+
+	export("set_response_header", func(ctx context.Context, m api.Module, name, nameLen, value, valueLen uint32) {
+		if h := responseHeader(currentCall(ctx)); h != nil {
+			h.Set(readString(m, name, nameLen), readString(m, value, valueLen))
+		}
+	})
+
+	export("del_response_header", func(ctx context.Context, m api.Module, name, nameLen uint32) {
+		if h := responseHeader(currentCall(ctx)); h != nil {
+			h.Del(readString(m, name, nameLen))
+		}
+	})
+
+	export("serve", func(ctx context.Context, m api.Module, status, body, bodyLen uint32) {
+		c := currentCall(ctx)
+		if c.response {
+			return
+		}
+
+		// the memory is released when the instance is closed
+		b := append([]byte(nil), read(m, body, bodyLen)...)
+		c.serve = &http.Response{
+			StatusCode:    int(status),
+			Header:        make(http.Header),
+			Body:          ioutil.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+		}
+	})
+
+	return b
+}
@@ -0,0 +1,338 @@
+/*
+Package wasm implements the wasm filter, which executes filters compiled to
+WebAssembly, e.g. from Rust, Go (TinyGo) or AssemblyScript.
+
+The modules are executed with the wazero runtime, in the same process as
+skipper, but isolated from it: a module can access only its own linear
+memory, and the request and the response through the functions imported
+from the "skipper" module. Every execution of a filter function happens in
+a new instance of the module, so no state is shared between the requests,
+or between the request and the response phase of the same request. The
+execution time and the memory of the instances are limited.
+
+The filter expects the path of the module file as the first argument, and
+optionally further string arguments, that the module can read:
+
+	auth: * -> wasm("/etc/skipper/filters/auth.wasm", "tenant-a") -> "https://www.example.org"
+
+The module file is compiled when the route is created, and compiled again
+when the routes are updated and the file has changed since, so a module
+can be swapped on a route without restarting skipper.
+
+The ABI
+
+The module needs to export its memory as "memory", and at least one of
+the filter functions, "request" and "response". The filter functions
+don't take arguments and don't return values. When the module exports an
+"_initialize" function, it is called after every instantiation. The WASI
+snapshot preview 1 functions are available, without access to the file
+system, the environment variables or the command line arguments.
+
+The module can import the following functions from the "skipper" module.
+All the parameters and the return values are 32 bit integers. The strings
+are passed as a pointer into the linear memory and a length. The functions
+returning strings copy the value into the provided buffer, as much of it
+as fits, and return the full length of the value, or -1 when the value
+doesn't exist:
+
+	get_arg(index, buf, buf_len) -> len
+	get_method(buf, buf_len) -> len
+	get_path(buf, buf_len) -> len
+	set_path(path, path_len)
+	get_request_header(name, name_len, buf, buf_len) -> len
+	set_request_header(name, name_len, value, value_len)
+	del_request_header(name, name_len)
+	get_status() -> status
+	set_status(status)
+	get_response_header(name, name_len, buf, buf_len) -> len
+	set_response_header(name, name_len, value, value_len)
+	del_response_header(name, name_len)
+	serve(status, body, body_len)
+
+The status and the response headers are available only in the response
+phase, otherwise get_status returns 0, get_response_header returns -1, and
+the setters have no effect. The serve function is available only in the
+request phase, and it responds to the request without calling the backend.
+
+When a filter function fails, e.g. because it exceeded the execution time
+or the memory limit, the request phase responds with 500 Internal Server
+Error, while the response phase leaves the response unchanged. The errors
+are logged.
+
+The filter is available in skipper when it is built with the wasmfilter
+build tag:
+
+	go build -tags wasmfilter ./cmd/skipper
+*/
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	// Name is the name of the wasm filter.
+	Name = "wasm"
+
+	// DefaultTimeout is the default execution time limit of a filter
+	// function.
+	DefaultTimeout = 100 * time.Millisecond
+
+	// DefaultMemoryLimit is the default memory limit of a module
+	// instance, in bytes.
+	DefaultMemoryLimit = 16 << 20
+
+	importModule = "skipper"
+	wasmPageSize = 64 << 10
+)
+
+var (
+	errMissingFunctions = errors.New("the module exports neither a request nor a response function")
+	errMissingMemory    = errors.New("the module doesn't export its memory")
+	errInvalidSignature = errors.New("the filter functions need to have no parameters and no results")
+	errOutOfRange       = errors.New("memory access out of range")
+)
+
+// Options configures the wasm filter specification.
+type Options struct {
+
+	// Timeout is the execution time limit of a filter function.
+	// Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// MemoryLimit is the maximum size of the linear memory of a
+	// module instance, in bytes, rounded up to 64KB pages. Defaults
+	// to DefaultMemoryLimit.
+	MemoryLimit int
+}
+
+// the compiled version of a module file
+type module struct {
+	modTime  time.Time
+	size     int64
+	compiled wazero.CompiledModule
+	request  bool
+	response bool
+}
+
+type spec struct {
+	timeout time.Duration
+	runtime wazero.Runtime
+	config  wazero.ModuleConfig
+	mx      sync.Mutex
+	modules map[string]*module
+}
+
+type filter struct {
+	spec   *spec
+	module *module
+	args   []string
+}
+
+// the context of a filter function call, available to the imported
+// functions
+type call struct {
+	ctx      filters.FilterContext
+	args     []string
+	response bool
+	serve    *http.Response
+}
+
+type callKey struct{}
+
+// New creates the specification of the wasm filter with the default
+// options.
+func New() filters.Spec {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates the specification of the wasm filter.
+func NewWithOptions(o Options) filters.Spec {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+
+	if o.MemoryLimit <= 0 {
+		o.MemoryLimit = DefaultMemoryLimit
+	}
+
+	pages := uint32((o.MemoryLimit + wasmPageSize - 1) / wasmPageSize)
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(pages))
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	if _, err := hostModule(r).Instantiate(ctx); err != nil {
+		// the host module is static, it cannot fail
+		panic(err)
+	}
+
+	return &spec{
+		timeout: o.Timeout,
+		runtime: r,
+
+		// anonymous instances, so that the same module can be
+		// instantiated concurrently
+		config: wazero.NewModuleConfig().
+			WithName("").
+			WithStartFunctions("_initialize"),
+
+		modules: make(map[string]*module),
+	}
+}
+
+func (*spec) Name() string { return Name }
+
+func isFilterFunction(f api.FunctionDefinition) bool {
+	return len(f.ParamTypes()) == 0 && len(f.ResultTypes()) == 0
+}
+
+func (s *spec) compile(name string, fi os.FileInfo) (*module, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := s.runtime.CompileModule(context.Background(), b)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &module{modTime: fi.ModTime(), size: fi.Size(), compiled: compiled}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		compiled.Close(context.Background())
+		return nil, errMissingMemory
+	}
+
+	functions := compiled.ExportedFunctions()
+	for n, flag := range map[string]*bool{"request": &m.request, "response": &m.response} {
+		f, ok := functions[n]
+		if !ok {
+			continue
+		}
+
+		if !isFilterFunction(f) {
+			compiled.Close(context.Background())
+			return nil, errInvalidSignature
+		}
+
+		*flag = true
+	}
+
+	if !m.request && !m.response {
+		compiled.Close(context.Background())
+		return nil, errMissingFunctions
+	}
+
+	return m, nil
+}
+
+// returns the compiled module, compiling it when it was not compiled yet,
+// or the file changed since. The previous versions of a module are not
+// released, because the filters of the previous routing table may still
+// use them.
+func (s *spec) module(name string) (*module, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if m, ok := s.modules[name]; ok && m.modTime.Equal(fi.ModTime()) && m.size == fi.Size() {
+		return m, nil
+	}
+
+	m, err := s.compile(name, fi)
+	if err != nil {
+		return nil, err
+	}
+
+	s.modules[name] = m
+	return m, nil
+}
+
+// CreateFilter creates a wasm filter. The first argument is the path of
+// the module file, the rest of the arguments are strings that the module
+// can read with get_arg.
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var sargs []string
+	for _, a := range args {
+		sa, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		sargs = append(sargs, sa)
+	}
+
+	m, err := s.module(sargs[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wasm module %s: %w", sargs[0], err)
+	}
+
+	return &filter{spec: s, module: m, args: sargs[1:]}, nil
+}
+
+func (f *filter) call(ctx filters.FilterContext, name string, c *call) error {
+	cc, cancel := context.WithTimeout(ctx.Request().Context(), f.spec.timeout)
+	defer cancel()
+
+	cc = context.WithValue(cc, callKey{}, c)
+	instance, err := f.spec.runtime.InstantiateModule(cc, f.module.compiled, f.spec.config)
+	if err != nil {
+		return err
+	}
+
+	defer instance.Close(context.Background())
+	_, err = instance.ExportedFunction(name).Call(cc)
+	return err
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	if !f.module.request {
+		return
+	}
+
+	c := &call{ctx: ctx, args: f.args}
+	if err := f.call(ctx, "request", c); err != nil {
+		log.Errorf("Error while executing the request function of a wasm filter: %v.", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	if c.serve != nil {
+		ctx.Serve(c.serve)
+	}
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	if !f.module.response {
+		return
+	}
+
+	c := &call{ctx: ctx, args: f.args, response: true}
+	if err := f.call(ctx, "response", c); err != nil {
+		log.Errorf("Error while executing the response function of a wasm filter: %v.", err)
+	}
+}
@@ -0,0 +1,515 @@
+package wasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+// a minimal encoder of WebAssembly binary modules, with i32 only
+// functions, to avoid checking in compiled test modules
+
+var signatures = map[string][2]int{
+	"get_arg":             {3, 1},
+	"get_method":          {2, 1},
+	"get_path":            {2, 1},
+	"set_path":            {2, 0},
+	"get_request_header":  {4, 1},
+	"set_request_header":  {4, 0},
+	"del_request_header":  {2, 0},
+	"get_status":          {0, 1},
+	"set_status":          {1, 0},
+	"get_response_header": {4, 1},
+	"set_response_header": {4, 0},
+	"del_response_header": {2, 0},
+	"serve":               {3, 0},
+}
+
+type testFunc struct {
+	name    string
+	params  int
+	results int
+	code    []byte
+}
+
+type testModule struct {
+	imports     []string
+	funcs       []testFunc
+	memoryPages int
+	data        map[uint32]string
+}
+
+func uleb(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func sleb(v int32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+
+		b = append(b, c|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	b := uleb(uint32(len(items)))
+	for _, i := range items {
+		b = append(b, i...)
+	}
+
+	return b
+}
+
+func name(s string) []byte {
+	return append(uleb(uint32(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, uleb(uint32(len(content)))...), content...)
+}
+
+func funcType(params, results int) []byte {
+	i32s := func(n int) []byte { return append(uleb(uint32(n)), bytes.Repeat([]byte{0x7f}, n)...) }
+	return code([]byte{0x60}, i32s(params), i32s(results))
+}
+
+func i32(v int32) []byte { return append([]byte{0x41}, sleb(v)...) }
+
+func code(instructions ...[]byte) []byte { return bytes.Join(instructions, nil) }
+
+// returns the call instruction of an imported function
+func (m testModule) call(f string) []byte {
+	for i, n := range m.imports {
+		if n == f {
+			return append([]byte{0x10}, uleb(uint32(i))...)
+		}
+	}
+
+	panic("missing import: " + f)
+}
+
+func (m testModule) encode() []byte {
+	var types, imports, functions, exports, codes, data [][]byte
+	for _, n := range m.imports {
+		s := signatures[n]
+		imports = append(imports, code(name(importModule), name(n), []byte{0x00}, uleb(uint32(len(types)))))
+		types = append(types, funcType(s[0], s[1]))
+	}
+
+	if m.memoryPages > 0 {
+		exports = append(exports, code(name("memory"), []byte{0x02, 0x00}))
+	}
+
+	for i, f := range m.funcs {
+		functions = append(functions, uleb(uint32(len(types))))
+		types = append(types, funcType(f.params, f.results))
+		exports = append(exports, code(name(f.name), []byte{0x00}, uleb(uint32(len(m.imports)+i))))
+		body := code([]byte{0x00}, f.code, []byte{0x0b})
+		codes = append(codes, append(uleb(uint32(len(body))), body...))
+	}
+
+	for offset, value := range m.data {
+		data = append(data, code([]byte{0x00}, i32(int32(offset)), []byte{0x0b}, name(value)))
+	}
+
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	b = append(b, section(1, vec(types...))...)
+	b = append(b, section(2, vec(imports...))...)
+	b = append(b, section(3, vec(functions...))...)
+	if m.memoryPages > 0 {
+		b = append(b, section(5, vec(code([]byte{0x00}, uleb(uint32(m.memoryPages)))))...)
+	}
+
+	b = append(b, section(7, vec(exports...))...)
+	b = append(b, section(10, vec(codes...))...)
+	return append(b, section(11, vec(data...))...)
+}
+
+// memory layout of the test modules
+const (
+	fooName = 0
+	barName = 16
+	body    = 32
+	buf     = 64
+	bufLen  = 64
+)
+
+var testData = map[uint32]string{
+	fooName: "X-Foo",
+	barName: "X-Bar",
+	body:    "I'm a teapot",
+}
+
+func requestModule(imports []string, instructions ...[]byte) testModule {
+	return testModule{
+		imports:     imports,
+		memoryPages: 1,
+		data:        testData,
+		funcs:       []testFunc{{name: "request", code: code(instructions...)}},
+	}
+}
+
+// copies the X-Foo request header to X-Bar
+func copyHeaderModule() testModule {
+	m := testModule{imports: []string{"get_request_header", "set_request_header"}}
+	return requestModule(
+		m.imports,
+		i32(barName), i32(5), i32(buf),
+		i32(fooName), i32(5), i32(buf), i32(bufLen), m.call("get_request_header"),
+		m.call("set_request_header"),
+	)
+}
+
+// sets the X-Bar request header to the first filter argument
+func argModule() testModule {
+	m := testModule{imports: []string{"get_arg", "set_request_header"}}
+	return requestModule(
+		m.imports,
+		i32(barName), i32(5), i32(buf),
+		i32(0), i32(buf), i32(bufLen), m.call("get_arg"),
+		m.call("set_request_header"),
+	)
+}
+
+func serveModule() testModule {
+	m := testModule{imports: []string{"serve"}}
+	return requestModule(m.imports, i32(http.StatusTeapot), i32(body), i32(12), m.call("serve"))
+}
+
+// increments the response status, and sets the X-Bar response header to
+// the X-Foo request header
+func responseModule() testModule {
+	m := testModule{imports: []string{
+		"get_status",
+		"set_status",
+		"get_request_header",
+		"set_response_header",
+	}}
+
+	m.memoryPages = 1
+	m.data = testData
+	m.funcs = []testFunc{{
+		name: "response",
+		code: code(
+			m.call("get_status"), i32(1), []byte{0x6a}, m.call("set_status"),
+			i32(barName), i32(5), i32(buf),
+			i32(fooName), i32(5), i32(buf), i32(bufLen), m.call("get_request_header"),
+			m.call("set_response_header"),
+		),
+	}}
+
+	return m
+}
+
+func writeModule(t *testing.T, dir, file string, m testModule) string {
+	p := filepath.Join(dir, file)
+	if err := ioutil.WriteFile(p, m.encode(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func tempDir(t *testing.T) string {
+	d, err := ioutil.TempDir("", "skipper-wasm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func createFilter(t *testing.T, s filters.Spec, args ...interface{}) filters.Filter {
+	f, err := s.CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func testContext() *filtertest.Context {
+	r := httptest.NewRequest("GET", "https://www.example.org/foo", nil)
+	r.Header.Set("X-Foo", "foo")
+	return &filtertest.Context{
+		FRequest:  r,
+		FStateBag: make(map[string]interface{}),
+	}
+}
+
+func TestRequest(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	f := createFilter(t, New(), writeModule(t, d, "copy.wasm", copyHeaderModule()))
+	ctx := testContext()
+	f.Request(ctx)
+	if ctx.FServed {
+		t.Fatal("unexpectedly served")
+	}
+
+	if h := ctx.FRequest.Header.Get("X-Bar"); h != "foo" {
+		t.Errorf("invalid header value, expected: foo, got: %s", h)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	p := writeModule(t, d, "arg.wasm", argModule())
+	f := createFilter(t, New(), p, "bar")
+	ctx := testContext()
+	f.Request(ctx)
+	if h := ctx.FRequest.Header.Get("X-Bar"); h != "bar" {
+		t.Errorf("invalid header value, expected: bar, got: %s", h)
+	}
+
+	t.Run("missing arg", func(t *testing.T) {
+		f := createFilter(t, New(), p)
+		ctx := testContext()
+		f.Request(ctx)
+
+		// get_arg returns -1, which is an invalid length
+		if ctx.FResponse == nil || ctx.FResponse.StatusCode != http.StatusInternalServerError {
+			t.Error("failed to fail")
+		}
+	})
+}
+
+func TestServe(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	f := createFilter(t, New(), writeModule(t, d, "serve.wasm", serveModule()))
+	ctx := testContext()
+	f.Request(ctx)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusTeapot {
+		t.Fatal("failed to serve")
+	}
+
+	b, err := ioutil.ReadAll(ctx.FResponse.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "I'm a teapot" {
+		t.Errorf("invalid body: %s", string(b))
+	}
+}
+
+func TestResponse(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	f := createFilter(t, New(), writeModule(t, d, "response.wasm", responseModule()))
+	ctx := testContext()
+	f.Request(ctx)
+	if ctx.FServed {
+		t.Fatal("unexpectedly served")
+	}
+
+	ctx.FResponse = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	f.Response(ctx)
+	if ctx.FResponse.StatusCode != http.StatusCreated {
+		t.Errorf("invalid status, expected: %d, got: %d", http.StatusCreated, ctx.FResponse.StatusCode)
+	}
+
+	if h := ctx.FResponse.Header.Get("X-Bar"); h != "foo" {
+		t.Errorf("invalid header value, expected: foo, got: %s", h)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	loop := requestModule(nil, []byte{0x03, 0x40, 0x0c, 0x00, 0x0b})
+
+	var outOfRange testModule
+	outOfRange.imports = []string{"get_request_header"}
+	outOfRange = requestModule(
+		outOfRange.imports,
+		i32(fooName), i32(5), i32(1<<30), i32(bufLen), outOfRange.call("get_request_header"),
+		[]byte{0x1a},
+	)
+
+	for _, test := range []struct {
+		title  string
+		module testModule
+	}{{
+		title:  "timeout",
+		module: loop,
+	}, {
+		title:  "memory access out of range",
+		module: outOfRange,
+	}, {
+		title:  "trap",
+		module: requestModule(nil, []byte{0x00}),
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s := NewWithOptions(Options{Timeout: 30 * time.Millisecond})
+			f := createFilter(t, s, writeModule(t, d, "failure.wasm", test.module))
+			ctx := testContext()
+
+			done := make(chan struct{})
+			go func() {
+				f.Request(ctx)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(3 * time.Second):
+				t.Fatal("the filter function was not stopped")
+			}
+
+			if ctx.FResponse == nil || ctx.FResponse.StatusCode != http.StatusInternalServerError {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	noMemory := copyHeaderModule()
+	noMemory.memoryPages = 0
+	noMemory.data = nil
+
+	noFunctions := copyHeaderModule()
+	noFunctions.funcs[0].name = "foo"
+
+	invalidSignature := copyHeaderModule()
+	invalidSignature.funcs[0].params = 1
+
+	notModule := filepath.Join(d, "invalid.wasm")
+	if err := ioutil.WriteFile(notModule, []byte("not a module"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title string
+		spec  filters.Spec
+		args  []interface{}
+	}{{
+		title: "no args",
+		spec:  New(),
+	}, {
+		title: "not string",
+		spec:  New(),
+		args:  []interface{}{writeModule(t, d, "copy.wasm", copyHeaderModule()), 42},
+	}, {
+		title: "missing file",
+		spec:  New(),
+		args:  []interface{}{filepath.Join(d, "missing.wasm")},
+	}, {
+		title: "invalid module",
+		spec:  New(),
+		args:  []interface{}{notModule},
+	}, {
+		title: "no memory",
+		spec:  New(),
+		args:  []interface{}{writeModule(t, d, "no-memory.wasm", noMemory)},
+	}, {
+		title: "no functions",
+		spec:  New(),
+		args:  []interface{}{writeModule(t, d, "no-functions.wasm", noFunctions)},
+	}, {
+		title: "invalid signature",
+		spec:  New(),
+		args:  []interface{}{writeModule(t, d, "invalid-signature.wasm", invalidSignature)},
+	}, {
+		title: "memory over the limit",
+		spec:  NewWithOptions(Options{MemoryLimit: 1 << 10}),
+		args:  []interface{}{writeModule(t, d, "large.wasm", testModule{memoryPages: 2, funcs: []testFunc{{name: "request"}}})},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := test.spec.CreateFilter(test.args); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
+
+func TestUpdateModule(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	s := New()
+	p := writeModule(t, d, "filter.wasm", copyHeaderModule())
+	f1 := createFilter(t, s, p)
+	if f := createFilter(t, s, p); f.(*filter).module != f1.(*filter).module {
+		t.Error("failed to reuse the compiled module")
+	}
+
+	writeModule(t, d, "filter.wasm", serveModule())
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(p, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	f2 := createFilter(t, s, p)
+
+	ctx := testContext()
+	f1.Request(ctx)
+	if ctx.FServed || ctx.FRequest.Header.Get("X-Bar") != "foo" {
+		t.Error("the previous filter failed to keep the previous module")
+	}
+
+	ctx = testContext()
+	f2.Request(ctx)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusTeapot {
+		t.Error("failed to update the module")
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(d)
+
+	f := createFilter(t, New(), writeModule(t, d, "copy.wasm", copyHeaderModule()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := testContext()
+			f.Request(ctx)
+			if ctx.FServed || ctx.FRequest.Header.Get("X-Bar") != "foo" {
+				t.Error("failed to execute the filter")
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/eskip"
@@ -29,6 +30,10 @@ const (
 	allRoutesPath = "/current-routes"
 	updatePathFmt = "/updated-routes/%s"
 	bearerPrefix  = "Bearer "
+
+	// the expiring tokens are refreshed when this fraction of
+	// their lifetime is left
+	tokenRefreshDivisor = 10
 )
 
 type (
@@ -92,6 +97,7 @@ type Client struct {
 	prependFilters []*eskip.Filter
 	appendFilters  []*eskip.Filter
 	authToken      string
+	tokenExpires   time.Time
 	httpClient     *http.Client
 	lastChanged    string
 }
@@ -179,22 +185,56 @@ func isApiAuthError(error string) bool {
 	return aerr == authErrorAuthorization || aerr == authErrorMissingCredentials || aerr == authErrorAuthentication
 }
 
-// Authenticates a client and stores the authentication token.
+// Authenticates a client and stores the authentication token. When the
+// token has a known lifetime, it stores also the time when it needs to be
+// refreshed.
 func (c *Client) authenticate() error {
+	c.tokenExpires = time.Time{}
 	if c.opts.Authentication == nil {
 		c.authToken = ""
 		return nil
 	}
 
-	t, err := c.opts.Authentication.GetToken()
+	ea, ok := c.opts.Authentication.(ExpiringAuthentication)
+	if !ok {
+		t, err := c.opts.Authentication.GetToken()
+		if err != nil {
+			return err
+		}
+
+		c.authToken = t
+		return nil
+	}
+
+	t, expiresIn, err := ea.GetTokenWithExpiry()
 	if err != nil {
 		return err
 	}
 
 	c.authToken = t
+	if expiresIn > 0 {
+		c.tokenExpires = time.Now().Add(expiresIn - expiresIn/tokenRefreshDivisor)
+	}
+
 	return nil
 }
 
+// Returns the stored authentication token, authenticating first when
+// there is no token yet, or when it is about to expire.
+func (c *Client) token() (string, error) {
+	if c.opts.Authentication == nil {
+		return "", nil
+	}
+
+	if c.authToken == "" || !c.tokenExpires.IsZero() && !time.Now().Before(c.tokenExpires) {
+		if err := c.authenticate(); err != nil {
+			return "", err
+		}
+	}
+
+	return c.authToken, nil
+}
+
 // Checks if an http response status indicates an error, and returns an error
 // object if it does.
 //lint:ignore ST1008 inkeeper is deprecated and will be deleted
@@ -225,8 +265,7 @@ func (c *Client) writeRoute(url string, route *jsonRoute) error {
 		return err
 	}
 
-	authToken, err := c.opts.Authentication.GetToken()
-
+	authToken, err := c.token()
 	if err != nil {
 		return err
 	}
@@ -264,7 +303,12 @@ func (c *Client) requestData(authRetry bool, url string) ([]*routeData, error) {
 		return nil, err
 	}
 
-	setAuthToken(req.Header, c.authToken)
+	authToken, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+
+	setAuthToken(req.Header, authToken)
 	response, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
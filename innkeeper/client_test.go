@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/zalando/skipper/eskip"
 )
@@ -587,3 +588,54 @@ func TestUsesPreAndPostRouteFilters(t *testing.T) {
 		}
 	}
 }
+
+type expiringAuth struct {
+	calls     int
+	expiresIn time.Duration
+}
+
+func (ea *expiringAuth) GetToken() (string, error) {
+	t, _, err := ea.GetTokenWithExpiry()
+	return t, err
+}
+
+func (ea *expiringAuth) GetTokenWithExpiry() (string, time.Duration, error) {
+	ea.calls++
+	return testAuthenticationToken, ea.expiresIn, nil
+}
+
+func TestRefreshesExpiringToken(t *testing.T) {
+	s := innkeeperServer(nil)
+	defer s.Close()
+
+	auth := &expiringAuth{expiresIn: time.Hour}
+	c, err := New(Options{Address: s.URL, Authentication: auth})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.LoadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth.calls != 1 {
+		t.Error("failed to reuse the token", auth.calls)
+	}
+
+	if c.tokenExpires.Before(time.Now().Add(50*time.Minute)) || c.tokenExpires.After(time.Now().Add(time.Hour)) {
+		t.Error("invalid token expiration", c.tokenExpires)
+	}
+
+	c.tokenExpires = time.Now().Add(-time.Second)
+	if _, _, err := c.LoadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth.calls != 2 {
+		t.Error("failed to refresh the token", auth.calls)
+	}
+}
@@ -1,6 +1,8 @@
 package innkeeper
 
 import (
+	"time"
+
 	"github.com/zalando/skipper/oauth"
 )
 
@@ -9,6 +11,14 @@ type Authentication interface {
 	GetToken() (string, error)
 }
 
+// An ExpiringAuthentication provides authentication tokens with a known
+// lifetime. The client refreshes these tokens before they expire. A
+// lifetime of 0 means that the token doesn't expire.
+type ExpiringAuthentication interface {
+	Authentication
+	GetTokenWithExpiry() (string, time.Duration, error)
+}
+
 type AuthOptions struct {
 	InnkeeperAuthToken  string
 	OAuthCredentialsDir string
@@ -0,0 +1,208 @@
+package loadbalancer
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultActiveCheckTimeout = time.Second
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+)
+
+// ActiveHealthCheck configures the periodic checking of the endpoints of
+// the load balanced backends. The endpoints failing the checks don't
+// receive requests until they pass the checks again.
+type ActiveHealthCheck struct {
+
+	// Interval is the period of the checks. Required.
+	Interval time.Duration
+
+	// Timeout of a single check. Defaults to 1 second.
+	Timeout time.Duration
+
+	// Path, when set, is requested with GET from the endpoints, and the
+	// checks pass when the response status is lower than 400. When not
+	// set, the checks only open a TCP connection to the endpoints.
+	Path string
+
+	// HealthyThreshold is the number of the passed checks in a row,
+	// after which an unhealthy endpoint is considered healthy again.
+	// Defaults to 2.
+	HealthyThreshold int
+
+	// UnhealthyThreshold is the number of the failed checks in a row,
+	// after which an endpoint is considered unhealthy. Defaults to 3.
+	UnhealthyThreshold int
+
+	// Insecure skips the verification of the TLS certificates of the
+	// https endpoints.
+	Insecure bool
+}
+
+// ActiveHealthChecker checks the endpoints registered in an endpoint
+// registry periodically. Use NewActiveHealthChecker to create one.
+type ActiveHealthChecker struct {
+	options  ActiveHealthCheck
+	registry *EndpointRegistry
+	client   *http.Client
+	counters map[registeredEndpoint]*checkCounter
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// counts the consecutive results of the checks of an endpoint
+type checkCounter struct {
+	passed, failed int
+}
+
+// NewActiveHealthChecker creates and starts an active health checker for
+// the endpoints in the registry. It returns nil, when the interval is not
+// set.
+func NewActiveHealthChecker(o ActiveHealthCheck, r *EndpointRegistry) *ActiveHealthChecker {
+	if o.Interval <= 0 {
+		return nil
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultActiveCheckTimeout
+	}
+
+	if o.HealthyThreshold <= 0 {
+		o.HealthyThreshold = defaultHealthyThreshold
+	}
+
+	if o.UnhealthyThreshold <= 0 {
+		o.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+
+	tr := &http.Transport{DisableKeepAlives: true}
+	if o.Insecure {
+		/* #nosec */
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	c := &ActiveHealthChecker{
+		options:  o,
+		registry: r,
+		client: &http.Client{
+			Transport: tr,
+			Timeout:   o.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		counters: make(map[registeredEndpoint]*checkCounter),
+		quit:     make(chan struct{}),
+	}
+
+	go c.run()
+	return c
+}
+
+func (c *ActiveHealthChecker) run() {
+	ticker := time.NewTicker(c.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkAll()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func (c *ActiveHealthChecker) check(e routing.LBEndpoint) bool {
+	if c.options.Path == "" || (e.Scheme != "http" && e.Scheme != "https") {
+		network := "tcp"
+		if e.Scheme == routing.UnixScheme {
+			network = "unix"
+		}
+
+		conn, err := net.DialTimeout(network, e.Host, c.options.Timeout)
+		if err != nil {
+			return false
+		}
+
+		conn.Close()
+		return true
+	}
+
+	rsp, err := c.client.Get(e.Scheme + "://" + e.Host + c.options.Path)
+	if err != nil {
+		return false
+	}
+
+	rsp.Body.Close()
+	return rsp.StatusCode < http.StatusBadRequest
+}
+
+// checks all the registered endpoints concurrently, and updates their
+// state based on the thresholds
+func (c *ActiveHealthChecker) checkAll() {
+	endpoints := c.registry.endpoints()
+	results := make([]bool, len(endpoints))
+
+	var wg sync.WaitGroup
+	wg.Add(len(endpoints))
+	for i := range endpoints {
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.check(endpoints[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	counters := make(map[registeredEndpoint]*checkCounter)
+	for i, e := range endpoints {
+		key := registeredEndpoint{scheme: e.Scheme, host: e.Host}
+		counter, ok := c.counters[key]
+		if !ok {
+			counter = &checkCounter{}
+		}
+
+		counters[key] = counter
+		c.update(e, counter, results[i])
+	}
+
+	c.counters = counters
+}
+
+func (c *ActiveHealthChecker) update(e routing.LBEndpoint, counter *checkCounter, passed bool) {
+	unhealthy := e.State.Stats().Unhealthy
+	if passed {
+		counter.passed++
+		counter.failed = 0
+		if unhealthy && counter.passed >= c.options.HealthyThreshold {
+			e.State.SetUnhealthy(false)
+			log.Infof("active health check: endpoint %s is healthy", e.Host)
+		}
+
+		return
+	}
+
+	counter.failed++
+	counter.passed = 0
+	if !unhealthy && counter.failed >= c.options.UnhealthyThreshold {
+		e.State.SetUnhealthy(true)
+		log.Infof("active health check: endpoint %s is unhealthy", e.Host)
+	}
+}
+
+// Close stops the active health checks.
+func (c *ActiveHealthChecker) Close() {
+	if c == nil {
+		return
+	}
+
+	c.once.Do(func() { close(c.quit) })
+}
@@ -0,0 +1,95 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func waitUnhealthy(t *testing.T, s *routing.LBEndpointState, expected bool) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for s.Stats().Unhealthy != expected {
+		select {
+		case <-timeout:
+			t.Fatalf("timeout waiting for unhealthy: %v", expected)
+		case <-time.After(3 * time.Millisecond):
+		}
+	}
+}
+
+func TestActiveHealthCheck(t *testing.T) {
+	var failing int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("invalid path: %s", r.URL.Path)
+		}
+
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	closed.Close()
+
+	bu, _ := url.Parse(backend.URL)
+	cu, _ := url.Parse(closed.URL)
+
+	t.Run("HTTP", func(t *testing.T) {
+		r := NewEndpointRegistry()
+		s := r.get("http", bu.Host)
+
+		c := NewActiveHealthChecker(ActiveHealthCheck{
+			Interval:           3 * time.Millisecond,
+			Path:               "/health",
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 2,
+		}, r)
+		defer c.Close()
+
+		atomic.StoreInt32(&failing, 1)
+		waitUnhealthy(t, s, true)
+
+		atomic.StoreInt32(&failing, 0)
+		waitUnhealthy(t, s, false)
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		r := NewEndpointRegistry()
+		healthy := r.get("http", bu.Host)
+		unhealthy := r.get("http", cu.Host)
+
+		c := NewActiveHealthChecker(ActiveHealthCheck{Interval: 3 * time.Millisecond}, r)
+		defer c.Close()
+
+		waitUnhealthy(t, unhealthy, true)
+		if healthy.Stats().Unhealthy {
+			t.Error("failed to pass the check of the healthy endpoint")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if c := NewActiveHealthChecker(ActiveHealthCheck{}, NewEndpointRegistry()); c != nil {
+			t.Error("unexpected active health checker")
+		}
+	})
+}
+
+func TestUnhealthyEndpointSkipped(t *testing.T) {
+	r := lbRoute("roundRobin", "http://10.0.0.1", "http://10.0.0.2")
+	r.LBEndpoints[0].State.SetUnhealthy(true)
+
+	ctx := &routing.LBContext{Request: &http.Request{}, Route: r}
+	for i := 0; i < 10; i++ {
+		if e := r.LBAlgorithm.Apply(ctx); e.Host != "10.0.0.2" {
+			t.Fatalf("failed to skip the unhealthy endpoint, got: %s", e.Host)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"hash/fnv"
 	"math/rand"
-	"net/url"
 	"sync"
 	"time"
 
@@ -29,60 +28,71 @@ const (
 
 	// ConsistentHash indicates choice between the backends based on their hashed address.
 	ConsistentHash
+
+	// LeastConnections indicates choosing the endpoint with fewer in-flight
+	// requests from two randomly selected ones (power of two choices).
+	LeastConnections
 )
 
 var (
 	algorithms = map[Algorithm]initializeAgorithm{
-		RoundRobin:     newRoundRobin,
-		Random:         newRandom,
-		ConsistentHash: newConsistentHash,
+		RoundRobin:       newRoundRobin,
+		Random:           newRandom,
+		ConsistentHash:   newConsistentHash,
+		LeastConnections: newLeastConnections,
 	}
 	defaultAlgorithm = newRoundRobin
 )
 
-func newRoundRobin(endpoints []string) routing.LBAlgorithm {
+func newRoundRobin(endpoints []string, h *passiveHealth) routing.LBAlgorithm {
 	i := time.Now().UnixNano()
 	rand.Seed(i)
 	return &roundRobin{
-		index: rand.Intn(len(endpoints)),
+		index:  rand.Intn(len(endpoints)),
+		health: h,
 	}
 }
 
 type roundRobin struct {
-	mx    sync.Mutex
-	index int
+	mx     sync.Mutex
+	index  int
+	health *passiveHealth
 }
 
 // Apply implements routing.LBAlgorithm with a roundrobin algorithm.
 func (r *roundRobin) Apply(ctx *routing.LBContext) routing.LBEndpoint {
 	r.mx.Lock()
 	defer r.mx.Unlock()
-	r.index = (r.index + 1) % len(ctx.Route.LBEndpoints)
+	r.index = r.health.skipUnavailable(ctx.Route.LBEndpoints, (r.index+1)%len(ctx.Route.LBEndpoints), time.Now())
 	return ctx.Route.LBEndpoints[r.index]
 }
 
 type random struct {
-	rand *rand.Rand
+	rand   *rand.Rand
+	health *passiveHealth
 }
 
-func newRandom(endpoints []string) routing.LBAlgorithm {
+func newRandom(endpoints []string, h *passiveHealth) routing.LBAlgorithm {
 	t := time.Now().UnixNano()
-	return &random{rand: rand.New(rand.NewSource(t))}
+	return &random{rand: rand.New(rand.NewSource(t)), health: h}
 }
 
 // Apply implements routing.LBAlgorithm with a stateless random algorithm.
 func (r *random) Apply(ctx *routing.LBContext) routing.LBEndpoint {
-	return ctx.Route.LBEndpoints[r.rand.Intn(len(ctx.Route.LBEndpoints))]
+	i := r.health.skipUnavailable(ctx.Route.LBEndpoints, r.rand.Intn(len(ctx.Route.LBEndpoints)), time.Now())
+	return ctx.Route.LBEndpoints[i]
 }
 
-type consistentHash struct{}
+type consistentHash struct {
+	health *passiveHealth
+}
 
-func newConsistentHash(endpoints []string) routing.LBAlgorithm {
-	return &consistentHash{}
+func newConsistentHash(endpoints []string, h *passiveHealth) routing.LBAlgorithm {
+	return &consistentHash{health: h}
 }
 
 // Apply implements routing.LBAlgorithm with a consistent hash algorithm.
-func (*consistentHash) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+func (c *consistentHash) Apply(ctx *routing.LBContext) routing.LBEndpoint {
 	var sum uint32
 	h := fnv.New32()
 
@@ -96,18 +106,84 @@ func (*consistentHash) Apply(ctx *routing.LBContext) routing.LBEndpoint {
 	if choice < 0 {
 		choice = len(ctx.Route.LBEndpoints) + choice
 	}
-	return ctx.Route.LBEndpoints[choice]
+	return ctx.Route.LBEndpoints[c.health.skipUnavailable(ctx.Route.LBEndpoints, choice, time.Now())]
+}
+
+type leastConnections struct {
+	mx     sync.Mutex
+	rand   *rand.Rand
+	health *passiveHealth
+}
+
+func newLeastConnections(endpoints []string, h *passiveHealth) routing.LBAlgorithm {
+	t := time.Now().UnixNano()
+	return &leastConnections{rand: rand.New(rand.NewSource(t)), health: h}
+}
+
+// Apply implements routing.LBAlgorithm with the power of two random
+// choices algorithm, selecting the endpoint with fewer in-flight requests
+// from two random ones.
+func (l *leastConnections) Apply(ctx *routing.LBContext) routing.LBEndpoint {
+	endpoints := ctx.Route.LBEndpoints
+	if len(endpoints) == 1 {
+		return endpoints[0]
+	}
+
+	l.mx.Lock()
+	i := l.rand.Intn(len(endpoints))
+	j := (i + 1 + l.rand.Intn(len(endpoints)-1)) % len(endpoints)
+	l.mx.Unlock()
+
+	now := time.Now()
+	i, j = l.health.skipUnavailable(endpoints, i, now), l.health.skipUnavailable(endpoints, j, now)
+	if endpoints[j].State.InFlight() < endpoints[i].State.InFlight() {
+		return endpoints[j]
+	}
+
+	return endpoints[i]
 }
 
 type (
-	algorithmProvider  struct{}
-	initializeAgorithm func(endpoints []string) routing.LBAlgorithm
+	algorithmProvider struct {
+		health   *passiveHealth
+		registry *EndpointRegistry
+	}
+
+	initializeAgorithm func(endpoints []string, h *passiveHealth) routing.LBAlgorithm
 )
 
+// Options configures the algorithm provider.
+type Options struct {
+
+	// PassiveHealthCheck configures the ejection of the unhealthy
+	// endpoints. The zero value means the default settings.
+	PassiveHealthCheck PassiveHealthCheck
+
+	// Registry maintains the state of the endpoints. It can be shared
+	// with the active health checks. When not set, a new registry is
+	// created.
+	Registry *EndpointRegistry
+}
+
 // NewAlgorithmProvider creates a routing.PostProcessor used to initialize
-// the algorithm of load balancing routes.
+// the algorithm of load balancing routes. The endpoints are ejected with
+// the default passive health check settings.
 func NewAlgorithmProvider() routing.PostProcessor {
-	return &algorithmProvider{}
+	return NewAlgorithmProviderWithOptions(Options{})
+}
+
+// NewAlgorithmProviderWithOptions creates a routing.PostProcessor used to
+// initialize the algorithm of load balancing routes, with custom health
+// check settings.
+func NewAlgorithmProviderWithOptions(o Options) routing.PostProcessor {
+	if o.Registry == nil {
+		o.Registry = NewEndpointRegistry()
+	}
+
+	return &algorithmProvider{
+		health:   newPassiveHealth(o.PassiveHealthCheck),
+		registry: o.Registry,
+	}
 }
 
 // AlgorithmFromString parses the string representation of the algorithm definition.
@@ -123,6 +199,8 @@ func AlgorithmFromString(a string) (Algorithm, error) {
 		return Random, nil
 	case "consistentHash":
 		return ConsistentHash, nil
+	case "leastConnections":
+		return LeastConnections, nil
 	default:
 		return None, errors.New("unsupported algorithm")
 	}
@@ -137,26 +215,32 @@ func (a Algorithm) String() string {
 		return "random"
 	case ConsistentHash:
 		return "consistentHash"
+	case LeastConnections:
+		return "leastConnections"
 	default:
 		return ""
 	}
 }
 
-func parseEndpoints(r *routing.Route) error {
+func parseEndpoints(r *routing.Route, registry *EndpointRegistry) error {
 	r.LBEndpoints = make([]routing.LBEndpoint, len(r.Route.LBEndpoints))
 	for i, e := range r.Route.LBEndpoints {
-		eu, err := url.ParseRequestURI(e)
+		scheme, host, err := routing.ParseBackendURL(e)
 		if err != nil {
 			return err
 		}
 
-		r.LBEndpoints[i] = routing.LBEndpoint{Scheme: eu.Scheme, Host: eu.Host}
+		r.LBEndpoints[i] = routing.LBEndpoint{
+			Scheme: scheme,
+			Host:   host,
+			State:  registry.get(scheme, host),
+		}
 	}
 
 	return nil
 }
 
-func setAlgorithm(r *routing.Route) error {
+func setAlgorithm(r *routing.Route, h *passiveHealth) error {
 	t, err := AlgorithmFromString(r.Route.LBAlgorithm)
 	if err != nil {
 		return err
@@ -167,7 +251,7 @@ func setAlgorithm(r *routing.Route) error {
 		initialize = algorithms[t]
 	}
 
-	r.LBAlgorithm = initialize(r.Route.LBEndpoints)
+	r.LBAlgorithm = initialize(r.Route.LBEndpoints, h)
 	return nil
 }
 
@@ -185,12 +269,12 @@ func (p *algorithmProvider) Do(r []*routing.Route) []*routing.Route {
 			continue
 		}
 
-		if err := parseEndpoints(ri); err != nil {
+		if err := parseEndpoints(ri, p.registry); err != nil {
 			log.Errorf("failed to parse LB endpoints for route %s: %v", ri.Id, err)
 			continue
 		}
 
-		if err := setAlgorithm(ri); err != nil {
+		if err := setAlgorithm(ri, p.health); err != nil {
 			log.Errorf("failed to set LB algorithm implementation for route %s: %v", ri.Id, err)
 			continue
 		}
@@ -198,5 +282,6 @@ func (p *algorithmProvider) Do(r []*routing.Route) []*routing.Route {
 		rr = append(rr, ri)
 	}
 
+	p.registry.retain(rr)
 	return rr
 }
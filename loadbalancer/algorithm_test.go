@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/zalando/skipper/eskip"
@@ -126,6 +127,30 @@ func TestSelectAlgorithm(t *testing.T) {
 		}
 	})
 
+	t.Run("LB route with explicit leastConnections algorithm", func(t *testing.T) {
+		p := NewAlgorithmProvider()
+		r := &routing.Route{
+			Route: eskip.Route{
+				BackendType: eskip.LBBackend,
+				LBAlgorithm: "leastConnections",
+				LBEndpoints: []string{"https://www.example.org"},
+			},
+		}
+
+		rr := p.Do([]*routing.Route{r})
+		if len(rr) != 1 {
+			t.Fatal("failed to process LB route")
+		}
+
+		if len(rr[0].LBEndpoints) != 1 || rr[0].LBEndpoints[0].State == nil {
+			t.Fatal("failed to set the endpoints")
+		}
+
+		if _, ok := rr[0].LBAlgorithm.(*leastConnections); !ok {
+			t.Fatal("failed to set the right algorithm")
+		}
+	})
+
 	t.Run("LB route with invalid algorithm", func(t *testing.T) {
 		p := NewAlgorithmProvider()
 		r := &routing.Route{
@@ -173,3 +198,143 @@ func TestSelectAlgorithm(t *testing.T) {
 		}
 	})
 }
+
+func lbRouteWithOptions(o Options, algorithm string, endpoints ...string) *routing.Route {
+	r := &routing.Route{
+		Route: eskip.Route{
+			BackendType: eskip.LBBackend,
+			LBAlgorithm: algorithm,
+			LBEndpoints: endpoints,
+		},
+	}
+
+	return NewAlgorithmProviderWithOptions(o).Do([]*routing.Route{r})[0]
+}
+
+func lbRoute(algorithm string, endpoints ...string) *routing.Route {
+	return lbRouteWithOptions(Options{}, algorithm, endpoints...)
+}
+
+func TestLeastConnections(t *testing.T) {
+	r := lbRoute("leastConnections", "http://10.0.0.1", "http://10.0.0.2")
+	for i := 0; i < 3; i++ {
+		r.LBEndpoints[0].State.Started()
+	}
+
+	ctx := &routing.LBContext{Request: &http.Request{}, Route: r}
+	for i := 0; i < 30; i++ {
+		if e := r.LBAlgorithm.Apply(ctx); e.Host != "10.0.0.2" {
+			t.Fatalf("failed to select the endpoint with fewer in-flight requests, got: %s", e.Host)
+		}
+	}
+}
+
+func failEndpoint(e routing.LBEndpoint, n int) {
+	for i := 0; i < n; i++ {
+		e.State.Started()
+		e.State.Done(true, 0)
+	}
+}
+
+var testAlgorithms = []string{"roundRobin", "random", "consistentHash", "leastConnections"}
+
+func testLBContext(r *routing.Route) *routing.LBContext {
+	return &routing.LBContext{
+		Request: &http.Request{RemoteAddr: "10.1.0.1:4321", Header: http.Header{}},
+		Route:   r,
+	}
+}
+
+func TestSkipUnhealthyEndpoints(t *testing.T) {
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			r := lbRouteWithOptions(
+				Options{PassiveHealthCheck: PassiveHealthCheck{MaxEjectedRatio: 1}},
+				algorithm,
+				"http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3",
+			)
+
+			failEndpoint(r.LBEndpoints[0], defaultConsecutiveFailures)
+			failEndpoint(r.LBEndpoints[1], defaultConsecutiveFailures)
+
+			ctx := testLBContext(r)
+			for i := 0; i < 30; i++ {
+				if e := r.LBAlgorithm.Apply(ctx); e.Host != "10.0.0.3" {
+					t.Fatalf("failed to skip the unhealthy endpoints, got: %s", e.Host)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipUnhealthyEndpointsMaxEjectedRatio(t *testing.T) {
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			r := lbRoute(algorithm, "http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3")
+			failEndpoint(r.LBEndpoints[0], defaultConsecutiveFailures)
+
+			ctx := testLBContext(r)
+			for i := 0; i < 30; i++ {
+				if e := r.LBAlgorithm.Apply(ctx); e.Host == "10.0.0.1" {
+					t.Fatal("failed to skip the unhealthy endpoint")
+				}
+			}
+		})
+	}
+}
+
+func TestAllEndpointsUnhealthy(t *testing.T) {
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			r := lbRoute(algorithm, "http://10.0.0.1", "http://10.0.0.2")
+			for _, e := range r.LBEndpoints {
+				e.State.SetUnhealthy(true)
+			}
+
+			ctx := testLBContext(r)
+			selected := make(map[string]bool)
+			for i := 0; i < 30; i++ {
+				e := r.LBAlgorithm.Apply(ctx)
+				if e.Host != "10.0.0.1" && e.Host != "10.0.0.2" {
+					t.Fatalf("invalid endpoint selected: %s", e.Host)
+				}
+
+				selected[e.Host] = true
+			}
+
+			// the consistent hash selects the same endpoint for the
+			// same client
+			if algorithm == "roundRobin" && len(selected) != 2 {
+				t.Errorf("failed to fall back to all the endpoints, got: %v", selected)
+			}
+		})
+	}
+}
+
+func TestAllEndpointsEjected(t *testing.T) {
+	r := lbRouteWithOptions(
+		Options{PassiveHealthCheck: PassiveHealthCheck{MaxEjectedRatio: 1}},
+		"roundRobin",
+		"http://10.0.0.1", "http://10.0.0.2",
+	)
+
+	for _, e := range r.LBEndpoints {
+		failEndpoint(e, defaultConsecutiveFailures)
+	}
+
+	ctx := testLBContext(r)
+	selected := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		selected[r.LBAlgorithm.Apply(ctx).Host] = true
+	}
+
+	for _, e := range r.LBEndpoints {
+		if e.State.Stats().EjectedUntil.IsZero() {
+			t.Errorf("failed to eject %s", e.Host)
+		}
+	}
+
+	if len(selected) != 2 {
+		t.Errorf("failed to fall back to all the endpoints, got: %v", selected)
+	}
+}
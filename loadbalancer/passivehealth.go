@@ -0,0 +1,221 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultConsecutiveFailures = 3
+	defaultMinRequests         = 10
+	defaultEjectionDuration    = 10 * time.Second
+	defaultMaxEjectionDuration = 5 * time.Minute
+	defaultMaxEjectedRatio     = 0.5
+)
+
+// PassiveHealthCheck configures the ejection of the unhealthy endpoints
+// of the load balanced backends, based on the outcome of the proxied
+// requests. The ejected endpoints don't receive requests until the
+// ejection expires. The zero value of a field means its default, while
+// the features marked as optional are disabled by default.
+type PassiveHealthCheck struct {
+
+	// ConsecutiveFailures is the number of the failed requests in a
+	// row after which an endpoint is ejected. The failed requests are
+	// the connection errors, the timeouts, and the 502, 503 and 504
+	// responses. Defaults to 3. When negative, the endpoints are not
+	// ejected due to consecutive failures.
+	ConsecutiveFailures int
+
+	// MaxErrorRate is the moving average of the failure ratio, e.g.
+	// 0.3, above which an endpoint is ejected. Optional.
+	MaxErrorRate float64
+
+	// LatencyFactor ejects the endpoints whose moving average latency
+	// is higher than the average latency of the other endpoints of the
+	// backend multiplied by this factor, e.g. 3. Optional.
+	LatencyFactor float64
+
+	// MinRequests is the number of the requests that an endpoint needs
+	// to receive before its error rate and latency are evaluated.
+	// Defaults to 10.
+	MinRequests int
+
+	// EjectionDuration is how long an endpoint is ejected the first
+	// time. Repeated ejections last proportionally longer. Defaults to
+	// 10 seconds.
+	EjectionDuration time.Duration
+
+	// MaxEjectionDuration is the upper limit of the ejection duration.
+	// Defaults to 5 minutes.
+	MaxEjectionDuration time.Duration
+
+	// MaxEjectedRatio is the maximum ratio of the endpoints of a backend
+	// that can be ejected at the same time. Defaults to 0.5.
+	MaxEjectedRatio float64
+
+	// SlowStart is the period after an ejection, during which the
+	// traffic to the reintroduced endpoint is gradually increased.
+	// Optional.
+	SlowStart time.Duration
+}
+
+type passiveHealth struct {
+	PassiveHealthCheck
+	mx   sync.Mutex
+	rand *rand.Rand
+}
+
+func newPassiveHealth(o PassiveHealthCheck) *passiveHealth {
+	if o.ConsecutiveFailures == 0 {
+		o.ConsecutiveFailures = defaultConsecutiveFailures
+	}
+
+	if o.MinRequests <= 0 {
+		o.MinRequests = defaultMinRequests
+	}
+
+	if o.EjectionDuration <= 0 {
+		o.EjectionDuration = defaultEjectionDuration
+	}
+
+	if o.MaxEjectionDuration <= 0 {
+		o.MaxEjectionDuration = defaultMaxEjectionDuration
+	}
+
+	if o.MaxEjectedRatio <= 0 {
+		o.MaxEjectedRatio = defaultMaxEjectedRatio
+	}
+
+	return &passiveHealth{
+		PassiveHealthCheck: o,
+		rand:               rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (h *passiveHealth) random() float64 {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return h.rand.Float64()
+}
+
+// returns the average latency of the endpoints other than the current
+// one, that received enough requests
+func (h *passiveHealth) otherLatency(endpoints []routing.LBEndpoint, current int) (time.Duration, bool) {
+	var sum time.Duration
+	var count int
+	for i, e := range endpoints {
+		if i == current {
+			continue
+		}
+
+		if s := e.State.Stats(); s.Requests >= int64(h.MinRequests) && s.Latency > 0 {
+			sum += s.Latency
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return sum / time.Duration(count), true
+}
+
+func (h *passiveHealth) unhealthy(endpoints []routing.LBEndpoint, i int, s routing.LBEndpointStats) bool {
+	if h.ConsecutiveFailures > 0 && s.ConsecutiveFailures >= int64(h.ConsecutiveFailures) {
+		return true
+	}
+
+	if s.Requests < int64(h.MinRequests) {
+		return false
+	}
+
+	if h.MaxErrorRate > 0 && s.ErrorRate > h.MaxErrorRate {
+		return true
+	}
+
+	if h.LatencyFactor > 0 {
+		if l, ok := h.otherLatency(endpoints, i); ok && float64(s.Latency) > h.LatencyFactor*float64(l) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns true when one more endpoint can be ejected without exceeding
+// the max ejected ratio
+func (h *passiveHealth) canEject(endpoints []routing.LBEndpoint, now time.Time) bool {
+	ejected := 1
+	for _, e := range endpoints {
+		if now.Before(e.State.Stats().EjectedUntil) {
+			ejected++
+		}
+	}
+
+	return float64(ejected) <= h.MaxEjectedRatio*float64(len(endpoints))
+}
+
+func (h *passiveHealth) eject(e routing.LBEndpoint, s routing.LBEndpointStats, now time.Time) {
+	d := h.EjectionDuration * time.Duration(s.Ejections+1)
+	if d > h.MaxEjectionDuration {
+		d = h.MaxEjectionDuration
+	}
+
+	e.State.Eject(now.Add(d))
+	log.Infof("passive health check: ejected endpoint %s for %v", e.Host, d)
+}
+
+// returns true when the endpoint can receive the request. The endpoints
+// failing the active health checks are not available. It ejects the
+// endpoint when it became unhealthy, and during the slow start after an
+// ejection, it lets through a gradually increasing ratio of the requests.
+func (h *passiveHealth) available(endpoints []routing.LBEndpoint, i int, now time.Time) bool {
+	e := endpoints[i]
+	if e.State == nil {
+		return true
+	}
+
+	s := e.State.Stats()
+	if s.Unhealthy || now.Before(s.EjectedUntil) {
+		return false
+	}
+
+	if h.unhealthy(endpoints, i, s) && h.canEject(endpoints, now) {
+		h.eject(e, s, now)
+		return false
+	}
+
+	if s.Ejections == 0 {
+		return true
+	}
+
+	sinceEjection := now.Sub(s.EjectedUntil)
+	if sinceEjection > h.MaxEjectionDuration+h.SlowStart {
+		e.State.ResetEjections()
+		return true
+	}
+
+	if sinceEjection < h.SlowStart {
+		return h.random() < float64(sinceEjection)/float64(h.SlowStart)
+	}
+
+	return true
+}
+
+// returns the first available endpoint starting from the selected index.
+// When none of the endpoints is available, it returns the selected one.
+func (h *passiveHealth) skipUnavailable(endpoints []routing.LBEndpoint, selected int, now time.Time) int {
+	for i := 0; i < len(endpoints); i++ {
+		if next := (selected + i) % len(endpoints); h.available(endpoints, next, now) {
+			return next
+		}
+	}
+
+	return selected
+}
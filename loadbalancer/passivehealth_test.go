@@ -0,0 +1,163 @@
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func testEndpoints(n int) []routing.LBEndpoint {
+	endpoints := make([]routing.LBEndpoint, n)
+	for i := range endpoints {
+		endpoints[i] = routing.LBEndpoint{
+			Scheme: "http",
+			Host:   string(rune('a' + i)),
+			State:  &routing.LBEndpointState{},
+		}
+	}
+
+	return endpoints
+}
+
+func succeedEndpoint(e routing.LBEndpoint, n int, latency time.Duration) {
+	for i := 0; i < n; i++ {
+		e.State.Started()
+		e.State.Done(false, latency)
+	}
+}
+
+func TestPassiveHealthCheck(t *testing.T) {
+	now := time.Now()
+
+	t.Run("consecutive failures", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{})
+		endpoints := testEndpoints(2)
+		failEndpoint(endpoints[0], defaultConsecutiveFailures-1)
+		if !h.available(endpoints, 0, now) {
+			t.Fatal("ejected too early")
+		}
+
+		failEndpoint(endpoints[0], 1)
+		if h.available(endpoints, 0, now) {
+			t.Fatal("failed to eject")
+		}
+
+		if s := endpoints[0].State.Stats(); !s.EjectedUntil.Equal(now.Add(defaultEjectionDuration)) || s.Ejections != 1 {
+			t.Errorf("invalid ejection: %v, %d", s.EjectedUntil, s.Ejections)
+		}
+
+		if !h.available(endpoints, 0, now.Add(defaultEjectionDuration+time.Millisecond)) {
+			t.Error("failed to reintroduce")
+		}
+	})
+
+	t.Run("consecutive failures disabled", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{ConsecutiveFailures: -1})
+		endpoints := testEndpoints(2)
+		failEndpoint(endpoints[0], 9)
+		if !h.available(endpoints, 0, now) {
+			t.Error("unexpected ejection")
+		}
+	})
+
+	t.Run("error rate", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{ConsecutiveFailures: -1, MaxErrorRate: 0.3, MinRequests: 10})
+		endpoints := testEndpoints(2)
+		for i := 0; i < 5; i++ {
+			failEndpoint(endpoints[0], 1)
+			succeedEndpoint(endpoints[0], 1, time.Millisecond)
+		}
+
+		if h.available(endpoints, 0, now) {
+			t.Error("failed to eject")
+		}
+	})
+
+	t.Run("error rate, too few requests", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{ConsecutiveFailures: -1, MaxErrorRate: 0.3, MinRequests: 10})
+		endpoints := testEndpoints(2)
+		failEndpoint(endpoints[0], 5)
+		if !h.available(endpoints, 0, now) {
+			t.Error("unexpected ejection")
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{LatencyFactor: 3, MinRequests: 10})
+		endpoints := testEndpoints(3)
+		succeedEndpoint(endpoints[0], 10, 100*time.Millisecond)
+		succeedEndpoint(endpoints[1], 10, 10*time.Millisecond)
+		succeedEndpoint(endpoints[2], 10, 12*time.Millisecond)
+		if h.available(endpoints, 0, now) {
+			t.Error("failed to eject the slow endpoint")
+		}
+
+		if !h.available(endpoints, 2, now) {
+			t.Error("unexpected ejection")
+		}
+	})
+
+	t.Run("max ejected ratio", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{})
+		endpoints := testEndpoints(2)
+		failEndpoint(endpoints[0], defaultConsecutiveFailures)
+		failEndpoint(endpoints[1], defaultConsecutiveFailures)
+		if h.available(endpoints, 0, now) {
+			t.Fatal("failed to eject")
+		}
+
+		if !h.available(endpoints, 1, now) {
+			t.Error("ejected more endpoints than allowed")
+		}
+	})
+
+	t.Run("repeated ejections", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{MaxEjectionDuration: 25 * time.Second})
+		endpoints := testEndpoints(2)
+		at := now
+		for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second} {
+			failEndpoint(endpoints[0], defaultConsecutiveFailures)
+			if h.available(endpoints, 0, at) {
+				t.Fatal("failed to eject")
+			}
+
+			s := endpoints[0].State.Stats()
+			if d := s.EjectedUntil.Sub(at); d != expected {
+				t.Errorf("invalid ejection duration, expected: %v, got: %v", expected, d)
+			}
+
+			at = s.EjectedUntil.Add(time.Millisecond)
+		}
+	})
+
+	t.Run("slow start", func(t *testing.T) {
+		h := newPassiveHealth(PassiveHealthCheck{SlowStart: time.Minute})
+		endpoints := testEndpoints(2)
+		failEndpoint(endpoints[0], defaultConsecutiveFailures)
+		if h.available(endpoints, 0, now) {
+			t.Fatal("failed to eject")
+		}
+
+		reintroduced := now.Add(defaultEjectionDuration)
+		count := func(after time.Duration) int {
+			var n int
+			for i := 0; i < 1000; i++ {
+				if h.available(endpoints, 0, reintroduced.Add(after)) {
+					n++
+				}
+			}
+
+			return n
+		}
+
+		early, late := count(6*time.Second), count(54*time.Second)
+		if early >= late || early > 250 || late < 750 {
+			t.Errorf("failed to gradually reintroduce the endpoint: %d, %d", early, late)
+		}
+
+		if count(61*time.Second) != 1000 {
+			t.Error("failed to fully reintroduce the endpoint")
+		}
+	})
+}
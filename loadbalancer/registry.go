@@ -0,0 +1,74 @@
+package loadbalancer
+
+import (
+	"sync"
+
+	"github.com/zalando/skipper/routing"
+)
+
+// EndpointRegistry maintains the state of the endpoints of the load
+// balanced backends, shared by the routes with the same endpoints, and
+// preserved across the route updates. The state is fed by the proxy and
+// the active health checks, and used by the load balancing algorithms.
+type EndpointRegistry struct {
+	mx     sync.Mutex
+	states map[registeredEndpoint]*routing.LBEndpointState
+}
+
+type registeredEndpoint struct {
+	scheme, host string
+}
+
+// NewEndpointRegistry creates an empty endpoint registry.
+func NewEndpointRegistry() *EndpointRegistry {
+	return &EndpointRegistry{
+		states: make(map[registeredEndpoint]*routing.LBEndpointState),
+	}
+}
+
+// returns the state of an endpoint, creating it when it's not registered
+// yet
+func (r *EndpointRegistry) get(scheme, host string) *routing.LBEndpointState {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	e := registeredEndpoint{scheme: scheme, host: host}
+	s, ok := r.states[e]
+	if !ok {
+		s = &routing.LBEndpointState{}
+		r.states[e] = s
+	}
+
+	return s
+}
+
+// removes the endpoints that are not used by the current routes
+func (r *EndpointRegistry) retain(routes []*routing.Route) {
+	current := make(map[registeredEndpoint]bool)
+	for _, ri := range routes {
+		for _, e := range ri.LBEndpoints {
+			current[registeredEndpoint{scheme: e.Scheme, host: e.Host}] = true
+		}
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for e := range r.states {
+		if !current[e] {
+			delete(r.states, e)
+		}
+	}
+}
+
+// returns the currently registered endpoints
+func (r *EndpointRegistry) endpoints() []routing.LBEndpoint {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	endpoints := make([]routing.LBEndpoint, 0, len(r.states))
+	for e, s := range r.states {
+		endpoints = append(endpoints, routing.LBEndpoint{Scheme: e.scheme, Host: e.host, State: s})
+	}
+
+	return endpoints
+}
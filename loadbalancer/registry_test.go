@@ -0,0 +1,41 @@
+package loadbalancer
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestEndpointRegistry(t *testing.T) {
+	registry := NewEndpointRegistry()
+	p := NewAlgorithmProviderWithOptions(Options{Registry: registry})
+
+	newRoutes := func(endpoints ...[]string) []*routing.Route {
+		var routes []*routing.Route
+		for _, e := range endpoints {
+			routes = append(routes, &routing.Route{Route: eskip.Route{BackendType: eskip.LBBackend, LBEndpoints: e}})
+		}
+
+		return routes
+	}
+
+	rr := p.Do(newRoutes(
+		[]string{"http://10.0.0.1", "http://10.0.0.2"},
+		[]string{"http://10.0.0.2", "http://10.0.0.3"},
+	))
+
+	if rr[0].LBEndpoints[1].State != rr[1].LBEndpoints[0].State {
+		t.Error("failed to share the endpoint state between the routes")
+	}
+
+	shared := rr[0].LBEndpoints[1].State
+	rr = p.Do(newRoutes([]string{"http://10.0.0.2"}))
+	if rr[0].LBEndpoints[0].State != shared {
+		t.Error("failed to preserve the endpoint state across the updates")
+	}
+
+	if n := len(registry.endpoints()); n != 1 {
+		t.Errorf("failed to remove the unused endpoints, got: %d", n)
+	}
+}
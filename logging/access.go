@@ -9,7 +9,6 @@ import (
 
 	"github.com/sirupsen/logrus"
 
-	flowidFilter "github.com/zalando/skipper/filters/flowid"
 	logFilter "github.com/zalando/skipper/filters/log"
 )
 
@@ -21,6 +20,10 @@ const (
 	combinedLogFormat = commonLogFormat + ` "%s" "%s"`
 	// We add the duration in ms, a requested host and a flow id and audit log
 	accessLogFormat = combinedLogFormat + " %d %s %s %s\n"
+
+	// the same as flowid.HeaderName, not imported, because the flowId
+	// filter depends on the logging package through skipper/net
+	flowIDHeader = "X-Flow-Id"
 )
 
 type accessLogFormatter struct {
@@ -44,6 +47,10 @@ type AccessEntry struct {
 
 	// The time that the request was received.
 	RequestTime time.Time
+
+	// The gRPC status code of the response, when the proxy runs in
+	// gRPC mode, and the request was a gRPC call.
+	GRPCStatus string
 }
 
 // TODO: create individual instances from the access log and
@@ -142,7 +149,7 @@ func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
 		referer = entry.Request.Referer()
 		userAgent = entry.Request.UserAgent()
 		requestedHost = entry.Request.Host
-		flowId = entry.Request.Header.Get(flowidFilter.HeaderName)
+		flowId = entry.Request.Header.Get(flowIDHeader)
 
 		uri = entry.Request.RequestURI
 		if stripQuery {
@@ -168,6 +175,10 @@ func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
 		"audit":          auditHeader,
 	}
 
+	if entry.GRPCStatus != "" {
+		logData["grpc-status"] = entry.GRPCStatus
+	}
+
 	for k, v := range additional {
 		logData[k] = v
 	}
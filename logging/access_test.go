@@ -11,6 +11,7 @@ import (
 
 const logOutput = `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "-" "-" 42 example.com - -`
 const logJSONOutput = `{"audit":"","duration":42,"flow-id":"","host":"127.0.0.1","level":"info","method":"GET","msg":"","proto":"HTTP/1.1","referer":"","requested-host":"example.com","response-size":2326,"status":418,"timestamp":"10/Oct/2000:13:55:36 -0700","uri":"/apache_pb.gif","user-agent":""}`
+const logGRPCJSONOutput = `{"audit":"","duration":42,"flow-id":"","grpc-status":"14","host":"127.0.0.1","level":"info","method":"GET","msg":"","proto":"HTTP/1.1","referer":"","requested-host":"example.com","response-size":2326,"status":418,"timestamp":"10/Oct/2000:13:55:36 -0700","uri":"/apache_pb.gif","user-agent":""}`
 const logExtendedJSONOutput = `{"audit":"","duration":42,"extra":"extra","flow-id":"","host":"127.0.0.1","level":"info","method":"GET","msg":"","proto":"HTTP/1.1","referer":"","requested-host":"example.com","response-size":2326,"status":418,"timestamp":"10/Oct/2000:13:55:36 -0700","uri":"/apache_pb.gif","user-agent":""}`
 
 func testRequest() *http.Request {
@@ -71,6 +72,12 @@ func TestAccessLogFormatJSONWithAdditionalData(t *testing.T) {
 	testAccessLogExtended(t, testAccessEntry(), map[string]interface{}{"extra": "extra"}, logExtendedJSONOutput, Options{AccessLogJSONEnabled: true})
 }
 
+func TestAccessLogFormatJSONWithGRPCStatus(t *testing.T) {
+	entry := testAccessEntry()
+	entry.GRPCStatus = "14"
+	testAccessLog(t, entry, logGRPCJSONOutput, Options{AccessLogJSONEnabled: true})
+}
+
 func TestAccessLogIgnoresEmptyEntry(t *testing.T) {
 	testAccessLogDefault(t, nil, "")
 }
@@ -0,0 +1,183 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ForwardedHeadersPolicy defines how the X-Forwarded-For,
+// X-Forwarded-Proto, X-Forwarded-Host and Forwarded headers of the
+// incoming requests are handled.
+type ForwardedHeadersPolicy int
+
+const (
+	// ForwardedHeadersKeep leaves the headers untouched.
+	ForwardedHeadersKeep ForwardedHeadersPolicy = iota
+
+	// ForwardedHeadersAppend appends the address of the direct peer to
+	// the headers received from a trusted proxy, and replaces the
+	// headers received from other peers.
+	ForwardedHeadersAppend
+
+	// ForwardedHeadersOverwrite replaces the headers with the address
+	// of the client, as identified with the trusted proxies, see
+	// RemoteHostTrusted.
+	ForwardedHeadersOverwrite
+
+	// ForwardedHeadersStrip removes the headers.
+	ForwardedHeadersStrip
+)
+
+const (
+	forwardedForHeader   = "X-Forwarded-For"
+	forwardedProtoHeader = "X-Forwarded-Proto"
+	forwardedHostHeader  = "X-Forwarded-Host"
+	forwardedPortHeader  = "X-Forwarded-Port"
+	forwardedHeader      = "Forwarded"
+)
+
+// ParseForwardedHeadersPolicy parses the name of a policy: keep, append,
+// overwrite or strip.
+func ParseForwardedHeadersPolicy(s string) (ForwardedHeadersPolicy, error) {
+	switch s {
+	case "", "keep":
+		return ForwardedHeadersKeep, nil
+	case "append":
+		return ForwardedHeadersAppend, nil
+	case "overwrite":
+		return ForwardedHeadersOverwrite, nil
+	case "strip":
+		return ForwardedHeadersStrip, nil
+	default:
+		return 0, fmt.Errorf("invalid forwarded headers policy: %s", s)
+	}
+}
+
+func (p ForwardedHeadersPolicy) String() string {
+	switch p {
+	case ForwardedHeadersAppend:
+		return "append"
+	case ForwardedHeadersOverwrite:
+		return "overwrite"
+	case ForwardedHeadersStrip:
+		return "strip"
+	default:
+		return "keep"
+	}
+}
+
+// ForwardedHeaders applies a policy to the X-Forwarded-* and Forwarded
+// headers of the incoming requests, so that the backends receive either
+// the headers of a legitimate proxy chain, or the ones set by Skipper,
+// but not the spoofed ones set by the clients.
+type ForwardedHeaders struct {
+
+	// Policy defines how the headers are handled.
+	Policy ForwardedHeadersPolicy
+
+	// TrustedProxies contains the networks of the proxies in front of
+	// Skipper, whose headers are preserved.
+	TrustedProxies []*net.IPNet
+
+	// Forwarded enables setting the standard Forwarded header, RFC
+	// 7239, too, besides the X-Forwarded-* headers.
+	Forwarded bool
+}
+
+func requestProto(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// formats an address as a node of the Forwarded header
+func forwardedNode(ip net.IP) string {
+	if ip.To4() == nil {
+		return fmt.Sprintf(`"[%s]"`, ip)
+	}
+
+	return ip.String()
+}
+
+func forwardedElement(ip net.IP, proto, host string) string {
+	e := "for=" + forwardedNode(ip) + ";proto=" + proto
+	if host != "" {
+		e += `;host="` + host + `"`
+	}
+
+	return e
+}
+
+func stripForwardedHeaders(h http.Header) {
+	h.Del(forwardedForHeader)
+	h.Del(forwardedProtoHeader)
+	h.Del(forwardedHostHeader)
+	h.Del(forwardedPortHeader)
+	h.Del(forwardedHeader)
+}
+
+// Set applies the policy to the headers of an incoming request.
+func (f *ForwardedHeaders) Set(r *http.Request) {
+	if f == nil || f.Policy == ForwardedHeadersKeep {
+		return
+	}
+
+	if f.Policy == ForwardedHeadersStrip {
+		stripForwardedHeaders(r.Header)
+		return
+	}
+
+	peer := parse(r.RemoteAddr)
+	if peer == nil {
+		return
+	}
+
+	trustedPeer := containsIP(f.TrustedProxies, peer)
+	proto, host := requestProto(r), r.Host
+	if trustedPeer {
+		if p := r.Header.Get(forwardedProtoHeader); p != "" {
+			proto = p
+		}
+
+		if h := r.Header.Get(forwardedHostHeader); h != "" {
+			host = h
+		}
+	}
+
+	switch {
+	case f.Policy == ForwardedHeadersAppend && trustedPeer:
+		r.Header.Set(forwardedForHeader, appendList(r.Header.Get(forwardedForHeader), peer.String()))
+		if f.Forwarded {
+			e := forwardedElement(peer, requestProto(r), r.Host)
+			r.Header.Set(forwardedHeader, appendList(r.Header.Get(forwardedHeader), e))
+		}
+	case f.Policy == ForwardedHeadersAppend:
+		stripForwardedHeaders(r.Header)
+		r.Header.Set(forwardedForHeader, peer.String())
+		if f.Forwarded {
+			r.Header.Set(forwardedHeader, forwardedElement(peer, proto, host))
+		}
+	default:
+		client := RemoteHostTrusted(r, f.TrustedProxies)
+		stripForwardedHeaders(r.Header)
+		r.Header.Set(forwardedForHeader, client.String())
+		if f.Forwarded {
+			r.Header.Set(forwardedHeader, forwardedElement(client, proto, host))
+		}
+	}
+
+	r.Header.Set(forwardedProtoHeader, proto)
+	r.Header.Set(forwardedHostHeader, host)
+}
+
+// appends a comma separated value, when it is not empty
+func appendList(list, value string) string {
+	if list == "" {
+		return value
+	}
+
+	return list + ", " + value
+}
@@ -0,0 +1,146 @@
+package net
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title      string
+		policy     ForwardedHeadersPolicy
+		forwarded  bool
+		remoteAddr string
+		tls        bool
+		header     http.Header
+		expected   http.Header
+	}{{
+		title:      "keep",
+		policy:     ForwardedHeadersKeep,
+		remoteAddr: "192.168.0.1:5678",
+		header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4"}},
+		expected:   http.Header{"X-Forwarded-For": []string{"1.2.3.4"}},
+	}, {
+		title:      "strip",
+		policy:     ForwardedHeadersStrip,
+		remoteAddr: "192.168.0.1:5678",
+		header: http.Header{
+			"X-Forwarded-For":   []string{"1.2.3.4"},
+			"X-Forwarded-Proto": []string{"https"},
+			"X-Forwarded-Host":  []string{"www.example.org"},
+			"X-Forwarded-Port":  []string{"443"},
+			"Forwarded":         []string{"for=1.2.3.4"},
+			"X-Foo":             []string{"bar"},
+		},
+		expected: http.Header{"X-Foo": []string{"bar"}},
+	}, {
+		title:      "append, untrusted peer",
+		policy:     ForwardedHeadersAppend,
+		remoteAddr: "192.168.0.1:5678",
+		header: http.Header{
+			"X-Forwarded-For":   []string{"1.2.3.4"},
+			"X-Forwarded-Proto": []string{"https"},
+		},
+		expected: http.Header{
+			"X-Forwarded-For":   []string{"192.168.0.1"},
+			"X-Forwarded-Proto": []string{"http"},
+			"X-Forwarded-Host":  []string{"www.example.org"},
+		},
+	}, {
+		title:      "append, trusted peer",
+		policy:     ForwardedHeadersAppend,
+		remoteAddr: "10.0.0.1:5678",
+		header: http.Header{
+			"X-Forwarded-For":   []string{"1.2.3.4"},
+			"X-Forwarded-Proto": []string{"https"},
+			"X-Forwarded-Host":  []string{"public.example.org"},
+		},
+		expected: http.Header{
+			"X-Forwarded-For":   []string{"1.2.3.4, 10.0.0.1"},
+			"X-Forwarded-Proto": []string{"https"},
+			"X-Forwarded-Host":  []string{"public.example.org"},
+		},
+	}, {
+		title:      "append, Forwarded",
+		policy:     ForwardedHeadersAppend,
+		forwarded:  true,
+		remoteAddr: "10.0.0.1:5678",
+		tls:        true,
+		header:     http.Header{"Forwarded": []string{`for="[2001:db8::1]"`}},
+		expected: http.Header{
+			"X-Forwarded-For":   []string{"10.0.0.1"},
+			"X-Forwarded-Proto": []string{"https"},
+			"X-Forwarded-Host":  []string{"www.example.org"},
+			"Forwarded":         []string{`for="[2001:db8::1]", for=10.0.0.1;proto=https;host="www.example.org"`},
+		},
+	}, {
+		title:      "overwrite, trusted chain",
+		policy:     ForwardedHeadersOverwrite,
+		forwarded:  true,
+		remoteAddr: "10.0.0.1:5678",
+		header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4, 5.6.7.8, 10.0.0.2"}},
+		expected: http.Header{
+			"X-Forwarded-For":   []string{"5.6.7.8"},
+			"X-Forwarded-Proto": []string{"http"},
+			"X-Forwarded-Host":  []string{"www.example.org"},
+			"Forwarded":         []string{`for=5.6.7.8;proto=http;host="www.example.org"`},
+		},
+	}, {
+		title:      "overwrite, untrusted peer",
+		policy:     ForwardedHeadersOverwrite,
+		remoteAddr: "192.168.0.1:5678",
+		header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4"}},
+		expected: http.Header{
+			"X-Forwarded-For":   []string{"192.168.0.1"},
+			"X-Forwarded-Proto": []string{"http"},
+			"X-Forwarded-Host":  []string{"www.example.org"},
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r := &http.Request{
+				RemoteAddr: test.remoteAddr,
+				Host:       "www.example.org",
+				Header:     test.header,
+			}
+
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			f := &ForwardedHeaders{Policy: test.policy, TrustedProxies: trusted, Forwarded: test.forwarded}
+			f.Set(r)
+			if len(r.Header) != len(test.expected) {
+				t.Fatalf("invalid headers, expected: %v, got: %v", test.expected, r.Header)
+			}
+
+			for k := range test.expected {
+				if r.Header.Get(k) != test.expected.Get(k) {
+					t.Errorf("invalid header %s, expected: %q, got: %q", k, test.expected.Get(k), r.Header.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestParseForwardedHeadersPolicy(t *testing.T) {
+	for _, p := range []ForwardedHeadersPolicy{
+		ForwardedHeadersKeep,
+		ForwardedHeadersAppend,
+		ForwardedHeadersOverwrite,
+		ForwardedHeadersStrip,
+	} {
+		if pp, err := ParseForwardedHeadersPolicy(p.String()); err != nil || pp != p {
+			t.Errorf("failed to parse policy %s: %v", p, err)
+		}
+	}
+
+	if _, err := ParseForwardedHeadersPolicy("foo"); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -1,6 +1,7 @@
 package net
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -61,3 +62,87 @@ func RemoteHostFromLast(r *http.Request) net.IP {
 
 	return parse(r.RemoteAddr)
 }
+
+// ParseCIDRs parses a list of IP addresses or networks in CIDR notation.
+// The addresses without a netmask are treated as single host networks,
+// both for IPv4 and IPv6.
+func ParseCIDRs(s []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, si := range s {
+		if !strings.Contains(si, "/") {
+			ip := net.ParseIP(si)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: si}
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+
+			si = fmt.Sprintf("%s/%d", si, bits)
+		}
+
+		_, n, err := net.ParseCIDR(si)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoteHostTrusted returns the remote address of the client, taking
+// the 'X-Forwarded-For' header into account only as far as it was set
+// by trusted proxies. When the direct peer is not a trusted proxy, its
+// address is returned, otherwise the addresses in the header are checked
+// from right to left, and the first one not belonging to a trusted proxy
+// is returned. When every address belongs to a trusted proxy, the first
+// one in the header is returned.
+//
+// Example, where proxy1 and proxy2 are trusted:
+//
+//     X-Forwarded-For: spoofed, client, proxy1
+//     RemoteAddr: proxy2
+func RemoteHostTrusted(r *http.Request, trusted []*net.IPNet) net.IP {
+	remote := parse(r.RemoteAddr)
+	if remote == nil || !containsIP(trusted, remote) {
+		return remote
+	}
+
+	ffs := r.Header.Get("X-Forwarded-For")
+	if ffs == "" {
+		return remote
+	}
+
+	ffa := strings.Split(ffs, ",")
+	var ip net.IP
+	for i := len(ffa) - 1; i >= 0; i-- {
+		ip = parse(strings.TrimSpace(ffa[i]))
+		if ip == nil {
+			// the header is invalid from this point, the last
+			// trusted address is the best guess
+			break
+		}
+
+		if !containsIP(trusted, ip) {
+			return ip
+		}
+
+		remote = ip
+	}
+
+	return remote
+}
@@ -84,3 +84,59 @@ func BenchmarkRemoteHostFromLast(b *testing.B) {
 		RemoteHostFromLast(r)
 	}
 }
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", "1.2.3.4", "2001:db8::1", "2001:db8:1::/48"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s []string
+	for _, n := range nets {
+		s = append(s, n.String())
+	}
+
+	if strings.Join(s, " ") != "10.0.0.0/8 1.2.3.4/32 2001:db8::1/128 2001:db8:1::/48" {
+		t.Error("failed to parse networks", s)
+	}
+
+	for _, invalid := range []string{"", "foo", "1.2.3.4/33", "1.2.3"} {
+		if _, err := ParseCIDRs([]string{invalid}); err == nil {
+			t.Error("failed to fail", invalid)
+		}
+	}
+}
+
+func TestRemoteHostTrusted(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		remote string
+		fwdHdr string
+		want   string
+	}{
+		{"no header", "1.2.3.4:9090", "", "1.2.3.4"},
+		{"untrusted peer, header ignored", "1.2.3.4:9090", "5.6.7.8", "1.2.3.4"},
+		{"trusted peer, no header", "10.0.0.1:9090", "", "10.0.0.1"},
+		{"trusted peer", "10.0.0.1:9090", "5.6.7.8", "5.6.7.8"},
+		{"trusted chain", "10.0.0.1:9090", "5.6.7.8, 192.168.1.1, 10.2.3.4", "5.6.7.8"},
+		{"spoofed entries ignored", "10.0.0.1:9090", "9.9.9.9, 5.6.7.8, 10.2.3.4", "5.6.7.8"},
+		{"all trusted", "10.0.0.1:9090", "10.0.0.2, 10.0.0.3", "10.0.0.2"},
+		{"invalid entry", "10.0.0.1:9090", "5.6.7.8, invalid, 10.0.0.2", "10.0.0.2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remote, Header: make(http.Header)}
+			if tt.fwdHdr != "" {
+				r.Header.Set("X-Forwarded-For", tt.fwdHdr)
+			}
+
+			if got := RemoteHostTrusted(r, trusted); !got.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("unexpected IP address: %v, wanted: %v", got, tt.want)
+			}
+		})
+	}
+}
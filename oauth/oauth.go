@@ -30,7 +30,8 @@ The structure of the user credentials document:
 
 The GetToken method ignores the expiration date and makes a new request to the
 OAuth2 service on every call, so storing the token, if necessary, is the
-responsibility of the calling code.
+responsibility of the calling code. GetTokenWithExpiry returns also the
+lifetime of the token, so that the calling code can refresh it in time.
 */
 package oauth
 
@@ -41,6 +42,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 )
 
 const (
@@ -81,20 +83,28 @@ func New(credentialsDir, oauthUrl, permissionScopes string) *OAuthClient {
 
 // Returns a new authentication token.
 func (oc *OAuthClient) GetToken() (string, error) {
+	token, _, err := oc.GetTokenWithExpiry()
+	return token, err
+}
+
+// Returns a new authentication token, and the duration of its validity.
+// When the OAuth2 service doesn't report the expiration, the returned
+// duration is 0.
+func (oc *OAuthClient) GetTokenWithExpiry() (string, time.Duration, error) {
 	uc, err := oc.getUserCredentials()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	cc, err := oc.getClientCredentials()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	postBody := oc.getAuthPostBody(uc)
 	req, err := http.NewRequest("POST", oc.oauthUrl, strings.NewReader(postBody))
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	req.SetBasicAuth(cc.Id, cc.Secret)
@@ -102,23 +112,23 @@ func (oc *OAuthClient) GetToken() (string, error) {
 
 	response, err := oc.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	defer response.Body.Close()
 
 	authResponseBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	var ar *authResponse
 	err = json.Unmarshal(authResponseBody, &ar)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	return ar.AccessToken, nil
+	return ar.AccessToken, time.Duration(ar.ExpiresIn) * time.Second, nil
 }
 
 // Prepares the POST body of the authentication request.
@@ -20,6 +20,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 const (
@@ -65,7 +66,7 @@ var successHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r
 	enc := json.NewEncoder(w)
 
 	// ignore error
-	enc.Encode(&authResponse{AccessToken: testToken})
+	enc.Encode(&authResponse{AccessToken: testToken, ExpiresIn: 3600})
 })
 
 var failureHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -145,6 +146,25 @@ func TestAuthenticate(t *testing.T) {
 	}
 }
 
+func TestAuthenticateWithExpiry(t *testing.T) {
+	oas := httptest.NewServer(successHandler)
+	defer oas.Close()
+	oauthClient := New("", oas.URL, "scope0 scope1")
+	authToken, expiresIn, err := oauthClient.GetTokenWithExpiry()
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if authToken != testToken {
+		t.Error("invalid token", authToken)
+	}
+
+	if expiresIn != time.Hour {
+		t.Error("invalid expiry", expiresIn)
+	}
+}
+
 func TestAuthenticateFail(t *testing.T) {
 	oas := httptest.NewServer(failureHandler)
 	defer oas.Close()
@@ -170,7 +170,11 @@ func (n *node) splitCommonPrefix(existingNodeIndex int, path string) (*node, int
 	}
 
 	commonPrefix := path[0:i]
-	childNode.path = childNode.path[i:]
+
+	// The existing node is copied instead of modified, because it may be
+	// shared with other copies of the tree.
+	childCopy := *childNode
+	childCopy.path = childNode.path[i:]
 
 	// Create a new intermediary node in the place of the existing node, with
 	// the existing node as a child.
@@ -178,14 +182,181 @@ func (n *node) splitCommonPrefix(existingNodeIndex int, path string) (*node, int
 		path:     commonPrefix,
 		priority: childNode.priority,
 		// Index is the first letter of the non-common part of the path.
-		staticIndices: []byte{childNode.path[0]},
-		staticChild:   []*node{childNode},
+		staticIndices: []byte{childCopy.path[0]},
+		staticChild:   []*node{&childCopy},
 	}
 	n.staticChild[existingNodeIndex] = newNode
 
 	return newNode, i
 }
 
+// splits the next token from the path, the same way as addPath
+func nextToken(path string) (token string, remaining string, nextSlash int) {
+	nextSlash = strings.Index(path, "/")
+	switch {
+	case path[0] == '/':
+		return "/", path[1:], nextSlash
+	case nextSlash == -1:
+		return path, "", nextSlash
+	default:
+		return path[:nextSlash], path[nextSlash:], nextSlash
+	}
+}
+
+// copy-on-write version of addPath. It returns a copy of the node with
+// the value associated to the path, without modifying the original node
+// or any of its descendants. The unaffected subtrees are shared between
+// the original node and the copy.
+func (n *node) withPath(path string, value interface{}) (*node, error) {
+	c := *n
+	if len(path) == 0 {
+		c.leafValue = value
+		return &c, nil
+	}
+
+	first := path[0]
+	thisToken, remainingPath, nextSlash := nextToken(path)
+
+	switch first {
+	case '*':
+		catchAll := n.catchAllChild
+		if catchAll == nil {
+			catchAll = &node{path: thisToken[1:], isCatchAll: true}
+		}
+
+		if path[1:] != catchAll.path {
+			return nil, fmt.Errorf(
+				"catch-all name in %s doesn't match %s",
+				path, catchAll.path)
+		}
+
+		if nextSlash != -1 {
+			return nil, fmt.Errorf("/ after catch-all found in %s", path)
+		}
+
+		catchAllCopy := *catchAll
+		catchAllCopy.leafValue = value
+		c.catchAllChild = &catchAllCopy
+		return &c, nil
+	case ':':
+		wildcard := n.wildcardChild
+		if wildcard == nil {
+			wildcard = &node{path: "wildcard"}
+		}
+
+		wildcardCopy, err := wildcard.withPath(remainingPath, value)
+		if err != nil {
+			return nil, err
+		}
+
+		c.wildcardChild = wildcardCopy
+		return &c, nil
+	}
+
+	if strings.ContainsAny(thisToken, ":*") {
+		return nil, fmt.Errorf("* or : in middle of path component %s", path)
+	}
+
+	c.staticIndices = append([]byte(nil), n.staticIndices...)
+	c.staticChild = append([]*node(nil), n.staticChild...)
+	for i, index := range c.staticIndices {
+		if first == index {
+			child, prefixSplit := c.splitCommonPrefix(i, thisToken)
+			childCopy, err := child.withPath(path[prefixSplit:], value)
+			if err != nil {
+				return nil, err
+			}
+
+			childCopy.priority++
+			c.staticChild[i] = childCopy
+			c.sortStaticChild(i)
+			return &c, nil
+		}
+	}
+
+	child, err := (&node{path: thisToken}).withPath(remainingPath, value)
+	if err != nil {
+		return nil, err
+	}
+
+	c.staticIndices = append(c.staticIndices, first)
+	c.staticChild = append(c.staticChild, child)
+	return &c, nil
+}
+
+func (n *node) empty() bool {
+	return n.leafValue == nil && len(n.staticChild) == 0 && n.wildcardChild == nil && n.catchAllChild == nil
+}
+
+// copy-on-write removal of the value associated to the path. It returns
+// the same node when the path was not found, and nil when the node became
+// empty. The original node and its descendants are not modified.
+func (n *node) withoutPath(path string) *node {
+	c := *n
+	if len(path) == 0 {
+		if n.leafValue == nil {
+			return n
+		}
+
+		c.leafValue = nil
+	} else {
+		first := path[0]
+		thisToken, remainingPath, _ := nextToken(path)
+
+		switch first {
+		case '*':
+			if n.catchAllChild == nil || n.catchAllChild.path != thisToken[1:] {
+				return n
+			}
+
+			c.catchAllChild = nil
+		case ':':
+			if n.wildcardChild == nil {
+				return n
+			}
+
+			wildcard := n.wildcardChild.withoutPath(remainingPath)
+			if wildcard == n.wildcardChild {
+				return n
+			}
+
+			c.wildcardChild = wildcard
+		default:
+			i := -1
+			for j, index := range n.staticIndices {
+				if first == index {
+					i = j
+					break
+				}
+			}
+
+			if i < 0 || !strings.HasPrefix(path, n.staticChild[i].path) {
+				return n
+			}
+
+			child := n.staticChild[i]
+			childCopy := child.withoutPath(path[len(child.path):])
+			if childCopy == child {
+				return n
+			}
+
+			if childCopy == nil {
+				c.staticIndices = append(append([]byte(nil), n.staticIndices[:i]...), n.staticIndices[i+1:]...)
+				c.staticChild = append(append([]*node(nil), n.staticChild[:i]...), n.staticChild[i+1:]...)
+			} else {
+				c.staticChild = append([]*node(nil), n.staticChild...)
+				c.staticChild[i] = childCopy
+			}
+		}
+	}
+
+	if c.empty() {
+		return nil
+	}
+
+	return &c
+}
+
 func (n *node) search(path string, m Matcher) (found *node, params []string, value interface{}) {
 	pathLen := len(path)
 	if pathLen == 0 {
@@ -289,6 +460,31 @@ func (t *Tree) Add(path string, value interface{}) error {
 	return nil
 }
 
+// Set returns a copy of the tree, where the value is associated to the
+// path. It accepts the same paths as Add. The original tree is not
+// modified, and the parts of the tree not affected by the change are
+// shared between the original tree and the copy, which makes it cheap
+// to apply changes to large trees that are in use for lookups.
+func (t *Tree) Set(path string, value interface{}) (*Tree, error) {
+	n, err := (*node)(t).withPath(path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*Tree)(n), nil
+}
+
+// Delete returns a copy of the tree, where no value is associated to the
+// path. Just like with Set, the original tree is not modified.
+func (t *Tree) Delete(path string) *Tree {
+	n := (*node)(t).withoutPath(path[1:])
+	if n == nil {
+		return &Tree{}
+	}
+
+	return (*Tree)(n)
+}
+
 // Lookup tries to find a value in the tree associated to a path. If the found path definition contains
 // wildcards, the values of the wildcards are returned in the second argument.
 func (t *Tree) Lookup(path string) (interface{}, []string) {
@@ -307,6 +307,93 @@ func TestDefaultMatcher(t *testing.T) {
 	}
 }
 
+func TestSetDelete(t *testing.T) {
+	paths := []struct{ path, lookup string }{
+		{"/", "/"},
+		{"/images", "/images"},
+		{"/images/abc.jpg", "/images/abc.jpg"},
+		{"/images/:imgname", "/images/def.jpg"},
+		{"/images/*path", "/images/a/b.jpg"},
+		{"/imaginary", "/imaginary"},
+		{"/users/:id/profile", "/users/42/profile"},
+		{"/users/:id/settings", "/users/42/settings"},
+		{"/static/*", "/static/style.css"},
+	}
+
+	var (
+		tree  = &Tree{}
+		trees []*Tree
+	)
+
+	for i, p := range paths {
+		next, err := tree.Set(p.path, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		trees = append(trees, next)
+		tree = next
+	}
+
+	// every intermediate version sees only its own values
+	for i, tr := range trees {
+		for j, p := range paths {
+			v, _ := tr.Lookup(p.lookup)
+			if j <= i && v != j {
+				t.Errorf("version %d: expected %d for %s, got: %v", i, j, p.lookup, v)
+			}
+		}
+	}
+
+	if _, err := tree.Set("/images/*other", 42); err == nil {
+		t.Error("failed to fail on conflicting catch-all name")
+	}
+
+	if _, err := tree.Set("/foo:bar", 42); err == nil {
+		t.Error("failed to fail on invalid path")
+	}
+
+	replaced, err := tree.Set("/images/abc.jpg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := replaced.Lookup("/images/abc.jpg"); v != 42 {
+		t.Error("failed to replace value, got:", v)
+	}
+
+	if v, _ := tree.Lookup("/images/abc.jpg"); v != 2 {
+		t.Error("original tree modified, got:", v)
+	}
+
+	deleted := tree.Delete("/images/abc.jpg")
+	if v, params := deleted.Lookup("/images/abc.jpg"); v != 3 || len(params) != 1 || params[0] != "abc.jpg" {
+		t.Error("failed to delete, got:", v, params)
+	}
+
+	if v, _ := tree.Lookup("/images/abc.jpg"); v != 2 {
+		t.Error("original tree modified by delete, got:", v)
+	}
+
+	if tree.Delete("/not/found") != tree {
+		t.Error("expected the same tree when deleting a missing path")
+	}
+
+	for _, p := range paths {
+		tree = tree.Delete(p.path)
+	}
+
+	if !(*node)(tree).empty() {
+		t.Error("failed to prune the empty nodes")
+	}
+
+	for i, p := range paths {
+		if v, _ := trees[len(trees)-1].Lookup(p.lookup); v != i {
+			t.Errorf("original tree modified by delete, expected %d for %s, got: %v", i, p.lookup, v)
+		}
+	}
+}
+
 func BenchmarkTreeNullRequest(b *testing.B) {
 	b.ReportAllocs()
 	tree := &node{path: "/"}
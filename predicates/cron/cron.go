@@ -18,16 +18,19 @@ import (
 	"time"
 )
 
-type clock func() time.Time
+// Clock returns the current time. It can be used to control the time
+// seen by the predicate, e.g. in tests.
+type Clock func() time.Time
 
 type spec struct {
+	clock Clock
 }
 
 func (*spec) Name() string {
 	return "Cron"
 }
 
-func (*spec) Create(args []interface{}) (routing.Predicate, error) {
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 	if len(args) != 1 {
 		return nil, predicates.ErrInvalidPredicateParameters
 	}
@@ -44,13 +47,13 @@ func (*spec) Create(args []interface{}) (routing.Predicate, error) {
 
 	return &predicate{
 		mask:    mask,
-		getTime: time.Now,
+		getTime: s.clock,
 	}, nil
 }
 
 type predicate struct {
 	mask    *cronmask.CronMask
-	getTime clock
+	getTime Clock
 }
 
 func (p *predicate) Match(r *http.Request) bool {
@@ -60,5 +63,11 @@ func (p *predicate) Match(r *http.Request) bool {
 }
 
 func New() routing.PredicateSpec {
-	return &spec{}
+	return NewWithClock(time.Now)
+}
+
+// NewWithClock creates the Cron predicate, evaluated against the time
+// returned by the clock.
+func NewWithClock(c Clock) routing.PredicateSpec {
+	return &spec{clock: c}
 }
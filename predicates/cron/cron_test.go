@@ -60,7 +60,7 @@ func TestPredicateMatch(t *testing.T) {
 		msg     string
 		args    []interface{}
 		matches bool
-		clock   clock
+		clock   Clock
 	}{
 		{
 			"match everything",
@@ -68,10 +68,22 @@ func TestPredicateMatch(t *testing.T) {
 			true,
 			time.Now,
 		},
+		{
+			"match the first minutes of the hour",
+			[]interface{}{"0-9 * * * *"},
+			true,
+			func() time.Time { return time.Date(2020, 1, 1, 12, 5, 0, 0, time.UTC) },
+		},
+		{
+			"not match outside of the first minutes of the hour",
+			[]interface{}{"0-9 * * * *"},
+			false,
+			func() time.Time { return time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC) },
+		},
 	}
 
 	for _, tc := range testCases {
-		p, err := New().Create(tc.args)
+		p, err := NewWithClock(tc.clock).Create(tc.args)
 		if err != nil {
 			t.Error(err)
 			continue
@@ -0,0 +1,131 @@
+/*
+Package host implements a predicate to match the host of the requests,
+without having to use regular expressions for the common cases.
+
+The HostAny predicate accepts one or more host patterns, and it matches
+when the host of the request matches any of them. A pattern is either
+an exact host name, or a wildcard pattern, where the first label is *,
+matching any subdomain, but not the domain itself:
+
+	HostAny("example.org", "*.example.org") -> "https://www.example.org";
+
+When a pattern contains a port, the port of the request needs to match,
+too, otherwise the port of the request is ignored:
+
+	HostAny("example.org:9090") -> "https://internal.example.org";
+
+The matching is case insensitive, and ignores the trailing dot of a fully
+qualified host name in the request.
+*/
+package host
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+// The predicate can be referenced in eskip by the name "HostAny".
+const Name = "HostAny"
+
+type (
+	spec struct{}
+
+	pattern struct {
+		host     string
+		port     string
+		wildcard bool
+	}
+
+	predicate struct {
+		patterns []pattern
+	}
+)
+
+// New creates a predicate specification, whose instances match the host
+// of the requests against wildcard host patterns.
+func New() routing.PredicateSpec { return &spec{} }
+
+func (s *spec) Name() string { return Name }
+
+// splits the host and the optional port
+func splitHostPort(h string) (string, string) {
+	host, port, err := net.SplitHostPort(h)
+	if err != nil {
+		return h, ""
+	}
+
+	return host, port
+}
+
+func normalizeHost(h string) string {
+	return strings.TrimSuffix(strings.ToLower(h), ".")
+}
+
+func parsePattern(p string) (pattern, bool) {
+	host, port := splitHostPort(p)
+	host = normalizeHost(host)
+
+	var wildcard bool
+	if strings.HasPrefix(host, "*.") {
+		wildcard = true
+		host = host[1:]
+	}
+
+	if host == "" || host == "." || strings.Contains(host, "*") {
+		return pattern{}, false
+	}
+
+	return pattern{host: host, port: port, wildcard: wildcard}, true
+}
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &predicate{}
+	for _, a := range args {
+		sa, ok := a.(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		pi, ok := parsePattern(sa)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		p.patterns = append(p.patterns, pi)
+	}
+
+	return p, nil
+}
+
+func (p pattern) match(host, port string) bool {
+	if p.port != "" && p.port != port {
+		return false
+	}
+
+	if p.wildcard {
+		// the host stored with the leading dot for the wildcards
+		return strings.HasSuffix(host, p.host)
+	}
+
+	return host == p.host
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	host, port := splitHostPort(r.Host)
+	host = normalizeHost(host)
+	for _, pi := range p.patterns {
+		if pi.match(host, port) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package host
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHostAnyArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"invalid type",
+		[]interface{}{float64(1)},
+		true,
+	}, {
+		"empty host",
+		[]interface{}{""},
+		true,
+	}, {
+		"wildcard only",
+		[]interface{}{"*"},
+		true,
+	}, {
+		"wildcard in the middle",
+		[]interface{}{"www.*.example.org"},
+		true,
+	}, {
+		"ok",
+		[]interface{}{"example.org", "*.example.org", "example.org:9090"},
+		false,
+	}} {
+		p, err := New().Create(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && (err != nil || p == nil) {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestHostAnyMatch(t *testing.T) {
+	for _, ti := range []struct {
+		msg   string
+		args  []interface{}
+		host  string
+		match bool
+	}{{
+		"exact",
+		[]interface{}{"example.org"},
+		"example.org",
+		true,
+	}, {
+		"exact, case insensitive",
+		[]interface{}{"Example.org"},
+		"EXAMPLE.ORG",
+		true,
+	}, {
+		"exact, trailing dot",
+		[]interface{}{"example.org"},
+		"example.org.",
+		true,
+	}, {
+		"exact, port ignored",
+		[]interface{}{"example.org"},
+		"example.org:9090",
+		true,
+	}, {
+		"exact, no match",
+		[]interface{}{"example.org"},
+		"www.example.org",
+		false,
+	}, {
+		"wildcard",
+		[]interface{}{"*.example.org"},
+		"www.example.org",
+		true,
+	}, {
+		"wildcard, multiple labels",
+		[]interface{}{"*.example.org"},
+		"api.eu.example.org",
+		true,
+	}, {
+		"wildcard, apex not matched",
+		[]interface{}{"*.example.org"},
+		"example.org",
+		false,
+	}, {
+		"wildcard, suffix only",
+		[]interface{}{"*.example.org"},
+		"www.badexample.org",
+		false,
+	}, {
+		"port",
+		[]interface{}{"*.example.org:9090"},
+		"www.example.org:9090",
+		true,
+	}, {
+		"port, no match",
+		[]interface{}{"example.org:9090"},
+		"example.org:8080",
+		false,
+	}, {
+		"port, missing in the request",
+		[]interface{}{"example.org:9090"},
+		"example.org",
+		false,
+	}, {
+		"any of multiple",
+		[]interface{}{"example.org", "*.example.com"},
+		"www.example.com",
+		true,
+	}} {
+		p, err := New().Create(ti.args)
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		if m := p.Match(&http.Request{Host: ti.host}); m != ti.match {
+			t.Error(ti.msg, "failed to match", m, ti.match)
+		}
+	}
+}
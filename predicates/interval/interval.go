@@ -19,6 +19,10 @@ date. Only one date is required to construct the predicate.
 After predicate matches only if current date is after or equal to
 the specified date. Only one date is required to construct the predicate.
 
+The predicates are evaluated against the system time, by default. The
+constructors with a clock argument, e.g. NewBetweenWithClock, allow to
+control the time, e.g. in tests.
+
 Examples:
 
 	example1: Path("/zalando") && Between("2016-01-01T12:00:00+02:00", "2016-02-01T12:00:00+02:00") -> "https://www.zalando.de";
@@ -49,8 +53,13 @@ const (
 	after
 )
 
+// Clock returns the current time. It can be used to control the time
+// seen by the predicates, e.g. in tests.
+type Clock func() time.Time
+
 type spec struct {
-	typ intervalType
+	typ   intervalType
+	clock Clock
 }
 
 type predicate struct {
@@ -61,13 +70,25 @@ type predicate struct {
 }
 
 // Creates Between predicate.
-func NewBetween() routing.PredicateSpec { return &spec{between} }
+func NewBetween() routing.PredicateSpec { return NewBetweenWithClock(time.Now) }
 
 // Creates Before predicate.
-func NewBefore() routing.PredicateSpec { return &spec{before} }
+func NewBefore() routing.PredicateSpec { return NewBeforeWithClock(time.Now) }
 
 // Creates After predicate.
-func NewAfter() routing.PredicateSpec { return &spec{after} }
+func NewAfter() routing.PredicateSpec { return NewAfterWithClock(time.Now) }
+
+// Creates Between predicate, evaluated against the time returned by
+// the clock.
+func NewBetweenWithClock(c Clock) routing.PredicateSpec { return &spec{between, c} }
+
+// Creates Before predicate, evaluated against the time returned by the
+// clock.
+func NewBeforeWithClock(c Clock) routing.PredicateSpec { return &spec{before, c} }
+
+// Creates After predicate, evaluated against the time returned by the
+// clock.
+func NewAfterWithClock(c Clock) routing.PredicateSpec { return &spec{after, c} }
 
 func (s *spec) Name() string {
 	switch s.typ {
@@ -94,24 +115,20 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 		}
 	}
 
-	defaultGetTime := func() time.Time {
-		return time.Now()
-	}
-
 	switch s.typ {
 	case between:
 		if begin, end, ok := parseArgs(args[0], args[1]); ok {
 			if begin.Before(end) {
-				return &predicate{s.typ, begin, end, defaultGetTime}, nil
+				return &predicate{s.typ, begin, end, s.clock}, nil
 			}
 		}
 	case before:
 		if end, ok := parseArg(args[0]); ok {
-			return &predicate{typ: s.typ, end: end, getTime: defaultGetTime}, nil
+			return &predicate{typ: s.typ, end: end, getTime: s.clock}, nil
 		}
 	case after:
 		if begin, ok := parseArg(args[0]); ok {
-			return &predicate{typ: s.typ, begin: begin, getTime: defaultGetTime}, nil
+			return &predicate{typ: s.typ, begin: begin, getTime: s.clock}, nil
 		}
 	}
 
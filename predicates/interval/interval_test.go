@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/zalando/skipper/routing"
 )
 
 func TestCreateBetween(t *testing.T) {
@@ -338,3 +340,28 @@ func TestMatchAfter(t *testing.T) {
 		}
 	}
 }
+
+func TestWithClock(t *testing.T) {
+	clock := func() time.Time { return time.Date(2016, 1, 15, 12, 0, 0, 0, time.UTC) }
+	for _, c := range []struct {
+		spec    routing.PredicateSpec
+		args    []interface{}
+		matches bool
+	}{
+		{NewBetweenWithClock(clock), []interface{}{"2016-01-01T12:00:00Z", "2016-02-01T12:00:00Z"}, true},
+		{NewBetweenWithClock(clock), []interface{}{"2016-02-01T12:00:00Z", "2016-03-01T12:00:00Z"}, false},
+		{NewBeforeWithClock(clock), []interface{}{"2016-02-01T12:00:00Z"}, true},
+		{NewBeforeWithClock(clock), []interface{}{"2016-01-01T12:00:00Z"}, false},
+		{NewAfterWithClock(clock), []interface{}{"2016-01-01T12:00:00Z"}, true},
+		{NewAfterWithClock(clock), []interface{}{"2016-02-01T12:00:00Z"}, false},
+	} {
+		p, err := c.spec.Create(c.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := p.Match(&http.Request{}); m != c.matches {
+			t.Errorf("%s%v: expected %t, got %t", c.spec.Name(), c.args, c.matches, m)
+		}
+	}
+}
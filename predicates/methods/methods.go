@@ -3,7 +3,9 @@
 Package methods implements a custom predicate to match routes
 based on the http method in request
 
-It supports multiple http methods, with case insensitive input
+It supports multiple http methods, with case insensitive input. Besides
+the standard methods, custom methods can be used, too, e.g. the WebDAV
+methods or PURGE, as long as they are valid HTTP tokens.
 
 Examples:
 
@@ -13,6 +15,9 @@ Examples:
     // matches GET or POST request
     example1: Methods("GET", "post") -> "http://example.org";
 
+    // matches WebDAV requests
+    example1: Methods("PROPFIND", "MKCOL") -> "http://example.org";
+
 */
 package methods
 
@@ -20,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/zalando/skipper/routing"
+	"golang.org/x/net/http/httpguts"
 	"net/http"
 	"strings"
 )
@@ -30,9 +36,7 @@ var ErrInvalidArgumentsCount = errors.New("at least one method should be specifi
 var ErrInvalidArgumentType = errors.New("only string values are allowed")
 
 type (
-	spec struct {
-		allowedMethods map[string]bool
-	}
+	spec struct{}
 
 	predicate struct {
 		methods map[string]bool
@@ -41,17 +45,7 @@ type (
 
 // New creates a new Methods predicate specification
 func New() routing.PredicateSpec {
-	return &spec{allowedMethods: map[string]bool{
-		http.MethodGet:     true,
-		http.MethodHead:    true,
-		http.MethodPost:    true,
-		http.MethodPut:     true,
-		http.MethodPatch:   true,
-		http.MethodDelete:  true,
-		http.MethodConnect: true,
-		http.MethodOptions: true,
-		http.MethodTrace:   true,
-	}}
+	return &spec{}
 }
 
 func (s *spec) Name() string { return Name }
@@ -73,11 +67,13 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 
 		method = strings.ToUpper(method)
 
-		if s.allowedMethods[method] {
-			predicate.methods[method] = true
-		} else {
+		// the methods need to be valid tokens, the same as the
+		// header names
+		if !httpguts.ValidHeaderFieldName(method) {
 			return nil, fmt.Errorf("method: %s is not allowed", method)
 		}
+
+		predicate.methods[method] = true
 	}
 
 	return &predicate, nil
@@ -26,8 +26,16 @@ func TestMethodsArgs(t *testing.T) {
 		true,
 	}, {
 		"invalid method",
-		[]interface{}{"name", "name2"},
+		[]interface{}{"GET", "PO ST"},
 		true,
+	}, {
+		"empty method",
+		[]interface{}{""},
+		true,
+	}, {
+		"custom methods",
+		[]interface{}{"PURGE", "propfind", "MKCOL"},
+		false,
 	}, {
 		"ok",
 		[]interface{}{http.MethodGet, http.MethodPost},
@@ -90,3 +98,22 @@ func TestMethodsMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestMethodsMatchCustom(t *testing.T) {
+	p, err := New().Create([]interface{}{"purge", "PROPFIND"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for method, match := range map[string]bool{
+		"PURGE":    true,
+		"PROPFIND": true,
+		"GET":      false,
+		"MKCOL":    false,
+	} {
+		r := &http.Request{Method: method}
+		if m := p.Match(r); m != match {
+			t.Error("failed to match", m, match, method)
+		}
+	}
+}
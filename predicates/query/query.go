@@ -1,9 +1,10 @@
 /*
-Package source implements a custom predicate to match routes
+Package query implements custom predicates to match routes
 based on the Query Params in URL
 
 It supports checking existence of query params and also checking whether
-query params value match to a given regular exp
+query params value match to a given regular exp, or equal to a given
+value
 
 Examples:
 
@@ -23,6 +24,10 @@ Examples:
     // matches http://example.org?bb=a&query=testing&query=example
     example1: QueryParam("query", "^example$") -> "http://example.org";
 
+    // matches the exact value
+    // matches http://example.org?version=beta, but not http://example.org?version=beta2
+    example1: QueryParamExact("version", "beta") -> "http://example.org";
+
 */
 package query
 
@@ -38,25 +43,45 @@ type matchType int
 const (
 	exists matchType = iota + 1
 	matches
+	equals
 )
 
 type predicate struct {
 	typ       matchType
 	paramName string
 	valueExp  *regexp.Regexp
+	value     string
+}
+type spec struct {
+	exact bool
 }
-type spec struct{}
 
-const name = "QueryParam"
+const (
+	name      = "QueryParam"
+	exactName = "QueryParamExact"
+)
 
 // New creates a new QueryParam predicate specification.
 func New() routing.PredicateSpec { return &spec{} }
 
+// NewExact creates a new QueryParamExact predicate specification. The
+// predicate matches when any of the values of the query param equals to
+// the given value.
+func NewExact() routing.PredicateSpec { return &spec{exact: true} }
+
 func (s *spec) Name() string {
+	if s.exact {
+		return exactName
+	}
+
 	return name
 }
 
 func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if s.exact {
+		return createExact(args)
+	}
+
 	if len(args) == 0 || len(args) > 2 {
 		return nil, predicates.ErrInvalidPredicateParameters
 	}
@@ -67,7 +92,7 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 	case !ok1:
 		return nil, predicates.ErrInvalidPredicateParameters
 	case len(args) == 1:
-		return &predicate{exists, name, nil}, nil
+		return &predicate{typ: exists, paramName: name}, nil
 	case len(args) == 2:
 		value, ok2 := args[1].(string)
 		if !ok2 {
@@ -77,13 +102,27 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &predicate{matches, name, valueExp}, nil
+		return &predicate{typ: matches, paramName: name, valueExp: valueExp}, nil
 	default:
 		return nil, predicates.ErrInvalidPredicateParameters
 	}
 
 }
 
+func createExact(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	name, ok1 := args[0].(string)
+	value, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &predicate{typ: equals, paramName: name, value: value}, nil
+}
+
 func (p *predicate) Match(r *http.Request) bool {
 	queryMap := r.URL.Query()
 	vals, ok := queryMap[p.paramName]
@@ -102,7 +141,14 @@ func (p *predicate) Match(r *http.Request) bool {
 			}
 			return false
 		}
+	case equals:
+		for _, v := range vals {
+			if v == p.value {
+				return true
+			}
+		}
 
+		return false
 	}
 
 	return false
@@ -140,3 +140,73 @@ func TestMatchArgs(t *testing.T) {
 		}()
 	}
 }
+
+func TestExact(t *testing.T) {
+	for _, ti := range []struct {
+		msg   string
+		args  []interface{}
+		query string
+		err   bool
+		match bool
+	}{{
+		msg:  "missing value",
+		args: []interface{}{"version"},
+		err:  true,
+	}, {
+		msg:  "not a string",
+		args: []interface{}{"version", 2.0},
+		err:  true,
+	}, {
+		msg:   "matches the exact value",
+		args:  []interface{}{"version", "beta"},
+		query: "version=beta",
+		match: true,
+	}, {
+		msg:   "does not match a longer value",
+		args:  []interface{}{"version", "beta"},
+		query: "version=beta2",
+	}, {
+		msg:   "does not treat the value as a regexp",
+		args:  []interface{}{"version", "b.*"},
+		query: "version=beta",
+	}, {
+		msg:   "matches one of multiple values",
+		args:  []interface{}{"version", "beta"},
+		query: "version=alpha&version=beta",
+		match: true,
+	}, {
+		msg:   "matches empty value",
+		args:  []interface{}{"version", ""},
+		query: "version=",
+		match: true,
+	}, {
+		msg:   "does not match missing param",
+		args:  []interface{}{"version", ""},
+		query: "foo=bar",
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			spec := NewExact()
+			if spec.Name() != "QueryParamExact" {
+				t.Fatalf("invalid name: %s", spec.Name())
+			}
+
+			p, err := spec.Create(ti.args)
+			if ti.err {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest("GET", "http://example.org/?"+ti.query, nil)
+			if m := p.Match(req); m != ti.match {
+				t.Errorf("unexpected match result, got: %v, expected: %v", m, ti.match)
+			}
+		})
+	}
+}
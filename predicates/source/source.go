@@ -23,6 +23,13 @@ There are two flavors of this predicate Source() and SourceFromLast().
 The difference is that Source() finds the remote host as first entry from
 the X-Forwarded-For header and SourceFromLast() as last entry.
 
+When the predicates are created with a list of trusted proxies, see
+NewWithTrustedProxies, the X-Forwarded-For header is taken into account
+only when the request was received from a trusted proxy, and the source
+is the last address in the header that doesn't belong to a trusted
+proxy. This prevents the clients from spoofing their address by sending
+the header themselves.
+
 Examples:
 
     // only match requests from 1.2.3.4
@@ -43,7 +50,6 @@ import (
 	"errors"
 	"net"
 	"net/http"
-	"strings"
 
 	snet "github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/routing"
@@ -57,17 +63,28 @@ const (
 var InvalidArgsError = errors.New("invalid arguments")
 
 type spec struct {
-	fromLast bool
+	fromLast       bool
+	trustedProxies []*net.IPNet
 }
 
 type predicate struct {
 	fromLast           bool
-	acceptedSourceNets []net.IPNet
+	trustedProxies     []*net.IPNet
+	acceptedSourceNets []*net.IPNet
 }
 
 func New() routing.PredicateSpec         { return &spec{} }
 func NewFromLast() routing.PredicateSpec { return &spec{fromLast: true} }
 
+// NewWithTrustedProxies creates the Source and the SourceFromLast
+// predicates, using the X-Forwarded-For header only as far as it was
+// set by the trusted proxies. With the trusted proxies, the two
+// predicates behave the same way.
+func NewWithTrustedProxies(trustedProxies []*net.IPNet) (source, sourceFromLast routing.PredicateSpec) {
+	return &spec{trustedProxies: trustedProxies},
+		&spec{fromLast: true, trustedProxies: trustedProxies}
+}
+
 func (s *spec) Name() string {
 	if s.fromLast {
 		return NameLast
@@ -80,34 +97,35 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 		return nil, InvalidArgsError
 	}
 
-	p := &predicate{fromLast: s.fromLast}
+	p := &predicate{fromLast: s.fromLast, trustedProxies: s.trustedProxies}
 
+	sargs := make([]string, len(args))
 	for i := range args {
-		if s, ok := args[i].(string); ok {
-			var netmask = s
-			if !strings.Contains(s, "/") {
-				netmask = s + "/32"
-			}
-			_, net, err := net.ParseCIDR(netmask)
-
-			if err != nil {
-				return nil, InvalidArgsError
-			}
-
-			p.acceptedSourceNets = append(p.acceptedSourceNets, *net)
-		} else {
+		s, ok := args[i].(string)
+		if !ok {
 			return nil, InvalidArgsError
 		}
+
+		sargs[i] = s
+	}
+
+	nets, err := snet.ParseCIDRs(sargs)
+	if err != nil {
+		return nil, InvalidArgsError
 	}
 
+	p.acceptedSourceNets = nets
 	return p, nil
 }
 
 func (p *predicate) Match(r *http.Request) bool {
 	var src net.IP
-	if p.fromLast {
+	switch {
+	case len(p.trustedProxies) > 0:
+		src = snet.RemoteHostTrusted(r, p.trustedProxies)
+	case p.fromLast:
 		src = snet.RemoteHostFromLast(r)
-	} else {
+	default:
 		src = snet.RemoteHost(r)
 	}
 
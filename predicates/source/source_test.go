@@ -3,6 +3,9 @@ package source
 import (
 	"net/http"
 	"testing"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/routing"
 )
 
 func TestCreate(t *testing.T) {
@@ -111,6 +114,11 @@ func TestMatching(t *testing.T) {
 		[]interface{}{"C0:FF::EE"},
 		&http.Request{RemoteAddr: "C0:FF::EE"},
 		true,
+	}, {
+		"should match only the single IPv6 address without mask",
+		[]interface{}{"C0:FF::EE"},
+		&http.Request{RemoteAddr: "C0:FF::EF"},
+		false,
 	}, {
 		"should work for IPv6 with mask - pass",
 		[]interface{}{"C0:FF::EE/127"},
@@ -211,3 +219,46 @@ func TestMatchingFromLast(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchingTrustedProxies(t *testing.T) {
+	trusted, err := snet.ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, sourceFromLast := NewWithTrustedProxies(trusted)
+	for _, ti := range []struct {
+		msg     string
+		req     *http.Request
+		matches bool
+	}{{
+		"direct request",
+		&http.Request{RemoteAddr: "8.8.8.8:9090"},
+		true,
+	}, {
+		"header from untrusted peer ignored",
+		&http.Request{RemoteAddr: "7.7.7.7:9090", Header: http.Header{"X-Forwarded-For": []string{"8.8.8.8"}}},
+		false,
+	}, {
+		"header from trusted proxy",
+		&http.Request{RemoteAddr: "10.0.0.1:9090", Header: http.Header{"X-Forwarded-For": []string{"8.8.8.8, 10.0.0.2"}}},
+		true,
+	}, {
+		"spoofed header entry ignored",
+		&http.Request{RemoteAddr: "10.0.0.1:9090", Header: http.Header{"X-Forwarded-For": []string{"8.8.8.8, 7.7.7.7"}}},
+		false,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			for _, spec := range []routing.PredicateSpec{source, sourceFromLast} {
+				p, err := spec.Create([]interface{}{"8.8.8.8"})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if m := p.Match(ti.req); m != ti.matches {
+					t.Error(spec.Name(), "failed to match as expected", m, ti.matches)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+)
+
+var (
+	errRequestBodyTooLarge = errors.New("request body too large")
+
+	// the connection is closed, so that the rest of the body is not
+	// read by the server
+	errRequestBodyTooLargeResponse = &proxyError{
+		err:              errRequestBodyTooLarge,
+		code:             http.StatusRequestEntityTooLarge,
+		additionalHeader: http.Header{"Connection": []string{"close"}},
+	}
+)
+
+// limitedBody fails the reading of a request body with unknown length,
+// when it exceeds the limit
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errRequestBodyTooLarge
+	}
+
+	// reading one byte over the limit tells whether it was exceeded
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errRequestBodyTooLarge
+	}
+
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// returns the maximum request body size set by the filters of the route,
+// or the proxy default
+func (p *Proxy) requestBodyLimit(bag map[string]interface{}) int64 {
+	if s, ok := bag[filters.MaxRequestBodySizeKey].(int64); ok {
+		return s
+	}
+
+	return p.maxRequestBodySize
+}
+
+// checks the length of the request body, when known, and applies the limit
+// on the reading, when not known
+func (p *Proxy) limitRequestBody(ctx *context) error {
+	limit := p.requestBodyLimit(ctx.StateBag())
+	if limit <= 0 {
+		return nil
+	}
+
+	r := ctx.request
+	switch {
+	case r.ContentLength > limit:
+		return errRequestBodyTooLargeResponse
+	case r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody:
+		r.Body = &limitedBody{ReadCloser: r.Body, remaining: limit}
+	}
+
+	return nil
+}
+
+// tells whether the request body exceeded the limit while it was forwarded
+func requestBodyExceeded(r *http.Request) bool {
+	b, ok := r.Body.(*limitedBody)
+	return ok && b.exceeded
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxRequestBodySize(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	for _, test := range []struct {
+		title          string
+		filters        string
+		params         Params
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{{
+		title:          "no limit",
+		body:           "Hello, world!",
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "within the limit",
+		params:         Params{MaxRequestBodySize: 13},
+		body:           "Hello, world!",
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "exceeds the limit",
+		params:         Params{MaxRequestBodySize: 12},
+		body:           "Hello, world!",
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		title:          "chunked, within the limit",
+		params:         Params{MaxRequestBodySize: 13},
+		body:           "Hello, world!",
+		chunked:        true,
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "chunked, exceeds the limit",
+		params:         Params{MaxRequestBodySize: 12},
+		body:           "Hello, world!",
+		chunked:        true,
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		title:          "route limit",
+		filters:        `maxRequestBodySize(5)`,
+		body:           "Hello, world!",
+		expectedStatus: http.StatusRequestEntityTooLarge,
+	}, {
+		title:          "route limit overrides the default",
+		filters:        `maxRequestBodySize(0)`,
+		params:         Params{MaxRequestBodySize: 5},
+		body:           "Hello, world!",
+		expectedStatus: http.StatusOK,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			route := fmt.Sprintf(`* -> %q`, backend.URL)
+			if test.filters != "" {
+				route = fmt.Sprintf(`* -> %s -> %q`, test.filters, backend.URL)
+			}
+
+			tp, err := newTestProxyWithParams(route, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			var body io.Reader = strings.NewReader(test.body)
+			if test.chunked {
+				// hides the length of the body from the client
+				body = ioutil.NopCloser(body)
+			}
+
+			rsp, err := http.Post(ps.URL, "text/plain", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedStatus {
+				t.Fatalf("invalid status code, expected: %d, got: %d", test.expectedStatus, rsp.StatusCode)
+			}
+
+			if rsp.StatusCode != http.StatusOK {
+				if !rsp.Close {
+					t.Error("failed to close the connection")
+				}
+
+				return
+			}
+
+			b, _ := ioutil.ReadAll(rsp.Body)
+			if string(b) != test.body {
+				t.Errorf("invalid response body: %q", string(b))
+			}
+		})
+	}
+}
@@ -617,3 +617,43 @@ func TestBreakerMultipleHostsAndRouteBasedSettings(t *testing.T) {
 		},
 	})
 }
+
+func TestBreakerLoadBalanced(t *testing.T) {
+	backend := newFailingBackend()
+	defer backend.close()
+
+	p := proxytest.WithParams(builtin.MakeRegistry(), proxy.Params{
+		CloseIdleConnsPeriod: -1,
+		CircuitBreakers: circuit.NewRegistry(circuit.BreakerSettings{
+			Type:     circuit.ConsecutiveFailures,
+			Failures: testConsecutiveFailureCount,
+		}),
+	}, &eskip.Route{
+		BackendType: eskip.LBBackend,
+		LBAlgorithm: "roundRobin",
+		LBEndpoints: []string{backend.url},
+	})
+	defer p.Close()
+
+	c := &breakerTestContext{
+		t:        t,
+		proxy:    p,
+		backends: map[string]*failingBackend{defaultHost: backend},
+	}
+
+	for _, step := range []scenarioStep{
+		request(200),
+		checkBackendCounter(1),
+		setBackendFail,
+		times(testConsecutiveFailureCount, request(500)),
+		checkBackendCounter(testConsecutiveFailureCount),
+		requestOpen,
+		checkBackendCounter(0),
+	} {
+		if t.Failed() {
+			return
+		}
+
+		step(c)
+	}
+}
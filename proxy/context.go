@@ -27,6 +27,8 @@ type context struct {
 	response             *http.Response
 	route                *routing.Route
 	deprecatedServed     bool
+	grpcStatus           string
+	triedEndpoints       []string
 	servedWithResponse   bool // to support the deprecated way independently
 	pathParams           map[string]string
 	stateBag             map[string]interface{}
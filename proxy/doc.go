@@ -93,7 +93,11 @@ filter context.
 In case none of the filters handled the request, the response
 properties, including the status and the headers, are mapped to the
 outgoing response writer, and the response body is streamed to it, with
-continuous flushing.
+continuous flushing. The request and response bodies are never buffered
+as a whole. The flushing of the response body can be delayed by the
+ResponseFlushInterval option, to reduce the number of small writes, except
+for server sent events (text/event-stream), which are always flushed
+immediately.
 
 
 Routing Rules
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// ParseEgressProxy parses the URL of an egress proxy, accepting the http,
+// https and socks5 schemes.
+func ParseEgressProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported egress proxy scheme: %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing egress proxy host: %q", s)
+	}
+
+	return u, nil
+}
+
+func egressProxyURL(bag map[string]interface{}) string {
+	u, _ := bag[filters.BackendEgressProxyKey].(string)
+	return u
+}
+
+// returns the proxy function of the backend transports. The backends that
+// are proxies themselves, set by the backendIsProxy filter, are connected
+// directly, while the rest of the backends are connected through the
+// egress proxy, when it is set. The egress proxy can be an http, https or
+// socks5 proxy.
+func egressProxyFunc(egress *url.URL) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if u, err := proxyFromHeader(req); u != nil || err != nil {
+			return u, err
+		}
+
+		return egress, nil
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestEgressProxy(t *testing.T) {
+	var requestURI string
+	egress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+	}))
+	defer egress.Close()
+
+	egressURL, err := url.Parse(egress.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title  string
+		routes string
+		params Params
+	}{{
+		title:  "global",
+		routes: `* -> "http://backend.example.org"`,
+		params: Params{EgressProxy: egressURL},
+	}, {
+		title:  "route",
+		routes: fmt.Sprintf(`* -> egressProxy(%q) -> "http://backend.example.org"`, egress.URL),
+	}, {
+		title:  "route overrides global",
+		routes: fmt.Sprintf(`* -> egressProxy(%q) -> "http://backend.example.org"`, egress.URL),
+		params: Params{EgressProxy: &url.URL{Scheme: "http", Host: "invalid.example.org:3128"}},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			requestURI = ""
+			tp, err := newTestProxyWithParams(test.routes, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://www.example.org/foo", nil)
+			tp.proxy.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("invalid status code: %d", w.Code)
+			}
+
+			if requestURI != "http://backend.example.org/foo" {
+				t.Errorf("failed to send the request through the egress proxy: %s", requestURI)
+			}
+		})
+	}
+}
@@ -17,6 +17,13 @@ type RoundTripper struct {
 }
 
 func NewRoundTripper(log logging.Logger, addr, filename string) (*RoundTripper, error) {
+	return NewRoundTripperWithParams(log, addr, filename, nil)
+}
+
+// NewRoundTripperWithParams creates a round tripper that passes the
+// additional params to the FastCGI backend. These params override the
+// params derived from the request.
+func NewRoundTripperWithParams(log logging.Logger, addr, filename string, params map[string]string) (*RoundTripper, error) {
 	connFactory := gofast.SimpleConnFactory("tcp", addr)
 
 	client, err := gofast.SimpleClientFactory(connFactory, 0)()
@@ -36,6 +43,10 @@ func NewRoundTripper(log logging.Logger, addr, filename string) (*RoundTripper,
 				// Gofast sets this param to `fastcgi` which is not what the backend will expect.
 				delete(req.Params, "REQUEST_SCHEME")
 
+				for k, v := range params {
+					req.Params[k] = v
+				}
+
 				return handler(client, req)
 			}
 		},
@@ -69,3 +69,43 @@ func TestFastCgi(t *testing.T) {
 		t.Error("wrong routing 1")
 	}
 }
+
+func TestFastCgiParams(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := fcgi.ProcessEnv(r)
+		w.Header().Set("X-Script-Filename", env["SCRIPT_FILENAME"])
+		w.Header().Set("X-App-Env", env["APP_ENV"])
+	}))
+
+	rt, err := NewRoundTripperWithParams(&logging.DefaultLog{}, l.Addr().String(), "index.php", map[string]string{
+		"SCRIPT_FILENAME": "/var/www/app/index.php",
+		"APP_ENV":         "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.ParseRequestURI("http://www.example.org/hello")
+	response, err := rt.RoundTrip(&http.Request{
+		URL:    u,
+		Method: "GET",
+		Proto:  "HTTP/1.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := response.Header.Get("X-Script-Filename"); f != "/var/www/app/index.php" {
+		t.Errorf("invalid script filename: %s", f)
+	}
+
+	if e := response.Header.Get("X-App-Env"); e != "test" {
+		t.Errorf("invalid param: %s", e)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const eventStreamMediaType = "text/event-stream"
+
+// flushWriter delays flushing the written data to the client, at most by
+// the configured interval, to reduce the number of small writes on the
+// connection. Since the delayed flush happens on a separate goroutine, the
+// writes and the flushes are synchronized.
+type flushWriter struct {
+	mx       sync.Mutex
+	w        flushedResponseWriter
+	interval time.Duration
+	timer    *time.Timer
+	pending  bool
+}
+
+// returns the interval of flushing the response body, based on the
+// configured value and the content type of the response. Server sent
+// events are always flushed immediately.
+func responseFlushInterval(interval time.Duration, rsp *http.Response) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rsp.Header.Get("Content-Type"))
+	if mediaType == eventStreamMediaType || strings.HasPrefix(mediaType, grpcContentType) {
+		return 0
+	}
+
+	return interval
+}
+
+func newFlushWriter(w flushedResponseWriter, interval time.Duration) *flushWriter {
+	return &flushWriter{w: w, interval: interval}
+}
+
+func (fw *flushWriter) Header() http.Header { return fw.w.Header() }
+
+func (fw *flushWriter) WriteHeader(code int) { fw.w.WriteHeader(code) }
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.mx.Lock()
+	defer fw.mx.Unlock()
+	return fw.w.Write(p)
+}
+
+// Flush flushes immediately when no interval was configured, otherwise it
+// schedules a flush, unless one is already pending.
+func (fw *flushWriter) Flush() {
+	fw.mx.Lock()
+	defer fw.mx.Unlock()
+
+	if fw.interval <= 0 {
+		fw.w.Flush()
+		return
+	}
+
+	if fw.pending {
+		return
+	}
+
+	fw.pending = true
+	if fw.timer == nil {
+		fw.timer = time.AfterFunc(fw.interval, fw.delayedFlush)
+	} else {
+		fw.timer.Reset(fw.interval)
+	}
+}
+
+func (fw *flushWriter) delayedFlush() {
+	fw.mx.Lock()
+	defer fw.mx.Unlock()
+
+	if !fw.pending {
+		return
+	}
+
+	fw.w.Flush()
+	fw.pending = false
+}
+
+// stop cancels the pending flush, and flushes the remaining data.
+func (fw *flushWriter) stop() {
+	fw.mx.Lock()
+	defer fw.mx.Unlock()
+
+	if fw.timer != nil {
+		fw.timer.Stop()
+	}
+
+	if fw.pending {
+		fw.w.Flush()
+		fw.pending = false
+	}
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingFlusher struct {
+	mx sync.Mutex
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *countingFlusher) Flush() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.flushes++
+}
+
+func (f *countingFlusher) count() int {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.flushes
+}
+
+func TestResponseFlushInterval(t *testing.T) {
+	for _, test := range []struct {
+		title       string
+		interval    time.Duration
+		contentType string
+		expected    time.Duration
+	}{{
+		title:       "not configured",
+		contentType: "text/plain",
+	}, {
+		title:       "configured",
+		interval:    time.Second,
+		contentType: "text/plain",
+		expected:    time.Second,
+	}, {
+		title:       "event stream",
+		interval:    time.Second,
+		contentType: "text/event-stream; charset=utf-8",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			rsp := &http.Response{Header: http.Header{"Content-Type": []string{test.contentType}}}
+			if d := responseFlushInterval(test.interval, rsp); d != test.expected {
+				t.Errorf("invalid flush interval, expected: %v, got: %v", test.expected, d)
+			}
+		})
+	}
+}
+
+func TestFlushWriter(t *testing.T) {
+	t.Run("immediate", func(t *testing.T) {
+		f := &countingFlusher{ResponseRecorder: httptest.NewRecorder()}
+		fw := newFlushWriter(f, 0)
+		for i := 0; i < 3; i++ {
+			fw.Write([]byte("foo"))
+			fw.Flush()
+		}
+
+		fw.stop()
+		if f.count() != 3 {
+			t.Errorf("invalid number of flushes, expected: 3, got: %d", f.count())
+		}
+	})
+
+	t.Run("delayed", func(t *testing.T) {
+		f := &countingFlusher{ResponseRecorder: httptest.NewRecorder()}
+		fw := newFlushWriter(f, 30*time.Millisecond)
+		for i := 0; i < 3; i++ {
+			fw.Write([]byte("foo"))
+			fw.Flush()
+		}
+
+		if f.count() != 0 {
+			t.Fatalf("unexpected flush, got: %d", f.count())
+		}
+
+		time.Sleep(90 * time.Millisecond)
+		if f.count() != 1 {
+			t.Errorf("invalid number of flushes, expected: 1, got: %d", f.count())
+		}
+
+		fw.stop()
+		if f.Body.String() != "foofoofoo" {
+			t.Errorf("invalid body: %s", f.Body.String())
+		}
+	})
+
+	t.Run("flush on stop", func(t *testing.T) {
+		f := &countingFlusher{ResponseRecorder: httptest.NewRecorder()}
+		fw := newFlushWriter(f, time.Hour)
+		fw.Write([]byte("foo"))
+		fw.Flush()
+		fw.stop()
+		if f.count() != 1 {
+			t.Errorf("invalid number of flushes, expected: 1, got: %d", f.count())
+		}
+	})
+}
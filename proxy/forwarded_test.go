@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	snet "github.com/zalando/skipper/net"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	var header http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> %q`, backend.URL), Params{
+		ForwardedHeaders: snet.ForwardedHeaders{Policy: snet.ForwardedHeadersAppend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	req, err := http.NewRequest("GET", ps.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if ff := header.Get("X-Forwarded-For"); ff != "127.0.0.1" {
+		t.Errorf("failed to replace the spoofed X-Forwarded-For header: %s", ff)
+	}
+
+	if proto := header.Get("X-Forwarded-Proto"); proto != "http" {
+		t.Errorf("invalid X-Forwarded-Proto header: %s", proto)
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	grpcContentType   = "application/grpc"
+	grpcStatusHeader  = "Grpc-Status"
+	grpcMessageHeader = "Grpc-Message"
+
+	// the status of the gRPC calls, with the route id and the gRPC
+	// status code
+	grpcStatusKey = "grpc.status.%s.%s"
+)
+
+// gRPC status codes used by the proxy, see:
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcCanceled         = 1
+	grpcUnknown          = 2
+	grpcDeadlineExceeded = 4
+	grpcPermissionDenied = 7
+	grpcResourceExhaust  = 8
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// isGRPCRequest returns true when the request has the gRPC content type,
+// e.g. application/grpc or application/grpc+proto.
+func isGRPCRequest(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return ct == grpcContentType ||
+		strings.HasPrefix(ct, grpcContentType+"+") ||
+		strings.HasPrefix(ct, grpcContentType+";")
+}
+
+// maps the HTTP status codes of the errors to gRPC status codes, based on:
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func grpcStatusFromHTTP(code int) int {
+	switch code {
+	case http.StatusBadRequest:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusTooManyRequests:
+		return grpcResourceExhaust
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcUnavailable
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	case 499:
+		return grpcCanceled
+	default:
+		return grpcUnknown
+	}
+}
+
+// returns the gRPC status of a backend response, either from the
+// trailers, or in case of trailers-only responses, from the header.
+func grpcStatus(rsp *http.Response) string {
+	if s := rsp.Trailer.Get(grpcStatusHeader); s != "" {
+		return s
+	}
+
+	if s := rsp.Header.Get(grpcStatusHeader); s != "" {
+		return s
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return strconv.Itoa(grpcStatusFromHTTP(rsp.StatusCode))
+	}
+
+	return strconv.Itoa(grpcUnknown)
+}
+
+// sends an error to a gRPC client as a trailers-only response, since the
+// gRPC clients don't understand the HTTP error responses
+func sendGRPCError(w http.ResponseWriter, code int) string {
+	status := strconv.Itoa(grpcStatusFromHTTP(code))
+	h := w.Header()
+	h.Set("Content-Type", grpcContentType)
+	h.Set(grpcStatusHeader, status)
+	h.Set(grpcMessageHeader, http.StatusText(code))
+	w.WriteHeader(http.StatusOK)
+	return status
+}
+
+func (p *Proxy) measureGRPCStatus(routeID, status string) {
+	p.metrics.IncCounter(fmt.Sprintf(grpcStatusKey, routeID, status))
+}
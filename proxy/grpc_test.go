@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestIsGRPCRequest(t *testing.T) {
+	for _, test := range []struct {
+		contentType string
+		expected    bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/grpc-web", false},
+		{"application/grpc", true},
+		{"application/grpc+proto", true},
+		{"application/grpc; charset=utf-8", true},
+	} {
+		r := &http.Request{Header: http.Header{"Content-Type": []string{test.contentType}}}
+		if isGRPCRequest(r) != test.expected {
+			t.Errorf("unexpected result for %q, expected: %v", test.contentType, test.expected)
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", grpcContentType)
+		w.Header().Set("Trailer", grpcStatusHeader)
+		w.Write([]byte("message"))
+		w.Header().Set(grpcStatusHeader, "5")
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	unavailable := httptest.NewServer(http.NotFoundHandler())
+	unavailable.Close()
+
+	routes := fmt.Sprintf(
+		`grpc: Path("/grpc") -> "%s"; unavailable: Path("/unavailable") -> "%s"`,
+		strings.Replace(backend.URL, "http://", "h2c://", 1),
+		strings.Replace(unavailable.URL, "http://", "h2c://", 1),
+	)
+
+	for _, test := range []struct {
+		title         string
+		grpc          bool
+		path          string
+		contentType   string
+		expectedCode  int
+		expectedGRPC  string
+		expectedCount string
+	}{{
+		title:         "status propagated",
+		grpc:          true,
+		path:          "/grpc",
+		contentType:   grpcContentType,
+		expectedCode:  http.StatusOK,
+		expectedGRPC:  "5",
+		expectedCount: "grpc.status.grpc.5",
+	}, {
+		title:         "proxy error as grpc status",
+		grpc:          true,
+		path:          "/unavailable",
+		contentType:   grpcContentType,
+		expectedCode:  http.StatusOK,
+		expectedGRPC:  "14",
+		expectedCount: "grpc.status.unavailable.14",
+	}, {
+		title:        "proxy error, not grpc request",
+		grpc:         true,
+		path:         "/unavailable",
+		contentType:  "application/json",
+		expectedCode: http.StatusBadGateway,
+	}, {
+		title:        "proxy error, grpc mode disabled",
+		path:         "/unavailable",
+		contentType:  grpcContentType,
+		expectedCode: http.StatusBadGateway,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(routes, Params{GRPC: test.grpc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			m := &metricstest.MockMetrics{}
+			tp.proxy.metrics = recordingMetrics{Metrics: metrics.Void, mock: m}
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			req, err := http.NewRequest("POST", ps.URL+test.path, strings.NewReader("request"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Content-Type", test.contentType)
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedCode {
+				t.Fatalf("invalid status code, expected: %d, got: %d", test.expectedCode, rsp.StatusCode)
+			}
+
+			if test.expectedGRPC == "" {
+				return
+			}
+
+			status := rsp.Header.Get(grpcStatusHeader)
+			if status == "" {
+				// reading the body to receive the trailers
+				if _, err := io.Copy(ioutil.Discard, rsp.Body); err != nil {
+					t.Fatal(err)
+				}
+
+				status = rsp.Trailer.Get(grpcStatusHeader)
+			}
+
+			if status != test.expectedGRPC {
+				t.Errorf("invalid grpc status, expected: %s, got: %s", test.expectedGRPC, status)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				if c[test.expectedCount] != 1 {
+					t.Errorf("failed to measure the grpc status: %v", c)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderFilter restricts the headers forwarded by the proxy. When Allow
+// is not empty, only the listed headers are forwarded. The headers listed
+// in Deny are never forwarded. The header names are case insensitive.
+type HeaderFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+type headerFilter struct {
+	allow, deny map[string]bool
+}
+
+func headerSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+
+	s := make(map[string]bool)
+	for _, n := range names {
+		s[http.CanonicalHeaderKey(strings.TrimSpace(n))] = true
+	}
+
+	return s
+}
+
+// returns nil when there is nothing to filter
+func newHeaderFilter(f HeaderFilter) *headerFilter {
+	if len(f.Allow) == 0 && len(f.Deny) == 0 {
+		return nil
+	}
+
+	return &headerFilter{
+		allow: headerSet(f.Allow),
+		deny:  headerSet(f.Deny),
+	}
+}
+
+func (f *headerFilter) apply(h http.Header) {
+	if f == nil {
+		return
+	}
+
+	for k := range h {
+		ck := http.CanonicalHeaderKey(k)
+		if f.deny[ck] || f.allow != nil && !f.allow[ck] {
+			delete(h, k)
+		}
+	}
+}
+
+// removes the hop-by-hop headers, including the ones listed in the
+// Connection header, as defined by RFC 7230, section 6.1. When keepUpgrade
+// is set, the Upgrade header is preserved, and the Connection header is
+// reduced to the upgrade token.
+func removeHopHeaders(h http.Header, keepUpgrade bool) {
+	upgrade := h["Upgrade"]
+	for _, v := range h["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+
+	for k := range hopHeaders {
+		h.Del(k)
+	}
+
+	if keepUpgrade && len(upgrade) > 0 {
+		h["Connection"] = []string{"Upgrade"}
+		h["Upgrade"] = upgrade
+	}
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoveHopHeaders(t *testing.T) {
+	h := http.Header{
+		"Connection":        []string{"X-Hop, keep-alive", "Upgrade"},
+		"Keep-Alive":        []string{"timeout=5"},
+		"X-Hop":             []string{"foo"},
+		"Upgrade":           []string{"websocket"},
+		"Transfer-Encoding": []string{"chunked"},
+		"X-End-To-End":      []string{"bar"},
+	}
+
+	removeHopHeaders(h, false)
+	if len(h) != 1 || h.Get("X-End-To-End") != "bar" {
+		t.Errorf("failed to remove the hop headers: %v", h)
+	}
+
+	h = http.Header{
+		"Connection": []string{"X-Hop, Upgrade"},
+		"X-Hop":      []string{"foo"},
+		"Upgrade":    []string{"websocket"},
+	}
+
+	removeHopHeaders(h, true)
+	if len(h) != 2 || h.Get("Connection") != "Upgrade" || h.Get("Upgrade") != "websocket" {
+		t.Errorf("failed to preserve the upgrade headers: %v", h)
+	}
+}
+
+func TestHeaderFilter(t *testing.T) {
+	var header http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Header().Set("Connection", "X-Backend-Hop")
+		w.Header().Set("X-Backend-Hop", "foo")
+		w.Header().Set("X-Powered-By", "bar")
+		w.Header().Set("X-Backend", "baz")
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> %q`, backend.URL), Params{
+		Flags:           HopHeadersRemoval,
+		RequestHeaders:  HeaderFilter{Allow: []string{"x-allowed", "X-Denied", "User-Agent"}, Deny: []string{"x-denied"}},
+		ResponseHeaders: HeaderFilter{Deny: []string{"X-Powered-By"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	req, err := http.NewRequest("GET", ps.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("X-Allowed", "foo")
+	req.Header.Set("X-Denied", "bar")
+	req.Header.Set("X-Other", "baz")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if header.Get("X-Allowed") != "foo" {
+		t.Error("failed to forward the allowed header")
+	}
+
+	if header.Get("X-Denied") != "" || header.Get("X-Other") != "" {
+		t.Errorf("failed to filter the request headers: %v", header)
+	}
+
+	if rsp.Header.Get("X-Backend-Hop") != "" {
+		t.Error("failed to remove the hop header of the response")
+	}
+
+	if rsp.Header.Get("X-Powered-By") != "" {
+		t.Error("failed to remove the denied response header")
+	}
+
+	if rsp.Header.Get("X-Backend") != "baz" {
+		t.Error("failed to return the response header")
+	}
+}
@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// backend scheme for HTTP/2 over TLS
+	h2Scheme = "h2"
+
+	// backend scheme for HTTP/2 over cleartext TCP, with prior
+	// knowledge, e.g. for gRPC backends inside the cluster
+	h2cScheme = "h2c"
+)
+
+var (
+	errHTTP2TransportOptions = errors.New("the PROXY protocol and, with h2c, the egress proxy are not supported for HTTP/2 backends")
+	errH2CConnectionLimit    = errors.New("h2c connection limit reached")
+	errResponseHeaderTimeout = errors.New("timeout awaiting response headers")
+)
+
+// http2Transports contains the round trippers for the backends that are
+// called with HTTP/2. The protocol is selected by the scheme of the
+// backend address, e.g. "h2c://grpc.default.svc.cluster.local:8080".
+//
+// The round trippers are derived from the transport of the route, so
+// they use the same dialer, timeouts and connection limits. The h2
+// transport is a copy of the route transport, negotiating only HTTP/2
+// with ALPN. The h2c transport dials the connections with the dialer of
+// the route transport, in the context of the request that opens them.
+type http2Transports struct {
+	h2  *http.Transport
+	h2c *h2cTransport
+}
+
+// h2cConnPool maintains the h2c connections. The connections are
+// multiplexed, and a new connection is opened only when the existing
+// ones to the same backend cannot take more requests.
+type h2cConnPool struct {
+	transport *http2.Transport
+	dial      dialFunc
+	maxConns  int
+	mx        sync.Mutex
+	conns     map[string][]*http2.ClientConn
+	dialing   map[string]int
+}
+
+type h2cTransport struct {
+	transport             *http2.Transport
+	pool                  *h2cConnPool
+	responseHeaderTimeout time.Duration
+}
+
+func newHTTP2Transports(tr *http.Transport) *http2Transports {
+	h2 := tr.Clone()
+	h2.ForceAttemptHTTP2 = true
+	if h2.TLSClientConfig == nil {
+		h2.TLSClientConfig = &tls.Config{}
+	}
+
+	h2.TLSClientConfig.NextProtos = []string{"h2"}
+
+	pool := &h2cConnPool{
+		dial:     tr.DialContext,
+		maxConns: tr.MaxConnsPerHost,
+		conns:    make(map[string][]*http2.ClientConn),
+		dialing:  make(map[string]int),
+	}
+
+	pool.transport = &http2.Transport{AllowHTTP: true, ConnPool: pool}
+	return &http2Transports{
+		h2: h2,
+		h2c: &h2cTransport{
+			transport:             pool.transport,
+			pool:                  pool,
+			responseHeaderTimeout: tr.ResponseHeaderTimeout,
+		},
+	}
+}
+
+func (p *h2cConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	p.mx.Lock()
+	for _, cc := range p.conns[addr] {
+		if cc.CanTakeNewRequest() {
+			p.mx.Unlock()
+			return cc, nil
+		}
+	}
+
+	if p.maxConns > 0 && len(p.conns[addr])+p.dialing[addr] >= p.maxConns {
+		p.mx.Unlock()
+		return nil, errH2CConnectionLimit
+	}
+
+	p.dialing[addr]++
+	p.mx.Unlock()
+
+	cc, err := p.newConn(req, addr)
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.dialing[addr]--
+	if err != nil {
+		return nil, err
+	}
+
+	p.conns[addr] = append(p.conns[addr], cc)
+	return cc, nil
+}
+
+func (p *h2cConnPool) newConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	conn, err := p.dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return cc, nil
+}
+
+func (p *h2cConnPool) MarkDead(cc *http2.ClientConn) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for addr, conns := range p.conns {
+		for i, c := range conns {
+			if c == cc {
+				p.conns[addr] = append(conns[:i:i], conns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// the connections don't report whether they are idle, so all of them are
+// removed from the pool, and closed after their active requests finished
+func (p *h2cConnPool) closeIdleConnections() {
+	p.mx.Lock()
+	conns := p.conns
+	p.conns = make(map[string][]*http2.ClientConn)
+	p.mx.Unlock()
+
+	for _, cc := range conns {
+		for _, c := range cc {
+			go c.Shutdown(stdlibcontext.Background())
+		}
+	}
+}
+
+// applies the response header timeout of the route transport, which the
+// HTTP/2 transport doesn't support
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.responseHeaderTimeout <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+
+	ctx, cancel := stdlibcontext.WithCancel(req.Context())
+	timer := time.AfterFunc(t.responseHeaderTimeout, cancel)
+	rsp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			rsp.Body.Close()
+		}
+
+		cancel()
+		return nil, errResponseHeaderTimeout
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	rsp.Body = &closeHookBody{ReadCloser: rsp.Body, onClose: cancel}
+	return rsp, nil
+}
+
+func isHTTP2Scheme(scheme string) bool {
+	return scheme == h2Scheme || scheme == h2cScheme
+}
+
+// returns the transport for the HTTP/2 backends, and sets the scheme
+// of the outgoing request to the one expected by the transport
+func (t *http2Transports) roundTripper(req *http.Request) http.RoundTripper {
+	var rt http.RoundTripper
+	switch req.URL.Scheme {
+	case h2Scheme:
+		req.URL.Scheme = "https"
+		rt = t.h2
+	case h2cScheme:
+		req.URL.Scheme = "http"
+		rt = t.h2c
+	default:
+		return nil
+	}
+
+	// HTTP/2 doesn't allow the connection specific headers, regardless
+	// of the hop headers removal setting, but the gRPC backends require
+	// TE: trailers
+	te := req.Header.Get("Te")
+	for h := range hopHeaders {
+		req.Header.Del(h)
+	}
+
+	if strings.Contains(strings.ToLower(te), "trailers") {
+		req.Header.Set("Te", "trailers")
+	}
+
+	return rt
+}
+
+func (t *http2Transports) closeIdleConnections() {
+	t.h2.CloseIdleConnections()
+	t.h2c.pool.closeIdleConnections()
+}
+
+// prepares the response writer for the trailers of the backend response,
+// when there are any. The trailers can be sent only with a chunked
+// response body, so the content length is not preserved in this case.
+func announceTrailers(w http.ResponseWriter, rsp *http.Response) {
+	if len(rsp.Trailer) == 0 {
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	for k := range rsp.Trailer {
+		w.Header().Add("Trailer", k)
+	}
+}
+
+// copies the trailers of the backend response to the response writer,
+// after the response body was sent
+func copyTrailers(w http.ResponseWriter, rsp *http.Response) {
+	for k, vv := range rsp.Trailer {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+}
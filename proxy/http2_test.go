@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func http2Backend(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("invalid protocol: %s", r.Proto)
+		}
+
+		if r.Header.Get("Te") != "trailers" {
+			t.Errorf("TE: trailers was not forwarded, got: %q", r.Header.Get("Te"))
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("Hello, world!"))
+		w.Header().Set("Grpc-Status", "0")
+	})
+}
+
+func TestHTTP2Backends(t *testing.T) {
+	h2cBackend := httptest.NewServer(h2c.NewHandler(http2Backend(t), &http2.Server{}))
+	defer h2cBackend.Close()
+
+	h2Backend := httptest.NewUnstartedServer(http2Backend(t))
+	h2Backend.EnableHTTP2 = true
+	h2Backend.StartTLS()
+	defer h2Backend.Close()
+
+	for _, test := range []struct {
+		title   string
+		backend string
+	}{{
+		title:   "h2c",
+		backend: strings.Replace(h2cBackend.URL, "http://", "h2c://", 1),
+	}, {
+		title:   "h2",
+		backend: strings.Replace(h2Backend.URL, "https://", "h2://", 1),
+	}, {
+		title:   "h2c, load balanced",
+		backend: fmt.Sprintf(`<roundRobin, "%s">`, strings.Replace(h2cBackend.URL, "http://", "h2c://", 1)),
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			backend := test.backend
+			if !strings.HasPrefix(backend, "<") {
+				backend = fmt.Sprintf("%q", backend)
+			}
+
+			tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> %s`, backend), Params{Flags: Insecure})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			req, err := http.NewRequest("POST", ps.URL, strings.NewReader("foo"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Te", "trailers")
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rsp.StatusCode != http.StatusOK || string(b) != "Hello, world!" {
+				t.Errorf("invalid response: %d, %s", rsp.StatusCode, string(b))
+			}
+
+			if rsp.Trailer.Get("Grpc-Status") != "0" {
+				t.Errorf("trailer was not propagated, got: %v", rsp.Trailer)
+			}
+		})
+	}
+}
+
+func TestHTTP2RouteTransportOptions(t *testing.T) {
+	slowHeader := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(120 * time.Millisecond)
+		w.Write([]byte("Hello, world!"))
+	})
+
+	h2cBackend := httptest.NewServer(h2c.NewHandler(slowHeader, &http2.Server{}))
+	defer h2cBackend.Close()
+
+	h2Backend := httptest.NewUnstartedServer(slowHeader)
+	h2Backend.EnableHTTP2 = true
+	h2Backend.StartTLS()
+	defer h2Backend.Close()
+
+	h2cURL := strings.Replace(h2cBackend.URL, "http://", "h2c://", 1)
+	h2URL := strings.Replace(h2Backend.URL, "https://", "h2://", 1)
+
+	for _, test := range []struct {
+		title          string
+		filters        string
+		backend        string
+		expectedStatus int
+	}{{
+		title:          "h2c, response header timeout",
+		filters:        `backendResponseHeaderTimeout("30ms")`,
+		backend:        h2cURL,
+		expectedStatus: http.StatusGatewayTimeout,
+	}, {
+		title:          "h2c, response header timeout not reached",
+		filters:        `backendResponseHeaderTimeout("1s")`,
+		backend:        h2cURL,
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "h2, response header timeout",
+		filters:        `backendResponseHeaderTimeout("30ms")`,
+		backend:        h2URL,
+		expectedStatus: http.StatusGatewayTimeout,
+	}, {
+		title:          "h2c, PROXY protocol",
+		filters:        `backendProxyProtocol(1)`,
+		backend:        h2cURL,
+		expectedStatus: http.StatusInternalServerError,
+	}, {
+		title:          "h2, PROXY protocol",
+		filters:        `backendProxyProtocol(2)`,
+		backend:        h2URL,
+		expectedStatus: http.StatusInternalServerError,
+	}, {
+		title:          "h2c, egress proxy",
+		filters:        `egressProxy("http://127.0.0.1:9")`,
+		backend:        h2cURL,
+		expectedStatus: http.StatusInternalServerError,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(
+				fmt.Sprintf(`* -> %s -> %q`, test.filters, test.backend),
+				Params{Flags: Insecure},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			rsp, err := http.Get(ps.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedStatus {
+				t.Fatalf("invalid status code, expected: %d, got: %d", test.expectedStatus, rsp.StatusCode)
+			}
+
+			b, _ := ioutil.ReadAll(rsp.Body)
+			if (test.expectedStatus == http.StatusOK) != (string(b) == "Hello, world!") {
+				t.Errorf("invalid response body: %q", string(b))
+			}
+		})
+	}
+}
+
+type testContextKey struct{}
+
+func TestH2CDialsWithTheRouteTransport(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), &http2.Server{}))
+	defer backend.Close()
+
+	var dials []stdlibcontext.Context
+	base := &http.Transport{}
+	dial := func(d net.Dialer) dialFunc {
+		return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+			dials = append(dials, ctx)
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+
+	base.DialContext = dial(net.Dialer{})
+	rts := newRouteTransports(base, net.Dialer{}, dial)
+	bag := map[string]interface{}{filters.BackendDialTimeoutKey: time.Second}
+
+	ctx := stdlibcontext.WithValue(stdlibcontext.Background(), testContextKey{}, "foo")
+	req, err := http.NewRequest("GET", strings.Replace(backend.URL, "http://", "h2c://", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = req.WithContext(ctx)
+	rt, err := rts.getHTTP2(req, bag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		rsp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	if len(dials) != 1 {
+		t.Fatalf("failed to reuse the connection, dials: %d", len(dials))
+	}
+
+	if dials[0].Value(testContextKey{}) != "foo" {
+		t.Error("failed to dial with the request context")
+	}
+
+	if _, ok := rts.http2[transportOptionsFromStateBag(bag)]; !ok {
+		t.Error("failed to derive the HTTP/2 transports from the route transport")
+	}
+
+	req.URL.Scheme = h2cScheme
+	if rt2, _ := rts.getHTTP2(req, bag); rt2 != rt {
+		t.Error("failed to share the transport with the same options")
+	}
+}
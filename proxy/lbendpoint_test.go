@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/zalando/skipper/routing"
+)
+
+// creates a proxy with a load balanced route to a live and a closed
+// backend, sends requests through it, and returns the route and the
+// host of the closed backend
+func lbEndpointStateTest(t *testing.T, algorithm string) (*routing.Route, string) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	tp, err := newTestProxyWithParams(
+		fmt.Sprintf(`* -> <%s, "%s", "%s">`, algorithm, backend.URL, closed.URL),
+		Params{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 12; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("invalid status code: %d", rsp.StatusCode)
+		}
+	}
+
+	req, err := http.NewRequest("GET", ps.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route, _ := tp.routing.Get().Do(req)
+	if route == nil {
+		t.Fatal("route not found")
+	}
+
+	cu, err := url.Parse(closed.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return route, cu.Host
+}
+
+func TestLBEndpointInFlight(t *testing.T) {
+	route, _ := lbEndpointStateTest(t, "leastConnections")
+	for _, e := range route.LBEndpoints {
+		if e.State.InFlight() != 0 {
+			t.Errorf("invalid number of in-flight requests to %s: %d", e.Host, e.State.InFlight())
+		}
+	}
+}
+
+func TestLBEndpointFailures(t *testing.T) {
+	route, closedHost := lbEndpointStateTest(t, "roundRobin")
+	if s := lbEndpointState(route, closedHost).Stats(); s.ConsecutiveFailures == 0 && s.Ejections == 0 {
+		t.Error("failed to record the failures of the unavailable endpoint")
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	// the number of the open backend connections, in total and by
+	// backend host
+	poolOpenKey     = "outgoing.connections.open"
+	poolOpenHostKey = "outgoing.connections.open.%s"
+
+	// the number of the new backend connections by backend host
+	poolCreatedKey = "outgoing.connections.created.%s"
+
+	// the number of the backend requests sent over reused idle
+	// connections by backend host
+	poolReusedKey = "outgoing.connections.reused.%s"
+)
+
+type dialFunc func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error)
+
+// connPool tracks the utilization of the outgoing connection pool, by
+// counting the open connections to the backend hosts.
+type connPool struct {
+	mx      sync.Mutex
+	metrics metrics.Metrics
+	total   int64
+	open    map[string]int64
+}
+
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func newConnPool(m metrics.Metrics) *connPool {
+	return &connPool{
+		metrics: m,
+		open:    make(map[string]int64),
+	}
+}
+
+func poolHostKey(format, addr string) string {
+	addr = strings.Replace(addr, ".", "_", -1)
+	addr = strings.Replace(addr, ":", "__", -1)
+	return fmt.Sprintf(format, addr)
+}
+
+func (p *connPool) update(addr string, delta int64) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.total += delta
+	p.open[addr] += delta
+	p.metrics.UpdateGauge(poolOpenKey, float64(p.total))
+	p.metrics.UpdateGauge(poolHostKey(poolOpenHostKey, addr), float64(p.open[addr]))
+	if p.open[addr] == 0 {
+		delete(p.open, addr)
+	}
+}
+
+// wraps the dial function, so that the connections are counted. When the
+// pool metrics are disabled, it returns the dial function unchanged.
+func (p *connPool) dialContext(dial dialFunc) dialFunc {
+	if p == nil {
+		return dial
+	}
+
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		p.metrics.IncCounter(poolHostKey(poolCreatedKey, addr))
+		p.update(addr, 1)
+		return &trackedConn{
+			Conn:    conn,
+			onClose: func() { p.update(addr, -1) },
+		}, nil
+	}
+}
+
+// adds a trace to the backend request, that counts the reused idle
+// connections
+func (p *connPool) traceRequest(req *http.Request) *http.Request {
+	if p == nil {
+		return req
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				p.metrics.IncCounter(poolHostKey(poolReusedKey, req.URL.Host))
+			}
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func TestConnectionPoolMetrics(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> %q`, backend.URL), Params{
+		ConnectionPoolMetrics: true,
+		CloseIdleConnsPeriod:  -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{}
+	tp.proxy.connPool.metrics = recordingMetrics{Metrics: metrics.Void, mock: m}
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 3; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+	}
+
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := poolHostKey(poolCreatedKey, u.Host)
+	reused := poolHostKey(poolReusedKey, u.Host)
+	open := poolHostKey(poolOpenHostKey, u.Host)
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[created] != 1 {
+			t.Errorf("invalid number of created connections, expected: 1, got: %d", c[created])
+		}
+
+		if c[reused] != 2 {
+			t.Errorf("invalid number of reused connections, expected: 2, got: %d", c[reused])
+		}
+	})
+
+	if v, _ := m.Gauge(open); v != 1 {
+		t.Errorf("invalid number of open connections, expected: 1, got: %v", v)
+	}
+
+	tp.proxy.routeTransports.closeIdleConnections()
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := m.Gauge(poolOpenKey); v != 0 {
+		t.Errorf("invalid number of open connections after closing, expected: 0, got: %v", v)
+	}
+}
+
+func TestMaxConnsPerHost(t *testing.T) {
+	p := WithParams(Params{MaxConnsPerHost: 3, IdleConnTimeout: time.Minute})
+	defer p.Close()
+
+	if p.roundTripper.MaxConnsPerHost != 3 || p.roundTripper.IdleConnTimeout != time.Minute {
+		t.Errorf(
+			"invalid transport settings, max conns per host: %d, idle conn timeout: %v",
+			p.roundTripper.MaxConnsPerHost,
+			p.roundTripper.IdleConnTimeout,
+		)
+	}
+}
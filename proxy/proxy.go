@@ -30,6 +30,7 @@ import (
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
+	snet "github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/proxy/fastcgi"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/rfc"
@@ -155,6 +156,23 @@ type Params struct {
 	// and the response messages during web socket upgrades.
 	ExperimentalUpgradeAudit bool
 
+	// RetryPolicy configures retrying the failed backend requests. When
+	// not set, only the requests to load balanced backends are retried
+	// once, when dialing the selected endpoint failed.
+	RetryPolicy *RetryPolicy
+
+	// GRPC enables the gRPC mode of the proxy. In this mode, the errors
+	// of the gRPC requests are sent as gRPC status codes instead of HTTP
+	// error responses, the responses are flushed immediately, and the
+	// gRPC status of the calls is measured and logged in the access log.
+	GRPC bool
+
+	// UpgradeIdleTimeout closes the upgraded connections, e.g. web
+	// sockets, when no data was sent in either direction for the
+	// configured duration. When zero, the idle connections are not
+	// closed.
+	UpgradeIdleTimeout time.Duration
+
 	// When set, no access log is printed.
 	AccessLogDisabled bool
 
@@ -181,9 +199,42 @@ type Params struct {
 	// MaxIdleConns limits the number of idle connections to all backends, 0 means no limit
 	MaxIdleConns int
 
+	// MaxConnsPerHost limits the total number of connections to a
+	// backend host, including the connections in dialing, active, and
+	// idle states. When the limit is reached, the backend requests wait
+	// for a free connection. 0 means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout sets how long an idle backend connection is kept
+	// open. When zero, CloseIdleConnsPeriod is used.
+	IdleConnTimeout time.Duration
+
+	// ConnectionPoolMetrics enables the metrics of the open, created and
+	// reused backend connections by backend host.
+	ConnectionPoolMetrics bool
+
 	// DisableHTTPKeepalives forces backend to always create a new connection
 	DisableHTTPKeepalives bool
 
+	// MaxRequestBodySize limits the size of the request bodies forwarded
+	// to the backends, in bytes. The requests with larger bodies are
+	// rejected with 413 Request Entity Too Large. Routes can override it
+	// with the maxRequestBodySize filter. 0 means no limit.
+	MaxRequestBodySize int64
+
+	// ForwardedHeaders defines how the X-Forwarded-* and Forwarded
+	// headers of the incoming requests are handled. By default, they are
+	// left untouched.
+	ForwardedHeaders snet.ForwardedHeaders
+
+	// RequestHeaders restricts the headers of the incoming requests,
+	// that are forwarded to the backends.
+	RequestHeaders HeaderFilter
+
+	// ResponseHeaders restricts the headers of the responses, that are
+	// returned to the clients.
+	ResponseHeaders HeaderFilter
+
 	// CircuitBreakers provides a registry that skipper can use to
 	// find the matching circuit breaker for backend requests. If not
 	// set, no circuit breakers are used.
@@ -205,6 +256,26 @@ type Params struct {
 	// The Flush interval for copying upgraded connections
 	FlushInterval time.Duration
 
+	// ResponseFlushInterval defines how often the response body is
+	// flushed to the client while streaming it from the backend. When
+	// zero, the response body is flushed after every read from the
+	// backend. The responses with the text/event-stream content type
+	// are always flushed immediately.
+	ResponseFlushInterval time.Duration
+
+	// ResponseBufferSize is the size of the backend response bodies, in
+	// bytes, that are read into memory before sending them to the
+	// client. When the complete body fits, the backend connection is
+	// released regardless of how fast the client receives the response.
+	// 0 means no buffering.
+	ResponseBufferSize int
+
+	// ResponseWriteTimeout limits the duration of the individual writes
+	// of the response to the client. When exceeded, the response is
+	// aborted and the backend connection released, so that slow clients
+	// cannot hold it indefinitely. 0 means no limit.
+	ResponseWriteTimeout time.Duration
+
 	// Timeout sets the TCP client connection timeout for proxy http connections to the backend
 	Timeout time.Duration
 
@@ -226,6 +297,13 @@ type Params struct {
 	// Client TLS to connect to Backends
 	ClientTLS *tls.Config
 
+	// EgressProxy, when set, is used to connect to the backends, e.g.
+	// when they are reachable only through a corporate proxy. The
+	// supported schemes are http, https and socks5. The https backends
+	// are reached with HTTP CONNECT through the http and https egress
+	// proxies. Routes can override it with the egressProxy filter.
+	EgressProxy *url.URL
+
 	// OpenTracing contains parameters related to OpenTracing instrumentation. For default values
 	// check OpenTracingParams
 	OpenTracing *OpenTracingParams
@@ -302,6 +380,9 @@ type PriorityRoute interface {
 // Proxy instances implement Skipper proxying functionality. For
 // initializing, see the WithParams the constructor and Params.
 type Proxy struct {
+	// accessed atomically, needs to be 64-bit aligned
+	activeUpgrades int64
+
 	experimentalUpgrade      bool
 	experimentalUpgradeAudit bool
 	accessLogDisabled        bool
@@ -309,11 +390,16 @@ type Proxy struct {
 	defaultHTTPStatus        int
 	routing                  *routing.Routing
 	roundTripper             *http.Transport
+	routeTransports          *routeTransports
+	connPool                 *connPool
 	priorityRoutes           []PriorityRoute
 	flags                    Flags
 	metrics                  metrics.Metrics
 	quit                     chan struct{}
 	flushInterval            time.Duration
+	responseFlushInterval    time.Duration
+	responseBufferSize       int
+	responseWriteTimeout     time.Duration
 	breakers                 *circuit.Registry
 	limiters                 *ratelimit.Registry
 	log                      logging.Logger
@@ -321,6 +407,13 @@ type Proxy struct {
 	lb                       *loadbalancer.LB
 	upgradeAuditLogOut       io.Writer
 	upgradeAuditLogErr       io.Writer
+	upgradeIdleTimeout       time.Duration
+	grpc                     bool
+	maxRequestBodySize       int64
+	forwardedHeaders         *snet.ForwardedHeaders
+	requestHeaders           *headerFilter
+	responseHeaders          *headerFilter
+	retrier                  *retrier
 	auditLogHook             chan struct{}
 }
 
@@ -374,28 +467,12 @@ func copyHeader(to, from http.Header) {
 	}
 }
 
-func copyHeaderExcluding(to, from http.Header, excludeHeaders map[string]bool) {
-	for k, v := range from {
-		// The http package converts header names to their canonical version.
-		// Meaning that the lookup below will be done using the canonical version of the header.
-		if _, ok := excludeHeaders[k]; !ok {
-			to[http.CanonicalHeaderKey(k)] = v
-		}
-	}
-}
-
 func cloneHeader(h http.Header) http.Header {
 	hh := make(http.Header)
 	copyHeader(hh, h)
 	return hh
 }
 
-func cloneHeaderExcluding(h http.Header, excludeList map[string]bool) http.Header {
-	hh := make(http.Header)
-	copyHeaderExcluding(hh, h, excludeList)
-	return hh
-}
-
 // copies a stream with flushing on every successful read operation
 // (similar to io.Copy but with flushing)
 func copyStream(to flushedResponseWriter, from io.Reader, tracing *proxyTracing, span ot.Span) error {
@@ -470,7 +547,7 @@ func setRequestURLForLoadBalancedBackend(u *url.URL, rt *routing.Route, lbctx *r
 
 // creates an outgoing http request to be forwarded to the route endpoint
 // based on the augmented incoming request
-func mapRequest(r *http.Request, rt *routing.Route, host string, removeHopHeaders bool, stateBag map[string]interface{}) (*http.Request, error) {
+func mapRequest(r *http.Request, rt *routing.Route, host string, hopHeadersRemoval bool, stateBag map[string]interface{}) (*http.Request, error) {
 	u := r.URL
 	switch rt.BackendType {
 	case eskip.DynamicBackend:
@@ -488,7 +565,7 @@ func mapRequest(r *http.Request, rt *routing.Route, host string, removeHopHeader
 		body = nil
 	}
 
-	rr, err := http.NewRequest(r.Method, u.String(), body)
+	rr, err := newOutgoingRequest(r.Method, u, body)
 	if err != nil {
 		return nil, err
 	}
@@ -496,10 +573,10 @@ func mapRequest(r *http.Request, rt *routing.Route, host string, removeHopHeader
 	rr = rr.WithContext(r.Context())
 
 	rr.ContentLength = r.ContentLength
-	if removeHopHeaders {
-		rr.Header = cloneHeaderExcluding(r.Header, hopHeaders)
-	} else {
-		rr.Header = cloneHeader(r.Header)
+	rr.Trailer = r.Trailer
+	rr.Header = cloneHeader(r.Header)
+	if hopHeadersRemoval {
+		removeHopHeaders(rr.Header, isUpgradeRequest(r))
 	}
 	rr.Host = host
 
@@ -604,26 +681,62 @@ func WithParams(p Params) *Proxy {
 		p.ExpectContinueTimeout = DefaultExpectContinueTimeout
 	}
 
+	if p.IdleConnTimeout == 0 {
+		p.IdleConnTimeout = p.CloseIdleConnsPeriod
+	}
+
+	m := metrics.Default
+	if p.Flags.Debug() {
+		m = metrics.Void
+	}
+
+	var pool *connPool
+	if p.ConnectionPoolMetrics {
+		pool = newConnPool(m)
+	}
+
+	dial := func(d net.Dialer) dialFunc {
+		return pool.dialContext(newSkipperDialer(d).DialContext)
+	}
+
+	dialer := net.Dialer{
+		Timeout:   p.Timeout,
+		KeepAlive: p.KeepAlive,
+		DualStack: p.DualStack,
+	}
+
 	tr := &http.Transport{
-		DialContext: newSkipperDialer(net.Dialer{
-			Timeout:   p.Timeout,
-			KeepAlive: p.KeepAlive,
-			DualStack: p.DualStack,
-		}).DialContext,
+		DialContext:           dial(dialer),
 		TLSHandshakeTimeout:   p.TLSHandshakeTimeout,
 		ResponseHeaderTimeout: p.ResponseHeaderTimeout,
 		ExpectContinueTimeout: p.ExpectContinueTimeout,
 		MaxIdleConns:          p.MaxIdleConns,
 		MaxIdleConnsPerHost:   p.IdleConnectionsPerHost,
-		IdleConnTimeout:       p.CloseIdleConnsPeriod,
+		MaxConnsPerHost:       p.MaxConnsPerHost,
+		IdleConnTimeout:       p.IdleConnTimeout,
 		DisableKeepAlives:     p.DisableHTTPKeepalives,
-		Proxy:                 proxyFromHeader,
+		Proxy:                 egressProxyFunc(p.EgressProxy),
 	}
 
 	if p.ClientTLS != nil {
 		tr.TLSClientConfig = p.ClientTLS
 	}
 
+	if p.Flags.Insecure() {
+		if tr.TLSClientConfig == nil {
+			/* #nosec */
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		} else {
+			/* #nosec */
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		}
+	}
+
+	ut := newUnixTransport(tr)
+	tr.RegisterProtocol(unixScheme, ut)
+
+	rts := newRouteTransports(tr, dialer, dial)
+
 	quit := make(chan struct{})
 	// We need this to reliably fade on DNS change, which is right
 	// now not fixed with IdleConnTimeout in the http.Transport.
@@ -633,7 +746,8 @@ func WithParams(p Params) *Proxy {
 			for {
 				select {
 				case <-time.After(p.CloseIdleConnsPeriod):
-					tr.CloseIdleConnections()
+					rts.closeIdleConnections()
+					ut.closeIdleConnections()
 				case <-quit:
 					return
 				}
@@ -641,21 +755,6 @@ func WithParams(p Params) *Proxy {
 		}()
 	}
 
-	if p.Flags.Insecure() {
-		if tr.TLSClientConfig == nil {
-			/* #nosec */
-			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		} else {
-			/* #nosec */
-			tr.TLSClientConfig.InsecureSkipVerify = true
-		}
-	}
-
-	m := metrics.Default
-	if p.Flags.Debug() {
-		m = metrics.Void
-	}
-
 	if p.MaxLoopbacks == 0 {
 		p.MaxLoopbacks = DefaultMaxLoopbacks
 	} else if p.MaxLoopbacks < 0 {
@@ -673,11 +772,16 @@ func WithParams(p Params) *Proxy {
 	return &Proxy{
 		routing:                  p.Routing,
 		roundTripper:             tr,
+		routeTransports:          rts,
+		connPool:                 pool,
 		priorityRoutes:           p.PriorityRoutes,
 		flags:                    p.Flags,
 		metrics:                  m,
 		quit:                     quit,
 		flushInterval:            p.FlushInterval,
+		responseFlushInterval:    p.ResponseFlushInterval,
+		responseBufferSize:       p.ResponseBufferSize,
+		responseWriteTimeout:     p.ResponseWriteTimeout,
 		experimentalUpgrade:      p.ExperimentalUpgrade,
 		experimentalUpgradeAudit: p.ExperimentalUpgradeAudit,
 		maxLoops:                 p.MaxLoopbacks,
@@ -690,6 +794,13 @@ func WithParams(p Params) *Proxy {
 		accessLogDisabled:        p.AccessLogDisabled,
 		upgradeAuditLogOut:       os.Stdout,
 		upgradeAuditLogErr:       os.Stderr,
+		upgradeIdleTimeout:       p.UpgradeIdleTimeout,
+		grpc:                     p.GRPC,
+		retrier:                  newRetrier(p.RetryPolicy, m),
+		maxRequestBodySize:       p.MaxRequestBodySize,
+		forwardedHeaders:         &p.ForwardedHeaders,
+		requestHeaders:           newHeaderFilter(p.RequestHeaders),
+		responseHeaders:          newHeaderFilter(p.ResponseHeaders),
 	}
 }
 
@@ -816,7 +927,13 @@ func (p *Proxy) lookupRoute(ctx *context) (rt *routing.Route, params map[string]
 // send a premature error response
 func (p *Proxy) sendError(c *context, id string, code int) {
 	addBranding(c.responseWriter.Header())
-	http.Error(c.responseWriter, http.StatusText(code), code)
+	if p.grpc && isGRPCRequest(c.request) {
+		c.grpcStatus = sendGRPCError(c.responseWriter, code)
+		p.measureGRPCStatus(id, c.grpcStatus)
+	} else {
+		http.Error(c.responseWriter, http.StatusText(code), code)
+	}
+
 	p.metrics.MeasureServe(
 		id,
 		c.metricsHost(),
@@ -840,6 +957,9 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, req *http.Request) error {
 		auditLogOut:     p.upgradeAuditLogOut,
 		auditLogErr:     p.upgradeAuditLogErr,
 		auditLogHook:    p.auditLogHook,
+		idleTimeout:     p.upgradeIdleTimeout,
+		metrics:         p.metrics,
+		active:          &p.activeUpgrades,
 	}
 
 	upgradeProxy.serveHTTP(ctx.responseWriter, req)
@@ -855,6 +975,15 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, *proxyError) {
 		return nil, &proxyError{err: err}
 	}
 
+	p.requestHeaders.apply(req.Header)
+
+	var endpoint *routing.LBEndpointState
+	if ctx.route.BackendType == eskip.LBBackend {
+		selectUntriedEndpoint(req.URL, ctx.route, ctx.triedEndpoints)
+		ctx.triedEndpoints = append(ctx.triedEndpoints, req.URL.Host)
+		endpoint = lbEndpointState(ctx.route, req.URL.Host)
+	}
+
 	if p.experimentalUpgrade && isUpgradeRequest(req) {
 		if err = p.makeUpgradeRequest(ctx, req); err != nil {
 			return nil, &proxyError{err: err}
@@ -884,10 +1013,17 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, *proxyError) {
 	_ = p.tracing.tracer.Inject(ctx.proxySpan.Context(), ot.HTTPHeaders, carrier)
 
 	req = req.WithContext(ot.ContextWithSpan(req.Context(), ctx.proxySpan))
+	req, tryTimeout := p.retrier.withTryTimeout(req)
+	req, cancelBackend := withBackendTimeout(req, bag)
+	req = withClientAddr(req, bag, ctx.request.RemoteAddr)
+	req = p.connPool.traceRequest(req)
 
 	p.metrics.IncCounter("outgoing." + req.Proto)
 	ctx.proxySpan.LogKV("http_roundtrip", StartEvent)
 
+	endpoint.Started()
+	roundTripStart := time.Now()
+
 	var response *http.Response
 	switch req.URL.Scheme {
 	case "fastcgi":
@@ -895,9 +1031,11 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, *proxyError) {
 		if sf, ok := ctx.StateBag()["fastCgiFilename"]; ok {
 			f = sf.(string)
 		}
-		rt, err := fastcgi.NewRoundTripper(p.log, req.URL.Host, f)
+		params, _ := ctx.StateBag()[filters.FastCgiParamsKey].(map[string]string)
+		rt, err := fastcgi.NewRoundTripperWithParams(p.log, req.URL.Host, f, params)
 		if err != nil {
 			p.log.Errorf("Failed to create fastcgi roundtripper: %v", err)
+			endpoint.Done(true, 0)
 
 			return nil, &proxyError{err: err}
 		}
@@ -914,11 +1052,49 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, *proxyError) {
 		response, err = rt.RoundTrip(req)
 		if err != nil {
 			p.log.Errorf("Failed to roundtrip to fastcgi: %v", err)
+			endpoint.Done(true, 0)
 
 			return nil, &proxyError{err: err}
 		}
+	case h2Scheme, h2cScheme:
+		var rt http.RoundTripper
+		if rt, err = p.routeTransports.getHTTP2(req, bag); err == nil {
+			response, err = rt.RoundTrip(req)
+		} else {
+			err = &proxyError{err: err, code: http.StatusInternalServerError}
+		}
 	default:
-		response, err = p.roundTripper.RoundTrip(req)
+		response, err = p.routeTransports.get(bag).RoundTrip(req)
+	}
+
+	if cancelBackend != nil {
+		if err == nil {
+			response.Body = &closeHookBody{ReadCloser: response.Body, onClose: cancelBackend}
+		} else {
+			if req.Context().Err() == stdlibcontext.DeadlineExceeded && ctx.request.Context().Err() == nil {
+				err = errBackendTimeout
+			}
+
+			cancelBackend()
+		}
+	}
+
+	if tryTimeout.stop() {
+		if err == nil {
+			response.Body.Close()
+		}
+
+		response, err = nil, errTryTimeout
+	}
+
+	if endpoint != nil {
+		if err == nil {
+			failed := isEndpointFailure(response.StatusCode)
+			latency := time.Since(roundTripStart)
+			response.Body = &closeHookBody{ReadCloser: response.Body, onClose: func() { endpoint.Done(failed, latency) }}
+		} else {
+			endpoint.Done(ctx.request.Context().Err() == nil && !requestBodyExceeded(ctx.request), 0)
+		}
 	}
 
 	ctx.proxySpan.LogKV("http_roundtrip", EndEvent)
@@ -928,6 +1104,21 @@ func (p *Proxy) makeBackendRequest(ctx *context) (*http.Response, *proxyError) {
 			"event", "error",
 			"message", err.Error())
 
+		if requestBodyExceeded(ctx.request) {
+			p.log.Errorf("Request body too large to %s", ctx.route.Backend)
+			p.tracing.setTag(ctx.proxySpan, HTTPStatusCodeTag, uint16(http.StatusRequestEntityTooLarge))
+			return nil, errRequestBodyTooLargeResponse
+		}
+
+		if err == errTryTimeout || err == errBackendTimeout || err == errResponseHeaderTimeout {
+			p.log.Errorf("Backend request timeout to %s", ctx.route.Backend)
+			p.tracing.setTag(ctx.proxySpan, HTTPStatusCodeTag, uint16(http.StatusGatewayTimeout))
+			return nil, &proxyError{
+				err:  err,
+				code: http.StatusGatewayTimeout,
+			}
+		}
+
 		if perr, ok := err.(*proxyError); ok {
 			p.log.Errorf("Failed to do backend roundtrip to %s: %v", ctx.route.Backend, perr)
 			//p.lb.AddHealthcheck(ctx.route.Backend)
@@ -1023,7 +1214,7 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 	}
 
 	settings, _ := c.stateBag[circuitfilters.RouteSettingsKey].(circuit.BreakerSettings)
-	settings.Host = c.outgoingHost
+	settings.Host = breakerHost(c)
 
 	b := p.breakers.Get(settings)
 	if b == nil {
@@ -1034,6 +1225,22 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 	return done, ok
 }
 
+// returns the key of the circuit breaker of the backend. The load balanced
+// routes, that don't set the outgoing host, share the breaker with the
+// routes that have the same endpoints.
+func breakerHost(c *context) string {
+	if c.outgoingHost != "" || c.route.BackendType != eskip.LBBackend {
+		return c.outgoingHost
+	}
+
+	hosts := make([]string, len(c.route.LBEndpoints))
+	for i, e := range c.route.LBEndpoints {
+		hosts[i] = e.Host
+	}
+
+	return strings.Join(hosts, ",")
+}
+
 func newRatelimitError(settings ratelimit.Settings, retryAfter int) error {
 	return &proxyError{
 		err:  errRatelimit,
@@ -1103,6 +1310,9 @@ func (p *Proxy) do(ctx *context) error {
 		ctx.outgoingDebugRequest = debugReq
 		ctx.setResponse(&http.Response{Header: make(http.Header)}, p.flags.PreserveOriginal())
 	} else {
+		if err := p.limitRequestBody(ctx); err != nil {
+			return err
+		}
 
 		done, allow := p.checkBreaker(ctx)
 		if !allow {
@@ -1111,35 +1321,45 @@ func (p *Proxy) do(ctx *context) error {
 		}
 
 		backendStart := time.Now()
+		p.retrier.request()
 		rsp, perr := p.makeBackendRequest(ctx)
+		attempt := 1
+		for ; p.retrier.shouldRetry(ctx, attempt, rsp, perr); attempt++ {
+			if perr != nil {
+				if done != nil {
+					done(false)
+				}
+
+				p.metrics.IncErrorsBackend(ctx.route.Id)
+			} else {
+				rsp.Body.Close()
+			}
+
+			if ctx.proxySpan != nil {
+				ctx.proxySpan.Finish()
+				ctx.proxySpan = nil
+			}
+
+			tracing.LogKV("retry", ctx.route.Id, ctx.Request().Context())
+			p.metrics.IncCounter(fmt.Sprintf(retryKey, ctx.route.Id))
+			rsp, perr = p.makeBackendRequest(ctx)
+		}
+
 		if perr != nil {
 			if done != nil {
-				done(false)
+				// the too large request bodies are not the failures of the backend
+				done(perr == errRequestBodyTooLargeResponse)
 			}
 
 			p.metrics.IncErrorsBackend(ctx.route.Id)
-
-			if retryable(ctx.Request()) && perr.DialError() && ctx.route.BackendType == eskip.LBBackend {
-				if ctx.proxySpan != nil {
-					ctx.proxySpan.Finish()
-					ctx.proxySpan = nil
-				}
-
-				tracing.LogKV("retry", ctx.route.Id, ctx.Request().Context())
-
-				perr = nil
-				var perr2 *proxyError
-				rsp, perr2 = p.makeBackendRequest(ctx)
-				if perr2 != nil {
-					p.log.Errorf("Failed to do retry backend request: %v", perr2)
-					if perr2.code >= http.StatusInternalServerError {
-						p.metrics.MeasureBackend5xx(backendStart)
-					}
-					return perr2
+			if attempt > 1 {
+				p.log.Errorf("Failed to do retry backend request: %v", perr)
+				if perr.code >= http.StatusInternalServerError {
+					p.metrics.MeasureBackend5xx(backendStart)
 				}
-			} else {
-				return perr
 			}
+
+			return perr
 		}
 
 		if rsp.StatusCode >= http.StatusInternalServerError {
@@ -1179,7 +1399,13 @@ func (p *Proxy) serveResponse(ctx *context) {
 
 	start := time.Now()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, StartEvent)
+	if p.flags.HopHeadersRemoval() {
+		removeHopHeaders(ctx.response.Header, false)
+	}
+
+	p.responseHeaders.apply(ctx.response.Header)
 	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
+	announceTrailers(ctx.responseWriter, ctx.response)
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, EndEvent)
 
 	if err := ctx.Request().Context().Err(); err != nil {
@@ -1190,9 +1416,19 @@ func (p *Proxy) serveResponse(ctx *context) {
 		p.tracing.setTag(ctx.proxySpan, ClientRequestStateTag, ClientRequestCanceled)
 	}
 
-	ctx.responseWriter.WriteHeader(ctx.response.StatusCode)
-	ctx.responseWriter.Flush()
-	err := copyStream(ctx.responseWriter, ctx.response.Body, p.tracing, ctx.proxySpan)
+	bufferResponse(ctx.response, p.responseBufferSize)
+	w := newResponseTimeoutWriter(ctx.responseWriter, p.responseWriteTimeout, ctx.response.Body)
+	w.WriteHeader(ctx.response.StatusCode)
+	w.Flush()
+	fw := newFlushWriter(w, responseFlushInterval(p.responseFlushInterval, ctx.response))
+	err := copyStream(fw, ctx.response.Body, p.tracing, ctx.proxySpan)
+	fw.stop()
+	copyTrailers(ctx.responseWriter, ctx.response)
+	if p.grpc && isGRPCRequest(ctx.request) {
+		ctx.grpcStatus = grpcStatus(ctx.response)
+		p.measureGRPCStatus(ctx.route.Id, ctx.grpcStatus)
+	}
+
 	if err != nil {
 		p.metrics.IncErrorsStreaming(ctx.route.Id)
 		p.log.Error("error while copying the response stream", err)
@@ -1398,6 +1634,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				StatusCode:   statusCode,
 				RequestTime:  ctx.startServe,
 				Duration:     time.Since(ctx.startServe),
+				GRPCStatus:   ctx.grpcStatus,
 			}
 
 			additionalData, _ := ctx.stateBag[al.AccessLogAdditionalDataKey].(map[string]interface{})
@@ -1410,6 +1647,8 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = rfc.PatchPath(r.URL.Path, r.URL.RawPath)
 	}
 
+	p.forwardedHeaders.Set(r)
+
 	p.tracing.setTag(span, SpanKindTag, SpanKindServer)
 	p.setCommonSpanInfo(r.URL, r, span)
 	r = r.WithContext(ot.ContextWithSpan(r.Context(), span))
@@ -1418,6 +1657,12 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx.startServe = time.Now()
 	ctx.tracer = p.tracing.tracer
 
+	// the default limit is visible to the filters, e.g. to limit the size
+	// of the decompressed request body, and can be overridden by them
+	if p.maxRequestBodySize > 0 {
+		ctx.stateBag[filters.MaxRequestBodySizeKey] = p.maxRequestBodySize
+	}
+
 	defer func() {
 		if ctx.response != nil && ctx.response.Body != nil {
 			err := ctx.response.Body.Close()
@@ -1441,6 +1686,13 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if abort, _ := ctx.stateBag[filters.AbortConnectionKey].(bool); abort {
+		p.tracing.setTag(span, ErrorTag, true)
+
+		// the http server closes the client connection without logging
+		panic(http.ErrAbortHandler)
+	}
+
 	p.serveResponse(ctx)
 	p.metrics.MeasureServe(
 		ctx.route.Id,
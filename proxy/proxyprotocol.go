@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"net"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxyprotocol"
+)
+
+type clientAddrKey struct{}
+
+func proxyProtocolVersion(bag map[string]interface{}) int {
+	v, _ := bag[filters.BackendProxyProtocolKey].(int)
+	return v
+}
+
+// stores the address of the client in the context of the backend request,
+// when the route sends the PROXY protocol header to the backend
+func withClientAddr(req *http.Request, bag map[string]interface{}, remoteAddr string) *http.Request {
+	if proxyProtocolVersion(bag) == 0 {
+		return req
+	}
+
+	a, err := net.ResolveTCPAddr("tcp", remoteAddr)
+	if err != nil {
+		return req
+	}
+
+	return req.WithContext(stdlibcontext.WithValue(req.Context(), clientAddrKey{}, a))
+}
+
+// sends the PROXY protocol header on the new backend connections, with
+// the address of the client and the address where the client connected to
+func proxyProtocolDial(dial dialFunc, version int) dialFunc {
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		src, _ := ctx.Value(clientAddrKey{}).(net.Addr)
+		dst, _ := ctx.Value(http.LocalAddrContextKey).(net.Addr)
+		if err := proxyprotocol.WriteHeader(conn, version, src, dst); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
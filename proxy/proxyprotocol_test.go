@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/proxyprotocol"
+)
+
+func TestBackendProxyProtocol(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})}
+
+	go backend.Serve(proxyprotocol.NewListener(l, proxyprotocol.Options{}))
+	defer backend.Close()
+
+	for _, version := range []int{1, 2} {
+		t.Run(fmt.Sprintf("version %d", version), func(t *testing.T) {
+			tp, err := newTestProxyWithParams(
+				fmt.Sprintf(`* -> backendProxyProtocol(%d) -> "http://%s"`, version, l.Addr()),
+				Params{},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			var clientAddr string
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+					c, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+					if err == nil {
+						clientAddr = c.LocalAddr().String()
+					}
+
+					return c, err
+				},
+			}}
+
+			for i := 0; i < 2; i++ {
+				rsp, err := client.Get(ps.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				b, err := ioutil.ReadAll(rsp.Body)
+				rsp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(b) != clientAddr {
+					t.Errorf("invalid client address at the backend, expected: %s, got: %s", clientAddr, string(b))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultRetryBudgetReserve = 10
+
+	// the number of the retried requests by the route id
+	retryKey = "retry.%s"
+
+	// the number of the retries denied by the retry budget
+	retryBudgetExhaustedKey = "retry.budget_exhausted"
+)
+
+var errTryTimeout = errors.New("backend request timeout")
+
+// RetryPolicy configures retrying the failed backend requests. Only the
+// requests without a body are retried, when their method is GET, HEAD, or
+// one of the explicitly allowed methods. The failed attempts of requests
+// to load balanced backends are retried against another endpoint, when
+// available.
+type RetryPolicy struct {
+
+	// MaxAttempts is the maximum number of the attempts to send a request
+	// to the backend, including the first one. When less than 2, the
+	// requests are not retried.
+	MaxAttempts int
+
+	// StatusCodes lists the response status codes of the backends, that
+	// should be retried, e.g. 503. The connection errors and the per try
+	// timeouts are always retried.
+	StatusCodes []int
+
+	// Methods lists the request methods that are retried in addition to
+	// GET and HEAD, e.g. DELETE.
+	Methods []string
+
+	// PerTryTimeout limits how long the response headers of a single
+	// attempt are waited for. When it expires, the request is retried, or
+	// when it was the last attempt, the proxy responds with 504 Gateway
+	// Timeout. When zero, the attempts are not limited.
+	PerTryTimeout time.Duration
+
+	// BudgetRatio limits the retries to the given ratio of the incoming
+	// requests, e.g. 0.2 allows at most 20% additional backend requests
+	// due to retries, to avoid retry storms when the backends are
+	// overloaded. When zero, the retries are not limited by a budget.
+	BudgetRatio float64
+
+	// BudgetReserve is the maximum number of retries that the budget can
+	// accumulate during periods with few failures. Defaults to 10.
+	BudgetReserve int
+}
+
+// retryBudget allows retrying only a ratio of the requests. Every request
+// deposits the ratio, and every retry withdraws one from the balance.
+type retryBudget struct {
+	mx      sync.Mutex
+	ratio   float64
+	reserve float64
+	balance float64
+}
+
+type retrier struct {
+	maxAttempts   int
+	methods       map[string]bool
+	statusCodes   map[int]bool
+	perTryTimeout time.Duration
+	budget        *retryBudget
+	metrics       metrics.Metrics
+}
+
+// tryTimeout cancels a single backend request, when the response headers
+// were not received in time.
+type tryTimeout struct {
+	timer   *time.Timer
+	expired int32
+}
+
+func (b *retryBudget) deposit() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.balance += b.ratio
+	if b.balance > b.reserve {
+		b.balance = b.reserve
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+
+	b.balance--
+	return true
+}
+
+func newRetrier(p *RetryPolicy, m metrics.Metrics) *retrier {
+	if p == nil {
+		return nil
+	}
+
+	r := &retrier{
+		maxAttempts:   p.MaxAttempts,
+		methods:       map[string]bool{http.MethodGet: true, http.MethodHead: true},
+		statusCodes:   make(map[int]bool),
+		perTryTimeout: p.PerTryTimeout,
+		metrics:       m,
+	}
+
+	for _, method := range p.Methods {
+		r.methods[method] = true
+	}
+
+	for _, c := range p.StatusCodes {
+		r.statusCodes[c] = true
+	}
+
+	if p.BudgetRatio > 0 {
+		reserve := p.BudgetReserve
+		if reserve <= 0 {
+			reserve = defaultRetryBudgetReserve
+		}
+
+		r.budget = &retryBudget{
+			ratio:   p.BudgetRatio,
+			reserve: float64(reserve),
+			balance: float64(reserve),
+		}
+	}
+
+	return r
+}
+
+func (r *retrier) request() {
+	if r != nil && r.budget != nil {
+		r.budget.deposit()
+	}
+}
+
+// decides whether a failed attempt can be retried. Without a retry
+// policy, only the dialing errors of the load balanced backends are
+// retried once.
+func (r *retrier) shouldRetry(ctx *context, attempt int, rsp *http.Response, perr *proxyError) bool {
+	if !retryable(ctx.Request()) {
+		return false
+	}
+
+	if r == nil {
+		return attempt == 1 && perr != nil && perr.DialError() && ctx.route.BackendType == eskip.LBBackend
+	}
+
+	if attempt >= r.maxAttempts || !r.methods[ctx.Request().Method] {
+		return false
+	}
+
+	switch {
+	case perr != nil && (perr.DialError() || perr.err == errTryTimeout):
+	case perr == nil && r.statusCodes[rsp.StatusCode]:
+	default:
+		return false
+	}
+
+	if r.budget != nil && !r.budget.withdraw() {
+		r.metrics.IncCounter(retryBudgetExhaustedKey)
+		return false
+	}
+
+	return true
+}
+
+func (r *retrier) withTryTimeout(req *http.Request) (*http.Request, *tryTimeout) {
+	if r == nil || r.perTryTimeout <= 0 {
+		return req, nil
+	}
+
+	c, cancel := stdlibcontext.WithCancel(req.Context())
+	t := &tryTimeout{}
+	t.timer = time.AfterFunc(r.perTryTimeout, func() {
+		atomic.StoreInt32(&t.expired, 1)
+		cancel()
+	})
+
+	return req.WithContext(c), t
+}
+
+// stops the timer, and returns true if the timeout has expired. When the
+// timeout has expired, the context of the backend request was canceled.
+func (t *tryTimeout) stop() bool {
+	if t == nil {
+		return false
+	}
+
+	t.timer.Stop()
+	return atomic.LoadInt32(&t.expired) == 1
+}
+
+// returns the state of the load balanced endpoint with the given host
+func lbEndpointState(rt *routing.Route, host string) *routing.LBEndpointState {
+	for _, e := range rt.LBEndpoints {
+		if e.Host == host {
+			return e.State
+		}
+	}
+
+	return nil
+}
+
+// returns true for the response status codes that indicate that the
+// endpoint is unhealthy, used by the passive health checks
+func isEndpointFailure(code int) bool {
+	return code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+// when the endpoint selected by the load balancer algorithm was already
+// tried for the current request, selects the next one that was not.
+func selectUntriedEndpoint(u *url.URL, rt *routing.Route, tried []string) {
+	isTried := func(host string) bool {
+		for _, t := range tried {
+			if t == host {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !isTried(u.Host) {
+		return
+	}
+
+	current := 0
+	for i, e := range rt.LBEndpoints {
+		if e.Host == u.Host {
+			current = i
+			break
+		}
+	}
+
+	for i := 1; i < len(rt.LBEndpoints); i++ {
+		e := rt.LBEndpoints[(current+i)%len(rt.LBEndpoints)]
+		if !isTried(e.Host) {
+			u.Scheme = e.Scheme
+			u.Host = e.Host
+			return
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+type countingBackend struct {
+	*httptest.Server
+	requests int64
+}
+
+func newCountingBackend(h func(w http.ResponseWriter, n int64)) *countingBackend {
+	b := &countingBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		h(w, atomic.AddInt64(&b.requests, 1))
+	}))
+
+	return b
+}
+
+func (b *countingBackend) count() int64 {
+	return atomic.LoadInt64(&b.requests)
+}
+
+func statusBackend(code int) *countingBackend {
+	return newCountingBackend(func(w http.ResponseWriter, _ int64) {
+		w.WriteHeader(code)
+	})
+}
+
+func retryTestRequest(t *testing.T, p *Proxy, method string) int {
+	req, err := http.NewRequest(method, "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	failing := statusBackend(http.StatusServiceUnavailable)
+	defer failing.Close()
+
+	ok := statusBackend(http.StatusOK)
+	defer ok.Close()
+
+	doc := fmt.Sprintf(`* -> <roundRobin, "%s", "%s">`, failing.URL, ok.URL)
+	tp, err := newTestProxyWithParams(doc, Params{RetryPolicy: &RetryPolicy{
+		MaxAttempts: 2,
+		StatusCodes: []int{http.StatusServiceUnavailable},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for i := 0; i < 4; i++ {
+		if code := retryTestRequest(t, tp.proxy, "GET"); code != http.StatusOK {
+			t.Errorf("failed to retry against the other endpoint, got: %d", code)
+		}
+	}
+
+	if failing.count() == 0 || ok.count() != 4 {
+		t.Errorf("invalid number of backend requests: %d, %d", failing.count(), ok.count())
+	}
+}
+
+func TestRetryMethods(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		methods  []string
+		expected int64
+	}{
+		{method: "GET", expected: 3},
+		{method: "HEAD", expected: 3},
+		{method: "DELETE", expected: 1},
+		{method: "DELETE", methods: []string{"DELETE"}, expected: 3},
+		{method: "POST", methods: []string{"DELETE"}, expected: 1},
+	} {
+		t.Run(fmt.Sprintf("%s, %v", test.method, test.methods), func(t *testing.T) {
+			backend := statusBackend(http.StatusServiceUnavailable)
+			defer backend.Close()
+
+			tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> "%s"`, backend.URL), Params{RetryPolicy: &RetryPolicy{
+				MaxAttempts: 3,
+				StatusCodes: []int{http.StatusServiceUnavailable},
+				Methods:     test.methods,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			if code := retryTestRequest(t, tp.proxy, test.method); code != http.StatusServiceUnavailable {
+				t.Errorf("invalid status code: %d", code)
+			}
+
+			if backend.count() != test.expected {
+				t.Errorf("invalid number of attempts, expected: %d, got: %d", test.expected, backend.count())
+			}
+		})
+	}
+}
+
+func TestRetryPerTryTimeout(t *testing.T) {
+	for _, test := range []struct {
+		title       string
+		maxAttempts int
+		expected    int
+	}{{
+		title:       "retried",
+		maxAttempts: 2,
+		expected:    http.StatusOK,
+	}, {
+		title:       "last attempt",
+		maxAttempts: 1,
+		expected:    http.StatusGatewayTimeout,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			backend := newCountingBackend(func(w http.ResponseWriter, n int64) {
+				if n == 1 {
+					time.Sleep(120 * time.Millisecond)
+				}
+			})
+			defer backend.Close()
+
+			tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> "%s"`, backend.URL), Params{RetryPolicy: &RetryPolicy{
+				MaxAttempts:   test.maxAttempts,
+				PerTryTimeout: 30 * time.Millisecond,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			if code := retryTestRequest(t, tp.proxy, "GET"); code != test.expected {
+				t.Errorf("invalid status code, expected: %d, got: %d", test.expected, code)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	backend := statusBackend(http.StatusServiceUnavailable)
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> "%s"`, backend.URL), Params{RetryPolicy: &RetryPolicy{
+		MaxAttempts:   2,
+		StatusCodes:   []int{http.StatusServiceUnavailable},
+		BudgetRatio:   0.1,
+		BudgetReserve: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	retryTestRequest(t, tp.proxy, "GET")
+	if backend.count() != 2 {
+		t.Fatalf("failed to retry within the budget: %d", backend.count())
+	}
+
+	retryTestRequest(t, tp.proxy, "GET")
+	if backend.count() != 3 {
+		t.Errorf("failed to limit the retries by the budget: %d", backend.count())
+	}
+}
+
+func TestSelectUntriedEndpoint(t *testing.T) {
+	rt := &routing.Route{LBEndpoints: []routing.LBEndpoint{
+		{Scheme: "http", Host: "10.0.0.1:80"},
+		{Scheme: "http", Host: "10.0.0.2:80"},
+		{Scheme: "http", Host: "10.0.0.3:80"},
+	}}
+
+	for _, test := range []struct {
+		title    string
+		selected string
+		tried    []string
+		expected string
+	}{{
+		title:    "not tried",
+		selected: "10.0.0.1:80",
+		expected: "10.0.0.1:80",
+	}, {
+		title:    "tried, next one",
+		selected: "10.0.0.1:80",
+		tried:    []string{"10.0.0.1:80"},
+		expected: "10.0.0.2:80",
+	}, {
+		title:    "tried, wraps around",
+		selected: "10.0.0.3:80",
+		tried:    []string{"10.0.0.3:80", "10.0.0.2:80"},
+		expected: "10.0.0.1:80",
+	}, {
+		title:    "all tried",
+		selected: "10.0.0.2:80",
+		tried:    []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"},
+		expected: "10.0.0.2:80",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			u := &url.URL{Scheme: "http", Host: test.selected}
+			selectUntriedEndpoint(u, rt, test.tried)
+			if u.Host != test.expected {
+				t.Errorf("invalid endpoint, expected: %s, got: %s", test.expected, u.Host)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var errResponseWriteTimeout = errors.New("response write timeout")
+
+// reads the backend response body into memory, up to the limit. When the
+// complete body fits, the backend connection is released before the
+// response is sent to the client, so that slow clients don't hold it.
+// Otherwise, the buffered part is sent first, followed by the rest of the
+// body streamed from the backend.
+func bufferResponse(rsp *http.Response, limit int) {
+	if limit <= 0 || rsp.Body == nil || rsp.ContentLength > int64(limit) {
+		return
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, rsp.Body, int64(limit)+1)
+	if err == io.EOF && n <= int64(limit) {
+		rsp.Body.Close()
+		rsp.Body = ioutil.NopCloser(&buf)
+		return
+	}
+
+	// on read errors, the error is returned again by the backend body,
+	// after the buffered part
+	rsp.Body = &bufferedBody{
+		Reader: io.MultiReader(&buf, rsp.Body),
+		Closer: rsp.Body,
+	}
+}
+
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// responseTimeoutWriter aborts the response, when a write or a flush to
+// the client doesn't complete within the timeout. In this case, the
+// backend response body is closed, releasing the backend connection held
+// by a slow client. The client connection itself is closed by the server,
+// when its write timeout is reached.
+type responseTimeoutWriter struct {
+	flushedResponseWriter
+	timeout time.Duration
+	body    io.Closer
+	once    sync.Once
+	mx      sync.Mutex
+	expired bool
+}
+
+func newResponseTimeoutWriter(w flushedResponseWriter, timeout time.Duration, body io.Closer) flushedResponseWriter {
+	if timeout <= 0 {
+		return w
+	}
+
+	return &responseTimeoutWriter{
+		flushedResponseWriter: w,
+		timeout:               timeout,
+		body:                  body,
+	}
+}
+
+func (w *responseTimeoutWriter) expire() {
+	w.once.Do(func() { w.body.Close() })
+	w.mx.Lock()
+	w.expired = true
+	w.mx.Unlock()
+}
+
+func (w *responseTimeoutWriter) hasExpired() bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.expired
+}
+
+func (w *responseTimeoutWriter) Write(p []byte) (int, error) {
+	if w.hasExpired() {
+		return 0, errResponseWriteTimeout
+	}
+
+	t := time.AfterFunc(w.timeout, w.expire)
+	n, err := w.flushedResponseWriter.Write(p)
+	if !t.Stop() && err == nil {
+		err = errResponseWriteTimeout
+	}
+
+	return n, err
+}
+
+func (w *responseTimeoutWriter) Flush() {
+	if w.hasExpired() {
+		return
+	}
+
+	t := time.AfterFunc(w.timeout, w.expire)
+	w.flushedResponseWriter.Flush()
+	t.Stop()
+}
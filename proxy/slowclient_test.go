@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBufferResponse(t *testing.T) {
+	for _, test := range []struct {
+		title          string
+		body           string
+		limit          int
+		expectReleased bool
+	}{{
+		title: "no buffering",
+		body:  "Hello, world!",
+	}, {
+		title:          "fits",
+		body:           "Hello, world!",
+		limit:          13,
+		expectReleased: true,
+	}, {
+		title: "doesn't fit",
+		body:  "Hello, world!",
+		limit: 12,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			body := &closeRecorder{Reader: strings.NewReader(test.body)}
+			rsp := &http.Response{Body: body, ContentLength: -1}
+			bufferResponse(rsp, test.limit)
+			if body.closed != test.expectReleased {
+				t.Errorf("invalid backend body state, expected closed: %t, got: %t", test.expectReleased, body.closed)
+			}
+
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.body {
+				t.Errorf("invalid body: %s", string(b))
+			}
+		})
+	}
+}
+
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestResponseWriteTimeout(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("Hello, world!")}
+	bw := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+	w := newResponseTimeoutWriter(bw, 30*time.Millisecond, body)
+
+	go func() {
+		time.Sleep(120 * time.Millisecond)
+		close(bw.release)
+	}()
+
+	if _, err := w.Write([]byte("Hello")); err != errResponseWriteTimeout {
+		t.Errorf("failed to time out, got: %v", err)
+	}
+
+	if _, err := w.Write([]byte(", world!")); err != errResponseWriteTimeout {
+		t.Errorf("failed to abort the response, got: %v", err)
+	}
+
+	if !body.closed {
+		t.Error("failed to release the backend response body")
+	}
+
+	w = newResponseTimeoutWriter(httptest.NewRecorder(), 30*time.Millisecond, body)
+	if _, err := w.Write([]byte("Hello")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResponseBufferSize(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 1<<10)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(`* -> "`+backend.URL+`"`, Params{
+		ResponseBufferSize:   1 << 12,
+		ResponseWriteTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://www.example.org", nil)
+	tp.proxy.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Errorf("invalid response: %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+var errBackendTimeout = errors.New("backend timeout")
+
+// transportOptions contains the transport level settings that can be set
+// per route by filters, overriding the proxy defaults
+type transportOptions struct {
+	dial           time.Duration
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+	proxyProtocol  int
+	egressProxy    string
+}
+
+// routeTransports maintains the transports for the routes with custom
+// transport options. The routes with the same options share the same
+// transport, and so the same connection pool. The HTTP/2 transports are
+// derived from the transport with the same options.
+type routeTransports struct {
+	mx         sync.Mutex
+	base       *http.Transport
+	dialer     net.Dialer
+	dial       func(net.Dialer) dialFunc
+	transports map[transportOptions]*http.Transport
+	http2      map[transportOptions]*http2Transports
+}
+
+// closeHookBody calls a function when the backend response body was closed,
+// e.g. to release the context of the backend request with an overall
+// timeout.
+type closeHookBody struct {
+	io.ReadCloser
+	once    sync.Once
+	onClose func()
+}
+
+func newRouteTransports(base *http.Transport, d net.Dialer, dial func(net.Dialer) dialFunc) *routeTransports {
+	return &routeTransports{
+		base:       base,
+		dialer:     d,
+		dial:       dial,
+		transports: make(map[transportOptions]*http.Transport),
+		http2:      make(map[transportOptions]*http2Transports),
+	}
+}
+
+func durationFromStateBag(bag map[string]interface{}, key string) time.Duration {
+	d, _ := bag[key].(time.Duration)
+	return d
+}
+
+func transportOptionsFromStateBag(bag map[string]interface{}) transportOptions {
+	return transportOptions{
+		dial:           durationFromStateBag(bag, filters.BackendDialTimeoutKey),
+		tlsHandshake:   durationFromStateBag(bag, filters.BackendTLSHandshakeTimeoutKey),
+		responseHeader: durationFromStateBag(bag, filters.BackendResponseHeaderTimeoutKey),
+		proxyProtocol:  proxyProtocolVersion(bag),
+		egressProxy:    egressProxyURL(bag),
+	}
+}
+
+// returns the transport matching the transport options set by the
+// filters of the route, or the default one, when none is set
+func (t *routeTransports) get(bag map[string]interface{}) *http.Transport {
+	to := transportOptionsFromStateBag(bag)
+	if to == (transportOptions{}) {
+		return t.base
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.transportLocked(to)
+}
+
+// returns the round tripper for the HTTP/2 backends, derived from the
+// transport matching the transport options of the route. The PROXY
+// protocol is not supported, because the HTTP/2 connections are shared by
+// the requests of different clients, and neither is the egress proxy
+// with h2c.
+func (t *routeTransports) getHTTP2(req *http.Request, bag map[string]interface{}) (http.RoundTripper, error) {
+	to := transportOptionsFromStateBag(bag)
+	if to.proxyProtocol > 0 || to.egressProxy != "" && req.URL.Scheme == h2cScheme {
+		return nil, errHTTP2TransportOptions
+	}
+
+	t.mx.Lock()
+	h2t, ok := t.http2[to]
+	if !ok {
+		tr := t.base
+		if to != (transportOptions{}) {
+			tr = t.transportLocked(to)
+		}
+
+		h2t = newHTTP2Transports(tr)
+		t.http2[to] = h2t
+	}
+
+	t.mx.Unlock()
+	return h2t.roundTripper(req), nil
+}
+
+func (t *routeTransports) transportLocked(to transportOptions) *http.Transport {
+	if tr, ok := t.transports[to]; ok {
+		return tr
+	}
+
+	tr := t.base.Clone()
+	if to.dial > 0 {
+		d := t.dialer
+		d.Timeout = to.dial
+		tr.DialContext = t.dial(d)
+	}
+
+	if to.tlsHandshake > 0 {
+		tr.TLSHandshakeTimeout = to.tlsHandshake
+	}
+
+	if to.responseHeader > 0 {
+		tr.ResponseHeaderTimeout = to.responseHeader
+	}
+
+	if to.proxyProtocol > 0 {
+		// the PROXY protocol header carries the address of a single
+		// client, so the connections are not reused
+		tr.DisableKeepAlives = true
+		tr.DialContext = proxyProtocolDial(tr.DialContext, to.proxyProtocol)
+	}
+
+	if to.egressProxy != "" {
+		// validated by the egressProxy filter
+		u, _ := url.Parse(to.egressProxy)
+		tr.Proxy = egressProxyFunc(u)
+	}
+
+	t.transports[to] = tr
+	return tr
+}
+
+func (t *routeTransports) closeIdleConnections() {
+	t.base.CloseIdleConnections()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, tr := range t.transports {
+		tr.CloseIdleConnections()
+	}
+
+	for _, h2t := range t.http2 {
+		h2t.closeIdleConnections()
+	}
+}
+
+// applies the overall backend timeout of the route, when set. The returned
+// cancel function is nil, when there is no timeout.
+func withBackendTimeout(req *http.Request, bag map[string]interface{}) (*http.Request, stdlibcontext.CancelFunc) {
+	d := durationFromStateBag(bag, filters.BackendTimeoutKey)
+	if d <= 0 {
+		return req, nil
+	}
+
+	c, cancel := stdlibcontext.WithTimeout(req.Context(), d)
+	return req.WithContext(c), cancel
+}
+
+func (b *closeHookBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+func TestBackendTimeouts(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-header" {
+			time.Sleep(120 * time.Millisecond)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/slow-body" {
+			time.Sleep(120 * time.Millisecond)
+		}
+
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	for _, test := range []struct {
+		title          string
+		filters        string
+		path           string
+		params         Params
+		expectedStatus int
+		expectBody     bool
+	}{{
+		title:          "no timeout",
+		path:           "/slow-header",
+		expectedStatus: http.StatusOK,
+		expectBody:     true,
+	}, {
+		title:          "backend timeout",
+		filters:        `backendTimeout("30ms")`,
+		path:           "/slow-header",
+		expectedStatus: http.StatusGatewayTimeout,
+	}, {
+		title:          "backend timeout while reading the body",
+		filters:        `backendTimeout("30ms")`,
+		path:           "/slow-body",
+		expectedStatus: http.StatusOK,
+	}, {
+		title:          "backend timeout not reached",
+		filters:        `backendTimeout("1s")`,
+		path:           "/slow-body",
+		expectedStatus: http.StatusOK,
+		expectBody:     true,
+	}, {
+		title:          "response header timeout",
+		filters:        `backendResponseHeaderTimeout("30ms")`,
+		path:           "/slow-header",
+		expectedStatus: http.StatusGatewayTimeout,
+	}, {
+		title:          "response header timeout overrides the default",
+		filters:        `backendResponseHeaderTimeout("1s")`,
+		path:           "/slow-header",
+		params:         Params{ResponseHeaderTimeout: 30 * time.Millisecond},
+		expectedStatus: http.StatusOK,
+		expectBody:     true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			route := fmt.Sprintf(`* -> %q`, backend.URL)
+			if test.filters != "" {
+				route = fmt.Sprintf(`* -> %s -> %q`, test.filters, backend.URL)
+			}
+
+			tp, err := newTestProxyWithParams(route, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			rsp, err := http.Get(ps.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != test.expectedStatus {
+				t.Fatalf("invalid status code, expected: %d, got: %d", test.expectedStatus, rsp.StatusCode)
+			}
+
+			b, _ := ioutil.ReadAll(rsp.Body)
+			if test.expectBody != (string(b) == "Hello, world!") {
+				t.Errorf("invalid response body: %q", string(b))
+			}
+		})
+	}
+}
+
+func TestRouteTransports(t *testing.T) {
+	base := &http.Transport{ResponseHeaderTimeout: time.Second}
+	dial := func(d net.Dialer) dialFunc { return d.DialContext }
+	rts := newRouteTransports(base, net.Dialer{Timeout: time.Second}, dial)
+
+	if tr := rts.get(map[string]interface{}{}); tr != base {
+		t.Error("failed to use the default transport")
+	}
+
+	bag := map[string]interface{}{
+		filters.BackendDialTimeoutKey:           100 * time.Millisecond,
+		filters.BackendResponseHeaderTimeoutKey: 3 * time.Second,
+	}
+
+	tr := rts.get(bag)
+	if tr == base {
+		t.Fatal("failed to create a transport with the route timeouts")
+	}
+
+	if tr.ResponseHeaderTimeout != 3*time.Second {
+		t.Errorf("invalid response header timeout: %v", tr.ResponseHeaderTimeout)
+	}
+
+	if rts.get(bag) != tr {
+		t.Error("failed to share the transport with the same timeouts")
+	}
+
+	bag[filters.BackendTLSHandshakeTimeoutKey] = time.Second
+	if rts.get(bag) == tr {
+		t.Error("failed to create a separate transport for different timeouts")
+	}
+}
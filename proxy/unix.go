@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	unixScheme = routing.UnixScheme
+	unixHost   = "localhost"
+)
+
+// unixTransport connects to the backends listening on unix domain sockets,
+// e.g. sidecar processes on the same host. The backend URLs have the form
+// unix:///path/to/socket, and the path of the socket is passed to the
+// transport as the host of the request URL. The sockets have separate
+// connection pools.
+type unixTransport struct {
+	mx         sync.Mutex
+	base       *http.Transport
+	transports map[string]*http.Transport
+}
+
+func newUnixTransport(base *http.Transport) *unixTransport {
+	return &unixTransport{
+		base:       base,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+func (t *unixTransport) get(socket string) *http.Transport {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if tr, ok := t.transports[socket]; ok {
+		return tr
+	}
+
+	tr := t.base.Clone()
+	dial := t.base.DialContext
+	tr.DialContext = func(ctx stdlibcontext.Context, _, _ string) (net.Conn, error) {
+		return dial(ctx, "unix", socket)
+	}
+
+	// the requests to the sockets are sent directly
+	tr.Proxy = nil
+
+	t.transports[socket] = tr
+	return tr
+}
+
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket := req.URL.Host
+	tr := t.get(socket)
+
+	u := *req.URL
+	u.Scheme = "http"
+	u.Host = unixHost
+
+	r := new(http.Request)
+	*r = *req
+	r.URL = &u
+	if r.Host == socket {
+		r.Host = unixHost
+	}
+
+	return tr.RoundTrip(r)
+}
+
+// creates the outgoing request. The path of the unix domain socket, used
+// as the host, cannot be formatted as a valid URL, therefore in this case
+// the URL is set directly.
+func newOutgoingRequest(method string, u *url.URL, body io.Reader) (*http.Request, error) {
+	if u.Scheme != unixScheme {
+		return http.NewRequest(method, u.String(), body)
+	}
+
+	r, err := http.NewRequest(method, "", body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.URL = cloneURL(u)
+	return r, nil
+}
+
+func (t *unixTransport) closeIdleConnections() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, tr := range t.transports {
+		tr.CloseIdleConnections()
+	}
+}
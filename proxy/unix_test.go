@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func startUnixBackend(t *testing.T, socket, name string) *httptest.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+		w.Write([]byte(name + r.URL.Path))
+	}))
+
+	s.Listener.Close()
+	s.Listener = l
+	s.Start()
+	return s
+}
+
+func TestUnixSocketBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	socket1, socket2 := filepath.Join(dir, "app1.sock"), filepath.Join(dir, "app2.sock")
+	s1 := startUnixBackend(t, socket1, "app1")
+	defer s1.Close()
+	s2 := startUnixBackend(t, socket2, "app2")
+	defer s2.Close()
+
+	for _, test := range []struct {
+		title    string
+		route    string
+		expected map[string]bool
+	}{{
+		title:    "network backend",
+		route:    fmt.Sprintf(`* -> "unix://%s"`, socket1),
+		expected: map[string]bool{"app1/foo": true},
+	}, {
+		title:    "load balanced",
+		route:    fmt.Sprintf(`* -> <roundRobin, "unix://%s", "unix://%s">`, socket1, socket2),
+		expected: map[string]bool{"app1/foo": true, "app2/foo": true},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(test.route, Params{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			received := make(map[string]bool)
+			for i := 0; i < 4; i++ {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest("GET", "http://www.example.org/foo", nil)
+				tp.proxy.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					t.Fatalf("invalid status code: %d", w.Code)
+				}
+
+				if h := w.Header().Get("X-Host"); h != unixHost {
+					t.Errorf("invalid host: %s", h)
+				}
+
+				received[w.Body.String()] = true
+			}
+
+			if len(received) != len(test.expected) {
+				t.Errorf("invalid responses, expected: %v, got: %v", test.expected, received)
+			}
+
+			for r := range received {
+				if !test.expected[r] {
+					t.Errorf("unexpected response: %s", r)
+				}
+			}
+		})
+	}
+}
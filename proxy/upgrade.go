@@ -12,8 +12,21 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	upgradeMetricsPrefix      = "upgrade."
+	upgradeConnectionsKey     = upgradeMetricsPrefix + "connections"
+	upgradeActiveKey          = upgradeMetricsPrefix + "active"
+	upgradeDurationKey        = upgradeMetricsPrefix + "duration"
+	upgradeIdleTimeoutsKey    = upgradeMetricsPrefix + "idle_timeouts"
+	upgradeBackendErrorsKey   = upgradeMetricsPrefix + "errors.backend"
+	upgradeBackendRejectedKey = upgradeMetricsPrefix + "rejected"
 )
 
 // isUpgradeRequest returns true if and only if there is a "Connection"
@@ -47,6 +60,25 @@ type upgradeProxy struct {
 	auditLogOut     io.Writer
 	auditLogErr     io.Writer
 	auditLogHook    chan struct{}
+	idleTimeout     time.Duration
+	metrics         metrics.Metrics
+	active          *int64
+}
+
+// activityReader records the time of the last successful read of the
+// underlying reader.
+type activityReader struct {
+	reader       io.Reader
+	lastActivity *int64
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		atomic.StoreInt64(r.lastActivity, time.Now().UnixNano())
+	}
+
+	return n, err
 }
 
 // TODO: add user here
@@ -67,16 +99,22 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	// https://tools.ietf.org/html/rfc7230#section-6.7
 	// and https://tools.ietf.org/html/rfc6455 (websocket)
 	if (req.ProtoMajor <= 1 && req.ProtoMinor < 1) ||
-		strings.ToLower(req.Header.Get("Connection")) != "upgrade" ||
+		!isUpgradeRequest(req) ||
 		req.Header.Get("Upgrade") == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(http.StatusText(http.StatusBadRequest)))
 		return
 	}
 
+	m := p.metrics
+	if m == nil {
+		m = metrics.Void
+	}
+
 	backendConn, err := p.dialBackend(req)
 	if err != nil {
 		log.Errorf("Error connecting to backend: %s", err)
+		m.IncCounter(upgradeBackendErrorsKey)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		w.Write([]byte(http.StatusText(http.StatusServiceUnavailable)))
 		return
@@ -109,12 +147,17 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	resp, err := http.ReadResponse(bufio.NewReader(backendConn), req)
 	if err != nil {
 		log.Errorf("Error reading response from backend: %s", err)
+		m.IncCounter(upgradeBackendErrorsKey)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
 		return
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		m.IncCounter(upgradeBackendRejectedKey)
+	}
+
 	if resp.StatusCode == http.StatusUnauthorized {
 		log.Debugf("Got unauthorized error from backend for: %s %s", req.Method, req.URL)
 		w.WriteHeader(http.StatusUnauthorized)
@@ -150,19 +193,47 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	start := time.Now()
+	m.IncCounter(upgradeConnectionsKey)
+	if p.active != nil {
+		m.UpdateGauge(upgradeActiveKey, float64(atomic.AddInt64(p.active, 1)))
+		defer func() {
+			m.UpdateGauge(upgradeActiveKey, float64(atomic.AddInt64(p.active, -1)))
+		}()
+	}
+
+	lastActivity := start.UnixNano()
+	fromBackend := &activityReader{reader: backendConn, lastActivity: &lastActivity}
+	fromClient := &activityReader{reader: requestHijackedConn, lastActivity: &lastActivity}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	if p.useAuditLog {
-		copyAsync(&wg, backendConn, requestHijackedConn, p.auditLogOut)
+		copyAsync(&wg, fromBackend, requestHijackedConn, p.auditLogOut)
 	} else {
-		copyAsync(&wg, backendConn, requestHijackedConn)
+		copyAsync(&wg, fromBackend, requestHijackedConn)
 	}
 
-	copyAsync(&wg, requestHijackedConn, backendConn)
+	copyAsync(&wg, fromClient, backendConn)
 	log.Debugf("Successfully upgraded to protocol %s by user request", getUpgradeRequest(req))
-	// Wait for goroutine to finish, such that the established connection does not break.
-	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		// Wait for goroutine to finish, such that the established connection does not break.
+		wg.Wait()
+		close(done)
+	}()
+
+	if p.idleTimeout > 0 && waitIdle(done, &lastActivity, p.idleTimeout) {
+		log.Debugf("Closing idle upgraded connection for protocol %s", getUpgradeRequest(req))
+		m.IncCounter(upgradeIdleTimeoutsKey)
+		requestHijackedConn.Close()
+		backendConn.Close()
+	}
+
+	<-done
+	m.MeasureSince(upgradeDurationKey, start)
 
 	if p.useAuditLog {
 		select {
@@ -172,6 +243,25 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// waitIdle blocks until either done is closed, or no activity was recorded
+// for the idle timeout. It returns true in the latter case.
+func waitIdle(done <-chan struct{}, lastActivity *int64, timeout time.Duration) bool {
+	wait := timeout
+	for {
+		select {
+		case <-done:
+			return false
+		case <-time.After(wait):
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(lastActivity)))
+			if idle >= timeout {
+				return true
+			}
+
+			wait = timeout - idle
+		}
+	}
+}
+
 func (p *upgradeProxy) dialBackend(req *http.Request) (net.Conn, error) {
 	dialAddr := canonicalAddr(req.URL)
 
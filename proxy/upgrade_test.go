@@ -20,6 +20,8 @@ import (
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
 	"github.com/zalando/skipper/routing"
 	"github.com/zalando/skipper/routing/testdataclient"
 	"golang.org/x/net/websocket"
@@ -425,3 +427,99 @@ func TestAuditLogging(t *testing.T) {
 		}
 	}))
 }
+
+// recordingMetrics records only the counters, gauges and timers
+type recordingMetrics struct {
+	metrics.Metrics
+	mock *metricstest.MockMetrics
+}
+
+func (m recordingMetrics) IncCounter(key string) { m.mock.IncCounter(key) }
+
+func (m recordingMetrics) UpdateGauge(key string, v float64) { m.mock.UpdateGauge(key, v) }
+
+func (m recordingMetrics) MeasureSince(key string, start time.Time) { m.mock.MeasureSince(key, start) }
+
+func TestUpgradeIdleTimeout(t *testing.T) {
+	wss := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		io.Copy(ws, ws)
+	}))
+
+	defer wss.Close()
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		DataClients: []routing.DataClient{
+			testdataclient.New([]*eskip.Route{{Backend: wss.URL}}),
+		},
+		Log: tl,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metricstest.MockMetrics{}
+	p := WithParams(Params{
+		Routing:             rt,
+		ExperimentalUpgrade: true,
+		UpgradeIdleTimeout:  30 * time.Millisecond,
+	})
+	p.metrics = recordingMetrics{Metrics: metrics.Void, mock: m}
+	defer p.Close()
+
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	wsc, err := websocket.Dial(
+		strings.Replace(ps.URL, "http:", "ws:", 1),
+		"",
+		"http://[::1]",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer wsc.Close()
+
+	message := "foo"
+	if _, err := wsc.Write([]byte(message)); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := make([]byte, len(message))
+	if _, err := wsc.Read(receive); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, wsc)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+
+	// the metrics are updated after the connection was closed
+	time.Sleep(15 * time.Millisecond)
+	m.WithCounters(func(c map[string]int64) {
+		if c[upgradeConnectionsKey] != 1 {
+			t.Errorf("invalid number of upgraded connections: %d", c[upgradeConnectionsKey])
+		}
+
+		if c[upgradeIdleTimeoutsKey] != 1 {
+			t.Errorf("invalid number of idle timeouts: %d", c[upgradeIdleTimeoutsKey])
+		}
+	})
+
+	if v, ok := m.Gauge(upgradeActiveKey); !ok || v != 0 {
+		t.Errorf("invalid number of active upgraded connections: %v", v)
+	}
+}
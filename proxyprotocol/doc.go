@@ -0,0 +1,18 @@
+/*
+Package proxyprotocol implements the PROXY protocol versions 1 and 2, as
+specified by HAProxy:
+
+https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt
+
+The load balancers in front of Skipper can use the protocol to pass the
+address of the original client, which is otherwise lost when the TCP
+connections are terminated by the load balancer. The listener returned by
+NewListener reads the PROXY protocol header from the accepted connections,
+and reports the received addresses as the remote and local addresses of the
+connections.
+
+The backends behind Skipper can get the address of the original client the
+same way, with the header written by WriteHeader, when the connection to the
+backend is established.
+*/
+package proxyprotocol
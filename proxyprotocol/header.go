@@ -0,0 +1,249 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maximum length of a version 1 header, including the CRLF
+	maxV1Length = 107
+
+	v2Command      = 0x20
+	v2Local        = 0x0
+	v2Proxy        = 0x1
+	v2FamilyInet   = 0x10
+	v2FamilyInet6  = 0x20
+	v2Stream       = 0x1
+	v2Inet4Length  = 12
+	v2Inet6Length  = 36
+	v2HeaderLength = 16
+)
+
+var (
+	v1Signature = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// ErrInvalidHeader is returned when the connection doesn't start with
+	// a valid PROXY protocol header.
+	ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+	// ErrInvalidVersion is returned when writing a header with a version
+	// other than 1 or 2.
+	ErrInvalidVersion = errors.New("invalid PROXY protocol version")
+)
+
+// reads the PROXY protocol header. The returned addresses are nil, when
+// the header doesn't contain them, e.g. in case of UNKNOWN or LOCAL.
+func readHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	b, err := r.Peek(len(v1Signature))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(b, v1Signature) {
+		return readV1(r)
+	}
+
+	b, err = r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(b, v2Signature) {
+		return readV2(r)
+	}
+
+	return nil, nil, ErrInvalidHeader
+}
+
+func readV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+	var line []byte
+	for len(line) < maxV1Length {
+		var c byte
+		if c, err = r.ReadByte(); err != nil {
+			return nil, nil, err
+		}
+
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	if len(fields) != 6 {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	srcAddr, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dstAddr, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return srcAddr, dstAddr, nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	a := net.ParseIP(ip)
+	if a == nil {
+		return nil, ErrInvalidHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	return &net.TCPAddr{IP: a, Port: int(p)}, nil
+}
+
+func readV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+	h := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, nil, err
+	}
+
+	if h[12]&0xf0 != v2Command {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(h[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch h[12] & 0xf {
+	case v2Local:
+		return nil, nil, nil
+	case v2Proxy:
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	// only the TCP addresses are used, the others are ignored, together
+	// with the optional TLVs
+	if h[13]&0xf != v2Stream {
+		return nil, nil, nil
+	}
+
+	switch h[13] & 0xf0 {
+	case v2FamilyInet:
+		if len(payload) < v2Inet4Length {
+			return nil, nil, ErrInvalidHeader
+		}
+
+		return v2Addr(payload[0:4], payload[8:10]), v2Addr(payload[4:8], payload[10:12]), nil
+	case v2FamilyInet6:
+		if len(payload) < v2Inet6Length {
+			return nil, nil, ErrInvalidHeader
+		}
+
+		return v2Addr(payload[0:16], payload[32:34]), v2Addr(payload[16:32], payload[34:36]), nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+func v2Addr(ip, port []byte) *net.TCPAddr {
+	return &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), ip...)),
+		Port: int(binary.BigEndian.Uint16(port)),
+	}
+}
+
+// returns the source and destination TCP addresses, when both are set
+// and they have the same address family
+func tcpAddrs(src, dst net.Addr) (s, d *net.TCPAddr, ipv4, ok bool) {
+	s, sok := src.(*net.TCPAddr)
+	d, dok := dst.(*net.TCPAddr)
+	if !sok || !dok || s == nil || d == nil {
+		return nil, nil, false, false
+	}
+
+	s4, d4 := s.IP.To4() != nil, d.IP.To4() != nil
+	if s4 != d4 {
+		return nil, nil, false, false
+	}
+
+	return s, d, s4, true
+}
+
+// WriteHeader writes a PROXY protocol header of the given version, 1 or 2,
+// with the source and destination addresses. When the addresses are not
+// TCP addresses of the same family, it writes a header without addresses
+// (UNKNOWN in version 1 and LOCAL in version 2), and the receiver uses the
+// addresses of the connection.
+func WriteHeader(w io.Writer, version int, src, dst net.Addr) error {
+	s, d, ipv4, ok := tcpAddrs(src, dst)
+	switch version {
+	case 1:
+		if !ok {
+			_, err := io.WriteString(w, "PROXY UNKNOWN\r\n")
+			return err
+		}
+
+		proto := "TCP6"
+		if ipv4 {
+			proto = "TCP4"
+		}
+
+		_, err := fmt.Fprintf(w, "PROXY %s %s %s %d %d\r\n", proto, s.IP, d.IP, s.Port, d.Port)
+		return err
+	case 2:
+		h := make([]byte, v2HeaderLength, v2HeaderLength+v2Inet6Length)
+		copy(h, v2Signature)
+		if !ok {
+			h[12] = v2Command | v2Local
+			_, err := w.Write(h)
+			return err
+		}
+
+		h[12] = v2Command | v2Proxy
+		if ipv4 {
+			h[13] = v2FamilyInet | v2Stream
+			binary.BigEndian.PutUint16(h[14:], v2Inet4Length)
+			h = append(h, s.IP.To4()...)
+			h = append(h, d.IP.To4()...)
+		} else {
+			h[13] = v2FamilyInet6 | v2Stream
+			binary.BigEndian.PutUint16(h[14:], v2Inet6Length)
+			h = append(h, s.IP.To16()...)
+			h = append(h, d.IP.To16()...)
+		}
+
+		h = append(h, byte(s.Port>>8), byte(s.Port), byte(d.Port>>8), byte(d.Port))
+		_, err := w.Write(h)
+		return err
+	default:
+		return ErrInvalidVersion
+	}
+}
@@ -0,0 +1,108 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	ipv4Src := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324}
+	ipv4Dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	ipv6Src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	ipv6Dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+
+	for _, test := range []struct {
+		title    string
+		version  int
+		src, dst net.Addr
+		expected string
+	}{{
+		title:    "v1, IPv4",
+		version:  1,
+		src:      ipv4Src,
+		dst:      ipv4Dst,
+		expected: "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n",
+	}, {
+		title:    "v1, IPv6",
+		version:  1,
+		src:      ipv6Src,
+		dst:      ipv6Dst,
+		expected: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+	}, {
+		title:    "v1, mixed families",
+		version:  1,
+		src:      ipv4Src,
+		dst:      ipv6Dst,
+		expected: "PROXY UNKNOWN\r\n",
+	}, {
+		title:   "v2, IPv4",
+		version: 2,
+		src:     ipv4Src,
+		dst:     ipv4Dst,
+	}, {
+		title:   "v2, IPv6",
+		version: 2,
+		src:     ipv6Src,
+		dst:     ipv6Dst,
+	}, {
+		title:   "v2, no addresses",
+		version: 2,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteHeader(&b, test.version, test.src, test.dst); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expected != "" && b.String() != test.expected {
+				t.Errorf("invalid header, expected: %q, got: %q", test.expected, b.String())
+			}
+
+			b.WriteString("GET / HTTP/1.1\r\n")
+			r := bufio.NewReader(&b)
+			src, dst, err := readHeader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, _, ok := tcpAddrs(test.src, test.dst); ok {
+				if src.String() != test.src.String() || dst.String() != test.dst.String() {
+					t.Errorf("invalid addresses: %v, %v", src, dst)
+				}
+			} else if src != nil || dst != nil {
+				t.Errorf("unexpected addresses: %v, %v", src, dst)
+			}
+
+			if rest, _ := r.ReadString('\n'); rest != "GET / HTTP/1.1\r\n" {
+				t.Errorf("failed to preserve the data after the header: %q", rest)
+			}
+		})
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	for _, h := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.168.0.1 10.0.0.1 56324\r\n",
+		"PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\n",
+		"PROXY UDP4 192.168.0.1 10.0.0.1 56324 443\r\n",
+		"PROXY TCP4 foo 10.0.0.1 56324 443\r\n",
+		"PROXY TCP4 192.168.0.1 10.0.0.1 56324 65536\r\n",
+		"PROXY TCP4 192.168.0.1 10.0.0.1 56324 443" + strings.Repeat(" ", 100) + "\r\n",
+		"\r\n\r\n\x00\r\nQUIT\n\x11\x11\x00\x0c",
+		"\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x04\x00\x00\x00\x00",
+	} {
+		if _, _, err := readHeader(bufio.NewReader(strings.NewReader(h))); err == nil {
+			t.Errorf("failed to fail: %q", h)
+		}
+	}
+}
+
+func TestInvalidVersion(t *testing.T) {
+	if err := WriteHeader(&bytes.Buffer{}, 3, nil, nil); err != ErrInvalidVersion {
+		t.Errorf("failed to fail with invalid version: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultHeaderTimeout = 10 * time.Second
+
+// Options are used to initialize the listener.
+type Options struct {
+
+	// HeaderTimeout sets how long the listener waits for the PROXY
+	// protocol header of a connection. Defaults to 10 seconds.
+	HeaderTimeout time.Duration
+}
+
+type listener struct {
+	net.Listener
+	options Options
+}
+
+// Conn is a connection accepted by the PROXY protocol listener. The header
+// is read on the first call to Read, RemoteAddr or LocalAddr, so that the
+// listener doesn't block accepting the connections.
+type Conn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+	once          sync.Once
+	remote, local net.Addr
+	err           error
+}
+
+// NewListener wraps a listener, expecting every accepted connection to
+// start with a PROXY protocol header, either version 1 or 2. The
+// connections without a valid header fail on the first read.
+func NewListener(l net.Listener, o Options) net.Listener {
+	if o.HeaderTimeout <= 0 {
+		o.HeaderTimeout = defaultHeaderTimeout
+	}
+
+	return &listener{Listener: l, options: o}
+}
+
+// Accept accepts the next connection, and wraps it, so that the header is
+// read from the connection.
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:          c,
+		reader:        bufio.NewReader(c),
+		headerTimeout: l.options.HeaderTimeout,
+	}, nil
+}
+
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+		c.remote, c.local, c.err = readHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// Read reads from the connection, after the PROXY protocol header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address received in the PROXY protocol
+// header, or, when not received, the remote address of the connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address received in the PROXY
+// protocol header, or, when not received, the local address of the
+// connection.
+func (c *Conn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.local != nil {
+		return c.local
+	}
+
+	return c.Conn.LocalAddr()
+}
@@ -0,0 +1,95 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewListener(nl, Options{HeaderTimeout: 100 * time.Millisecond})
+	defer l.Close()
+
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+
+	t.Run("with header", func(t *testing.T) {
+		c, err := net.Dial("tcp", nl.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer c.Close()
+		if err := WriteHeader(c, 2, src, dst); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Write([]byte("Hello, world!\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		ac, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer ac.Close()
+		if ac.RemoteAddr().String() != src.String() || ac.LocalAddr().String() != dst.String() {
+			t.Errorf("invalid addresses: %v, %v", ac.RemoteAddr(), ac.LocalAddr())
+		}
+
+		if s, err := bufio.NewReader(ac).ReadString('\n'); err != nil || s != "Hello, world!\n" {
+			t.Errorf("failed to read the data: %q, %v", s, err)
+		}
+	})
+
+	t.Run("without header", func(t *testing.T) {
+		c, err := net.Dial("tcp", nl.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer c.Close()
+		if _, err := c.Write([]byte("Hello, world!\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		ac, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer ac.Close()
+		if ac.RemoteAddr().String() != c.LocalAddr().String() {
+			t.Errorf("invalid remote address: %v", ac.RemoteAddr())
+		}
+
+		if _, err := ac.Read(make([]byte, 32)); err != ErrInvalidHeader {
+			t.Errorf("failed to fail: %v", err)
+		}
+	})
+
+	t.Run("header timeout", func(t *testing.T) {
+		c, err := net.Dial("tcp", nl.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer c.Close()
+		ac, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer ac.Close()
+		if _, err := ac.Read(make([]byte, 32)); err == nil {
+			t.Error("failed to time out")
+		}
+	})
+}
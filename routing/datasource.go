@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
@@ -29,7 +30,15 @@ const (
 	headerRegexpName = "HeaderRegexp"
 )
 
-var errInvalidWeightParams = errors.New("invalid argument for the Weight predicate")
+// UnixScheme is the scheme of the backends listening on unix domain
+// sockets.
+const UnixScheme = "unix"
+
+var (
+	errInvalidWeightParams = errors.New("invalid argument for the Weight predicate")
+	errLoadTimeout         = errors.New("timeout while loading the routes from the data client")
+	errMissingUnixSocket   = errors.New("missing unix socket path in the backend address")
+)
 
 func (it incomingType) String() string {
 	switch it {
@@ -67,29 +76,96 @@ func (d *incomingData) log(l logging.Logger, suppress bool) {
 	}
 }
 
+type loadResult struct {
+	routes     []*eskip.Route
+	deletedIDs []string
+	err        error
+}
+
+// calls the data client in the background, and returns the result on the
+// returned channel
+func load(c DataClient, initial bool) <-chan loadResult {
+	result := make(chan loadResult, 1)
+	go func() {
+		var r loadResult
+		if initial {
+			r.routes, r.err = c.LoadAll()
+		} else {
+			r.routes, r.deletedIDs, r.err = c.LoadUpdate()
+		}
+
+		result <- r
+	}()
+
+	return result
+}
+
 // continuously receives route definitions from a data client on the the output channel.
 // The function does not return unless quit is closed. When started, it request for the
 // whole current set of routes, and continues polling for the subsequent updates. When a
 // communication error occurs, it re-requests the whole valid set, and continues polling.
 // Currently, the routes with the same id coming from different sources are merged in an
 // undeterministic way, but this may change in the future.
+//
+// When the data client implements UpdateNotifier, the next update is requested as soon
+// as it signals a change, or when the poll timeout is over, whichever happens first.
+//
+// When the load timeout is set, and a request to the data client takes longer, it is
+// handled as a communication error. Since the data clients are not expected to handle
+// concurrent requests, the abandoned request is awaited before the next one is started,
+// and in case it was a request for the whole set of routes, its result is used.
 func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <-chan struct{}) {
-	initial := true
-	for {
-		var (
-			routes     []*eskip.Route
-			deletedIDs []string
-			err        error
-		)
+	var (
+		initial        = true
+		pending        <-chan loadResult
+		pendingInitial bool
+		notify         <-chan struct{}
+	)
 
+	if n, ok := c.(UpdateNotifier); ok {
+		notify = n.UpdateNotify()
+	}
+
+	for {
 		to := o.PollTimeout
 
-		if initial {
-			routes, err = c.LoadAll()
-		} else {
-			routes, deletedIDs, err = c.LoadUpdate()
+		var result <-chan loadResult
+		switch {
+		case pending != nil && pendingInitial == initial:
+			result = pending
+		case pending != nil:
+			select {
+			case <-pending:
+			case <-quit:
+				return
+			}
+
+			result = load(c, initial)
+		default:
+			result = load(c, initial)
+		}
+
+		pending = nil
+
+		var timeout <-chan time.Time
+		if o.LoadTimeout > 0 {
+			timeout = time.After(o.LoadTimeout)
+		}
+
+		start := time.Now()
+		var r loadResult
+		select {
+		case r = <-result:
+		case <-timeout:
+			r.err = errLoadTimeout
+			pending, pendingInitial = result, initial
+		case <-quit:
+			return
 		}
 
+		routes, deletedIDs, err := r.routes, r.deletedIDs, r.err
+		routingMetrics{o.Metrics}.measureLoad(c, initial, start, err)
+
 		switch {
 		case err != nil && initial:
 			o.Log.Error("error while receiveing initial data;", err)
@@ -115,6 +191,7 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 
 		select {
 		case <-time.After(to):
+		case <-notify:
 		case <-quit:
 			return
 		}
@@ -160,15 +237,23 @@ func mergeDefs(defsByClient map[DataClient]routeDefs) []*eskip.Route {
 	return all
 }
 
+// merged route definitions from all the data clients
+type mergedDefs struct {
+	routes []*eskip.Route
+
+	// true when every data client delivered its initial set of routes
+	complete bool
+}
+
 // receives the initial set of the route definitiosn and their
 // updates from multiple data clients, merges them by route id
 // and sends the merged route definitions to the output channel.
 //
 // The active set of routes from last successful update are used until the
 // next successful update.
-func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
+func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan *mergedDefs {
 	in := make(chan *incomingData)
-	out := make(chan []*eskip.Route)
+	out := make(chan *mergedDefs)
 	defsByClient := make(map[DataClient]routeDefs)
 
 	for _, c := range o.DataClients {
@@ -188,8 +273,13 @@ func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
 			c := incoming.client
 			defsByClient[c] = applyIncoming(defsByClient[c], incoming)
 
+			merged := &mergedDefs{
+				routes:   mergeDefs(defsByClient),
+				complete: len(defsByClient) == len(o.DataClients),
+			}
+
 			select {
-			case out <- mergeDefs(defsByClient):
+			case out <- merged:
 			case <-quit:
 				return
 			}
@@ -207,12 +297,28 @@ func splitBackend(r *eskip.Route) (string, string, error) {
 		return "", "", nil
 	}
 
-	bu, err := url.ParseRequestURI(r.Backend)
+	return ParseBackendURL(r.Backend)
+}
+
+// ParseBackendURL parses the address of a network backend or a load
+// balanced endpoint, and returns its scheme and host. For the backends
+// listening on unix domain sockets, e.g. unix:///var/run/app.sock, the
+// host is the path of the socket.
+func ParseBackendURL(backend string) (scheme, host string, err error) {
+	bu, err := url.ParseRequestURI(backend)
 	if err != nil {
 		return "", "", err
 	}
 
-	return bu.Scheme, bu.Host, nil
+	if bu.Scheme != UnixScheme {
+		return bu.Scheme, bu.Host, nil
+	}
+
+	if bu.Path == "" {
+		return "", "", errMissingUnixSocket
+	}
+
+	return bu.Scheme, bu.Path, nil
 }
 
 // creates a filter instance based on its definition and its
@@ -485,9 +591,14 @@ func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *esk
 	return r, nil
 }
 
-// convert a slice of predicate specs to a map keyed by their names
-func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
+// merges the predicate registry and the slice of predicate specs to a map
+// keyed by their names
+func mapPredicates(pr PredicateRegistry, cps []PredicateSpec) map[string]PredicateSpec {
 	cpm := make(map[string]PredicateSpec)
+	for name, cp := range pr {
+		cpm[name] = cp
+	}
+
 	for _, cp := range cps {
 		cpm[cp.Name()] = cp
 	}
@@ -495,13 +606,50 @@ func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
 	return cpm
 }
 
-// processes a set of route definitions for the routing table
-func processRouteDefs(o Options, fr filters.Registry, defs []*eskip.Route) (routes []*Route, invalidDefs []*eskip.Route) {
-	cpm := mapPredicates(o.Predicates)
+// processed routes from the previous generation of the routing table,
+// by their id. The unchanged route definitions don't need to be processed
+// again, and they keep using the same filter and predicate instances,
+// which allows the matcher to apply the changes incrementally.
+type processedRoutes map[string]*processedRoute
+
+type processedRoute struct {
+	def   *eskip.Route
+	route *Route
+}
+
+// returns a copy of the previously processed route when the definition
+// didn't change. The copy shares the filter and predicate instances, but
+// the post processors can modify it without affecting the route in the
+// previous generation.
+func (p processedRoutes) get(def *eskip.Route) (*Route, bool) {
+	pr, ok := p[def.Id]
+	if !ok || pr.def != def && !eskip.Eq(pr.def, def) {
+		return nil, false
+	}
+
+	r := *pr.route
+	r.Filters = append([]*RouteFilter(nil), pr.route.Filters...)
+	return &r, true
+}
+
+// processes a set of route definitions for the routing table, reusing the
+// previously processed routes when their definition didn't change
+func processRouteDefs(o Options, fr filters.Registry, defs []*eskip.Route, previous processedRoutes) (routes []*Route, invalidDefs []*eskip.Route, processed processedRoutes) {
+	cpm := mapPredicates(o.PredicateRegistry, o.Predicates)
+	processed = make(processedRoutes, len(defs))
 	for _, def := range defs {
+		if route, ok := previous.get(def); ok {
+			processed[def.Id] = previous[def.Id]
+			routes = append(routes, route)
+			continue
+		}
+
 		route, err := processRouteDef(cpm, fr, def)
 		if err == nil {
-			routes = append(routes, route)
+			processed[def.Id] = &processedRoute{def: def, route: route}
+			r := *route
+			r.Filters = append([]*RouteFilter(nil), route.Filters...)
+			routes = append(routes, &r)
 		} else {
 			invalidDefs = append(invalidDefs, def)
 			o.Log.Errorf("failed to process route (%v): %v", def.Id, err)
@@ -515,6 +663,31 @@ type routeTable struct {
 	validRoutes   []*eskip.Route
 	invalidRoutes []*eskip.Route
 	created       time.Time
+
+	// true when the routes of every data client are included
+	complete bool
+}
+
+// checks a freshly built routing table, when the validation gate is
+// enabled, or update checks are configured
+func validateUpdate(o Options, m *matcher, validRoutes, invalidRoutes []*eskip.Route) error {
+	if o.RejectInvalidUpdates && len(invalidRoutes) > 0 {
+		ids := make([]string, len(invalidRoutes))
+		for i, r := range invalidRoutes {
+			ids[i] = r.Id
+		}
+
+		return fmt.Errorf("invalid routes: %s", strings.Join(ids, ", "))
+	}
+
+	lookup := &RouteLookup{matcher: m}
+	for _, check := range o.UpdateChecks {
+		if err := check(lookup, validRoutes); err != nil {
+			return fmt.Errorf("update check failed: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // receives the next version of the routing table on the output channel,
@@ -524,25 +697,30 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 	var (
 		rt           *routeTable
 		outRelay     chan<- *routeTable
-		updatesRelay <-chan []*eskip.Route
+		updatesRelay <-chan *mergedDefs
+		processed    processedRoutes
+		m            = newEmptyMatcher(o.MatchingOptions)
+		rm           = routingMetrics{o.Metrics}
 	)
 	updatesRelay = updates
 	for {
 		select {
-		case defs := <-updatesRelay:
+		case merged := <-updatesRelay:
 			o.Log.Info("route settings received")
+			start := time.Now()
+			defs := merged.routes
 
 			for i := range o.PreProcessors {
 				defs = o.PreProcessors[i].Do(defs)
 			}
 
-			routes, invalidRoutes := processRouteDefs(o, o.FilterRegistry, defs)
+			routes, invalidRoutes, nextProcessed := processRouteDefs(o, o.FilterRegistry, defs, processed)
 
 			for i := range o.PostProcessors {
 				routes = o.PostProcessors[i].Do(routes)
 			}
 
-			m, errs := newMatcher(routes, o.MatchingOptions)
+			nextMatcher, errs := m.update(routes)
 
 			invalidRouteIds := make(map[string]struct{})
 			validRoutes := []*eskip.Route{}
@@ -564,11 +742,22 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 				return validRoutes[i].Id < validRoutes[j].Id
 			})
 
+			if err := validateUpdate(o, nextMatcher, validRoutes, invalidRoutes); err != nil {
+				// keeping the previous routing table, and the state
+				// of the incremental updates, too
+				o.Log.Errorf("routing table update rejected: %v", err)
+				rm.updateRejected(start, len(invalidRoutes))
+				continue
+			}
+
+			rm.updateApplied(start, len(validRoutes), len(invalidRoutes))
+			m, processed = nextMatcher, nextProcessed
 			rt = &routeTable{
 				m:             m,
 				validRoutes:   validRoutes,
 				invalidRoutes: invalidRoutes,
 				created:       time.Now().UTC(),
+				complete:      merged.complete,
 			}
 			updatesRelay = nil
 			outRelay = out
@@ -1,9 +1,11 @@
 package routing
 
 import (
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/logging/loggingtest"
@@ -205,3 +207,110 @@ func TestLogging(t *testing.T) {
 		)
 	})
 }
+
+// a data client that returns the pending update on every poll, and
+// signals when it has one
+type notifyingClient struct {
+	mx      sync.Mutex
+	initial []*eskip.Route
+	update  []*eskip.Route
+	notify  chan struct{}
+}
+
+func (c *notifyingClient) LoadAll() ([]*eskip.Route, error) {
+	return c.initial, nil
+}
+
+func (c *notifyingClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	u := c.update
+	c.update = nil
+	return u, nil, nil
+}
+
+func (c *notifyingClient) UpdateNotify() <-chan struct{} {
+	return c.notify
+}
+
+func TestUpdateNotify(t *testing.T) {
+	initial, err := eskip.Parse(`foo: Path("/foo") -> "https://foo.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := eskip.Parse(`bar: Path("/bar") -> "https://bar.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &notifyingClient{initial: initial, notify: make(chan struct{}, 1)}
+
+	testLog := loggingtest.New()
+	defer testLog.Close()
+
+	rt := New(Options{
+		DataClients: []DataClient{client},
+		Log:         testLog,
+		PollTimeout: time.Hour,
+	})
+	defer rt.Close()
+
+	if err := testLog.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	testLog.Reset()
+	client.mx.Lock()
+	client.update = update
+	client.mx.Unlock()
+	if err := testLog.WaitFor("route settings applied", 120*time.Millisecond); err == nil {
+		t.Fatal("unexpected update before the poll timeout")
+	}
+
+	client.notify <- struct{}{}
+	if err := testLog.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal("failed to receive the update after the notification", err)
+	}
+}
+
+func TestParseBackendURL(t *testing.T) {
+	for _, test := range []struct {
+		backend        string
+		scheme, host   string
+		expectingError bool
+	}{{
+		backend: "https://www.example.org:8443",
+		scheme:  "https",
+		host:    "www.example.org:8443",
+	}, {
+		backend: "unix:///var/run/app.sock",
+		scheme:  "unix",
+		host:    "/var/run/app.sock",
+	}, {
+		backend:        "unix://",
+		expectingError: true,
+	}, {
+		backend:        "www.example.org",
+		expectingError: true,
+	}} {
+		t.Run(test.backend, func(t *testing.T) {
+			scheme, host, err := ParseBackendURL(test.backend)
+			if test.expectingError {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if scheme != test.scheme || host != test.host {
+				t.Errorf("invalid scheme and host: %s, %s", scheme, host)
+			}
+		})
+	}
+}
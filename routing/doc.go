@@ -16,6 +16,11 @@ met.
 (The regular expression conditions for the path, 'PathRegexp', are
 applied only in step 2.)
 
+To debug which routes were considered for a request, and why a route
+won over the others, the Explain method reports the evaluated routes in
+their order of priority, together with the result of each of their
+conditions.
+
 The matching conditions and the built-in filters that use regular
 expressions, use the go stdlib regexp, which uses re2:
 
@@ -91,9 +96,23 @@ updates, and, if an update is received, generates a new lookup tree. In
 case of communication failure during polling, it reloads the whole set
 of routes from the failing client.
 
+The new lookup tree is not built from scratch. The routes whose
+definition didn't change keep their filter and predicate instances, and
+only the changed routes are inserted into or removed from the lookup
+tree. The tree is updated with copy-on-write, sharing the unchanged
+parts with the previous version, so that the requests in flight can
+still use the previous version, while the update is applied.
+
 The active set of routes from the last successful update are used until
 the next successful update happens.
 
+By default, the invalid routes of an update are skipped, and the rest of
+the routes are applied. With the RejectInvalidUpdates option, an update
+containing an invalid route is rejected as a whole, and with the
+UpdateChecks option, custom checks, e.g. test requests, can be executed
+against the new routing table, before it gets applied. In both cases, the
+previous routing table stays active, when an update is rejected.
+
 Currently, the routes with the same id coming from different sources are
 merged in an nondeterministic way, but this behavior may change in the
 future.
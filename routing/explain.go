@@ -0,0 +1,234 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dimfeld/httppath"
+	"github.com/zalando/skipper/eskip"
+)
+
+// ConditionResult describes the result of evaluating a single matching
+// condition of a route.
+type ConditionResult struct {
+	Condition string `json:"condition"`
+	Matched   bool   `json:"matched"`
+}
+
+// Candidate describes a route that was considered during the lookup.
+type Candidate struct {
+
+	// RouteID is the id of the candidate route.
+	RouteID string `json:"routeId"`
+
+	// Weight is the priority of the route among the routes with the
+	// same path condition. The routes with higher weight are evaluated
+	// first.
+	Weight int `json:"weight"`
+
+	// Evaluated is false when the route was not evaluated, because a
+	// route with higher priority and the same path condition matched
+	// the request.
+	Evaluated bool `json:"evaluated"`
+
+	// Matched is true when every condition of the route was met.
+	Matched bool `json:"matched"`
+
+	// Conditions contains the results of the individual conditions of
+	// the route, when it was evaluated.
+	Conditions []ConditionResult `json:"conditions,omitempty"`
+}
+
+// Explanation describes how a request was matched against the routing
+// table.
+type Explanation struct {
+
+	// Route is the matched route, or nil, when none of the routes
+	// matched.
+	Route *Route `json:"-"`
+
+	// RouteID is the id of the matched route.
+	RouteID string `json:"routeId,omitempty"`
+
+	// Params contains the path parameters of the matched route.
+	Params map[string]string `json:"params,omitempty"`
+
+	// Candidates lists the routes that were considered during the
+	// lookup, in the order of evaluation.
+	Candidates []*Candidate `json:"candidates"`
+}
+
+type explainRequestMatcher struct {
+	leafRequestMatcher
+	explanation *Explanation
+}
+
+func (m *explainRequestMatcher) Match(value interface{}) (bool, interface{}) {
+	v, ok := value.(*pathMatcher)
+	if !ok {
+		return false, nil
+	}
+
+	l := explainLeaves(m.explanation, v.leaves, m.r, m.path, m.exactPath)
+	return l != nil, l
+}
+
+func conditionString(name string, args ...interface{}) string {
+	sargs := make([]string, len(args))
+	for i, a := range args {
+		if s, ok := a.(string); ok {
+			sargs[i] = strconv.Quote(s)
+		} else {
+			sargs[i] = fmt.Sprint(a)
+		}
+	}
+
+	return fmt.Sprintf("%s(%s)", name, strings.Join(sargs, ", "))
+}
+
+// returns the names of the custom predicates of a route, in the order of
+// the predicate instances. See processPredicates.
+func customPredicateNames(r *Route) []string {
+	var names []string
+	for _, p := range r.Route.Predicates {
+		if p.Name == "Weight" || isTreePredicate(p.Name) {
+			continue
+		}
+
+		names = append(names, conditionString(p.Name, p.Args...))
+	}
+
+	return names
+}
+
+// evaluates every condition of a leaf matcher, the same way as matchLeaf,
+// but without stopping at the first failing one
+func explainLeaf(l *leafMatcher, req *http.Request, path, exactPath string) *Candidate {
+	c := &Candidate{
+		RouteID:   l.route.Id,
+		Weight:    l.priority,
+		Evaluated: true,
+		Matched:   true,
+	}
+
+	check := func(condition string, matched bool) {
+		c.Conditions = append(c.Conditions, ConditionResult{Condition: condition, Matched: matched})
+		c.Matched = c.Matched && matched
+	}
+
+	switch {
+	case l.route.path != "":
+		check(conditionString(PathName, l.route.path), true)
+	case l.route.pathSubtree != "":
+		check(conditionString(PathSubtreeName, l.route.pathSubtree), true)
+	}
+
+	if l.exactPath != "" {
+		check(conditionString("ExactPath", l.exactPath), l.exactPath == path)
+	}
+
+	if l.method != "" {
+		check(conditionString(methodName, l.method), l.method == req.Method)
+	}
+
+	for _, rx := range l.hostRxs {
+		check(conditionString(hostRegexpName, rx.String()), rx.MatchString(req.Host))
+	}
+
+	for _, rx := range l.pathRxs {
+		check(conditionString(pathRegexpName, rx.String()), rx.MatchString(exactPath))
+	}
+
+	for _, k := range eskip.SortedKeys(l.headersExact) {
+		v := l.headersExact[k]
+		check(conditionString(headerName, k, v), matchHeader(req.Header, k, func(val string) bool { return val == v }))
+	}
+
+	for _, k := range eskip.SortedKeys(l.headersRegexp) {
+		for _, rx := range l.headersRegexp[k] {
+			check(conditionString(headerRegexpName, k, rx.String()), matchHeader(req.Header, k, rx.MatchString))
+		}
+	}
+
+	names := customPredicateNames(l.route)
+	for i, p := range l.predicates {
+		name := fmt.Sprintf("predicate #%d", i)
+		if len(names) == len(l.predicates) {
+			name = names[i]
+		}
+
+		check(name, p.Match(req))
+	}
+
+	return c
+}
+
+// evaluates a set of leaf matchers and records the candidates, while
+// returning the same result as matchLeaves
+func explainLeaves(e *Explanation, leaves leafMatchers, req *http.Request, path, exactPath string) *leafMatcher {
+	var found *leafMatcher
+	for _, l := range leaves {
+		if found != nil {
+			e.Candidates = append(e.Candidates, &Candidate{RouteID: l.route.Id, Weight: l.priority})
+			continue
+		}
+
+		c := explainLeaf(l, req, path, exactPath)
+		e.Candidates = append(e.Candidates, c)
+		if c.Matched {
+			found = l
+		}
+	}
+
+	return found
+}
+
+// explains the matching of a request, following the same steps as the
+// match method
+func (m *matcher) explain(r *http.Request) *Explanation {
+	path := httppath.Clean(r.URL.Path)
+	exact := path
+	if m.matchingOptions.ignoreTrailingSlash() {
+		path = trimTrailingSlash(path)
+	}
+
+	e := &Explanation{}
+	erm := &explainRequestMatcher{
+		leafRequestMatcher: leafRequestMatcher{r: r, path: path, exactPath: exact},
+		explanation:        e,
+	}
+
+	params, l := matchPathTree(m.paths, path, erm)
+	if l == nil {
+		l = explainLeaves(e, m.rootLeaves, r, path, exact)
+	}
+
+	if l != nil {
+		e.Route = l.route
+		e.RouteID = l.route.Id
+		e.Params = params
+	}
+
+	return e
+}
+
+// Explain reports how a request would be matched in the current routing
+// tree: which routes were considered, in which order, which of their
+// conditions were met, and which route won. It is meant for debugging
+// the precedence of the routes, and it is more expensive than Route.
+//
+// Note that the custom predicates of every considered route are
+// evaluated, even when one of the other conditions of the same route
+// failed.
+func (r *Routing) Explain(req *http.Request) *Explanation {
+	rt := r.routeTable.Load().(*routeTable)
+	return rt.m.explain(req)
+}
+
+// Explain reports how a request would be matched against the captured
+// routing table. See Routing.Explain.
+func (rl *RouteLookup) Explain(req *http.Request) *Explanation {
+	return rl.matcher.explain(req)
+}
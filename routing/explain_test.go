@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	rs, err := docToRoutes(`
+		v1: Path("/api/:resource") -> "https://v1.example.org";
+		v2: Path("/api/:resource") && Header("X-Version", "2") -> "https://v2.example.org";
+		v3: Path("/api/:resource") && Header("X-Version", "3") && Method("POST") -> "https://v3.example.org";
+		custom: Path("/custom") && True() -> "https://custom.example.org";
+		catchAll: Host(/^www[.]example[.]org$/) -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, errs := newMatcher(rs, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs[0])
+	}
+
+	for _, ti := range []struct {
+		msg        string
+		method     string
+		host       string
+		path       string
+		version    string
+		expected   string
+		params     map[string]string
+		candidates []*Candidate
+	}{{
+		msg:      "weight decides",
+		method:   "GET",
+		path:     "/api/foo",
+		version:  "2",
+		expected: "v2",
+		params:   map[string]string{"resource": "foo"},
+		candidates: []*Candidate{{
+			RouteID:   "v3",
+			Weight:    2,
+			Evaluated: true,
+			Conditions: []ConditionResult{
+				{`Path("/api/:resource")`, true},
+				{`Method("POST")`, false},
+				{`Header("X-Version", "3")`, false},
+			},
+		}, {
+			RouteID:   "v2",
+			Weight:    1,
+			Evaluated: true,
+			Matched:   true,
+			Conditions: []ConditionResult{
+				{`Path("/api/:resource")`, true},
+				{`Header("X-Version", "2")`, true},
+			},
+		}, {
+			RouteID: "v1",
+		}},
+	}, {
+		msg:      "custom predicate",
+		method:   "GET",
+		path:     "/custom",
+		expected: "custom",
+		params:   map[string]string{},
+		candidates: []*Candidate{{
+			RouteID:   "custom",
+			Weight:    1,
+			Evaluated: true,
+			Matched:   true,
+			Conditions: []ConditionResult{
+				{`Path("/custom")`, true},
+				{`True()`, true},
+			},
+		}},
+	}, {
+		msg:    "no match",
+		method: "GET",
+		host:   "api.example.org",
+		path:   "/foo",
+		candidates: []*Candidate{{
+			RouteID:   "catchAll",
+			Weight:    1,
+			Evaluated: true,
+			Conditions: []ConditionResult{
+				{`Host("^www[.]example[.]org$")`, false},
+			},
+		}},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			req := &http.Request{
+				Method: ti.method,
+				Host:   ti.host,
+				URL:    &url.URL{Path: ti.path},
+				Header: http.Header{"X-Version": []string{ti.version}},
+			}
+
+			e := m.explain(req)
+			if e.RouteID != ti.expected {
+				t.Errorf("unexpected route, got: %s, expected: %s", e.RouteID, ti.expected)
+			}
+
+			if r, _ := m.match(req); r != e.Route {
+				t.Error("the explanation and the match are different")
+			}
+
+			if !reflect.DeepEqual(e.Params, ti.params) {
+				t.Errorf("unexpected params, got: %v, expected: %v", e.Params, ti.params)
+			}
+
+			if !reflect.DeepEqual(e.Candidates, ti.candidates) {
+				t.Error("unexpected candidates")
+				for _, c := range e.Candidates {
+					t.Log(c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the weight of the latest request in the moving averages of the error
+// rate and the latency
+const lbStatsDecay = 0.1
+
+// LBEndpointState tracks the in-flight requests, the failures and the
+// latency of a load balanced endpoint, as reported by the proxy. It is used
+// by the load balancing algorithms to prefer the less loaded endpoints,
+// and to eject the unhealthy ones. The methods can be called on a nil
+// state.
+type LBEndpointState struct {
+	inFlight int64
+
+	mx    sync.Mutex
+	stats LBEndpointStats
+}
+
+// LBEndpointStats is a snapshot of the state of a load balanced endpoint.
+type LBEndpointStats struct {
+
+	// InFlight is the number of the requests in progress.
+	InFlight int64
+
+	// Requests is the number of the finished requests since the
+	// endpoint was created or last ejected.
+	Requests int64
+
+	// ConsecutiveFailures is the number of the failed requests since
+	// the last successful one.
+	ConsecutiveFailures int64
+
+	// LastFailure is the time of the last failed request.
+	LastFailure time.Time
+
+	// ErrorRate is the exponentially weighted moving average of the
+	// failed requests, between 0 and 1.
+	ErrorRate float64
+
+	// Latency is the exponentially weighted moving average of the
+	// latency of the successful requests.
+	Latency time.Duration
+
+	// EjectedUntil is the end of the last ejection of the endpoint.
+	EjectedUntil time.Time
+
+	// Ejections is the number of the ejections, since the endpoint was
+	// last reset.
+	Ejections int
+
+	// Unhealthy is set when the endpoint failed the active health
+	// checks.
+	Unhealthy bool
+}
+
+// Started records a request sent to the endpoint.
+func (s *LBEndpointState) Started() {
+	if s != nil {
+		atomic.AddInt64(&s.inFlight, 1)
+	}
+}
+
+// Done records a finished request to the endpoint, whether it has failed,
+// and its latency. A successful request resets the consecutive failures.
+func (s *LBEndpointState) Done(failed bool, latency time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.inFlight, -1)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	st := &s.stats
+	st.Requests++
+
+	var e float64
+	if failed {
+		e = 1
+		st.ConsecutiveFailures++
+		st.LastFailure = time.Now()
+	} else {
+		st.ConsecutiveFailures = 0
+		if st.Latency == 0 {
+			st.Latency = latency
+		} else {
+			st.Latency += time.Duration(lbStatsDecay * float64(latency-st.Latency))
+		}
+	}
+
+	if st.Requests == 1 {
+		st.ErrorRate = e
+	} else {
+		st.ErrorRate += lbStatsDecay * (e - st.ErrorRate)
+	}
+}
+
+// Stats returns a snapshot of the state of the endpoint.
+func (s *LBEndpointState) Stats() LBEndpointStats {
+	if s == nil {
+		return LBEndpointStats{}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	st := s.stats
+	st.InFlight = atomic.LoadInt64(&s.inFlight)
+	return st
+}
+
+// InFlight returns the number of the requests currently in progress to
+// the endpoint.
+func (s *LBEndpointState) InFlight() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Eject marks the endpoint as ejected until the given time, and resets
+// the statistics of the requests, so that the endpoint is evaluated
+// from scratch when it is reintroduced.
+func (s *LBEndpointState) Eject(until time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.stats = LBEndpointStats{
+		EjectedUntil: until,
+		Ejections:    s.stats.Ejections + 1,
+		Unhealthy:    s.stats.Unhealthy,
+	}
+}
+
+// SetUnhealthy records the result of the active health checks of the
+// endpoint.
+func (s *LBEndpointState) SetUnhealthy(unhealthy bool) {
+	if s == nil {
+		return
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.stats.Unhealthy = unhealthy
+}
+
+// ResetEjections resets the number of the ejections of the endpoint.
+func (s *LBEndpointState) ResetEjections() {
+	if s == nil {
+		return
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.stats.Ejections = 0
+}
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/dimfeld/httppath"
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/pathmux"
 )
 
@@ -34,6 +36,7 @@ type leafMatcher struct {
 	exactPath            string
 	method               string
 	weight               int
+	priority             int
 	hostRxs              []*regexp.Regexp
 	pathRxs              []*regexp.Regexp
 	headersExact         map[string]string
@@ -54,16 +57,36 @@ func leafWeight(l *leafMatcher) int {
 	w += len(l.hostRxs)
 	w += len(l.pathRxs)
 	w += len(l.headersExact)
-	w += len(l.headersRegexp)
+	for _, rxs := range l.headersRegexp {
+		w += len(rxs)
+	}
+
 	w += len(l.predicates)
 
 	return w
 }
 
-// Sorting of leaf matchers:
-func (ls leafMatchers) Len() int           { return len(ls) }
-func (ls leafMatchers) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
-func (ls leafMatchers) Less(i, j int) bool { return leafWeight(ls[i]) > leafWeight(ls[j]) }
+// returns the priority of a leaf among the leaves with the same path,
+// based on the precedence model set in the matching options
+func leafPriority(l *leafMatcher, o MatchingOptions) int {
+	if o.explicitWeightPrecedence() {
+		return l.weight
+	}
+
+	return leafWeight(l)
+}
+
+// Sorting of leaf matchers, by priority, and in case of equal
+// priority, by route id:
+func (ls leafMatchers) Len() int      { return len(ls) }
+func (ls leafMatchers) Swap(i, j int) { ls[i], ls[j] = ls[j], ls[i] }
+func (ls leafMatchers) Less(i, j int) bool {
+	if ls[i].priority != ls[j].priority {
+		return ls[i].priority > ls[j].priority
+	}
+
+	return ls[i].route.Id < ls[j].route.Id
+}
 
 type pathMatcher struct {
 	leaves leafMatchers
@@ -74,6 +97,10 @@ type matcher struct {
 	paths           *pathmux.Tree
 	rootLeaves      leafMatchers
 	matchingOptions MatchingOptions
+
+	// used for the incremental updates, see the update method
+	pathMatchers  map[string]*pathMatcher
+	registrations map[string][]*registration
 }
 
 // An error created if a route definition cannot be processed.
@@ -256,46 +283,191 @@ func trimTrailingSlash(path string) string {
 	return path
 }
 
-// add each path matcher to the path tree. If a matcher is a subtree, add it with the
-// additional paths.
-func addTreeMatchers(pathTree *pathmux.Tree, matchers map[string]*pathMatcher) []*definitionError {
-	var errors []*definitionError
-	for p, m := range matchers {
+// returns the paths in the path tree where a leaf of a subtree route needs
+// to be added
+func subtreePaths(path string, o MatchingOptions) []string {
+	basePath := freeWildcardRx.ReplaceAllLiteralString(path, "")
+	basePath = strings.TrimSuffix(basePath, "/")
+	if basePath == "" {
+		return []string{"/", "/**"}
+	}
 
-		// sort leaves during construction time, based on their priority
-		sort.Stable(m.leaves)
+	if o.ignoreTrailingSlash() {
+		return []string{basePath, basePath + "/**"}
+	}
 
-		if err := pathTree.Add(p, m); err != nil {
-			errors = append(errors, &definitionError{Index: -1, Original: err})
+	return []string{basePath, basePath + "/**", basePath + "/"}
+}
+
+// returns the paths in the path tree where the leaf of a route needs to be
+// added. When the route doesn't have a path condition, it returns nil, and
+// the leaf goes to the root leaves.
+func leafPaths(r *Route, o MatchingOptions) ([]string, error) {
+	if r.path == "" && r.pathSubtree == "" {
+		return nil, nil
+	}
+
+	path, err := normalizePath(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pathSubtree != "" {
+		return subtreePaths(path, o), nil
+	}
+
+	if o.ignoreTrailingSlash() {
+		path = trimTrailingSlash(path)
+	}
+
+	return []string{path}, nil
+}
+
+// compares the filter or predicate instances without panicking on
+// non-comparable types
+func sameInstance(a, b interface{}) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || ta != nil && !ta.Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// tells whether a route can keep its leaf in the next generation of the
+// matcher. It is the case, when the route was created from the same
+// definition, and it shares the filter and predicate instances with the
+// previous version. See also the processing cache in the datasource.
+func sameRoute(a, b *Route) bool {
+	if a == b {
+		return true
+	}
+
+	if a.weight != b.weight ||
+		a.path != b.path ||
+		a.pathSubtree != b.pathSubtree ||
+		a.Scheme != b.Scheme ||
+		a.Host != b.Host ||
+		len(a.Predicates) != len(b.Predicates) ||
+		len(a.Filters) != len(b.Filters) ||
+		len(a.LBEndpoints) != len(b.LBEndpoints) {
+		return false
+	}
+
+	for i := range a.Predicates {
+		if !sameInstance(a.Predicates[i], b.Predicates[i]) {
+			return false
 		}
 	}
 
-	return errors
+	for i := range a.Filters {
+		if a.Filters[i].Name != b.Filters[i].Name ||
+			a.Filters[i].Index != b.Filters[i].Index ||
+			!sameInstance(a.Filters[i].Filter, b.Filters[i].Filter) {
+			return false
+		}
+	}
+
+	for i := range a.LBEndpoints {
+		if a.LBEndpoints[i] != b.LBEndpoints[i] {
+			return false
+		}
+	}
+
+	return eskip.Eq(&a.Route, &b.Route)
 }
 
-func addLeafToPath(pms map[string]*pathMatcher, path string, l *leafMatcher) {
-	pm, ok := pms[path]
-	if !ok {
-		pm = &pathMatcher{}
-		pms[path] = pm
+// registration of a route in the matcher, used to apply the subsequent
+// updates incrementally
+type registration struct {
+	route *Route
+	leaf  *leafMatcher
+
+	// the paths in the path tree where the leaf was added, nil for the
+	// root leaves
+	paths []string
+
+	// set when the route was rejected by the matcher
+	err error
+}
+
+// collects the changes of an update, before applying them to the path
+// tree and the root leaves
+type matcherChanges struct {
+	removed     map[*leafMatcher]bool
+	added       map[string]leafMatchers
+	addedRoot   leafMatchers
+	dirtyPaths  map[string]bool
+	dirtyRoot   bool
+	compiledRxs map[string]*regexp.Regexp
+}
+
+func (c *matcherChanges) remove(reg *registration) {
+	if reg.err != nil {
+		return
+	}
+
+	c.removed[reg.leaf] = true
+	if reg.paths == nil {
+		c.dirtyRoot = true
+		return
 	}
 
-	pm.leaves = append(pm.leaves, l)
+	for _, p := range reg.paths {
+		c.dirtyPaths[p] = true
+	}
 }
 
-func addSubtreeLeafsToPath(pms map[string]*pathMatcher, path string, l *leafMatcher, o MatchingOptions) {
-	basePath := freeWildcardRx.ReplaceAllLiteralString(path, "")
-	basePath = strings.TrimSuffix(basePath, "/")
-	if basePath == "" {
-		addLeafToPath(pms, "/", l)
-		addLeafToPath(pms, "/**", l)
-	} else {
-		addLeafToPath(pms, basePath, l)
-		addLeafToPath(pms, basePath+"/**", l)
-		if !o.ignoreTrailingSlash() {
-			addLeafToPath(pms, basePath+"/", l)
+func (c *matcherChanges) add(r *Route, o MatchingOptions) *registration {
+	reg := &registration{route: r}
+	reg.leaf, reg.err = newLeaf(r, c.compiledRxs)
+	if reg.err != nil {
+		return reg
+	}
+
+	reg.leaf.priority = leafPriority(reg.leaf, o)
+
+	reg.paths, reg.err = leafPaths(r, o)
+	if reg.err != nil {
+		return reg
+	}
+
+	if reg.paths == nil {
+		c.addedRoot = append(c.addedRoot, reg.leaf)
+		c.dirtyRoot = true
+		return reg
+	}
+
+	for _, p := range reg.paths {
+		c.added[p] = append(c.added[p], reg.leaf)
+		c.dirtyPaths[p] = true
+	}
+
+	return reg
+}
+
+// returns a new list of leaves without the removed ones and with the
+// added ones, sorted by their priority
+func (c *matcherChanges) applyLeaves(leaves, added leafMatchers) leafMatchers {
+	var next leafMatchers
+	for _, l := range leaves {
+		if !c.removed[l] {
+			next = append(next, l)
 		}
 	}
+
+	next = append(next, added...)
+	sort.Stable(next)
+	return next
+}
+
+func newEmptyMatcher(o MatchingOptions) *matcher {
+	return &matcher{
+		paths:           &pathmux.Tree{},
+		matchingOptions: o,
+		pathMatchers:    make(map[string]*pathMatcher),
+		registrations:   make(map[string][]*registration),
+	}
 }
 
 // constructs a matcher based on the provided definitions.
@@ -310,54 +482,126 @@ func addSubtreeLeafsToPath(pms map[string]*pathMatcher, path string, l *leafMatc
 // on the rest of the conditions so that most strict route
 // definition matches first.
 func newMatcher(rs []*Route, o MatchingOptions) (*matcher, []*definitionError) {
-	var (
-		errors     []*definitionError
-		rootLeaves leafMatchers
-	)
+	return newEmptyMatcher(o).update(rs)
+}
+
+// creates the next generation of the matcher from the complete set of
+// the current routes, without modifying the current matcher, that may
+// be in use.
+//
+// Only the routes that changed compared to the previous generation, are
+// processed. The path tree is updated with copy-on-write, so the next
+// generation shares the unchanged parts of the tree, and only the leaf
+// matchers of the affected paths are sorted again.
+func (m *matcher) update(rs []*Route) (*matcher, []*definitionError) {
+	var errors []*definitionError
+
+	next := &matcher{
+		paths:           m.paths,
+		rootLeaves:      m.rootLeaves,
+		matchingOptions: m.matchingOptions,
+		pathMatchers:    make(map[string]*pathMatcher, len(m.pathMatchers)),
+		registrations:   make(map[string][]*registration, len(rs)),
+	}
+
+	for p, pm := range m.pathMatchers {
+		next.pathMatchers[p] = pm
+	}
 
-	pathMatchers := make(map[string]*pathMatcher)
-	compiledRxs := make(map[string]*regexp.Regexp)
+	c := &matcherChanges{
+		removed:     make(map[*leafMatcher]bool),
+		added:       make(map[string]leafMatchers),
+		dirtyPaths:  make(map[string]bool),
+		compiledRxs: make(map[string]*regexp.Regexp),
+	}
 
+	// the indexes of the routes grouped by their id, to handle the
+	// repeated ids, too
+	byID := make(map[string][]int)
+	var ids []string
 	for i, r := range rs {
-		l, err := newLeaf(r, compiledRxs)
-		if err != nil {
-			errors = append(errors, &definitionError{r.Id, i, err})
-			continue
+		if _, ok := byID[r.Id]; !ok {
+			ids = append(ids, r.Id)
 		}
 
-		path, err := normalizePath(r)
-		if err != nil {
-			errors = append(errors, &definitionError{r.Id, i, err})
-			continue
+		byID[r.Id] = append(byID[r.Id], i)
+	}
+
+	for _, id := range ids {
+		current, previous := byID[id], m.registrations[id]
+		unchanged := len(current) == len(previous)
+		for i := 0; unchanged && i < len(current); i++ {
+			unchanged = sameRoute(previous[i].route, rs[current[i]])
 		}
 
-		if r.pathSubtree != "" {
-			addSubtreeLeafsToPath(pathMatchers, path, l, o)
+		if unchanged {
+			next.registrations[id] = previous
+			for i, reg := range previous {
+				if reg.err != nil {
+					errors = append(errors, &definitionError{id, current[i], reg.err})
+				}
+			}
+
 			continue
 		}
 
-		if r.path == "" {
-			rootLeaves = append(rootLeaves, l)
+		for _, reg := range previous {
+			c.remove(reg)
+		}
+
+		for _, i := range current {
+			reg := c.add(rs[i], m.matchingOptions)
+			if reg.err != nil {
+				errors = append(errors, &definitionError{id, i, reg.err})
+			}
+
+			next.registrations[id] = append(next.registrations[id], reg)
+		}
+	}
+
+	for id, previous := range m.registrations {
+		if _, ok := byID[id]; ok {
 			continue
 		}
 
-		if o.ignoreTrailingSlash() {
-			path = trimTrailingSlash(path)
+		for _, reg := range previous {
+			c.remove(reg)
 		}
-		addLeafToPath(pathMatchers, path, l)
 	}
 
-	pathTree := &pathmux.Tree{}
-	errors = append(errors, addTreeMatchers(pathTree, pathMatchers)...)
+	if c.dirtyRoot {
+		next.rootLeaves = c.applyLeaves(m.rootLeaves, c.addedRoot)
+	}
+
+	for p := range c.dirtyPaths {
+		var leaves leafMatchers
+		if pm, ok := m.pathMatchers[p]; ok {
+			leaves = pm.leaves
+		}
+
+		leaves = c.applyLeaves(leaves, c.added[p])
+		if len(leaves) == 0 {
+			delete(next.pathMatchers, p)
+			next.paths = next.paths.Delete(p)
+			continue
+		}
+
+		pm := &pathMatcher{leaves: leaves}
+		paths, err := next.paths.Set(p, pm)
+		if err != nil {
+			errors = append(errors, &definitionError{Index: -1, Original: err})
+			continue
+		}
 
-	// sort root leaves during construction time, based on their priority
-	sort.Stable(rootLeaves)
+		next.pathMatchers[p] = pm
+		next.paths = paths
+	}
 
-	return &matcher{pathTree, rootLeaves, o}, errors
+	return next, errors
 }
 
 // matches a path in the path trie structure.
-func matchPathTree(tree *pathmux.Tree, path string, lrm *leafRequestMatcher) (map[string]string, *leafMatcher) {
+func matchPathTree(tree *pathmux.Tree, path string, lrm pathmux.Matcher) (map[string]string, *leafMatcher) {
 	v, params, value := tree.LookupMatcher(path, lrm)
 	if v == nil {
 		return nil, nil
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"reflect"
 	"regexp"
 	"testing"
 
@@ -101,7 +102,7 @@ func docToRoutes(doc string) ([]*Route, error) {
 	if err != nil {
 		return nil, err
 	}
-	routes, _ := processRouteDefs(Options{Predicates: []PredicateSpec{&truePredicate{}}}, nil, defs)
+	routes, _, _ := processRouteDefs(Options{Predicates: []PredicateSpec{&truePredicate{}}}, nil, defs, nil)
 	return routes, nil
 }
 
@@ -193,7 +194,7 @@ func generateRoutes(paths []string) []*Route {
 		defs[i] = &eskip.Route{Id: fmt.Sprintf("route%d", i), Path: p, Backend: p}
 	}
 
-	routes, _ := processRouteDefs(Options{}, nil, defs)
+	routes, _, _ := processRouteDefs(Options{}, nil, defs, nil)
 	return routes
 }
 
@@ -968,6 +969,166 @@ func TestMatcherWithPathConflict(t *testing.T) {
 	}
 }
 
+func TestMatcherUpdate(t *testing.T) {
+	o := Options{Predicates: []PredicateSpec{&truePredicate{}}}
+	defs, err := eskip.Parse(`
+		unchanged: Path("/unchanged") -> "https://unchanged.example.org";
+		changed: Path("/api") -> "https://v1.example.org";
+		deleted: PathSubtree("/deleted") -> "https://deleted.example.org";
+		catchAll: True() -> "https://catchall.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, _, processed := processRouteDefs(o, nil, defs, nil)
+	m1, errs := newMatcher(routes, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs[0])
+	}
+
+	nextDefs, err := eskip.Parse(`
+		unchanged: Path("/unchanged") -> "https://unchanged.example.org";
+		changed: Path("/api") -> "https://v2.example.org";
+		added: Path("/api") && Header("X-Version", "3") -> "https://v3.example.org";
+		invalid: PathRegexp("**") -> "https://invalid.example.org";
+		catchAll: True() -> "https://catchall.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextRoutes, _, _ := processRouteDefs(o, nil, nextDefs, processed)
+	m2, errs := m1.update(nextRoutes)
+	if len(errs) != 1 || errs[0].ID != "invalid" {
+		t.Fatal("failed to report the invalid route", errs)
+	}
+
+	for _, ti := range []struct {
+		msg      string
+		matcher  *matcher
+		path     string
+		header   string
+		expected string
+	}{{
+		msg:      "previous, unchanged",
+		matcher:  m1,
+		path:     "/unchanged",
+		expected: "https://unchanged.example.org",
+	}, {
+		msg:      "previous, changed",
+		matcher:  m1,
+		path:     "/api",
+		header:   "3",
+		expected: "https://v1.example.org",
+	}, {
+		msg:      "previous, deleted",
+		matcher:  m1,
+		path:     "/deleted/foo",
+		expected: "https://deleted.example.org",
+	}, {
+		msg:      "next, unchanged",
+		matcher:  m2,
+		path:     "/unchanged",
+		expected: "https://unchanged.example.org",
+	}, {
+		msg:      "next, changed",
+		matcher:  m2,
+		path:     "/api",
+		expected: "https://v2.example.org",
+	}, {
+		msg:      "next, added",
+		matcher:  m2,
+		path:     "/api",
+		header:   "3",
+		expected: "https://v3.example.org",
+	}, {
+		msg:      "next, deleted",
+		matcher:  m2,
+		path:     "/deleted/foo",
+		expected: "https://catchall.example.org",
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			req := &http.Request{URL: &url.URL{Path: ti.path}, Header: make(http.Header)}
+			if ti.header != "" {
+				req.Header.Set("X-Version", ti.header)
+			}
+
+			r, _ := ti.matcher.match(req)
+			if r == nil || r.Backend != ti.expected {
+				t.Errorf("failed to match the expected route: %v", r)
+			}
+		})
+	}
+
+	if m1.registrations["unchanged"][0].leaf != m2.registrations["unchanged"][0].leaf {
+		t.Error("failed to keep the leaf of the unchanged route")
+	}
+
+	if m1.registrations["catchAll"][0].leaf != m2.registrations["catchAll"][0].leaf {
+		t.Error("failed to keep the leaf of the unchanged root route")
+	}
+
+	if _, ok := m2.pathMatchers["/deleted"]; ok {
+		t.Error("failed to remove the path of the deleted route")
+	}
+
+	m3, errs := m2.update(nextRoutes)
+	if len(errs) != 1 || errs[0].ID != "invalid" {
+		t.Error("failed to report the invalid route again", errs)
+	}
+
+	if m3.paths != m2.paths {
+		t.Error("unexpected change in the path tree")
+	}
+}
+
+func TestRoutePrecedence(t *testing.T) {
+	rs, err := docToRoutes(`
+		b: Path("/api") && True() -> "https://b.example.org";
+		a: Path("/api") && True() -> "https://a.example.org";
+		weighted: Path("/api") && Weight(1) -> "https://weighted.example.org";
+		conditions: Path("/api") && True() && Header("X-Foo", "bar") -> "https://conditions.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		msg      string
+		options  MatchingOptions
+		header   string
+		expected string
+	}{{
+		msg:      "equal priority decided by route id",
+		expected: "a",
+	}, {
+		msg:      "more conditions",
+		header:   "bar",
+		expected: "conditions",
+	}, {
+		msg:      "explicit weight",
+		options:  ExplicitWeightPrecedence,
+		header:   "bar",
+		expected: "weighted",
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			// the order of the input doesn't matter
+			for _, routes := range [][]*Route{rs, {rs[3], rs[2], rs[1], rs[0]}} {
+				m, errs := newMatcher(routes, ti.options)
+				if len(errs) != 0 {
+					t.Fatal(errs[0])
+				}
+
+				req := &http.Request{URL: &url.URL{Path: "/api"}, Header: http.Header{"X-Foo": []string{ti.header}}}
+				if r, _ := m.match(req); r == nil || r.Id != ti.expected {
+					t.Errorf("failed to match the expected route, got: %v, expected: %s", r, ti.expected)
+				}
+			}
+		})
+	}
+}
+
 func TestMatchToSlash(t *testing.T) {
 	m, err := docToMatcherOpts(`Path("/some/path/") -> "https://example.org"`, IgnoreTrailingSlash)
 	if err != nil {
@@ -1309,3 +1470,79 @@ func BenchmarkConstructionMass(b *testing.B) {
 		}
 	}
 }
+
+func TestMatchHeaderPrecedence(t *testing.T) {
+	rs, err := docToRoutes(`
+		noHeader: Path("/api") -> "https://v1.example.org";
+		exact: Path("/api") && Header("X-Api-Version", "2") -> "https://v2.example.org";
+		regexp: Path("/api") && HeaderRegexp("Accept", "json") && HeaderRegexp("Accept", "v3") -> "https://v3.example.org";
+		otherPath: Path("/other") && Header("X-Api-Version", "2") -> "https://other.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, errs := newMatcher(rs, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	for _, test := range []struct {
+		header   http.Header
+		expected string
+	}{{
+		expected: "noHeader",
+	}, {
+		header:   http.Header{"X-Api-Version": []string{"2"}},
+		expected: "exact",
+	}, {
+		header:   http.Header{"Accept": []string{"application/json"}},
+		expected: "noHeader",
+	}, {
+		header: http.Header{
+			"X-Api-Version": []string{"2"},
+			"Accept":        []string{"application/vnd.v3+json"},
+		},
+		expected: "regexp",
+	}} {
+		req := &http.Request{Method: "GET", URL: &url.URL{Path: "/api"}, Header: test.header}
+		r, _ := m.match(req)
+		if r == nil || r.Id != test.expected {
+			t.Error("failed to match the expected route", test.expected, r)
+		}
+	}
+}
+
+func TestMatchPathParams(t *testing.T) {
+	for _, test := range []struct {
+		route    string
+		path     string
+		expected map[string]string
+	}{{
+		route:    `Path("/users/:id") -> <shunt>`,
+		path:     "/users/42",
+		expected: map[string]string{"id": "42"},
+	}, {
+		route:    `Path("/users/:id/*rest") -> <shunt>`,
+		path:     "/users/42/orders/1",
+		expected: map[string]string{"id": "42", "rest": "/orders/1"},
+	}, {
+		route:    `Path("/files/*") -> <shunt>`,
+		path:     "/files/foo/bar",
+		expected: map[string]string{"*": "/foo/bar"},
+	}} {
+		rs, err := docToRoutes(test.route)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, errs := newMatcher(rs, MatchingOptionsNone)
+		if len(errs) != 0 {
+			t.Fatal(errs)
+		}
+
+		r, params := m.match(&http.Request{Method: "GET", URL: &url.URL{Path: test.path}})
+		if r == nil || !reflect.DeepEqual(params, test.expected) {
+			t.Error("failed to match the path params", test.route, params, test.expected)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	metricsPrefix = "routing."
+
+	// gauges of the active routing table
+	activeRoutesKey     = metricsPrefix + "routes.active"
+	invalidRoutesKey    = metricsPrefix + "routes.invalid"
+	lastUpdateKey       = metricsPrefix + "update.last_success"
+	updateProcessingKey = metricsPrefix + "update.processing"
+
+	// counters
+	rejectedRoutesKey  = metricsPrefix + "routes.rejected"
+	rejectedUpdatesKey = metricsPrefix + "update.rejected"
+
+	// per data client, with the name of the data client and the type of the
+	// request, loadall or loadupdate
+	dataClientLoadKey        = metricsPrefix + "dataclient.%s.%s"
+	dataClientErrorsKey      = metricsPrefix + "dataclient.%s.errors"
+	dataClientTimeoutsKey    = metricsPrefix + "dataclient.%s.timeouts"
+	dataClientLastSuccessKey = metricsPrefix + "dataclient.%s.last_success"
+)
+
+var dataClientNameReplacer = strings.NewReplacer("*", "", ".", "_")
+
+// routingMetrics wraps the optional metrics collector of the routing,
+// it is safe to use when no metrics were configured.
+type routingMetrics struct {
+	metrics metrics.Metrics
+}
+
+// returns the name of a data client used in the metrics keys, based on
+// its type, e.g. kubernetes_Client
+func dataClientName(c DataClient) string {
+	return dataClientNameReplacer.Replace(fmt.Sprintf("%T", c))
+}
+
+func (m routingMetrics) measureLoad(c DataClient, initial bool, start time.Time, err error) {
+	if m.metrics == nil {
+		return
+	}
+
+	name := dataClientName(c)
+	if err != nil {
+		m.metrics.IncCounter(fmt.Sprintf(dataClientErrorsKey, name))
+		if err == errLoadTimeout {
+			m.metrics.IncCounter(fmt.Sprintf(dataClientTimeoutsKey, name))
+		}
+
+		return
+	}
+
+	typ := "loadupdate"
+	if initial {
+		typ = "loadall"
+	}
+
+	m.metrics.MeasureSince(fmt.Sprintf(dataClientLoadKey, name, typ), start)
+	m.metrics.UpdateGauge(fmt.Sprintf(dataClientLastSuccessKey, name), float64(time.Now().Unix()))
+}
+
+func (m routingMetrics) updateApplied(start time.Time, valid, invalid int) {
+	if m.metrics == nil {
+		return
+	}
+
+	m.metrics.MeasureSince(updateProcessingKey, start)
+	m.metrics.UpdateGauge(activeRoutesKey, float64(valid))
+	m.metrics.UpdateGauge(invalidRoutesKey, float64(invalid))
+	m.metrics.UpdateGauge(lastUpdateKey, float64(time.Now().Unix()))
+	if invalid > 0 {
+		m.metrics.IncCounterBy(rejectedRoutesKey, int64(invalid))
+	}
+}
+
+func (m routingMetrics) updateRejected(start time.Time, invalid int) {
+	if m.metrics == nil {
+		return
+	}
+
+	m.metrics.MeasureSince(updateProcessingKey, start)
+	m.metrics.IncCounter(rejectedUpdatesKey)
+	if invalid > 0 {
+		m.metrics.IncCounterBy(rejectedRoutesKey, int64(invalid))
+	}
+}
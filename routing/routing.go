@@ -12,6 +12,7 @@ import (
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/metrics"
 )
 
 const (
@@ -41,12 +42,22 @@ const (
 
 	// IgnoreTrailingSlash indicates that trailing slashes in paths are ignored.
 	IgnoreTrailingSlash MatchingOptions = 1 << iota
+
+	// ExplicitWeightPrecedence indicates that the priority of the routes
+	// with the same path condition is decided only by their Weight
+	// predicate, instead of the Weight predicate and the number of the
+	// other conditions.
+	ExplicitWeightPrecedence
 )
 
 func (o MatchingOptions) ignoreTrailingSlash() bool {
 	return o&IgnoreTrailingSlash > 0
 }
 
+func (o MatchingOptions) explicitWeightPrecedence() bool {
+	return o&ExplicitWeightPrecedence > 0
+}
+
 // DataClient instances provide data sources for
 // route definitions.
 type DataClient interface {
@@ -54,6 +65,15 @@ type DataClient interface {
 	LoadUpdate() ([]*eskip.Route, []string, error)
 }
 
+// UpdateNotifier can be implemented by the data clients that know when
+// their route definitions have changed. The routing requests the updates
+// from these clients as soon as they signal a change on the returned
+// channel, without waiting for the poll timeout. The clients are still
+// polled, too.
+type UpdateNotifier interface {
+	UpdateNotify() <-chan struct{}
+}
+
 // Predicate instances are used as custom user defined route
 // matching predicates.
 type Predicate interface {
@@ -74,6 +94,17 @@ type PredicateSpec interface {
 	Create([]interface{}) (Predicate, error)
 }
 
+// PredicateRegistry contains the predicate specifications, that can be
+// referenced by their names in the route definitions, analogous to
+// filters.Registry.
+type PredicateRegistry map[string]PredicateSpec
+
+// Register adds a predicate specification to the registry. It
+// overrides the previously registered specification with the same name.
+func (r PredicateRegistry) Register(s PredicateSpec) {
+	r[s.Name()] = s
+}
+
 // Options for initialization for routing.
 type Options struct {
 
@@ -87,16 +118,30 @@ type Options struct {
 	MatchingOptions MatchingOptions
 
 	// The timeout between requests to the data
-	// clients for route definition updates.
+	// clients for route definition updates. Data clients
+	// implementing UpdateNotifier are requested earlier
+	// when they signal a change.
 	PollTimeout time.Duration
 
 	// The set of different data clients where the
 	// route definitions are read from.
 	DataClients []DataClient
 
+	// LoadTimeout limits the duration of a single request to a data
+	// client, for the initial set of routes or for an update. A request
+	// exceeding it is handled as a failed request, and is retried. When
+	// zero, the requests are not limited.
+	LoadTimeout time.Duration
+
 	// Specifications of custom, user defined predicates.
 	Predicates []PredicateSpec
 
+	// Registry containing the specifications of custom predicates.
+	// It can be used in addition to, or instead of, the Predicates
+	// field. In case of name collision, the specifications in the
+	// Predicates field take precedence.
+	PredicateRegistry PredicateRegistry
+
 	// Performance tuning option.
 	//
 	// When zero, the newly constructed routing
@@ -127,6 +172,65 @@ type Options struct {
 	// SignalFirstLoad enables signaling on the first load
 	// of the routing configuration during the startup.
 	SignalFirstLoad bool
+
+	// WaitAllDataClients, when set together with SignalFirstLoad, delays
+	// the signaling of the first load until every data client delivered
+	// its initial set of routes.
+	WaitAllDataClients bool
+
+	// FirstLoadTimeout, when set together with SignalFirstLoad, limits
+	// how long the first load is waited for. When it expires, the first
+	// load is signaled anyway with the routes received so far, possibly
+	// none, while the data clients that have failed are retried in the
+	// background.
+	FirstLoadTimeout time.Duration
+
+	// RejectInvalidUpdates enables the validation gate for the updates
+	// of the routing table. When set, a routing table containing any
+	// invalid route, e.g. with a filter that cannot be created or a
+	// backend address that cannot be parsed, is rejected as a whole,
+	// and the previous routing table stays active until a valid update
+	// is received.
+	RejectInvalidUpdates bool
+
+	// UpdateChecks are executed against every freshly built routing
+	// table before it is applied. When any of them fails, the routing
+	// table is rejected, and the previous one stays active.
+	UpdateChecks []UpdateCheck
+
+	// Metrics is used to collect metrics about the routing table and its
+	// updates, e.g. the number of active and invalid routes, the duration
+	// of loading the routes from the data clients, or the time of the last
+	// successful update. When not set, no metrics are collected.
+	Metrics metrics.Metrics
+}
+
+// UpdateCheck is used to validate a freshly built routing table before it
+// gets applied, e.g. by checking that critical requests are still matched
+// by the expected routes. The lookup argument allows matching requests
+// against the new routing table, while routes contains its valid route
+// definitions. When it returns an error, the routing table is rejected.
+type UpdateCheck func(lookup *RouteLookup, routes []*eskip.Route) error
+
+// ExpectRoute returns an UpdateCheck that verifies that a request with the
+// given method and URL is matched by the route with the given id.
+func ExpectRoute(method, url, routeID string) UpdateCheck {
+	return func(lookup *RouteLookup, _ []*eskip.Route) error {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			return err
+		}
+
+		r, _ := lookup.Do(req)
+		switch {
+		case r == nil:
+			return fmt.Errorf("no route found for %s %s, expected: %s", method, url, routeID)
+		case r.Id != routeID:
+			return fmt.Errorf("route %s found for %s %s, expected: %s", r.Id, method, url, routeID)
+		default:
+			return nil
+		}
+	}
 }
 
 // RouteFilter contains extensions to generic filter
@@ -144,6 +248,11 @@ type RouteFilter struct {
 // backends.
 type LBEndpoint struct {
 	Scheme, Host string
+
+	// State tracks the in-flight requests and the failures of the
+	// endpoint. It is set by the load balancer post-processor, and can
+	// be nil.
+	State *LBEndpointState
 }
 
 // LBAlgorithm implementations apply a load balancing algorithm
@@ -298,7 +407,12 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	routes := slice(rt.validRoutes, offset, limit)
 	if strings.Contains(req.Header.Get("Accept"), "application/json") {
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(routes); err != nil {
+		enc := json.NewEncoder(w)
+		if extractPrettyJSON(req) {
+			enc.SetIndent("", "  ")
+		}
+
+		if err := enc.Encode(routes); err != nil {
 			http.Error(
 				w,
 				http.StatusText(http.StatusInternalServerError),
@@ -312,19 +426,36 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	eskip.Fprint(w, extractPretty(req), routes...)
 }
 
+func (r *Routing) signalFirstLoad() {
+	if !r.firstLoadSignaled {
+		close(r.firstLoad)
+		r.firstLoadSignaled = true
+	}
+}
+
 func (r *Routing) startReceivingUpdates(o Options) {
 	c := make(chan *routeTable)
 	go receiveRouteMatcher(o, c, r.quit)
 	go func() {
+		var firstLoadTimeout <-chan time.Time
+		if !r.firstLoadSignaled && o.FirstLoadTimeout > 0 {
+			firstLoadTimeout = time.After(o.FirstLoadTimeout)
+		}
+
 		for {
 			select {
 			case rt := <-c:
 				r.routeTable.Store(rt)
-				if !r.firstLoadSignaled {
-					close(r.firstLoad)
-					r.firstLoadSignaled = true
+				if !o.WaitAllDataClients || rt.complete {
+					r.signalFirstLoad()
 				}
+
 				r.log.Info("route settings applied")
+			case <-firstLoadTimeout:
+				if !r.firstLoadSignaled {
+					r.log.Warn("timeout while waiting for the initial routes of the data clients, continuing with partial routing table")
+					r.signalFirstLoad()
+				}
 			case <-r.quit:
 				return
 			}
@@ -421,3 +552,19 @@ func extractPretty(r *http.Request) eskip.PrettyPrintInfo {
 	}
 	return eskip.PrettyPrintInfo{Pretty: false, IndentStr: ""}
 }
+
+// the JSON output is indented only when the pretty parameter is set, to
+// keep the default output unchanged
+func extractPrettyJSON(r *http.Request) bool {
+	vals, ok := r.Form["pretty"]
+	if !ok || len(vals) == 0 {
+		return false
+	}
+
+	switch vals[0] {
+	case "", "1", "true":
+		return true
+	default:
+		return false
+	}
+}
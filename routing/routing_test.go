@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +19,8 @@ import (
 	"github.com/zalando/skipper/filters/builtin"
 	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/predicates/primitive"
 	"github.com/zalando/skipper/routing"
 	"github.com/zalando/skipper/routing/testdataclient"
 )
@@ -383,6 +386,220 @@ func TestProcessesFilterDefinitions(t *testing.T) {
 	}
 }
 
+func TestReusesUnchangedRoutes(t *testing.T) {
+	fr := make(filters.Registry)
+	fr.Register(&filtertest.Filter{FilterName: "filter1"})
+
+	dc, err := testdataclient.NewDoc(`
+		route1: Path("/route1") -> filter1("foo") -> "https://route1.example.org";
+		route2: Path("/route2") -> filter1("bar") -> "https://route2.example.org";
+		route3: Path("/route3") -> filter1("baz") -> "https://route3.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := newTestRoutingWithFilters(fr, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	before1, err := tr.checkGetRequest("https://www.example.org/route1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before2, err := tr.checkGetRequest("https://www.example.org/route2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.log.Reset()
+	if err := dc.UpdateDoc(`route2: Path("/route2") -> filter1("qux") -> "https://route2.example.org"`, []string{"route3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.waitForRouteSetting(); err != nil {
+		t.Fatal(err)
+	}
+
+	after1, err := tr.checkGetRequest("https://www.example.org/route1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after2, err := tr.checkGetRequest("https://www.example.org/route2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after1 != before1 || after1.Filters[0].Filter != before1.Filters[0].Filter {
+		t.Error("failed to reuse the unchanged route")
+	}
+
+	if after2.Filters[0].Filter == before2.Filters[0].Filter || after2.Filters[0].Filter.(*filtertest.Filter).Args[0] != "qux" {
+		t.Error("failed to update the changed route")
+	}
+
+	if r, _ := tr.checkGetRequest("https://www.example.org/route3"); r != nil {
+		t.Error("failed to delete route")
+	}
+}
+
+func TestValidationGate(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		options  routing.Options
+		update   string
+		rejected bool
+	}{{
+		msg:    "invalid routes skipped by default",
+		update: `route1: Path("/route1") -> "https://new.example.org"; invalid: Path("/invalid") -> notAFilter() -> <shunt>`,
+	}, {
+		msg:      "invalid routes rejected",
+		options:  routing.Options{RejectInvalidUpdates: true},
+		update:   `route1: Path("/route1") -> "https://new.example.org"; invalid: Path("/invalid") -> notAFilter() -> <shunt>`,
+		rejected: true,
+	}, {
+		msg:     "valid update accepted",
+		options: routing.Options{RejectInvalidUpdates: true},
+		update:  `route1: Path("/route1") -> "https://new.example.org"`,
+	}, {
+		msg: "update check passes",
+		options: routing.Options{UpdateChecks: []routing.UpdateCheck{
+			routing.ExpectRoute("GET", "https://www.example.org/health", "health"),
+		}},
+		update: `route1: Path("/route1") -> "https://new.example.org"`,
+	}, {
+		msg: "update check fails",
+		options: routing.Options{UpdateChecks: []routing.UpdateCheck{
+			routing.ExpectRoute("GET", "https://www.example.org/health", "health"),
+		}},
+		update:   `route1: Path("/route1") -> "https://new.example.org"; health2: Path("/health") && True() -> <shunt>`,
+		rejected: true,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			dc, err := testdataclient.NewDoc(`
+				route1: Path("/route1") -> "https://old.example.org";
+				health: Path("/health") -> status(200) -> <shunt>;
+			`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tl := loggingtest.New()
+			defer tl.Close()
+
+			o := ti.options
+			o.FilterRegistry = builtin.MakeRegistry()
+			o.Predicates = []routing.PredicateSpec{primitive.NewTrue()}
+			o.DataClients = []routing.DataClient{dc}
+			o.PollTimeout = pollTimeout
+			o.Log = tl
+			rt := routing.New(o)
+			defer rt.Close()
+
+			tr := &testRouting{tl, rt}
+			if err := tr.waitForRouteSetting(); err != nil {
+				t.Fatal(err)
+			}
+
+			tl.Reset()
+			if err := dc.UpdateDoc(ti.update, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if ti.rejected {
+				if err := tl.WaitFor("routing table update rejected", 12*pollTimeout); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := tr.waitForRouteSetting(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := tr.checkGetRequest("https://www.example.org/route1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := "https://new.example.org"
+			if ti.rejected {
+				expected = "https://old.example.org"
+			}
+
+			if r.Backend != expected {
+				t.Errorf("unexpected backend, got: %s, expected: %s", r.Backend, expected)
+			}
+		})
+	}
+}
+
+func TestRoutingMetrics(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		route1: Path("/route1") -> "https://route1.example.org";
+		route2: Path("/route2") -> "https://route2.example.org";
+		invalid: Path("/invalid") -> notAFilter() -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	m := &metricstest.MockMetrics{}
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		Metrics:        m,
+	})
+	defer rt.Close()
+
+	tr := &testRouting{tl, rt}
+	if err := tr.waitForRouteSetting(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]float64{
+		"routing.routes.active":  2,
+		"routing.routes.invalid": 1,
+	} {
+		if v, ok := m.Gauge(key); !ok || v != expected {
+			t.Errorf("unexpected value for %s, got: %v, expected: %v", key, v, expected)
+		}
+	}
+
+	for _, key := range []string{
+		"routing.update.last_success",
+		"routing.dataclient.testdataclient_Client.last_success",
+	} {
+		if v, ok := m.Gauge(key); !ok || v <= 0 {
+			t.Errorf("missing timestamp for %s", key)
+		}
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c["routing.routes.rejected"] != 1 {
+			t.Errorf("unexpected rejected routes count: %d", c["routing.routes.rejected"])
+		}
+	})
+
+	m.WithMeasures(func(measures map[string][]time.Duration) {
+		for _, key := range []string{
+			"routing.update.processing",
+			"routing.dataclient.testdataclient_Client.loadall",
+		} {
+			if len(measures[key]) == 0 {
+				t.Errorf("missing measurement: %s", key)
+			}
+		}
+	})
+}
+
 func TestProcessesPredicates(t *testing.T) {
 	dc, err := testdataclient.NewDoc(`
         route1: CustomPredicate("custom1") -> "https://route1.example.org";
@@ -430,6 +647,44 @@ func TestProcessesPredicates(t *testing.T) {
 	}
 }
 
+func TestPredicateRegistry(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		route1: CustomPredicate("custom1") -> "https://route1.example.org";
+		catchAll: * -> "https://route.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := make(routing.PredicateRegistry)
+	pr.Register(&predicate{})
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry:    builtin.MakeRegistry(),
+		PredicateRegistry: pr,
+		DataClients:       []routing.DataClient{dc},
+		PollTimeout:       pollTimeout,
+		Log:               tl,
+	})
+	defer rt.Close()
+
+	if err := tl.WaitFor("route settings applied", 12*pollTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set(predicateHeader, "custom1")
+	if r, _ := rt.Route(req); r == nil || r.Backend != "https://route1.example.org" {
+		t.Error("failed to match the route with the registered predicate")
+	}
+}
+
 // TestNonMatchedStaticRoute for bug #116: non-matched static route suppress wild-carded route
 func TestNonMatchedStaticRoute(t *testing.T) {
 	dc, err := testdataclient.NewDoc(`
@@ -618,6 +873,47 @@ func TestRoutingHandlerJsonResponse(t *testing.T) {
 	}
 }
 
+func TestRoutingHandlerPrettyJsonResponse(t *testing.T) {
+	dc, _ := testdataclient.NewDoc(`route1: Path("/foo") -> "https://route1.example.org"`)
+	tr, _ := newTestRoutingWithPredicates(nil, dc)
+	defer tr.close()
+
+	server := httptest.NewServer(tr.routing)
+	defer server.Close()
+
+	for _, ti := range []struct {
+		query    string
+		indented bool
+	}{
+		{"", false},
+		{"?pretty", true},
+		{"?pretty=true", true},
+		{"?pretty=false", false},
+	} {
+		req, _ := http.NewRequest("GET", server.URL+ti.query, nil)
+		req.Header.Set("accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if indented := strings.Contains(string(body), "\n  "); indented != ti.indented {
+			t.Errorf("%q: indented: %v, expected: %v", ti.query, indented, ti.indented)
+		}
+
+		var routes []*eskip.Route
+		if err := json.Unmarshal(body, &routes); err != nil || len(routes) != 1 {
+			t.Errorf("%q: invalid response: %v", ti.query, err)
+		}
+	}
+}
+
 func TestRoutingHandlerFilterInvalidRoutes(t *testing.T) {
 	dc, _ := testdataclient.NewDoc(`
         route1: CustomPredicate("custom1") -> "https://route1.example.org";
@@ -909,3 +1205,133 @@ func TestSignalFirstLoad(t *testing.T) {
 		}
 	})
 }
+
+type blockingDataClient struct {
+	release chan struct{}
+	routes  []*eskip.Route
+}
+
+func newBlockingDataClient(routes ...*eskip.Route) *blockingDataClient {
+	return &blockingDataClient{release: make(chan struct{}), routes: routes}
+}
+
+func (c *blockingDataClient) LoadAll() ([]*eskip.Route, error) {
+	<-c.release
+	return c.routes, nil
+}
+
+func (c *blockingDataClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	return nil, nil, nil
+}
+
+func TestLoadTimeout(t *testing.T) {
+	dc := newBlockingDataClient(&eskip.Route{Id: "route1", Path: "/some-path", Backend: "https://www.example.org"})
+
+	l := loggingtest.New()
+	defer l.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    3 * time.Millisecond,
+		LoadTimeout:    3 * time.Millisecond,
+		Log:            l,
+	})
+
+	defer rt.Close()
+
+	if err := l.WaitFor("error while receiveing initial data", 120*time.Millisecond); err != nil {
+		t.Fatal("failed to time out", err)
+	}
+
+	close(dc.release)
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal("failed to receive route settings", err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/some-path", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _ := rt.Route(req); r == nil || r.Id != "route1" {
+		t.Error("failed to match the route")
+	}
+}
+
+func TestWaitAllDataClients(t *testing.T) {
+	waitFirstLoad := func(rt *routing.Routing, to time.Duration) bool {
+		select {
+		case <-rt.FirstLoad():
+			return true
+		case <-time.After(to):
+			return false
+		}
+	}
+
+	t.Run("waits for all", func(t *testing.T) {
+		dc1 := testdataclient.New([]*eskip.Route{{Id: "route1", Path: "/foo", Backend: "https://foo.example.org"}})
+		dc2 := newBlockingDataClient(&eskip.Route{Id: "route2", Path: "/bar", Backend: "https://bar.example.org"})
+
+		l := loggingtest.New()
+		defer l.Close()
+
+		rt := routing.New(routing.Options{
+			SignalFirstLoad:    true,
+			WaitAllDataClients: true,
+			FilterRegistry:     builtin.MakeRegistry(),
+			DataClients:        []routing.DataClient{dc1, dc2},
+			PollTimeout:        3 * time.Millisecond,
+			Log:                l,
+		})
+
+		defer rt.Close()
+
+		if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+			t.Fatal("failed to receive route settings", err)
+		}
+
+		if waitFirstLoad(rt, 15*time.Millisecond) {
+			t.Fatal("the first load was signaled before all the data clients delivered")
+		}
+
+		close(dc2.release)
+		if !waitFirstLoad(rt, 120*time.Millisecond) {
+			t.Error("the first load was not signaled")
+		}
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		dc1 := testdataclient.New([]*eskip.Route{{Id: "route1", Path: "/foo", Backend: "https://foo.example.org"}})
+		dc2 := newBlockingDataClient(&eskip.Route{Id: "route2", Path: "/bar", Backend: "https://bar.example.org"})
+		defer close(dc2.release)
+
+		l := loggingtest.New()
+		defer l.Close()
+
+		rt := routing.New(routing.Options{
+			SignalFirstLoad:    true,
+			WaitAllDataClients: true,
+			FirstLoadTimeout:   15 * time.Millisecond,
+			FilterRegistry:     builtin.MakeRegistry(),
+			DataClients:        []routing.DataClient{dc1, dc2},
+			PollTimeout:        3 * time.Millisecond,
+			Log:                l,
+		})
+
+		defer rt.Close()
+
+		if !waitFirstLoad(rt, 120*time.Millisecond) {
+			t.Fatal("the first load was not signaled")
+		}
+
+		req, err := http.NewRequest("GET", "https://www.example.org/foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r, _ := rt.Route(req); r == nil || r.Id != "route1" {
+			t.Error("failed to match the route of the available data client")
+		}
+	})
+}
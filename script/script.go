@@ -3,13 +3,17 @@ package script
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	lua "github.com/yuin/gopher-lua"
 	"github.com/zalando/skipper/filters"
@@ -27,6 +31,13 @@ var InitialPoolSize int = 3
 // requests, but only this number is cached.
 var MaxPoolSize int = 10
 
+// MaxBodySize is the default maximum size of the request and response bodies
+// in bytes, that the scripts can access. It can be changed per route with the
+// lua-max-body-size filter parameter.
+var MaxBodySize int64 = 1 << 20
+
+const optionPrefix = "lua-"
+
 type luaScript struct{}
 
 // NewLuaScript creates a new filter spec for skipper
@@ -48,22 +59,66 @@ func (ls *luaScript) CreateFilter(config []interface{}) (filters.Filter, error)
 	if !ok {
 		return nil, filters.ErrInvalidFilterParameters
 	}
-	var params []string
+	s := &script{source: src, maxBodySize: MaxBodySize}
 	for _, p := range config[1:] {
 		ps, ok := p.(string)
 		if !ok {
 			return nil, filters.ErrInvalidFilterParameters
 		}
-		params = append(params, ps)
+
+		if strings.HasPrefix(ps, optionPrefix) {
+			if err := s.setOption(ps); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		s.routeParams = append(s.routeParams, ps)
 	}
 
-	s := &script{source: src, routeParams: params}
 	if err := s.initScript(); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
 
+// sets the options of the filter, that limit the resources available to the
+// script:
+//
+// - lua-timeout: the maximum execution time of the request() and response()
+// functions, e.g. lua-timeout=50ms
+// - lua-call-stack-size: the maximum depth of the lua call stack
+// - lua-registry-size: the size of the lua data stack
+// - lua-max-body-size: the maximum size of the request and response bodies
+// in bytes, that the script can access
+func (s *script) setOption(o string) error {
+	parts := strings.SplitN(strings.TrimPrefix(o, optionPrefix), "=", 2)
+	if len(parts) != 2 {
+		return filters.ErrInvalidFilterParameters
+	}
+
+	var err error
+	switch parts[0] {
+	case "timeout":
+		s.timeout, err = time.ParseDuration(parts[1])
+	case "call-stack-size":
+		s.callStackSize, err = strconv.Atoi(parts[1])
+	case "registry-size":
+		s.registrySize, err = strconv.Atoi(parts[1])
+	case "max-body-size":
+		s.maxBodySize, err = strconv.ParseInt(parts[1], 10, 64)
+	default:
+		return filters.ErrInvalidFilterParameters
+	}
+
+	if err != nil {
+		return filters.ErrInvalidFilterParameters
+	}
+
+	return nil
+}
+
 func (s *script) getState() (*lua.LState, error) {
 	select {
 	case L := <-s.pool:
@@ -89,7 +144,10 @@ func (s *script) putState(L *lua.LState) {
 }
 
 func (s *script) newState() (*lua.LState, error) {
-	l := lua.NewState()
+	l := lua.NewState(lua.Options{
+		CallStackSize: s.callStackSize,
+		RegistrySize:  s.registrySize,
+	})
 	l.PreloadModule("base64", base64.Loader)
 	l.PreloadModule("http", gluahttp.NewHttpModule(&http.Client{}).Loader)
 	l.PreloadModule("url", gluaurl.Loader)
@@ -136,9 +194,13 @@ func (s *script) initScript() error {
 }
 
 type script struct {
-	source      string
-	routeParams []string
-	pool        chan *lua.LState
+	source        string
+	routeParams   []string
+	pool          chan *lua.LState
+	timeout       time.Duration
+	callStackSize int
+	registrySize  int
+	maxBodySize   int64
 }
 
 func (s *script) Request(f filters.FilterContext) {
@@ -155,13 +217,21 @@ func (s *script) runFunc(name string, f filters.FilterContext) {
 		log.Printf("ERROR: %s", err)
 		return
 	}
-	defer s.putState(L)
 
 	fn := L.GetGlobal(name)
 	if fn.Type() != lua.LTFunction {
+		s.putState(L)
 		return
 	}
 
+	var ctx context.Context
+	if s.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		L.SetContext(ctx)
+	}
+
 	pt := L.NewTable()
 	for _, p := range s.routeParams {
 		parts := strings.SplitN(p, "=", 2)
@@ -183,6 +253,19 @@ func (s *script) runFunc(name string, f filters.FilterContext) {
 	if err != nil {
 		fmt.Printf("Error calling %s from %s: %s", name, s.source, err)
 	}
+
+	if ctx != nil {
+		L.RemoveContext()
+
+		// the execution interrupted by the timeout may have left the
+		// state inconsistent
+		if ctx.Err() != nil {
+			L.Close()
+			return
+		}
+	}
+
+	s.putState(L)
 }
 
 func (s *script) filterContextAsLuaTable(L *lua.LState, f filters.FilterContext) *lua.LTable {
@@ -195,7 +278,7 @@ func (s *script) filterContextAsLuaTable(L *lua.LState, f filters.FilterContext)
 
 	// add metatable to dynamically access fields in the request
 	req_mt := L.NewTable()
-	req_mt.RawSet(lua.LString("__index"), L.NewFunction(getRequestValue(f)))
+	req_mt.RawSet(lua.LString("__index"), L.NewFunction(getRequestValue(f, s.maxBodySize)))
 	req_mt.RawSet(lua.LString("__newindex"), L.NewFunction(setRequestValue(f)))
 	L.SetMetatable(req, req_mt)
 
@@ -222,6 +305,10 @@ func (s *script) filterContextAsLuaTable(L *lua.LState, f filters.FilterContext)
 	reshdr_mt.RawSet(lua.LString("__newindex"), L.NewFunction(setResponseHeader(f)))
 	L.SetMetatable(reshdr, reshdr_mt)
 	res.RawSet(lua.LString("header"), reshdr)
+	res_mt := L.NewTable()
+	res_mt.RawSet(lua.LString("__index"), L.NewFunction(getResponseValue(f, s.maxBodySize)))
+	res_mt.RawSet(lua.LString("__newindex"), L.NewFunction(setResponseValue(f)))
+	L.SetMetatable(res, res_mt)
 	t.RawSet(lua.LString("response"), res)
 
 	// finally
@@ -292,7 +379,41 @@ func serveHeaderWalk(h http.Header) func(lua.LValue, lua.LValue) {
 	}
 }
 
-func getRequestValue(f filters.FilterContext) func(*lua.LState) int {
+type readAheadBody struct {
+	io.Reader
+	io.Closer
+}
+
+// reads the body into memory, up to the limit, and returns the replacement
+// of the original body. When the body exceeds the limit, the read part is
+// put back in front of the rest of the body.
+func readBody(body io.ReadCloser, limit int64) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+
+	r := io.Reader(body)
+	if limit > 0 {
+		r = io.LimitReader(body, limit+1)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, body, err
+	}
+
+	if limit > 0 && int64(len(b)) > limit {
+		return nil, &readAheadBody{
+			Reader: io.MultiReader(bytes.NewReader(b), body),
+			Closer: body,
+		}, errors.New("body too large")
+	}
+
+	body.Close()
+	return b, ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func getRequestValue(f filters.FilterContext, maxBodySize int64) func(*lua.LState) int {
 	return func(s *lua.LState) int {
 		key := s.ToString(-1)
 		var ret lua.LValue
@@ -311,6 +432,20 @@ func getRequestValue(f filters.FilterContext) func(*lua.LState) int {
 			ret = lua.LString(f.Request().Method)
 		case "url":
 			ret = lua.LString(f.Request().URL.String())
+		case "path":
+			ret = lua.LString(f.Request().URL.Path)
+		case "raw_query":
+			ret = lua.LString(f.Request().URL.RawQuery)
+		case "body":
+			r := f.Request()
+			b, body, err := readBody(r.Body, maxBodySize)
+			r.Body = body
+			if err != nil {
+				log.Printf("ERROR reading the request body: %s", err)
+				ret = lua.LNil
+			} else {
+				ret = lua.LString(b)
+			}
 		default:
 			ret = lua.LNil
 		}
@@ -332,6 +467,20 @@ func setRequestValue(f filters.FilterContext) func(*lua.LState) int {
 				return 1
 			}
 			f.Request().URL = u
+		case "path":
+			f.Request().URL.Path = s.ToString(-1)
+			f.Request().URL.RawPath = ""
+		case "raw_query":
+			f.Request().URL.RawQuery = s.ToString(-1)
+		case "body":
+			b := s.ToString(-1)
+			r := f.Request()
+			if r.Body != nil {
+				r.Body.Close()
+			}
+
+			r.Body = ioutil.NopCloser(strings.NewReader(b))
+			r.ContentLength = int64(len(b))
 		default:
 			// do nothing for now
 		}
@@ -444,3 +593,56 @@ func setResponseHeader(f filters.FilterContext) func(*lua.LState) int {
 		return 0
 	}
 }
+
+func getResponseValue(f filters.FilterContext, maxBodySize int64) func(*lua.LState) int {
+	return func(s *lua.LState) int {
+		key := s.ToString(-1)
+		var ret lua.LValue
+		switch key {
+		case "status_code":
+			ret = lua.LNumber(f.Response().StatusCode)
+		case "body":
+			r := f.Response()
+			b, body, err := readBody(r.Body, maxBodySize)
+			r.Body = body
+			if err != nil {
+				log.Printf("ERROR reading the response body: %s", err)
+				ret = lua.LNil
+			} else {
+				ret = lua.LString(b)
+			}
+		default:
+			ret = lua.LNil
+		}
+		s.Push(ret)
+		return 1
+	}
+}
+
+func setResponseValue(f filters.FilterContext) func(*lua.LState) int {
+	return func(s *lua.LState) int {
+		key := s.ToString(-2)
+		switch key {
+		case "status_code":
+			n, ok := s.Get(-1).(lua.LNumber)
+			if !ok {
+				s.Push(lua.LString("invalid type, need a number"))
+				return 1
+			}
+			f.Response().StatusCode = int(n)
+		case "body":
+			b := s.ToString(-1)
+			r := f.Response()
+			if r.Body != nil {
+				r.Body.Close()
+			}
+
+			r.Body = ioutil.NopCloser(strings.NewReader(b))
+			r.ContentLength = int64(len(b))
+			r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		default:
+			// do nothing for now
+		}
+		return 0
+	}
+}
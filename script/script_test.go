@@ -1,8 +1,11 @@
 package script
 
 import (
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/zalando/skipper/filters"
@@ -140,3 +143,164 @@ func TestSetHeader(t *testing.T) {
 		t.Errorf("failed to set request header value")
 	}
 }
+
+func TestOptions(t *testing.T) {
+	code := `function request(ctx, params); ctx.state_bag["timeout"] = params["lua-timeout"]; end`
+	for _, test := range []struct {
+		name      string
+		options   []interface{}
+		returnsOK bool
+	}{
+		{"timeout", []interface{}{"lua-timeout=50ms"}, true},
+		{"all", []interface{}{"lua-timeout=50ms", "lua-call-stack-size=64", "lua-registry-size=1024", "lua-max-body-size=1024"}, true},
+		{"invalid timeout", []interface{}{"lua-timeout=fast"}, false},
+		{"invalid size", []interface{}{"lua-max-body-size=large"}, false},
+		{"missing value", []interface{}{"lua-timeout"}, false},
+		{"unknown option", []interface{}{"lua-foo=bar"}, false},
+	} {
+		ls := &luaScript{}
+		scr, err := ls.CreateFilter(append([]interface{}{code}, test.options...))
+		if (err == nil) != test.returnsOK {
+			t.Errorf("test %s returns unexpected error value: %v", test.name, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		fc := &luaContext{bag: make(map[string]interface{})}
+		scr.Request(fc)
+		if _, ok := fc.bag["timeout"]; ok {
+			t.Errorf("test %s: option passed to the script", test.name)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	code := `function request(ctx, params); while true do end; end`
+	ls := &luaScript{}
+	scr, err := ls.CreateFilter([]interface{}{code, "lua-timeout=30ms"})
+	if err != nil {
+		t.Fatalf("failed to compile test code: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scr.Request(&luaContext{bag: make(map[string]interface{})})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("failed to interrupt the script")
+	}
+}
+
+func TestRequestFields(t *testing.T) {
+	code := `function request(ctx, params)
+		ctx.state_bag["path"] = ctx.request.path
+		ctx.state_bag["query"] = ctx.request.raw_query
+		ctx.state_bag["body"] = ctx.request.body
+		ctx.request.path = "/bar"
+		ctx.request.raw_query = "baz=qux"
+		ctx.request.body = string.upper(ctx.request.body)
+	end`
+	ls := &luaScript{}
+	scr, err := ls.CreateFilter([]interface{}{code})
+	if err != nil {
+		t.Fatalf("failed to compile test code: %s", err)
+	}
+
+	req, _ := http.NewRequest("POST", "http://www.example.com/foo?bar=baz", strings.NewReader("Hello, world!"))
+	fc := &luaContext{
+		bag:     make(map[string]interface{}),
+		request: req,
+	}
+
+	scr.Request(fc)
+	if fc.bag["path"] != "/foo" || fc.bag["query"] != "bar=baz" || fc.bag["body"] != "Hello, world!" {
+		t.Errorf("failed to get request values: %v", fc.bag)
+	}
+
+	if req.URL.Path != "/bar" || req.URL.RawQuery != "baz=qux" {
+		t.Errorf("failed to set request url: %v", req.URL)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "HELLO, WORLD!" || req.ContentLength != int64(len(b)) {
+		t.Errorf("failed to set request body: %s, %d", string(b), req.ContentLength)
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	code := `function request(ctx, params); if ctx.request.body == nil then ctx.state_bag["body"] = "nil"; end; end`
+	ls := &luaScript{}
+	scr, err := ls.CreateFilter([]interface{}{code, "lua-max-body-size=5"})
+	if err != nil {
+		t.Fatalf("failed to compile test code: %s", err)
+	}
+
+	req, _ := http.NewRequest("POST", "http://www.example.com/", strings.NewReader("Hello, world!"))
+	fc := &luaContext{
+		bag:     make(map[string]interface{}),
+		request: req,
+	}
+
+	scr.Request(fc)
+	if fc.bag["body"] != "nil" {
+		t.Error("failed to deny access to the body")
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "Hello, world!" {
+		t.Errorf("failed to preserve the request body: %s", string(b))
+	}
+}
+
+func TestResponseFields(t *testing.T) {
+	code := `function response(ctx, params)
+		if ctx.response.status_code == 404 then
+			ctx.response.status_code = 200
+			ctx.response.body = "not " .. ctx.response.body
+		end
+	end`
+	ls := &luaScript{}
+	scr, err := ls.CreateFilter([]interface{}{code})
+	if err != nil {
+		t.Fatalf("failed to compile test code: %s", err)
+	}
+
+	rsp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("found")),
+	}
+
+	fc := &luaContext{
+		bag:      make(map[string]interface{}),
+		response: rsp,
+	}
+
+	scr.Response(fc)
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("failed to set the status code: %d", rsp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "not found" || rsp.Header.Get("Content-Length") != "9" {
+		t.Errorf("failed to set the response body: %s, %s", string(b), rsp.Header.Get("Content-Length"))
+	}
+}
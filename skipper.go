@@ -8,11 +8,13 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,8 +25,15 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/circuit"
+	"github.com/zalando/skipper/dataclients/consul"
+	"github.com/zalando/skipper/dataclients/git"
 	"github.com/zalando/skipper/dataclients/kubernetes"
+	"github.com/zalando/skipper/dataclients/postgres"
+	redisdc "github.com/zalando/skipper/dataclients/redis"
 	"github.com/zalando/skipper/dataclients/routestring"
+	"github.com/zalando/skipper/dataclients/s3"
+	"github.com/zalando/skipper/dataclients/xds"
+	"github.com/zalando/skipper/dataclients/zookeeper"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/eskipfile"
 	"github.com/zalando/skipper/etcd"
@@ -32,19 +41,24 @@ import (
 	"github.com/zalando/skipper/filters/apiusagemonitoring"
 	"github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/cache"
+	cookiefilter "github.com/zalando/skipper/filters/cookie"
 	logfilter "github.com/zalando/skipper/filters/log"
 	"github.com/zalando/skipper/innkeeper"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
+	snet "github.com/zalando/skipper/net"
 	pauth "github.com/zalando/skipper/predicates/auth"
 	"github.com/zalando/skipper/predicates/cookie"
+	"github.com/zalando/skipper/predicates/host"
 	"github.com/zalando/skipper/predicates/interval"
 	"github.com/zalando/skipper/predicates/methods"
 	"github.com/zalando/skipper/predicates/query"
 	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/predicates/traffic"
 	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/proxyprotocol"
 	"github.com/zalando/skipper/queuelistener"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/routing"
@@ -52,6 +66,8 @@ import (
 	"github.com/zalando/skipper/secrets"
 	"github.com/zalando/skipper/swarm"
 	"github.com/zalando/skipper/tracing"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
@@ -72,6 +88,12 @@ type Options struct {
 	// to 0.
 	WaitForHealthcheckInterval time.Duration
 
+	// ShutdownDrainTimeout sets how long skipper waits for the in-flight
+	// requests to finish during the shutdown, after it stopped accepting
+	// new connections. The remaining connections are closed after the
+	// timeout. Defaults to 0, meaning no timeout.
+	ShutdownDrainTimeout time.Duration
+
 	// StatusChecks is an experimental feature. It defines a
 	// comma separated list of HTTP URLs to do GET requests to,
 	// that have to return 200 before skipper becomes ready
@@ -83,6 +105,13 @@ type Options struct {
 	// Network address that skipper should listen on.
 	Address string
 
+	// EnableProxyProtocol makes the listener expect the PROXY protocol
+	// header, version 1 or 2, on every incoming connection, e.g. from a
+	// load balancer in front of skipper, and use the client address
+	// received in it. The header is waited for up to
+	// ReadHeaderTimeoutServer.
+	EnableProxyProtocol bool
+
 	// EnableTCPQueue is an experimental feature. It enables controlling the
 	// concurrently processed requests at the TCP listener.
 	EnableTCPQueue bool
@@ -116,15 +145,39 @@ type Options struct {
 	// Path prefix for skipper related data in the etcd storage.
 	EtcdPrefix string
 
+	// Additional etcd path prefixes, whose routes are merged with
+	// the routes under EtcdPrefix.
+	EtcdPrefixes []string
+
 	// Timeout used for a single request when querying for updates
 	// in etcd. This is independent of, and an addition to,
 	// SourcePollTimeout. When not set, the internally defined 1s
 	// is used.
 	EtcdWaitTimeout time.Duration
 
+	// Timeout of the etcd requests other than querying for updates.
+	// When not set, EtcdWaitTimeout is used.
+	EtcdRequestTimeout time.Duration
+
+	// Number of retries of the etcd requests failing with transient
+	// errors, with exponential backoff.
+	EtcdMaxRetries int
+
+	// Number of consecutive failed etcd requests, after which the etcd
+	// client stops sending requests for a while. 0 disables it.
+	EtcdDegradedThreshold int
+
 	// Skip TLS certificate check for etcd connections.
 	EtcdInsecure bool
 
+	// Path to a CA certificate bundle used to verify the etcd endpoints.
+	EtcdCAFile string
+
+	// Paths to the client certificate and key for etcd client certificate
+	// authentication.
+	EtcdCertFile string
+	EtcdKeyFile  string
+
 	// If set this value is used as Bearer token for etcd OAuth authorization.
 	EtcdOAuthToken string
 
@@ -134,6 +187,81 @@ type Options struct {
 	// If set this value is used as password for etcd basic authorization.
 	EtcdPassword string
 
+	// If set, skipper uses the etcd v3 API via the etcd JSON gateway.
+	// The gateway must be available on the etcd endpoints under the /v3
+	// path, the native gRPC protocol is not used.
+	EtcdV3 bool
+
+	// If set, the route expressions written to etcd are compressed.
+	EtcdCompress bool
+
+	// Address of a Consul agent, used to read the route definitions
+	// from the Consul key/value store.
+	ConsulAddress string
+
+	// Key prefix for skipper related data in the Consul key/value store.
+	ConsulPrefix string
+
+	// If set this value is used as ACL token for Consul.
+	ConsulToken string
+
+	// Address of a Redis server, used to read the route definitions.
+	// The server needs to have the keyspace notifications enabled.
+	RedisRoutesAddress string
+
+	// Key prefix of the routes stored in Redis.
+	RedisRoutesPrefix string
+
+	// Name of an AWS S3 bucket, containing eskip documents with the
+	// route definitions.
+	S3RoutesBucket string
+
+	// Key prefix of the eskip documents in the S3 bucket.
+	S3RoutesPrefix string
+
+	// AWS region of the S3 bucket.
+	S3RoutesRegion string
+
+	// URL of a git repository, containing eskip files with the route
+	// definitions.
+	GitRoutesRepository string
+
+	// Branch of the git repository. Defaults to the default branch.
+	GitRoutesBranch string
+
+	// Path of the directory in the git repository containing the eskip
+	// files.
+	GitRoutesPath string
+
+	// Addresses of ZooKeeper servers, storing the route definitions.
+	ZookeeperServers []string
+
+	// Path of the znode containing the route definitions. Defaults to
+	// /skipper.
+	ZookeeperPrefix string
+
+	// URL of an xDS management server, providing route configurations
+	// for Envoy proxies. The grpc:// and grpcs:// URLs select the
+	// aggregated discovery service over gRPC, the http:// and https://
+	// URLs the REST-JSON variant of the xDS protocol.
+	XDSServer string
+
+	// Node ID identifying skipper to the xDS management server.
+	// Defaults to the hostname.
+	XDSNodeID string
+
+	// Names of the xDS route configurations to load. Defaults to all.
+	XDSRouteConfigurations []string
+
+	// Connection URL of a PostgreSQL database, storing the route
+	// definitions. Connecting without TLS (sslmode=disable) is allowed
+	// only to databases on loopback addresses or unix sockets.
+	PostgresRoutesURL string
+
+	// Name of the PostgreSQL table storing the route definitions.
+	// Defaults to routes.
+	PostgresRoutesTable string
+
 	// If set enables skipper to generate based on ingress resources in kubernetes cluster
 	Kubernetes bool
 
@@ -222,6 +350,10 @@ type Options struct {
 	// InlineRoutes can define routes as eskip text.
 	InlineRoutes string
 
+	// RoutesURLs are HTTP(S) URLs of remote eskip documents, that are
+	// polled for updates.
+	RoutesURLs []string
+
 	// Polling timeout of the routing data sources.
 	SourcePollTimeout time.Duration
 
@@ -258,6 +390,10 @@ type Options struct {
 	// Defines MaxHeaderBytes for server http connections.
 	MaxHeaderBytes int
 
+	// MaxRequestBodySize limits the size of the request bodies
+	// forwarded to the backends, in bytes. 0 means no limit.
+	MaxRequestBodySize int64
+
 	// Enable connection state metrics for server http connections.
 	EnableConnMetricsServer bool
 
@@ -291,6 +427,19 @@ type Options struct {
 	// limit.
 	MaxIdleConnsBackend int
 
+	// MaxConnsPerHostBackend limits the total number of connections
+	// to a single backend host, 0 means no limit.
+	MaxConnsPerHostBackend int
+
+	// IdleConnTimeoutBackend sets how long the idle backend
+	// connections are kept open. When zero, CloseIdleConnsPeriod
+	// is used.
+	IdleConnTimeoutBackend time.Duration
+
+	// EnableConnectionPoolMetrics enables the metrics of the open,
+	// created and reused backend connections by backend host.
+	EnableConnectionPoolMetrics bool
+
 	// DisableHTTPKeepalives sets DisableKeepAlives, which forces
 	// a backend to always create a new connection.
 	DisableHTTPKeepalives bool
@@ -299,6 +448,23 @@ type Options struct {
 	// lookup.
 	IgnoreTrailingSlash bool
 
+	// ExplicitWeightPrecedence indicates that the priority of the routes
+	// with the same path condition is decided only by their Weight
+	// predicate, and not by the number of their conditions. Routes with
+	// the same priority are ordered by their id.
+	ExplicitWeightPrecedence bool
+
+	// RejectInvalidRouteUpdates enables the validation gate for the
+	// routing table updates: when any of the routes in an update is
+	// invalid, the whole update is rejected, and the previous routing
+	// table stays active.
+	RejectInvalidRouteUpdates bool
+
+	// RouteUpdateChecks are executed against every new version of the
+	// routing table before it is applied. When any of them fails, the
+	// previous routing table stays active.
+	RouteUpdateChecks []routing.UpdateCheck
+
 	// Priority routes that are matched against the requests before
 	// the standard routes from the data clients.
 	PriorityRoutes []proxy.PriorityRoute
@@ -313,6 +479,20 @@ type Options struct {
 	// of routes were applied.
 	WaitFirstRouteLoad bool
 
+	// WaitAllDataClients, when used with WaitFirstRouteLoad, delays starting
+	// the listener until every data client delivered its initial routes.
+	WaitAllDataClients bool
+
+	// FirstRouteLoadTimeout, when used with WaitFirstRouteLoad, limits how
+	// long the first batch of routes is waited for. When it expires, the
+	// listener is started with the routes received so far.
+	FirstRouteLoadTimeout time.Duration
+
+	// DataClientLoadTimeout limits the duration of a single request to a
+	// data client. The requests exceeding it are handled as failed, and
+	// retried. When not set, the requests are not limited.
+	DataClientLoadTimeout time.Duration
+
 	// SuppressRouteUpdateLogs indicates to log only summaries of the routing updates
 	// instead of full details of the updated/deleted routes.
 	SuppressRouteUpdateLogs bool
@@ -454,9 +634,32 @@ type Options struct {
 	// Client TLS to connect to Backends
 	ClientTLS *tls.Config
 
+	// EgressProxy is the URL of the proxy used to connect to the
+	// backends, with the http, https or socks5 scheme, e.g.
+	// socks5://proxy.example.org:1080. When not set, the backends are
+	// connected directly.
+	EgressProxy string
+
 	// Flush interval for upgraded Proxy connections
 	BackendFlushInterval time.Duration
 
+	// ResponseFlushInterval defines how often the proxied response bodies
+	// are flushed to the clients. When zero, they are flushed after every
+	// read from the backend. Server sent events are always flushed
+	// immediately.
+	ResponseFlushInterval time.Duration
+
+	// ResponseBufferSize is the size of the backend response bodies, in
+	// bytes, that are read into memory before sending them to the
+	// clients, releasing the backend connections early. 0 means no
+	// buffering.
+	ResponseBufferSize int
+
+	// ResponseWriteTimeout limits the duration of the individual writes
+	// of the responses to the clients. When exceeded, the response is
+	// aborted and the backend connection is released. 0 means no limit.
+	ResponseWriteTimeout time.Duration
+
 	// Experimental feature to handle protocol Upgrades for Websockets, SPDY, etc.
 	ExperimentalUpgrade bool
 
@@ -464,6 +667,33 @@ type Options struct {
 	// and the response messages during web socket upgrades.
 	ExperimentalUpgradeAudit bool
 
+	// UpgradeIdleTimeout closes the upgraded connections, e.g. web sockets,
+	// when no data was sent in either direction for the configured
+	// duration. When zero, the idle connections are not closed.
+	UpgradeIdleTimeout time.Duration
+
+	// EnableGRPC enables the gRPC mode of the proxy: the errors of the gRPC
+	// calls are sent as gRPC status codes, and the gRPC status of the calls
+	// is measured and logged. When the proxy listens without TLS, it
+	// accepts HTTP/2 with prior knowledge (h2c), too, to support the gRPC
+	// clients calling it in cleartext.
+	EnableGRPC bool
+
+	// RetryPolicy configures retrying the failed backend requests. When
+	// not set, only the requests to load balanced backends are retried
+	// once, when dialing the selected endpoint failed.
+	RetryPolicy *proxy.RetryPolicy
+
+	// PassiveHealthCheck configures the ejection of the unhealthy
+	// endpoints of the load balanced backends. The zero value enables
+	// the ejection after consecutive failures with the default settings.
+	PassiveHealthCheck loadbalancer.PassiveHealthCheck
+
+	// ActiveHealthCheck configures the periodic checks of the endpoints
+	// of the load balanced backends. Disabled when the interval is not
+	// set.
+	ActiveHealthCheck loadbalancer.ActiveHealthCheck
+
 	// MaxLoopbacks defines the maximum number of loops that the proxy can execute when the routing table
 	// contains loop backends (<loopback>).
 	MaxLoopbacks int
@@ -559,6 +789,44 @@ type Options struct {
 	// header, in this case you want to set this to true.
 	ReverseSourcePredicate bool
 
+	// SourcePredicateTrustedProxies is a list of IP addresses or CIDR
+	// networks of the proxies in front of Skipper. When set, the
+	// Source and SourceFromLast predicates use the address of the
+	// first untrusted hop in the X-Forwarded-For header, instead of
+	// the first or the last entry.
+	SourcePredicateTrustedProxies []string
+
+	// ForwardedHeadersPolicy defines how the X-Forwarded-For,
+	// X-Forwarded-Proto, X-Forwarded-Host and Forwarded headers of the
+	// incoming requests are handled: kept, appended, overwritten or
+	// stripped. Defaults to keeping them untouched.
+	ForwardedHeadersPolicy snet.ForwardedHeadersPolicy
+
+	// ForwardedHeadersTrustedProxies is a list of IP addresses or CIDR
+	// networks of the proxies in front of Skipper, whose forwarded
+	// headers are preserved by the append and overwrite policies.
+	ForwardedHeadersTrustedProxies []string
+
+	// EnableForwardedHeader enables setting the standard Forwarded
+	// header, RFC 7239, together with the X-Forwarded-* headers.
+	EnableForwardedHeader bool
+
+	// RequestHeadersAllow, when not empty, is the list of the request
+	// headers forwarded to the backends. Other headers are removed.
+	RequestHeadersAllow []string
+
+	// RequestHeadersDeny is the list of the request headers that are
+	// not forwarded to the backends.
+	RequestHeadersDeny []string
+
+	// ResponseHeadersAllow, when not empty, is the list of the response
+	// headers returned to the clients. Other headers are removed.
+	ResponseHeadersAllow []string
+
+	// ResponseHeadersDeny is the list of the response headers that are
+	// not returned to the clients.
+	ResponseHeadersDeny []string
+
 	// OAuthTokeninfoURL sets the the URL to be queried for
 	// information for all auth.NewOAuthTokeninfo*() filters.
 	OAuthTokeninfoURL string
@@ -569,9 +837,36 @@ type Options struct {
 	// OAuthTokenintrospectionTimeout sets timeout duration while calling oauth tokenintrospection service
 	OAuthTokenintrospectionTimeout time.Duration
 
+	// OAuthTokeninfoCacheTTL enables caching the valid tokeninfo
+	// responses for the given duration, but not longer than the
+	// expiration of the token.
+	OAuthTokeninfoCacheTTL time.Duration
+
+	// OAuthTokenintrospectionCacheTTL enables caching the active token
+	// introspection responses for the given duration, but not longer
+	// than the expiration of the token.
+	OAuthTokenintrospectionCacheTTL time.Duration
+
 	// OIDCSecretsFile path to the file containing key to encrypt OpenID token
 	OIDCSecretsFile string
 
+	// CookieSecretsFile path to the file containing the comma separated
+	// secrets to encrypt the cookies with the encryptResponseCookie filter
+	CookieSecretsFile string
+
+	// ResponseCacheSize sets the maximum size of the in-memory store of
+	// the responseCache filter, in bytes. Defaults to 64MB.
+	ResponseCacheSize int
+
+	// ResponseCacheRedisAddress, when set, makes the responseCache
+	// filter store the responses in Redis instead of the memory.
+	ResponseCacheRedisAddress string
+
+	// ResponseCacheStore, when set, is used by the responseCache filter
+	// to store the responses, and the other response cache options are
+	// ignored.
+	ResponseCacheStore cache.Store
+
 	// SecretsRegistry to store and load secretsencrypt
 	SecretsRegistry *secrets.Registry
 
@@ -640,6 +935,16 @@ func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.Data
 		clients = append(clients, f)
 	}
 
+	for _, u := range o.RoutesURLs {
+		rc, err := eskipfile.Remote(eskipfile.RemoteOptions{URL: u})
+		if err != nil {
+			log.Error("error while initializing remote eskip client", err)
+			return nil, err
+		}
+
+		clients = append(clients, rc)
+	}
+
 	if o.InlineRoutes != "" {
 		ir, err := routestring.New(o.InlineRoutes)
 		if err != nil {
@@ -669,13 +974,22 @@ func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.Data
 
 	if len(o.EtcdUrls) > 0 {
 		etcdClient, err := etcd.New(etcd.Options{
-			Endpoints:  o.EtcdUrls,
-			Prefix:     o.EtcdPrefix,
-			Timeout:    o.EtcdWaitTimeout,
-			Insecure:   o.EtcdInsecure,
-			OAuthToken: o.EtcdOAuthToken,
-			Username:   o.EtcdUsername,
-			Password:   o.EtcdPassword,
+			Endpoints:         o.EtcdUrls,
+			Prefix:            o.EtcdPrefix,
+			Prefixes:          o.EtcdPrefixes,
+			Timeout:           o.EtcdWaitTimeout,
+			RequestTimeout:    o.EtcdRequestTimeout,
+			MaxRetries:        o.EtcdMaxRetries,
+			DegradedThreshold: o.EtcdDegradedThreshold,
+			Insecure:          o.EtcdInsecure,
+			CAFile:            o.EtcdCAFile,
+			CertFile:          o.EtcdCertFile,
+			KeyFile:           o.EtcdKeyFile,
+			OAuthToken:        o.EtcdOAuthToken,
+			Username:          o.EtcdUsername,
+			Password:          o.EtcdPassword,
+			V3:                o.EtcdV3,
+			Compress:          o.EtcdCompress,
 		})
 
 		if err != nil {
@@ -685,6 +999,101 @@ func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.Data
 		clients = append(clients, etcdClient)
 	}
 
+	if o.ConsulAddress != "" {
+		consulClient, err := consul.New(consul.Options{
+			Address: o.ConsulAddress,
+			Prefix:  o.ConsulPrefix,
+			Token:   o.ConsulToken,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, consulClient)
+	}
+
+	if o.RedisRoutesAddress != "" {
+		redisClient, err := redisdc.New(redisdc.Options{
+			Address: o.RedisRoutesAddress,
+			Prefix:  o.RedisRoutesPrefix,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, redisClient)
+	}
+
+	if o.S3RoutesBucket != "" {
+		s3Client, err := s3.New(s3.Options{
+			Bucket: o.S3RoutesBucket,
+			Prefix: o.S3RoutesPrefix,
+			Region: o.S3RoutesRegion,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, s3Client)
+	}
+
+	if o.GitRoutesRepository != "" {
+		gitClient, err := git.New(git.Options{
+			Repository: o.GitRoutesRepository,
+			Branch:     o.GitRoutesBranch,
+			Path:       o.GitRoutesPath,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, gitClient)
+	}
+
+	if len(o.ZookeeperServers) > 0 {
+		zkClient, err := zookeeper.New(zookeeper.Options{
+			Servers: o.ZookeeperServers,
+			Prefix:  o.ZookeeperPrefix,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, zkClient)
+	}
+
+	if o.XDSServer != "" {
+		xdsClient, err := xds.New(xds.Options{
+			Server:              o.XDSServer,
+			NodeID:              o.XDSNodeID,
+			RouteConfigurations: o.XDSRouteConfigurations,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, xdsClient)
+	}
+
+	if o.PostgresRoutesURL != "" {
+		postgresClient, err := postgres.New(postgres.Options{
+			URL:   o.PostgresRoutesURL,
+			Table: o.PostgresRoutesTable,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, postgresClient)
+	}
+
 	if o.Kubernetes {
 		kubernetesClient, err := kubernetes.New(kubernetes.Options{
 			KubernetesInCluster:        o.KubernetesInCluster,
@@ -761,7 +1170,25 @@ func (o *Options) isHTTPS() bool {
 	return (o.ProxyTLS != nil) || (o.CertPathTLS != "" && o.KeyPathTLS != "")
 }
 
+// wraps the listener, when the PROXY protocol is enabled
+func proxyProtocolListener(o *Options, l net.Listener) net.Listener {
+	if !o.EnableProxyProtocol {
+		return l
+	}
+
+	return proxyprotocol.NewListener(l, proxyprotocol.Options{HeaderTimeout: o.ReadHeaderTimeoutServer})
+}
+
 func listen(o *Options, mtr metrics.Metrics) (net.Listener, error) {
+	l, err := listenTCP(o, mtr)
+	if err != nil {
+		return nil, err
+	}
+
+	return proxyProtocolListener(o, l), nil
+}
+
+func listenTCP(o *Options, mtr metrics.Metrics) (net.Listener, error) {
 	if o.Address == "" {
 		o.Address = ":http"
 	}
@@ -811,12 +1238,36 @@ func listen(o *Options, mtr metrics.Metrics) (net.Listener, error) {
 	})
 }
 
+// serverHealth is served on the support listener as /healthz. It starts
+// failing when the shutdown signal was received, so that the load balancer
+// in front can take the instance out of its pool before the connections
+// are drained.
+type serverHealth struct {
+	shuttingDown int32
+}
+
+func (h *serverHealth) shutdown() {
+	if h != nil {
+		atomic.StoreInt32(&h.shuttingDown, 1)
+	}
+}
+
+func (h *serverHealth) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if atomic.LoadInt32(&h.shuttingDown) != 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func listenAndServeQuit(
 	proxy http.Handler,
 	o *Options,
 	sigs chan os.Signal,
 	idleConnsCH chan struct{},
 	mtr metrics.Metrics,
+	health *serverHealth,
 ) error {
 	// create the access log handler
 	log.Infof("proxy listener on %v", o.Address)
@@ -838,6 +1289,42 @@ func listenAndServeQuit(
 		}
 	}
 
+	// making idleConnsCH and sigs optional parameters is required to be able to tear down a server
+	// from the tests
+	if idleConnsCH == nil {
+		idleConnsCH = make(chan struct{})
+	}
+
+	if sigs == nil {
+		sigs = make(chan os.Signal, 1)
+	}
+
+	go func() {
+		signal.Notify(sigs, syscall.SIGTERM)
+
+		<-sigs
+
+		health.shutdown()
+		log.Infof("Got shutdown signal, wait %v for health check", o.WaitForHealthcheckInterval)
+		time.Sleep(o.WaitForHealthcheckInterval)
+
+		log.Info("Start shutdown")
+		ctx := context.Background()
+		if o.ShutdownDrainTimeout > 0 {
+			var cancel func()
+			ctx, cancel = context.WithTimeout(ctx, o.ShutdownDrainTimeout)
+			defer cancel()
+		}
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Errorf("Failed to graceful shutdown: %v", err)
+
+			// closing the connections with requests still in progress
+			srv.Close()
+		}
+		close(idleConnsCH)
+	}()
+
 	if o.isHTTPS() {
 		if o.ProxyTLS != nil {
 			srv.TLSConfig = o.ProxyTLS
@@ -861,34 +1348,36 @@ func listenAndServeQuit(
 			o.KeyPathTLS = ""
 			srv.TLSConfig = tlsCfg
 		}
-		return srv.ListenAndServeTLS(o.CertPathTLS, o.KeyPathTLS)
-	}
-	log.Infof("TLS settings not found, defaulting to HTTP")
-
-	// making idleConnsCH and sigs optional parameters is required to be able to tear down a server
-	// from the tests
-	if idleConnsCH == nil {
-		idleConnsCH = make(chan struct{})
-	}
 
-	if sigs == nil {
-		sigs = make(chan os.Signal, 1)
-	}
-
-	go func() {
-		signal.Notify(sigs, syscall.SIGTERM)
+		var err error
+		if o.EnableProxyProtocol {
+			address := o.Address
+			if address == "" {
+				address = ":https"
+			}
 
-		<-sigs
+			var l net.Listener
+			if l, err = net.Listen("tcp", address); err != nil {
+				return err
+			}
 
-		log.Infof("Got shutdown signal, wait %v for health check", o.WaitForHealthcheckInterval)
-		time.Sleep(o.WaitForHealthcheckInterval)
+			err = srv.ServeTLS(proxyProtocolListener(o, l), o.CertPathTLS, o.KeyPathTLS)
+		} else {
+			err = srv.ListenAndServeTLS(o.CertPathTLS, o.KeyPathTLS)
+		}
 
-		log.Info("Start shutdown")
-		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Errorf("Failed to graceful shutdown: %v", err)
+		if err != http.ErrServerClosed {
+			return err
 		}
-		close(idleConnsCH)
-	}()
+
+		<-idleConnsCH
+		log.Infof("done.")
+		return nil
+	}
+	log.Infof("TLS settings not found, defaulting to HTTP")
+	if o.EnableGRPC {
+		srv.Handler = h2c.NewHandler(proxy, &http2.Server{IdleTimeout: o.IdleTimeoutServer})
+	}
 
 	l, err := listen(o, mtr)
 	if err != nil {
@@ -906,7 +1395,7 @@ func listenAndServeQuit(
 }
 
 func listenAndServe(proxy http.Handler, o *Options) error {
-	return listenAndServeQuit(proxy, o, nil, nil, nil)
+	return listenAndServeQuit(proxy, o, nil, nil, nil, nil)
 }
 
 func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
@@ -1016,6 +1505,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 			Timeout:      o.OAuthTokeninfoTimeout,
 			MaxIdleConns: o.IdleConnectionsPerHost,
 			Tracer:       tracer,
+			CacheTTL:     o.OAuthTokeninfoCacheTTL,
 		}
 
 		o.CustomFilters = append(o.CustomFilters,
@@ -1043,6 +1533,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		Timeout:      o.OAuthTokenintrospectionTimeout,
 		MaxIdleConns: o.IdleConnectionsPerHost,
 		Tracer:       tracer,
+		CacheTTL:     o.OAuthTokenintrospectionCacheTTL,
 	}
 
 	who := auth.WebhookOptions{
@@ -1051,9 +1542,27 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		Tracer:       tracer,
 	}
 
+	jwo := auth.JWTValidationOptions{
+		MaxIdleConns: o.IdleConnectionsPerHost,
+		Tracer:       tracer,
+	}
+
+	responseCacheStore := o.ResponseCacheStore
+	if responseCacheStore == nil && o.ResponseCacheRedisAddress != "" {
+		responseCacheStore, err = cache.NewRedisStore(cache.RedisStoreOptions{Address: o.ResponseCacheRedisAddress})
+		if err != nil {
+			return err
+		}
+	} else if responseCacheStore == nil {
+		responseCacheStore = cache.NewMemoryStore(o.ResponseCacheSize)
+	}
+
 	o.CustomFilters = append(o.CustomFilters,
 		logfilter.NewAuditLog(o.MaxAuditBody),
 		auth.NewBearerInjector(sp),
+		auth.NewHmacVerify(sp),
+		auth.NewHmacSign(sp),
+		auth.NewCSRF(sp),
 		auth.TokenintrospectionWithOptions(auth.NewOAuthTokenintrospectionAnyClaims, tio),
 		auth.TokenintrospectionWithOptions(auth.NewOAuthTokenintrospectionAllClaims, tio),
 		auth.TokenintrospectionWithOptions(auth.NewOAuthTokenintrospectionAnyKV, tio),
@@ -1063,10 +1572,14 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		auth.TokenintrospectionWithOptions(auth.NewSecureOAuthTokenintrospectionAnyKV, tio),
 		auth.TokenintrospectionWithOptions(auth.NewSecureOAuthTokenintrospectionAllKV, tio),
 		auth.WebhookWithOptions(who),
+		auth.JWTValidationWithOptions(jwo),
 		auth.NewOAuthOidcUserInfos(o.OIDCSecretsFile, o.SecretsRegistry),
 		auth.NewOAuthOidcAnyClaims(o.OIDCSecretsFile, o.SecretsRegistry),
 		auth.NewOAuthOidcAllClaims(o.OIDCSecretsFile, o.SecretsRegistry),
 		auth.NewOIDCQueryClaimsFilter(),
+		cookiefilter.NewEncryptResponseCookie(o.CookieSecretsFile, o.SecretsRegistry),
+		cookiefilter.NewDecryptRequestCookie(o.CookieSecretsFile, o.SecretsRegistry),
+		cache.New(responseCacheStore),
 		apiusagemonitoring.NewApiUsageMonitoring(
 			o.ApiUsageMonitoringEnable,
 			o.ApiUsageMonitoringRealmKeys,
@@ -1086,7 +1599,11 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	// create the proxy instance
 	var mo routing.MatchingOptions
 	if o.IgnoreTrailingSlash {
-		mo = routing.IgnoreTrailingSlash
+		mo |= routing.IgnoreTrailingSlash
+	}
+
+	if o.ExplicitWeightPrecedence {
+		mo |= routing.ExplicitWeightPrecedence
 	}
 
 	// ensure a non-zero poll timeout
@@ -1100,16 +1617,27 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		updateBuffer = 0
 	}
 
+	sourcePredicate, sourceFromLastPredicate := source.New(), source.NewFromLast()
+	if len(o.SourcePredicateTrustedProxies) > 0 {
+		trusted, err := snet.ParseCIDRs(o.SourcePredicateTrustedProxies)
+		if err != nil {
+			return fmt.Errorf("invalid source predicate trusted proxies: %w", err)
+		}
+
+		sourcePredicate, sourceFromLastPredicate = source.NewWithTrustedProxies(trusted)
+	}
+
 	// include bundled custom predicates
 	o.CustomPredicates = append(o.CustomPredicates,
-		source.New(),
-		source.NewFromLast(),
+		sourcePredicate,
+		sourceFromLastPredicate,
 		interval.NewBetween(),
 		interval.NewBefore(),
 		interval.NewAfter(),
 		cron.New(),
 		cookie.New(),
 		query.New(),
+		query.NewExact(),
 		traffic.New(),
 		primitive.NewTrue(),
 		primitive.NewFalse(),
@@ -1118,6 +1646,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		pauth.NewJWTPayloadAllKVRegexp(),
 		pauth.NewJWTPayloadAnyKVRegexp(),
 		methods.New(),
+		host.New(),
 	)
 
 	schedulerRegistry := scheduler.RegistryWith(scheduler.Options{
@@ -1126,22 +1655,35 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	})
 	defer schedulerRegistry.Close()
 
+	endpointRegistry := loadbalancer.NewEndpointRegistry()
+	activeHealthChecker := loadbalancer.NewActiveHealthChecker(o.ActiveHealthCheck, endpointRegistry)
+	defer activeHealthChecker.Close()
+
 	// create a routing engine
 	ro := routing.Options{
 		FilterRegistry:  registry,
 		MatchingOptions: mo,
 		PollTimeout:     o.SourcePollTimeout,
+		LoadTimeout:     o.DataClientLoadTimeout,
 		DataClients:     dataClients,
 		Predicates:      o.CustomPredicates,
 		UpdateBuffer:    updateBuffer,
 		SuppressLogs:    o.SuppressRouteUpdateLogs,
 		PostProcessors: []routing.PostProcessor{
 			loadbalancer.HealthcheckPostProcessor{LB: lbInstance},
-			loadbalancer.NewAlgorithmProvider(),
+			loadbalancer.NewAlgorithmProviderWithOptions(loadbalancer.Options{
+				PassiveHealthCheck: o.PassiveHealthCheck,
+				Registry:           endpointRegistry,
+			}),
 			schedulerRegistry,
 			builtin.NewRouteCreationMetrics(mtr),
 		},
-		SignalFirstLoad: o.WaitFirstRouteLoad,
+		SignalFirstLoad:      o.WaitFirstRouteLoad,
+		WaitAllDataClients:   o.WaitAllDataClients,
+		FirstLoadTimeout:     o.FirstRouteLoadTimeout,
+		RejectInvalidUpdates: o.RejectInvalidRouteUpdates,
+		UpdateChecks:         o.RouteUpdateChecks,
+		Metrics:              mtr,
 	}
 	if o.DefaultFilters != nil {
 		ro.PreProcessors = []routing.PreProcessor{o.DefaultFilters}
@@ -1149,6 +1691,18 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	routing := routing.New(ro)
 	defer routing.Close()
 
+	forwardedTrustedProxies, err := snet.ParseCIDRs(o.ForwardedHeadersTrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid forwarded headers trusted proxies: %w", err)
+	}
+
+	var egressProxy *url.URL
+	if o.EgressProxy != "" {
+		if egressProxy, err = proxy.ParseEgressProxy(o.EgressProxy); err != nil {
+			return fmt.Errorf("invalid egress proxy: %w", err)
+		}
+	}
+
 	proxyFlags := proxy.Flags(o.ProxyOptions) | o.ProxyFlags
 	proxyParams := proxy.Params{
 		Routing:                  routing,
@@ -1157,8 +1711,14 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		IdleConnectionsPerHost:   o.IdleConnectionsPerHost,
 		CloseIdleConnsPeriod:     o.CloseIdleConnsPeriod,
 		FlushInterval:            o.BackendFlushInterval,
+		ResponseFlushInterval:    o.ResponseFlushInterval,
+		ResponseBufferSize:       o.ResponseBufferSize,
+		ResponseWriteTimeout:     o.ResponseWriteTimeout,
 		ExperimentalUpgrade:      o.ExperimentalUpgrade,
 		ExperimentalUpgradeAudit: o.ExperimentalUpgradeAudit,
+		UpgradeIdleTimeout:       o.UpgradeIdleTimeout,
+		GRPC:                     o.EnableGRPC,
+		RetryPolicy:              o.RetryPolicy,
 		MaxLoopbacks:             o.MaxLoopbacks,
 		DefaultHTTPStatus:        o.DefaultHTTPStatus,
 		LoadBalancer:             lbInstance,
@@ -1169,9 +1729,27 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		DualStack:                o.DualStackBackend,
 		TLSHandshakeTimeout:      o.TLSHandshakeTimeoutBackend,
 		MaxIdleConns:             o.MaxIdleConnsBackend,
+		MaxConnsPerHost:          o.MaxConnsPerHostBackend,
+		IdleConnTimeout:          o.IdleConnTimeoutBackend,
+		ConnectionPoolMetrics:    o.EnableConnectionPoolMetrics,
 		DisableHTTPKeepalives:    o.DisableHTTPKeepalives,
+		MaxRequestBodySize:       o.MaxRequestBodySize,
 		AccessLogDisabled:        o.AccessLogDisabled,
 		ClientTLS:                o.ClientTLS,
+		EgressProxy:              egressProxy,
+		ForwardedHeaders: snet.ForwardedHeaders{
+			Policy:         o.ForwardedHeadersPolicy,
+			TrustedProxies: forwardedTrustedProxies,
+			Forwarded:      o.EnableForwardedHeader,
+		},
+		RequestHeaders: proxy.HeaderFilter{
+			Allow: o.RequestHeadersAllow,
+			Deny:  o.RequestHeadersDeny,
+		},
+		ResponseHeaders: proxy.HeaderFilter{
+			Allow: o.ResponseHeadersAllow,
+			Deny:  o.ResponseHeadersDeny,
+		},
 	}
 
 	var swarmer ratelimit.Swarmer
@@ -1262,8 +1840,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		supportListener = o.MetricsListener
 	}
 
+	health := &serverHealth{}
 	if supportListener != "" {
 		mux := http.NewServeMux()
+		mux.Handle("/healthz", health)
 		mux.Handle("/routes", routing)
 		mux.Handle("/routes/", routing)
 
@@ -1317,7 +1897,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	// wait for the first route configuration to be loaded if enabled:
 	<-routing.FirstLoad()
 
-	return listenAndServeQuit(proxy, &o, sig, idleConnsCH, mtr)
+	return listenAndServeQuit(proxy, &o, sig, idleConnsCH, mtr, health)
 }
 
 // Run skipper.
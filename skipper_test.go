@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/signal"
 	"sync"
@@ -301,3 +302,106 @@ func TestHTTPServerShutdown(t *testing.T) {
 	wg.Wait()
 	time.Sleep(d)
 }
+
+func TestHTTPServerShutdownDrainTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{
+		Address:              l.Addr().String(),
+		ShutdownDrainTimeout: 100 * time.Millisecond,
+	}
+
+	l.Close()
+
+	dc, err := routestring.New(`r0: * -> latency("3s") -> status(200) -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	sigs := make(chan os.Signal, 1)
+	health := &serverHealth{}
+	done := make(chan error, 1)
+	go func() { done <- listenAndServeQuit(proxy, &o, sigs, nil, nil, health) }()
+
+	requestDone := make(chan error, 1)
+	go func() {
+		rsp, err := waitConnGet("http://" + o.Address)
+		if err == nil {
+			rsp.Body.Close()
+		}
+
+		requestDone <- err
+	}()
+
+	// the request is in progress when the shutdown starts
+	time.Sleep(300 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	health.ServeHTTP(w, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("invalid health status before shutdown: %d", w.Code)
+	}
+
+	sigs <- syscall.SIGTERM
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failed to shut down after the drain timeout")
+	}
+
+	w = httptest.NewRecorder()
+	health.ServeHTTP(w, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("invalid health status after shutdown: %d", w.Code)
+	}
+
+	if err := <-requestDone; err == nil {
+		t.Error("failed to close the connection of the request in progress")
+	}
+}
+
+func TestListenProxyProtocol(t *testing.T) {
+	o := Options{Address: "127.0.0.1:0", EnableProxyProtocol: true}
+	l, err := listen(&o, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	if _, err := c.Write([]byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ac.Close()
+	if ac.RemoteAddr().String() != "192.168.0.1:56324" {
+		t.Errorf("failed to use the client address from the PROXY protocol header: %v", ac.RemoteAddr())
+	}
+}